@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// expandAlias replaces a user-defined alias used as the command name by its
+// expansion. Flags given before and after the alias are kept in place.
+// Built-in commands always win over aliases, and expansions are not expanded
+// again, so aliases cannot loop.
+func expandAlias(root *cobra.Command, args []string, aliases map[string]string) ([]string, error) {
+	if len(aliases) == 0 {
+		return args, nil
+	}
+
+	index := commandNameIndex(root, args)
+	if index < 0 {
+		return args, nil
+	}
+
+	name := args[index]
+	expansion, exists := aliases[name]
+	if !exists || isBuiltinCommand(root, name) {
+		return args, nil
+	}
+
+	expanded, err := splitAliasArgs(expansion)
+	if err != nil {
+		return nil, fmt.Errorf("invalid alias '%s': %w", name, err)
+	}
+	if len(expanded) == 0 {
+		return args, nil
+	}
+
+	result := make([]string, 0, len(args)+len(expanded))
+	result = append(result, args[:index]...)
+	result = append(result, expanded...)
+	result = append(result, args[index+1:]...)
+	return result, nil
+}
+
+// commandNameIndex returns the index of the first argument that is not a
+// global flag or a global flag value, or -1
+func commandNameIndex(root *cobra.Command, args []string) int {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			return -1
+		case !strings.HasPrefix(arg, "-"):
+			return i
+		case !strings.Contains(arg, "=") && flagTakesValue(root, arg):
+			i++ // skip the flag's value
+		}
+	}
+	return -1
+}
+
+// flagTakesValue reports whether a global flag such as --config or -v is
+// followed by a separate value argument
+func flagTakesValue(root *cobra.Command, arg string) bool {
+	var flag *pflag.Flag
+	if name, ok := strings.CutPrefix(arg, "--"); ok {
+		flag = root.PersistentFlags().Lookup(name)
+	} else if shorthand := strings.TrimPrefix(arg, "-"); len(shorthand) == 1 {
+		flag = root.PersistentFlags().ShorthandLookup(shorthand)
+	}
+	return flag != nil && flag.NoOptDefVal == ""
+}
+
+// isBuiltinCommand reports whether name is a command or command alias of root
+func isBuiltinCommand(root *cobra.Command, name string) bool {
+	if name == "help" {
+		return true
+	}
+	for _, c := range root.Commands() {
+		if c.Name() == name || c.HasAlias(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitAliasArgs splits an alias expansion into arguments, honoring single
+// and double quotes
+func splitAliasArgs(expansion string) ([]string, error) {
+	var (
+		args    []string
+		current strings.Builder
+		quote   rune
+		inArg   bool
+	)
+
+	for _, r := range expansion {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandAlias(t *testing.T) {
+	aliases := map[string]string{
+		"sw":     "switch --verify",
+		"save":   "list",
+		"msg":    `save --message "daily snapshot"`,
+		"broken": `switch "work`,
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{"expands alias", []string{"sw", "work"}, []string{"switch", "--verify", "work"}},
+		{"keeps global flags", []string{"--config", "/tmp/c.yaml", "-v", "sw", "work"}, []string{"--config", "/tmp/c.yaml", "-v", "switch", "--verify", "work"}},
+		{"flag with inline value", []string{"--sandbox=/tmp/demo", "sw"}, []string{"--sandbox=/tmp/demo", "switch", "--verify"}},
+		{"honors quotes", []string{"msg"}, []string{"save", "--message", "daily snapshot"}},
+		{"built-in commands win", []string{"save"}, []string{"save"}},
+		{"unknown names are left alone", []string{"work"}, []string{"work"}},
+		{"no command", []string{"--debug"}, []string{"--debug"}},
+		{"args after -- are left alone", []string{"--", "sw"}, []string{"--", "sw"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := expandAlias(rootCmd, tt.args, aliases)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("rejects unterminated quotes", func(t *testing.T) {
+		_, err := expandAlias(rootCmd, []string{"broken"}, aliases)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,252 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+var (
+	applyFile   string
+	applyName   string
+	applySet    []string
+	applyDryRun bool
+)
+
+var applyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Create or update an environment from a spec file",
+	Long: `Create or update an environment to match a spec file, by default the
+envswitch.yaml of the current directory. Keep the file in a project repository
+so everyone on the team sets up the project's environment the same way.
+
+The spec lists the enabled tools (the others are disabled, their snapshots
+kept), the variables with their defaults, the hooks and the plugins that must
+be installed. Fields left out are left as they are. Variables the environment
+already has keep their value; the values of new secret variables are asked
+for, or given with --set.
+
+  name: acme
+  description: ACME project
+  tools: [git, kubectl, aws]
+  environment_variables:
+    - key: AWS_REGION
+      default: eu-west-1
+    - key: ACME_TOKEN
+      secret: true
+  hooks:
+    post_switch:
+      - command: kubectl config use-context acme
+  plugins: [vault]
+  tags: [acme]
+
+Examples:
+  envswitch apply
+  envswitch apply -f deploy/envswitch.yaml --name acme-staging
+  envswitch apply --set ACME_TOKEN=... --dry-run`,
+	Args: cobra.NoArgs,
+	RunE: runApply,
+}
+
+func init() {
+	rootCmd.AddCommand(applyCmd)
+
+	applyCmd.Flags().StringVarP(&applyFile, "file", "f", environment.SpecFileName, "Spec file to apply")
+	applyCmd.Flags().StringVar(&applyName, "name", "", "Environment to create or update (default: the name of the spec)")
+	applyCmd.Flags().StringArrayVar(&applySet, "set", nil, "Value of a variable, as KEY=value (repeatable)")
+	applyCmd.Flags().BoolVar(&applyDryRun, "dry-run", false, "Show the changes without applying them")
+
+	_ = applyCmd.RegisterFlagCompletionFunc("name", completeEnvironmentNames)
+	_ = applyCmd.RegisterFlagCompletionFunc("file", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"yaml", "yml"}, cobra.ShellCompDirectiveFilterFileExt
+	})
+}
+
+func runApply(cmd *cobra.Command, args []string) error {
+	spec, err := environment.LoadSpec(applyFile)
+	if err != nil {
+		return err
+	}
+
+	name := applyName
+	if name == "" {
+		name = spec.Name
+	}
+	if name == "" {
+		return fmt.Errorf("%s has no name, set one or use --name", applyFile)
+	}
+
+	pluginTools, err := specPluginTools(spec)
+	if err != nil {
+		return err
+	}
+	registry := getToolRegistry()
+	for _, toolName := range spec.Tools {
+		if _, known := registry[toolName]; !known {
+			return fmt.Errorf("unknown tool '%s' in %s (run 'envswitch tools list' to see the available tools)", toolName, applyFile)
+		}
+	}
+
+	exists, err := environment.EnvironmentExists(name)
+	if err != nil {
+		return err
+	}
+	var env *environment.Environment
+	if exists {
+		if env, err = environment.LoadEnvironment(name); err != nil {
+			return fmt.Errorf("failed to load environment '%s': %w", name, err)
+		}
+	} else {
+		if err := checkNewEnvironment(name); err != nil {
+			return err
+		}
+		env = &environment.Environment{Name: name}
+	}
+
+	vars, err := specVarsToSet(spec, env, exists, applySet)
+	if err != nil {
+		return err
+	}
+
+	if applyDryRun {
+		return printApplyPreview(spec, env, exists, pluginTools, vars)
+	}
+	if exists && env.Protected && !confirmProtected(env, "apply the spec to it") {
+		fmt.Println("Canceled.")
+		return nil
+	}
+
+	// Resolve the values before creating anything, a missing one must not
+	// leave a half-created environment behind
+	values, err := variableValues(vars, applySet, os.Stdin)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		if env, err = newEnvironment(name, "", false); err != nil {
+			return err
+		}
+	}
+	changes := spec.Apply(env, pluginTools)
+	if exists {
+		if err = env.Save(); err == nil {
+			// Variables the spec marks as secret are encrypted
+			err = env.SealEnvVars()
+		}
+	} else {
+		err = saveNewEnvironment(env)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save environment: %w", err)
+	}
+	if err := setTemplateVars(env, vars, values); err != nil {
+		return err
+	}
+	for _, specVar := range vars {
+		changes = append(changes, fmt.Sprintf("set %s", specVar.Key))
+	}
+
+	switch {
+	case !exists:
+		fmt.Printf("✅ Created '%s' from %s\n", name, applyFile)
+	case len(changes) == 0:
+		fmt.Printf("✓ '%s' already matches %s\n", name, applyFile)
+		return nil
+	default:
+		fmt.Printf("✅ Updated '%s' from %s\n", name, applyFile)
+	}
+	for _, change := range changes {
+		fmt.Printf("  ✓ %s\n", change)
+	}
+	if !exists {
+		fmt.Println()
+		fmt.Printf("Next: envswitch switch %s\n", name)
+	}
+	return nil
+}
+
+// specPluginTools checks that the plugins of the spec are installed and
+// returns the tools they provide
+func specPluginTools(spec *environment.Spec) (map[string]string, error) {
+	pluginTools := make(map[string]string, len(spec.Plugins))
+	var missing []string
+	for _, pluginName := range spec.Plugins {
+		manifest, err := loadInstalledPlugin(pluginName)
+		if err != nil {
+			missing = append(missing, pluginName)
+			continue
+		}
+		pluginTools[pluginName] = manifest.Metadata.ToolName
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("missing plugin(s) %s, install them with 'envswitch plugin install <name>'", strings.Join(missing, ", "))
+	}
+	return pluginTools, nil
+}
+
+// specVarsToSet returns the variables of the spec that env does not have
+// yet, and the ones given a value with --set
+func specVarsToSet(spec *environment.Spec, env *environment.Environment, exists bool, assignments []string) ([]environment.TemplateVar, error) {
+	var vars []environment.TemplateVar
+	for _, specVar := range spec.EnvVars {
+		if !exists || hasAssignment(assignments, specVar.Key) {
+			vars = append(vars, specVar)
+			continue
+		}
+		if _, ok, err := env.GetEnvVar(specVar.Key); err != nil {
+			return nil, err
+		} else if !ok {
+			vars = append(vars, specVar)
+		}
+	}
+	return vars, nil
+}
+
+// printApplyPreview prints the changes applying the spec would make
+func printApplyPreview(spec *environment.Spec, env *environment.Environment, exists bool, pluginTools map[string]string, vars []environment.TemplateVar) error {
+	if !exists {
+		fmt.Printf("Would create '%s' from %s\n", env.Name, applyFile)
+	} else {
+		fmt.Printf("Would update '%s' from %s\n", env.Name, applyFile)
+	}
+
+	// Applied to the loaded environment, which is not saved
+	changes := spec.Apply(env, pluginTools)
+	for _, specVar := range vars {
+		switch {
+		case hasAssignment(applySet, specVar.Key):
+			changes = append(changes, fmt.Sprintf("set %s", specVar.Key))
+		case specVar.Secret:
+			changes = append(changes, fmt.Sprintf("ask for the value of %s", specVar.Key))
+		default:
+			changes = append(changes, fmt.Sprintf("set %s to its default", specVar.Key))
+		}
+	}
+
+	if len(changes) == 0 {
+		fmt.Println("  No changes, it already matches")
+	}
+	for _, change := range changes {
+		fmt.Printf("  • %s\n", change)
+	}
+	fmt.Println()
+	fmt.Println("No changes will be applied (use without --dry-run to apply)")
+	return nil
+}
+
+// hasAssignment reports whether assignments give key a value
+func hasAssignment(assignments []string, key string) bool {
+	for _, assignment := range assignments {
+		if assignedKey, _, _ := strings.Cut(assignment, "="); assignedKey == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hugofrely/envswitch/internal/keyring"
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+func TestRunApply(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+	keyring.MockInit() // secret values are encrypted with a key kept in the keyring
+
+	envsDir := filepath.Join(tempHome, ".envswitch", "environments")
+	require.NoError(t, os.MkdirAll(envsDir, 0755))
+
+	specPath := filepath.Join(tempHome, "envswitch.yaml")
+	require.NoError(t, os.WriteFile(specPath, []byte(`name: acme
+description: ACME project
+tools: [git, kubectl]
+environment_variables:
+  - key: AWS_REGION
+    default: eu-west-1
+  - key: ACME_TOKEN
+    secret: true
+tags: [acme]
+`), 0644))
+
+	applyFile = specPath
+	t.Cleanup(func() {
+		applyFile = environment.SpecFileName
+		applyName = ""
+		applySet = nil
+		applyDryRun = false
+	})
+
+	t.Run("previews without creating", func(t *testing.T) {
+		applyDryRun = true
+		defer func() { applyDryRun = false }()
+
+		output := captureStdout(t, func() {
+			require.NoError(t, runApply(applyCmd, nil))
+		})
+		assert.Contains(t, output, "Would create 'acme'")
+		assert.Contains(t, output, "enable kubectl")
+		assert.Contains(t, output, "ask for the value of ACME_TOKEN")
+		assert.NoDirExists(t, filepath.Join(envsDir, "acme"))
+	})
+
+	t.Run("creates the environment", func(t *testing.T) {
+		applySet = []string{"ACME_TOKEN=t0ken"}
+		defer func() { applySet = nil }()
+
+		output := captureStdout(t, func() {
+			require.NoError(t, runApply(applyCmd, nil))
+		})
+		assert.Contains(t, output, "Created 'acme'")
+
+		env, err := environment.LoadEnvironment("acme")
+		require.NoError(t, err)
+		assert.Equal(t, "ACME project", env.Description)
+		assert.True(t, env.Tools["git"].Enabled)
+		assert.True(t, env.Tools["kubectl"].Enabled)
+		assert.False(t, env.Tools["docker"].Enabled)
+		assert.Equal(t, []string{"acme"}, env.Tags)
+		assert.True(t, env.IsSecretEnvVar("ACME_TOKEN"))
+
+		value, _, err := env.GetEnvVar("AWS_REGION")
+		require.NoError(t, err)
+		assert.Equal(t, "eu-west-1", value)
+	})
+
+	t.Run("keeps the values of an existing environment", func(t *testing.T) {
+		env, err := environment.LoadEnvironment("acme")
+		require.NoError(t, err)
+		require.NoError(t, env.SetEnvVar("AWS_REGION", "us-east-1"))
+
+		output := captureStdout(t, func() {
+			require.NoError(t, runApply(applyCmd, nil))
+		})
+		assert.Contains(t, output, "already matches")
+
+		env, err = environment.LoadEnvironment("acme")
+		require.NoError(t, err)
+		value, _, err := env.GetEnvVar("AWS_REGION")
+		require.NoError(t, err)
+		assert.Equal(t, "us-east-1", value)
+	})
+
+	t.Run("updates an environment under another name", func(t *testing.T) {
+		createEnvWithVars(t, envsDir, "acme-staging", map[string]string{"ACME_TOKEN": "staging"})
+		applyName = "acme-staging"
+		defer func() { applyName = "" }()
+
+		output := captureStdout(t, func() {
+			require.NoError(t, runApply(applyCmd, nil))
+		})
+		assert.Contains(t, output, "Updated 'acme-staging'")
+		assert.Contains(t, output, "set AWS_REGION")
+		assert.NotContains(t, output, "set ACME_TOKEN")
+	})
+
+	t.Run("confirms before updating a protected environment", func(t *testing.T) {
+		createEnvWithVars(t, envsDir, "acme-prod", nil)
+		captureStdout(t, func() { require.NoError(t, setProtected("acme-prod", true)) })
+		applyName = "acme-prod"
+		applySet = []string{"ACME_TOKEN=t0ken"}
+		defer func() { applyName, applySet = "", nil }()
+
+		setProtectedInput(t, "y\n")
+		output := captureStdout(t, func() {
+			require.NoError(t, runApply(applyCmd, nil))
+		})
+		assert.Contains(t, output, "Canceled.")
+		env, err := environment.LoadEnvironment("acme-prod")
+		require.NoError(t, err)
+		assert.False(t, env.Tools["kubectl"].Enabled)
+
+		setProtectedInput(t, "acme-prod\n")
+		output = captureStdout(t, func() {
+			require.NoError(t, runApply(applyCmd, nil))
+		})
+		assert.Contains(t, output, "Updated 'acme-prod'")
+		env, err = environment.LoadEnvironment("acme-prod")
+		require.NoError(t, err)
+		assert.True(t, env.Tools["kubectl"].Enabled)
+	})
+
+	t.Run("requires the plugins of the spec", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(specPath, []byte("name: acme\nplugins: [vault]\n"), 0644))
+
+		err := runApply(applyCmd, nil)
+		assert.ErrorContains(t, err, "missing plugin(s) vault")
+
+		installTestPlugin(t, tempHome, "vault", filepath.Join(tempHome, ".vault"))
+		captureStdout(t, func() {
+			require.NoError(t, runApply(applyCmd, nil))
+		})
+		env, err := environment.LoadEnvironment("acme")
+		require.NoError(t, err)
+		assert.True(t, env.Tools["vault"].Enabled)
+	})
+
+	t.Run("rejects unknown tools", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(specPath, []byte("name: acme\ntools: [nope]\n"), 0644))
+		assert.ErrorContains(t, runApply(applyCmd, nil), "unknown tool 'nope'")
+	})
+}
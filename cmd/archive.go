@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+
+	"github.com/hugofrely/envswitch/internal/archive"
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+var unarchiveName string
+
+var archiveCmd = &cobra.Command{
+	Use:   "archive <name|pattern>...",
+	Short: "Put environments away in the archive store",
+	Long: `Move environments out of the list, into ~/.envswitch/archives/environments.
+
+An archived environment is a tar.gz archive that is never pruned like backups.
+It is left out of 'envswitch list' until 'envswitch unarchive' restores it, and
+'envswitch list --archived' lists them. The active environment cannot be
+archived.
+
+Examples:
+  envswitch archive old-client
+  envswitch archive 'client-a/*'
+  envswitch list --archived
+  envswitch unarchive old-client`,
+	Args:              cobra.MinimumNArgs(1),
+	ValidArgsFunction: completeEnvironmentNameArgs,
+	RunE:              runArchive,
+}
+
+var unarchiveCmd = &cobra.Command{
+	Use:   "unarchive <name>",
+	Short: "Restore an archived environment",
+	Long: `Restore an environment put away with 'envswitch archive', and remove its archive.
+
+Examples:
+  envswitch unarchive old-client
+  envswitch unarchive old-client --name old-client-2023`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeArchivedNames,
+	RunE:              runUnarchive,
+}
+
+func init() {
+	rootCmd.AddCommand(archiveCmd)
+	rootCmd.AddCommand(unarchiveCmd)
+	unarchiveCmd.Flags().StringVar(&unarchiveName, "name", "", "Restore under this name")
+}
+
+func runArchive(cmd *cobra.Command, args []string) error {
+	names, err := environment.ExpandNames(args)
+	if err != nil {
+		return err
+	}
+
+	// Check them all before archiving any
+	current, _ := environment.GetCurrentEnvironmentName()
+	envs := make([]*environment.Environment, 0, len(names))
+	for _, name := range names {
+		if name == current {
+			return fmt.Errorf("cannot archive active environment '%s' (switch to another one first)", name)
+		}
+		env, err := environment.LoadEnvironment(name)
+		if err != nil {
+			return fmt.Errorf("environment '%s' not found: %w", name, err)
+		}
+		envs = append(envs, env)
+	}
+
+	var failed []string
+	for _, env := range envs {
+		arch, err := archive.ShelveEnvironment(env)
+		if err != nil {
+			fmt.Printf("✗ %s: %v\n", env.Name, err)
+			failed = append(failed, env.Name)
+			continue
+		}
+		fmt.Printf("📦 Archived '%s' (%s)\n", env.Name, humanize.Bytes(uint64(arch.SizeBytes)))
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to archive %s", strings.Join(failed, ", "))
+	}
+	if len(envs) > 0 {
+		fmt.Println()
+		fmt.Println("Restore with: envswitch unarchive <name>")
+	}
+	return nil
+}
+
+func runUnarchive(cmd *cobra.Command, args []string) error {
+	name := environment.NormalizeName(args[0])
+	if unarchiveName != "" {
+		if err := checkNewEnvironment(unarchiveName); err != nil {
+			return err
+		}
+	}
+
+	if err := archive.UnshelveEnvironment(name, unarchiveName); err != nil {
+		return err
+	}
+
+	restored := name
+	if unarchiveName != "" {
+		restored = unarchiveName
+	}
+	fmt.Printf("✅ Environment '%s' unarchived\n", restored)
+	fmt.Printf("Next: envswitch switch %s\n", restored)
+	return nil
+}
+
+// completeArchivedNames completes the names of the archived environments
+func completeArchivedNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	archives, err := archive.ListShelved()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	names := make([]string, 0, len(archives))
+	for _, arch := range archives {
+		names = append(names, arch.EnvName)
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+func TestRunArchiveUnarchive(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+
+	envsDir := filepath.Join(tempHome, ".envswitch", "environments")
+	require.NoError(t, os.MkdirAll(envsDir, 0755))
+	createEnvWithVars(t, envsDir, "work", nil)
+	createEnvWithVars(t, envsDir, "old-client", nil)
+	require.NoError(t, environment.SetCurrentEnvironment("work"))
+
+	t.Run("refuses the active environment", func(t *testing.T) {
+		err := runArchive(archiveCmd, []string{"work"})
+		assert.ErrorContains(t, err, "cannot archive active environment")
+		assert.DirExists(t, filepath.Join(envsDir, "work"))
+	})
+
+	t.Run("moves an environment out of the list", func(t *testing.T) {
+		output := captureStdout(t, func() {
+			require.NoError(t, runArchive(archiveCmd, []string{"old-client"}))
+		})
+		assert.Contains(t, output, "Archived 'old-client'")
+		assert.NoDirExists(t, filepath.Join(envsDir, "old-client"))
+
+		output = captureStdout(t, func() {
+			require.NoError(t, runList(listCmd, nil))
+		})
+		assert.NotContains(t, output, "old-client")
+		assert.Contains(t, output, "1 archived")
+
+		listArchived = true
+		defer func() { listArchived = false }()
+		output = captureStdout(t, func() {
+			require.NoError(t, runList(listCmd, nil))
+		})
+		assert.Contains(t, output, "old-client")
+	})
+
+	t.Run("restores an archived environment", func(t *testing.T) {
+		captureStdout(t, func() {
+			require.NoError(t, runUnarchive(unarchiveCmd, []string{"old-client"}))
+		})
+		env, err := environment.LoadEnvironment("old-client")
+		require.NoError(t, err)
+		assert.Equal(t, "old-client", env.Name)
+
+		assert.ErrorContains(t, runUnarchive(unarchiveCmd, []string{"old-client"}), "no archived environment")
+	})
+}
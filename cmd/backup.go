@@ -0,0 +1,526 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+
+	"github.com/hugofrely/envswitch/internal/archive"
+	"github.com/hugofrely/envswitch/internal/config"
+	"github.com/hugofrely/envswitch/internal/storage"
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+var (
+	backupCreateAll       bool
+	backupRestoreEnv      string
+	backupRestoreYes      bool
+	backupRestoreNoBackup bool
+	backupRestoreApply    bool
+	backupPruneKeep       int
+	backupPruneOlderThan  string
+	backupPruneDryRun     bool
+	backupPruneYes        bool
+	backupGCDryRun        bool
+)
+
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Manage environment backups",
+	Long: `Manage the backups of ~/.envswitch/archives. A backup is taken before every
+switch and delete, and can be created by hand with 'envswitch backup create'.
+
+Backups taken on switch and with 'envswitch backup create' are deduplicated:
+each is a manifest (.manifest.json) of its files, whose content is stored once
+in ~/.envswitch/archives/blobs and shared by every backup holding it.`,
+}
+
+var backupListCmd = &cobra.Command{
+	Use:   "list [environment]",
+	Short: "List backups with their size and age",
+	Long: `List backups, newest first, optionally only those of one environment.
+
+Examples:
+  envswitch backup list
+  envswitch backup list work`,
+	Aliases:           []string{"ls"},
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeEnvironmentNames,
+	RunE:              runBackupList,
+}
+
+var backupCreateCmd = &cobra.Command{
+	Use:   "create [environment]",
+	Short: "Back up an environment now",
+	Long: `Back up an environment, the active one unless a name is given, or every
+environment with --all.
+
+Examples:
+  envswitch backup create
+  envswitch backup create work
+  envswitch backup create --all`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeEnvironmentNames,
+	RunE:              runBackupCreate,
+}
+
+var backupRestoreCmd = &cobra.Command{
+	Use:   "restore <backup>",
+	Short: "Restore an environment from a backup",
+	Long: `Restore an environment from a backup, given by file name as shown by
+'envswitch backup list' or by path. The environment is restored under the
+name it was backed up with unless --env is given, and its current state is
+backed up first.
+
+Restoring the active environment only replaces its snapshots: use --apply to
+also restore its tools on this machine.
+
+Examples:
+  envswitch backup restore work-20240115-103000.manifest.json
+  envswitch backup restore work-20240115-103000.tar.gz --env work-old
+  envswitch backup restore ~/backups/work.tar.gz --apply --yes`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeBackupNames,
+	RunE:              runBackupRestore,
+}
+
+var backupPruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Delete old backups",
+	Long: `Delete backups beyond a count or older than an age. Without --keep nor
+--older-than, backup_retention backups are kept.
+
+Ages are Go durations (12h, 90m) or a number of days or weeks (30d, 2w).
+
+Examples:
+  envswitch backup prune --keep 5
+  envswitch backup prune --older-than 30d
+  envswitch backup prune --older-than 2w --dry-run`,
+	Args: cobra.NoArgs,
+	RunE: runBackupPrune,
+}
+
+var backupGCCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Delete backup content no backup uses anymore",
+	Long: `Delete the content of the blob store no backup manifest references anymore.
+It runs after 'envswitch backup prune' and the cleanup of old backups on
+switch, and is only needed by hand after deleting manifests yourself.
+
+Content written in the last hour is kept, as it may belong to a backup being
+taken.
+
+Examples:
+  envswitch backup gc
+  envswitch backup gc --dry-run`,
+	Args: cobra.NoArgs,
+	RunE: runBackupGC,
+}
+
+func init() {
+	rootCmd.AddCommand(backupCmd)
+	backupCmd.AddCommand(backupListCmd)
+	backupCmd.AddCommand(backupCreateCmd)
+	backupCmd.AddCommand(backupRestoreCmd)
+	backupCmd.AddCommand(backupPruneCmd)
+	backupCmd.AddCommand(backupGCCmd)
+
+	backupCreateCmd.Flags().BoolVar(&backupCreateAll, "all", false, "Back up every environment")
+
+	backupRestoreCmd.Flags().StringVar(&backupRestoreEnv, "env", "", "Environment to restore into (default: the backed up one)")
+	backupRestoreCmd.Flags().BoolVarP(&backupRestoreYes, "yes", "y", false, "Skip confirmation")
+	backupRestoreCmd.Flags().BoolVar(&backupRestoreNoBackup, "no-backup", false, "Don't back up the environment being replaced")
+	backupRestoreCmd.Flags().BoolVar(&backupRestoreApply, "apply", false, "Restore the tools too when the environment is active")
+	_ = backupRestoreCmd.RegisterFlagCompletionFunc("env", completeEnvironmentFlag)
+
+	backupPruneCmd.Flags().IntVar(&backupPruneKeep, "keep", 0, "Number of most recent backups to keep")
+	backupPruneCmd.Flags().StringVar(&backupPruneOlderThan, "older-than", "", "Delete backups older than this age (e.g. 30d, 2w, 12h)")
+	backupPruneCmd.Flags().BoolVar(&backupPruneDryRun, "dry-run", false, "Show the backups that would be deleted")
+	backupPruneCmd.Flags().BoolVarP(&backupPruneYes, "yes", "y", false, "Skip confirmation")
+
+	backupGCCmd.Flags().BoolVar(&backupGCDryRun, "dry-run", false, "Show what would be deleted")
+}
+
+// sortedArchives returns the backups, newest first, of envName or of every
+// environment when it is empty
+func sortedArchives(envName string) ([]*archive.Archive, error) {
+	archives, err := archive.ListArchives()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backups: %w", err)
+	}
+
+	filtered := make([]*archive.Archive, 0, len(archives))
+	for _, arch := range archives {
+		if envName == "" || arch.EnvName == envName {
+			filtered = append(filtered, arch)
+		}
+	}
+	sort.SliceStable(filtered, func(i, j int) bool {
+		return filtered[i].ArchivedAt.After(filtered[j].ArchivedAt)
+	})
+	return filtered, nil
+}
+
+func runBackupList(cmd *cobra.Command, args []string) error {
+	envName := ""
+	if len(args) > 0 {
+		envName = args[0]
+	}
+
+	archives, err := sortedArchives(envName)
+	if err != nil {
+		return err
+	}
+	if len(archives) == 0 {
+		if envName != "" {
+			fmt.Printf("No backups of '%s'\n", envName)
+		} else {
+			fmt.Println("No backups yet")
+		}
+		return nil
+	}
+
+	var total int64
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "BACKUP\tENVIRONMENT\tSIZE\tAGE")
+	for _, arch := range archives {
+		total += arch.SizeBytes
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", filepath.Base(arch.Path), arch.EnvName,
+			humanize.Bytes(uint64(arch.SizeBytes)), formatTimeAgo(arch.ArchivedAt))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	// Deduplicated backups share their content, only the directory tells
+	// the room they take
+	if envName == "" {
+		if archiveDir, err := archive.GetArchiveDir(); err == nil {
+			size := storage.PathSize(archiveDir)
+			// Archived environments are not backups
+			if shelvedDir, err := archive.GetShelvedDir(); err == nil {
+				size -= storage.PathSize(shelvedDir)
+			}
+			fmt.Printf("\n%d backup(s), %s on disk\n", len(archives), humanize.Bytes(size))
+			return nil
+		}
+	}
+	fmt.Printf("\n%d backup(s), %s\n", len(archives), humanize.Bytes(uint64(total)))
+	return nil
+}
+
+func runBackupCreate(cmd *cobra.Command, args []string) error {
+	if backupCreateAll && len(args) > 0 {
+		return fmt.Errorf("--all takes no environment name")
+	}
+
+	var envs []*environment.Environment
+	if backupCreateAll {
+		all, err := environment.ListEnvironments()
+		if err != nil {
+			return err
+		}
+		if len(all) == 0 {
+			fmt.Println("No environments to back up")
+			return nil
+		}
+		envs = all
+	} else {
+		name := ""
+		if len(args) > 0 {
+			name = args[0]
+		}
+		env, err := resolveEnvTarget(name)
+		if err != nil {
+			return err
+		}
+		envs = []*environment.Environment{env}
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	defer waitForMirrors()
+
+	failed := 0
+	for _, env := range envs {
+		arch, err := archive.BackupEnvironment(env)
+		if err != nil {
+			if !backupCreateAll {
+				return fmt.Errorf("failed to back up '%s': %w", env.Name, err)
+			}
+			fmt.Printf("❌ Failed to back up '%s': %v\n", env.Name, err)
+			failed++
+			continue
+		}
+		mirrorBackup(arch.Path, cfg)
+		fmt.Printf("✅ Backed up '%s' to %s\n", env.Name, filepath.Base(arch.Path))
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to back up %d of %d environment(s)", failed, len(envs))
+	}
+	return nil
+}
+
+// resolveBackupPath returns the path of a backup given by path or by file
+// name in the archive directory
+func resolveBackupPath(backup string) (string, error) {
+	if _, err := os.Stat(backup); err == nil {
+		return backup, nil
+	}
+
+	archiveDir, err := archive.GetArchiveDir()
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(archiveDir, filepath.Base(backup))
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("backup '%s' not found (see 'envswitch backup list')", backup)
+	}
+	return path, nil
+}
+
+func runBackupRestore(cmd *cobra.Command, args []string) error {
+	backupPath, err := resolveBackupPath(args[0])
+	if err != nil {
+		return err
+	}
+
+	info, err := archive.InspectArchive(backupPath)
+	if err != nil {
+		return fmt.Errorf("failed to read backup '%s': %w", args[0], err)
+	}
+	archivedName := info.Environment.Name
+	if archivedName == "" {
+		return fmt.Errorf("backup '%s' does not contain an environment", args[0])
+	}
+
+	targetName := archivedName
+	if backupRestoreEnv != "" {
+		targetName = backupRestoreEnv
+	}
+	if err := environment.ValidateName(targetName); err != nil {
+		return err
+	}
+
+	existing, _ := environment.LoadEnvironment(targetName)
+	if !backupRestoreYes && existing != nil && existing.Protected {
+		if !confirmProtected(existing, "restore the backup over it") {
+			fmt.Println("Canceled.")
+			return nil
+		}
+	} else if !backupRestoreYes {
+		if existing != nil {
+			fmt.Printf("⚠️  Replace environment '%s' with the backup %s? [y/N]: ", targetName, filepath.Base(backupPath))
+		} else {
+			fmt.Printf("Restore environment '%s' from %s? [y/N]: ", targetName, filepath.Base(backupPath))
+		}
+		var response string
+		if _, err := fmt.Scanln(&response); err != nil {
+			fmt.Println("Canceled.")
+			return nil
+		}
+		if response != "y" && response != "Y" {
+			fmt.Println("Canceled.")
+			return nil
+		}
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if existing != nil && !backupRestoreNoBackup {
+		arch, err := archive.BackupEnvironment(existing)
+		if err != nil {
+			return fmt.Errorf("failed to back up '%s' before restoring: %w", targetName, err)
+		}
+		mirrorBackup(arch.Path, cfg)
+		defer waitForMirrors()
+		fmt.Printf("✓ Backed up the current '%s' to %s\n", targetName, filepath.Base(arch.Path))
+	}
+
+	env, err := restoreEnvironmentFromBackup(archivedName, targetName, backupPath)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("✅ Restored '%s' from %s\n", env.Name, filepath.Base(backupPath))
+
+	current, err := environment.GetCurrentEnvironment()
+	if err != nil || current == nil || current.Name != env.Name {
+		return nil
+	}
+	if !backupRestoreApply {
+		fmt.Printf("⚠️  '%s' is active: its tools are restored the next time you switch to it (or use --apply)\n", env.Name)
+		return nil
+	}
+
+	toolCount, err := restoreEnvironment(context.Background(), env, getToolRegistry(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to restore tools: %w", err)
+	}
+	fmt.Printf("✓ Restored %d tool(s) on this machine\n", toolCount)
+	return nil
+}
+
+// parseAge parses a Go duration or a number of days ("30d") or weeks ("2w")
+func parseAge(value string) (time.Duration, error) {
+	units := map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour}
+	for suffix, unit := range units {
+		if count, found := strings.CutSuffix(value, suffix); found {
+			n, err := strconv.Atoi(count)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid age '%s'", value)
+			}
+			return time.Duration(n) * unit, nil
+		}
+	}
+
+	age, err := time.ParseDuration(value)
+	if err != nil || age <= 0 {
+		return 0, fmt.Errorf("invalid age '%s' (use e.g. 30d, 2w or 12h)", value)
+	}
+	return age, nil
+}
+
+func runBackupPrune(cmd *cobra.Command, args []string) error {
+	if backupPruneKeep < 0 {
+		return fmt.Errorf("--keep must be positive")
+	}
+
+	var maxAge time.Duration
+	if backupPruneOlderThan != "" {
+		var err error
+		if maxAge, err = parseAge(backupPruneOlderThan); err != nil {
+			return err
+		}
+	}
+
+	keep := backupPruneKeep
+	if keep == 0 && maxAge == 0 {
+		cfg, err := config.LoadConfig()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+		if cfg.BackupRetention <= 0 {
+			return fmt.Errorf("backup_retention is disabled, use --keep or --older-than")
+		}
+		keep = cfg.BackupRetention
+	}
+
+	archives, err := archive.ListArchives()
+	if err != nil {
+		return fmt.Errorf("failed to list backups: %w", err)
+	}
+	prune := archive.ArchivesToPrune(archives, keep, maxAge, time.Now())
+	if len(prune) == 0 {
+		fmt.Println("No backups to delete")
+		return nil
+	}
+
+	var total int64
+	for _, arch := range prune {
+		total += arch.SizeBytes
+	}
+	fmt.Printf("Backups to delete (%d, %s):\n", len(prune), humanize.Bytes(uint64(total)))
+	for _, arch := range prune {
+		fmt.Printf("  • %s (%s)\n", filepath.Base(arch.Path), formatTimeAgo(arch.ArchivedAt))
+	}
+
+	if backupPruneDryRun {
+		fmt.Println("\nNo backups deleted (use without --dry-run to delete)")
+		return nil
+	}
+
+	if !backupPruneYes {
+		fmt.Print("\nDelete them? [y/N]: ")
+		var response string
+		if _, err := fmt.Scanln(&response); err != nil {
+			fmt.Println("Canceled.")
+			return nil
+		}
+		if response != "y" && response != "Y" {
+			fmt.Println("Canceled.")
+			return nil
+		}
+	}
+
+	deleted := 0
+	for _, arch := range prune {
+		if err := archive.DeleteArchive(arch.Path); err != nil {
+			fmt.Printf("✗ %s: %v\n", filepath.Base(arch.Path), err)
+			continue
+		}
+		deleted++
+	}
+	fmt.Printf("✅ Deleted %d backup(s)\n", deleted)
+	if deleted > 0 {
+		collectBackupGarbage()
+	}
+	if deleted < len(prune) {
+		return fmt.Errorf("failed to delete %d backup(s)", len(prune)-deleted)
+	}
+	return nil
+}
+
+func runBackupGC(cmd *cobra.Command, args []string) error {
+	result, err := archive.CollectGarbage(backupGCDryRun)
+	if err != nil {
+		return fmt.Errorf("failed to collect unused backup content: %w", err)
+	}
+
+	if result.Blobs == 0 {
+		fmt.Println("No unused backup content")
+		return nil
+	}
+	if backupGCDryRun {
+		fmt.Printf("%d unused file(s) would be deleted, freeing %s\n", result.Blobs, humanize.Bytes(uint64(result.Bytes)))
+		return nil
+	}
+	fmt.Printf("✅ Deleted %d unused file(s), freed %s (%d still in use)\n", result.Blobs, humanize.Bytes(uint64(result.Bytes)), result.Remaining)
+	return nil
+}
+
+// collectBackupGarbage deletes the backup content left unused once backups
+// were deleted. A failure is reported but never fails the command.
+func collectBackupGarbage() {
+	result, err := archive.CollectGarbage(false)
+	if err != nil {
+		backupLog.Warn("Failed to delete unused backup content: %v", err)
+		return
+	}
+	if result.Blobs > 0 {
+		backupLog.Debug("Deleted %d unused backup file(s), %s", result.Blobs, humanize.Bytes(uint64(result.Bytes)))
+	}
+}
+
+// completeBackupNames provides completion for backup file names
+func completeBackupNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) != 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	archives, err := sortedArchives("")
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var names []string
+	for _, arch := range archives {
+		if name := filepath.Base(arch.Path); strings.HasPrefix(name, toComplete) {
+			names = append(names, name)
+		}
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
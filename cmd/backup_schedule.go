@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/hugofrely/envswitch/internal/archive"
+)
+
+var (
+	backupScheduleEvery string
+	backupScheduleAt    string
+)
+
+var backupScheduleCmd = &cobra.Command{
+	Use:   "schedule",
+	Short: "Back up every environment periodically",
+	Long: `Install a schedule running 'envswitch backup create --all' periodically, with
+the scheduler of the system: a launchd agent on macOS, a systemd user timer on
+Linux (a crontab entry without systemd), a Task Scheduler task on Windows.
+
+Installing a schedule replaces the previous one. Scheduled backups are not
+pruned: add 'envswitch backup prune' to your routine, or run it from cron too.
+
+Examples:
+  # Every day at 03:00
+  envswitch backup schedule
+
+  # Every Sunday at 22:30, or every hour at minute 15
+  envswitch backup schedule --every weekly --at 22:30
+  envswitch backup schedule --every hourly --at 00:15
+
+  envswitch backup schedule status
+  envswitch backup schedule remove`,
+	Args: cobra.NoArgs,
+	RunE: runBackupSchedule,
+}
+
+var backupScheduleStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the backup schedule and the last backup",
+	Args:  cobra.NoArgs,
+	RunE:  runBackupScheduleStatus,
+}
+
+var backupScheduleRemoveCmd = &cobra.Command{
+	Use:   "remove",
+	Short: "Remove the backup schedule",
+	Args:  cobra.NoArgs,
+	RunE:  runBackupScheduleRemove,
+}
+
+func init() {
+	backupCmd.AddCommand(backupScheduleCmd)
+	backupScheduleCmd.AddCommand(backupScheduleStatusCmd)
+	backupScheduleCmd.AddCommand(backupScheduleRemoveCmd)
+
+	backupScheduleCmd.Flags().StringVar(&backupScheduleEvery, "every", archive.ScheduleDaily, "How often to back up: hourly, daily or weekly (on Sundays)")
+	backupScheduleCmd.Flags().StringVar(&backupScheduleAt, "at", "03:00", "Time of the backup, HH:MM (only the minute for hourly)")
+	_ = backupScheduleCmd.RegisterFlagCompletionFunc("every", cobra.FixedCompletions(archive.ScheduleFrequencies, cobra.ShellCompDirectiveNoFileComp))
+}
+
+func runBackupSchedule(cmd *cobra.Command, args []string) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to find the envswitch executable: %w", err)
+	}
+	logsDir, err := getSwitchLogDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(logsDir, 0700); err != nil {
+		return fmt.Errorf("failed to create logs directory: %w", err)
+	}
+
+	schedule, err := archive.InstallSchedule(archive.Schedule{
+		Every:   backupScheduleEvery,
+		At:      backupScheduleAt,
+		Command: []string{executable, "backup", "create", "--all"},
+		LogFile: filepath.Join(logsDir, "backup-schedule.log"),
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ Backups scheduled %s with %s\n", schedule, schedule.Installer)
+	fmt.Printf("   %s\n", schedule.Path)
+	return nil
+}
+
+func runBackupScheduleStatus(cmd *cobra.Command, args []string) error {
+	schedule, err := archive.LoadSchedule()
+	if err != nil {
+		return err
+	}
+	if schedule == nil {
+		fmt.Println("No backup schedule, install one with 'envswitch backup schedule'")
+		return nil
+	}
+
+	fmt.Printf("Schedule:    %s\n", schedule)
+	fmt.Printf("Installer:   %s (%s)\n", schedule.Installer, schedule.Path)
+	fmt.Printf("Installed:   %s\n", formatTimeAgo(schedule.InstalledAt))
+	if _, err := os.Stat(schedule.LogFile); err == nil {
+		fmt.Printf("Log:         %s\n", schedule.LogFile)
+	}
+
+	archives, err := sortedArchives("")
+	switch {
+	case err != nil:
+		fmt.Printf("Last backup: unknown (%v)\n", err)
+	case len(archives) == 0:
+		fmt.Println("Last backup: none yet")
+	default:
+		fmt.Printf("Last backup: %s (%s)\n", formatTimeAgo(archives[0].ArchivedAt), filepath.Base(archives[0].Path))
+	}
+
+	if !archive.ScheduleInstalled(schedule) {
+		fmt.Printf("\n⚠️  The schedule is no longer installed in %s, run 'envswitch backup schedule' again\n", schedule.Installer)
+	}
+	return nil
+}
+
+func runBackupScheduleRemove(cmd *cobra.Command, args []string) error {
+	if err := archive.RemoveSchedule(); err != nil {
+		if errors.Is(err, archive.ErrNoSchedule) {
+			fmt.Println("No backup schedule to remove")
+			return nil
+		}
+		return err
+	}
+	fmt.Println("✅ Backup schedule removed")
+	return nil
+}
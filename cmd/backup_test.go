@@ -0,0 +1,272 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hugofrely/envswitch/internal/archive"
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+func TestParseAge(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{value: "30d", want: 30 * 24 * time.Hour},
+		{value: "2w", want: 14 * 24 * time.Hour},
+		{value: "12h", want: 12 * time.Hour},
+		{value: "90m", want: 90 * time.Minute},
+		{value: "0d", wantErr: true},
+		{value: "xd", wantErr: true},
+		{value: "-1h", wantErr: true},
+		{value: "soon", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseAge(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRunBackupCreateAndList(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+
+	envsDir := filepath.Join(tempHome, ".envswitch", "environments")
+	require.NoError(t, os.MkdirAll(envsDir, 0755))
+	createEnvWithVars(t, envsDir, "work", nil)
+	createEnvWithVars(t, envsDir, "home", nil)
+	require.NoError(t, environment.SetCurrentEnvironment("work"))
+
+	output := captureStdout(t, func() {
+		require.NoError(t, runBackupCreate(backupCreateCmd, nil))
+	})
+	assert.Contains(t, output, "Backed up 'work'")
+
+	require.NoError(t, runBackupCreate(backupCreateCmd, []string{"home"}))
+
+	output = captureStdout(t, func() {
+		require.NoError(t, runBackupList(backupListCmd, nil))
+	})
+	assert.Contains(t, output, "ENVIRONMENT")
+	assert.Contains(t, output, "work-")
+	assert.Contains(t, output, "home-")
+	assert.Contains(t, output, "2 backup(s)")
+
+	output = captureStdout(t, func() {
+		require.NoError(t, runBackupList(backupListCmd, []string{"home"}))
+	})
+	assert.NotContains(t, output, "work-")
+	assert.Contains(t, output, "1 backup(s)")
+
+	output = captureStdout(t, func() {
+		require.NoError(t, runBackupList(backupListCmd, []string{"other"}))
+	})
+	assert.Contains(t, output, "No backups of 'other'")
+
+	backupCreateAll = true
+	defer func() { backupCreateAll = false }()
+	assert.ErrorContains(t, runBackupCreate(backupCreateCmd, []string{"home"}), "--all takes no environment name")
+
+	output = captureStdout(t, func() {
+		require.NoError(t, runBackupCreate(backupCreateCmd, nil))
+	})
+	assert.Contains(t, output, "Backed up 'home'")
+	assert.Contains(t, output, "Backed up 'work'")
+}
+
+func TestRunBackupRestore(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+
+	envsDir := filepath.Join(tempHome, ".envswitch", "environments")
+	require.NoError(t, os.MkdirAll(envsDir, 0755))
+	work := createEnvWithVars(t, envsDir, "work", nil)
+
+	markerPath := filepath.Join(work.Path, "snapshots", "git", "marker")
+	require.NoError(t, os.MkdirAll(filepath.Dir(markerPath), 0755))
+	require.NoError(t, os.WriteFile(markerPath, []byte("before"), 0644))
+
+	backup, err := archive.ArchiveEnvironment(work)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(markerPath, []byte("after"), 0644))
+
+	backupRestoreYes = true
+	defer func() {
+		backupRestoreYes = false
+		backupRestoreEnv = ""
+		backupRestoreNoBackup = false
+	}()
+
+	t.Run("into another environment", func(t *testing.T) {
+		backupRestoreEnv = "work-old"
+		require.NoError(t, runBackupRestore(backupRestoreCmd, []string{filepath.Base(backup.Path)}))
+
+		restored, err := environment.LoadEnvironment("work-old")
+		require.NoError(t, err)
+		assert.Equal(t, "work-old", restored.Name)
+
+		data, err := os.ReadFile(filepath.Join(restored.Path, "snapshots", "git", "marker"))
+		require.NoError(t, err)
+		assert.Equal(t, "before", string(data))
+
+		// The original is untouched
+		data, err = os.ReadFile(markerPath)
+		require.NoError(t, err)
+		assert.Equal(t, "after", string(data))
+	})
+
+	t.Run("over the backed up environment", func(t *testing.T) {
+		backupRestoreEnv = ""
+		backupRestoreNoBackup = true
+		require.NoError(t, runBackupRestore(backupRestoreCmd, []string{backup.Path}))
+
+		data, err := os.ReadFile(markerPath)
+		require.NoError(t, err)
+		assert.Equal(t, "before", string(data))
+	})
+
+	t.Run("unknown backup", func(t *testing.T) {
+		err := runBackupRestore(backupRestoreCmd, []string{"missing.tar.gz"})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "not found")
+	})
+
+	t.Run("invalid target name", func(t *testing.T) {
+		backupRestoreEnv = "Not Valid"
+		assert.Error(t, runBackupRestore(backupRestoreCmd, []string{backup.Path}))
+	})
+}
+
+func TestRunBackupPrune(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+
+	archiveDir, err := archive.GetArchiveDir()
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(archiveDir, 0700))
+
+	now := time.Now()
+	ages := map[string]time.Duration{
+		"work-20240101-000000.tar.gz": 40 * 24 * time.Hour,
+		"work-20240201-000000.tar.gz": 10 * 24 * time.Hour,
+		"home-20240301-000000.tar.gz": time.Hour,
+	}
+	for name, age := range ages {
+		path := filepath.Join(archiveDir, name)
+		require.NoError(t, os.WriteFile(path, []byte("archive"), 0600))
+		require.NoError(t, os.Chtimes(path, now.Add(-age), now.Add(-age)))
+	}
+
+	backupPruneYes = true
+	defer func() {
+		backupPruneYes = false
+		backupPruneKeep = 0
+		backupPruneOlderThan = ""
+		backupPruneDryRun = false
+	}()
+
+	t.Run("dry run", func(t *testing.T) {
+		backupPruneKeep = 1
+		backupPruneDryRun = true
+		output := captureStdout(t, func() {
+			require.NoError(t, runBackupPrune(backupPruneCmd, nil))
+		})
+		assert.Contains(t, output, "work-20240101-000000.tar.gz")
+		assert.Contains(t, output, "work-20240201-000000.tar.gz")
+
+		archives, err := archive.ListArchives()
+		require.NoError(t, err)
+		assert.Len(t, archives, 3)
+	})
+
+	t.Run("older than", func(t *testing.T) {
+		backupPruneKeep = 0
+		backupPruneDryRun = false
+		backupPruneOlderThan = "30d"
+		require.NoError(t, runBackupPrune(backupPruneCmd, nil))
+
+		archives, err := archive.ListArchives()
+		require.NoError(t, err)
+		assert.Len(t, archives, 2)
+		assert.NoFileExists(t, filepath.Join(archiveDir, "work-20240101-000000.tar.gz"))
+	})
+
+	t.Run("keep", func(t *testing.T) {
+		backupPruneKeep = 1
+		backupPruneOlderThan = ""
+		require.NoError(t, runBackupPrune(backupPruneCmd, nil))
+
+		archives, err := archive.ListArchives()
+		require.NoError(t, err)
+		require.Len(t, archives, 1)
+		assert.Equal(t, "home", archives[0].EnvName)
+	})
+
+	t.Run("invalid age", func(t *testing.T) {
+		backupPruneKeep = 0
+		backupPruneOlderThan = "later"
+		assert.Error(t, runBackupPrune(backupPruneCmd, nil))
+	})
+}
+
+func TestRunBackupGC(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+
+	envsDir := filepath.Join(tempHome, ".envswitch", "environments")
+	require.NoError(t, os.MkdirAll(envsDir, 0755))
+	env := createEnvWithVars(t, envsDir, "work", map[string]string{"AWS_PROFILE": "work"})
+
+	output := captureStdout(t, func() {
+		require.NoError(t, runBackupGC(backupGCCmd, nil))
+	})
+	assert.Contains(t, output, "No unused backup content")
+
+	backup, err := archive.BackupEnvironment(env)
+	require.NoError(t, err)
+	require.NoError(t, archive.DeleteArchive(backup.Path))
+
+	// Age the blobs past the grace period for backups being taken
+	archiveDir, err := archive.GetArchiveDir()
+	require.NoError(t, err)
+	old := time.Now().Add(-2 * time.Hour)
+	require.NoError(t, filepath.Walk(filepath.Join(archiveDir, "blobs"), func(path string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			return os.Chtimes(path, old, old)
+		}
+		return err
+	}))
+
+	backupGCDryRun = true
+	defer func() { backupGCDryRun = false }()
+	output = captureStdout(t, func() {
+		require.NoError(t, runBackupGC(backupGCCmd, nil))
+	})
+	assert.Contains(t, output, "would be deleted")
+
+	backupGCDryRun = false
+	output = captureStdout(t, func() {
+		require.NoError(t, runBackupGC(backupGCCmd, nil))
+	})
+	assert.Contains(t, output, "✅ Deleted")
+
+	output = captureStdout(t, func() {
+		require.NoError(t, runBackupGC(backupGCCmd, nil))
+	})
+	assert.Contains(t, output, "No unused backup content")
+}
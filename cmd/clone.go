@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+var (
+	cloneOnly            []string
+	cloneNoEnvVars       bool
+	cloneResetTimestamps bool
+	cloneDescription     string
+)
+
+var cloneCmd = &cobra.Command{
+	Use:   "clone <source> <destination>",
+	Short: "Clone an environment",
+	Long: `Create an environment with the snapshots, tools and variables of another.
+
+The clone starts with no switch or snapshot history, and is not switched to.
+By default its tools keep the snapshot times of the source, --reset-timestamps
+dates them to the clone.
+
+Examples:
+  envswitch clone prod staging
+  envswitch clone prod staging --only kubectl,gcloud
+  envswitch clone client-a client-b --no-env-vars --reset-timestamps`,
+	Args: cobra.ExactArgs(2),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return completeEnvironmentNameArgs(cmd, args, toComplete)
+	},
+	RunE: runClone,
+}
+
+func init() {
+	rootCmd.AddCommand(cloneCmd)
+	cloneCmd.Flags().StringSliceVar(&cloneOnly, "only", nil, "Only clone these tools (comma-separated)")
+	cloneCmd.Flags().BoolVar(&cloneNoEnvVars, "no-env-vars", false, "Do not clone the environment variables")
+	cloneCmd.Flags().BoolVar(&cloneResetTimestamps, "reset-timestamps", false, "Date the tool snapshots to the clone")
+	cloneCmd.Flags().StringVarP(&cloneDescription, "description", "d", "", "Description (default: the source's)")
+}
+
+func runClone(cmd *cobra.Command, args []string) error {
+	sourceName, destName := args[0], args[1]
+
+	if err := checkNewEnvironment(destName); err != nil {
+		return err
+	}
+	source, err := environment.LoadEnvironment(sourceName)
+	if err != nil {
+		return err
+	}
+
+	envDir, err := environment.GetEnvironmentsDir()
+	if err != nil {
+		return err
+	}
+
+	description := cloneDescription
+	if description == "" {
+		description = source.Description
+	}
+	env, err := newEnvironment(destName, description, false)
+	if err != nil {
+		return err
+	}
+
+	opts := cloneOptions{
+		Tools:           toolFilter{Only: cloneOnly},
+		NoEnvVars:       cloneNoEnvVars,
+		ResetTimestamps: cloneResetTimestamps,
+	}
+	if err := cloneEnvironment(envDir, sourceName, env.Path, env, opts); err != nil {
+		_ = os.RemoveAll(env.Path)
+		return err
+	}
+	if err := saveNewEnvironment(env); err != nil {
+		_ = os.RemoveAll(env.Path)
+		return err
+	}
+
+	fmt.Printf("✅ Environment '%s' cloned from '%s'\n", destName, sourceName)
+	fmt.Printf("   Path: %s\n", env.Path)
+	fmt.Println()
+	fmt.Printf("Next: envswitch switch %s\n", destName)
+	return nil
+}
@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+func TestRunClone(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+
+	envDir := filepath.Join(tempHome, ".envswitch", "environments")
+	sourcePath := filepath.Join(envDir, "prod")
+	for _, tool := range []string{"gcloud", "kubectl"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(sourcePath, "snapshots", tool), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(sourcePath, "snapshots", tool, "config"), []byte(tool), 0644))
+	}
+	snapshotTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	source := &environment.Environment{
+		Name:         "prod",
+		Description:  "Production",
+		Path:         sourcePath,
+		LastSnapshot: snapshotTime,
+		Tools: map[string]environment.ToolConfig{
+			"gcloud":  {Enabled: true, SnapshotPath: "snapshots/gcloud", LastSnapshot: snapshotTime},
+			"kubectl": {Enabled: true, SnapshotPath: "snapshots/kubectl", LastSnapshot: snapshotTime},
+		},
+		EnvVars: map[string]string{},
+	}
+	require.NoError(t, source.Save())
+	require.NoError(t, source.SetEnvVar("REGION", "eu-west-1"))
+
+	defer func() {
+		cloneOnly = nil
+		cloneNoEnvVars = false
+		cloneResetTimestamps = false
+	}()
+
+	t.Run("clones tools, variables and snapshot times", func(t *testing.T) {
+		output := captureStdout(t, func() {
+			require.NoError(t, runClone(cloneCmd, []string{"prod", "staging"}))
+		})
+		assert.Contains(t, output, "cloned from 'prod'")
+
+		env, err := environment.LoadEnvironment("staging")
+		require.NoError(t, err)
+		assert.Equal(t, "Production", env.Description)
+		assert.Len(t, env.Tools, 2)
+		assert.Equal(t, snapshotTime, env.Tools["kubectl"].LastSnapshot.UTC())
+		assert.FileExists(t, filepath.Join(env.Path, "snapshots", "gcloud", "config"))
+
+		value, _, err := env.GetEnvVar("REGION")
+		require.NoError(t, err)
+		assert.Equal(t, "eu-west-1", value)
+
+		// Not switched to
+		current, _ := environment.GetCurrentEnvironment()
+		assert.Nil(t, current)
+	})
+
+	t.Run("clones selected tools without variables", func(t *testing.T) {
+		cloneOnly = []string{"kubectl"}
+		cloneNoEnvVars = true
+		cloneResetTimestamps = true
+
+		captureStdout(t, func() {
+			require.NoError(t, runClone(cloneCmd, []string{"prod", "dev"}))
+		})
+
+		env, err := environment.LoadEnvironment("dev")
+		require.NoError(t, err)
+		assert.Len(t, env.Tools, 1)
+		assert.True(t, env.Tools["kubectl"].LastSnapshot.After(snapshotTime))
+		assert.Equal(t, env.CreatedAt.Unix(), env.Tools["kubectl"].LastSnapshot.Unix())
+		assert.NoDirExists(t, filepath.Join(env.Path, "snapshots", "gcloud"))
+		assert.FileExists(t, filepath.Join(env.Path, "snapshots", "kubectl", "config"))
+		assert.Empty(t, env.EnvVars)
+		assert.NoFileExists(t, filepath.Join(env.Path, "snapshots", "env-vars.env"))
+	})
+
+	t.Run("rejects a tool the source does not have", func(t *testing.T) {
+		cloneOnly = []string{"terraform"}
+		err := runClone(cloneCmd, []string{"prod", "qa"})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "has no tool 'terraform'")
+		assert.NoDirExists(t, filepath.Join(envDir, "qa"))
+	})
+
+	t.Run("refuses an existing destination", func(t *testing.T) {
+		err := runClone(cloneCmd, []string{"prod", "staging"})
+		assert.ErrorContains(t, err, "already exists")
+	})
+}
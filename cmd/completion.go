@@ -7,7 +7,7 @@ import (
 )
 
 var completionCmd = &cobra.Command{
-	Use:   "completion [bash|zsh|fish]",
+	Use:   "completion [bash|zsh|fish|powershell]",
 	Short: "Generate shell completion script",
 	Long: `Generate shell completion script for envswitch.
 
@@ -38,9 +38,15 @@ Fish:
 
   # To load completions for each session, execute once:
   $ envswitch completion fish > ~/.config/fish/completions/envswitch.fish
+
+PowerShell:
+  PS> envswitch completion powershell | Out-String | Invoke-Expression
+
+  # To load completions for each session, add the output to your profile:
+  PS> envswitch completion powershell >> $PROFILE
 `,
 	DisableFlagsInUseLine: true,
-	ValidArgs:             []string{"bash", "zsh", "fish"},
+	ValidArgs:             []string{"bash", "zsh", "fish", "powershell"},
 	Args:                  cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
 	RunE:                  runCompletion,
 }
@@ -57,6 +63,8 @@ func runCompletion(cmd *cobra.Command, args []string) error {
 		return cmd.Root().GenZshCompletion(os.Stdout)
 	case "fish":
 		return cmd.Root().GenFishCompletion(os.Stdout, true)
+	case "powershell":
+		return cmd.Root().GenPowerShellCompletionWithDesc(os.Stdout)
 	}
 	return nil
 }
@@ -1,12 +1,18 @@
 package cmd
 
 import (
+	"sort"
+	"strings"
+
 	"github.com/spf13/cobra"
 
+	"github.com/hugofrely/envswitch/internal/config"
 	"github.com/hugofrely/envswitch/pkg/environment"
 )
 
-// completeEnvironmentNames provides completion for environment names
+// completeEnvironmentNames provides completion for environment names. Groups
+// are completed one level at a time: "client-a/" is offered until the user
+// types it, then the environments inside it.
 func completeEnvironmentNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 	if len(args) != 0 {
 		return nil, cobra.ShellCompDirectiveNoFileComp
@@ -18,9 +24,134 @@ func completeEnvironmentNames(cmd *cobra.Command, args []string, toComplete stri
 	}
 
 	var names []string
+	seen := make(map[string]bool)
+	directive := cobra.ShellCompDirectiveNoFileComp
 	for _, env := range envs {
-		names = append(names, env.Name)
+		if !strings.HasPrefix(env.Name, toComplete) {
+			continue
+		}
+
+		name := env.Name
+		rest := strings.TrimPrefix(env.Name, toComplete)
+		if i := strings.Index(rest, environment.GroupSeparator); i >= 0 {
+			name = toComplete + rest[:i+1]
+			directive |= cobra.ShellCompDirectiveNoSpace
+		}
+
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+
+	return names, directive
+}
+
+// completeEnvironmentFlag provides completion for flags taking an environment name
+func completeEnvironmentFlag(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return completeEnvironmentNames(cmd, nil, toComplete)
+}
+
+// completeEnvironmentNameArgs provides completion for commands taking any
+// number of environments, leaving out the ones already given
+func completeEnvironmentNameArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	names, directive := completeEnvironmentNames(cmd, nil, toComplete)
+	given := make(map[string]bool, len(args))
+	for _, arg := range args {
+		given[arg] = true
+	}
+	remaining := names[:0]
+	for _, name := range names {
+		if !given[name] {
+			remaining = append(remaining, name)
+		}
+	}
+	return remaining, directive
+}
+
+// completeEnvironmentListFlag provides completion for flags taking a
+// comma-separated list of environment names, completing the last one
+func completeEnvironmentListFlag(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	prefix := ""
+	if i := strings.LastIndex(toComplete, ","); i >= 0 {
+		prefix, toComplete = toComplete[:i+1], toComplete[i+1:]
+	}
+
+	names, directive := completeEnvironmentNames(cmd, nil, toComplete)
+	for i, name := range names {
+		names[i] = prefix + name
+	}
+	return names, directive
+}
+
+// completeEnvVarKeys provides completion for the variables of the
+// environment chosen with --env (--from for env copy), the active one by
+// default. Keys already given are left out.
+func completeEnvVarKeys(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	envName, _ := cmd.Flags().GetString("env")
+	if from, err := cmd.Flags().GetString("from"); err == nil {
+		envName = from
+	}
+	env, err := resolveEnvTarget(envName)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	envVars, err := env.ListEnvVarsMasked("")
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	given := make(map[string]bool, len(args))
+	for _, arg := range args {
+		given[arg] = true
+	}
+	var keys []string
+	for _, envVar := range envVars {
+		if !given[envVar.Key] {
+			keys = append(keys, envVar.Key)
+		}
+	}
+	return keys, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeFirstEnvVarKey provides completion for commands taking a single
+// variable, or a variable then a new name
+func completeFirstEnvVarKey(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) != 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
 	}
+	return completeEnvVarKeys(cmd, args, toComplete)
+}
+
+// completeConfigKeys provides completion for the config keys, then for the
+// values accepted by the key
+func completeConfigKeys(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	switch {
+	case len(args) == 0:
+		return config.Keys, cobra.ShellCompDirectiveNoFileComp
+	case len(args) == 1 && cmd.Name() == "set":
+		return config.KeyValues(args[0]), cobra.ShellCompDirectiveNoFileComp
+	default:
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// completeToolNames provides completion for tool names, plugins included
+func completeToolNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) != 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	var names []string
+	for name := range getToolRegistry() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
 
 	return names, cobra.ShellCompDirectiveNoFileComp
 }
+
+// completeToolFlag provides completion for flags taking tool names
+func completeToolFlag(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return completeToolNames(cmd, nil, toComplete)
+}
@@ -1,11 +1,10 @@
 package cmd
 
 import (
-	"bytes"
-	"io"
-	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/spf13/cobra"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -43,129 +42,136 @@ func TestCompletionCommand(t *testing.T) {
 
 func TestRunCompletion(t *testing.T) {
 	t.Run("generates bash completion script", func(t *testing.T) {
-		// Capture stdout
-		oldStdout := os.Stdout
-		r, w, _ := os.Pipe()
-		os.Stdout = w
-
-		err := runCompletion(completionCmd, []string{"bash"})
-		require.NoError(t, err)
-
-		// Restore stdout
-		w.Close()
-		os.Stdout = oldStdout
-
-		var buf bytes.Buffer
-		io.Copy(&buf, r)
-
-		output := buf.String()
+		output := captureStdout(t, func() {
+			require.NoError(t, runCompletion(completionCmd, []string{"bash"}))
+		})
 		assert.NotEmpty(t, output)
 		assert.Contains(t, output, "bash completion")
 	})
 
 	t.Run("generates zsh completion script", func(t *testing.T) {
-		// Capture stdout
-		oldStdout := os.Stdout
-		r, w, _ := os.Pipe()
-		os.Stdout = w
-
-		err := runCompletion(completionCmd, []string{"zsh"})
-		require.NoError(t, err)
-
-		// Restore stdout
-		w.Close()
-		os.Stdout = oldStdout
-
-		var buf bytes.Buffer
-		io.Copy(&buf, r)
-
-		output := buf.String()
+		output := captureStdout(t, func() {
+			require.NoError(t, runCompletion(completionCmd, []string{"zsh"}))
+		})
 		assert.NotEmpty(t, output)
 		assert.Contains(t, output, "zsh completion")
 	})
 
 	t.Run("generates fish completion script", func(t *testing.T) {
-		// Capture stdout
-		oldStdout := os.Stdout
-		r, w, _ := os.Pipe()
-		os.Stdout = w
-
-		err := runCompletion(completionCmd, []string{"fish"})
-		require.NoError(t, err)
-
-		// Restore stdout
-		w.Close()
-		os.Stdout = oldStdout
-
-		var buf bytes.Buffer
-		io.Copy(&buf, r)
-
-		output := buf.String()
+		output := captureStdout(t, func() {
+			require.NoError(t, runCompletion(completionCmd, []string{"fish"}))
+		})
 		assert.NotEmpty(t, output)
 		// Fish completion has a different format
 		assert.NotEmpty(t, output)
 	})
 }
 
+func TestRunCompletionPowerShell(t *testing.T) {
+	output := captureStdout(t, func() {
+		require.NoError(t, runCompletion(completionCmd, []string{"powershell"}))
+	})
+	assert.Contains(t, output, "Register-ArgumentCompleter")
+}
+
 func TestCompletionIntegration(t *testing.T) {
 	t.Run("bash completion includes all commands", func(t *testing.T) {
-		// Capture stdout
-		oldStdout := os.Stdout
-		r, w, _ := os.Pipe()
-		os.Stdout = w
-
-		err := runCompletion(completionCmd, []string{"bash"})
-		require.NoError(t, err)
-
-		// Restore stdout
-		w.Close()
-		os.Stdout = oldStdout
-
-		var buf bytes.Buffer
-		io.Copy(&buf, r)
-
-		output := buf.String()
+		output := captureStdout(t, func() {
+			require.NoError(t, runCompletion(completionCmd, []string{"bash"}))
+		})
 		// Verify that the completion script includes main commands
 		assert.Contains(t, output, "envswitch")
 	})
 
 	t.Run("zsh completion includes all commands", func(t *testing.T) {
-		// Capture stdout
-		oldStdout := os.Stdout
-		r, w, _ := os.Pipe()
-		os.Stdout = w
+		output := captureStdout(t, func() {
+			require.NoError(t, runCompletion(completionCmd, []string{"zsh"}))
+		})
+		assert.Contains(t, output, "envswitch")
+	})
 
-		err := runCompletion(completionCmd, []string{"zsh"})
-		require.NoError(t, err)
+	t.Run("fish completion includes all commands", func(t *testing.T) {
+		output := captureStdout(t, func() {
+			require.NoError(t, runCompletion(completionCmd, []string{"fish"}))
+		})
+		assert.Contains(t, output, "envswitch")
+	})
+}
 
-		// Restore stdout
-		w.Close()
-		os.Stdout = oldStdout
+func TestCompleteEnvironmentNames(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
 
-		var buf bytes.Buffer
-		io.Copy(&buf, r)
+	envsDir := filepath.Join(tempHome, ".envswitch", "environments")
+	createEnvWithVars(t, envsDir, "personal", nil)
+	createEnvWithVars(t, envsDir, "client-a/prod", nil)
+	createEnvWithVars(t, envsDir, "client-a/dev", nil)
 
-		output := buf.String()
-		assert.Contains(t, output, "envswitch")
+	t.Run("offers groups before their environments", func(t *testing.T) {
+		names, directive := completeEnvironmentNames(rootCmd, nil, "")
+
+		assert.Equal(t, []string{"client-a/", "personal"}, names)
+		assert.NotZero(t, directive&cobra.ShellCompDirectiveNoSpace)
 	})
 
-	t.Run("fish completion includes all commands", func(t *testing.T) {
-		// Capture stdout
-		oldStdout := os.Stdout
-		r, w, _ := os.Pipe()
-		os.Stdout = w
+	t.Run("completes inside a group", func(t *testing.T) {
+		names, directive := completeEnvironmentNames(rootCmd, nil, "client-a/")
 
-		err := runCompletion(completionCmd, []string{"fish"})
-		require.NoError(t, err)
+		assert.Equal(t, []string{"client-a/dev", "client-a/prod"}, names)
+		assert.Zero(t, directive&cobra.ShellCompDirectiveNoSpace)
+	})
+}
 
-		// Restore stdout
-		w.Close()
-		os.Stdout = oldStdout
+func TestCompleteEnvironmentLists(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
 
-		var buf bytes.Buffer
-		io.Copy(&buf, r)
+	envsDir := filepath.Join(tempHome, ".envswitch", "environments")
+	createEnvWithVars(t, envsDir, "personal", nil)
+	createEnvWithVars(t, envsDir, "work", nil)
 
-		output := buf.String()
-		assert.Contains(t, output, "envswitch")
+	t.Run("leaves out the environments already given", func(t *testing.T) {
+		names, _ := completeEnvironmentNameArgs(exportCmd, []string{"work"}, "")
+		assert.Equal(t, []string{"personal"}, names)
 	})
+
+	t.Run("completes the last name of a comma-separated list", func(t *testing.T) {
+		names, _ := completeEnvironmentListFlag(envCopyCmd, nil, "work,p")
+		assert.Equal(t, []string{"work,personal"}, names)
+	})
+}
+
+func TestCompleteEnvVarKeys(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+
+	envsDir := filepath.Join(tempHome, ".envswitch", "environments")
+	createEnvWithVars(t, envsDir, "work", map[string]string{"API_URL": "u", "REGION": "r"})
+
+	cmd := &cobra.Command{Use: "unset"}
+	cmd.Flags().String("env", "work", "")
+
+	keys, _ := completeEnvVarKeys(cmd, nil, "")
+	assert.Equal(t, []string{"API_URL", "REGION"}, keys)
+
+	keys, _ = completeEnvVarKeys(cmd, []string{"API_URL"}, "")
+	assert.Equal(t, []string{"REGION"}, keys)
+
+	keys, _ = completeFirstEnvVarKey(cmd, []string{"API_URL"}, "")
+	assert.Empty(t, keys)
+}
+
+func TestCompleteConfigKeys(t *testing.T) {
+	keys, _ := completeConfigKeys(configSetCmd, nil, "")
+	assert.Contains(t, keys, "prompt_color")
+	assert.Contains(t, keys, "default_editor")
+
+	values, _ := completeConfigKeys(configSetCmd, []string{"prompt_color"}, "")
+	assert.Contains(t, values, "blue")
+
+	values, _ = completeConfigKeys(configSetCmd, []string{"verify_after_switch"}, "")
+	assert.Equal(t, []string{"true", "false"}, values)
+
+	values, _ = completeConfigKeys(configGetCmd, []string{"prompt_color"}, "")
+	assert.Empty(t, values)
 }
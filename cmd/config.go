@@ -1,13 +1,20 @@
 package cmd
 
 import (
+	"bytes"
 	"fmt"
-	"strconv"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v3"
 
 	"github.com/hugofrely/envswitch/internal/config"
+	"github.com/hugofrely/envswitch/internal/output"
+	"github.com/hugofrely/envswitch/internal/storage"
 )
 
 var configCmd = &cobra.Command{
@@ -23,17 +30,51 @@ var configListCmd = &cobra.Command{
 }
 
 var configGetCmd = &cobra.Command{
-	Use:   "get <key>",
-	Short: "Get a configuration value",
-	Args:  cobra.ExactArgs(1),
-	RunE:  runConfigGet,
+	Use:               "get <key>",
+	Short:             "Get a configuration value",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeConfigKeys,
+	RunE:              runConfigGet,
 }
 
 var configSetCmd = &cobra.Command{
-	Use:   "set <key> <value>",
-	Short: "Set a configuration value",
-	Args:  cobra.ExactArgs(2),
-	RunE:  runConfigSet,
+	Use:               "set <key> <value>",
+	Short:             "Set a configuration value",
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: completeConfigKeys,
+	RunE:              runConfigSet,
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate [file]",
+	Short: "Check the configuration file for errors",
+	Long: `Check ~/.envswitch/config.yaml and config.local.yaml, or the given file, for
+unknown keys, values of the wrong type and invalid values. The ENVSWITCH_<KEY>
+overrides set in the environment are checked too.
+
+Examples:
+  envswitch config validate
+  envswitch config validate ./config.yaml`,
+	Args: cobra.MaximumNArgs(1),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"yaml", "yml"}, cobra.ShellCompDirectiveFilterFileExt
+	},
+	RunE: runConfigValidate,
+}
+
+var configEditCmd = &cobra.Command{
+	Use:   "edit",
+	Short: "Edit the configuration file",
+	Long: `Open ~/.envswitch/config.yaml in an editor and save it back once it is valid.
+The editor is default_editor, then $VISUAL, then $EDITOR. When the edited file
+has errors they are listed and it can be edited again; the configuration is
+left unchanged otherwise.
+
+Examples:
+  envswitch config edit
+  envswitch config set default_editor "code --wait"`,
+	Args: cobra.NoArgs,
+	RunE: runConfigEdit,
 }
 
 func init() {
@@ -41,6 +82,8 @@ func init() {
 	configCmd.AddCommand(configListCmd)
 	configCmd.AddCommand(configGetCmd)
 	configCmd.AddCommand(configSetCmd)
+	configCmd.AddCommand(configValidateCmd)
+	configCmd.AddCommand(configEditCmd)
 }
 
 func runConfigList(cmd *cobra.Command, args []string) error {
@@ -49,6 +92,14 @@ func runConfigList(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 
+	out, err := resultWriter()
+	if err != nil {
+		return err
+	}
+	if out.Structured() {
+		return out.Write(output.YAMLTagged(cfg))
+	}
+
 	// Marshal to YAML for pretty printing
 	data, err := yaml.Marshal(cfg)
 	if err != nil {
@@ -58,6 +109,10 @@ func runConfigList(cmd *cobra.Command, args []string) error {
 	fmt.Println("Global Configuration:")
 	fmt.Println()
 	fmt.Print(string(data))
+	if overrides := cfg.Overrides(); len(overrides) > 0 {
+		fmt.Println()
+		fmt.Printf("Overridden by: %s\n", strings.Join(overrides, ", "))
+	}
 
 	return nil
 }
@@ -83,27 +138,12 @@ func runConfigSet(cmd *cobra.Command, args []string) error {
 	key := args[0]
 	valueStr := args[1]
 
-	cfg, err := config.LoadConfig()
+	cfg, err := config.LoadBaseConfig()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 
-	// Try to parse value as different types
-	var value interface{}
-
-	// Special handling for auto_save_before_switch which needs string values
-	if key == "auto_save_before_switch" {
-		value = valueStr
-	} else if valueStr == "true" || valueStr == "false" {
-		// Try bool for other keys
-		value = valueStr == "true"
-	} else if intVal, err := strconv.Atoi(valueStr); err == nil {
-		// Try int
-		value = intVal
-	} else {
-		// Default to string
-		value = valueStr
-	}
+	value := config.ParseValue(key, valueStr)
 
 	if err := cfg.Set(key, value); err != nil {
 		return err
@@ -114,5 +154,158 @@ func runConfigSet(cmd *cobra.Command, args []string) error {
 	}
 
 	fmt.Printf("✅ Configuration updated: %s = %v\n", key, value)
+	if key == "ssh_include_private_keys" && cfg.SSHIncludePrivateKeys {
+		fmt.Println("⚠️  SSH private keys will be copied into snapshots, and leave this machine with export, send and sync")
+	}
+
+	// Warn when config.local.yaml or the environment hides the new value
+	if merged, err := config.LoadConfig(); err == nil {
+		if effective, err := merged.Get(key); err == nil && fmt.Sprint(effective) != fmt.Sprint(value) {
+			fmt.Printf("⚠️  Overridden on this machine (%s), the value in effect is %v\n", strings.Join(merged.Overrides(), ", "), effective)
+		}
+	}
+	return nil
+}
+
+func runConfigValidate(cmd *cobra.Command, args []string) error {
+	paths := []string{config.GetConfigPath(), config.GetLocalConfigPath()}
+	if len(args) > 0 {
+		if _, err := os.Stat(args[0]); err != nil {
+			return fmt.Errorf("%s does not exist", args[0])
+		}
+		paths = args
+	}
+
+	checked, problems := 0, 0
+	for _, path := range paths {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			continue
+		}
+		checked++
+
+		errs, err := config.ValidateFile(path)
+		if err != nil {
+			return err
+		}
+		if len(errs) == 0 {
+			fmt.Printf("✅ %s is valid\n", path)
+			continue
+		}
+		fmt.Printf("✗ %s:\n", path)
+		for _, err := range errs {
+			fmt.Printf("  ✗ %v\n", err)
+		}
+		problems += len(errs)
+	}
+
+	// The ENVSWITCH_<KEY> overrides are only checked along with the files
+	// they apply to
+	if len(args) == 0 {
+		if _, err := config.LoadConfig(); err != nil && problems == 0 {
+			fmt.Printf("✗ %v\n", err)
+			problems++
+		}
+	}
+
+	if problems > 0 {
+		return fmt.Errorf("%d problem(s) in the configuration", problems)
+	}
+	if checked == 0 {
+		fmt.Println("✓ No config file, the defaults are used")
+	}
+	return nil
+}
+
+func runConfigEdit(cmd *cobra.Command, args []string) error {
+	path := config.GetConfigPath()
+	original, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		if original, err = yaml.Marshal(config.DefaultConfig()); err != nil {
+			return fmt.Errorf("failed to format config: %w", err)
+		}
+	} else if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	tmp, err := os.CreateTemp("", "envswitch-config-*.yaml")
+	if err != nil {
+		return fmt.Errorf("failed to create a copy to edit: %w", err)
+	}
+	tmpPath := tmp.Name()
+	_ = tmp.Close()
+	defer os.Remove(tmpPath)
+
+	editor := editorCommand()
+	data := original
+	for {
+		if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+			return fmt.Errorf("failed to write the copy to edit: %w", err)
+		}
+		if err := runEditor(editor, tmpPath); err != nil {
+			return err
+		}
+		if data, err = os.ReadFile(tmpPath); err != nil {
+			return fmt.Errorf("failed to read the edited copy: %w", err)
+		}
+		if bytes.Equal(data, original) {
+			fmt.Println("No changes")
+			return nil
+		}
+
+		errs := config.ValidateData(data)
+		if len(errs) == 0 {
+			break
+		}
+		fmt.Println("The edited configuration has errors:")
+		for _, err := range errs {
+			fmt.Printf("  ✗ %v\n", err)
+		}
+		fmt.Print("Edit it again? [y/N]: ")
+		var response string
+		if _, err := fmt.Scanln(&response); err != nil || (response != "y" && response != "Y") {
+			return fmt.Errorf("invalid configuration, %s left unchanged", path)
+		}
+	}
+
+	if err := storage.MkdirPrivate(filepath.Dir(path)); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	fmt.Printf("✅ Saved %s\n", path)
+	return nil
+}
+
+// editorCommand returns the editor of 'config edit' and its arguments
+func editorCommand() []string {
+	var editor string
+	// An unreadable config must still be editable, its editor then ignored
+	if cfg, err := config.LoadConfig(); err == nil {
+		editor = cfg.DefaultEditor
+	}
+	for _, name := range []string{"VISUAL", "EDITOR"} {
+		if editor == "" {
+			editor = os.Getenv(name)
+		}
+	}
+	if fields := strings.Fields(editor); len(fields) > 0 {
+		return fields
+	}
+	if runtime.GOOS == "windows" {
+		return []string{"notepad"}
+	}
+	return []string{"vi"}
+}
+
+// runEditor opens path in editor and waits for it to exit
+func runEditor(editor []string, path string) error {
+	editCmd := exec.Command(editor[0], append(editor[1:], path)...)
+	editCmd.Stdin = os.Stdin
+	editCmd.Stdout = os.Stdout
+	editCmd.Stderr = os.Stderr
+	if err := editCmd.Run(); err != nil {
+		return fmt.Errorf("editor %s failed: %w", editor[0], err)
+	}
 	return nil
 }
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"encoding/json"
 	"os"
 	"path/filepath"
 	"testing"
@@ -78,6 +79,19 @@ func TestRunConfigList(t *testing.T) {
 		err = runConfigList(configListCmd, []string{})
 		assert.NoError(t, err)
 	})
+
+	t.Run("lists configuration as JSON", func(t *testing.T) {
+		setOutputFormat(t, "json")
+
+		out := captureStdout(t, func() {
+			require.NoError(t, runConfigList(configListCmd, []string{}))
+		})
+
+		var values map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(out), &values), out)
+		assert.Equal(t, "debug", values["log_level"])
+		assert.Equal(t, false, values["color_output"])
+	})
 }
 
 func TestRunConfigGet(t *testing.T) {
@@ -379,7 +393,120 @@ func TestConfigIntegration(t *testing.T) {
 		// Check some default values
 		assert.Equal(t, "warn", cfg.LogLevel)
 		assert.Equal(t, 10, cfg.BackupRetention)
-		assert.Equal(t, "false", cfg.AutoSaveBeforeSwitch) // Changed to false to match new workflow
+		assert.Equal(t, "true", cfg.AutoSaveBeforeSwitch)
 		assert.True(t, cfg.ColorOutput)
 	})
 }
+
+func TestRunConfigValidate(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	t.Run("no config file", func(t *testing.T) {
+		out := captureStdout(t, func() {
+			require.NoError(t, runConfigValidate(configValidateCmd, nil))
+		})
+		assert.Contains(t, out, "defaults are used")
+	})
+
+	t.Run("reports every problem", func(t *testing.T) {
+		path := filepath.Join(home, "config.yaml")
+		require.NoError(t, os.WriteFile(path, []byte("promt_color: red\nbackup_retention: lots\nprompt_color: purple\n"), 0600))
+
+		var err error
+		out := captureStdout(t, func() {
+			err = runConfigValidate(configValidateCmd, []string{path})
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "3 problem(s)")
+		assert.Contains(t, out, "unknown key 'promt_color'")
+		assert.Contains(t, out, "line 2: cannot unmarshal")
+		assert.Contains(t, out, "prompt_color: invalid value 'purple'")
+	})
+
+	t.Run("valid file", func(t *testing.T) {
+		cfg := config.DefaultConfig()
+		require.NoError(t, cfg.Save())
+
+		out := captureStdout(t, func() {
+			require.NoError(t, runConfigValidate(configValidateCmd, nil))
+		})
+		assert.Contains(t, out, "is valid")
+	})
+}
+
+func TestRunConfigEdit(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("VISUAL", "")
+
+	// The "editor" replaces the edited copy with the content of a file
+	edited := filepath.Join(t.TempDir(), "edited.yaml")
+	t.Setenv("EDITOR", "cp "+edited)
+
+	t.Run("saves a valid config", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(edited, []byte("# tuned\nprompt_color: red\n"), 0600))
+
+		captureStdout(t, func() {
+			require.NoError(t, runConfigEdit(configEditCmd, nil))
+		})
+
+		data, err := os.ReadFile(config.GetConfigPath())
+		require.NoError(t, err)
+		assert.Equal(t, "# tuned\nprompt_color: red\n", string(data))
+	})
+
+	t.Run("leaves the config unchanged when invalid", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(edited, []byte("prompt_color: purple\n"), 0600))
+
+		var err error
+		out := captureStdout(t, func() {
+			err = runConfigEdit(configEditCmd, nil)
+		})
+		require.Error(t, err)
+		assert.Contains(t, out, "prompt_color: invalid value 'purple'")
+
+		cfg, err := config.LoadConfig()
+		require.NoError(t, err)
+		assert.Equal(t, "red", cfg.PromptColor)
+	})
+
+	t.Run("uses default_editor first", func(t *testing.T) {
+		t.Setenv("EDITOR", "")
+		cfg, err := config.LoadConfig()
+		require.NoError(t, err)
+		cfg.DefaultEditor = "code --wait"
+		require.NoError(t, cfg.Save())
+
+		assert.Equal(t, []string{"code", "--wait"}, editorCommand())
+	})
+}
+
+func TestRunConfigSetWithOverrides(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("ENVSWITCH_PROMPT_COLOR", "red")
+
+	out := captureStdout(t, func() {
+		require.NoError(t, runConfigSet(configSetCmd, []string{"prompt_color", "green"}))
+	})
+	assert.Contains(t, out, "Overridden on this machine (ENVSWITCH_PROMPT_COLOR), the value in effect is red")
+
+	// The override is not written to config.yaml
+	cfg, err := config.LoadBaseConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "green", cfg.PromptColor)
+}
+
+func TestRunConfigSetSSHPrivateKeys(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	out := captureStdout(t, func() {
+		require.NoError(t, runConfigSet(configSetCmd, []string{"ssh_include_private_keys", "true"}))
+	})
+	assert.Contains(t, out, "SSH private keys will be copied into snapshots")
+
+	out = captureStdout(t, func() {
+		require.NoError(t, runConfigSet(configSetCmd, []string{"ssh_include_private_keys", "false"}))
+	})
+	assert.NotContains(t, out, "private keys")
+}
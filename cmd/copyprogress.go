@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/dustin/go-humanize"
+
+	"github.com/hugofrely/envswitch/internal/output"
+	"github.com/hugofrely/envswitch/internal/storage"
+	"github.com/hugofrely/envswitch/pkg/spinner"
+	"github.com/hugofrely/envswitch/pkg/tools"
+)
+
+// largeCopyBytes is the size from which the copy of a tool shows a progress
+// bar on the spinner
+const largeCopyBytes = 10 << 20
+
+// progressBarWidth is the number of cells of a progress bar
+const progressBarWidth = 20
+
+// copyProgress is called as the files of a tool are copied, by the tools
+// that report it
+type copyProgress func(toolName string, p storage.CopyProgress)
+
+type copyProgressKey struct{}
+
+// withCopyProgress returns a context whose tool snapshots and restores
+// report the progress of their copies to report
+func withCopyProgress(ctx context.Context, report copyProgress) context.Context {
+	if report == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, copyProgressKey{}, report)
+}
+
+// copyProgressFrom returns the copyProgress of ctx, nil when there is none
+func copyProgressFrom(ctx context.Context) copyProgress {
+	report, _ := ctx.Value(copyProgressKey{}).(copyProgress)
+	return report
+}
+
+// reportCopies runs fn with tool reporting the progress of its copies to the
+// copyProgress of ctx, when both are there
+func reportCopies(ctx context.Context, toolName string, tool tools.Tool, fn func() error) error {
+	report := copyProgressFrom(ctx)
+	reporter, ok := tool.(tools.ProgressReporter)
+	if report == nil || !ok {
+		return fn()
+	}
+
+	reporter.SetProgress(storage.ProgressFunc(func(p storage.CopyProgress) {
+		report(toolName, p)
+	}))
+	defer reporter.SetProgress(nil)
+	return fn()
+}
+
+// percentChanges returns a function reporting whether the percentage of a
+// tool's copy changed since it last returned true, to not redraw on every
+// file
+func percentChanges() func(toolName string, p storage.CopyProgress) (int, bool) {
+	var mu sync.Mutex
+	shown := make(map[string]int)
+	return func(toolName string, p storage.CopyProgress) (int, bool) {
+		percent := 100
+		if p.TotalBytes > 0 {
+			percent = int(p.Bytes * 100 / p.TotalBytes)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if last, seen := shown[toolName]; seen && last == percent {
+			return percent, false
+		}
+		shown[toolName] = percent
+		return percent, true
+	}
+}
+
+// spinnerCopyProgress shows the copies of large tools as a progress bar on
+// the spinner, or returns nil when there is no spinner
+func spinnerCopyProgress(s *spinner.Spinner, action string) copyProgress {
+	if s == nil {
+		return nil
+	}
+	changed := percentChanges()
+	return func(toolName string, p storage.CopyProgress) {
+		if p.TotalBytes < largeCopyBytes {
+			return
+		}
+		percent, ok := changed(toolName, p)
+		if !ok {
+			return
+		}
+		s.Update(fmt.Sprintf("%s %s %s %3d%% (%s/%s, %d/%d files)", action, toolName, progressBar(percent), percent,
+			humanize.Bytes(uint64(p.Bytes)), humanize.Bytes(uint64(p.TotalBytes)), p.Files, p.TotalFiles))
+	}
+}
+
+// progressBar draws percent as a bar of progressBarWidth cells
+func progressBar(percent int) string {
+	if percent < 0 {
+		percent = 0
+	}
+	if percent > 100 {
+		percent = 100
+	}
+	filled := percent * progressBarWidth / 100
+	return "[" + strings.Repeat("#", filled) + strings.Repeat("-", progressBarWidth-filled) + "]"
+}
+
+// copyProgressEvent is the event of the progress of a copy, written with
+// --output json
+type copyProgressEvent struct {
+	Event      string `json:"event"`
+	Phase      string `json:"phase"`
+	Tool       string `json:"tool"`
+	Percent    int    `json:"percent"`
+	Files      int    `json:"files"`
+	TotalFiles int    `json:"total_files"`
+	Bytes      int64  `json:"bytes"`
+	TotalBytes int64  `json:"total_bytes"`
+}
+
+// eventCopyProgress writes the progress of copies to w as JSON events, one
+// per line, each time the percentage of a tool changes
+func eventCopyProgress(w io.Writer, phase string) copyProgress {
+	var mu sync.Mutex
+	changed := percentChanges()
+	return func(toolName string, p storage.CopyProgress) {
+		percent, ok := changed(toolName, p)
+		if !ok {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		_ = output.WriteEvent(w, copyProgressEvent{
+			Event:      "copy_progress",
+			Phase:      phase,
+			Tool:       toolName,
+			Percent:    percent,
+			Files:      p.Files,
+			TotalFiles: p.TotalFiles,
+			Bytes:      p.Bytes,
+			TotalBytes: p.TotalBytes,
+		})
+	}
+}
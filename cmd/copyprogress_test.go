@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hugofrely/envswitch/internal/storage"
+	"github.com/hugofrely/envswitch/pkg/tools"
+)
+
+func TestReportCopies(t *testing.T) {
+	configDir := t.TempDir()
+	for _, name := range []string{"config", "credentials"} {
+		require.NoError(t, os.WriteFile(filepath.Join(configDir, name), []byte("value"), 0600))
+	}
+	tool := &tools.DockerTool{DockerConfigDir: configDir}
+
+	var buf bytes.Buffer
+	ctx := withCopyProgress(context.Background(), eventCopyProgress(&buf, phaseSave))
+	require.NoError(t, reportCopies(ctx, "docker", tool, func() error {
+		_, err := storage.SnapshotDirProgress(configDir, t.TempDir(), tool.Progress)
+		return err
+	}))
+	assert.Nil(t, tool.Progress, "the progress is unset once done")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 3, "one event per percentage")
+	var last copyProgressEvent
+	require.NoError(t, json.Unmarshal([]byte(lines[2]), &last))
+	assert.Equal(t, copyProgressEvent{
+		Event: "copy_progress", Phase: "save", Tool: "docker", Percent: 100,
+		Files: 2, TotalFiles: 2, Bytes: 10, TotalBytes: 10,
+	}, last)
+
+	t.Run("without a copyProgress", func(t *testing.T) {
+		called := false
+		require.NoError(t, reportCopies(context.Background(), "docker", tool, func() error {
+			called = true
+			assert.Nil(t, tool.Progress)
+			return nil
+		}))
+		assert.True(t, called)
+	})
+}
+
+func TestProgressBar(t *testing.T) {
+	assert.Equal(t, "[--------------------]", progressBar(0))
+	assert.Equal(t, "[##########----------]", progressBar(50))
+	assert.Equal(t, "[####################]", progressBar(100))
+	assert.Equal(t, "[####################]", progressBar(150))
+}
@@ -4,13 +4,14 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
 
+	"github.com/hugofrely/envswitch/internal/storage"
 	"github.com/hugofrely/envswitch/pkg/environment"
 	"github.com/hugofrely/envswitch/pkg/spinner"
-	"github.com/hugofrely/envswitch/pkg/tools"
 )
 
 var (
@@ -18,13 +19,25 @@ var (
 	createEmpty       bool
 	createFrom        string
 	createDescription string
+	createTemplate    string
+	createSet         []string
 )
 
 var createCmd = &cobra.Command{
 	Use:   "create <name>",
 	Short: "Create a new environment",
 	Long: `Create a new environment from the current system state,
-another environment, or as an empty template.`,
+another environment, or as an empty template.
+
+With --template, the environment gets the tools, hooks, tags and variables of
+a template saved with 'envswitch template save'. The values of secret
+variables are asked for, or given with --set.
+
+Examples:
+  envswitch create work --from-current
+  envswitch create staging --from prod
+  envswitch create client-b --template client
+  envswitch create client-b --template client --set API_TOKEN=... --from-current`,
 	Args: cobra.ExactArgs(1),
 	RunE: runCreate,
 }
@@ -36,6 +49,9 @@ func init() {
 	createCmd.Flags().BoolVar(&createEmpty, "empty", false, "Create empty environment")
 	createCmd.Flags().StringVar(&createFrom, "from", "", "Clone from existing environment")
 	createCmd.Flags().StringVarP(&createDescription, "description", "d", "", "Environment description")
+	createCmd.Flags().StringVar(&createTemplate, "template", "", "Create from a template")
+	createCmd.Flags().StringArrayVar(&createSet, "set", nil, "Value of a template variable, as KEY=value (repeatable)")
+	_ = createCmd.RegisterFlagCompletionFunc("template", completeTemplateNames)
 
 	// Add auto-completion for --from flag
 	createCmd.RegisterFlagCompletionFunc("from", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
@@ -51,8 +67,16 @@ func init() {
 	})
 }
 
+// cloneOptions selects what cloneEnvironment copies. The zero value copies
+// every tool and the variables, keeping their snapshot times.
+type cloneOptions struct {
+	Tools           toolFilter
+	NoEnvVars       bool
+	ResetTimestamps bool // date the tool snapshots to the clone
+}
+
 // cloneEnvironment copies snapshots and configuration from an existing environment
-func cloneEnvironment(envDir, sourceName, destPath string, env *environment.Environment) error {
+func cloneEnvironment(envDir, sourceName, destPath string, env *environment.Environment, opts cloneOptions) error {
 	fmt.Printf("📋 Cloning from environment '%s'...\n", sourceName)
 	fmt.Println()
 
@@ -67,10 +91,20 @@ func cloneEnvironment(envDir, sourceName, destPath string, env *environment.Envi
 		return fmt.Errorf("failed to load source environment: %w", err)
 	}
 
+	for _, name := range opts.Tools.Only {
+		if _, exists := sourceEnv.Tools[name]; !exists {
+			return fmt.Errorf("environment '%s' has no tool '%s'", sourceName, name)
+		}
+	}
+
 	// Copy snapshots directory
 	sourceSnapshots := filepath.Join(sourceEnvPath, "snapshots")
 	destSnapshots := filepath.Join(destPath, "snapshots")
 
+	if err := storage.CheckDiskSpace(destPath, storage.PathSize(sourceSnapshots)); err != nil {
+		return diskSpaceError(err, "clone")
+	}
+
 	err = filepath.Walk(sourceSnapshots, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
@@ -82,6 +116,18 @@ func cloneEnvironment(envDir, sourceName, destPath string, env *environment.Envi
 			return err
 		}
 
+		// Leave out the tools and variables not cloned
+		top := strings.Split(filepath.ToSlash(relPath), "/")[0]
+		if top == "env-vars.env" && opts.NoEnvVars {
+			return nil
+		}
+		if _, isTool := sourceEnv.Tools[top]; isTool && !opts.Tools.allows(top) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
 		destPathFile := filepath.Join(destSnapshots, relPath)
 
 		// Create directories
@@ -101,38 +147,64 @@ func cloneEnvironment(envDir, sourceName, destPath string, env *environment.Envi
 		return fmt.Errorf("failed to copy snapshots: %w", err)
 	}
 
-	// Copy tool configurations and env vars
-	env.Tools = sourceEnv.Tools
-	env.EnvVars = sourceEnv.EnvVars
+	// Copy tool configurations
+	env.Tools = make(map[string]environment.ToolConfig)
+	for name, toolConfig := range sourceEnv.Tools {
+		if !opts.Tools.allows(name) {
+			continue
+		}
+		if opts.ResetTimestamps && !toolConfig.LastSnapshot.IsZero() {
+			toolConfig.LastSnapshot = env.CreatedAt
+		}
+		env.Tools[name] = toolConfig
+	}
+	if !sourceEnv.LastSnapshot.IsZero() {
+		env.LastSnapshot = sourceEnv.LastSnapshot
+		if opts.ResetTimestamps {
+			env.LastSnapshot = env.CreatedAt
+		}
+	}
 
-	// Copy env-vars.env file
-	sourceEnvVars := filepath.Join(sourceEnvPath, "env-vars.env")
-	destEnvVars := filepath.Join(destPath, "env-vars.env")
-	if data, err := os.ReadFile(sourceEnvVars); err == nil {
-		if err := os.WriteFile(destEnvVars, data, 0644); err != nil {
-			return fmt.Errorf("failed to copy env-vars.env: %w", err)
+	// Copy env vars and env-vars.env file
+	if !opts.NoEnvVars {
+		env.EnvVars = sourceEnv.EnvVars
+		env.SecretEnvVars = sourceEnv.SecretEnvVars
+		sourceEnvVars := filepath.Join(sourceEnvPath, "env-vars.env")
+		destEnvVars := filepath.Join(destPath, "env-vars.env")
+		if data, err := os.ReadFile(sourceEnvVars); err == nil {
+			if err := os.WriteFile(destEnvVars, data, 0600); err != nil {
+				return fmt.Errorf("failed to copy env-vars.env: %w", err)
+			}
 		}
 	}
 
-	fmt.Printf("✅ Cloned %d tool(s) from '%s'\n", len(sourceEnv.Tools), sourceName)
+	fmt.Printf("✅ Cloned %d tool(s) from '%s'\n", len(env.Tools), sourceName)
 	fmt.Println()
 
 	return nil
 }
 
-// captureCurrentState captures snapshots from the current system state
-func captureCurrentState(envPath string, env *environment.Environment) error {
+// captureCurrentState captures snapshots from the current system state,
+// leaving out the tools the filter does not allow
+func captureCurrentState(envPath string, env *environment.Environment, filter toolFilter) error {
 	spin := spinner.New("Capturing current state")
 	spin.Start()
 
 	// Capture snapshots for each tool
 	capturedCount := 0
-	availableTools := map[string]tools.Tool{
-		"gcloud":  tools.NewGCloudTool(),
-		"kubectl": tools.NewKubectlTool(),
-		"aws":     tools.NewAWSTool(),
-		"docker":  tools.NewDockerTool(),
-		"git":     tools.NewGitTool(),
+	var capturedTools []string
+	availableTools := filter.apply(getToolRegistry())
+	keyringFiles := sensitiveFiles()
+
+	var installedTools []string
+	for toolName, toolImpl := range availableTools {
+		if toolImpl.IsInstalled() {
+			installedTools = append(installedTools, toolName)
+		}
+	}
+	if err := checkSnapshotSpace(env, availableTools, installedTools); err != nil {
+		spin.Error("Not enough disk space")
+		return err
 	}
 
 	for toolName, toolImpl := range availableTools {
@@ -175,6 +247,14 @@ func captureCurrentState(envPath string, env *environment.Environment) error {
 			}
 			continue
 		}
+		if _, err := storage.HardenPermissions(snapshotPath); err != nil {
+			snapshotLog.Warn("Failed to restrict permissions of the %s snapshot: %v", toolName, err)
+		}
+
+		if err := env.SeparateHostScoped(toolName); err != nil {
+			snapshotLog.Warn("Failed to store machine-specific snapshot of %s: %v", toolName, err)
+		}
+		storeSensitiveFiles(env, toolName, keyringFiles)
 
 		// Get metadata
 		metadata, err := toolImpl.GetMetadata()
@@ -187,9 +267,16 @@ func captureCurrentState(envPath string, env *environment.Environment) error {
 			Enabled:      true,
 			SnapshotPath: filepath.Join("snapshots", toolName),
 			Metadata:     metadata,
+			LastSnapshot: time.Now(),
+			SnapshotSize: env.ToolSnapshotSize(toolName),
 		}
 
 		capturedCount++
+		capturedTools = append(capturedTools, toolName)
+	}
+
+	if err := env.UpdateChecksums(capturedTools...); err != nil {
+		snapshotLog.Warn("Failed to record snapshot checksums: %v", err)
 	}
 
 	// Update snapshot info
@@ -199,39 +286,53 @@ func captureCurrentState(envPath string, env *environment.Environment) error {
 	return nil
 }
 
-func runCreate(cmd *cobra.Command, args []string) error {
-	name := args[0]
-
+// checkNewEnvironment checks that an environment named name can be created
+func checkNewEnvironment(name string) error {
 	// Validate name
-	if name == "" {
-		return fmt.Errorf("environment name cannot be empty")
+	if err := environment.ValidateName(name); err != nil {
+		return err
 	}
 
-	// Check if environment already exists
-	envDir, err := environment.GetEnvironmentsDir()
-	if err != nil {
+	// Groups and environments cannot share a directory
+	if err := environment.CheckGroups(name); err != nil {
 		return err
 	}
 
-	envPath := filepath.Join(envDir, name)
-	if _, err := os.Stat(envPath); !os.IsNotExist(err) {
+	// Check if environment already exists (ignoring case, names must not
+	// collide on case-insensitive filesystems)
+	exists, err := environment.EnvironmentExists(name)
+	if err != nil {
+		return err
+	}
+	if exists {
 		return fmt.Errorf("environment '%s' already exists", name)
 	}
+	return nil
+}
+
+// newEnvironment creates the directories of a new environment and returns
+// it with every tool, enabled or not, and without saving it
+func newEnvironment(name, description string, enableTools bool) (*environment.Environment, error) {
+	envDir, err := environment.GetEnvironmentsDir()
+	if err != nil {
+		return nil, err
+	}
+	envPath := filepath.Join(envDir, name)
 
 	// Create environment directory structure
-	if err := os.MkdirAll(envPath, 0755); err != nil {
-		return fmt.Errorf("failed to create environment directory: %w", err)
+	if err := os.MkdirAll(envPath, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create environment directory: %w", err)
 	}
 
 	snapshotsPath := filepath.Join(envPath, "snapshots")
-	if err := os.MkdirAll(snapshotsPath, 0755); err != nil {
-		return fmt.Errorf("failed to create snapshots directory: %w", err)
+	if err := os.MkdirAll(snapshotsPath, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create snapshots directory: %w", err)
 	}
 
 	// Create environment object
 	env := &environment.Environment{
 		Name:        name,
-		Description: createDescription,
+		Description: description,
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 		LastUsed:    time.Time{},
@@ -241,37 +342,98 @@ func runCreate(cmd *cobra.Command, args []string) error {
 	}
 
 	// Initialize tools
-	toolNames := []string{"gcloud", "kubectl", "aws", "azure", "docker", "terraform", "git"}
-	for _, toolName := range toolNames {
+	for _, tool := range newToolRegistry().List() {
+		toolName := tool.Name()
 		env.Tools[toolName] = environment.ToolConfig{
-			Enabled:      createFromCurrent, // Only enable if creating from current
+			Enabled:      enableTools,
 			SnapshotPath: filepath.Join("snapshots", toolName),
 			Metadata:     make(map[string]interface{}),
 		}
 	}
+	return env, nil
+}
+
+// saveNewEnvironment saves a created environment with its variables file
+func saveNewEnvironment(env *environment.Environment) error {
+	// Save metadata
+	if err := env.Save(); err != nil {
+		return fmt.Errorf("failed to save environment: %w", err)
+	}
+
+	// Create empty env-vars.env file (only if it doesn't exist, e.g., wasn't copied from --from)
+	envVarsPath := filepath.Join(env.Path, "env-vars.env")
+	if _, err := os.Stat(envVarsPath); os.IsNotExist(err) {
+		if err := os.WriteFile(envVarsPath, []byte("# Environment variables\n"), 0600); err != nil {
+			return fmt.Errorf("failed to create env-vars.env: %w", err)
+		}
+	}
+	return nil
+}
+
+func runCreate(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	if err := checkNewEnvironment(name); err != nil {
+		return err
+	}
+
+	envDir, err := environment.GetEnvironmentsDir()
+	if err != nil {
+		return err
+	}
+
+	// Resolve the template values before creating anything, a missing one
+	// must not leave a half-created environment behind
+	var tmpl *environment.Template
+	var templateVars map[string]string
+	if createTemplate != "" {
+		if createFrom != "" {
+			return fmt.Errorf("--template and --from cannot be used together")
+		}
+		if tmpl, err = environment.LoadTemplate(createTemplate); err != nil {
+			return err
+		}
+		if templateVars, err = variableValues(tmpl.EnvVars, createSet, os.Stdin); err != nil {
+			return err
+		}
+	} else if len(createSet) > 0 {
+		return fmt.Errorf("--set requires --template")
+	}
+
+	// Only enable the tools if creating from current
+	env, err := newEnvironment(name, createDescription, createFromCurrent && tmpl == nil)
+	if err != nil {
+		return err
+	}
+	envPath := env.Path
+
+	filter := toolFilter{}
+	if tmpl != nil {
+		tmpl.Apply(env)
+		// Only the tools of the template are captured
+		filter.Only = tmpl.Tools
+	}
 
 	// Handle --from flag (clone from existing environment)
 	if createFrom != "" {
-		if err := cloneEnvironment(envDir, createFrom, envPath, env); err != nil {
+		if err := cloneEnvironment(envDir, createFrom, envPath, env, cloneOptions{}); err != nil {
 			return err
 		}
-	} else if createFromCurrent {
-		if err := captureCurrentState(envPath, env); err != nil {
+	} else if createFromCurrent && (tmpl == nil || len(tmpl.Tools) > 0) {
+		if err := captureCurrentState(envPath, env, filter); err != nil {
 			return err
 		}
 	}
 
-	// Save metadata
-	if err := env.Save(); err != nil {
-		return fmt.Errorf("failed to save environment: %w", err)
+	if err := saveNewEnvironment(env); err != nil {
+		return err
 	}
 
-	// Create empty env-vars.env file (only if it doesn't exist, e.g., wasn't copied from --from)
-	envVarsPath := filepath.Join(envPath, "env-vars.env")
-	if _, err := os.Stat(envVarsPath); os.IsNotExist(err) {
-		if err := os.WriteFile(envVarsPath, []byte("# Environment variables\n"), 0644); err != nil {
-			return fmt.Errorf("failed to create env-vars.env: %w", err)
+	if tmpl != nil {
+		if err := setTemplateVars(env, tmpl.EnvVars, templateVars); err != nil {
+			return err
 		}
+		fmt.Printf("📋 Applied template '%s': %d tool(s), %d variable(s)\n", tmpl.Name, len(tmpl.Tools), len(tmpl.EnvVars))
 	}
 
 	fmt.Printf("✅ Environment '%s' created successfully\n", name)
@@ -223,3 +223,44 @@ func TestRunCreate(t *testing.T) {
 		assert.Contains(t, err.Error(), "does not exist")
 	})
 }
+
+func TestRunCreateNameValidation(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+
+	t.Run("rejects uppercase names", func(t *testing.T) {
+		err := runCreate(createCmd, []string{"Work"})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "use 'work'")
+	})
+
+	t.Run("rejects invalid characters", func(t *testing.T) {
+		err := runCreate(createCmd, []string{"my env"})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid character")
+	})
+
+	t.Run("rejects names colliding ignoring case", func(t *testing.T) {
+		envsDir := filepath.Join(tempHome, ".envswitch", "environments")
+		createEnvWithVars(t, envsDir, "Legacy", nil)
+
+		err := runCreate(createCmd, []string{"legacy"})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "already exists")
+	})
+
+	t.Run("rejects grouping under an environment", func(t *testing.T) {
+		err := runCreate(createCmd, []string{"legacy/dev"})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "'legacy' is an environment")
+	})
+
+	t.Run("rejects names used by a group", func(t *testing.T) {
+		envsDir := filepath.Join(tempHome, ".envswitch", "environments")
+		createEnvWithVars(t, envsDir, "client-a/prod", nil)
+
+		err := runCreate(createCmd, []string{"client-a"})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "is a group")
+	})
+}
@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/hugofrely/envswitch/internal/output"
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+var currentJSON bool
+
+var currentCmd = &cobra.Command{
+	Use:   "current",
+	Short: "Print the name of the active environment",
+	Long: `Print the name of the active environment, and nothing with exit status 1
+when no environment is active, for scripts and prompt frameworks.
+
+With --json (or --output json/yaml), the time of the last switch to the
+environment and whether its live config drifted from the snapshot are printed
+as well. The drift is read from the cache of 'envswitch prompt' when it is
+less than 30 seconds old, and computed otherwise.
+
+Examples:
+  envswitch current
+  envswitch current --json
+  [ "$(envswitch current)" = prod ] && echo "careful"`,
+	Args: cobra.NoArgs,
+	RunE: runCurrent,
+}
+
+func init() {
+	rootCmd.AddCommand(currentCmd)
+	currentCmd.Flags().BoolVar(&currentJSON, "json", false, "Output as JSON with the last switch time and drift")
+}
+
+// currentInfo is the active environment as printed by 'envswitch current'
+type currentInfo struct {
+	Environment    string     `json:"environment" yaml:"environment"`
+	LastSwitch     *time.Time `json:"last_switch,omitempty" yaml:"last_switch,omitempty"`
+	Dirty          bool       `json:"dirty" yaml:"dirty"`
+	DriftCheckedAt *time.Time `json:"drift_checked_at,omitempty" yaml:"drift_checked_at,omitempty"`
+}
+
+func runCurrent(cmd *cobra.Command, args []string) error {
+	out, err := currentWriter()
+	if err != nil {
+		return err
+	}
+
+	name, err := environment.GetCurrentEnvironmentName()
+	if err != nil {
+		return fmt.Errorf("failed to get current environment: %w", err)
+	}
+
+	if !out.Structured() {
+		if name == "" {
+			return noCurrentEnvironment(cmd)
+		}
+		fmt.Println(name)
+		return nil
+	}
+
+	info := currentInfo{Environment: name}
+	if name != "" {
+		env, err := environment.LoadEnvironment(name)
+		if err != nil {
+			return err
+		}
+		info = currentEnvironmentInfo(env, time.Now())
+	}
+	if err := out.Write(info); err != nil {
+		return err
+	}
+	if name == "" {
+		return noCurrentEnvironment(cmd)
+	}
+	return nil
+}
+
+// currentWriter returns the writer of 'envswitch current', --json is a
+// shortcut for --output json
+func currentWriter() (*output.Writer, error) {
+	if currentJSON {
+		return output.NewWriter(output.FormatJSON, os.Stdout), nil
+	}
+	return resultWriter()
+}
+
+// noCurrentEnvironment makes the command exit with status 1 without printing
+// an error
+func noCurrentEnvironment(cmd *cobra.Command) error {
+	cmd.SilenceErrors = true
+	cmd.SilenceUsage = true
+	return &exitError{code: 1}
+}
+
+// currentEnvironmentInfo returns the last switch to env and its drift, from
+// the prompt cache when it is fresh
+func currentEnvironmentInfo(env *environment.Environment, now time.Time) currentInfo {
+	info := currentInfo{Environment: env.Name}
+	if !env.LastUsed.IsZero() {
+		lastSwitch := env.LastUsed
+		info.LastSwitch = &lastSwitch
+	}
+
+	status, _ := environment.LoadPromptStatus()
+	if status.Fresh(env.Name, promptStatusMaxAge, now) {
+		checkedAt := status.CheckedAt
+		info.Dirty = status.Dirty
+		info.DriftCheckedAt = &checkedAt
+		return info
+	}
+
+	info.Dirty = computeStatus(env).Dirty
+	info.DriftCheckedAt = &now
+	recordPromptStatus(env.Name, info.Dirty)
+	return info
+}
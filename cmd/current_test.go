@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+func TestRunCurrent(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+
+	envsDir := filepath.Join(tempHome, ".envswitch", "environments")
+	require.NoError(t, os.MkdirAll(envsDir, 0755))
+
+	defer func() { currentJSON = false }()
+
+	t.Run("exits with status 1 without an active environment", func(t *testing.T) {
+		var err error
+		out := captureStdout(t, func() {
+			err = runCurrent(currentCmd, nil)
+		})
+		assert.Empty(t, out)
+		code, silent := ExitCode(err)
+		assert.Equal(t, 1, code)
+		assert.True(t, silent)
+	})
+
+	configPath := filepath.Join(tempHome, ".testrc")
+	require.NoError(t, os.WriteFile(configPath, []byte("snapshot"), 0644))
+	installTestPlugin(t, tempHome, "testtool", configPath)
+
+	env := createEnvWithVars(t, envsDir, "work", nil)
+	env.Tools["testtool"] = environment.ToolConfig{Enabled: true}
+	env.LastUsed = time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+	snapshotDir := filepath.Join(env.Path, "snapshots", "testtool")
+	require.NoError(t, os.MkdirAll(snapshotDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(snapshotDir, ".testrc"), []byte("snapshot"), 0644))
+	require.NoError(t, env.Save())
+	require.NoError(t, environment.SetCurrentEnvironment("work"))
+
+	t.Run("prints the name", func(t *testing.T) {
+		out := captureStdout(t, func() {
+			require.NoError(t, runCurrent(currentCmd, nil))
+		})
+		assert.Equal(t, "work\n", out)
+	})
+
+	t.Run("computes the drift when nothing is cached", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(configPath, []byte("live"), 0644))
+
+		currentJSON = true
+		out := captureStdout(t, func() {
+			require.NoError(t, runCurrent(currentCmd, nil))
+		})
+		currentJSON = false
+
+		var info currentInfo
+		require.NoError(t, json.Unmarshal([]byte(out), &info))
+		assert.Equal(t, "work", info.Environment)
+		require.NotNil(t, info.LastSwitch)
+		assert.True(t, info.LastSwitch.Equal(env.LastUsed))
+		assert.True(t, info.Dirty)
+		assert.NotNil(t, info.DriftCheckedAt)
+
+		status, err := environment.LoadPromptStatus()
+		require.NoError(t, err)
+		require.NotNil(t, status)
+		assert.True(t, status.Dirty, "the drift is cached for the prompt")
+	})
+
+	t.Run("reads a fresh cached drift", func(t *testing.T) {
+		checkedAt := time.Now().Add(-time.Second).Truncate(time.Second)
+		require.NoError(t, environment.SavePromptStatus(environment.PromptStatus{
+			Environment: "work",
+			Dirty:       false,
+			CheckedAt:   checkedAt,
+		}))
+
+		currentJSON = true
+		out := captureStdout(t, func() {
+			require.NoError(t, runCurrent(currentCmd, nil))
+		})
+		currentJSON = false
+
+		var info currentInfo
+		require.NoError(t, json.Unmarshal([]byte(out), &info))
+		assert.False(t, info.Dirty)
+		require.NotNil(t, info.DriftCheckedAt)
+		assert.True(t, info.DriftCheckedAt.Equal(checkedAt))
+	})
+}
@@ -0,0 +1,304 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/cobra"
+
+	"github.com/hugofrely/envswitch/internal/config"
+	"github.com/hugofrely/envswitch/internal/logger"
+	"github.com/hugofrely/envswitch/internal/storage"
+	"github.com/hugofrely/envswitch/pkg/environment"
+	"github.com/hugofrely/envswitch/pkg/tools"
+)
+
+var daemonDebounce time.Duration
+
+var daemonLog = logger.Named("daemon")
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Refresh the snapshots of the active environment when tool configs change",
+	Long: `Watch the config files of the tools of the active environment, and save
+them into its snapshots once they stayed unchanged for the debounce time, so
+the environment is never stale when you switch away from it.
+
+The daemon follows switches to the environment that becomes active, and
+ignores the files restored by the switch itself. It runs until interrupted,
+start it from your login items or a user service.
+
+Auto-snapshots are opt-in:
+  envswitch config set auto_snapshot true
+  envswitch config set auto_snapshot_debounce 30s   # default: 10s
+
+Examples:
+  envswitch daemon
+  envswitch daemon --debounce 1m`,
+	Args: cobra.NoArgs,
+	RunE: runDaemon,
+}
+
+func init() {
+	rootCmd.AddCommand(daemonCmd)
+	daemonCmd.Flags().DurationVar(&daemonDebounce, "debounce", 0, "Time configs must stay unchanged before saving them (default: auto_snapshot_debounce)")
+}
+
+func runDaemon(cmd *cobra.Command, args []string) error {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if !cfg.AutoSnapshot {
+		return fmt.Errorf("auto-snapshot is disabled, enable it with 'envswitch config set auto_snapshot true'")
+	}
+
+	debounce := daemonDebounce
+	if debounce == 0 {
+		if debounce, err = cfg.AutoSnapshotDelay(); err != nil {
+			return fmt.Errorf("invalid auto_snapshot_debounce: %w", err)
+		}
+	}
+	if debounce < 0 {
+		return fmt.Errorf("--debounce must be positive")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	d, err := newSnapshotDaemon(debounce)
+	if err != nil {
+		return err
+	}
+	defer d.close()
+
+	fmt.Printf("👀 Watching tool configs, snapshots are refreshed %s after changes settle (Ctrl+C to stop)\n", debounce)
+	return d.run(ctx)
+}
+
+// snapshotDaemon saves the tools of the active environment whose live
+// config changed into its snapshots
+type snapshotDaemon struct {
+	watcher  *fsnotify.Watcher
+	debounce time.Duration
+	lockPath string
+
+	// env is the active environment, empty when there is none
+	env string
+	// paths maps the live paths of the watched tools to the tool names
+	paths map[string]string
+	// pending are the tools changed since their last snapshot
+	pending map[string]bool
+}
+
+func newSnapshotDaemon(debounce time.Duration) (*snapshotDaemon, error) {
+	dir, err := environment.GetEnvswitchDir()
+	if err != nil {
+		return nil, err
+	}
+	if err := storage.MkdirPrivate(dir); err != nil {
+		return nil, fmt.Errorf("failed to create envswitch directory: %w", err)
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to watch files: %w", err)
+	}
+
+	d := &snapshotDaemon{
+		watcher:  watcher,
+		debounce: debounce,
+		lockPath: filepath.Join(dir, "current.lock"),
+	}
+	d.rewatch()
+	return d, nil
+}
+
+func (d *snapshotDaemon) close() {
+	_ = d.watcher.Close()
+}
+
+// run handles file events until ctx is done, saving the changed tools when
+// no event came for the debounce time
+func (d *snapshotDaemon) run(ctx context.Context) error {
+	timer := time.NewTimer(d.debounce)
+	timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-d.watcher.Events:
+			if !ok {
+				return nil
+			}
+			if d.handle(event) {
+				timer.Reset(d.debounce)
+			}
+		case err, ok := <-d.watcher.Errors:
+			if !ok {
+				return nil
+			}
+			daemonLog.Warn("File watcher error: %v", err)
+		case <-timer.C:
+			d.flush()
+		}
+	}
+}
+
+// rewatch watches the envswitch directory, for switches, and the live paths
+// of the enabled tools of the active environment
+func (d *snapshotDaemon) rewatch() {
+	for _, path := range d.watcher.WatchList() {
+		_ = d.watcher.Remove(path)
+	}
+	d.env = ""
+	d.paths = map[string]string{}
+	d.pending = map[string]bool{}
+
+	if err := d.watcher.Add(filepath.Dir(d.lockPath)); err != nil {
+		daemonLog.Warn("Failed to watch %s: %v", filepath.Dir(d.lockPath), err)
+	}
+
+	env, err := environment.GetCurrentEnvironment()
+	if err != nil || env == nil {
+		return
+	}
+	d.env = env.Name
+
+	// Tools left out of the last switch hold another environment's config
+	registry := liveToolFilter(env).apply(getToolRegistry())
+	for _, toolName := range enabledRegistryTools(env, registry) {
+		provider, ok := registry[toolName].(tools.PathProvider)
+		if !ok {
+			continue
+		}
+		for _, path := range provider.ConfigPaths() {
+			d.paths[filepath.Clean(path)] = toolName
+			d.watchPath(path)
+		}
+	}
+	daemonLog.Debug("Watching %d path(s) of '%s'", len(d.paths), d.env)
+}
+
+// watchPath watches a directory with its subdirectories, or the directory of
+// a file since editors replace files rather than writing them
+func (d *snapshotDaemon) watchPath(path string) {
+	info, err := os.Stat(path)
+	if err != nil || !info.IsDir() {
+		// Files created later are seen from their directory
+		if err := d.watcher.Add(filepath.Dir(path)); err != nil {
+			daemonLog.Debug("Failed to watch %s: %v", filepath.Dir(path), err)
+		}
+		return
+	}
+
+	_ = filepath.WalkDir(path, func(dir string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.IsDir() {
+			return nil
+		}
+		if err := d.watcher.Add(dir); err != nil {
+			daemonLog.Debug("Failed to watch %s: %v", dir, err)
+		}
+		return nil
+	})
+}
+
+// handle records the tool changed by event, and reports whether the
+// debounce starts over
+func (d *snapshotDaemon) handle(event fsnotify.Event) bool {
+	if event.Name == d.lockPath {
+		// The live config now belongs to another environment
+		d.rewatch()
+		return false
+	}
+
+	// Permission and access time changes do not change snapshots
+	if event.Op == fsnotify.Chmod {
+		return false
+	}
+
+	toolName := d.toolFor(event.Name)
+	if toolName == "" {
+		return false
+	}
+	if event.Has(fsnotify.Create) {
+		if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+			d.watchPath(event.Name)
+		}
+	}
+	d.pending[toolName] = true
+	return true
+}
+
+// toolFor returns the tool whose live path holds path, empty when none does
+func (d *snapshotDaemon) toolFor(path string) string {
+	path = filepath.Clean(path)
+	best, toolName := "", ""
+	for livePath, name := range d.paths {
+		if path != livePath && !strings.HasPrefix(path, livePath+string(filepath.Separator)) {
+			continue
+		}
+		if len(livePath) > len(best) {
+			best, toolName = livePath, name
+		}
+	}
+	return toolName
+}
+
+// flush saves the changed tools into the active environment
+func (d *snapshotDaemon) flush() {
+	if len(d.pending) == 0 {
+		return
+	}
+	toolNames := make([]string, 0, len(d.pending))
+	for toolName := range d.pending {
+		toolNames = append(toolNames, toolName)
+	}
+	sort.Strings(toolNames)
+	d.pending = map[string]bool{}
+
+	// The files a switch restores are its target's snapshots already
+	if environment.SwitchInProgress() {
+		daemonLog.Debug("Switch in progress, not saving %s", strings.Join(toolNames, ", "))
+		return
+	}
+
+	env, err := environment.GetCurrentEnvironment()
+	if err != nil || env == nil || env.Name != d.env {
+		d.rewatch()
+		return
+	}
+	// Wait for a save of the same environment, and start from what it saved
+	env, unlock, err := environment.LockEnvironment(env.Name)
+	if err != nil {
+		daemonLog.Warn("Failed to save %s into '%s': %v", strings.Join(toolNames, ", "), d.env, err)
+		return
+	}
+	defer unlock()
+
+	registry := getToolRegistry()
+	changed := make(map[string]tools.Tool, len(toolNames))
+	for _, toolName := range toolNames {
+		if tool, ok := registry[toolName]; ok {
+			changed[toolName] = tool
+		}
+	}
+
+	if err := snapshotCurrentEnvironment(context.Background(), env, changed, nil); err != nil {
+		daemonLog.Warn("Failed to save %s into '%s': %v", strings.Join(toolNames, ", "), env.Name, err)
+		fmt.Printf("%s ✗ Failed to save %s into '%s': %v\n", time.Now().Format("15:04:05"), strings.Join(toolNames, ", "), env.Name, err)
+		return
+	}
+	recordPromptStatus(env.Name, false)
+	fmt.Printf("%s ✓ Saved %s into '%s'\n", time.Now().Format("15:04:05"), strings.Join(toolNames, ", "), env.Name)
+}
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hugofrely/envswitch/internal/config"
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+func TestRunDaemonDisabled(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	err := runDaemon(daemonCmd, nil)
+	assert.ErrorContains(t, err, "envswitch config set auto_snapshot true")
+
+	cfg := config.DefaultConfig()
+	cfg.AutoSnapshot = true
+	cfg.AutoSnapshotDebounce = "never"
+	require.NoError(t, cfg.Save())
+	err = runDaemon(daemonCmd, nil)
+	assert.ErrorContains(t, err, "invalid auto_snapshot_debounce")
+}
+
+func TestSnapshotDaemonToolFor(t *testing.T) {
+	d := &snapshotDaemon{paths: map[string]string{
+		filepath.Join("home", ".kube"):                "kubectl",
+		filepath.Join("home", ".kube", "cache"):       "cache",
+		filepath.Join("home", ".gitconfig"):           "git",
+		filepath.Join("home", ".config", "gcloud"):    "gcloud",
+		filepath.Join("home", ".config", "gcloud-ex"): "other",
+	}}
+
+	assert.Equal(t, "kubectl", d.toolFor(filepath.Join("home", ".kube", "config")))
+	assert.Equal(t, "cache", d.toolFor(filepath.Join("home", ".kube", "cache", "x")), "the longest path wins")
+	assert.Equal(t, "git", d.toolFor(filepath.Join("home", ".gitconfig")))
+	assert.Equal(t, "gcloud", d.toolFor(filepath.Join("home", ".config", "gcloud", "active_config")))
+	assert.Empty(t, d.toolFor(filepath.Join("home", ".gitconfig.lock")))
+	assert.Empty(t, d.toolFor(filepath.Join("home", ".bashrc")))
+}
+
+func TestSnapshotDaemon(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+	envsDir := filepath.Join(tempHome, ".envswitch", "environments")
+
+	configPath := filepath.Join(tempHome, ".testrc")
+	require.NoError(t, os.WriteFile(configPath, []byte("snapshot"), 0644))
+	installTestPlugin(t, tempHome, "testtool", configPath)
+
+	env := createEnvWithVars(t, envsDir, "work", nil)
+	env.Tools["testtool"] = environment.ToolConfig{Enabled: true}
+	snapshotPath := filepath.Join(env.Path, "snapshots", "testtool", ".testrc")
+	require.NoError(t, os.MkdirAll(filepath.Dir(snapshotPath), 0755))
+	require.NoError(t, os.WriteFile(snapshotPath, []byte("snapshot"), 0644))
+	require.NoError(t, env.Save())
+	require.NoError(t, environment.SetCurrentEnvironment("work"))
+
+	readSnapshot := func() string {
+		data, err := os.ReadFile(snapshotPath)
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	out := captureStdout(t, func() {
+		d, err := newSnapshotDaemon(50 * time.Millisecond)
+		require.NoError(t, err)
+		defer d.close()
+		info, err := os.Stat(filepath.Join(tempHome, ".envswitch"))
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0700), info.Mode().Perm())
+		assert.Equal(t, "work", d.env)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() { done <- d.run(ctx) }()
+		defer func() {
+			cancel()
+			require.NoError(t, <-done)
+		}()
+
+		// Files restored by a switch are not saved
+		endSwitch, err := environment.BeginSwitch()
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(configPath, []byte("restoring"), 0644))
+		time.Sleep(300 * time.Millisecond)
+		assert.Equal(t, "snapshot", readSnapshot())
+		endSwitch()
+
+		require.NoError(t, os.WriteFile(configPath, []byte("changed"), 0644))
+		assert.Eventually(t, func() bool { return readSnapshot() == "changed" }, 5*time.Second, 20*time.Millisecond)
+	})
+	assert.Contains(t, out, "✓ Saved testtool into 'work'")
+
+	saved, err := environment.LoadEnvironment("work")
+	require.NoError(t, err)
+	assert.False(t, saved.Tools["testtool"].LastSnapshot.IsZero())
+}
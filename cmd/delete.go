@@ -3,35 +3,71 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
 
 	"github.com/hugofrely/envswitch/internal/archive"
+	"github.com/hugofrely/envswitch/internal/config"
 	"github.com/hugofrely/envswitch/pkg/environment"
 )
 
 var (
 	deleteForce     bool
+	deleteYes       bool
 	deleteNoArchive bool
+	deleteTags      []string
 )
 
 var deleteCmd = &cobra.Command{
-	Use:               "delete <name>",
-	Aliases:           []string{"rm"},
-	Short:             "Delete an environment",
-	Long:              `Delete an environment and all its snapshots.`,
-	Args:              cobra.ExactArgs(1),
+	Use:     "delete <name> | --tag <tag>",
+	Aliases: []string{"rm"},
+	Short:   "Delete an environment",
+	Long: `Delete an environment and all its snapshots.
+
+The environment is archived to ~/.envswitch/archives before it is removed, and
+the deletion is aborted if the archive cannot be written. The active
+environment can only be deleted with --force, and a protected one (see
+'envswitch protect') only after typing its name or with --yes.
+
+With --tag, every environment with the tags is deleted, after listing them and
+asking for confirmation once.
+
+Examples:
+  envswitch delete old-client
+  envswitch delete old-client --yes --no-archive
+  envswitch delete work --force
+  envswitch delete --tag client=acme`,
+	Args:              validateDeleteArgs,
 	ValidArgsFunction: completeEnvironmentNames,
 	RunE:              runDelete,
 }
 
 func init() {
 	rootCmd.AddCommand(deleteCmd)
-	deleteCmd.Flags().BoolVarP(&deleteForce, "force", "f", false, "Skip confirmation")
+	deleteCmd.Flags().BoolVarP(&deleteForce, "force", "f", false, "Allow deleting the active environment (implies --yes)")
+	deleteCmd.Flags().BoolVarP(&deleteYes, "yes", "y", false, "Skip confirmation")
 	deleteCmd.Flags().BoolVar(&deleteNoArchive, "no-archive", false, "Skip archiving before deletion")
+	deleteCmd.Flags().StringArrayVar(&deleteTags, "tag", nil, "Delete the environments with this tag, or tag key (repeatable)")
+	_ = deleteCmd.RegisterFlagCompletionFunc("tag", completeTags)
+}
+
+// validateDeleteArgs requires an environment name, or none with --tag
+func validateDeleteArgs(cmd *cobra.Command, args []string) error {
+	if len(deleteTags) > 0 {
+		if len(args) > 0 {
+			return fmt.Errorf("cannot specify an environment name with --tag")
+		}
+		return nil
+	}
+	return cobra.ExactArgs(1)(cmd, args)
 }
 
 func runDelete(cmd *cobra.Command, args []string) error {
+	if len(deleteTags) > 0 {
+		return deleteTagged()
+	}
+
 	name := args[0]
 
 	// Check if environment exists
@@ -42,36 +78,110 @@ func runDelete(cmd *cobra.Command, args []string) error {
 
 	// Check if it's the current environment
 	current, _ := environment.GetCurrentEnvironment()
-	if current != nil && current.Name == name {
-		return fmt.Errorf("cannot delete active environment '%s'", name)
+	isActive := current != nil && current.Name == name
+	if isActive && !deleteForce {
+		return fmt.Errorf("cannot delete active environment '%s' (use --force to delete it anyway)", name)
 	}
 
 	// Confirm deletion
-	if !deleteForce {
-		fmt.Printf("⚠️  Are you sure you want to delete '%s'? [y/N]: ", name)
-		var response string
-		if _, err := fmt.Scanln(&response); err != nil {
-			// If there's an error reading input, treat as "no"
-			fmt.Println("Canceled.")
-			return nil
+	if !deleteYes && !deleteForce {
+		if isActive {
+			fmt.Printf("⚠️  '%s' is the active environment.\n", name)
 		}
-		if response != "y" && response != "Y" {
+		var confirmed bool
+		if env.Protected {
+			confirmed = confirmProtected(env, "delete it")
+		} else {
+			confirmed = confirmDeletion(fmt.Sprintf("'%s'", name))
+		}
+		if !confirmed {
 			fmt.Println("Canceled.")
 			return nil
 		}
 	}
 
+	return deleteEnvironment(env, isActive)
+}
+
+// deleteTagged deletes the environments matching every --tag filter, after a
+// single confirmation
+func deleteTagged() error {
+	envs, err := tagFilteredEnvironments(deleteTags)
+	if err != nil {
+		return err
+	}
+
+	current, _ := environment.GetCurrentEnvironment()
+	activeName := ""
+	if current != nil {
+		activeName = current.Name
+	}
+
+	fmt.Printf("Environments tagged %s:\n", strings.Join(deleteTags, " and "))
+	for _, env := range envs {
+		if env.Name == activeName {
+			if !deleteForce {
+				return fmt.Errorf("cannot delete active environment '%s' (use --force to delete it anyway)", env.Name)
+			}
+			fmt.Printf("  %s (active)\n", env.Name)
+			continue
+		}
+		fmt.Printf("  %s\n", env.Name)
+	}
+
+	if !deleteYes && !deleteForce && !confirmDeletion(fmt.Sprintf("these %d environment(s)", len(envs))) {
+		fmt.Println("Canceled.")
+		return nil
+	}
+
+	var failed int
+	for _, env := range envs {
+		fmt.Println()
+		if env.Protected && !deleteYes && !deleteForce && !confirmProtected(env, "delete it") {
+			fmt.Printf("Skipped '%s'.\n", env.Name)
+			continue
+		}
+		if err := deleteEnvironment(env, env.Name == activeName); err != nil {
+			fmt.Printf("✗ %s: %v\n", env.Name, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to delete %d of %d environment(s)", failed, len(envs))
+	}
+	return nil
+}
+
+// confirmDeletion asks whether to delete what, false when the answer cannot
+// be read
+func confirmDeletion(what string) bool {
+	fmt.Printf("⚠️  Are you sure you want to delete %s? [y/N]: ", what)
+	var response string
+	if _, err := fmt.Scanln(&response); err != nil {
+		// If there's an error reading input, treat as "no"
+		return false
+	}
+	return response == "y" || response == "Y"
+}
+
+// deleteEnvironment archives env unless --no-archive is given, and removes it
+func deleteEnvironment(env *environment.Environment, isActive bool) error {
+	name := env.Name
+
 	// Archive before deletion (unless --no-archive is specified)
 	var archivePath string
 	if !deleteNoArchive {
 		fmt.Println("📦 Archiving environment before deletion...")
 		arch, err := archive.ArchiveEnvironment(env)
 		if err != nil {
-			fmt.Printf("⚠️  Warning: Failed to archive environment: %v\n", err)
-			fmt.Println("   Proceeding with deletion...")
-		} else {
-			archivePath = arch.Path
-			fmt.Printf("✓ Archived to: %s\n", archivePath)
+			return fmt.Errorf("failed to archive environment, nothing was deleted (use --no-archive to skip archiving): %w", err)
+		}
+		archivePath = arch.Path
+		fmt.Printf("✓ Archived to: %s\n", archivePath)
+
+		if cfg, cfgErr := config.LoadConfig(); cfgErr == nil {
+			mirrorBackup(archivePath, cfg)
+			defer waitForMirrors()
 		}
 	}
 
@@ -79,11 +189,27 @@ func runDelete(cmd *cobra.Command, args []string) error {
 	if err := os.RemoveAll(env.Path); err != nil {
 		return fmt.Errorf("failed to delete environment: %w", err)
 	}
+	environment.RemoveEmptyGroups(env.Path)
+	if historyDir, err := env.SnapshotHistoryDir(); err == nil {
+		_ = os.RemoveAll(historyDir)
+	}
+	if historyDir, err := env.ShellHistoryDir(); err == nil {
+		_ = os.RemoveAll(historyDir)
+	}
+
+	if isActive {
+		if err := environment.ClearCurrentEnvironment(); err != nil {
+			return fmt.Errorf("environment deleted but failed to clear active environment: %w", err)
+		}
+	}
 
 	fmt.Printf("✅ Environment '%s' deleted successfully\n", name)
 	if archivePath != "" {
 		fmt.Printf("   Archive saved at: %s\n", archivePath)
 	}
+	if isActive {
+		fmt.Println("   No environment is active now; your tool configurations were left as they are")
+	}
 
 	return nil
 }
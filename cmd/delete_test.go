@@ -33,7 +33,7 @@ func TestRunDelete(t *testing.T) {
 	err := os.MkdirAll(envDir, 0755)
 	require.NoError(t, err)
 
-	t.Run("deletes environment with force flag", func(t *testing.T) {
+	t.Run("deletes environment with yes flag", func(t *testing.T) {
 		// Create test environment
 		env := &environment.Environment{
 			Name: "to-delete",
@@ -45,8 +45,8 @@ func TestRunDelete(t *testing.T) {
 		require.NoError(t, err)
 
 		// Delete with force flag
-		deleteForce = true
-		defer func() { deleteForce = false }()
+		deleteYes = true
+		defer func() { deleteYes = false }()
 
 		err = runDelete(deleteCmd, []string{"to-delete"})
 		require.NoError(t, err)
@@ -56,6 +56,21 @@ func TestRunDelete(t *testing.T) {
 		assert.True(t, os.IsNotExist(err))
 	})
 
+	t.Run("removes the group left empty", func(t *testing.T) {
+		createEnvWithVars(t, envDir, "client-a/prod", nil)
+		createEnvWithVars(t, envDir, "client-b/prod", nil)
+		createEnvWithVars(t, envDir, "client-b/dev", nil)
+
+		deleteYes = true
+		defer func() { deleteYes = false }()
+
+		require.NoError(t, runDelete(deleteCmd, []string{"client-a/prod"}))
+		require.NoError(t, runDelete(deleteCmd, []string{"client-b/prod"}))
+
+		assert.NoDirExists(t, filepath.Join(envDir, "client-a"))
+		assert.DirExists(t, filepath.Join(envDir, "client-b", "dev"))
+	})
+
 	t.Run("returns error for non-existent environment", func(t *testing.T) {
 		deleteForce = true
 		defer func() { deleteForce = false }()
@@ -80,8 +95,8 @@ func TestRunDelete(t *testing.T) {
 		require.NoError(t, err)
 
 		// Try to delete
-		deleteForce = true
-		defer func() { deleteForce = false }()
+		deleteYes = true
+		defer func() { deleteYes = false }()
 
 		err = runDelete(deleteCmd, []string{"current-env"})
 		assert.Error(t, err)
@@ -92,6 +107,55 @@ func TestRunDelete(t *testing.T) {
 		assert.NoError(t, err)
 	})
 
+	t.Run("deletes active environment with force flag", func(t *testing.T) {
+		env := &environment.Environment{
+			Name: "forced-env",
+			Path: filepath.Join(envDir, "forced-env"),
+		}
+		require.NoError(t, os.MkdirAll(env.Path, 0755))
+		require.NoError(t, env.Save())
+		require.NoError(t, environment.SetCurrentEnvironment("forced-env"))
+
+		deleteForce = true
+		defer func() { deleteForce = false }()
+
+		require.NoError(t, runDelete(deleteCmd, []string{"forced-env"}))
+
+		_, err := os.Stat(env.Path)
+		assert.True(t, os.IsNotExist(err))
+
+		current, err := environment.GetCurrentEnvironment()
+		require.NoError(t, err)
+		assert.Nil(t, current)
+		_, err = os.Stat(filepath.Join(envswitchDir, "current.lock"))
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("keeps environment when archiving fails", func(t *testing.T) {
+		env := &environment.Environment{
+			Name: "archive-fails",
+			Path: filepath.Join(envDir, "archive-fails"),
+		}
+		require.NoError(t, os.MkdirAll(env.Path, 0755))
+		require.NoError(t, env.Save())
+
+		// A file where the archives directory should be makes archiving fail
+		archiveDir := filepath.Join(envswitchDir, "archives")
+		require.NoError(t, os.RemoveAll(archiveDir))
+		require.NoError(t, os.WriteFile(archiveDir, []byte("not a directory"), 0644))
+		defer os.Remove(archiveDir)
+
+		deleteYes = true
+		defer func() { deleteYes = false }()
+
+		err := runDelete(deleteCmd, []string{"archive-fails"})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "nothing was deleted")
+
+		_, err = os.Stat(env.Path)
+		assert.NoError(t, err)
+	})
+
 	t.Run("deletes environment directory and all contents", func(t *testing.T) {
 		// Create environment with some files
 		env := &environment.Environment{
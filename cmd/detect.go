@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+
+	"github.com/hugofrely/envswitch/internal/storage"
+	"github.com/hugofrely/envswitch/pkg/environment"
+	"github.com/hugofrely/envswitch/pkg/tools"
+)
+
+var (
+	detectEnv string
+	detectAll bool
+)
+
+// detectInput is where the answers to detect are read
+var detectInput io.Reader = os.Stdin
+
+var detectCmd = &cobra.Command{
+	Use:   "detect",
+	Short: "Find installed tools the environment does not capture",
+	Long: `Look for the supported tools, plugins included, that are installed on this
+machine but not enabled in the environment (the active one unless --env is
+given). For each, the configuration paths it would capture are shown and you
+are asked whether to enable it. --all enables them all without asking.
+
+Examples:
+  envswitch detect
+  envswitch detect --env work --all`,
+	Args: cobra.NoArgs,
+	RunE: runDetect,
+}
+
+func init() {
+	rootCmd.AddCommand(detectCmd)
+	detectCmd.Flags().StringVar(&detectEnv, "env", "", "Environment to use (default: active environment)")
+	detectCmd.Flags().BoolVar(&detectAll, "all", false, "Enable every detected tool without asking")
+	_ = detectCmd.RegisterFlagCompletionFunc("env", completeEnvironmentFlag)
+}
+
+// detectedTool is an installed tool the environment does not capture
+type detectedTool struct {
+	Name  string
+	Paths []string // existing configuration paths the tool would capture
+}
+
+// detectTools returns the installed tools of the registry that are not
+// enabled in env, sorted by name
+func detectTools(env *environment.Environment, registry map[string]tools.Tool) []detectedTool {
+	var detected []detectedTool
+	for name, tool := range registry {
+		if env.Tools[name].Enabled || !tool.IsInstalled() {
+			continue
+		}
+		found := detectedTool{Name: name}
+		if provider, ok := tool.(tools.PathProvider); ok {
+			for _, path := range provider.ConfigPaths() {
+				if _, err := os.Stat(path); err == nil {
+					found.Paths = append(found.Paths, path)
+				}
+			}
+		}
+		detected = append(detected, found)
+	}
+	sort.Slice(detected, func(i, j int) bool { return detected[i].Name < detected[j].Name })
+	return detected
+}
+
+func runDetect(cmd *cobra.Command, args []string) error {
+	env, err := resolveEnvTarget(detectEnv)
+	if err != nil {
+		return err
+	}
+	return offerDetectedTools(env, getToolRegistry())
+}
+
+// offerDetectedTools shows the installed tools of the registry env does not
+// capture, and enables those accepted, or all of them with --all
+func offerDetectedTools(env *environment.Environment, registry map[string]tools.Tool) error {
+	detected := detectTools(env, registry)
+	if len(detected) == 0 {
+		fmt.Printf("✅ '%s' captures every installed tool\n", env.Name)
+		return nil
+	}
+
+	fmt.Printf("Installed tools '%s' does not capture:\n\n", env.Name)
+	input := bufio.NewReader(detectInput)
+	var enabled []string
+	for _, tool := range detected {
+		fmt.Printf("  %s\n", tool.Name)
+		if len(tool.Paths) == 0 {
+			fmt.Println("    (no configuration yet)")
+		}
+		for _, path := range tool.Paths {
+			fmt.Printf("    %s (%s)\n", path, humanize.Bytes(storage.PathSize(path)))
+		}
+
+		if !detectAll {
+			fmt.Printf("  Enable %s? [y/N]: ", tool.Name)
+			response, _ := input.ReadString('\n')
+			response = strings.ToLower(strings.TrimSpace(response))
+			if response != "y" && response != "yes" {
+				fmt.Println()
+				continue
+			}
+		}
+		enableEnvironmentTool(env, tool.Name)
+		enabled = append(enabled, tool.Name)
+		fmt.Println()
+	}
+
+	if len(enabled) == 0 {
+		fmt.Println("No tool enabled.")
+		return nil
+	}
+	if err := env.Save(); err != nil {
+		return fmt.Errorf("failed to save environment: %w", err)
+	}
+
+	fmt.Printf("✅ Enabled %s in '%s'\n", strings.Join(enabled, ", "), env.Name)
+	fmt.Printf("   Their configuration is captured the next time you save '%s' or switch away from it\n", env.Name)
+	return nil
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hugofrely/envswitch/pkg/environment"
+	"github.com/hugofrely/envswitch/pkg/tools"
+)
+
+func TestDetectTools(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+
+	// Generic tools are installed when their command is in PATH
+	binDir := t.TempDir()
+	for _, name := range []string{"alpha", "beta", "gamma"} {
+		require.NoError(t, os.WriteFile(filepath.Join(binDir, name), []byte("#!/bin/sh\n"), 0755))
+	}
+	t.Setenv("PATH", binDir)
+
+	alphaConfig := filepath.Join(tempHome, ".alpharc")
+	require.NoError(t, os.WriteFile(alphaConfig, []byte("alpha"), 0600))
+	registry := map[string]tools.Tool{
+		"alpha":   tools.NewGenericTool("alpha", alphaConfig),
+		"beta":    tools.NewGenericTool("beta", filepath.Join(tempHome, ".betarc")),
+		"gamma":   tools.NewGenericTool("gamma", filepath.Join(tempHome, ".gammarc")),
+		"missing": tools.NewGenericTool("missing", filepath.Join(tempHome, ".missingrc")),
+	}
+
+	envsDir := filepath.Join(tempHome, ".envswitch", "environments")
+	require.NoError(t, os.MkdirAll(envsDir, 0755))
+	env := createEnvWithVars(t, envsDir, "work", nil)
+	env.Tools["gamma"] = environment.ToolConfig{Enabled: true}
+	require.NoError(t, env.Save())
+
+	t.Run("finds installed tools not enabled", func(t *testing.T) {
+		detected := detectTools(env, registry)
+		require.Len(t, detected, 2)
+		assert.Equal(t, detectedTool{Name: "alpha", Paths: []string{alphaConfig}}, detected[0])
+		assert.Equal(t, detectedTool{Name: "beta"}, detected[1])
+	})
+
+	t.Run("enables the tools accepted", func(t *testing.T) {
+		detectInput = strings.NewReader("y\nn\n")
+		defer func() { detectInput = os.Stdin }()
+
+		out := captureStdout(t, func() {
+			require.NoError(t, offerDetectedTools(env, registry))
+		})
+		assert.Contains(t, out, alphaConfig)
+		assert.Contains(t, out, "(no configuration yet)")
+		assert.Contains(t, out, "✅ Enabled alpha in 'work'")
+
+		saved, err := environment.LoadEnvironment("work")
+		require.NoError(t, err)
+		assert.True(t, saved.Tools["alpha"].Enabled)
+		assert.False(t, saved.Tools["beta"].Enabled)
+	})
+
+	t.Run("enables every tool with --all", func(t *testing.T) {
+		detectAll = true
+		defer func() { detectAll = false }()
+
+		out := captureStdout(t, func() {
+			require.NoError(t, offerDetectedTools(env, registry))
+		})
+		assert.Contains(t, out, "✅ Enabled beta in 'work'")
+		assert.Empty(t, detectTools(env, registry))
+
+		out = captureStdout(t, func() {
+			require.NoError(t, offerDetectedTools(env, registry))
+		})
+		assert.Contains(t, out, "captures every installed tool")
+	})
+}
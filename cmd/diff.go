@@ -0,0 +1,213 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/spf13/cobra"
+
+	"github.com/hugofrely/envswitch/internal/redact"
+	"github.com/hugofrely/envswitch/pkg/environment"
+	"github.com/hugofrely/envswitch/pkg/tools"
+)
+
+var (
+	diffTool string
+	diffJSON bool
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff [name]",
+	Short: "Show changes between the live system and an environment's snapshots",
+	Long: `Compare the current live state of each tool against the snapshots stored
+in an environment (the active environment by default).
+
+Changes are reported from the snapshot's point of view:
+  + added     present on the system but not in the snapshot
+  - removed   present in the snapshot but missing on the system
+  ~ modified  different between the snapshot and the system
+
+Examples:
+  # Pending changes for the active environment
+  envswitch diff
+
+  # Compare against another environment
+  envswitch diff work
+
+  # Only one tool, as JSON
+  envswitch diff work --tool git --json`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeEnvironmentNames,
+	RunE:              runDiff,
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+	diffCmd.Flags().StringVar(&diffTool, "tool", "", "Only diff the given tool")
+	diffCmd.Flags().BoolVar(&diffJSON, "json", false, "Output as JSON")
+}
+
+// ToolDiff holds the diff result for a single tool
+type ToolDiff struct {
+	Tool    string         `json:"tool"`
+	Changes []tools.Change `json:"changes"`
+	Error   string         `json:"error,omitempty"`
+}
+
+func runDiff(cmd *cobra.Command, args []string) error {
+	env, err := resolveDiffEnvironment(args)
+	if err != nil {
+		return err
+	}
+
+	if diffTool != "" {
+		if toolConfig, exists := env.Tools[diffTool]; !exists || !toolConfig.Enabled {
+			return fmt.Errorf("tool '%s' is not enabled in environment '%s'", diffTool, env.Name)
+		}
+	}
+
+	diffs := diffEnvironment(env, diffTool)
+
+	if diffJSON {
+		data, err := json.MarshalIndent(diffs, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to format diff: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	printDiffs(env.Name, diffs)
+	return nil
+}
+
+// resolveDiffEnvironment returns the named environment or the active one
+func resolveDiffEnvironment(args []string) (*environment.Environment, error) {
+	if len(args) == 1 {
+		env, err := environment.LoadEnvironment(args[0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to load environment '%s': %w", args[0], err)
+		}
+		return env, nil
+	}
+
+	env, err := environment.GetCurrentEnvironment()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current environment: %w", err)
+	}
+	if env == nil {
+		return nil, fmt.Errorf("no active environment. Specify an environment name")
+	}
+	return env, nil
+}
+
+// diffEnvironment computes the diff of every enabled tool (or only onlyTool)
+func diffEnvironment(env *environment.Environment, onlyTool string) []ToolDiff {
+	toolRegistry := getToolRegistry()
+
+	toolNames := make([]string, 0, len(env.Tools))
+	for toolName, toolConfig := range env.Tools {
+		if !toolConfig.Enabled {
+			continue
+		}
+		if onlyTool != "" && toolName != onlyTool {
+			continue
+		}
+		toolNames = append(toolNames, toolName)
+	}
+	sort.Strings(toolNames)
+
+	diffs := make([]ToolDiff, 0, len(toolNames))
+	for _, toolName := range toolNames {
+		result := ToolDiff{Tool: toolName, Changes: []tools.Change{}}
+
+		tool, exists := toolRegistry[toolName]
+		if !exists {
+			result.Error = "unknown tool"
+			diffs = append(diffs, result)
+			continue
+		}
+
+		diffs = append(diffs, diffToolSnapshot(env, toolName, tool, result))
+	}
+
+	return diffs
+}
+
+// diffToolSnapshot diffs a single tool against its snapshot, including the
+// machine-specific overlay
+func diffToolSnapshot(env *environment.Environment, toolName string, tool tools.Tool, result ToolDiff) ToolDiff {
+	snapshotPath, cleanup, err := env.ResolveToolSnapshot(toolName)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer cleanup()
+
+	if _, err := os.Stat(snapshotPath); os.IsNotExist(err) {
+		result.Error = "no snapshot found"
+		return result
+	}
+
+	changes, err := tool.Diff(snapshotPath)
+	if err != nil {
+		result.Error = redact.String(err.Error())
+		return result
+	}
+	for _, change := range changes {
+		result.Changes = append(result.Changes, change.Redacted())
+	}
+	return result
+}
+
+// printDiffs displays tool diffs in a human readable form
+func printDiffs(envName string, diffs []ToolDiff) {
+	if len(diffs) == 0 {
+		fmt.Printf("No enabled tools in '%s'\n", envName)
+		return
+	}
+
+	fmt.Printf("Changes since last snapshot of '%s':\n\n", envName)
+
+	total := 0
+	for _, d := range diffs {
+		switch {
+		case d.Error != "":
+			fmt.Printf("  ⚠️  %s: %s\n", d.Tool, d.Error)
+		case len(d.Changes) == 0:
+			fmt.Printf("  ✓ %s: no changes\n", d.Tool)
+		default:
+			fmt.Printf("  ✗ %s: %d change(s)\n", d.Tool, len(d.Changes))
+			for _, change := range d.Changes {
+				fmt.Printf("      %s\n", formatChange(change))
+			}
+			total += len(d.Changes)
+		}
+	}
+
+	fmt.Println()
+	fmt.Printf("Total: %d change(s)\n", total)
+}
+
+// formatChange formats a single change for display
+func formatChange(change tools.Change) string {
+	switch change.Type {
+	case tools.ChangeTypeAdded:
+		if change.NewValue != "" {
+			return fmt.Sprintf("+ %s: %s", change.Path, change.NewValue)
+		}
+		return fmt.Sprintf("+ %s", change.Path)
+	case tools.ChangeTypeRemoved:
+		if change.OldValue != "" {
+			return fmt.Sprintf("- %s: %s", change.Path, change.OldValue)
+		}
+		return fmt.Sprintf("- %s", change.Path)
+	default:
+		if change.OldValue != "" || change.NewValue != "" {
+			return fmt.Sprintf("~ %s: %s → %s", change.Path, change.OldValue, change.NewValue)
+		}
+		return fmt.Sprintf("~ %s", change.Path)
+	}
+}
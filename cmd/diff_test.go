@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hugofrely/envswitch/pkg/environment"
+	"github.com/hugofrely/envswitch/pkg/tools"
+)
+
+// installTestPlugin installs a file-based plugin for toolName pointing at configPath
+func installTestPlugin(t *testing.T, home, toolName, configPath string) {
+	pluginDir := filepath.Join(home, ".envswitch", "plugins", toolName)
+	require.NoError(t, os.MkdirAll(pluginDir, 0755))
+	manifest := "metadata:\n  name: " + toolName + "\n  version: 1.0.0\n  tool_name: " + toolName + "\n  config_path: " + configPath + "\n"
+	require.NoError(t, os.WriteFile(filepath.Join(pluginDir, "plugin.yaml"), []byte(manifest), 0644))
+}
+
+func TestRunDiff(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+
+	envsDir := filepath.Join(tempHome, ".envswitch", "environments")
+	require.NoError(t, os.MkdirAll(envsDir, 0755))
+
+	configPath := filepath.Join(tempHome, ".testrc")
+	require.NoError(t, os.WriteFile(configPath, []byte("live"), 0644))
+	installTestPlugin(t, tempHome, "testtool", configPath)
+
+	env := &environment.Environment{
+		Name: "diff-env",
+		Path: filepath.Join(envsDir, "diff-env"),
+		Tools: map[string]environment.ToolConfig{
+			"testtool": {Enabled: true},
+			"missing":  {Enabled: true},
+			"disabled": {Enabled: false},
+		},
+	}
+	snapshotDir := filepath.Join(env.Path, "snapshots", "testtool")
+	require.NoError(t, os.MkdirAll(snapshotDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(snapshotDir, ".testrc"), []byte("snapshot"), 0644))
+	require.NoError(t, env.Save())
+
+	t.Run("reports changes per enabled tool", func(t *testing.T) {
+		diffs := diffEnvironment(env, "")
+		require.Len(t, diffs, 2)
+
+		assert.Equal(t, "missing", diffs[0].Tool)
+		assert.NotEmpty(t, diffs[0].Error)
+
+		assert.Equal(t, "testtool", diffs[1].Tool)
+		require.Len(t, diffs[1].Changes, 1)
+		assert.Equal(t, tools.ChangeTypeModified, diffs[1].Changes[0].Type)
+	})
+
+	t.Run("filters by tool", func(t *testing.T) {
+		diffs := diffEnvironment(env, "testtool")
+		require.Len(t, diffs, 1)
+		assert.Equal(t, "testtool", diffs[0].Tool)
+	})
+
+	t.Run("runs for named environment", func(t *testing.T) {
+		assert.NoError(t, runDiff(diffCmd, []string{"diff-env"}))
+	})
+
+	t.Run("outputs json", func(t *testing.T) {
+		diffJSON = true
+		defer func() { diffJSON = false }()
+		assert.NoError(t, runDiff(diffCmd, []string{"diff-env"}))
+	})
+
+	t.Run("rejects tool that is not enabled", func(t *testing.T) {
+		diffTool = "disabled"
+		defer func() { diffTool = "" }()
+		assert.Error(t, runDiff(diffCmd, []string{"diff-env"}))
+	})
+
+	t.Run("requires active environment without name", func(t *testing.T) {
+		assert.Error(t, runDiff(diffCmd, []string{}))
+	})
+}
+
+func TestFormatChange(t *testing.T) {
+	assert.Equal(t, "+ region: us-east-1", formatChange(tools.Change{Type: tools.ChangeTypeAdded, Path: "region", NewValue: "us-east-1"}))
+	assert.Equal(t, "- config.json", formatChange(tools.Change{Type: tools.ChangeTypeRemoved, Path: "config.json"}))
+	assert.Equal(t, "~ account: a → b", formatChange(tools.Change{Type: tools.ChangeTypeModified, Path: "account", OldValue: "a", NewValue: "b"}))
+}
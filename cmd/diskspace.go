@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/hugofrely/envswitch/internal/storage"
+	"github.com/hugofrely/envswitch/pkg/environment"
+	"github.com/hugofrely/envswitch/pkg/tools"
+)
+
+// estimateSnapshotSize returns how many bytes snapshotting a tool into
+// snapshotPath may add. Snapshots are incremental, so the size of the
+// existing snapshot is subtracted from the size of the live configuration.
+func estimateSnapshotSize(tool tools.Tool, snapshotPath string) uint64 {
+	provider, ok := tool.(tools.PathProvider)
+	if !ok {
+		return 0
+	}
+
+	var live uint64
+	for _, path := range provider.ConfigPaths() {
+		live += storage.PathSize(path)
+	}
+
+	existing := storage.PathSize(snapshotPath)
+	if live <= existing {
+		return 0
+	}
+	return live - existing
+}
+
+// checkSnapshotSpace makes sure the filesystem holding the environment has
+// room for snapshots of the given tools before any of them is written
+func checkSnapshotSpace(env *environment.Environment, toolRegistry map[string]tools.Tool, toolNames []string) error {
+	var required uint64
+	for _, toolName := range toolNames {
+		required += estimateSnapshotSize(toolRegistry[toolName], filepath.Join(env.Path, "snapshots", toolName))
+	}
+	return diskSpaceError(storage.CheckDiskSpace(env.Path, required), "snapshot")
+}
+
+// diskSpaceError explains how to make room when err reports a lack of disk
+// space, other errors are returned unchanged
+func diskSpaceError(err error, operation string) error {
+	var spaceErr *storage.InsufficientSpaceError
+	if !errors.As(err, &spaceErr) {
+		return err
+	}
+	return fmt.Errorf("%s aborted before writing anything: %w\n"+
+		"   Free up some space, or exclude large tools with exclude_tools in ~/.envswitch/config.yaml", operation, err)
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hugofrely/envswitch/internal/storage"
+	"github.com/hugofrely/envswitch/pkg/tools"
+)
+
+func TestEstimateSnapshotSize(t *testing.T) {
+	tempDir := t.TempDir()
+	livePath := filepath.Join(tempDir, "live")
+	snapshotPath := filepath.Join(tempDir, "snapshot")
+
+	require.NoError(t, os.MkdirAll(livePath, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(livePath, "config"), make([]byte, 1000), 0644))
+
+	tool := tools.NewGenericTool("demo", livePath)
+
+	t.Run("counts the whole configuration for a new snapshot", func(t *testing.T) {
+		assert.Equal(t, uint64(1000), estimateSnapshotSize(tool, snapshotPath))
+	})
+
+	t.Run("only counts growth for an existing snapshot", func(t *testing.T) {
+		require.NoError(t, os.MkdirAll(snapshotPath, 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(snapshotPath, "config"), make([]byte, 600), 0644))
+		assert.Equal(t, uint64(400), estimateSnapshotSize(tool, snapshotPath))
+	})
+
+	t.Run("nothing when the snapshot is larger", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filepath.Join(snapshotPath, "config"), make([]byte, 2000), 0644))
+		assert.Zero(t, estimateSnapshotSize(tool, snapshotPath))
+	})
+}
+
+func TestDiskSpaceError(t *testing.T) {
+	assert.NoError(t, diskSpaceError(nil, "snapshot"))
+
+	err := diskSpaceError(&storage.InsufficientSpaceError{Path: "/home", Required: 2 << 30, Available: 1 << 30}, "snapshot")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "snapshot aborted before writing anything")
+	assert.Contains(t, err.Error(), "not enough disk space on /home")
+	assert.Contains(t, err.Error(), "exclude_tools")
+
+	var spaceErr *storage.InsufficientSpaceError
+	assert.ErrorAs(t, err, &spaceErr)
+}
@@ -0,0 +1,460 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/hugofrely/envswitch/internal/config"
+	"github.com/hugofrely/envswitch/internal/storage"
+	"github.com/hugofrely/envswitch/pkg/environment"
+	"github.com/hugofrely/envswitch/pkg/plugin"
+)
+
+// doctorMaxProblems limits how many problems of a check are listed
+const doctorMaxProblems = 10
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the health of the envswitch store",
+	Long: `Check ~/.envswitch for problems and explain how to fix them.
+
+Checks:
+  current.lock  active environment that no longer exists, or a corrupted lock
+  metadata      environments whose metadata.yaml cannot be read
+  snapshots     enabled tools without a snapshot
+  plugins       plugin directories without a valid plugin.yaml
+  permissions   snapshots and other files other users can read
+  config        config.yaml values that are invalid
+
+With --fix, the problems that are safe to repair are fixed: an orphaned or
+corrupted current.lock is cleared, leftover plugin directories are removed and
+permissions are restricted. The others are only reported.
+
+Examples:
+  envswitch doctor
+  envswitch doctor --fix
+  envswitch doctor --output json`,
+	Args: cobra.NoArgs,
+	RunE: runDoctor,
+}
+
+var doctorFix bool
+
+// doctorCheck is a diagnostic run by 'envswitch doctor'. It returns the
+// problems it found and how to fix them.
+type doctorCheck struct {
+	name string
+	run  func(envswitchDir string) (problems []string, fix string, err error)
+	// repair fixes the problems that are safe to fix, for --fix, and
+	// describes what it did. Checks without one are only reported.
+	repair func(envswitchDir string) (fixed []string, err error)
+}
+
+var doctorChecks = []doctorCheck{
+	{name: "current.lock", run: checkCurrentLock, repair: repairCurrentLock},
+	{name: "metadata", run: checkEnvironmentMetadata},
+	{name: "snapshots", run: checkMissingSnapshots},
+	{name: "plugins", run: checkStalePlugins, repair: repairStalePlugins},
+	{name: "permissions", run: checkStorePermissions, repair: repairStorePermissions},
+	{name: "config", run: checkConfigValues},
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+	doctorCmd.Flags().BoolVar(&doctorFix, "fix", false, "Repair the problems that are safe to fix")
+}
+
+// doctorResult is the outcome of a doctor check
+type doctorResult struct {
+	Check    string   `json:"check" yaml:"check"`
+	Status   string   `json:"status" yaml:"status"` // ok, problems or error
+	Problems []string `json:"problems,omitempty" yaml:"problems,omitempty"`
+	Fix      string   `json:"fix,omitempty" yaml:"fix,omitempty"`
+	// Fixed lists what --fix repaired, Problems what it left
+	Fixed []string `json:"fixed,omitempty" yaml:"fixed,omitempty"`
+	Error string   `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// Status of a doctor check
+const (
+	doctorStatusOK       = "ok"
+	doctorStatusProblems = "problems"
+	doctorStatusError    = "error"
+)
+
+func runDoctor(cmd *cobra.Command, args []string) error {
+	envswitchDir, err := environment.GetEnvswitchDir()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(envswitchDir); err != nil {
+		return fmt.Errorf("envswitch is not initialized (run 'envswitch init'): %w", err)
+	}
+
+	out, err := resultWriter()
+	if err != nil {
+		return err
+	}
+
+	total := 0
+	results := make([]doctorResult, 0, len(doctorChecks))
+	for _, check := range doctorChecks {
+		result := runDoctorCheck(check, envswitchDir, out.Structured())
+		if result.Status == doctorStatusError {
+			total++
+		}
+		total += len(result.Problems)
+		results = append(results, result)
+	}
+
+	if out.Structured() {
+		if err := out.Write(results); err != nil {
+			return err
+		}
+	}
+
+	if total > 0 {
+		return fmt.Errorf("found %d problem(s)", total)
+	}
+	if out.Structured() {
+		return nil
+	}
+
+	if doctorFix {
+		fmt.Println("\n✅ No problems left")
+		return nil
+	}
+	fmt.Println("\n✅ No problems found")
+	return nil
+}
+
+// runDoctorCheck runs a check, and its repair with --fix. The outcome is
+// printed as it goes unless quiet is set.
+func runDoctorCheck(check doctorCheck, envswitchDir string, quiet bool) doctorResult {
+	result := doctorResult{Check: check.name, Status: doctorStatusOK}
+	printf := func(format string, args ...interface{}) {
+		if !quiet {
+			fmt.Printf(format, args...)
+		}
+	}
+
+	problems, fix, err := check.run(envswitchDir)
+	if err != nil {
+		printf("⚠️  %s: check failed: %v\n", check.name, err)
+		result.Status = doctorStatusError
+		result.Error = err.Error()
+		return result
+	}
+	if len(problems) == 0 {
+		printf("✓ %s\n", check.name)
+		return result
+	}
+
+	printf("✗ %s: %d problem(s)\n", check.name, len(problems))
+	if !quiet {
+		printDoctorList(problems)
+	}
+
+	if doctorFix && check.repair != nil {
+		fixed, err := check.repair(envswitchDir)
+		result.Fixed = fixed
+		for _, line := range fixed {
+			printf("  🔧 %s\n", line)
+		}
+		if err != nil {
+			printf("  ⚠️  Repair failed: %v\n", err)
+			result.Error = fmt.Sprintf("repair failed: %v", err)
+		}
+
+		// Count what the repair left
+		if problems, _, err = check.run(envswitchDir); err != nil {
+			printf("  ⚠️  Check failed after repair: %v\n", err)
+			result.Status = doctorStatusError
+			result.Error = fmt.Sprintf("check failed after repair: %v", err)
+			return result
+		}
+		if len(problems) == 0 {
+			return result
+		}
+	}
+
+	result.Status = doctorStatusProblems
+	result.Problems = problems
+	result.Fix = fix
+	if fix != "" {
+		printf("  Fix: %s\n", fix)
+	}
+	return result
+}
+
+// printDoctorList prints the problems of a check, up to doctorMaxProblems
+func printDoctorList(problems []string) {
+	for i, problem := range problems {
+		if i == doctorMaxProblems {
+			fmt.Printf("    ... and %d more\n", len(problems)-doctorMaxProblems)
+			break
+		}
+		fmt.Printf("    %s\n", problem)
+	}
+}
+
+// checkCurrentLock reports a current.lock naming an environment that no
+// longer exists or that is corrupted, and the quarantined lock such a pointer
+// leaves behind
+func checkCurrentLock(envswitchDir string) ([]string, string, error) {
+	var problems []string
+
+	name, err := environment.GetCurrentEnvironmentName()
+	if errors.Is(err, environment.ErrCorrupted) {
+		problems = append(problems, corruptionReason(err))
+	} else if err != nil {
+		return nil, "", err
+	}
+	if name != "" {
+		exists, err := environment.EnvironmentExists(name)
+		if err != nil {
+			return nil, "", err
+		}
+		if !exists {
+			problems = append(problems, fmt.Sprintf("current.lock points to '%s', which does not exist", name))
+		}
+	}
+
+	stale, err := environment.GetStaleCurrentEnvironment()
+	if err != nil {
+		return nil, "", err
+	}
+	if stale != "" {
+		problems = append(problems, fmt.Sprintf("current.lock.stale is left over from the deleted environment '%s'", stale))
+	}
+
+	return problems, "run 'envswitch doctor --fix' to clear it", nil
+}
+
+func repairCurrentLock(envswitchDir string) ([]string, error) {
+	var fixed []string
+
+	name, err := environment.GetCurrentEnvironmentName()
+	if errors.Is(err, environment.ErrCorrupted) {
+		if err := environment.ClearCurrentEnvironment(); err != nil {
+			return fixed, err
+		}
+		fixed = append(fixed, "Cleared the corrupted current.lock, no environment is active")
+	} else if err != nil {
+		return nil, err
+	}
+	if name != "" {
+		if exists, err := environment.EnvironmentExists(name); err == nil && !exists {
+			if err := environment.ClearCurrentEnvironment(); err != nil {
+				return fixed, err
+			}
+			fixed = append(fixed, fmt.Sprintf("Cleared current.lock, no environment is active (was '%s')", name))
+		}
+	}
+
+	if stale, _ := environment.GetStaleCurrentEnvironment(); stale != "" {
+		if err := environment.ClearStaleCurrentEnvironment(); err != nil {
+			return fixed, err
+		}
+		fixed = append(fixed, "Removed current.lock.stale")
+	}
+	return fixed, nil
+}
+
+// checkEnvironmentMetadata reports environments whose metadata.yaml cannot
+// be loaded, which every command silently skips
+func checkEnvironmentMetadata(envswitchDir string) ([]string, string, error) {
+	invalid, err := environment.FindInvalidEnvironments()
+	if err != nil {
+		return nil, "", err
+	}
+
+	problems := make([]string, 0, len(invalid))
+	for _, env := range invalid {
+		problems = append(problems, fmt.Sprintf("%s: %s", env.Name, corruptionReason(env.Err)))
+	}
+	return problems, "repair metadata.yaml by hand, or restore the environment with 'envswitch backup restore'", nil
+}
+
+// corruptionReason returns an error without the hint to run doctor, which
+// doctor itself has no use for
+func corruptionReason(err error) string {
+	return strings.TrimSuffix(err.Error(), ": "+environment.ErrCorrupted.Error())
+}
+
+// checkMissingSnapshots reports enabled tools without a snapshot, which a
+// switch to their environment cannot restore
+func checkMissingSnapshots(envswitchDir string) ([]string, string, error) {
+	envs, err := environment.ListEnvironments()
+	if err != nil {
+		return nil, "", err
+	}
+
+	var problems []string
+	for _, env := range envs {
+		var toolNames []string
+		for toolName, toolConfig := range env.Tools {
+			if toolConfig.Enabled && !hasToolSnapshot(env, toolName) {
+				toolNames = append(toolNames, toolName)
+			}
+		}
+		sort.Strings(toolNames)
+		for _, toolName := range toolNames {
+			problems = append(problems, fmt.Sprintf("%s: %s is enabled but has no snapshot", env.Name, toolName))
+		}
+	}
+	return problems, "save the environment while it is active, or run 'envswitch tools disable <tool> --env <env>'", nil
+}
+
+// hasToolSnapshot reports whether the tool has a shared or machine-specific
+// snapshot directory
+func hasToolSnapshot(env *environment.Environment, toolName string) bool {
+	for _, dir := range []string{
+		filepath.Join(env.Path, "snapshots", toolName),
+		filepath.Join(env.HostSnapshotsDir(), toolName),
+	} {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return true
+		}
+	}
+	return false
+}
+
+// stalePlugin is a directory of the plugins directory that is not a plugin
+type stalePlugin struct {
+	path      string
+	problem   string
+	removable bool // safe to remove with --fix
+}
+
+// findStalePlugins lists the plugin directories without a valid manifest
+func findStalePlugins() ([]stalePlugin, error) {
+	pluginsDir, err := plugin.GetPluginsDir()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(pluginsDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var stale []stalePlugin
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		path := filepath.Join(pluginsDir, entry.Name())
+		manifestPath := filepath.Join(path, "plugin.yaml")
+
+		switch {
+		case strings.HasPrefix(entry.Name(), "."):
+			stale = append(stale, stalePlugin{path, "is left over from an interrupted install", true})
+		case !pathExists(manifestPath):
+			stale = append(stale, stalePlugin{path, "has no plugin.yaml", true})
+		default:
+			// A manifest that does not load may be a plugin being written
+			if _, err := plugin.LoadManifest(manifestPath); err != nil {
+				stale = append(stale, stalePlugin{path, fmt.Sprintf("has an invalid plugin.yaml: %v", err), false})
+			}
+		}
+	}
+	return stale, nil
+}
+
+// pathExists reports whether path exists
+func pathExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func checkStalePlugins(envswitchDir string) ([]string, string, error) {
+	stale, err := findStalePlugins()
+	if err != nil {
+		return nil, "", err
+	}
+
+	problems := make([]string, 0, len(stale))
+	for _, p := range stale {
+		problems = append(problems, fmt.Sprintf("%s %s", displayStorePath(envswitchDir, p.path), p.problem))
+	}
+	return problems, "run 'envswitch doctor --fix' to remove leftover directories; fix invalid manifests or run 'envswitch plugin remove <plugin>'", nil
+}
+
+func repairStalePlugins(envswitchDir string) ([]string, error) {
+	stale, err := findStalePlugins()
+	if err != nil {
+		return nil, err
+	}
+
+	var fixed []string
+	for _, p := range stale {
+		if !p.removable {
+			continue
+		}
+		if err := os.RemoveAll(p.path); err != nil {
+			return fixed, err
+		}
+		fixed = append(fixed, fmt.Sprintf("Removed %s", displayStorePath(envswitchDir, p.path)))
+	}
+	return fixed, nil
+}
+
+// checkConfigValues reports a config.yaml that does not parse or holds
+// unknown keys or invalid values
+func checkConfigValues(envswitchDir string) ([]string, string, error) {
+	fix := "run 'envswitch config edit' or use 'envswitch config set <key> <value>'"
+
+	var problems []string
+	for _, path := range []string{config.GetConfigPath(), config.GetLocalConfigPath()} {
+		errs, err := config.ValidateFile(path)
+		if err != nil {
+			return []string{err.Error()}, fix, nil
+		}
+		for _, err := range errs {
+			if path != config.GetConfigPath() {
+				err = fmt.Errorf("%s: %w", filepath.Base(path), err)
+			}
+			problems = append(problems, err.Error())
+		}
+	}
+	return problems, fix, nil
+}
+
+// checkStorePermissions reports paths of the store other users can access,
+// world-readable ones first as they expose credentials to every user
+func checkStorePermissions(envswitchDir string) ([]string, string, error) {
+	issues, err := storage.FindLoosePermissions(envswitchDir)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var worldReadable, groupAccess []string
+	for _, issue := range issues {
+		path := displayStorePath(envswitchDir, issue.Path)
+		if issue.WorldReadable() {
+			worldReadable = append(worldReadable, fmt.Sprintf("%s is readable by all users (%04o)", path, issue.Mode))
+		} else {
+			groupAccess = append(groupAccess, fmt.Sprintf("%s is accessible to its group (%04o)", path, issue.Mode))
+		}
+	}
+
+	return append(worldReadable, groupAccess...), "run 'envswitch harden' or 'envswitch doctor --fix'", nil
+}
+
+func repairStorePermissions(envswitchDir string) ([]string, error) {
+	issues, err := storage.HardenPermissions(envswitchDir)
+	if len(issues) == 0 {
+		return nil, err
+	}
+	return []string{fmt.Sprintf("Restricted the permissions of %d path(s)", len(issues))}, err
+}
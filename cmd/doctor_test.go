@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+func TestRunDoctorPermissions(t *testing.T) {
+	createLooseStore(t)
+
+	var err error
+	out := captureStdout(t, func() {
+		err = runDoctor(doctorCmd, nil)
+	})
+	assert.ErrorContains(t, err, "problem(s)")
+	assert.Contains(t, out, "✗ permissions")
+	assert.Contains(t, out, "~/.envswitch/environments/work/snapshots/aws/credentials is readable by all users (0644)")
+	assert.Contains(t, out, "envswitch harden")
+
+	captureStdout(t, func() {
+		require.NoError(t, runHarden(hardenCmd, nil))
+	})
+
+	out = captureStdout(t, func() {
+		err = runDoctor(doctorCmd, nil)
+	})
+	require.NoError(t, err)
+	assert.Contains(t, out, "✓ permissions")
+	assert.Contains(t, out, "No problems found")
+}
+
+func TestRunDoctorStoreChecks(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+
+	envswitchDir := filepath.Join(tempHome, ".envswitch")
+	envsDir := filepath.Join(envswitchDir, "environments")
+	require.NoError(t, os.MkdirAll(envsDir, 0700))
+
+	// An enabled tool without snapshot, and an environment that does not load
+	work := createEnvWithVars(t, envsDir, "work", nil)
+	work.Tools["kubectl"] = environment.ToolConfig{Enabled: true}
+	work.Tools["docker"] = environment.ToolConfig{Enabled: false}
+	require.NoError(t, work.Save())
+	brokenDir := filepath.Join(envsDir, "broken")
+	require.NoError(t, os.MkdirAll(brokenDir, 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(brokenDir, "metadata.yaml"), []byte("tools: [\n"), 0600))
+
+	// A lock on a deleted environment
+	require.NoError(t, os.WriteFile(filepath.Join(envswitchDir, "current.lock"), []byte("gone"), 0600))
+
+	// A plugin without manifest, a leftover install and an invalid manifest
+	pluginsDir := filepath.Join(envswitchDir, "plugins")
+	require.NoError(t, os.MkdirAll(filepath.Join(pluginsDir, "empty"), 0700))
+	require.NoError(t, os.MkdirAll(filepath.Join(pluginsDir, ".vim.installing"), 0700))
+	require.NoError(t, os.MkdirAll(filepath.Join(pluginsDir, "draft"), 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(pluginsDir, "draft", "plugin.yaml"), []byte("metadata:\n  name: draft\n"), 0600))
+
+	// Invalid config values
+	require.NoError(t, os.WriteFile(filepath.Join(envswitchDir, "config.yaml"), []byte("prompt_color: purple\nlog_level: loud\n"), 0600))
+
+	var err error
+	out := captureStdout(t, func() {
+		err = runDoctor(doctorCmd, nil)
+	})
+	require.Error(t, err)
+	assert.Contains(t, out, "current.lock points to 'gone', which does not exist")
+	assert.Contains(t, out, "broken: failed to parse metadata")
+	assert.Contains(t, out, "work: kubectl is enabled but has no snapshot")
+	assert.NotContains(t, out, "docker")
+	assert.Contains(t, out, "~/.envswitch/plugins/empty has no plugin.yaml")
+	assert.Contains(t, out, "~/.envswitch/plugins/.vim.installing is left over from an interrupted install")
+	assert.Contains(t, out, "~/.envswitch/plugins/draft has an invalid plugin.yaml")
+	assert.Contains(t, out, "prompt_color: invalid value 'purple'")
+	assert.Contains(t, out, "log_level: invalid value 'loud'")
+
+	doctorFix = true
+	defer func() { doctorFix = false }()
+	out = captureStdout(t, func() {
+		err = runDoctor(doctorCmd, nil)
+	})
+	require.Error(t, err)
+	assert.Contains(t, out, "🔧 Cleared current.lock")
+	assert.Contains(t, out, "🔧 Removed ~/.envswitch/plugins/empty")
+	assert.NoDirExists(t, filepath.Join(pluginsDir, "empty"))
+	assert.NoDirExists(t, filepath.Join(pluginsDir, ".vim.installing"))
+	assert.DirExists(t, filepath.Join(pluginsDir, "draft"), "invalid manifests are only reported")
+	assert.NoFileExists(t, filepath.Join(envswitchDir, "current.lock"))
+	assert.FileExists(t, filepath.Join(brokenDir, "metadata.yaml"))
+
+	// Only the problems --fix does not repair remain
+	doctorFix = false
+	out = captureStdout(t, func() {
+		err = runDoctor(doctorCmd, nil)
+	})
+	require.Error(t, err)
+	assert.Contains(t, out, "✓ current.lock")
+	assert.Contains(t, out, "✗ plugins: 1 problem(s)")
+}
+
+func TestRunDoctorCorruptedLock(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+
+	envswitchDir := filepath.Join(tempHome, ".envswitch")
+	require.NoError(t, os.MkdirAll(filepath.Join(envswitchDir, "environments"), 0700))
+	// A crash while current.lock was written left it empty
+	require.NoError(t, os.WriteFile(filepath.Join(envswitchDir, "current.lock"), nil, 0600))
+
+	var err error
+	out := captureStdout(t, func() {
+		err = runDoctor(doctorCmd, nil)
+	})
+	require.Error(t, err)
+	assert.Contains(t, out, "current.lock is empty")
+	assert.NotContains(t, out, "run 'envswitch doctor'")
+
+	doctorFix = true
+	defer func() { doctorFix = false }()
+	out = captureStdout(t, func() {
+		err = runDoctor(doctorCmd, nil)
+	})
+	require.NoError(t, err)
+	assert.Contains(t, out, "🔧 Cleared the corrupted current.lock")
+	assert.NoFileExists(t, filepath.Join(envswitchDir, "current.lock"))
+}
+
+func TestRunDoctorStructured(t *testing.T) {
+	createLooseStore(t)
+	setOutputFormat(t, "json")
+
+	var err error
+	out := captureStdout(t, func() {
+		err = runDoctor(doctorCmd, nil)
+	})
+	assert.ErrorContains(t, err, "problem(s)")
+
+	var results []doctorResult
+	require.NoError(t, json.Unmarshal([]byte(out), &results), out)
+	require.Len(t, results, len(doctorChecks))
+	for _, result := range results {
+		if result.Check != "permissions" {
+			continue
+		}
+		assert.Equal(t, doctorStatusProblems, result.Status)
+		assert.Contains(t, result.Problems, "~/.envswitch/environments/work/snapshots/aws/credentials is readable by all users (0644)")
+		assert.Contains(t, result.Fix, "envswitch harden")
+	}
+}
@@ -0,0 +1,283 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+
+	"github.com/hugofrely/envswitch/internal/archive"
+	"github.com/hugofrely/envswitch/internal/storage"
+	"github.com/hugofrely/envswitch/pkg/environment"
+	"github.com/hugofrely/envswitch/pkg/tools"
+)
+
+// Sizes above which du suggests making room
+const (
+	duLargeSnapshot = 10 * 1000 * 1000  // a snapshot worth an exclude pattern
+	duLargeArchives = 100 * 1000 * 1000 // backups worth pruning
+)
+
+var duTop int
+
+var duCmd = &cobra.Command{
+	Use:   "du [environment|group/|pattern]",
+	Short: "Show the disk usage of environments, snapshots and backups",
+	Long: `Show the disk usage of each environment and of its tool snapshots, and of the
+backups in ~/.envswitch/archives. The largest snapshots are listed last, with
+exclude patterns that would make them smaller.
+
+Examples:
+  envswitch du
+  envswitch du work
+  envswitch du 'client-*' --top 10
+  envswitch du -o json`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeEnvironmentNames,
+	RunE:              runDu,
+}
+
+func init() {
+	rootCmd.AddCommand(duCmd)
+	duCmd.Flags().IntVar(&duTop, "top", 5, "Number of largest snapshots to show")
+}
+
+// diskUsage is the report printed by du
+type diskUsage struct {
+	Environments []envUsage   `json:"environments" yaml:"environments"`
+	Archives     archiveUsage `json:"archives" yaml:"archives"`
+	TotalBytes   uint64       `json:"total_bytes" yaml:"total_bytes"`
+	Largest      []toolUsage  `json:"largest,omitempty" yaml:"largest,omitempty"`
+	Suggestions  []string     `json:"suggestions,omitempty" yaml:"suggestions,omitempty"`
+}
+
+// envUsage is the disk usage of an environment and of its tool snapshots
+type envUsage struct {
+	Name      string      `json:"name" yaml:"name"`
+	SizeBytes uint64      `json:"size_bytes" yaml:"size_bytes"`
+	Tools     []toolUsage `json:"tools,omitempty" yaml:"tools,omitempty"`
+}
+
+// toolUsage is the disk usage of a tool snapshot
+type toolUsage struct {
+	Environment string `json:"environment,omitempty" yaml:"environment,omitempty"`
+	Tool        string `json:"tool" yaml:"tool"`
+	SizeBytes   uint64 `json:"size_bytes" yaml:"size_bytes"`
+}
+
+// archiveUsage is the disk usage of the backups and archives
+type archiveUsage struct {
+	Count     int    `json:"count" yaml:"count"`
+	SizeBytes uint64 `json:"size_bytes" yaml:"size_bytes"`
+	BlobBytes uint64 `json:"blob_bytes,omitempty" yaml:"blob_bytes,omitempty"` // content shared by deduplicated backups
+}
+
+func runDu(cmd *cobra.Command, args []string) error {
+	if duTop < 0 {
+		return fmt.Errorf("--top must be positive")
+	}
+
+	environments, err := environment.ListEnvironments()
+	if err != nil {
+		return err
+	}
+	if len(args) == 1 {
+		if environments, err = filterEnvironments(environments, args[0]); err != nil {
+			return err
+		}
+		if len(environments) == 0 {
+			return fmt.Errorf("no environment matches '%s'", args[0])
+		}
+	}
+
+	usage := collectDiskUsage(environments, getToolRegistry())
+	// Backups are not per environment, only report them for the whole tree
+	if len(args) == 0 {
+		usage.Archives = collectArchiveUsage()
+		usage.TotalBytes += usage.Archives.SizeBytes
+		if usage.Archives.SizeBytes >= duLargeArchives {
+			usage.Suggestions = append(usage.Suggestions, fmt.Sprintf(
+				"backups take %s: delete old ones with 'envswitch backup prune --older-than 30d'",
+				humanize.Bytes(usage.Archives.SizeBytes)))
+		}
+	}
+
+	out, err := resultWriter()
+	if err != nil {
+		return err
+	}
+	if out.Structured() {
+		return out.Write(usage)
+	}
+
+	return printDiskUsage(usage, len(args) == 0)
+}
+
+// collectDiskUsage measures environments, sorted from the largest, and
+// suggests exclusions for their largest snapshots
+func collectDiskUsage(environments []*environment.Environment, registry map[string]tools.Tool) *diskUsage {
+	usage := &diskUsage{Environments: []envUsage{}}
+	var all []toolUsage
+
+	byName := make(map[string]*environment.Environment, len(environments))
+	for _, env := range environments {
+		byName[env.Name] = env
+		entry := envUsage{Name: env.Name, SizeBytes: storage.PathSize(env.Path)}
+		for toolName, config := range env.Tools {
+			if !config.Enabled {
+				continue
+			}
+			tool := toolUsage{Tool: toolName, SizeBytes: env.ToolSnapshotSize(toolName)}
+			entry.Tools = append(entry.Tools, tool)
+
+			tool.Environment = env.Name
+			all = append(all, tool)
+		}
+		sortToolUsage(entry.Tools)
+
+		usage.Environments = append(usage.Environments, entry)
+		usage.TotalBytes += entry.SizeBytes
+	}
+	sort.SliceStable(usage.Environments, func(i, j int) bool {
+		return usage.Environments[i].SizeBytes > usage.Environments[j].SizeBytes
+	})
+
+	sortToolUsage(all)
+	for _, tool := range all {
+		if len(usage.Largest) >= duTop || tool.SizeBytes == 0 {
+			break
+		}
+		usage.Largest = append(usage.Largest, tool)
+
+		if tool.SizeBytes < duLargeSnapshot {
+			continue
+		}
+		if suggestion := suggestExclusion(byName[tool.Environment], tool, registry[tool.Tool]); suggestion != "" {
+			usage.Suggestions = append(usage.Suggestions, suggestion)
+		}
+	}
+
+	return usage
+}
+
+// sortToolUsage sorts snapshots from the largest, then by name
+func sortToolUsage(usage []toolUsage) {
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].SizeBytes != usage[j].SizeBytes {
+			return usage[i].SizeBytes > usage[j].SizeBytes
+		}
+		if usage[i].Environment != usage[j].Environment {
+			return usage[i].Environment < usage[j].Environment
+		}
+		return usage[i].Tool < usage[j].Tool
+	})
+}
+
+// suggestExclusion suggests leaving the entry taking most of a large snapshot
+// out of it, with exclude_patterns for the tools supporting them and
+// exclude_tools for the others
+func suggestExclusion(env *environment.Environment, usage toolUsage, tool tools.Tool) string {
+	snapshotPath := filepath.Join(env.Path, "snapshots", usage.Tool)
+	entries, err := os.ReadDir(snapshotPath)
+	if err != nil {
+		return ""
+	}
+
+	var largest string
+	var largestSize uint64
+	var largestDir bool
+	for _, entry := range entries {
+		if entry.Name() == storage.ManifestFile {
+			continue
+		}
+		if size := storage.PathSize(filepath.Join(snapshotPath, entry.Name())); size > largestSize {
+			largest, largestSize, largestDir = entry.Name(), size, entry.IsDir()
+		}
+	}
+
+	name := env.Name + "/" + usage.Tool
+	if _, ok := tool.(tools.Excluder); ok && largest != "" && largestSize*2 >= usage.SizeBytes {
+		pattern := largest
+		if largestDir {
+			pattern += "/**"
+		}
+		return fmt.Sprintf("%s: %s takes %s of %s, add \"%s\" to exclude_patterns if %s does not need it",
+			name, largest, humanize.Bytes(largestSize), humanize.Bytes(usage.SizeBytes), pattern, usage.Tool)
+	}
+	return fmt.Sprintf("%s takes %s: add %s to exclude_tools if you do not need to switch it",
+		name, humanize.Bytes(usage.SizeBytes), usage.Tool)
+}
+
+// collectArchiveUsage measures the archive directory
+func collectArchiveUsage() archiveUsage {
+	var usage archiveUsage
+	archiveDir, err := archive.GetArchiveDir()
+	if err != nil {
+		return usage
+	}
+	if archives, err := archive.ListArchives(); err == nil {
+		usage.Count = len(archives)
+	}
+	if shelved, err := archive.ListShelved(); err == nil {
+		usage.Count += len(shelved)
+	}
+	usage.SizeBytes = storage.PathSize(archiveDir)
+	usage.BlobBytes = storage.PathSize(filepath.Join(archiveDir, "blobs"))
+	return usage
+}
+
+// printDiskUsage prints the report of du, with the backups when they were
+// measured
+func printDiskUsage(usage *diskUsage, withArchives bool) error {
+	if len(usage.Environments) == 0 {
+		fmt.Println("No environments yet")
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ENVIRONMENT\tSIZE\tSNAPSHOTS")
+		for _, env := range usage.Environments {
+			tools := make([]string, 0, len(env.Tools))
+			for _, tool := range env.Tools {
+				tools = append(tools, fmt.Sprintf("%s %s", tool.Tool, humanize.Bytes(tool.SizeBytes)))
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", env.Name, humanize.Bytes(env.SizeBytes), wideValue(strings.Join(tools, ", ")))
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+
+	fmt.Println()
+	if withArchives {
+		fmt.Printf("Backups: %d, %s", usage.Archives.Count, humanize.Bytes(usage.Archives.SizeBytes))
+		if usage.Archives.BlobBytes > 0 {
+			fmt.Printf(" (%s of shared content)", humanize.Bytes(usage.Archives.BlobBytes))
+		}
+		fmt.Println()
+	}
+	fmt.Printf("Total: %s\n", humanize.Bytes(usage.TotalBytes))
+
+	if len(usage.Largest) > 0 {
+		fmt.Println()
+		fmt.Println("Largest snapshots:")
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for i, tool := range usage.Largest {
+			fmt.Fprintf(w, "  %d. %s/%s\t%s\n", i+1, tool.Environment, tool.Tool, humanize.Bytes(tool.SizeBytes))
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if len(usage.Suggestions) > 0 {
+		fmt.Println()
+		for _, suggestion := range usage.Suggestions {
+			fmt.Printf("💡 %s\n", suggestion)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+// writeSizedFile creates a file of size bytes, sparse so tests stay fast
+func writeSizedFile(t *testing.T, path string, size int64) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	file, err := os.Create(path)
+	require.NoError(t, err)
+	require.NoError(t, file.Truncate(size))
+	require.NoError(t, file.Close())
+}
+
+// createEnvWithSnapshots creates an environment whose tools have snapshot
+// files of the given sizes, by path under snapshots/
+func createEnvWithSnapshots(t *testing.T, envsDir, name string, files map[string]int64) *environment.Environment {
+	t.Helper()
+	env := createEnvWithVars(t, envsDir, name, nil)
+	for file, size := range files {
+		writeSizedFile(t, filepath.Join(env.Path, "snapshots", filepath.FromSlash(file)), size)
+		toolName := strings.SplitN(file, "/", 2)[0]
+		env.Tools[toolName] = environment.ToolConfig{Enabled: true, SnapshotSize: uint64(size)}
+	}
+	require.NoError(t, env.Save())
+	return env
+}
+
+func TestRunDu(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+
+	envsDir := filepath.Join(tempHome, ".envswitch", "environments")
+	require.NoError(t, os.MkdirAll(envsDir, 0755))
+	createEnvWithSnapshots(t, envsDir, "work", map[string]int64{
+		"gcloud/logs/run.log":   12 * 1000 * 1000,
+		"gcloud/configurations": 2000,
+		"git/gitconfig":         300,
+	})
+	createEnvWithSnapshots(t, envsDir, "home", map[string]int64{
+		"git/gitconfig": 200,
+	})
+
+	t.Run("text", func(t *testing.T) {
+		output := captureStdout(t, func() {
+			require.NoError(t, runDu(duCmd, nil))
+		})
+
+		assert.Less(t, strings.Index(output, "work"), strings.Index(output, "home"), "largest environment first")
+		assert.Contains(t, output, "gcloud 12 MB, git 300 B")
+		assert.Contains(t, output, "Backups: 0")
+		assert.Contains(t, output, "1. work/gcloud")
+		assert.Contains(t, output, `add "logs/**" to exclude_patterns`)
+	})
+
+	t.Run("filtered", func(t *testing.T) {
+		output := captureStdout(t, func() {
+			require.NoError(t, runDu(duCmd, []string{"home"}))
+		})
+		assert.NotContains(t, output, "work")
+		assert.NotContains(t, output, "Backups:")
+
+		assert.Error(t, runDu(duCmd, []string{"missing"}))
+	})
+
+	t.Run("json", func(t *testing.T) {
+		setOutputFormat(t, "json")
+		duTop = 2
+		defer func() { duTop = 5 }()
+
+		output := captureStdout(t, func() {
+			require.NoError(t, runDu(duCmd, nil))
+		})
+
+		var usage diskUsage
+		require.NoError(t, json.Unmarshal([]byte(output), &usage))
+		require.Len(t, usage.Environments, 2)
+		assert.Equal(t, "work", usage.Environments[0].Name)
+		require.Len(t, usage.Largest, 2)
+		assert.Equal(t, toolUsage{Environment: "work", Tool: "gcloud", SizeBytes: 12002000}, usage.Largest[0])
+		assert.Len(t, usage.Suggestions, 1)
+	})
+}
+
+func TestSuggestExclusion(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+
+	envsDir := filepath.Join(tempHome, ".envswitch", "environments")
+	env := createEnvWithSnapshots(t, envsDir, "work", map[string]int64{
+		"custom/a.bin": 6 * 1000 * 1000,
+		"custom/b.bin": 6 * 1000 * 1000,
+	})
+	registry := getToolRegistry()
+
+	// Tools without exclude patterns can only be excluded as a whole
+	suggestion := suggestExclusion(env, toolUsage{Tool: "custom", SizeBytes: 12 * 1000 * 1000}, registry["custom"])
+	assert.Contains(t, suggestion, "add custom to exclude_tools")
+
+	// No single entry takes most of the snapshot
+	suggestion = suggestExclusion(env, toolUsage{Tool: "custom", SizeBytes: 30 * 1000 * 1000}, registry["gcloud"])
+	assert.Contains(t, suggestion, "exclude_tools")
+}
@@ -0,0 +1,593 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/hugofrely/envswitch/internal/shell"
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+var (
+	envRenameAllEnvs bool
+	envRenameEnv     string
+	envRenameForce   bool
+	envCopyFrom      string
+	envCopyTo        string
+	envCopyForce     bool
+	envTargetEnv     string
+	envSetSecret     bool
+	envListReveal    bool
+	envImportSecret  bool
+	envImportForce   bool
+	envExportShell   string
+	envExportPrev    string
+)
+
+var envCmd = &cobra.Command{
+	Use:   "env",
+	Short: "Manage environment variables",
+	Long:  `Manage the environment variables tracked by your environments.`,
+}
+
+var envRenameCmd = &cobra.Command{
+	Use:   "rename <old> <new>",
+	Short: "Rename an environment variable",
+	Long: `Rename an environment variable in the active environment, a specific
+environment, or all environments at once.
+
+Both the tracked variable and its captured value are renamed.
+
+Examples:
+  # Rename in the active environment
+  envswitch env rename API_KEY CLIENT_API_KEY
+
+  # Rename in a specific environment
+  envswitch env rename API_KEY CLIENT_API_KEY --env work
+
+  # Rename in every environment
+  envswitch env rename API_KEY CLIENT_API_KEY --all-envs`,
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: completeFirstEnvVarKey,
+	RunE:              runEnvRename,
+}
+
+var envCopyCmd = &cobra.Command{
+	Use:   "copy <key>",
+	Short: "Copy an environment variable to other environments",
+	Long: `Copy an environment variable from one environment to one or more others.
+
+Examples:
+  # Copy API_URL from client-a to client-b and client-c
+  envswitch env copy API_URL --from client-a --to client-b,client-c
+
+  # Copy API_URL to every environment of a group
+  envswitch env copy API_URL --from client-a/prod --to 'client-a/*'
+
+  # Overwrite the variable if it already exists in the targets
+  envswitch env copy API_URL --from client-a --to client-b --force`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeFirstEnvVarKey,
+	RunE:              runEnvCopy,
+}
+
+var envSetCmd = &cobra.Command{
+	Use:   "set <KEY=VALUE>...",
+	Short: "Set environment variables",
+	Long: `Set one or more environment variables in the active environment or a
+specific environment.
+
+Variables marked with --secret are masked in 'env list' and 'show' output.
+
+Examples:
+  # Set a variable in the active environment
+  envswitch env set API_URL=https://api.example.com
+
+  # Set several variables in a specific environment
+  envswitch env set API_URL=https://api.example.com REGION=eu-west-1 --env work
+
+  # Set a secret variable
+  envswitch env set API_TOKEN=abc123 --secret`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runEnvSet,
+}
+
+var envGetCmd = &cobra.Command{
+	Use:   "get <KEY>",
+	Short: "Print the value of an environment variable",
+	Long: `Print the value of an environment variable, including secret ones.
+
+Examples:
+  envswitch env get API_URL
+  export API_TOKEN=$(envswitch env get API_TOKEN --env work)`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeFirstEnvVarKey,
+	RunE:              runEnvGet,
+}
+
+var envTrackCmd = &cobra.Command{
+	Use:   "track <NAME|GLOB>...",
+	Short: "Capture environment variables from the shell on save",
+	Long: `Track variables by name or glob: each 'envswitch save' captures the ones set
+in the shell. Variables matching env_vars_deny in the config are left out,
+unless tracked by their exact name. 'env unset' stops tracking a pattern.
+
+Examples:
+  envswitch env track AWS_PROFILE
+  envswitch env track 'AWS_*' 'VAULT_*' --env work`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runEnvTrack,
+}
+
+var envUnsetCmd = &cobra.Command{
+	Use:   "unset <KEY>...",
+	Short: "Remove environment variables",
+	Long: `Remove one or more environment variables from an environment.
+
+Examples:
+  envswitch env unset API_URL
+  envswitch env unset API_URL REGION --env work`,
+	Args:              cobra.MinimumNArgs(1),
+	ValidArgsFunction: completeEnvVarKeys,
+	RunE:              runEnvUnset,
+}
+
+var envListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List environment variables",
+	Long: `List the environment variables of an environment. Secret values are
+masked unless --reveal is given.
+
+Examples:
+  envswitch env list
+  envswitch env list --env work --reveal`,
+	Args: cobra.NoArgs,
+	RunE: runEnvList,
+}
+
+var envImportCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Import environment variables from a .env file",
+	Long: `Import environment variables from a .env file. Lines may start with
+"export" and values may be quoted. Existing variables are kept unless --force
+is given.
+
+Examples:
+  envswitch env import .env
+  envswitch env import secrets.env --secret --env work
+  envswitch env import .env --force`,
+	Args: cobra.ExactArgs(1),
+	RunE: runEnvImport,
+}
+
+var envExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Print shell commands that apply environment variables",
+	Long: `Print the shell commands that export the variables of the active
+environment (or --env) into the current shell session. Variables listed in
+--previous that the environment does not set are unset.
+
+The shell integration ('envswitch shell init') runs this automatically after
+each switch, so you normally do not need to call it yourself.
+
+Examples:
+  eval "$(envswitch env export)"
+  envswitch env export --shell fish | source
+  envswitch env export --shell powershell | Out-String | Invoke-Expression`,
+	Args: cobra.NoArgs,
+	RunE: runEnvExport,
+}
+
+func init() {
+	rootCmd.AddCommand(envCmd)
+	envCmd.AddCommand(envRenameCmd)
+	envCmd.AddCommand(envCopyCmd)
+	envCmd.AddCommand(envSetCmd)
+	envCmd.AddCommand(envGetCmd)
+	envCmd.AddCommand(envTrackCmd)
+	envCmd.AddCommand(envUnsetCmd)
+	envCmd.AddCommand(envListCmd)
+	envCmd.AddCommand(envImportCmd)
+	envCmd.AddCommand(envExportCmd)
+
+	for _, cmd := range []*cobra.Command{envSetCmd, envGetCmd, envTrackCmd, envUnsetCmd, envListCmd, envImportCmd, envExportCmd} {
+		cmd.Flags().StringVar(&envTargetEnv, "env", "", "Environment to use (default: active environment)")
+		_ = cmd.RegisterFlagCompletionFunc("env", completeEnvironmentFlag)
+	}
+	envSetCmd.Flags().BoolVar(&envSetSecret, "secret", false, "Mark the variables as secret")
+	envListCmd.Flags().BoolVar(&envListReveal, "reveal", false, "Show secret values")
+	envImportCmd.Flags().BoolVar(&envImportSecret, "secret", false, "Mark the imported variables as secret")
+	envImportCmd.Flags().BoolVarP(&envImportForce, "force", "f", false, "Overwrite variables that already exist")
+	envExportCmd.Flags().StringVar(&envExportShell, "shell", "", "Shell syntax: bash, zsh, fish, powershell or cmd (default: detected)")
+	envExportCmd.Flags().StringVar(&envExportPrev, "previous", "", "Space-separated variables exported earlier, unset when no longer set")
+	_ = envExportCmd.RegisterFlagCompletionFunc("shell", cobra.FixedCompletions([]string{"bash", "zsh", "fish", "powershell"}, cobra.ShellCompDirectiveNoFileComp))
+
+	envRenameCmd.Flags().BoolVar(&envRenameAllEnvs, "all-envs", false, "Rename in all environments")
+	envRenameCmd.Flags().StringVar(&envRenameEnv, "env", "", "Environment to update (default: active environment)")
+	envRenameCmd.Flags().BoolVarP(&envRenameForce, "force", "f", false, "Overwrite the new variable if it already exists")
+	_ = envRenameCmd.RegisterFlagCompletionFunc("env", completeEnvironmentFlag)
+
+	envCopyCmd.Flags().StringVar(&envCopyFrom, "from", "", "Source environment")
+	envCopyCmd.Flags().StringVar(&envCopyTo, "to", "", "Comma-separated list of target environments or patterns")
+	envCopyCmd.Flags().BoolVarP(&envCopyForce, "force", "f", false, "Overwrite the variable if it already exists in a target")
+	_ = envCopyCmd.MarkFlagRequired("from")
+	_ = envCopyCmd.MarkFlagRequired("to")
+	_ = envCopyCmd.RegisterFlagCompletionFunc("from", completeEnvironmentFlag)
+	_ = envCopyCmd.RegisterFlagCompletionFunc("to", completeEnvironmentListFlag)
+}
+
+func runEnvRename(cmd *cobra.Command, args []string) error {
+	oldKey, newKey := args[0], args[1]
+
+	if oldKey == newKey {
+		return fmt.Errorf("old and new names are identical")
+	}
+
+	if envRenameAllEnvs && envRenameEnv != "" {
+		return fmt.Errorf("cannot use --env with --all-envs")
+	}
+
+	targets, err := resolveEnvTargets(envRenameEnv, envRenameAllEnvs)
+	if err != nil {
+		return err
+	}
+
+	renamed := 0
+	var failures []string
+	for _, env := range targets {
+		found, err := env.RenameEnvVar(oldKey, newKey, envRenameForce)
+		if err != nil {
+			fmt.Printf("✗ %s: %v\n", env.Name, err)
+			failures = append(failures, env.Name)
+			continue
+		}
+		if found {
+			fmt.Printf("✓ %s: %s → %s\n", env.Name, oldKey, newKey)
+			renamed++
+		}
+	}
+
+	if renamed == 0 && len(failures) == 0 {
+		return fmt.Errorf("variable %s not found", oldKey)
+	}
+
+	fmt.Printf("\n✅ Renamed %s in %d environment(s)\n", oldKey, renamed)
+	if len(failures) > 0 {
+		return fmt.Errorf("failed to rename in: %s", strings.Join(failures, ", "))
+	}
+
+	return nil
+}
+
+func runEnvCopy(cmd *cobra.Command, args []string) error {
+	key := args[0]
+
+	source, err := environment.LoadEnvironment(envCopyFrom)
+	if err != nil {
+		return fmt.Errorf("failed to load environment '%s': %w", envCopyFrom, err)
+	}
+
+	targetNames, err := environment.ExpandNames(splitEnvList(envCopyTo))
+	if err != nil {
+		return err
+	}
+	if len(targetNames) == 0 {
+		return fmt.Errorf("no target environments specified")
+	}
+
+	copied := 0
+	var failures []string
+	for _, name := range targetNames {
+		if name == source.Name {
+			continue
+		}
+
+		target, err := environment.LoadEnvironment(name)
+		if err != nil {
+			fmt.Printf("✗ %s: environment not found\n", name)
+			failures = append(failures, name)
+			continue
+		}
+
+		if err := source.CopyEnvVarTo(target, key, envCopyForce); err != nil {
+			fmt.Printf("✗ %s: %v\n", name, err)
+			failures = append(failures, name)
+			continue
+		}
+
+		fmt.Printf("✓ %s: copied %s\n", name, key)
+		copied++
+	}
+
+	fmt.Printf("\n✅ Copied %s to %d environment(s)\n", key, copied)
+	if len(failures) > 0 {
+		return fmt.Errorf("failed to copy to: %s", strings.Join(failures, ", "))
+	}
+
+	return nil
+}
+
+func runEnvSet(cmd *cobra.Command, args []string) error {
+	envVars := make([]environment.EnvVar, 0, len(args))
+	for _, arg := range args {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok {
+			return fmt.Errorf("invalid argument '%s': expected KEY=VALUE", arg)
+		}
+		if err := environment.ValidateEnvVarKey(key); err != nil {
+			return err
+		}
+		envVars = append(envVars, environment.EnvVar{Key: key, Value: value})
+	}
+
+	env, err := resolveEnvTarget(envTargetEnv)
+	if err != nil {
+		return err
+	}
+
+	for _, envVar := range envVars {
+		if envSetSecret {
+			env.SetEnvVarSecret(envVar.Key, true)
+		}
+		if err := env.SetEnvVar(envVar.Key, envVar.Value); err != nil {
+			return fmt.Errorf("failed to set %s: %w", envVar.Key, err)
+		}
+		fmt.Printf("✓ %s: set %s\n", env.Name, envVar.Key)
+	}
+
+	return nil
+}
+
+func runEnvGet(cmd *cobra.Command, args []string) error {
+	key := args[0]
+
+	env, err := resolveEnvTarget(envTargetEnv)
+	if err != nil {
+		return err
+	}
+
+	value, ok, err := env.GetEnvVar(key)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("variable %s not found in environment '%s'", key, env.Name)
+	}
+
+	fmt.Println(value)
+	return nil
+}
+
+func runEnvTrack(cmd *cobra.Command, args []string) error {
+	env, err := resolveEnvTarget(envTargetEnv)
+	if err != nil {
+		return err
+	}
+
+	for _, pattern := range args {
+		added, err := env.TrackEnvVar(pattern)
+		if err != nil {
+			return fmt.Errorf("failed to track %s: %w", pattern, err)
+		}
+		if !added {
+			fmt.Printf("%s: %s is already tracked\n", env.Name, pattern)
+			continue
+		}
+		fmt.Printf("✓ %s: tracking %s\n", env.Name, pattern)
+	}
+	return nil
+}
+
+func runEnvUnset(cmd *cobra.Command, args []string) error {
+	env, err := resolveEnvTarget(envTargetEnv)
+	if err != nil {
+		return err
+	}
+
+	var missing []string
+	for _, key := range args {
+		removed, err := env.UnsetEnvVar(key)
+		if err != nil {
+			return fmt.Errorf("failed to unset %s: %w", key, err)
+		}
+		if !removed {
+			missing = append(missing, key)
+			continue
+		}
+		fmt.Printf("✓ %s: unset %s\n", env.Name, key)
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("variable(s) not found: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+func runEnvList(cmd *cobra.Command, args []string) error {
+	env, err := resolveEnvTarget(envTargetEnv)
+	if err != nil {
+		return err
+	}
+
+	// Secret values are only decrypted to be revealed
+	var envVars []environment.EnvVar
+	if envListReveal {
+		envVars, err = env.ListEnvVars()
+	} else {
+		envVars, err = env.ListEnvVarsMasked(secretMask)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(envVars) == 0 {
+		fmt.Printf("No environment variables in '%s'.\n", env.Name)
+		fmt.Println("Set one with: envswitch env set KEY=VALUE")
+		return nil
+	}
+
+	for _, envVar := range envVars {
+		fmt.Printf("%s=%s\n", envVar.Key, envVar.Value)
+	}
+	return nil
+}
+
+func runEnvImport(cmd *cobra.Command, args []string) error {
+	envVars, err := environment.ReadEnvFile(args[0])
+	if err != nil {
+		return err
+	}
+	for _, envVar := range envVars {
+		if err := environment.ValidateEnvVarKey(envVar.Key); err != nil {
+			return fmt.Errorf("%s: %w", args[0], err)
+		}
+	}
+
+	env, err := resolveEnvTarget(envTargetEnv)
+	if err != nil {
+		return err
+	}
+
+	imported, skipped := 0, 0
+	for _, envVar := range envVars {
+		if _, exists, err := env.GetEnvVar(envVar.Key); err != nil {
+			return err
+		} else if exists && !envImportForce {
+			fmt.Printf("⚠️  %s: %s already exists, skipping (use --force to overwrite)\n", env.Name, envVar.Key)
+			skipped++
+			continue
+		}
+
+		if envImportSecret {
+			env.SetEnvVarSecret(envVar.Key, true)
+		}
+		if err := env.SetEnvVar(envVar.Key, envVar.Value); err != nil {
+			return fmt.Errorf("failed to set %s: %w", envVar.Key, err)
+		}
+		imported++
+	}
+
+	fmt.Printf("✅ Imported %d variable(s) into '%s'", imported, env.Name)
+	if skipped > 0 {
+		fmt.Printf(" (%d skipped)", skipped)
+	}
+	fmt.Println()
+	return nil
+}
+
+func runEnvExport(cmd *cobra.Command, args []string) error {
+	shellType := envExportShell
+	if shellType == "" {
+		shellType = detectShell()
+	}
+
+	// Without an active environment, only the previous variables are unset
+	var env *environment.Environment
+	var err error
+	if envTargetEnv != "" {
+		env, err = resolveEnvTarget(envTargetEnv)
+	} else {
+		env, err = environment.GetCurrentEnvironment()
+	}
+	if err != nil {
+		return err
+	}
+
+	return writeShellExports(os.Stdout, shellType, env, strings.Fields(envExportPrev))
+}
+
+// writeShellExports writes the commands applying the variables of env to a
+// shell session and unsetting the previous ones. env may be nil.
+func writeShellExports(w io.Writer, shellType string, env *environment.Environment, previous []string) error {
+	var envVars []environment.EnvVar
+	if env != nil {
+		var err error
+		if envVars, err = env.ListEnvVars(); err != nil {
+			return err
+		}
+		// A secret that cannot be read is not exported, the others are
+		if envVars, err = environment.ResolveEnvVars(envVars); err != nil {
+			envVarsLog.Warn("%v", err)
+		}
+	}
+
+	script, err := environment.FormatShellExports(shellType, envVars, previous)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, script)
+	return err
+}
+
+// detectShell returns the user's shell, see shell.DetectShell
+func detectShell() string {
+	return shell.DetectShell()
+}
+
+// resolveEnvTarget returns the single environment an env subcommand edits
+func resolveEnvTarget(name string) (*environment.Environment, error) {
+	if name == "" {
+		current, err := environment.GetCurrentEnvironment()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get current environment: %w", err)
+		}
+		if current == nil {
+			return nil, fmt.Errorf("no active environment. Use --env to choose one")
+		}
+		return current, nil
+	}
+
+	targets, err := resolveEnvTargets(name, false)
+	if err != nil {
+		return nil, err
+	}
+	return targets[0], nil
+}
+
+// resolveEnvTargets returns the environments an env subcommand should act on
+func resolveEnvTargets(name string, all bool) ([]*environment.Environment, error) {
+	if all {
+		envs, err := environment.ListEnvironments()
+		if err != nil {
+			return nil, err
+		}
+		if len(envs) == 0 {
+			return nil, fmt.Errorf("no environments found")
+		}
+		return envs, nil
+	}
+
+	if name != "" {
+		env, err := environment.LoadEnvironment(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load environment '%s': %w", name, err)
+		}
+		return []*environment.Environment{env}, nil
+	}
+
+	current, err := environment.GetCurrentEnvironment()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current environment: %w", err)
+	}
+	if current == nil {
+		return nil, fmt.Errorf("no active environment. Use --env or --all-envs")
+	}
+	return []*environment.Environment{current}, nil
+}
+
+// splitEnvList splits a comma-separated list of environment names
+func splitEnvList(value string) []string {
+	var names []string
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
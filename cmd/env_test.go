@@ -0,0 +1,303 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hugofrely/envswitch/internal/keyring"
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+func createEnvWithVars(t *testing.T, envsDir, name string, vars map[string]string) *environment.Environment {
+	env := &environment.Environment{
+		Name:    name,
+		Path:    filepath.Join(envsDir, name),
+		Tools:   make(map[string]environment.ToolConfig),
+		EnvVars: vars,
+	}
+	require.NoError(t, os.MkdirAll(env.Path, 0755))
+	require.NoError(t, env.Save())
+	return env
+}
+
+func TestRunEnvRename(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+
+	envsDir := filepath.Join(tempHome, ".envswitch", "environments")
+	require.NoError(t, os.MkdirAll(envsDir, 0755))
+
+	createEnvWithVars(t, envsDir, "client-a", map[string]string{"API_KEY": "a"})
+	createEnvWithVars(t, envsDir, "client-b", map[string]string{"API_KEY": "b"})
+	createEnvWithVars(t, envsDir, "client-c", map[string]string{"OTHER": "c"})
+
+	t.Run("requires an active environment without flags", func(t *testing.T) {
+		err := runEnvRename(envRenameCmd, []string{"API_KEY", "CLIENT_KEY"})
+		assert.Error(t, err)
+	})
+
+	t.Run("renames in a single environment", func(t *testing.T) {
+		envRenameEnv = "client-a"
+		defer func() { envRenameEnv = "" }()
+
+		require.NoError(t, runEnvRename(envRenameCmd, []string{"API_KEY", "CLIENT_KEY"}))
+
+		env, err := environment.LoadEnvironment("client-a")
+		require.NoError(t, err)
+		assert.Equal(t, "a", env.EnvVars["CLIENT_KEY"])
+
+		env, err = environment.LoadEnvironment("client-b")
+		require.NoError(t, err)
+		assert.Equal(t, "b", env.EnvVars["API_KEY"])
+	})
+
+	t.Run("renames across all environments", func(t *testing.T) {
+		envRenameAllEnvs = true
+		defer func() { envRenameAllEnvs = false }()
+
+		require.NoError(t, runEnvRename(envRenameCmd, []string{"API_KEY", "CLIENT_KEY"}))
+
+		env, err := environment.LoadEnvironment("client-b")
+		require.NoError(t, err)
+		assert.Equal(t, "b", env.EnvVars["CLIENT_KEY"])
+		assert.NotContains(t, env.EnvVars, "API_KEY")
+	})
+
+	t.Run("fails when variable is missing everywhere", func(t *testing.T) {
+		envRenameAllEnvs = true
+		defer func() { envRenameAllEnvs = false }()
+
+		assert.Error(t, runEnvRename(envRenameCmd, []string{"NOPE", "STILL_NOPE"}))
+	})
+}
+
+func TestRunEnvCopy(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+
+	envsDir := filepath.Join(tempHome, ".envswitch", "environments")
+	require.NoError(t, os.MkdirAll(envsDir, 0755))
+
+	createEnvWithVars(t, envsDir, "source", map[string]string{"API_URL": "https://api"})
+	createEnvWithVars(t, envsDir, "target-1", map[string]string{})
+	createEnvWithVars(t, envsDir, "target-2", map[string]string{"API_URL": "keep"})
+
+	defer func() { envCopyFrom, envCopyTo, envCopyForce = "", "", false }()
+
+	t.Run("copies to multiple environments and reports conflicts", func(t *testing.T) {
+		envCopyFrom = "source"
+		envCopyTo = "target-1, target-2"
+
+		err := runEnvCopy(envCopyCmd, []string{"API_URL"})
+		assert.Error(t, err)
+
+		env, err := environment.LoadEnvironment("target-1")
+		require.NoError(t, err)
+		assert.Equal(t, "https://api", env.EnvVars["API_URL"])
+
+		env, err = environment.LoadEnvironment("target-2")
+		require.NoError(t, err)
+		assert.Equal(t, "keep", env.EnvVars["API_URL"])
+	})
+
+	t.Run("overwrites with force", func(t *testing.T) {
+		envCopyFrom = "source"
+		envCopyTo = "target-2"
+		envCopyForce = true
+
+		require.NoError(t, runEnvCopy(envCopyCmd, []string{"API_URL"}))
+
+		env, err := environment.LoadEnvironment("target-2")
+		require.NoError(t, err)
+		assert.Equal(t, "https://api", env.EnvVars["API_URL"])
+	})
+}
+
+func TestRunEnvSetGetUnset(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+	keyring.MockInit() // secret values are encrypted with a key kept in the keyring
+
+	envsDir := filepath.Join(tempHome, ".envswitch", "environments")
+	require.NoError(t, os.MkdirAll(envsDir, 0755))
+	createEnvWithVars(t, envsDir, "work", map[string]string{})
+
+	envTargetEnv = "work"
+	defer func() { envTargetEnv, envSetSecret, envListReveal = "", false, false }()
+
+	t.Run("sets variables and marks secrets", func(t *testing.T) {
+		envSetSecret = true
+		defer func() { envSetSecret = false }()
+
+		require.NoError(t, runEnvSet(envSetCmd, []string{"API_TOKEN=abc=123"}))
+
+		env, err := environment.LoadEnvironment("work")
+		require.NoError(t, err)
+		assert.True(t, env.IsSecretEnvVar("API_TOKEN"))
+		value, _, err := env.GetEnvVar("API_TOKEN")
+		require.NoError(t, err)
+		assert.Equal(t, "abc=123", value)
+
+		// Neither the metadata nor the env-vars file hold the value
+		assert.Empty(t, env.EnvVars["API_TOKEN"])
+		data, err := os.ReadFile(filepath.Join(env.Path, "snapshots", "env-vars.env"))
+		require.NoError(t, err)
+		assert.NotContains(t, string(data), "abc=123")
+	})
+
+	t.Run("rejects invalid arguments before changing anything", func(t *testing.T) {
+		assert.Error(t, runEnvSet(envSetCmd, []string{"GOOD=1", "NOEQUALS"}))
+		assert.Error(t, runEnvSet(envSetCmd, []string{"GOOD=1", "BAD-KEY=1"}))
+
+		env, err := environment.LoadEnvironment("work")
+		require.NoError(t, err)
+		assert.NotContains(t, env.EnvVars, "GOOD")
+	})
+
+	t.Run("list masks secrets unless revealed", func(t *testing.T) {
+		require.NoError(t, runEnvSet(envSetCmd, []string{"API_URL=https://api"}))
+
+		output := captureStdout(t, func() { require.NoError(t, runEnvList(envListCmd, nil)) })
+		assert.Equal(t, "API_TOKEN=********\nAPI_URL=https://api\n", output)
+
+		envListReveal = true
+		output = captureStdout(t, func() { require.NoError(t, runEnvList(envListCmd, nil)) })
+		envListReveal = false
+		assert.Contains(t, output, "API_TOKEN=abc=123")
+	})
+
+	t.Run("get prints the raw value", func(t *testing.T) {
+		output := captureStdout(t, func() { require.NoError(t, runEnvGet(envGetCmd, []string{"API_TOKEN"})) })
+		assert.Equal(t, "abc=123\n", output)
+
+		assert.Error(t, runEnvGet(envGetCmd, []string{"MISSING"}))
+	})
+
+	t.Run("unset removes variables and reports missing ones", func(t *testing.T) {
+		err := runEnvUnset(envUnsetCmd, []string{"API_TOKEN", "MISSING"})
+		assert.Error(t, err)
+
+		env, err := environment.LoadEnvironment("work")
+		require.NoError(t, err)
+		assert.NotContains(t, env.EnvVars, "API_TOKEN")
+		assert.Empty(t, env.SecretEnvVars)
+		assert.Contains(t, env.EnvVars, "API_URL")
+	})
+}
+
+func TestRunEnvTrack(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+
+	envsDir := filepath.Join(tempHome, ".envswitch", "environments")
+	require.NoError(t, os.MkdirAll(envsDir, 0755))
+	createEnvWithVars(t, envsDir, "work", map[string]string{"API_URL": "https://api"})
+
+	envTargetEnv = "work"
+	defer func() { envTargetEnv = "" }()
+
+	output := captureStdout(t, func() { require.NoError(t, runEnvTrack(envTrackCmd, []string{"AWS_*", "AWS_PROFILE", "API_URL"})) })
+	assert.Contains(t, output, "tracking AWS_*")
+	assert.Contains(t, output, "API_URL is already tracked")
+
+	env, err := environment.LoadEnvironment("work")
+	require.NoError(t, err)
+	assert.Contains(t, env.EnvVars, "AWS_*")
+	assert.Equal(t, "https://api", env.EnvVars["API_URL"])
+
+	assert.Error(t, runEnvTrack(envTrackCmd, []string{"AWS_[A-"}))
+	assert.Error(t, runEnvTrack(envTrackCmd, []string{"BAD-KEY"}))
+
+	// Globs are not variables of their own
+	output = captureStdout(t, func() { require.NoError(t, runEnvList(envListCmd, nil)) })
+	assert.Equal(t, "API_URL=https://api\nAWS_PROFILE=\n", output)
+
+	require.NoError(t, runEnvUnset(envUnsetCmd, []string{"AWS_*"}))
+	env, err = environment.LoadEnvironment("work")
+	require.NoError(t, err)
+	assert.NotContains(t, env.EnvVars, "AWS_*")
+}
+
+func TestRunEnvImport(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+	keyring.MockInit() // secret values are encrypted with a key kept in the keyring
+
+	envsDir := filepath.Join(tempHome, ".envswitch", "environments")
+	require.NoError(t, os.MkdirAll(envsDir, 0755))
+	createEnvWithVars(t, envsDir, "work", map[string]string{"REGION": "us-east-1"})
+
+	envFile := filepath.Join(t.TempDir(), ".env")
+	require.NoError(t, os.WriteFile(envFile, []byte("export REGION=eu-west-1\nAPI_TOKEN='t0k3n'\n"), 0644))
+
+	envTargetEnv = "work"
+	defer func() { envTargetEnv, envImportSecret, envImportForce = "", false, false }()
+
+	t.Run("keeps existing variables without force", func(t *testing.T) {
+		envImportSecret = true
+		require.NoError(t, runEnvImport(envImportCmd, []string{envFile}))
+
+		env, err := environment.LoadEnvironment("work")
+		require.NoError(t, err)
+		assert.Equal(t, "us-east-1", env.EnvVars["REGION"])
+		value, _, err := env.GetEnvVar("API_TOKEN")
+		require.NoError(t, err)
+		assert.Equal(t, "t0k3n", value)
+		assert.Equal(t, []string{"API_TOKEN"}, env.SecretEnvVars)
+	})
+
+	t.Run("overwrites with force", func(t *testing.T) {
+		envImportSecret = false
+		envImportForce = true
+		require.NoError(t, runEnvImport(envImportCmd, []string{envFile}))
+
+		env, err := environment.LoadEnvironment("work")
+		require.NoError(t, err)
+		assert.Equal(t, "eu-west-1", env.EnvVars["REGION"])
+	})
+
+	t.Run("rejects files with invalid keys", func(t *testing.T) {
+		badFile := filepath.Join(t.TempDir(), "bad.env")
+		require.NoError(t, os.WriteFile(badFile, []byte("BAD-KEY=1\n"), 0644))
+		assert.Error(t, runEnvImport(envImportCmd, []string{badFile}))
+	})
+}
+
+func TestRunEnvExport(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+
+	envsDir := filepath.Join(tempHome, ".envswitch", "environments")
+	require.NoError(t, os.MkdirAll(envsDir, 0755))
+	createEnvWithVars(t, envsDir, "work", map[string]string{"API_URL": "https://work"})
+
+	defer func() { envTargetEnv, envExportShell, envExportPrev = "", "", "" }()
+
+	t.Run("only unsets previous variables without an active environment", func(t *testing.T) {
+		envExportShell = "bash"
+		envExportPrev = "OLD_VAR"
+
+		output := captureStdout(t, func() { require.NoError(t, runEnvExport(envExportCmd, nil)) })
+		assert.Equal(t, "unset OLD_VAR\n__ENVSWITCH_VARS=''\n", output)
+	})
+
+	t.Run("exports the variables of the active environment", func(t *testing.T) {
+		require.NoError(t, environment.SetCurrentEnvironment("work"))
+		envExportShell = "fish"
+		envExportPrev = "API_URL OLD_VAR"
+
+		output := captureStdout(t, func() { require.NoError(t, runEnvExport(envExportCmd, nil)) })
+		assert.Equal(t, "set -e OLD_VAR\nset -gx API_URL 'https://work'\nset -g __envswitch_vars API_URL\n", output)
+	})
+
+	t.Run("detects the shell from SHELL", func(t *testing.T) {
+		t.Setenv("SHELL", "/usr/bin/zsh")
+		assert.Equal(t, "zsh", detectShell())
+		t.Setenv("SHELL", "")
+		assert.Equal(t, "bash", detectShell())
+	})
+}
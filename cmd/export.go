@@ -6,11 +6,13 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/hugofrely/envswitch/internal/archive"
+	"github.com/hugofrely/envswitch/pkg/environment"
 )
 
 var (
 	exportOutput string
 	exportAll    bool
+	exportTags   []string
 )
 
 var exportCmd = &cobra.Command{
@@ -34,9 +36,15 @@ Examples:
   # Export all environments
   envswitch export --all --output all-envs/
 
+  # Export every environment of a group
+  envswitch export 'client-a/*' --output client-a/
+
+  # Export the environments with a tag
+  envswitch export --tag client=acme --output acme/
+
   # Export to current directory (default)
   envswitch export work`,
-	ValidArgsFunction: completeEnvironmentNames,
+	ValidArgsFunction: completeEnvironmentNameArgs,
 	RunE:              runExport,
 }
 
@@ -44,6 +52,9 @@ func init() {
 	rootCmd.AddCommand(exportCmd)
 	exportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "Output path (file or directory)")
 	exportCmd.Flags().BoolVar(&exportAll, "all", false, "Export all environments")
+	exportCmd.Flags().StringArrayVar(&exportTags, "tag", nil, "Export the environments with this tag, or tag key (repeatable)")
+	_ = exportCmd.RegisterFlagCompletionFunc("tag", completeTags)
+	exportCmd.MarkFlagsMutuallyExclusive("all", "tag")
 }
 
 func runExport(cmd *cobra.Command, args []string) error {
@@ -52,6 +63,13 @@ func runExport(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("cannot specify environment names with --all flag")
 	}
 
+	if len(exportTags) > 0 {
+		if len(args) > 0 {
+			return fmt.Errorf("cannot specify environment names with --tag flag")
+		}
+		return exportTagged()
+	}
+
 	if !exportAll && len(args) == 0 {
 		return fmt.Errorf("must specify at least one environment name or use --all flag")
 	}
@@ -71,12 +89,19 @@ func runExport(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
+	// A pattern exports to a directory even when it matches one environment
+	single := len(args) == 1 && !environment.IsPattern(args[0])
+	args, err := environment.ExpandNames(args)
+	if err != nil {
+		return err
+	}
+
 	// Export single environment
-	if len(args) == 1 {
+	if single {
 		envName := args[0]
 		output := exportOutput
 		if output == "" {
-			output = fmt.Sprintf("%s-export.tar.gz", envName)
+			output = fmt.Sprintf("%s-export.tar.gz", environment.FlatName(envName))
 		}
 
 		if err := archive.ExportEnvironment(envName, output); err != nil {
@@ -100,3 +125,28 @@ func runExport(cmd *cobra.Command, args []string) error {
 	fmt.Printf("✅ %d environment(s) exported to: %s\n", len(args), output)
 	return nil
 }
+
+// exportTagged exports the environments matching every --tag filter to a
+// directory
+func exportTagged() error {
+	envs, err := tagFilteredEnvironments(exportTags)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(envs))
+	for _, env := range envs {
+		names = append(names, env.Name)
+	}
+
+	output := exportOutput
+	if output == "" {
+		output = "envswitch-export"
+	}
+
+	if err := archive.ExportEnvironments(names, output); err != nil {
+		return fmt.Errorf("failed to export environments: %w", err)
+	}
+
+	fmt.Printf("✅ %d environment(s) exported to: %s\n", len(names), output)
+	return nil
+}
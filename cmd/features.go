@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/hugofrely/envswitch/internal/config"
+	"github.com/hugofrely/envswitch/internal/features"
+)
+
+var featuresCmd = &cobra.Command{
+	Use:   "features",
+	Short: "List feature flags and deprecations",
+	Long: `List the feature flags of this version with their stage and whether they
+are on, followed by the deprecated commands, flags and settings and the
+version that removes them.
+
+Experimental features are off by default and may change between releases.
+Turn them on with 'envswitch features enable <name>', which writes the flag
+to the features section of config.yaml.
+
+Set ENVSWITCH_NO_DEPRECATION_WARNINGS=1 to silence deprecation warnings.
+
+Examples:
+  envswitch features
+  envswitch features enable <feature>
+  envswitch features disable <feature>`,
+	Args: cobra.NoArgs,
+	RunE: runFeatures,
+}
+
+var featuresEnableCmd = &cobra.Command{
+	Use:               "enable <feature>",
+	Short:             "Turn a feature on",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeFeatureNames,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setFeature(args[0], true)
+	},
+}
+
+var featuresDisableCmd = &cobra.Command{
+	Use:               "disable <feature>",
+	Short:             "Turn a feature off",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeFeatureNames,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setFeature(args[0], false)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(featuresCmd)
+	featuresCmd.AddCommand(featuresEnableCmd)
+	featuresCmd.AddCommand(featuresDisableCmd)
+}
+
+func runFeatures(cmd *cobra.Command, args []string) error {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	all := features.All()
+	if len(all) == 0 {
+		fmt.Println("No feature flags in this version")
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "FEATURE\tSTAGE\tSTATE\tDESCRIPTION")
+		for _, feature := range all {
+			state := "off"
+			if cfg.FeatureEnabled(feature.Name) {
+				state = "on"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", feature.Name, feature.Stage, state, feature.Description)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if deprecations := features.Deprecations(); len(deprecations) > 0 {
+		fmt.Println("\nDeprecations:")
+		for _, d := range deprecations {
+			fmt.Printf("  ⚠️  %s\n", d)
+		}
+	}
+
+	return nil
+}
+
+// setFeature turns a feature flag on or off in config.yaml
+func setFeature(name string, enabled bool) error {
+	cfg, err := config.LoadBaseConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if err := cfg.Set("features."+name, enabled); err != nil {
+		return err
+	}
+	if err := cfg.Save(); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	feature, _ := features.Lookup(name)
+	if enabled {
+		fmt.Printf("✅ Enabled %s\n", name)
+		if feature.Stage == features.StageExperimental {
+			fmt.Println("⚠️  This feature is experimental and may change in future releases")
+		}
+	} else {
+		fmt.Printf("✅ Disabled %s\n", name)
+	}
+	return nil
+}
+
+// completeFeatureNames completes the names of the feature flags
+func completeFeatureNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	var names []string
+	for _, feature := range features.All() {
+		names = append(names, feature.Name+"\t"+feature.Description)
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hugofrely/envswitch/internal/config"
+	"github.com/hugofrely/envswitch/internal/features"
+)
+
+func init() {
+	features.Register(features.Feature{
+		Name:        "cmd_test_experimental",
+		Description: "Feature used by the cmd tests",
+		Stage:       features.StageExperimental,
+	})
+}
+
+func TestFeaturesEnableDisable(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	out := captureStdout(t, func() {
+		require.NoError(t, setFeature("cmd_test_experimental", true))
+	})
+	assert.Contains(t, out, "✅ Enabled cmd_test_experimental")
+	assert.Contains(t, out, "experimental")
+
+	cfg, err := config.LoadConfig()
+	require.NoError(t, err)
+	assert.True(t, cfg.FeatureEnabled("cmd_test_experimental"))
+
+	out = captureStdout(t, func() {
+		require.NoError(t, runFeatures(featuresCmd, nil))
+	})
+	assert.Regexp(t, `cmd_test_experimental\s+experimental\s+on\s+Feature used by the cmd tests`, out)
+
+	captureStdout(t, func() {
+		require.NoError(t, setFeature("cmd_test_experimental", false))
+	})
+	cfg, err = config.LoadConfig()
+	require.NoError(t, err)
+	assert.False(t, cfg.FeatureEnabled("cmd_test_experimental"))
+	assert.Empty(t, cfg.Features)
+}
+
+func TestFeaturesEnableUnknown(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	err := setFeature("not_a_feature", true)
+	assert.ErrorContains(t, err, "unknown feature")
+}
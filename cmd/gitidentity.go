@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"github.com/hugofrely/envswitch/pkg/environment"
+	"github.com/hugofrely/envswitch/pkg/tools"
+)
+
+// collectGitIdentities returns the git identities of the environments mapped
+// to directories, for git_mode includeif. cleanup removes the snapshots
+// merged for them.
+func collectGitIdentities() ([]tools.GitIdentity, func()) {
+	var cleanups []func()
+	cleanup := func() {
+		for _, fn := range cleanups {
+			fn()
+		}
+	}
+
+	environments, err := environment.ListEnvironments()
+	if err != nil {
+		restoreLog.Warn("Failed to list environments for git identities: %v", err)
+		return nil, cleanup
+	}
+
+	var identities []tools.GitIdentity
+	for _, env := range environments {
+		if len(env.Git.Directories) == 0 {
+			continue
+		}
+		snapshotPath, snapshotCleanup, err := env.ResolveToolSnapshot("git")
+		if err != nil {
+			restoreLog.Warn("Failed to prepare the git snapshot of %s: %v", env.Name, err)
+			continue
+		}
+		cleanups = append(cleanups, snapshotCleanup)
+
+		identities = append(identities, tools.GitIdentity{
+			Environment:  env.Name,
+			Directories:  env.Git.Directories,
+			SnapshotPath: snapshotPath,
+			Includes:     env.Git.Includes,
+			UserName:     env.Git.UserName,
+			UserEmail:    env.Git.UserEmail,
+			SigningKey:   env.Git.SigningKey,
+		})
+	}
+	return identities, cleanup
+}
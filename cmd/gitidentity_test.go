@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollectGitIdentities(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("HOME", tempDir)
+
+	work := createTestEnv(t, tempDir, "work")
+	work.Git.Directories = []string{"~/work/"}
+	work.Git.UserEmail = "me@work.example.com"
+	require.NoError(t, work.Save())
+	createTestEnv(t, tempDir, "personal")
+
+	identities, cleanup := collectGitIdentities()
+	defer cleanup()
+
+	require.Len(t, identities, 1, "only environments mapped to directories have an identity")
+	assert.Equal(t, "work", identities[0].Environment)
+	assert.Equal(t, []string{"~/work/"}, identities[0].Directories)
+	assert.Equal(t, "me@work.example.com", identities[0].UserEmail)
+	assert.Equal(t, filepath.Join(work.Path, "snapshots", "git"), identities[0].SnapshotPath)
+}
@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/hugofrely/envswitch/internal/storage"
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+var hardenDryRun bool
+
+var hardenCmd = &cobra.Command{
+	Use:   "harden",
+	Short: "Restrict the permissions of ~/.envswitch to your user",
+	Long: `Remove group and other access from ~/.envswitch and everything in it.
+
+Snapshots hold credentials (cloud tokens, kubeconfigs, SSH keys), so the store
+should only be readable by you: directories are set to 0700 and files to 0600,
+keeping execute bits for the owner. Stores created by older versions of
+envswitch, or files copied in by hand, may be more open than that;
+'envswitch doctor' reports them.
+
+Examples:
+  envswitch harden
+  envswitch harden --dry-run`,
+	Args: cobra.NoArgs,
+	RunE: runHarden,
+}
+
+func init() {
+	rootCmd.AddCommand(hardenCmd)
+	hardenCmd.Flags().BoolVar(&hardenDryRun, "dry-run", false, "Show what would change without changing it")
+}
+
+func runHarden(cmd *cobra.Command, args []string) error {
+	envswitchDir, err := environment.GetEnvswitchDir()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(envswitchDir); err != nil {
+		return fmt.Errorf("envswitch is not initialized (run 'envswitch init'): %w", err)
+	}
+
+	var issues []storage.PermissionIssue
+	if hardenDryRun {
+		issues, err = storage.FindLoosePermissions(envswitchDir)
+	} else {
+		issues, err = storage.HardenPermissions(envswitchDir)
+	}
+	for _, issue := range issues {
+		fmt.Printf("  %s: %04o → %04o\n", displayStorePath(envswitchDir, issue.Path), issue.Mode, issue.Fixed)
+	}
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case len(issues) == 0:
+		fmt.Println("✅ Permissions of ~/.envswitch are already restricted to your user")
+	case hardenDryRun:
+		fmt.Printf("\n%d path(s) would be restricted (run without --dry-run to apply)\n", len(issues))
+	default:
+		fmt.Printf("\n✅ Restricted the permissions of %d path(s)\n", len(issues))
+	}
+	return nil
+}
+
+// displayStorePath shows a path of the store as ~/.envswitch/...
+func displayStorePath(envswitchDir, path string) string {
+	rel, err := filepath.Rel(envswitchDir, path)
+	if err != nil {
+		return path
+	}
+	if rel == "." {
+		return "~/.envswitch"
+	}
+	return filepath.Join("~/.envswitch", rel)
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// createLooseStore creates a ~/.envswitch with a world-readable snapshot
+func createLooseStore(t *testing.T) (envswitchDir, secret string) {
+	t.Helper()
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	envswitchDir = filepath.Join(home, ".envswitch")
+	snapshotDir := filepath.Join(envswitchDir, "environments", "work", "snapshots", "aws")
+	require.NoError(t, os.MkdirAll(snapshotDir, 0755))
+	require.NoError(t, os.Chmod(envswitchDir, 0755))
+	secret = filepath.Join(snapshotDir, "credentials")
+	require.NoError(t, os.WriteFile(secret, []byte("[default]\naws_secret_access_key=x\n"), 0644))
+	return envswitchDir, secret
+}
+
+func TestRunHarden(t *testing.T) {
+	envswitchDir, secret := createLooseStore(t)
+
+	t.Run("dry run changes nothing", func(t *testing.T) {
+		hardenDryRun = true
+		defer func() { hardenDryRun = false }()
+
+		out := captureStdout(t, func() {
+			require.NoError(t, runHarden(hardenCmd, nil))
+		})
+		assert.Contains(t, out, "~/.envswitch/environments/work/snapshots/aws/credentials: 0644 → 0600")
+		assert.Contains(t, out, "would be restricted")
+
+		info, err := os.Stat(secret)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+	})
+
+	t.Run("restricts the store", func(t *testing.T) {
+		out := captureStdout(t, func() {
+			require.NoError(t, runHarden(hardenCmd, nil))
+		})
+		assert.Contains(t, out, "✅ Restricted the permissions")
+
+		info, err := os.Stat(secret)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+		info, err = os.Stat(envswitchDir)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0700), info.Mode().Perm())
+	})
+
+	t.Run("nothing left to do", func(t *testing.T) {
+		out := captureStdout(t, func() {
+			require.NoError(t, runHarden(hardenCmd, nil))
+		})
+		assert.Contains(t, out, "already restricted")
+	})
+}
+
+func TestRunHardenNotInitialized(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	err := runHarden(hardenCmd, nil)
+	assert.ErrorContains(t, err, "not initialized")
+}
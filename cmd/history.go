@@ -2,6 +2,9 @@ package cmd
 
 import (
 	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
 
@@ -9,8 +12,12 @@ import (
 )
 
 var (
-	historyLimit int
-	historyAll   bool
+	historyLimit       int
+	historyAll         bool
+	historyEnv         string
+	historySince       string
+	historyFailedOnly  bool
+	historyShowTimings bool
 )
 
 var historyCmd = &cobra.Command{
@@ -31,6 +38,15 @@ Examples:
   # Show all history
   envswitch history --all
 
+  # Show the failed switches from or to prod of the last week
+  envswitch history --env prod --since 7d --failed-only
+
+  # Show the last switches as JSON
+  envswitch history --output json
+
+  # Show switch counts, durations and failure rates
+  envswitch history stats
+
   # Show detailed view of history
   envswitch history show
 
@@ -42,8 +58,25 @@ Examples:
 var historyShowCmd = &cobra.Command{
 	Use:   "show",
 	Short: "Show detailed history view",
-	Long:  `Show a detailed view of the switch history with all information.`,
-	RunE:  runHistoryShow,
+	Long: `Show a detailed view of the switch history with all information.
+
+Examples:
+  envswitch history show
+  envswitch history show --timings -n 5`,
+	RunE: runHistoryShow,
+}
+
+var historyStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Summarize switch counts, durations and failures",
+	Long: `Summarize the switch history: the number of switches per environment pair,
+their average duration and failure rate.
+
+Examples:
+  envswitch history stats
+  envswitch history stats --env prod --since 30d`,
+	Args: cobra.NoArgs,
+	RunE: runHistoryStats,
 }
 
 var historyClearCmd = &cobra.Command{
@@ -56,6 +89,7 @@ var historyClearCmd = &cobra.Command{
 func init() {
 	rootCmd.AddCommand(historyCmd)
 	historyCmd.AddCommand(historyShowCmd)
+	historyCmd.AddCommand(historyStatsCmd)
 	historyCmd.AddCommand(historyClearCmd)
 
 	// Add flags to main command
@@ -65,32 +99,118 @@ func init() {
 	// Add flags to show subcommand
 	historyShowCmd.Flags().IntVarP(&historyLimit, "limit", "n", 10, "Number of entries to show")
 	historyShowCmd.Flags().BoolVar(&historyAll, "all", false, "Show all history entries")
+	historyShowCmd.Flags().BoolVar(&historyShowTimings, "timings", false, "Show how long each phase and tool of the switches took")
+
+	// Filters, shared by the listing subcommands
+	for _, c := range []*cobra.Command{historyCmd, historyShowCmd, historyStatsCmd} {
+		c.Flags().StringVar(&historyEnv, "env", "", "Only switches from or to this environment")
+		c.Flags().StringVar(&historySince, "since", "", "Only switches in this period (e.g. 7d, 2w, 12h) or since a date (2006-01-02)")
+		_ = c.RegisterFlagCompletionFunc("env", completeEnvironmentFlag)
+	}
+	historyCmd.Flags().BoolVar(&historyFailedOnly, "failed-only", false, "Only failed switches")
+	historyShowCmd.Flags().BoolVar(&historyFailedOnly, "failed-only", false, "Only failed switches")
 }
 
-func runHistory(cmd *cobra.Command, args []string) error {
+// historyFilter returns the filter set by the --env, --since and
+// --failed-only flags
+func historyFilter() (history.Filter, error) {
+	filter := history.Filter{Env: historyEnv, FailedOnly: historyFailedOnly}
+	if historySince == "" {
+		return filter, nil
+	}
+
+	if date, err := time.ParseInLocation("2006-01-02", historySince, time.Local); err == nil {
+		filter.Since = date
+		return filter, nil
+	}
+	age, err := parseAge(historySince)
+	if err != nil {
+		return filter, fmt.Errorf("invalid --since: %w", err)
+	}
+	filter.Since = time.Now().Add(-age)
+	return filter, nil
+}
+
+// historyEntries returns the last limit entries matching the filter flags,
+// all of them when limit is 0, with the number of matching entries and of
+// entries in the whole history
+func historyEntries(limit int) ([]history.SwitchEntry, int, int, error) {
+	filter, err := historyFilter()
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	// The latest entries are read from the history index
+	if filter.IsEmpty() && limit > 0 {
+		entries, total, err := history.LoadRecent(limit)
+		if err != nil {
+			return nil, 0, 0, fmt.Errorf("failed to load history: %w", err)
+		}
+		return entries, total, total, nil
+	}
+
 	hist, err := history.LoadHistory()
 	if err != nil {
-		return fmt.Errorf("failed to load history: %w", err)
+		return nil, 0, 0, fmt.Errorf("failed to load history: %w", err)
+	}
+	matches := &history.History{Entries: hist.Search(filter)}
+	if limit == 0 {
+		limit = len(matches.Entries)
+	}
+	return matches.GetLast(limit), len(matches.Entries), len(hist.Entries), nil
+}
+
+// historyListing is the structured form of the history, most recent switch
+// first
+type historyListing struct {
+	Total   int                   `json:"total" yaml:"total"`
+	Matched int                   `json:"matched" yaml:"matched"`
+	Entries []history.SwitchEntry `json:"entries" yaml:"entries"`
+}
+
+func newHistoryListing(entries []history.SwitchEntry, matched, total int) historyListing {
+	listing := historyListing{Total: total, Matched: matched, Entries: make([]history.SwitchEntry, 0, len(entries))}
+	for i := len(entries) - 1; i >= 0; i-- {
+		listing.Entries = append(listing.Entries, entries[i])
+	}
+	return listing
+}
+
+// historyListLimit returns the number of entries to list
+func historyListLimit() int {
+	if historyAll {
+		return 0
+	}
+	return historyLimit
+}
+
+func runHistory(cmd *cobra.Command, args []string) error {
+	entries, matched, total, err := historyEntries(historyListLimit())
+	if err != nil {
+		return err
+	}
+	out, err := resultWriter()
+	if err != nil {
+		return err
+	}
+	if out.Structured() {
+		return out.Write(newHistoryListing(entries, matched, total))
 	}
 
-	if len(hist.Entries) == 0 {
+	if total == 0 {
 		fmt.Println("No switch history found.")
 		fmt.Println()
 		fmt.Println("Switch between environments to build your history:")
 		fmt.Println("  envswitch switch <environment>")
 		return nil
 	}
-
-	// Determine how many entries to show
-	limit := historyLimit
-	if historyAll {
-		limit = len(hist.Entries)
+	if matched == 0 {
+		fmt.Println("No switches match the filters.")
+		return nil
 	}
 
-	entries := hist.GetLast(limit)
-
 	// Display header
-	fmt.Printf("Switch History (showing %d of %d):\n", len(entries), len(hist.Entries))
+	fmt.Printf("Switch History (showing %d of %d):\n", len(entries), matched)
 	fmt.Println()
 
 	// Display entries in reverse order (most recent first)
@@ -99,33 +219,36 @@ func runHistory(cmd *cobra.Command, args []string) error {
 		displayHistoryEntry(&entry, false)
 	}
 
-	if !historyAll && len(hist.Entries) > historyLimit {
-		fmt.Printf("\nShowing last %d entries. Use --all to see all %d entries.\n", historyLimit, len(hist.Entries))
+	if !historyAll && matched > historyLimit {
+		fmt.Printf("\nShowing last %d entries. Use --all to see all %d entries.\n", historyLimit, matched)
 	}
 
 	return nil
 }
 
 func runHistoryShow(cmd *cobra.Command, args []string) error {
-	hist, err := history.LoadHistory()
+	entries, matched, total, err := historyEntries(historyListLimit())
 	if err != nil {
-		return fmt.Errorf("failed to load history: %w", err)
+		return err
+	}
+	out, err := resultWriter()
+	if err != nil {
+		return err
+	}
+	if out.Structured() {
+		return out.Write(newHistoryListing(entries, matched, total))
 	}
 
-	if len(hist.Entries) == 0 {
+	if total == 0 {
 		fmt.Println("No switch history found.")
 		return nil
 	}
-
-	// Determine how many entries to show
-	limit := historyLimit
-	if historyAll {
-		limit = len(hist.Entries)
+	if matched == 0 {
+		fmt.Println("No switches match the filters.")
+		return nil
 	}
 
-	entries := hist.GetLast(limit)
-
-	fmt.Printf("Detailed Switch History (showing %d of %d):\n", len(entries), len(hist.Entries))
+	fmt.Printf("Detailed Switch History (showing %d of %d):\n", len(entries), matched)
 	fmt.Println()
 
 	// Display entries in reverse order (most recent first)
@@ -140,6 +263,33 @@ func runHistoryShow(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+func runHistoryStats(cmd *cobra.Command, args []string) error {
+	entries, _, _, err := historyEntries(0)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		fmt.Println("No switches to summarize.")
+		return nil
+	}
+
+	stats := history.ComputeStats(entries)
+	fmt.Println("Switch Statistics:")
+	fmt.Println()
+	fmt.Printf("Switches:     %d\n", stats.Total.Count)
+	fmt.Printf("Failures:     %d (%.1f%%)\n", stats.Total.Failures, stats.Total.FailureRate()*100)
+	fmt.Printf("Avg duration: %s\n", formatDuration(stats.Total.AverageDurationMs()))
+	fmt.Println()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SWITCH\tCOUNT\tFAILED\tFAILURE RATE\tAVG DURATION")
+	for _, pair := range stats.Pairs {
+		fmt.Fprintf(w, "%s → %s\t%d\t%d\t%.1f%%\t%s\n", pair.From, pair.To, pair.Count, pair.Failures,
+			pair.FailureRate()*100, formatDuration(pair.AverageDurationMs()))
+	}
+	return w.Flush()
+}
+
 func runHistoryClear(cmd *cobra.Command, args []string) error {
 	hist := &history.History{
 		Entries: []history.SwitchEntry{},
@@ -169,26 +319,48 @@ func displayHistoryEntry(entry *history.SwitchEntry, detailed bool) {
 	if detailed {
 		// Detailed view
 		fmt.Printf("─────────────────────────────────────────────────────\n")
+		fmt.Printf("ID:       %d\n", entry.ID)
 		fmt.Printf("Time:     %s\n", timestamp)
 		fmt.Printf("Switch:   %s → %s\n", entry.From, entry.To)
+		if entry.Rollback {
+			fmt.Printf("Type:     rollback\n")
+		}
 		fmt.Printf("Status:   %s %s\n", status, getStatusText(entry.Success))
 		fmt.Printf("Duration: %s\n", duration)
 
 		if entry.ToolsCount > 0 {
 			fmt.Printf("Tools:    %d tool(s) restored\n", entry.ToolsCount)
 		}
+		if filter := (toolFilter{Only: entry.OnlyTools, Skip: entry.SkipTools}); !filter.isEmpty() {
+			fmt.Printf("Filter:   %s\n", filter)
+		}
 
 		if entry.BackupPath != "" {
 			fmt.Printf("Backup:   %s\n", entry.BackupPath)
 		}
+		if entry.LogFile != "" {
+			fmt.Printf("Log:      %s\n", entry.LogFile)
+		}
 
 		if entry.ErrorMsg != "" {
 			fmt.Printf("Error:    %s\n", entry.ErrorMsg)
 		}
+
+		if historyShowTimings && len(entry.Timings) > 0 {
+			fmt.Println("Timings:")
+			_ = printTimings(os.Stdout, entry.Timings, entry.DurationMs)
+		}
 	} else {
 		// Compact view
 		fromTo := fmt.Sprintf("%s → %s", entry.From, entry.To)
-		fmt.Printf("%s %s  %-30s  %s", status, timestamp, fromTo, duration)
+		fmt.Printf("%s #%-4d %s  %-30s  %s", status, entry.ID, timestamp, fromTo, duration)
+
+		if entry.Rollback {
+			fmt.Print(" (rollback)")
+		}
+		if filter := (toolFilter{Only: entry.OnlyTools, Skip: entry.SkipTools}); !filter.isEmpty() {
+			fmt.Printf(" (%s)", filter)
+		}
 
 		if entry.ErrorMsg != "" {
 			fmt.Printf(" (error: %s)", truncateString(entry.ErrorMsg, 40))
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"encoding/json"
 	"os"
 	"path/filepath"
 	"testing"
@@ -320,3 +321,110 @@ func TestHistoryWithMetadata(t *testing.T) {
 	err := rootCmd.Execute()
 	assert.NoError(t, err)
 }
+
+func TestHistoryFilter(t *testing.T) {
+	defer func() {
+		historyEnv = ""
+		historySince = ""
+		historyFailedOnly = false
+	}()
+
+	historyEnv = "prod"
+	historyFailedOnly = true
+	filter, err := historyFilter()
+	require.NoError(t, err)
+	assert.Equal(t, "prod", filter.Env)
+	assert.True(t, filter.FailedOnly)
+	assert.True(t, filter.Since.IsZero())
+
+	historySince = "7d"
+	filter, err = historyFilter()
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(-7*24*time.Hour), filter.Since, time.Minute)
+
+	historySince = "2024-01-15"
+	filter, err = historyFilter()
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 1, 15, 0, 0, 0, 0, time.Local), filter.Since)
+
+	historySince = "yesterday"
+	_, err = historyFilter()
+	assert.Error(t, err)
+}
+
+func TestRunHistoryFiltered(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+	require.NoError(t, os.MkdirAll(filepath.Join(tempHome, ".envswitch"), 0755))
+
+	now := time.Now()
+	hist := &history.History{}
+	for _, entry := range []history.SwitchEntry{
+		{Timestamp: now.Add(-10 * 24 * time.Hour), From: "dev", To: "prod", Success: false, ErrorMsg: "old failure", DurationMs: 3000},
+		{Timestamp: now.Add(-time.Hour), From: "prod", To: "dev", Success: true, DurationMs: 1000},
+		{Timestamp: now.Add(-30 * time.Minute), From: "dev", To: "prod", Success: false, ErrorMsg: "recent failure", DurationMs: 2000},
+		{Timestamp: now, From: "dev", To: "staging", Success: true, DurationMs: 500},
+	} {
+		entry := entry
+		require.NoError(t, hist.AddEntry(&entry))
+	}
+
+	defer func() {
+		historyEnv = ""
+		historySince = ""
+		historyFailedOnly = false
+	}()
+
+	historyEnv = "prod"
+	historySince = "7d"
+	historyFailedOnly = true
+	output := captureStdout(t, func() {
+		require.NoError(t, runHistory(historyCmd, nil))
+	})
+	assert.Contains(t, output, "showing 1 of 1")
+	assert.Contains(t, output, "recent failure")
+	assert.NotContains(t, output, "old failure")
+
+	historyEnv = "qa"
+	output = captureStdout(t, func() {
+		require.NoError(t, runHistory(historyCmd, nil))
+	})
+	assert.Contains(t, output, "No switches match the filters.")
+
+	historyEnv = ""
+	historySince = ""
+	historyFailedOnly = false
+	output = captureStdout(t, func() {
+		require.NoError(t, runHistoryStats(historyStatsCmd, nil))
+	})
+	assert.Contains(t, output, "Switches:     4")
+	assert.Contains(t, output, "Failures:     2 (50.0%)")
+	assert.Contains(t, output, "Avg duration: 1.62s")
+	assert.Contains(t, output, "dev → prod")
+	assert.Contains(t, output, "100.0%")
+	assert.Contains(t, output, "dev → staging")
+}
+
+func TestRunHistoryStructured(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+	require.NoError(t, os.MkdirAll(filepath.Join(tempHome, ".envswitch"), 0755))
+
+	hist := &history.History{}
+	for _, to := range []string{"dev", "prod"} {
+		require.NoError(t, hist.AddEntry(&history.SwitchEntry{Timestamp: time.Now(), From: "home", To: to, Success: true, ToolsCount: 3}))
+	}
+	setOutputFormat(t, "json")
+
+	out := captureStdout(t, func() {
+		require.NoError(t, runHistory(historyCmd, nil))
+	})
+
+	var listing historyListing
+	require.NoError(t, json.Unmarshal([]byte(out), &listing))
+	assert.Equal(t, 2, listing.Total)
+	assert.Equal(t, 2, listing.Matched)
+	require.Len(t, listing.Entries, 2)
+	assert.Equal(t, "prod", listing.Entries[0].To, "most recent first")
+	assert.Equal(t, 3, listing.Entries[0].ToolsCount)
+}
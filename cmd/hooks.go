@@ -0,0 +1,615 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/hugofrely/envswitch/internal/config"
+	"github.com/hugofrely/envswitch/internal/hooks"
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+var (
+	hooksTemplateEnv   string
+	hooksTemplateSet   []string
+	hooksTemplateEvent string
+
+	hooksAddEvent       string
+	hooksAddCommand     string
+	hooksAddAction      string
+	hooksAddWith        []string
+	hooksAddArgs        []string
+	hooksAddDescription string
+	hooksAddTimeout     string
+	hooksAddCwd         string
+	hooksAddVars        []string
+	hooksAddOnFailure   string
+	hooksAddVerify      bool
+
+	hooksTestEvent string
+	hooksTestRun   bool
+)
+
+var hooksCmd = &cobra.Command{
+	Use:   "hooks",
+	Short: "Manage environment hooks",
+	Long: `Manage the commands an environment runs before or after switching and snapshotting.
+
+Examples:
+  envswitch hooks list work
+  envswitch hooks add work --event post_switch --command "kubectl get nodes"
+  envswitch hooks remove work post_switch 1
+  envswitch hooks test work`,
+}
+
+var hooksListCmd = &cobra.Command{
+	Use:   "list [env]",
+	Short: "List the hooks of an environment",
+	Long: `List the hooks of the active environment or of env, numbered per event,
+followed by the global hooks of config.yaml that run for every environment.
+
+Examples:
+  envswitch hooks list
+  envswitch hooks list work`,
+	Aliases:           []string{"ls"},
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeEnvironmentNames,
+	RunE:              runHooksList,
+}
+
+var hooksAddCmd = &cobra.Command{
+	Use:   "add [env]",
+	Short: "Add a hook to an environment",
+	Long: `Add a hook to the active environment or to env.
+
+Without --command nor --action you are prompted for the event, the command
+and a description.
+
+--action adds a built-in action instead of a shell command, with its
+parameters given with --with: see 'envswitch hooks actions'.
+
+Examples:
+  # Prompt for the hook
+  envswitch hooks add work
+
+  # Add it in one go
+  envswitch hooks add work --event post_switch --command "kubectl get nodes" \
+      --description "Check cluster access" --timeout 30s --on-failure warn
+
+  # Pass variables to the hook
+  envswitch hooks add --event pre_switch --command ./check-vpn.sh \
+      --cwd ~/code/infra --var VPN_NAME=corp
+
+  # Built-in actions
+  envswitch hooks add work --action notify --with message="Now on {env}"
+  envswitch hooks add work --action run-make --with target=login --cwd ~/code/infra
+  envswitch hooks add work --action exec --arg kubectl --arg get --arg nodes`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeEnvironmentNames,
+	RunE:              runHooksAdd,
+}
+
+var hooksRemoveCmd = &cobra.Command{
+	Use:   "remove [env] <event> <number>",
+	Short: "Remove a hook from an environment",
+	Long: `Remove a hook from the active environment or from env. Hooks are numbered
+per event, as shown by 'envswitch hooks list'.
+
+Examples:
+  envswitch hooks remove post_switch 1
+  envswitch hooks remove work pre_switch 2`,
+	Aliases:           []string{"rm"},
+	Args:              cobra.RangeArgs(2, 3),
+	ValidArgsFunction: completeHooksRemoveArgs,
+	RunE:              runHooksRemove,
+}
+
+var hooksTestCmd = &cobra.Command{
+	Use:   "test [env]",
+	Short: "Show what the hooks of an environment would run",
+	Long: `Show what switching to the active environment or to env would run: the
+pre-switch and post-switch hooks, global ones included, in order, with their
+working directory, timeout, variables and failure policy. Nothing is run
+unless --run is given.
+
+Examples:
+  envswitch hooks test work
+  envswitch hooks test work --event pre_snapshot
+  envswitch hooks test work --run`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeEnvironmentNames,
+	RunE:              runHooksTest,
+}
+
+var hooksActionsCmd = &cobra.Command{
+	Use:   "actions",
+	Short: "List the built-in hook actions",
+	Long: `List the built-in actions a hook can run instead of a shell command, with
+their parameters. Actions run their program directly, without a shell, and
+their parameters are checked when the hook is added or loaded.
+
+In metadata.yaml or config.yaml:
+
+  post_switch:
+    - action: notify
+      with:
+        message: "Switched to {env}"
+    - action: exec
+      args: [kubectl, config, use-context, prod]`,
+	Args: cobra.NoArgs,
+	RunE: runHooksActions,
+}
+
+var hooksTemplatesCmd = &cobra.Command{
+	Use:   "templates",
+	Short: "List the hook templates",
+	Long: `List the ready-made hooks that 'envswitch hooks add-template' can add to
+an environment, with the parameters each one asks for.`,
+	Args: cobra.NoArgs,
+	RunE: runHooksTemplates,
+}
+
+var hooksAddTemplateCmd = &cobra.Command{
+	Use:   "add-template <template>",
+	Short: "Add a ready-made hook to an environment",
+	Long: `Add a ready-made hook to the active environment or a specific one.
+
+You are prompted for each parameter of the template; pass --set to provide
+them up front (for example in scripts). Run 'envswitch hooks templates' to see
+the available templates.
+
+Examples:
+  # Connect to the VPN after switching to work
+  envswitch hooks add-template vpn-switch --env work
+
+  # Announce switches in Slack without prompts
+  envswitch hooks add-template slack-notify \
+      --set webhook_url=https://hooks.slack.com/services/T000/B000/XXX
+
+  # Log in to a registry, reading the token from a secret variable
+  envswitch env set GHCR_TOKEN=ghp_xxx --secret
+  envswitch hooks add-template docker-login \
+      --set registry=ghcr.io --set username=me --set password_var=GHCR_TOKEN`,
+	Args:      cobra.ExactArgs(1),
+	ValidArgs: hooks.TemplateNames(),
+	RunE:      runHooksAddTemplate,
+}
+
+func init() {
+	rootCmd.AddCommand(hooksCmd)
+	hooksCmd.AddCommand(hooksListCmd)
+	hooksCmd.AddCommand(hooksAddCmd)
+	hooksCmd.AddCommand(hooksRemoveCmd)
+	hooksCmd.AddCommand(hooksTestCmd)
+	hooksCmd.AddCommand(hooksActionsCmd)
+	hooksCmd.AddCommand(hooksTemplatesCmd)
+	hooksCmd.AddCommand(hooksAddTemplateCmd)
+
+	hooksAddCmd.Flags().StringVar(&hooksAddEvent, "event", "", "Event to run the hook on (default: post_switch)")
+	hooksAddCmd.Flags().StringVar(&hooksAddCommand, "command", "", "Shell command to run (prompted when omitted)")
+	hooksAddCmd.Flags().StringVar(&hooksAddAction, "action", "", "Built-in action to run instead of a command (see 'envswitch hooks actions')")
+	hooksAddCmd.Flags().StringArrayVar(&hooksAddWith, "with", nil, "Parameter of the action as name=value (repeatable)")
+	hooksAddCmd.Flags().StringArrayVar(&hooksAddArgs, "arg", nil, "Program, then arguments, of the exec action (repeatable)")
+	hooksAddCmd.Flags().StringVar(&hooksAddDescription, "description", "", "Description shown when the hook runs")
+	hooksAddCmd.Flags().StringVar(&hooksAddTimeout, "timeout", "", "Kill the hook after this duration, e.g. 30s")
+	hooksAddCmd.Flags().StringVar(&hooksAddCwd, "cwd", "", "Working directory of the hook")
+	hooksAddCmd.Flags().StringArrayVar(&hooksAddVars, "var", nil, "Variable for the hook as KEY=value (repeatable)")
+	hooksAddCmd.Flags().StringVar(&hooksAddOnFailure, "on-failure", "", "What to do when the hook fails: abort, warn or ignore (default: abort)")
+	hooksAddCmd.Flags().BoolVar(&hooksAddVerify, "verify", false, "Report the hook as a verification")
+	_ = hooksAddCmd.RegisterFlagCompletionFunc("event", completeHookEvents)
+	_ = hooksAddCmd.RegisterFlagCompletionFunc("action", cobra.FixedCompletions(environment.HookActionNames(), cobra.ShellCompDirectiveNoFileComp))
+	_ = hooksAddCmd.RegisterFlagCompletionFunc("on-failure", cobra.FixedCompletions(
+		[]string{environment.HookFailAbort, environment.HookFailWarn, environment.HookFailIgnore}, cobra.ShellCompDirectiveNoFileComp))
+
+	hooksTestCmd.Flags().StringVar(&hooksTestEvent, "event", "", "Only this event (default: pre_switch and post_switch)")
+	hooksTestCmd.Flags().BoolVar(&hooksTestRun, "run", false, "Run the hooks instead of showing them")
+	_ = hooksTestCmd.RegisterFlagCompletionFunc("event", completeHookEvents)
+
+	hooksAddTemplateCmd.Flags().StringVar(&hooksTemplateEnv, "env", "", "Environment to update (default: active environment)")
+	hooksAddTemplateCmd.Flags().StringArrayVar(&hooksTemplateSet, "set", nil, "Template parameter as name=value (repeatable)")
+	hooksAddTemplateCmd.Flags().StringVar(&hooksTemplateEvent, "event", "", "Event to run the hook on (default: the template's event)")
+	_ = hooksAddTemplateCmd.RegisterFlagCompletionFunc("env", completeEnvironmentFlag)
+}
+
+// hooksTargetEnv returns the environment named by the optional first
+// argument of a hooks subcommand, the active environment by default
+func hooksTargetEnv(args []string, withName int) (*environment.Environment, error) {
+	if len(args) == withName {
+		return resolveEnvTarget(args[0])
+	}
+
+	current, err := environment.GetCurrentEnvironment()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current environment: %w", err)
+	}
+	if current == nil {
+		return nil, fmt.Errorf("no active environment. Pass the environment name")
+	}
+	return current, nil
+}
+
+func runHooksList(cmd *cobra.Command, args []string) error {
+	env, err := hooksTargetEnv(args, 1)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	fmt.Printf("Hooks of '%s':\n", env.Name)
+	if !printHooks(&env.Hooks) {
+		fmt.Println("  (none)")
+	}
+
+	global := environment.Hooks{PreSwitch: cfg.Hooks.PreSwitch, PostSwitch: cfg.Hooks.PostSwitch}
+	if len(global.PreSwitch)+len(global.PostSwitch) > 0 {
+		fmt.Println("\nGlobal hooks (config.yaml):")
+		printHooks(&global)
+	}
+	return nil
+}
+
+// printHooks lists hooks per event, numbered as 'hooks remove' expects, and
+// reports whether there were any
+func printHooks(h *environment.Hooks) bool {
+	found := false
+	for _, event := range environment.HookEvents {
+		list, _ := h.ForEvent(event)
+		if len(*list) == 0 {
+			continue
+		}
+		found = true
+
+		fmt.Printf("  %s\n", event)
+		for i, hook := range *list {
+			action := hook.Command
+			switch {
+			case hook.Action != "":
+				action = "action: " + hooks.DescribeAction(hook)
+			case action == "":
+				action = strings.ReplaceAll(hook.Script, "\n", "; ")
+			}
+			fmt.Printf("    %d. %s", i+1, action)
+			if hook.Description != "" {
+				fmt.Printf("  # %s", hook.Description)
+			}
+			fmt.Println()
+			if options := hookOptions(hookDetails{Timeout: hook.Timeout, Cwd: hook.Cwd, Env: hook.Env, OnFailure: hook.OnFailure}); options != "" {
+				fmt.Printf("       (%s)\n", options)
+			}
+		}
+	}
+	return found
+}
+
+func runHooksAdd(cmd *cobra.Command, args []string) error {
+	env, err := hooksTargetEnv(args, 1)
+	if err != nil {
+		return err
+	}
+
+	hook := environment.Hook{
+		Command:     hooksAddCommand,
+		Action:      hooksAddAction,
+		Args:        hooksAddArgs,
+		Description: hooksAddDescription,
+		Verify:      hooksAddVerify,
+		Timeout:     hooksAddTimeout,
+		Cwd:         hooksAddCwd,
+		OnFailure:   hooksAddOnFailure,
+	}
+	for _, assignment := range hooksAddVars {
+		key, value, ok := strings.Cut(assignment, "=")
+		if !ok {
+			return fmt.Errorf("invalid --var '%s': expected KEY=value", assignment)
+		}
+		if hook.Env == nil {
+			hook.Env = make(map[string]string)
+		}
+		hook.Env[key] = value
+	}
+	for _, assignment := range hooksAddWith {
+		name, value, ok := strings.Cut(assignment, "=")
+		if !ok {
+			return fmt.Errorf("invalid --with '%s': expected name=value", assignment)
+		}
+		if hook.With == nil {
+			hook.With = make(map[string]string)
+		}
+		hook.With[name] = value
+	}
+
+	event := hooksAddEvent
+	if hook.Command == "" && hook.Action == "" {
+		if err := promptHook(&hook, &event, os.Stdin); err != nil {
+			return err
+		}
+	}
+	if event == "" {
+		event = environment.HookPostSwitch
+	}
+
+	if err := hook.Validate(); err != nil {
+		return err
+	}
+
+	added, err := env.Hooks.AddHook(event, hook)
+	if err != nil {
+		return err
+	}
+	if !added {
+		fmt.Printf("This hook is already in %s hooks of '%s'\n", event, env.Name)
+		return nil
+	}
+
+	if err := env.Save(); err != nil {
+		return fmt.Errorf("failed to save environment: %w", err)
+	}
+
+	list, _ := env.Hooks.ForEvent(event)
+	fmt.Printf("✅ Added %s hook #%d to '%s'\n", event, len(*list), env.Name)
+	return nil
+}
+
+// promptHook asks for the event, command and description of a hook. The
+// event prompt is skipped when --event was given.
+func promptHook(hook *environment.Hook, event *string, in io.Reader) error {
+	reader := bufio.NewReader(in)
+	ask := func(prompt string) string {
+		fmt.Print(prompt)
+		line, _ := reader.ReadString('\n')
+		return strings.TrimSpace(line)
+	}
+
+	if *event == "" {
+		*event = ask(fmt.Sprintf("Event (%s) [%s]: ", strings.Join(environment.HookEvents, ", "), environment.HookPostSwitch))
+	}
+	hook.Command = ask("Command: ")
+	if hook.Command == "" {
+		return fmt.Errorf("a command is required (use --command)")
+	}
+	if hook.Description == "" {
+		hook.Description = ask("Description (optional): ")
+	}
+	return nil
+}
+
+func runHooksRemove(cmd *cobra.Command, args []string) error {
+	env, err := hooksTargetEnv(args, 3)
+	if err != nil {
+		return err
+	}
+
+	event, numberArg := args[len(args)-2], args[len(args)-1]
+	number, err := strconv.Atoi(numberArg)
+	if err != nil {
+		return fmt.Errorf("invalid hook number '%s'", numberArg)
+	}
+
+	removed, err := env.Hooks.RemoveHook(event, number)
+	if err != nil {
+		return err
+	}
+
+	if err := env.Save(); err != nil {
+		return fmt.Errorf("failed to save environment: %w", err)
+	}
+
+	description := removed.Description
+	if description == "" {
+		description = removed.Command
+	}
+	if description == "" && removed.Action != "" {
+		description = hooks.DescribeAction(removed)
+	}
+	fmt.Printf("✅ Removed %s hook #%d from '%s'", event, number, env.Name)
+	if description != "" {
+		fmt.Printf(": %s", description)
+	}
+	fmt.Println()
+	return nil
+}
+
+func runHooksTest(cmd *cobra.Command, args []string) error {
+	env, err := hooksTargetEnv(args, 1)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	// Hooks in the order a switch runs them, global ones included
+	events := []string{environment.HookPreSwitch, environment.HookPostSwitch}
+	if hooksTestEvent != "" {
+		events = []string{hooksTestEvent}
+	}
+
+	for _, event := range events {
+		var list []environment.Hook
+		switch event {
+		case environment.HookPreSwitch:
+			list = preSwitchHooks(env, cfg)
+		case environment.HookPostSwitch:
+			list = postSwitchHooks(env, cfg)
+		default:
+			eventHooks, err := env.Hooks.ForEvent(event)
+			if err != nil {
+				return err
+			}
+			list = *eventHooks
+		}
+
+		fmt.Printf("%s:\n", event)
+		if len(list) == 0 {
+			fmt.Println("  (none)")
+			continue
+		}
+
+		if hooksTestRun {
+			err = hooks.ExecuteHooks(list, env.Name)
+		} else {
+			err = hooks.DryRunHooks(os.Stdout, list, env.Name)
+		}
+		if err != nil {
+			return fmt.Errorf("%s hooks: %w", event, err)
+		}
+	}
+
+	if !hooksTestRun {
+		fmt.Println("\nNothing was run (use --run to run the hooks)")
+	}
+	return nil
+}
+
+// completeHookEvents completes the hook event names
+func completeHookEvents(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return environment.HookEvents, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeHooksRemoveArgs completes the optional environment, then the event
+func completeHooksRemoveArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	switch len(args) {
+	case 0:
+		names, directive := completeEnvironmentNames(cmd, args, toComplete)
+		return append(names, environment.HookEvents...), directive
+	case 1:
+		for _, event := range environment.HookEvents {
+			if args[0] == event {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+		}
+		return environment.HookEvents, cobra.ShellCompDirectiveNoFileComp
+	default:
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+func runHooksActions(cmd *cobra.Command, args []string) error {
+	for _, action := range environment.HookActions() {
+		fmt.Printf("%s\n", action.Name)
+		fmt.Printf("  %s\n", action.Summary)
+		if action.Args {
+			fmt.Printf("    args: program and arguments to run\n")
+		}
+		for _, param := range action.Params {
+			switch {
+			case param.Required:
+				fmt.Printf("    %s: %s (required)\n", param.Name, param.Description)
+			case param.Default != "":
+				fmt.Printf("    %s: %s (default: %s)\n", param.Name, param.Description, param.Default)
+			default:
+				fmt.Printf("    %s: %s\n", param.Name, param.Description)
+			}
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+func runHooksTemplates(cmd *cobra.Command, args []string) error {
+	for _, tmpl := range hooks.Templates() {
+		fmt.Printf("%s (%s)\n", tmpl.Name, tmpl.Event)
+		fmt.Printf("  %s\n", tmpl.Summary)
+		for _, param := range tmpl.Params {
+			if param.Default != "" {
+				fmt.Printf("    %s: %s (default: %s)\n", param.Name, param.Prompt, param.Default)
+			} else {
+				fmt.Printf("    %s: %s\n", param.Name, param.Prompt)
+			}
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+func runHooksAddTemplate(cmd *cobra.Command, args []string) error {
+	tmpl, err := hooks.LookupTemplate(args[0])
+	if err != nil {
+		return err
+	}
+
+	values := make(map[string]string)
+	for _, assignment := range hooksTemplateSet {
+		name, value, ok := strings.Cut(assignment, "=")
+		if !ok {
+			return fmt.Errorf("invalid --set '%s': expected name=value", assignment)
+		}
+		values[name] = value
+	}
+
+	env, err := resolveEnvTarget(hooksTemplateEnv)
+	if err != nil {
+		return err
+	}
+
+	if err := promptTemplateParams(tmpl, values, os.Stdin); err != nil {
+		return err
+	}
+
+	hook, err := tmpl.Render(values)
+	if err != nil {
+		return err
+	}
+
+	event := tmpl.Event
+	if hooksTemplateEvent != "" {
+		event = hooksTemplateEvent
+	}
+
+	added, err := env.Hooks.AddHook(event, hook)
+	if err != nil {
+		return err
+	}
+	if !added {
+		fmt.Printf("Hook '%s' is already in %s hooks of '%s'\n", hook.Description, event, env.Name)
+		return nil
+	}
+
+	if err := env.Save(); err != nil {
+		return fmt.Errorf("failed to save environment: %w", err)
+	}
+
+	fmt.Printf("✅ Added %s hook '%s' to '%s'\n", event, hook.Description, env.Name)
+	return nil
+}
+
+// promptTemplateParams asks for the template parameters missing from values,
+// offering their default
+func promptTemplateParams(tmpl hooks.Template, values map[string]string, in io.Reader) error {
+	reader := bufio.NewReader(in)
+	for _, param := range tmpl.Params {
+		if _, ok := values[param.Name]; ok {
+			continue
+		}
+
+		if param.Default != "" {
+			fmt.Printf("%s [%s]: ", param.Prompt, param.Default)
+		} else {
+			fmt.Printf("%s: ", param.Prompt)
+		}
+
+		line, err := reader.ReadString('\n')
+		value := strings.TrimSpace(line)
+		if value == "" {
+			value = param.Default
+		}
+		if value == "" && err != nil {
+			fmt.Println()
+			return fmt.Errorf("missing value for %s (use --set %s=...)", param.Name, param.Name)
+		}
+		values[param.Name] = value
+	}
+	return nil
+}
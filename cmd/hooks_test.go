@@ -0,0 +1,314 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hugofrely/envswitch/internal/hooks"
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+func TestRunHooksAddTemplate(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+
+	envsDir := filepath.Join(tempHome, ".envswitch", "environments")
+	require.NoError(t, os.MkdirAll(envsDir, 0755))
+	createEnvWithVars(t, envsDir, "work", nil)
+
+	hooksTemplateEnv = "work"
+	defer func() { hooksTemplateEnv, hooksTemplateSet, hooksTemplateEvent = "", nil, "" }()
+
+	t.Run("adds the hook with --set values", func(t *testing.T) {
+		hooksTemplateSet = []string{"connection=corp"}
+
+		require.NoError(t, runHooksAddTemplate(hooksAddTemplateCmd, []string{"vpn-switch"}))
+
+		env, err := environment.LoadEnvironment("work")
+		require.NoError(t, err)
+		require.Len(t, env.Hooks.PostSwitch, 1)
+		assert.Equal(t, "Connect VPN corp", env.Hooks.PostSwitch[0].Description)
+		assert.Contains(t, env.Hooks.PostSwitch[0].Script, "nmcli connection up id 'corp'")
+	})
+
+	t.Run("does not add the same hook twice", func(t *testing.T) {
+		hooksTemplateSet = []string{"connection=corp"}
+
+		require.NoError(t, runHooksAddTemplate(hooksAddTemplateCmd, []string{"vpn-switch"}))
+
+		env, err := environment.LoadEnvironment("work")
+		require.NoError(t, err)
+		assert.Len(t, env.Hooks.PostSwitch, 1)
+	})
+
+	t.Run("overrides the event", func(t *testing.T) {
+		hooksTemplateSet = []string{"connection=lab"}
+		hooksTemplateEvent = environment.HookPreSwitch
+		defer func() { hooksTemplateEvent = "" }()
+
+		require.NoError(t, runHooksAddTemplate(hooksAddTemplateCmd, []string{"vpn-switch"}))
+
+		env, err := environment.LoadEnvironment("work")
+		require.NoError(t, err)
+		assert.Len(t, env.Hooks.PreSwitch, 1)
+	})
+
+	t.Run("rejects unknown templates and malformed --set", func(t *testing.T) {
+		assert.Error(t, runHooksAddTemplate(hooksAddTemplateCmd, []string{"unknown"}))
+
+		hooksTemplateSet = []string{"connection"}
+		assert.Error(t, runHooksAddTemplate(hooksAddTemplateCmd, []string{"vpn-switch"}))
+	})
+}
+
+func TestPromptTemplateParams(t *testing.T) {
+	tmpl, err := hooks.LookupTemplate("docker-login")
+	require.NoError(t, err)
+
+	t.Run("uses answers and defaults", func(t *testing.T) {
+		values := map[string]string{"username": "me"}
+
+		captureStdout(t, func() {
+			require.NoError(t, promptTemplateParams(tmpl, values, strings.NewReader("ghcr.io\n\n")))
+		})
+
+		assert.Equal(t, map[string]string{"registry": "ghcr.io", "username": "me", "password_var": "DOCKER_PASSWORD"}, values)
+	})
+
+	t.Run("fails when input ends without a default", func(t *testing.T) {
+		values := map[string]string{}
+
+		captureStdout(t, func() {
+			err := promptTemplateParams(tmpl, values, strings.NewReader(""))
+			assert.ErrorContains(t, err, "missing value for username")
+		})
+	})
+}
+
+func TestRunHooksAddListRemove(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+
+	envsDir := filepath.Join(tempHome, ".envswitch", "environments")
+	require.NoError(t, os.MkdirAll(envsDir, 0755))
+	createEnvWithVars(t, envsDir, "work", nil)
+
+	defer func() {
+		hooksAddEvent, hooksAddCommand, hooksAddDescription = "", "", ""
+		hooksAddTimeout, hooksAddCwd, hooksAddOnFailure = "", "", ""
+		hooksAddVars, hooksAddVerify = nil, false
+	}()
+
+	t.Run("adds hooks from flags", func(t *testing.T) {
+		hooksAddCommand = "kubectl get nodes"
+		hooksAddDescription = "Check cluster"
+		hooksAddTimeout = "30s"
+		hooksAddOnFailure = environment.HookFailWarn
+		hooksAddVars = []string{"KUBECONFIG=/tmp/kube"}
+
+		out := captureStdout(t, func() {
+			require.NoError(t, runHooksAdd(hooksAddCmd, []string{"work"}))
+		})
+		assert.Contains(t, out, "✅ Added post_switch hook #1 to 'work'")
+
+		hooksAddEvent = environment.HookPreSwitch
+		hooksAddCommand = "echo hi"
+		hooksAddDescription, hooksAddTimeout, hooksAddOnFailure, hooksAddVars = "", "", "", nil
+		captureStdout(t, func() {
+			require.NoError(t, runHooksAdd(hooksAddCmd, []string{"work"}))
+		})
+
+		env, err := environment.LoadEnvironment("work")
+		require.NoError(t, err)
+		require.Len(t, env.Hooks.PostSwitch, 1)
+		assert.Equal(t, environment.Hook{
+			Command:     "kubectl get nodes",
+			Description: "Check cluster",
+			Timeout:     "30s",
+			OnFailure:   environment.HookFailWarn,
+			Env:         map[string]string{"KUBECONFIG": "/tmp/kube"},
+		}, env.Hooks.PostSwitch[0])
+		require.Len(t, env.Hooks.PreSwitch, 1)
+	})
+
+	t.Run("rejects invalid hooks", func(t *testing.T) {
+		hooksAddCommand = "echo hi"
+		hooksAddTimeout = "soon"
+		defer func() { hooksAddTimeout = "" }()
+
+		assert.ErrorContains(t, runHooksAdd(hooksAddCmd, []string{"work"}), "invalid timeout")
+
+		hooksAddTimeout = ""
+		hooksAddVars = []string{"NOVALUE"}
+		defer func() { hooksAddVars = nil }()
+		assert.ErrorContains(t, runHooksAdd(hooksAddCmd, []string{"work"}), "invalid --var")
+	})
+
+	t.Run("lists hooks with global ones", func(t *testing.T) {
+		configPath := filepath.Join(tempHome, ".envswitch", "config.yaml")
+		require.NoError(t, os.WriteFile(configPath, []byte("hooks:\n  post_switch:\n    - command: restart-lsp\n"), 0600))
+		defer os.Remove(configPath)
+
+		out := captureStdout(t, func() {
+			require.NoError(t, runHooksList(hooksListCmd, []string{"work"}))
+		})
+		assert.Contains(t, out, "Hooks of 'work':\n  pre_switch\n    1. echo hi\n  post_switch\n    1. kubectl get nodes  # Check cluster\n")
+		assert.Contains(t, out, "(timeout 30s, env KUBECONFIG, on_failure warn)")
+		assert.Contains(t, out, "Global hooks (config.yaml):\n  post_switch\n    1. restart-lsp\n")
+	})
+
+	t.Run("removes hooks by number", func(t *testing.T) {
+		assert.ErrorContains(t, runHooksRemove(hooksRemoveCmd, []string{"work", "post_switch", "2"}), "no post_switch hook #2")
+		assert.ErrorContains(t, runHooksRemove(hooksRemoveCmd, []string{"work", "post_switch", "one"}), "invalid hook number")
+
+		out := captureStdout(t, func() {
+			require.NoError(t, runHooksRemove(hooksRemoveCmd, []string{"work", "post_switch", "1"}))
+		})
+		assert.Contains(t, out, "✅ Removed post_switch hook #1 from 'work': Check cluster")
+
+		env, err := environment.LoadEnvironment("work")
+		require.NoError(t, err)
+		assert.Empty(t, env.Hooks.PostSwitch)
+		assert.Len(t, env.Hooks.PreSwitch, 1)
+	})
+
+	t.Run("uses the active environment", func(t *testing.T) {
+		require.NoError(t, environment.SetCurrentEnvironment("work"))
+		defer func() { _ = os.Remove(filepath.Join(tempHome, ".envswitch", "current.lock")) }()
+
+		captureStdout(t, func() {
+			require.NoError(t, runHooksRemove(hooksRemoveCmd, []string{"pre_switch", "1"}))
+		})
+
+		env, err := environment.LoadEnvironment("work")
+		require.NoError(t, err)
+		assert.Empty(t, env.Hooks.PreSwitch)
+	})
+
+	t.Run("requires an environment", func(t *testing.T) {
+		assert.ErrorContains(t, runHooksList(hooksListCmd, nil), "no active environment")
+	})
+}
+
+func TestPromptHook(t *testing.T) {
+	var hook environment.Hook
+	event := ""
+
+	captureStdout(t, func() {
+		require.NoError(t, promptHook(&hook, &event, strings.NewReader("pre_switch\nmake login\nLog in\n")))
+	})
+	assert.Equal(t, environment.HookPreSwitch, event)
+	assert.Equal(t, environment.Hook{Command: "make login", Description: "Log in"}, hook)
+
+	hook, event = environment.Hook{}, environment.HookPostSwitch
+	captureStdout(t, func() {
+		assert.ErrorContains(t, promptHook(&hook, &event, strings.NewReader("\n")), "a command is required")
+	})
+}
+
+func TestRunHooksTest(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+
+	envsDir := filepath.Join(tempHome, ".envswitch", "environments")
+	require.NoError(t, os.MkdirAll(envsDir, 0755))
+	createEnvWithVars(t, envsDir, "work", nil)
+
+	marker := filepath.Join(tempHome, "ran")
+	env, err := environment.LoadEnvironment("work")
+	require.NoError(t, err)
+	env.Hooks.PostSwitch = []environment.Hook{{Command: "touch " + marker}}
+	require.NoError(t, env.Save())
+
+	configPath := filepath.Join(tempHome, ".envswitch", "config.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte("hooks:\n  pre_switch:\n    - command: echo global\n"), 0600))
+
+	t.Run("shows hooks without running them", func(t *testing.T) {
+		out := captureStdout(t, func() {
+			require.NoError(t, runHooksTest(hooksTestCmd, []string{"work"}))
+		})
+		assert.NoFileExists(t, marker)
+		assert.Contains(t, out, "pre_switch:\n  Hook 1/1: echo global\n")
+		assert.Contains(t, out, "post_switch:\n  Hook 1/1: touch "+marker)
+		assert.Contains(t, out, "Nothing was run")
+	})
+
+	t.Run("filters by event", func(t *testing.T) {
+		hooksTestEvent = environment.HookPreSnapshot
+		defer func() { hooksTestEvent = "" }()
+
+		out := captureStdout(t, func() {
+			require.NoError(t, runHooksTest(hooksTestCmd, []string{"work"}))
+		})
+		assert.Contains(t, out, "pre_snapshot:\n  (none)")
+		assert.NotContains(t, out, "post_switch")
+	})
+
+	t.Run("runs hooks with --run", func(t *testing.T) {
+		hooksTestRun = true
+		defer func() { hooksTestRun = false }()
+
+		captureStdout(t, func() {
+			require.NoError(t, runHooksTest(hooksTestCmd, []string{"work"}))
+		})
+		assert.FileExists(t, marker)
+	})
+}
+
+func TestRunHooksAddAction(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+
+	envsDir := filepath.Join(tempHome, ".envswitch", "environments")
+	require.NoError(t, os.MkdirAll(envsDir, 0755))
+	createEnvWithVars(t, envsDir, "work", nil)
+
+	defer func() {
+		hooksAddAction, hooksAddWith, hooksAddArgs = "", nil, nil
+	}()
+
+	t.Run("adds an action with its parameters", func(t *testing.T) {
+		hooksAddAction = environment.HookActionNotify
+		hooksAddWith = []string{"message=Now on {env}"}
+
+		out := captureStdout(t, func() {
+			require.NoError(t, runHooksAdd(hooksAddCmd, []string{"work"}))
+		})
+		assert.Contains(t, out, "✅ Added post_switch hook #1 to 'work'")
+
+		env, err := environment.LoadEnvironment("work")
+		require.NoError(t, err)
+		require.Len(t, env.Hooks.PostSwitch, 1)
+		assert.Equal(t, environment.Hook{
+			Action: environment.HookActionNotify,
+			With:   map[string]string{"message": "Now on {env}"},
+		}, env.Hooks.PostSwitch[0])
+
+		out = captureStdout(t, func() {
+			require.NoError(t, runHooksList(hooksListCmd, []string{"work"}))
+		})
+		assert.Contains(t, out, "1. action: notify message=Now on {env}")
+	})
+
+	t.Run("rejects invalid actions", func(t *testing.T) {
+		hooksAddAction, hooksAddWith = "reboot", nil
+		assert.ErrorContains(t, runHooksAdd(hooksAddCmd, []string{"work"}), "unknown hook action 'reboot'")
+
+		hooksAddAction, hooksAddWith = environment.HookActionNotify, []string{"loud"}
+		assert.ErrorContains(t, runHooksAdd(hooksAddCmd, []string{"work"}), "invalid --with")
+	})
+
+	t.Run("lists the actions", func(t *testing.T) {
+		out := captureStdout(t, func() {
+			require.NoError(t, runHooksActions(hooksActionsCmd, nil))
+		})
+		assert.Contains(t, out, "docker-login\n")
+		assert.Contains(t, out, "    username: User name (required)\n")
+		assert.Contains(t, out, "    args: program and arguments to run\n")
+	})
+}
@@ -7,12 +7,14 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/hugofrely/envswitch/internal/archive"
+	"github.com/hugofrely/envswitch/pkg/environment"
 )
 
 var (
 	importName  string
 	importForce bool
 	importAll   bool
+	importDir   string
 )
 
 var importCmd = &cobra.Command{
@@ -26,7 +28,9 @@ This allows you to:
   - Migrate environments from other machines
   - Restore archived environments
 
-The archive must be a .tar.gz file created by 'envswitch export'.
+The archive must be a .tar.gz file created by 'envswitch export'. Its
+metadata.yaml is validated before the environment is installed, and the
+installed environment must load before the import succeeds.
 
 Examples:
   # Import an environment
@@ -39,8 +43,9 @@ Examples:
   envswitch import work-backup.tar.gz --force
 
   # Import all environments from a directory
+  envswitch import --dir ~/backups/ --all
   envswitch import ~/backups/ --all`,
-	Args: cobra.ExactArgs(1),
+	Args: validateImportArgs,
 	RunE: runImport,
 }
 
@@ -49,21 +54,56 @@ func init() {
 	importCmd.Flags().StringVarP(&importName, "name", "n", "", "New name for the imported environment")
 	importCmd.Flags().BoolVarP(&importForce, "force", "f", false, "Overwrite existing environment")
 	importCmd.Flags().BoolVar(&importAll, "all", false, "Import all archives from directory")
+	importCmd.Flags().StringVar(&importDir, "dir", "", "Directory to import all archives from (with --all)")
+	_ = importCmd.MarkFlagDirname("dir")
 }
 
-func runImport(cmd *cobra.Command, args []string) error {
-	archivePath := args[0]
+// validateImportArgs requires one archive, or with --all a directory given
+// as argument or with --dir
+func validateImportArgs(cmd *cobra.Command, args []string) error {
+	if !importAll {
+		if importDir != "" {
+			return fmt.Errorf("--dir requires --all")
+		}
+		return cobra.ExactArgs(1)(cmd, args)
+	}
+	if importName != "" {
+		return fmt.Errorf("--name cannot be used with --all")
+	}
+	if importDir != "" {
+		if len(args) > 0 {
+			return fmt.Errorf("give the directory either as argument or with --dir")
+		}
+		return nil
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("--all requires a directory (argument or --dir)")
+	}
+	return nil
+}
 
+func runImport(cmd *cobra.Command, args []string) error {
 	// Import all from directory
 	if importAll {
-		if err := archive.ImportAll(archivePath, importForce); err != nil {
+		dir := importDir
+		if dir == "" {
+			dir = args[0]
+		}
+
+		result, err := archive.ImportAll(dir, archive.ImportOptions{Force: importForce, ConfirmReplace: confirmReplace})
+		if err != nil {
 			return fmt.Errorf("failed to import environments: %w", err)
 		}
 
-		fmt.Printf("✅ Environments imported from: %s\n", archivePath)
+		fmt.Printf("✅ Imported %d environment(s) from: %s\n", len(result.Imported), dir)
+		if len(result.Failed) > 0 {
+			return fmt.Errorf("failed to import %d archive(s)", len(result.Failed))
+		}
 		return nil
 	}
 
+	archivePath := args[0]
+
 	// Validate single archive
 	if !strings.HasSuffix(archivePath, ".tar.gz") && !strings.HasSuffix(archivePath, ".tgz") {
 		return fmt.Errorf("invalid archive format: must be .tar.gz or .tgz")
@@ -71,9 +111,10 @@ func runImport(cmd *cobra.Command, args []string) error {
 
 	// Import single archive
 	options := archive.ImportOptions{
-		ArchivePath: archivePath,
-		NewName:     importName,
-		Force:       importForce,
+		ArchivePath:    archivePath,
+		NewName:        importName,
+		Force:          importForce,
+		ConfirmReplace: confirmReplace,
 	}
 
 	if err := archive.ImportEnvironment(archivePath, options); err != nil {
@@ -83,3 +124,8 @@ func runImport(cmd *cobra.Command, args []string) error {
 	// Success message is already displayed by the spinner in ImportEnvironment
 	return nil
 }
+
+// confirmReplace asks before an import overwrites a protected environment
+func confirmReplace(existing *environment.Environment) bool {
+	return confirmProtected(existing, "replace it")
+}
@@ -1,9 +1,15 @@
 package cmd
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hugofrely/envswitch/internal/archive"
+	"github.com/hugofrely/envswitch/pkg/environment"
 )
 
 func TestImportCommand(t *testing.T) {
@@ -52,3 +58,108 @@ func TestImportCommand(t *testing.T) {
 		assert.Contains(t, commandNames, "import", "import command should be registered")
 	})
 }
+
+func TestValidateImportArgs(t *testing.T) {
+	defer func() {
+		importAll = false
+		importDir = ""
+		importName = ""
+	}()
+
+	importDir = "backups"
+	assert.Error(t, validateImportArgs(importCmd, nil), "--dir requires --all")
+
+	importAll = true
+	assert.NoError(t, validateImportArgs(importCmd, nil))
+	assert.Error(t, validateImportArgs(importCmd, []string{"other"}))
+
+	importDir = ""
+	assert.NoError(t, validateImportArgs(importCmd, []string{"backups"}))
+	assert.Error(t, validateImportArgs(importCmd, nil))
+
+	importName = "renamed"
+	assert.Error(t, validateImportArgs(importCmd, []string{"backups"}))
+}
+
+func TestRunImportAllFromDir(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+
+	envsDir := filepath.Join(tempHome, ".envswitch", "environments")
+	require.NoError(t, os.MkdirAll(envsDir, 0755))
+	createEnvWithVars(t, envsDir, "work", map[string]string{"AWS_PROFILE": "work"})
+	createEnvWithVars(t, envsDir, "home", nil)
+
+	exportDir := t.TempDir()
+	require.NoError(t, archive.ExportAllEnvironments(exportDir))
+	require.NoError(t, os.RemoveAll(envsDir))
+
+	importAll = true
+	importDir = exportDir
+	defer func() {
+		importAll = false
+		importDir = ""
+	}()
+
+	output := captureStdout(t, func() {
+		require.NoError(t, runImport(importCmd, nil))
+	})
+	assert.Contains(t, output, "[1/2]")
+	assert.Contains(t, output, "Imported 2 environment(s)")
+
+	env, err := environment.LoadEnvironment("work")
+	require.NoError(t, err)
+	assert.Equal(t, "work", env.Name)
+}
+
+func TestRunImportProtected(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+
+	envsDir := filepath.Join(tempHome, ".envswitch", "environments")
+	require.NoError(t, os.MkdirAll(envsDir, 0755))
+	env := createEnvWithVars(t, envsDir, "prod", nil)
+	env.Description = "archived"
+	require.NoError(t, env.Save())
+
+	archivePath := filepath.Join(t.TempDir(), "prod.tar.gz")
+	require.NoError(t, archive.ExportEnvironment("prod", archivePath))
+
+	env.Description = "live"
+	require.NoError(t, env.Save())
+	captureStdout(t, func() { require.NoError(t, setProtected("prod", true)) })
+
+	importForce = true
+	defer func() { importForce = false }()
+
+	description := func() string {
+		loaded, err := environment.LoadEnvironment("prod")
+		require.NoError(t, err)
+		return loaded.Description
+	}
+
+	t.Run("keeps it without confirmation", func(t *testing.T) {
+		setProtectedInput(t, "y\n")
+		var err error
+		captureStdout(t, func() { err = runImport(importCmd, []string{archivePath}) })
+		assert.ErrorContains(t, err, "'prod' is protected")
+		assert.Equal(t, "live", description())
+	})
+
+	t.Run("keeps it with --all", func(t *testing.T) {
+		importAll = true
+		defer func() { importAll = false }()
+
+		setProtectedInput(t, "\n")
+		var err error
+		captureStdout(t, func() { err = runImport(importCmd, []string{filepath.Dir(archivePath)}) })
+		assert.Error(t, err)
+		assert.Equal(t, "live", description())
+	})
+
+	t.Run("replaces it once its name is typed", func(t *testing.T) {
+		setProtectedInput(t, "prod\n")
+		captureStdout(t, func() { require.NoError(t, runImport(importCmd, []string{archivePath})) })
+		assert.Equal(t, "archived", description())
+	})
+}
@@ -7,6 +7,9 @@ import (
 
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v3"
+
+	"github.com/hugofrely/envswitch/internal/platform"
+	"github.com/hugofrely/envswitch/internal/storage"
 )
 
 var initCmd = &cobra.Command{
@@ -21,7 +24,7 @@ func init() {
 }
 
 func runInit(cmd *cobra.Command, args []string) error {
-	home, err := os.UserHomeDir()
+	home, err := platform.HomeDir()
 	if err != nil {
 		return fmt.Errorf("failed to get home directory: %w", err)
 	}
@@ -30,7 +33,7 @@ func runInit(cmd *cobra.Command, args []string) error {
 
 	// Create main directory
 	fmt.Println("Creating ~/.envswitch/...")
-	if err := os.MkdirAll(envswitchDir, 0755); err != nil {
+	if err := storage.MkdirPrivate(envswitchDir); err != nil {
 		return fmt.Errorf("failed to create directory: %w", err)
 	}
 
@@ -38,7 +41,7 @@ func runInit(cmd *cobra.Command, args []string) error {
 	dirs := []string{"environments", "auto-backups"}
 	for _, dir := range dirs {
 		dirPath := filepath.Join(envswitchDir, dir)
-		if err := os.MkdirAll(dirPath, 0755); err != nil {
+		if err := os.MkdirAll(dirPath, 0700); err != nil {
 			return fmt.Errorf("failed to create %s: %w", dir, err)
 		}
 	}
@@ -47,8 +50,8 @@ func runInit(cmd *cobra.Command, args []string) error {
 	configPath := filepath.Join(envswitchDir, "config.yaml")
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
 		defaultConfig := map[string]interface{}{
-			"version":                   "1.0",
-			"auto_save_before_switch":   false,
+			"version":                   "1.1",
+			"auto_save_before_switch":   "true",
 			"verify_after_switch":       false,
 			"backup_retention":          10,
 			"enable_prompt_integration": true,
@@ -57,6 +60,7 @@ func runInit(cmd *cobra.Command, args []string) error {
 			"log_level":                 "info",
 			"log_file":                  filepath.Join(envswitchDir, "envswitch.log"),
 			"exclude_tools":             []string{},
+			"ssh_include_private_keys":  false,
 			"color_output":              true,
 			"show_timestamps":           false,
 			"backup_before_switch":      true,
@@ -67,7 +71,7 @@ func runInit(cmd *cobra.Command, args []string) error {
 			return fmt.Errorf("failed to marshal config: %w", err)
 		}
 
-		if err := os.WriteFile(configPath, data, 0644); err != nil {
+		if err := os.WriteFile(configPath, data, 0600); err != nil {
 			return fmt.Errorf("failed to write config: %w", err)
 		}
 	}
@@ -75,7 +79,7 @@ func runInit(cmd *cobra.Command, args []string) error {
 	// Create history log
 	historyPath := filepath.Join(envswitchDir, "history.log")
 	if _, err := os.Stat(historyPath); os.IsNotExist(err) {
-		if err := os.WriteFile(historyPath, []byte(""), 0644); err != nil {
+		if err := os.WriteFile(historyPath, []byte(""), 0600); err != nil {
 			return fmt.Errorf("failed to create history log: %w", err)
 		}
 	}
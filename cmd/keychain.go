@@ -0,0 +1,257 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/hugofrely/envswitch/internal/keychain"
+	"github.com/hugofrely/envswitch/internal/logger"
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+var (
+	keychainEnv      string
+	keychainAccount  string
+	keychainInternet bool
+	keychainYes      bool
+)
+
+var keychainLog = logger.Named("keychain")
+
+var keychainCmd = &cobra.Command{
+	Use:   "keychain",
+	Short: "Manage macOS Keychain items captured with an environment",
+	Long: `Manage the macOS Keychain items captured and restored with an environment.
+
+On macOS, tools such as docker (osxkeychain credential helper) store tokens in
+the Keychain rather than in config files. Only items explicitly added to an
+environment's allowlist are ever read or written.
+
+Captured items are stored in the environment's snapshot directory in plain
+text, readable only by your user.`,
+}
+
+var keychainAddCmd = &cobra.Command{
+	Use:   "add <service>",
+	Short: "Allow a Keychain item to be captured with an environment",
+	Long: `Add a Keychain item to an environment's allowlist.
+
+The item is captured on the next save or switch and restored when switching
+back to the environment. macOS may ask you to allow access to the item.
+
+Examples:
+  # Capture a generic password item
+  envswitch keychain add my-api-token
+
+  # Capture docker registry credentials (internet password)
+  envswitch keychain add index.docker.io --internet --env work`,
+	Args: cobra.ExactArgs(1),
+	RunE: runKeychainAdd,
+}
+
+var keychainRemoveCmd = &cobra.Command{
+	Use:     "remove <service>",
+	Aliases: []string{"rm"},
+	Short:   "Stop capturing a Keychain item",
+	Args:    cobra.ExactArgs(1),
+	RunE:    runKeychainRemove,
+}
+
+var keychainListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List allowlisted Keychain items",
+	Args:    cobra.NoArgs,
+	RunE:    runKeychainList,
+}
+
+func init() {
+	rootCmd.AddCommand(keychainCmd)
+	keychainCmd.AddCommand(keychainAddCmd)
+	keychainCmd.AddCommand(keychainRemoveCmd)
+	keychainCmd.AddCommand(keychainListCmd)
+
+	keychainCmd.PersistentFlags().StringVar(&keychainEnv, "env", "", "Environment to update (default: active environment)")
+	_ = keychainCmd.RegisterFlagCompletionFunc("env", completeEnvironmentFlag)
+
+	keychainAddCmd.Flags().StringVar(&keychainAccount, "account", "", "Account of the item (default: first match)")
+	keychainAddCmd.Flags().BoolVar(&keychainInternet, "internet", false, "Item is an internet password (e.g. docker credentials)")
+	keychainAddCmd.Flags().BoolVarP(&keychainYes, "yes", "y", false, "Skip the confirmation prompt")
+}
+
+func runKeychainAdd(cmd *cobra.Command, args []string) error {
+	service := args[0]
+
+	if !keychain.IsSupported() {
+		return fmt.Errorf("keychain integration is only available on macOS")
+	}
+
+	env, err := resolveKeychainEnvironment()
+	if err != nil {
+		return err
+	}
+
+	for _, item := range env.Keychain {
+		if item.Service == service && item.Account == keychainAccount {
+			return fmt.Errorf("keychain item '%s' is already captured by '%s'", service, env.Name)
+		}
+	}
+
+	if !keychainYes {
+		fmt.Printf("⚠️  The secret stored in Keychain item '%s' will be copied, encrypted, to:\n", service)
+		fmt.Printf("   %s\n", env.Path)
+		fmt.Printf("   and written back to your Keychain when switching to '%s'.\n", env.Name)
+		fmt.Print("Continue? [y/N]: ")
+		var response string
+		if _, err := fmt.Scanln(&response); err != nil || (response != "y" && response != "Y") {
+			fmt.Println("Canceled.")
+			return nil
+		}
+	}
+
+	item := environment.KeychainItem{Service: service, Account: keychainAccount}
+	if keychainInternet {
+		item.Kind = keychain.KindInternet
+	}
+	env.Keychain = append(env.Keychain, item)
+
+	if err := env.Save(); err != nil {
+		return fmt.Errorf("failed to save environment: %w", err)
+	}
+
+	fmt.Printf("✅ Keychain item '%s' will be captured with '%s'\n", service, env.Name)
+	return nil
+}
+
+func runKeychainRemove(cmd *cobra.Command, args []string) error {
+	service := args[0]
+
+	env, err := resolveKeychainEnvironment()
+	if err != nil {
+		return err
+	}
+
+	kept := env.Keychain[:0]
+	removed := 0
+	for _, item := range env.Keychain {
+		if item.Service == service {
+			removed++
+			continue
+		}
+		kept = append(kept, item)
+	}
+
+	if removed == 0 {
+		return fmt.Errorf("keychain item '%s' is not captured by '%s'", service, env.Name)
+	}
+
+	env.Keychain = kept
+	if err := env.Save(); err != nil {
+		return fmt.Errorf("failed to save environment: %w", err)
+	}
+
+	// Drop the captured secret as well
+	secrets, err := keychain.Load(env)
+	if err != nil {
+		return err
+	}
+	remaining := make([]keychain.Secret, 0, len(secrets))
+	for _, secret := range secrets {
+		if secret.Service != service {
+			remaining = append(remaining, secret)
+		}
+	}
+	if err := keychain.Save(env, remaining); err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ Keychain item '%s' removed from '%s'\n", service, env.Name)
+	return nil
+}
+
+func runKeychainList(cmd *cobra.Command, args []string) error {
+	env, err := resolveKeychainEnvironment()
+	if err != nil {
+		return err
+	}
+
+	if len(env.Keychain) == 0 {
+		fmt.Printf("No keychain items captured by '%s'\n", env.Name)
+		return nil
+	}
+
+	fmt.Printf("Keychain items captured by '%s':\n", env.Name)
+	for _, item := range env.Keychain {
+		kind := item.Kind
+		if kind == "" {
+			kind = keychain.KindGeneric
+		}
+		if item.Account != "" {
+			fmt.Printf("  • %s (%s, account: %s)\n", item.Service, kind, item.Account)
+		} else {
+			fmt.Printf("  • %s (%s)\n", item.Service, kind)
+		}
+	}
+
+	return nil
+}
+
+// resolveKeychainEnvironment returns the --env environment or the active one
+func resolveKeychainEnvironment() (*environment.Environment, error) {
+	targets, err := resolveEnvTargets(keychainEnv, false)
+	if err != nil {
+		return nil, err
+	}
+	return targets[0], nil
+}
+
+// captureKeychain captures the allowlisted Keychain items of an environment
+func captureKeychain(env *environment.Environment) {
+	if len(env.Keychain) == 0 {
+		return
+	}
+	if !keychain.IsSupported() {
+		keychainLog.Debug("Keychain not available, skipping %d item(s)", len(env.Keychain))
+		return
+	}
+
+	keychainLog.Debug("Capturing keychain items...")
+	secrets, err := keychain.Capture(env.Keychain)
+	if err != nil {
+		keychainLog.Warn("Failed to capture keychain items: %v", err)
+		return
+	}
+	if err := keychain.Save(env, secrets); err != nil {
+		keychainLog.Warn("Failed to save keychain items: %v", err)
+		return
+	}
+	keychainLog.Debug("Captured %d keychain item(s)", len(secrets))
+}
+
+// restoreKeychain writes an environment's captured Keychain items back
+func restoreKeychain(env *environment.Environment) {
+	if len(env.Keychain) == 0 {
+		return
+	}
+	if !keychain.IsSupported() {
+		keychainLog.Debug("Keychain not available, skipping %d item(s)", len(env.Keychain))
+		return
+	}
+
+	secrets, err := keychain.Load(env)
+	if err != nil {
+		keychainLog.Warn("Failed to load keychain items: %v", err)
+		return
+	}
+	if len(secrets) == 0 {
+		return
+	}
+
+	keychainLog.Debug("Restoring keychain items...")
+	if err := keychain.Restore(secrets); err != nil {
+		keychainLog.Warn("Failed to restore keychain items: %v", err)
+		return
+	}
+	keychainLog.Debug("Restored %d keychain item(s)", len(secrets))
+}
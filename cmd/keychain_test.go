@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hugofrely/envswitch/internal/keychain"
+	"github.com/hugofrely/envswitch/internal/keyring"
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+func TestRunKeychainRemove(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+	keyring.MockInit() // captured passwords are encrypted with a key kept in the keyring
+
+	envsDir := filepath.Join(tempHome, ".envswitch", "environments")
+	require.NoError(t, os.MkdirAll(envsDir, 0755))
+
+	env := createEnvWithVars(t, envsDir, "work", nil)
+	env.Keychain = []environment.KeychainItem{
+		{Service: "api-token"},
+		{Service: "index.docker.io", Kind: keychain.KindInternet},
+	}
+	require.NoError(t, env.Save())
+	require.NoError(t, keychain.Save(env, []keychain.Secret{
+		{Service: "api-token", Kind: keychain.KindGeneric, Password: "a"},
+		{Service: "index.docker.io", Kind: keychain.KindInternet, Password: "b"},
+	}))
+
+	keychainEnv = "work"
+	defer func() { keychainEnv = "" }()
+
+	t.Run("removes item and captured secret", func(t *testing.T) {
+		require.NoError(t, runKeychainRemove(keychainRemoveCmd, []string{"api-token"}))
+
+		loaded, err := environment.LoadEnvironment("work")
+		require.NoError(t, err)
+		assert.Equal(t, []environment.KeychainItem{{Service: "index.docker.io", Kind: keychain.KindInternet}}, loaded.Keychain)
+
+		secrets, err := keychain.Load(loaded)
+		require.NoError(t, err)
+		require.Len(t, secrets, 1)
+		assert.Equal(t, "index.docker.io", secrets[0].Service)
+	})
+
+	t.Run("fails for unknown item", func(t *testing.T) {
+		assert.Error(t, runKeychainRemove(keychainRemoveCmd, []string{"api-token"}))
+	})
+
+	t.Run("lists items", func(t *testing.T) {
+		assert.NoError(t, runKeychainList(keychainListCmd, nil))
+	})
+}
+
+func TestRunKeychainAddUnsupported(t *testing.T) {
+	if keychain.IsSupported() {
+		t.Skip("keychain is available on this system")
+	}
+
+	err := runKeychainAdd(keychainAddCmd, []string{"api-token"})
+	assert.Error(t, err)
+}
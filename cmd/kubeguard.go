@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hugofrely/envswitch/pkg/environment"
+	"github.com/hugofrely/envswitch/pkg/tools"
+)
+
+// registryKubectl returns the kubectl tool of a registry, nil when the
+// switch leaves kubectl alone
+func registryKubectl(env *environment.Environment, registry map[string]tools.Tool) *tools.KubectlTool {
+	if len(env.Kubectl.AllowedContexts) == 0 || !env.Tools["kubectl"].Enabled {
+		return nil
+	}
+	kubectlTool, _ := registry["kubectl"].(*tools.KubectlTool)
+	return kubectlTool
+}
+
+// checkSnapshotKubeContext aborts a --strict switch before anything changes
+// when the snapshot of env restores a context it does not allow
+func checkSnapshotKubeContext(env *environment.Environment, registry map[string]tools.Tool) error {
+	kubectlTool := registryKubectl(env, registry)
+	if kubectlTool == nil || !switchStrict {
+		return nil
+	}
+
+	snapshotPath, cleanup, err := env.ResolveToolSnapshot("kubectl")
+	if err != nil {
+		return fmt.Errorf("failed to prepare the kubectl snapshot of '%s': %w", env.Name, err)
+	}
+	defer cleanup()
+
+	context, err := kubectlTool.SnapshotContext(snapshotPath)
+	if err != nil {
+		return fmt.Errorf("failed to read the kubectl context of '%s': %w", env.Name, err)
+	}
+	if !env.KubeContextAllowed(context) {
+		return fmt.Errorf("%s, switch aborted", kubeContextNotAllowed(env, context))
+	}
+	return nil
+}
+
+// checkLiveKubeContext warns when the live kubectl context is not allowed by
+// env after switching to it, and fails with --strict
+func checkLiveKubeContext(env *environment.Environment, registry map[string]tools.Tool) error {
+	kubectlTool := registryKubectl(env, registry)
+	if kubectlTool == nil {
+		return nil
+	}
+
+	context, err := kubectlTool.CurrentContext()
+	if err != nil {
+		restoreLog.Warn("Failed to read the kubectl context: %v", err)
+		context = ""
+	}
+	if env.KubeContextAllowed(context) {
+		return nil
+	}
+
+	if switchStrict {
+		return fmt.Errorf("switched to '%s', but %s", env.Name, kubeContextNotAllowed(env, context))
+	}
+	fmt.Printf("⚠️  %s\n", kubeContextNotAllowed(env, context))
+	fmt.Println("   Check it with 'kubectl config current-context' before running commands")
+	return nil
+}
+
+// kubeContextNotAllowed describes a context env does not allow
+func kubeContextNotAllowed(env *environment.Environment, context string) string {
+	if context == "" {
+		context = "(none)"
+	}
+	return fmt.Sprintf("kubectl context '%s' is not allowed in '%s' (allowed: %s)",
+		context, env.Name, strings.Join(env.Kubectl.AllowedContexts, ", "))
+}
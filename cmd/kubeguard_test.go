@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hugofrely/envswitch/pkg/environment"
+	"github.com/hugofrely/envswitch/pkg/tools"
+)
+
+// writeKubeConfig writes a kubeconfig with a current context
+func writeKubeConfig(t *testing.T, dir, context string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	content := "apiVersion: v1\nkind: Config\ncurrent-context: " + context + "\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "config"), []byte(content), 0600))
+}
+
+func TestKubeContextGuard(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+
+	env := createEnvWithVars(t, filepath.Join(tempHome, ".envswitch", "environments"), "staging", nil)
+	env.Tools["kubectl"] = environment.ToolConfig{Enabled: true}
+	env.Kubectl.AllowedContexts = []string{"staging-*"}
+	writeKubeConfig(t, filepath.Join(env.Path, "snapshots", "kubectl"), "prod-eu")
+
+	liveDir := filepath.Join(tempHome, ".kube")
+	registry := map[string]tools.Tool{"kubectl": &tools.KubectlTool{KubeConfigDir: liveDir}}
+
+	t.Run("only checks the snapshot with --strict", func(t *testing.T) {
+		assert.NoError(t, checkSnapshotKubeContext(env, registry))
+
+		switchStrict = true
+		defer func() { switchStrict = false }()
+		err := checkSnapshotKubeContext(env, registry)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "'prod-eu' is not allowed")
+	})
+
+	t.Run("warns about the live context", func(t *testing.T) {
+		writeKubeConfig(t, liveDir, "prod-eu")
+		out := captureStdout(t, func() { assert.NoError(t, checkLiveKubeContext(env, registry)) })
+		assert.Contains(t, out, "kubectl context 'prod-eu' is not allowed in 'staging' (allowed: staging-*)")
+
+		switchStrict = true
+		defer func() { switchStrict = false }()
+		assert.Error(t, checkLiveKubeContext(env, registry))
+	})
+
+	t.Run("accepts an allowed context", func(t *testing.T) {
+		writeKubeConfig(t, liveDir, "staging-eu")
+		out := captureStdout(t, func() { assert.NoError(t, checkLiveKubeContext(env, registry)) })
+		assert.Empty(t, out)
+	})
+
+	t.Run("ignores switches leaving kubectl alone", func(t *testing.T) {
+		writeKubeConfig(t, liveDir, "prod-eu")
+		switchStrict = true
+		defer func() { switchStrict = false }()
+		assert.NoError(t, checkLiveKubeContext(env, map[string]tools.Tool{}))
+	})
+}
@@ -2,38 +2,146 @@ package cmd
 
 import (
 	"fmt"
+	"os"
+	"path"
+	"sort"
 	"strings"
+	"text/tabwriter"
 	"time"
 
 	"github.com/dustin/go-humanize"
 	"github.com/spf13/cobra"
 
+	"github.com/hugofrely/envswitch/internal/archive"
+	"github.com/hugofrely/envswitch/internal/output"
 	"github.com/hugofrely/envswitch/pkg/environment"
 )
 
 var (
 	listDetailed bool
+	listJSON     bool
+	listYAML     bool
+	listWide     bool
+	listTags     []string
+	listArchived bool
 )
 
 var listCmd = &cobra.Command{
-	Use:     "list",
+	Use:     "list [group|pattern]",
 	Aliases: []string{"ls"},
 	Short:   "List all environments",
-	Long:    `List all available environments with their status and basic information.`,
-	RunE:    runList,
+	Long: `List all available environments with their status and basic information.
+
+Grouped environments (such as client-a/prod) are listed under their group.
+Pass a group or a wildcard pattern to list only some environments, and
+--tag to list the environments with a tag (see 'envswitch tag'), and
+--archived to list the environments put away with 'envswitch archive'.
+
+Examples:
+  envswitch list
+  envswitch list client-a
+  envswitch list 'client-a/*'
+  envswitch list --tag client=acme --tag type=prod
+  envswitch list --wide
+  envswitch list --archived
+  envswitch list --output json | jq '.[] | select(.active) | .name'`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeEnvironmentNames,
+	RunE:              runList,
 }
 
 func init() {
 	rootCmd.AddCommand(listCmd)
 	listCmd.Flags().BoolVar(&listDetailed, "detailed", false, "Show detailed information")
+	listCmd.Flags().BoolVar(&listJSON, "json", false, "Output as JSON")
+	listCmd.Flags().BoolVar(&listYAML, "yaml", false, "Output as YAML")
+	listCmd.Flags().BoolVar(&listWide, "wide", false, "Show a table with per-tool details")
+	listCmd.Flags().StringArrayVar(&listTags, "tag", nil, "Only list environments with this tag, or tag key (repeatable)")
+	listCmd.Flags().BoolVar(&listArchived, "archived", false, "List the archived environments")
+	_ = listCmd.RegisterFlagCompletionFunc("tag", completeTags)
+	listCmd.MarkFlagsMutuallyExclusive("json", "yaml", "wide", "detailed")
+	listCmd.MarkFlagsMutuallyExclusive("archived", "wide", "detailed", "tag")
+}
+
+// environmentListing is the structured form of an environment in list output
+type environmentListing struct {
+	Name         string                            `json:"name" yaml:"name"`
+	Description  string                            `json:"description,omitempty" yaml:"description,omitempty"`
+	Active       bool                              `json:"active" yaml:"active"`
+	LastUsed     *time.Time                        `json:"last_used,omitempty" yaml:"last_used,omitempty"`
+	LastSnapshot *time.Time                        `json:"last_snapshot,omitempty" yaml:"last_snapshot,omitempty"`
+	Tools        []string                          `json:"tools" yaml:"tools"`
+	Tags         []string                          `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Protected    bool                              `json:"protected,omitempty" yaml:"protected,omitempty"`
+	Metadata     map[string]map[string]interface{} `json:"metadata,omitempty" yaml:"metadata,omitempty"`
+	SnapshotSize uint64                            `json:"snapshot_size,omitempty" yaml:"snapshot_size,omitempty"`
+}
+
+// wideColumns are the per-tool metadata shown by list --wide
+var wideColumns = []struct {
+	header string
+	tool   string
+	key    string
+}{
+	{"GCLOUD PROJECT", "gcloud", "project"},
+	{"AWS PROFILE", "aws", "profile"},
+	{"KUBE CONTEXT", "kubectl", "current_context"},
+	{"GIT EMAIL", "git", "user_email"},
+}
+
+// archivedListing is the structured form of an archived environment in list
+// --archived output
+type archivedListing struct {
+	Name       string    `json:"name" yaml:"name"`
+	ArchivedAt time.Time `json:"archived_at" yaml:"archived_at"`
+	SizeBytes  int64     `json:"size_bytes" yaml:"size_bytes"`
+	Path       string    `json:"path" yaml:"path"`
 }
 
 func runList(cmd *cobra.Command, args []string) error {
+	if listArchived {
+		return runListArchived(args)
+	}
+
 	environments, err := environment.ListEnvironments()
 	if err != nil {
 		return err
 	}
+	if len(args) == 1 {
+		if environments, err = filterEnvironments(environments, args[0]); err != nil {
+			return err
+		}
+	}
+	environments = environment.FilterByTags(environments, listTags)
 
+	out, err := listWriter()
+	if err != nil {
+		return err
+	}
+
+	if out.Structured() || listWide {
+		current, _ := environment.GetCurrentEnvironment()
+		var currentName string
+		if current != nil {
+			currentName = current.Name
+		}
+
+		listings := make([]environmentListing, 0, len(environments))
+		for _, env := range environments {
+			listings = append(listings, newEnvironmentListing(env, env.Name == currentName))
+		}
+
+		if out.Structured() {
+			return out.Write(listings)
+		}
+		printWideList(listings)
+		return nil
+	}
+
+	if len(environments) == 0 && len(listTags) > 0 {
+		fmt.Printf("No environment is tagged %s.\n", strings.Join(listTags, " and "))
+		return nil
+	}
 	if len(environments) == 0 {
 		fmt.Println("No environments found.")
 		fmt.Println()
@@ -51,16 +159,41 @@ func runList(cmd *cobra.Command, args []string) error {
 	fmt.Println("Available environments:")
 	fmt.Println()
 
+	// Ungrouped environments come first, then each group under a header
+	sort.SliceStable(environments, func(i, j int) bool {
+		gi, gj := environment.GroupOf(environments[i].Name), environment.GroupOf(environments[j].Name)
+		if (gi == "") != (gj == "") {
+			return gi == ""
+		}
+		return gi < gj
+	})
+
+	lastGroup := ""
 	for _, env := range environments {
-		prefix := "  "
+		indent := "  "
+		displayName := env.Name
+		if group := environment.GroupOf(env.Name); group != "" {
+			if group != lastGroup {
+				fmt.Printf("  %s%s\n", group, environment.GroupSeparator)
+				lastGroup = group
+			}
+			indent = "    "
+			displayName = strings.TrimPrefix(env.Name, group+environment.GroupSeparator)
+		}
+
+		prefix := indent
 		suffix := ""
 
 		if env.Name == currentName {
-			prefix = "  * "
+			prefix = indent + "* "
 			suffix = " (active)"
 		}
 
-		fmt.Printf("%s%s%s", prefix, env.Name, suffix)
+		if env.Protected {
+			suffix += " 🔒"
+		}
+
+		fmt.Printf("%s%s%s", prefix, displayName, suffix)
 
 		if env.Description != "" {
 			fmt.Printf(" - %s", env.Description)
@@ -82,6 +215,9 @@ func runList(cmd *cobra.Command, args []string) error {
 			if len(enabledTools) > 0 {
 				fmt.Printf("                       Tools: %s\n", strings.Join(enabledTools, ", "))
 			}
+			if len(env.Tags) > 0 {
+				fmt.Printf("                       Tags: %s\n", strings.Join(env.Tags, ", "))
+			}
 			fmt.Println()
 		}
 	}
@@ -92,9 +228,193 @@ func runList(cmd *cobra.Command, args []string) error {
 	}
 	fmt.Println()
 
+	if archived, err := archive.ListShelved(); err == nil && len(archived) > 0 {
+		fmt.Printf("%d archived (envswitch list --archived)\n", len(archived))
+	}
+
+	return nil
+}
+
+// runListArchived lists the environments put away with 'envswitch archive',
+// only those of a group or matching a pattern when one is given
+func runListArchived(args []string) error {
+	archived, err := archive.ListShelved()
+	if err != nil {
+		return err
+	}
+	if len(args) == 1 {
+		stubs := make([]*environment.Environment, 0, len(archived))
+		byName := make(map[string]*archive.Archive, len(archived))
+		for _, arch := range archived {
+			stubs = append(stubs, &environment.Environment{Name: arch.EnvName})
+			byName[arch.EnvName] = arch
+		}
+		if stubs, err = filterEnvironments(stubs, args[0]); err != nil {
+			return err
+		}
+		archived = archived[:0]
+		for _, stub := range stubs {
+			archived = append(archived, byName[stub.Name])
+		}
+	}
+
+	out, err := listWriter()
+	if err != nil {
+		return err
+	}
+	if out.Structured() {
+		listings := make([]archivedListing, 0, len(archived))
+		for _, arch := range archived {
+			listings = append(listings, archivedListing{
+				Name:       arch.EnvName,
+				ArchivedAt: arch.ArchivedAt,
+				SizeBytes:  arch.SizeBytes,
+				Path:       arch.Path,
+			})
+		}
+		return out.Write(listings)
+	}
+
+	if len(archived) == 0 {
+		fmt.Println("No archived environments.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tARCHIVED\tSIZE")
+	for _, arch := range archived {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", arch.EnvName, formatTimeAgo(arch.ArchivedAt), humanize.Bytes(uint64(arch.SizeBytes)))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("\nTotal: %d archived (restore with: envswitch unarchive <name>)\n", len(archived))
 	return nil
 }
 
+// listWriter returns the writer of the list, --json and --yaml are shortcuts
+// for --output
+func listWriter() (*output.Writer, error) {
+	switch {
+	case listJSON:
+		return output.NewWriter(output.FormatJSON, os.Stdout), nil
+	case listYAML:
+		return output.NewWriter(output.FormatYAML, os.Stdout), nil
+	}
+	return resultWriter()
+}
+
+// filterEnvironments keeps the environments matching a wildcard pattern, or
+// the environments of a group when filter is not a pattern
+func filterEnvironments(envs []*environment.Environment, filter string) ([]*environment.Environment, error) {
+	filter = environment.NormalizeName(filter)
+
+	var filtered []*environment.Environment
+	for _, env := range envs {
+		var matched bool
+		if environment.IsPattern(filter) {
+			ok, err := path.Match(filter, env.Name)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern '%s': %w", filter, err)
+			}
+			matched = ok
+		} else {
+			group := strings.TrimSuffix(filter, environment.GroupSeparator)
+			matched = env.Name == group || strings.HasPrefix(env.Name, group+environment.GroupSeparator)
+		}
+
+		if matched {
+			filtered = append(filtered, env)
+		}
+	}
+	return filtered, nil
+}
+
+// newEnvironmentListing returns the structured form of env
+func newEnvironmentListing(env *environment.Environment, active bool) environmentListing {
+	listing := environmentListing{
+		Name:         env.Name,
+		Description:  env.Description,
+		Active:       active,
+		Tools:        []string{},
+		Tags:         env.Tags,
+		Protected:    env.Protected,
+		SnapshotSize: env.SnapshotSize(),
+	}
+
+	if !env.LastUsed.IsZero() {
+		lastUsed := env.LastUsed
+		listing.LastUsed = &lastUsed
+	}
+	if !env.LastSnapshot.IsZero() {
+		lastSnapshot := env.LastSnapshot
+		listing.LastSnapshot = &lastSnapshot
+	}
+
+	for toolName, toolConfig := range env.Tools {
+		if !toolConfig.Enabled {
+			continue
+		}
+		listing.Tools = append(listing.Tools, toolName)
+		if len(toolConfig.Metadata) > 0 {
+			if listing.Metadata == nil {
+				listing.Metadata = make(map[string]map[string]interface{})
+			}
+			listing.Metadata[toolName] = toolConfig.Metadata
+		}
+	}
+	sort.Strings(listing.Tools)
+
+	return listing
+}
+
+// printWideList prints environments as a table with per-tool details
+func printWideList(listings []environmentListing) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	headers := []string{"NAME", "ACTIVE", "TOOLS"}
+	for _, column := range wideColumns {
+		headers = append(headers, column.header)
+	}
+	headers = append(headers, "SIZE", "LAST USED")
+	fmt.Fprintln(w, strings.Join(headers, "\t"))
+
+	for _, listing := range listings {
+		active := ""
+		if listing.Active {
+			active = "*"
+		}
+
+		row := []string{listing.Name, active, wideValue(strings.Join(listing.Tools, ","))}
+		for _, column := range wideColumns {
+			row = append(row, wideValue(listing.Metadata[column.tool][column.key]))
+		}
+
+		size := "-"
+		if listing.SnapshotSize > 0 {
+			size = humanize.Bytes(listing.SnapshotSize)
+		}
+
+		lastUsed := "never"
+		if listing.LastUsed != nil {
+			lastUsed = formatTimeAgo(*listing.LastUsed)
+		}
+		row = append(row, size, lastUsed)
+
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+
+	_ = w.Flush()
+}
+
+// wideValue formats a metadata value for the wide table
+func wideValue(value interface{}) string {
+	if value == nil || fmt.Sprint(value) == "" {
+		return "-"
+	}
+	return fmt.Sprint(value)
+}
+
 func formatTimeAgo(t time.Time) string {
 	return humanize.Time(t)
 }
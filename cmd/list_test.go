@@ -1,6 +1,8 @@
 package cmd
 
 import (
+	"encoding/json"
+	"io"
 	"os"
 	"path/filepath"
 	"testing"
@@ -8,6 +10,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
 
 	"github.com/hugofrely/envswitch/pkg/environment"
 )
@@ -170,7 +173,7 @@ func TestFormatTimeAgo(t *testing.T) {
 
 func TestListCommand(t *testing.T) {
 	t.Run("has correct metadata", func(t *testing.T) {
-		assert.Equal(t, "list", listCmd.Use)
+		assert.Equal(t, "list [group|pattern]", listCmd.Use)
 		assert.Contains(t, listCmd.Aliases, "ls")
 		assert.NotEmpty(t, listCmd.Short)
 		assert.NotEmpty(t, listCmd.Long)
@@ -182,3 +185,150 @@ func TestListCommand(t *testing.T) {
 		assert.Equal(t, "false", flag.DefValue)
 	})
 }
+
+// captureStdout returns what fn prints to stdout
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	oldStdout := os.Stdout
+	os.Stdout = w
+
+	// Drain while fn runs, output larger than the pipe buffer would block it
+	done := make(chan []byte)
+	go func() {
+		out, _ := io.ReadAll(r)
+		done <- out
+	}()
+
+	fn()
+
+	os.Stdout = oldStdout
+	require.NoError(t, w.Close())
+	return string(<-done)
+}
+
+func TestRunListStructured(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+
+	envsDir := filepath.Join(tempHome, ".envswitch", "environments")
+	lastUsed := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	work := &environment.Environment{
+		Name:        "work",
+		Description: "Work environment",
+		LastUsed:    lastUsed,
+		Path:        filepath.Join(envsDir, "work"),
+		Tools: map[string]environment.ToolConfig{
+			"gcloud": {Enabled: true, Metadata: map[string]interface{}{"project": "acme-prod"}},
+			"aws":    {Enabled: true, Metadata: map[string]interface{}{"profile": "acme"}},
+			"docker": {Enabled: false},
+		},
+	}
+	require.NoError(t, os.MkdirAll(work.Path, 0755))
+	require.NoError(t, work.Save())
+	createEnvWithVars(t, envsDir, "home", nil)
+	require.NoError(t, environment.SetCurrentEnvironment("work"))
+
+	t.Run("json", func(t *testing.T) {
+		listJSON = true
+		defer func() { listJSON = false }()
+
+		out := captureStdout(t, func() { require.NoError(t, runList(listCmd, nil)) })
+
+		var listings []environmentListing
+		require.NoError(t, json.Unmarshal([]byte(out), &listings))
+		require.Len(t, listings, 2)
+
+		byName := map[string]environmentListing{}
+		for _, listing := range listings {
+			byName[listing.Name] = listing
+		}
+
+		assert.True(t, byName["work"].Active)
+		assert.Equal(t, "Work environment", byName["work"].Description)
+		assert.Equal(t, []string{"aws", "gcloud"}, byName["work"].Tools)
+		require.NotNil(t, byName["work"].LastUsed)
+		assert.True(t, lastUsed.Equal(*byName["work"].LastUsed))
+		assert.Equal(t, "acme-prod", byName["work"].Metadata["gcloud"]["project"])
+		assert.False(t, byName["home"].Active)
+		assert.Nil(t, byName["home"].LastUsed)
+	})
+
+	t.Run("yaml", func(t *testing.T) {
+		listYAML = true
+		defer func() { listYAML = false }()
+
+		out := captureStdout(t, func() { require.NoError(t, runList(listCmd, nil)) })
+
+		var listings []environmentListing
+		require.NoError(t, yaml.Unmarshal([]byte(out), &listings))
+		assert.Len(t, listings, 2)
+	})
+
+	t.Run("output flag", func(t *testing.T) {
+		setOutputFormat(t, "yaml")
+
+		out := captureStdout(t, func() { require.NoError(t, runList(listCmd, nil)) })
+
+		var listings []environmentListing
+		require.NoError(t, yaml.Unmarshal([]byte(out), &listings))
+		assert.Len(t, listings, 2)
+	})
+
+	t.Run("wide", func(t *testing.T) {
+		listWide = true
+		defer func() { listWide = false }()
+
+		out := captureStdout(t, func() { require.NoError(t, runList(listCmd, nil)) })
+
+		assert.Contains(t, out, "GCLOUD PROJECT")
+		assert.Contains(t, out, "acme-prod")
+		assert.Contains(t, out, "aws,gcloud")
+		assert.Contains(t, out, "never")
+	})
+
+	t.Run("json without environments is an empty array", func(t *testing.T) {
+		t.Setenv("HOME", t.TempDir())
+		listJSON = true
+		defer func() { listJSON = false }()
+
+		out := captureStdout(t, func() { require.NoError(t, runList(listCmd, nil)) })
+		assert.Equal(t, "[]\n", out)
+	})
+}
+
+func TestRunListGroups(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+
+	envsDir := filepath.Join(tempHome, ".envswitch", "environments")
+	createEnvWithVars(t, envsDir, "personal", nil)
+	createEnvWithVars(t, envsDir, "client-a/prod", nil)
+	createEnvWithVars(t, envsDir, "client-a/dev", nil)
+	createEnvWithVars(t, envsDir, "client-b/prod", nil)
+	require.NoError(t, environment.SetCurrentEnvironment("client-a/dev"))
+
+	t.Run("lists environments under their group", func(t *testing.T) {
+		out := captureStdout(t, func() { require.NoError(t, runList(listCmd, nil)) })
+
+		assert.Contains(t, out, "  personal\n  client-a/\n    * dev (active)\n    prod\n  client-b/\n    prod\n")
+		assert.Contains(t, out, "Total: 4 environments")
+	})
+
+	t.Run("filters by group", func(t *testing.T) {
+		out := captureStdout(t, func() { require.NoError(t, runList(listCmd, []string{"client-a"})) })
+
+		assert.Contains(t, out, "Total: 2 environments")
+		assert.NotContains(t, out, "client-b")
+	})
+
+	t.Run("filters by pattern", func(t *testing.T) {
+		out := captureStdout(t, func() { require.NoError(t, runList(listCmd, []string{"*/prod"})) })
+
+		assert.Contains(t, out, "client-a/")
+		assert.Contains(t, out, "client-b/")
+		assert.Contains(t, out, "Total: 2 environments")
+	})
+}
@@ -0,0 +1,219 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/hugofrely/envswitch/internal/config"
+	"github.com/hugofrely/envswitch/internal/logger"
+	"github.com/hugofrely/envswitch/internal/redact"
+)
+
+// logPollInterval is how often --follow checks the log file for new lines
+const logPollInterval = 500 * time.Millisecond
+
+var (
+	logsFollow bool
+	logsSince  time.Duration
+	logsLevel  string
+	logsLines  int
+)
+
+var logsCmd = &cobra.Command{
+	Use:   "logs",
+	Short: "Show the envswitch log",
+	Long: `Show the envswitch log file configured by log_file, with optional filtering.
+
+Examples:
+  # Last 50 lines
+  envswitch logs
+
+  # Warnings and errors of the last hour
+  envswitch logs --since 1h --level warn
+
+  # Keep printing new lines as they are written
+  envswitch logs --follow`,
+	Args: cobra.NoArgs,
+	RunE: runLogs,
+}
+
+func init() {
+	rootCmd.AddCommand(logsCmd)
+	logsCmd.Flags().BoolVarP(&logsFollow, "follow", "f", false, "Keep printing new lines as they are written")
+	logsCmd.Flags().DurationVar(&logsSince, "since", 0, "Only show lines written within this duration, e.g. 30m or 2h")
+	logsCmd.Flags().StringVar(&logsLevel, "level", "debug", "Minimum level to show (debug, info, warn, error)")
+	logsCmd.Flags().IntVarP(&logsLines, "lines", "n", 50, "Number of lines to show, 0 for all")
+
+	_ = logsCmd.RegisterFlagCompletionFunc("level", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"debug", "info", "warn", "error"}, cobra.ShellCompDirectiveNoFileComp
+	})
+}
+
+// logFilter selects log lines by level and age. Lines that are not log
+// messages, such as the rest of a multi-line message, follow the decision
+// made for the message before them.
+type logFilter struct {
+	minLevel logger.LogLevel
+	since    time.Time
+	keepLast bool
+}
+
+func newLogFilter(level string, since time.Duration) (*logFilter, error) {
+	minLevel, err := logger.ParseLevel(strings.ToLower(level))
+	if err != nil {
+		return nil, err
+	}
+
+	filter := &logFilter{minLevel: minLevel}
+	if since > 0 {
+		filter.since = time.Now().Add(-since)
+	}
+	return filter, nil
+}
+
+// keep reports whether a line should be shown
+func (f *logFilter) keep(line string) bool {
+	entry, ok := logger.ParseEntry(line)
+	if !ok {
+		return f.keepLast
+	}
+
+	f.keepLast = entry.Level >= f.minLevel
+	if !f.since.IsZero() && (entry.Time.IsZero() || entry.Time.Before(f.since)) {
+		f.keepLast = false
+	}
+	return f.keepLast
+}
+
+func runLogs(cmd *cobra.Command, args []string) error {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if cfg.LogFile == "" {
+		return fmt.Errorf("logging to a file is disabled, set log_file to enable it")
+	}
+
+	filter, err := newLogFilter(logsLevel, logsSince)
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+
+	var offset int64
+	file, err := os.Open(cfg.LogFile)
+	switch {
+	case err == nil:
+		offset, err = printLogTail(out, file, filter, logsLines)
+		_ = file.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read log file: %w", err)
+		}
+	case os.IsNotExist(err):
+		if !logsFollow {
+			fmt.Fprintf(out, "No log entries yet (%s)\n", cfg.LogFile)
+			return nil
+		}
+	default:
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+
+	if !logsFollow {
+		return nil
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	return followLog(ctx, out, cfg.LogFile, offset, filter, logPollInterval)
+}
+
+// printLogTail prints the last n lines of r kept by filter (all of them if
+// n is 0) and returns the number of bytes read up to the last complete line
+func printLogTail(out io.Writer, r io.Reader, filter *logFilter, n int) (int64, error) {
+	var kept []string
+	consumed, err := readLogLines(r, func(line string) {
+		if !filter.keep(line) {
+			return
+		}
+		kept = append(kept, line)
+		if n > 0 && len(kept) > n {
+			kept = kept[1:]
+		}
+	})
+
+	// Lines written by older versions were not masked
+	for _, line := range kept {
+		fmt.Fprintln(out, redact.String(line))
+	}
+	return consumed, err
+}
+
+// followLog prints the lines appended to the log file after offset until ctx
+// is done. A file that shrank was truncated or rotated and is read again from
+// the start.
+func followLog(ctx context.Context, out io.Writer, path string, offset int64, filter *logFilter, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if info.Size() < offset {
+			offset = 0
+		}
+		if info.Size() == offset {
+			continue
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		if _, err := file.Seek(offset, io.SeekStart); err == nil {
+			consumed, _ := readLogLines(file, func(line string) {
+				if filter.keep(line) {
+					fmt.Fprintln(out, redact.String(line))
+				}
+			})
+			offset += consumed
+		}
+		_ = file.Close()
+	}
+}
+
+// readLogLines calls fn for each complete line of r and returns the number of
+// bytes consumed. A trailing line without a newline is still being written
+// and is left for the next read.
+func readLogLines(r io.Reader, fn func(line string)) (int64, error) {
+	reader := bufio.NewReader(r)
+	var consumed int64
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err == io.EOF {
+			return consumed, nil
+		}
+		if err != nil {
+			return consumed, err
+		}
+		consumed += int64(len(line))
+		fn(strings.TrimRight(line, "\r\n"))
+	}
+}
@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// syncBuffer is a bytes.Buffer safe for a writer and a reader goroutine
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func logLine(at time.Time, level, msg string) string {
+	return at.Format("2006-01-02 15:04:05") + " [" + level + "] " + msg + "\n"
+}
+
+func TestPrintLogTail(t *testing.T) {
+	now := time.Now()
+	log := logLine(now.Add(-3*time.Hour), "WARN", "old warning") +
+		logLine(now.Add(-10*time.Minute), "DEBUG", "[tools] snapshot git") +
+		logLine(now.Add(-5*time.Minute), "ERROR", "restore failed:") +
+		"  details of the failure\n" +
+		logLine(now.Add(-time.Minute), "INFO", "switched to work") +
+		"[INFO] partial line without newl"
+
+	t.Run("filters by level and age", func(t *testing.T) {
+		filter, err := newLogFilter("warn", time.Hour)
+		require.NoError(t, err)
+
+		var out bytes.Buffer
+		consumed, err := printLogTail(&out, strings.NewReader(log), filter, 0)
+		require.NoError(t, err)
+
+		assert.Equal(t, "restore failed:\n  details of the failure\n", stripLogPrefixes(out.String()))
+		assert.Equal(t, int64(strings.LastIndex(log, "\n")+1), consumed)
+	})
+
+	t.Run("keeps the last lines", func(t *testing.T) {
+		filter, err := newLogFilter("debug", 0)
+		require.NoError(t, err)
+
+		var out bytes.Buffer
+		_, err = printLogTail(&out, strings.NewReader(log), filter, 2)
+		require.NoError(t, err)
+
+		assert.Equal(t, "  details of the failure\nswitched to work\n", stripLogPrefixes(out.String()))
+	})
+
+	t.Run("rejects invalid levels", func(t *testing.T) {
+		_, err := newLogFilter("loud", 0)
+		assert.Error(t, err)
+	})
+}
+
+// stripLogPrefixes removes the timestamp and level of each log line
+func stripLogPrefixes(out string) string {
+	var lines []string
+	for _, line := range strings.SplitAfter(out, "\n") {
+		if idx := strings.Index(line, "] "); idx >= 0 && !strings.HasPrefix(line, " ") {
+			line = line[idx+2:]
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "")
+}
+
+func TestFollowLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "envswitch.log")
+	initial := logLine(time.Now(), "INFO", "already there")
+	require.NoError(t, os.WriteFile(path, []byte(initial), 0644))
+
+	filter, err := newLogFilter("info", 0)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var out syncBuffer
+	done := make(chan error)
+	go func() {
+		done <- followLog(ctx, &out, path, int64(len(initial)), filter, 10*time.Millisecond)
+	}()
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	_, err = file.WriteString(logLine(time.Now(), "DEBUG", "hidden") + logLine(time.Now(), "WARN", "new warning"))
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	assert.Eventually(t, func() bool {
+		return strings.Contains(out.String(), "new warning")
+	}, 2*time.Second, 10*time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-done)
+
+	assert.NotContains(t, out.String(), "already there")
+	assert.NotContains(t, out.String(), "hidden")
+}
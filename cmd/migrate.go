@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+var migrateDryRun bool
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Migrate environments to the current naming rules",
+	Long: `Rename environments whose names are not lowercase.
+
+Environment names are lowercase so that 'Work' and 'work' cannot collide on
+case-insensitive filesystems (macOS, Windows). Environments created before
+this rule are renamed to their lowercase form; when two names collide, the
+second one gets a numeric suffix (work-2). The active environment is updated
+accordingly.
+
+Examples:
+  # Preview the renames
+  envswitch migrate --dry-run
+
+  # Apply them
+  envswitch migrate`,
+	Args: cobra.NoArgs,
+	RunE: runMigrate,
+}
+
+func init() {
+	rootCmd.AddCommand(migrateCmd)
+	migrateCmd.Flags().BoolVar(&migrateDryRun, "dry-run", false, "Show the renames without applying them")
+}
+
+func runMigrate(cmd *cobra.Command, args []string) error {
+	migrations, err := environment.PlanNameMigrations()
+	if err != nil {
+		return fmt.Errorf("failed to plan migration: %w", err)
+	}
+
+	if len(migrations) == 0 {
+		fmt.Println("✅ All environment names are up to date")
+		return nil
+	}
+
+	for _, m := range migrations {
+		note := ""
+		if m.Conflict {
+			note = " (name already taken)"
+		}
+
+		if migrateDryRun {
+			fmt.Printf("  %s → %s%s\n", m.From, m.To, note)
+			continue
+		}
+
+		if err := environment.ApplyNameMigration(m); err != nil {
+			return err
+		}
+		fmt.Printf("✓ %s → %s%s\n", m.From, m.To, note)
+	}
+
+	if migrateDryRun {
+		fmt.Println()
+		fmt.Println("No changes applied (use without --dry-run to apply)")
+		return nil
+	}
+
+	fmt.Printf("\n✅ Renamed %d environment(s)\n", len(migrations))
+	return nil
+}
@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+func TestRunMigrate(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+
+	envsDir := filepath.Join(tempHome, ".envswitch", "environments")
+	require.NoError(t, os.MkdirAll(envsDir, 0755))
+	createEnvWithVars(t, envsDir, "Client-A", nil)
+
+	t.Run("dry run changes nothing", func(t *testing.T) {
+		migrateDryRun = true
+		defer func() { migrateDryRun = false }()
+
+		require.NoError(t, runMigrate(migrateCmd, nil))
+		assert.DirExists(t, filepath.Join(envsDir, "Client-A"))
+	})
+
+	t.Run("renames environments", func(t *testing.T) {
+		require.NoError(t, runMigrate(migrateCmd, nil))
+
+		env, err := environment.LoadEnvironment("client-a")
+		require.NoError(t, err)
+		assert.Equal(t, "client-a", env.Name)
+		assert.Equal(t, filepath.Join(envsDir, "client-a"), env.Path)
+	})
+
+	t.Run("nothing left to migrate", func(t *testing.T) {
+		assert.NoError(t, runMigrate(migrateCmd, nil))
+	})
+}
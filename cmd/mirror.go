@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/hugofrely/envswitch/internal/archive"
+	"github.com/hugofrely/envswitch/internal/config"
+	"github.com/hugofrely/envswitch/internal/logger"
+	"github.com/hugofrely/envswitch/internal/platform"
+)
+
+var (
+	mirrorLog = logger.Named("backup").Named("mirror")
+
+	// mirrorWG tracks mirror copies still running in the background
+	mirrorWG sync.WaitGroup
+)
+
+// mirrorBackup copies a backup archive to backup_mirror_dir in the
+// background. A failed copy is reported but never fails the command.
+func mirrorBackup(archivePath string, cfg *config.Config) {
+	if archivePath == "" || cfg == nil || cfg.BackupMirrorDir == "" {
+		return
+	}
+
+	mirrorDir := expandHomePath(cfg.BackupMirrorDir)
+
+	mirrorWG.Add(1)
+	go func() {
+		defer mirrorWG.Done()
+
+		mirrorPath, err := archive.MirrorArchive(archivePath, mirrorDir)
+		if err != nil {
+			mirrorLog.Warn("Failed to mirror backup %s to %s: %v", filepath.Base(archivePath), mirrorDir, err)
+			return
+		}
+		mirrorLog.Debug("Backup mirrored to %s", mirrorPath)
+	}()
+}
+
+// waitForMirrors blocks until background mirror copies are done, so the
+// process does not exit in the middle of one
+func waitForMirrors() {
+	mirrorWG.Wait()
+}
+
+// expandHomePath expands environment variables and a leading ~ in a path
+// from the config file
+func expandHomePath(path string) string {
+	path = os.ExpandEnv(path)
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := platform.HomeDir(); err == nil {
+			path = filepath.Join(home, strings.TrimPrefix(path, "~"))
+		}
+	}
+	return path
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hugofrely/envswitch/internal/config"
+)
+
+func TestMirrorBackup(t *testing.T) {
+	tempDir := t.TempDir()
+	archivePath := filepath.Join(tempDir, "work-20240101-120000.tar.gz")
+	require.NoError(t, os.WriteFile(archivePath, []byte("archive"), 0644))
+
+	t.Run("copies the archive to the mirror directory", func(t *testing.T) {
+		mirrorDir := filepath.Join(tempDir, "mirror")
+		mirrorBackup(archivePath, &config.Config{BackupMirrorDir: mirrorDir})
+		waitForMirrors()
+
+		data, err := os.ReadFile(filepath.Join(mirrorDir, filepath.Base(archivePath)))
+		require.NoError(t, err)
+		assert.Equal(t, "archive", string(data))
+	})
+
+	t.Run("tolerates failures", func(t *testing.T) {
+		blocker := filepath.Join(tempDir, "blocker")
+		require.NoError(t, os.WriteFile(blocker, []byte("file"), 0644))
+
+		mirrorBackup(archivePath, &config.Config{BackupMirrorDir: filepath.Join(blocker, "mirror")})
+		waitForMirrors()
+	})
+
+	t.Run("does nothing without a mirror directory", func(t *testing.T) {
+		mirrorBackup(archivePath, &config.Config{})
+		mirrorBackup("", &config.Config{BackupMirrorDir: tempDir})
+		waitForMirrors()
+	})
+}
+
+func TestExpandHomePath(t *testing.T) {
+	t.Setenv("HOME", "/home/test")
+	t.Setenv("BACKUP_DISK", "/mnt/backup")
+
+	assert.Equal(t, "/home/test/backups", expandHomePath("~/backups"))
+	assert.Equal(t, "/mnt/backup/envswitch", expandHomePath("$BACKUP_DISK/envswitch"))
+	assert.Equal(t, "/srv/backups", expandHomePath("/srv/backups"))
+}
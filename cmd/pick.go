@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hugofrely/envswitch/internal/picker"
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+// pickEnvironment lets the user choose an environment in the terminal
+func pickEnvironment(title string) (string, error) {
+	environments, err := environment.ListEnvironments()
+	if err != nil {
+		return "", err
+	}
+	if len(environments) == 0 {
+		return "", fmt.Errorf("no environments found, create one with 'envswitch create <name>'")
+	}
+
+	current, _ := environment.GetCurrentEnvironment()
+	items := make([]picker.Item, 0, len(environments))
+	for _, env := range environments {
+		items = append(items, pickerItem(env, current != nil && env.Name == current.Name))
+	}
+
+	index, err := picker.Pick(title, items)
+	if errors.Is(err, picker.ErrNotInteractive) {
+		return "", fmt.Errorf("no environment given and %w", err)
+	}
+	if err != nil {
+		return "", err
+	}
+	return environments[index].Name, nil
+}
+
+// pickerItem returns the picker entry of an environment: its description and
+// when it was last used
+func pickerItem(env *environment.Environment, active bool) picker.Item {
+	detail := env.Description
+	if !env.LastUsed.IsZero() {
+		if detail != "" {
+			detail += " · "
+		}
+		detail += "used " + formatTimeAgo(env.LastUsed)
+	}
+	return picker.Item{Label: env.Name, Detail: detail, Marked: active}
+}
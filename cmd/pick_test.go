@@ -0,0 +1,22 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+func TestPickerItem(t *testing.T) {
+	env := &environment.Environment{Name: "work", Description: "Work environment", LastUsed: time.Now().Add(-2 * time.Hour)}
+	item := pickerItem(env, true)
+	assert.Equal(t, "work", item.Label)
+	assert.Equal(t, "Work environment · used 2 hours ago", item.Detail)
+	assert.True(t, item.Marked)
+
+	item = pickerItem(&environment.Environment{Name: "new"}, false)
+	assert.Empty(t, item.Detail)
+	assert.False(t, item.Marked)
+}
@@ -4,9 +4,13 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
 
 	"github.com/spf13/cobra"
 
+	"github.com/hugofrely/envswitch/internal/config"
 	"github.com/hugofrely/envswitch/pkg/environment"
 	"github.com/hugofrely/envswitch/pkg/plugin"
 )
@@ -21,11 +25,20 @@ custom integrations, and advanced features.
 
 Available commands:
   list      List installed plugins
-  install   Install a plugin
+  install   Install a plugin from the registry or a local path
+  search    Search the plugin registry
+  update    Update plugins installed from the registry
+  enable    Enable a plugin in an environment
+  disable   Disable a plugin in an environment
   remove    Remove a plugin
   info      Show plugin information`,
 }
 
+var (
+	pluginRegistryURL string
+	pluginEnv         string
+)
+
 var pluginListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List installed plugins",
@@ -34,13 +47,18 @@ var pluginListCmd = &cobra.Command{
 }
 
 var pluginInstallCmd = &cobra.Command{
-	Use:   "install <path-to-plugin>",
+	Use:   "install <plugin-name|path>",
 	Short: "Install a plugin",
-	Long: `Install a plugin from a local directory or archive.
+	Long: `Install a plugin from the registry, a local directory or a .tar.gz archive.
 
-The plugin must contain a plugin.yaml manifest file.
+A plugin name is looked up in the plugin index (plugin_registry in the config,
+or --registry); its archive is downloaded and its SHA-256 checksum verified
+before anything is installed. The plugin must contain a plugin.yaml manifest file.
 
 Examples:
+  # Install from the registry
+  envswitch plugin install terraform
+
   # Install from a directory
   envswitch plugin install ./my-plugin
 
@@ -50,21 +68,81 @@ Examples:
 	RunE: runPluginInstall,
 }
 
+var pluginSearchCmd = &cobra.Command{
+	Use:   "search [query]",
+	Short: "Search the plugin registry",
+	Long: `List the plugins of the registry whose name, tool, description or tags
+contain the query. Without a query, every plugin is listed.
+
+Examples:
+  envswitch plugin search
+  envswitch plugin search terraform`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runPluginSearch,
+}
+
+var pluginUpdateCmd = &cobra.Command{
+	Use:   "update [plugin-name...]",
+	Short: "Update plugins from the registry",
+	Long: `Update installed plugins to the version listed in the registry.
+
+Without names, every installed plugin found in the registry is updated.
+Downloads are verified against their SHA-256 checksum before replacing the
+installed plugin.
+
+Examples:
+  envswitch plugin update
+  envswitch plugin update terraform`,
+	RunE:              runPluginUpdate,
+	ValidArgsFunction: completePluginNames,
+}
+
 var pluginRemoveCmd = &cobra.Command{
-	Use:     "remove <plugin-name>",
-	Aliases: []string{"rm", "uninstall"},
-	Short:   "Remove an installed plugin",
-	Long:    `Remove an installed plugin by name.`,
-	Args:    cobra.ExactArgs(1),
-	RunE:    runPluginRemove,
+	Use:               "remove <plugin-name>",
+	Aliases:           []string{"rm", "uninstall"},
+	Short:             "Remove an installed plugin",
+	Long:              `Remove an installed plugin by name.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completePluginNames,
+	RunE:              runPluginRemove,
 }
 
 var pluginInfoCmd = &cobra.Command{
-	Use:   "info <plugin-name>",
-	Short: "Show plugin information",
-	Long:  `Display detailed information about an installed plugin.`,
-	Args:  cobra.ExactArgs(1),
-	RunE:  runPluginInfo,
+	Use:               "info <plugin-name>",
+	Short:             "Show plugin information",
+	Long:              `Display detailed information about an installed plugin.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completePluginNames,
+	RunE:              runPluginInfo,
+}
+
+var pluginEnableCmd = &cobra.Command{
+	Use:   "enable <plugin-name>",
+	Short: "Enable a plugin in an environment",
+	Long: `Enable a plugin in the environment, the active one unless --env is given.
+The tool of the plugin is captured the next time the environment is saved.
+
+Examples:
+  envswitch plugin enable terraform
+  envswitch plugin enable terraform --env work`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completePluginNames,
+	RunE:              runPluginEnable,
+}
+
+var pluginDisableCmd = &cobra.Command{
+	Use:   "disable <plugin-name>",
+	Short: "Disable a plugin in an environment",
+	Long: `Disable a plugin in the environment, the active one unless --env is given.
+Its tool is no longer captured or restored there, and installing or updating
+plugins does not enable it again. Its existing snapshot is kept.
+
+Examples:
+  envswitch plugin disable terraform
+  envswitch plugin disable terraform --env personal`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completePluginNames,
+	RunE:              runPluginDisable,
 }
 
 func init() {
@@ -73,6 +151,29 @@ func init() {
 	pluginCmd.AddCommand(pluginInstallCmd)
 	pluginCmd.AddCommand(pluginRemoveCmd)
 	pluginCmd.AddCommand(pluginInfoCmd)
+	pluginCmd.AddCommand(pluginSearchCmd)
+	pluginCmd.AddCommand(pluginUpdateCmd)
+	pluginCmd.AddCommand(pluginEnableCmd)
+	pluginCmd.AddCommand(pluginDisableCmd)
+
+	for _, c := range []*cobra.Command{pluginEnableCmd, pluginDisableCmd} {
+		c.Flags().StringVar(&pluginEnv, "env", "", "Environment to use (default: active environment)")
+		_ = c.RegisterFlagCompletionFunc("env", completeEnvironmentFlag)
+	}
+
+	for _, c := range []*cobra.Command{pluginInstallCmd, pluginSearchCmd, pluginUpdateCmd} {
+		c.Flags().StringVar(&pluginRegistryURL, "registry", "", "URL or path of the plugin index (default: plugin_registry from the config)")
+	}
+}
+
+// pluginListing is the structured form of an installed plugin in plugin list
+// output
+type pluginListing struct {
+	Name        string   `json:"name" yaml:"name"`
+	Version     string   `json:"version" yaml:"version"`
+	Description string   `json:"description,omitempty" yaml:"description,omitempty"`
+	Tool        string   `json:"tool,omitempty" yaml:"tool,omitempty"`
+	DisabledIn  []string `json:"disabled_in,omitempty" yaml:"disabled_in,omitempty"`
 }
 
 func runPluginList(cmd *cobra.Command, args []string) error {
@@ -81,6 +182,24 @@ func runPluginList(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to list plugins: %w", err)
 	}
 
+	out, err := resultWriter()
+	if err != nil {
+		return err
+	}
+	if out.Structured() {
+		listings := make([]pluginListing, 0, len(plugins))
+		for _, p := range plugins {
+			listings = append(listings, pluginListing{
+				Name:        p.Metadata.Name,
+				Version:     p.Metadata.Version,
+				Description: p.Metadata.Description,
+				Tool:        p.Metadata.ToolName,
+				DisabledIn:  pluginDisabledEnvironments(p.Metadata.Name),
+			})
+		}
+		return out.Write(listings)
+	}
+
 	if len(plugins) == 0 {
 		fmt.Println("No plugins installed.")
 		fmt.Println()
@@ -99,6 +218,9 @@ func runPluginList(cmd *cobra.Command, args []string) error {
 		if p.Metadata.ToolName != "" {
 			fmt.Printf("    Tool: %s\n", p.Metadata.ToolName)
 		}
+		if disabled := pluginDisabledEnvironments(p.Metadata.Name); len(disabled) > 0 {
+			fmt.Printf("    Disabled in: %s\n", strings.Join(disabled, ", "))
+		}
 		fmt.Println()
 	}
 
@@ -107,52 +229,184 @@ func runPluginList(cmd *cobra.Command, args []string) error {
 }
 
 func runPluginInstall(cmd *cobra.Command, args []string) error {
-	sourcePath := args[0]
+	source := args[0]
 
-	// Check if source exists
-	if _, err := os.Stat(sourcePath); os.IsNotExist(err) {
-		return fmt.Errorf("plugin path not found: %s", sourcePath)
+	info, statErr := os.Stat(source)
+	if os.IsNotExist(statErr) && isPluginName(source) {
+		return installPluginFromRegistry(source)
 	}
+	if statErr != nil {
+		return fmt.Errorf("plugin path not found: %s", source)
+	}
+
+	// Archives are extracted before being installed like a directory
+	sourcePath := source
+	if !info.IsDir() {
+		tempDir, err := os.MkdirTemp("", "envswitch-plugin-")
+		if err != nil {
+			return fmt.Errorf("failed to create temporary directory: %w", err)
+		}
+		defer os.RemoveAll(tempDir)
 
+		file, err := os.Open(source)
+		if err != nil {
+			return fmt.Errorf("failed to open plugin archive: %w", err)
+		}
+		err = plugin.ExtractArchive(file, tempDir)
+		_ = file.Close()
+		if err != nil {
+			return fmt.Errorf("failed to extract plugin archive: %w", err)
+		}
+		if sourcePath, err = plugin.FindManifestDir(tempDir); err != nil {
+			return err
+		}
+	}
+
+	manifest, err := installPluginFromDir(sourcePath, false)
+	if err != nil {
+		return err
+	}
+	printPluginInstalled(manifest)
+	return nil
+}
+
+// isPluginName reports whether source names a plugin of the registry rather
+// than a path
+func isPluginName(source string) bool {
+	return source != "" && !strings.ContainsAny(source, `/\`) &&
+		!strings.HasPrefix(source, ".") && !strings.HasSuffix(source, ".tar.gz") && !strings.HasSuffix(source, ".tgz")
+}
+
+// pluginRegistry returns the registry of --registry, plugin_registry or the
+// default index
+func pluginRegistry() (*plugin.Registry, error) {
+	url := pluginRegistryURL
+	if url == "" {
+		cfg, err := config.LoadConfig()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load config: %w", err)
+		}
+		url = cfg.PluginRegistry
+	}
+	return plugin.NewRegistry(url), nil
+}
+
+func installPluginFromRegistry(name string) error {
+	registry, err := pluginRegistry()
+	if err != nil {
+		return err
+	}
+	index, err := registry.FetchIndex()
+	if err != nil {
+		return err
+	}
+
+	entry, ok := index.Find(name)
+	if !ok {
+		return fmt.Errorf("plugin '%s' not found in the registry (see 'envswitch plugin search')", name)
+	}
+
+	installed, err := plugin.IsPluginInstalled(entry.Name)
+	if err != nil {
+		return fmt.Errorf("failed to check if plugin is installed: %w", err)
+	}
+	if installed {
+		return fmt.Errorf("plugin '%s' is already installed (use 'envswitch plugin update %s')", entry.Name, entry.Name)
+	}
+
+	fmt.Printf("📦 Downloading %s v%s...\n", entry.Name, entry.Version)
+	manifest, err := downloadAndInstallPlugin(registry, entry, false)
+	if err != nil {
+		return err
+	}
+	fmt.Println("✓ Checksum verified")
+	printPluginInstalled(manifest)
+	return nil
+}
+
+// downloadAndInstallPlugin downloads the archive of entry and installs it,
+// replacing the installed plugin when replace is set
+func downloadAndInstallPlugin(registry *plugin.Registry, entry *plugin.IndexEntry, replace bool) (*plugin.Manifest, error) {
+	tempDir, err := os.MkdirTemp("", "envswitch-plugin-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	sourcePath, err := registry.Download(entry, tempDir)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, err := plugin.LoadManifest(filepath.Join(sourcePath, "plugin.yaml"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load plugin manifest: %w", err)
+	}
+	if manifest.Metadata.Name != entry.Name {
+		return nil, fmt.Errorf("archive of plugin '%s' holds plugin '%s'", entry.Name, manifest.Metadata.Name)
+	}
+
+	return installPluginFromDir(sourcePath, replace)
+}
+
+// installPluginFromDir copies the plugin in sourcePath to the plugins
+// directory. An installed plugin of the same name is an error unless replace
+// is set, in which case it is swapped for the new one.
+func installPluginFromDir(sourcePath string, replace bool) (*plugin.Manifest, error) {
 	// Check for plugin.yaml manifest
 	manifestPath := filepath.Join(sourcePath, "plugin.yaml")
 	if _, err := os.Stat(manifestPath); os.IsNotExist(err) {
-		return fmt.Errorf("plugin.yaml not found in %s", sourcePath)
+		return nil, fmt.Errorf("plugin.yaml not found in %s", sourcePath)
 	}
 
 	// Load manifest
 	manifest, err := plugin.LoadManifest(manifestPath)
 	if err != nil {
-		return fmt.Errorf("failed to load plugin manifest: %w", err)
+		return nil, fmt.Errorf("failed to load plugin manifest: %w", err)
 	}
 
 	// Check if plugin already installed
 	installed, err := plugin.IsPluginInstalled(manifest.Metadata.Name)
 	if err != nil {
-		return fmt.Errorf("failed to check if plugin is installed: %w", err)
+		return nil, fmt.Errorf("failed to check if plugin is installed: %w", err)
 	}
 
-	if installed {
-		return fmt.Errorf("plugin '%s' is already installed (remove it first)", manifest.Metadata.Name)
+	if installed && !replace {
+		return nil, fmt.Errorf("plugin '%s' is already installed (remove it first)", manifest.Metadata.Name)
 	}
 
 	// Get plugins directory
 	pluginsDir, err := plugin.GetPluginsDir()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Create plugins directory if it doesn't exist
 	if err := os.MkdirAll(pluginsDir, 0755); err != nil {
-		return fmt.Errorf("failed to create plugins directory: %w", err)
+		return nil, fmt.Errorf("failed to create plugins directory: %w", err)
 	}
 
-	// Copy plugin to plugins directory
+	// Copy next to the installed plugin first, so a failed copy leaves it intact
 	destPath := filepath.Join(pluginsDir, manifest.Metadata.Name)
-	if err := copyDir(sourcePath, destPath); err != nil {
-		return fmt.Errorf("failed to install plugin: %w", err)
+	stagingPath := filepath.Join(pluginsDir, "."+manifest.Metadata.Name+".installing")
+	_ = os.RemoveAll(stagingPath)
+	if err := copyDir(sourcePath, stagingPath); err != nil {
+		_ = os.RemoveAll(stagingPath)
+		return nil, fmt.Errorf("failed to install plugin: %w", err)
+	}
+	if err := os.RemoveAll(destPath); err != nil {
+		_ = os.RemoveAll(stagingPath)
+		return nil, fmt.Errorf("failed to replace plugin: %w", err)
+	}
+	if err := os.Rename(stagingPath, destPath); err != nil {
+		return nil, fmt.Errorf("failed to install plugin: %w", err)
 	}
 
+	manifest.Dir = destPath
+	return manifest, nil
+}
+
+func printPluginInstalled(manifest *plugin.Manifest) {
 	fmt.Printf("✅ Plugin '%s' v%s installed successfully\n", manifest.Metadata.Name, manifest.Metadata.Version)
 	if manifest.Metadata.Description != "" {
 		fmt.Printf("   %s\n", manifest.Metadata.Description)
@@ -165,10 +419,155 @@ func runPluginInstall(cmd *cobra.Command, args []string) error {
 	} else {
 		fmt.Println("✅ Plugin enabled in all environments")
 	}
+}
+
+func runPluginSearch(cmd *cobra.Command, args []string) error {
+	query := ""
+	if len(args) > 0 {
+		query = args[0]
+	}
 
+	registry, err := pluginRegistry()
+	if err != nil {
+		return err
+	}
+	index, err := registry.FetchIndex()
+	if err != nil {
+		return err
+	}
+
+	matches := index.Search(query)
+	if len(matches) == 0 {
+		if query == "" {
+			fmt.Println("The registry has no plugins.")
+		} else {
+			fmt.Printf("No plugins match '%s'.\n", query)
+		}
+		return nil
+	}
+
+	installedVersions, err := installedPluginVersions()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tVERSION\tINSTALLED\tDESCRIPTION")
+	for _, entry := range matches {
+		installed := "-"
+		if version, ok := installedVersions[entry.Name]; ok {
+			installed = version
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", entry.Name, entry.Version, installed, entry.Description)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Println()
+	fmt.Println("Install a plugin with: envswitch plugin install <name>")
+	return nil
+}
+
+func runPluginUpdate(cmd *cobra.Command, args []string) error {
+	installedVersions, err := installedPluginVersions()
+	if err != nil {
+		return err
+	}
+	for _, name := range args {
+		if _, ok := installedVersions[name]; !ok {
+			return fmt.Errorf("plugin '%s' is not installed", name)
+		}
+	}
+	if len(installedVersions) == 0 {
+		fmt.Println("No plugins installed.")
+		return nil
+	}
+
+	registry, err := pluginRegistry()
+	if err != nil {
+		return err
+	}
+	index, err := registry.FetchIndex()
+	if err != nil {
+		return err
+	}
+
+	names := args
+	if len(names) == 0 {
+		for name := range installedVersions {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+
+	updated := 0
+	var failed []string
+	for _, name := range names {
+		current := installedVersions[name]
+		entry, ok := index.Find(name)
+		if !ok {
+			// Plugins installed from a path are not in the registry
+			if len(args) > 0 {
+				fmt.Printf("✗ %s: not found in the registry\n", name)
+				failed = append(failed, name)
+			}
+			continue
+		}
+		if plugin.CompareVersions(entry.Version, current) <= 0 {
+			fmt.Printf("✓ %s is up to date (v%s)\n", name, current)
+			continue
+		}
+
+		if _, err := downloadAndInstallPlugin(registry, entry, true); err != nil {
+			fmt.Printf("✗ %s: %v\n", name, err)
+			failed = append(failed, name)
+			continue
+		}
+		fmt.Printf("✅ %s updated: v%s → v%s\n", name, current, entry.Version)
+		updated++
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to update %d plugin(s): %s", len(failed), strings.Join(failed, ", "))
+	}
+	if updated == 0 {
+		fmt.Println("All plugins are up to date.")
+	}
 	return nil
 }
 
+// installedPluginVersions maps the name of each installed plugin to its version
+func installedPluginVersions() (map[string]string, error) {
+	plugins, err := plugin.ListInstalledPlugins()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list plugins: %w", err)
+	}
+
+	versions := make(map[string]string, len(plugins))
+	for _, p := range plugins {
+		versions[p.Metadata.Name] = p.Metadata.Version
+	}
+	return versions, nil
+}
+
+// completePluginNames completes the names of installed plugins
+func completePluginNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	versions, err := installedPluginVersions()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	var names []string
+	for name := range versions {
+		if strings.HasPrefix(name, toComplete) && !containsString(args, name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
 func runPluginRemove(cmd *cobra.Command, args []string) error {
 	pluginName := args[0]
 
@@ -192,27 +591,11 @@ func runPluginRemove(cmd *cobra.Command, args []string) error {
 }
 
 func runPluginInfo(cmd *cobra.Command, args []string) error {
-	pluginName := args[0]
-
-	// Get plugins directory
-	pluginsDir, err := plugin.GetPluginsDir()
+	manifest, err := loadInstalledPlugin(args[0])
 	if err != nil {
 		return err
 	}
 
-	manifestPath := filepath.Join(pluginsDir, pluginName, "plugin.yaml")
-
-	// Check if plugin exists
-	if _, statErr := os.Stat(manifestPath); os.IsNotExist(statErr) {
-		return fmt.Errorf("plugin '%s' is not installed", pluginName)
-	}
-
-	// Load manifest
-	manifest, err := plugin.LoadManifest(manifestPath)
-	if err != nil {
-		return fmt.Errorf("failed to load plugin: %w", err)
-	}
-
 	// Display info
 	fmt.Printf("Plugin: %s\n", manifest.Metadata.Name)
 	fmt.Printf("Version: %s\n", manifest.Metadata.Version)
@@ -235,9 +618,111 @@ func runPluginInfo(cmd *cobra.Command, args []string) error {
 		fmt.Printf("Tags: %v\n", manifest.Metadata.Tags)
 	}
 
+	if commands := manifest.Metadata.Commands; !commands.IsEmpty() {
+		timeout, _ := commands.TimeoutDuration()
+		fmt.Printf("Commands (timeout %s):\n", timeout)
+		for _, command := range []struct{ name, script string }{
+			{"snapshot", commands.SnapshotCmd},
+			{"restore", commands.RestoreCmd},
+			{"metadata", commands.MetadataCmd},
+			{"validate", commands.ValidateCmd},
+		} {
+			if command.script != "" {
+				fmt.Printf("  %s: %s\n", command.name, command.script)
+			}
+		}
+	}
+
+	return nil
+}
+
+// loadInstalledPlugin loads the manifest of an installed plugin
+func loadInstalledPlugin(pluginName string) (*plugin.Manifest, error) {
+	// Get plugins directory
+	pluginsDir, err := plugin.GetPluginsDir()
+	if err != nil {
+		return nil, err
+	}
+
+	manifestPath := filepath.Join(pluginsDir, pluginName, "plugin.yaml")
+
+	// Check if plugin exists
+	if _, statErr := os.Stat(manifestPath); os.IsNotExist(statErr) {
+		return nil, fmt.Errorf("plugin '%s' is not installed", pluginName)
+	}
+
+	// Load manifest
+	manifest, err := plugin.LoadManifest(manifestPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load plugin: %w", err)
+	}
+	return manifest, nil
+}
+
+func runPluginEnable(cmd *cobra.Command, args []string) error {
+	return setPluginEnabled(args[0], true)
+}
+
+func runPluginDisable(cmd *cobra.Command, args []string) error {
+	return setPluginEnabled(args[0], false)
+}
+
+// setPluginEnabled enables or disables an installed plugin in the
+// environment of --env
+func setPluginEnabled(pluginName string, enabled bool) error {
+	manifest, err := loadInstalledPlugin(pluginName)
+	if err != nil {
+		return err
+	}
+
+	env, err := resolveEnvTarget(pluginEnv)
+	if err != nil {
+		return err
+	}
+
+	state := "disabled"
+	if enabled {
+		state = "enabled"
+	}
+	toolName := manifest.Metadata.ToolName
+	_, listed := env.Plugins[pluginName]
+	if listed && env.PluginEnabled(pluginName) == enabled && env.Tools[toolName].Enabled == enabled {
+		fmt.Printf("Plugin '%s' is already %s in '%s'\n", pluginName, state, env.Name)
+		return nil
+	}
+
+	env.SetPluginEnabled(pluginName, toolName, enabled)
+	if err := env.Save(); err != nil {
+		return fmt.Errorf("failed to save environment: %w", err)
+	}
+
+	if enabled {
+		fmt.Printf("✅ Enabled plugin '%s' in '%s'\n", pluginName, env.Name)
+		fmt.Printf("   %s is captured the next time you save '%s' or switch away from it\n", toolName, env.Name)
+	} else {
+		fmt.Printf("✅ Disabled plugin '%s' in '%s' (its snapshot is kept)\n", pluginName, env.Name)
+	}
 	return nil
 }
 
+// pluginDisabledEnvironments returns the environments that disable the
+// plugin, sorted by name
+func pluginDisabledEnvironments(pluginName string) []string {
+	envs, err := environment.ListEnvironments()
+	if err != nil {
+		return nil
+	}
+
+	var names []string
+	for _, env := range envs {
+		if !env.PluginEnabled(pluginName) {
+			names = append(names, env.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
 // copyDir recursively copies a directory (helper function)
 func copyDir(src, dst string) error {
 	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
@@ -1,9 +1,22 @@
 package cmd
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hugofrely/envswitch/pkg/environment"
+	"github.com/hugofrely/envswitch/pkg/plugin"
+	"github.com/hugofrely/envswitch/pkg/tools"
 )
 
 func TestPluginCommand(t *testing.T) {
@@ -24,6 +37,10 @@ func TestPluginCommand(t *testing.T) {
 		assert.Contains(t, commandNames, "install")
 		assert.Contains(t, commandNames, "remove")
 		assert.Contains(t, commandNames, "info")
+		assert.Contains(t, commandNames, "search")
+		assert.Contains(t, commandNames, "update")
+		assert.Contains(t, commandNames, "enable")
+		assert.Contains(t, commandNames, "disable")
 	})
 
 	t.Run("is registered with root command", func(t *testing.T) {
@@ -50,7 +67,7 @@ func TestPluginListCommand(t *testing.T) {
 
 func TestPluginInstallCommand(t *testing.T) {
 	t.Run("has correct metadata", func(t *testing.T) {
-		assert.Equal(t, "install <path-to-plugin>", pluginInstallCmd.Use)
+		assert.Equal(t, "install <plugin-name|path>", pluginInstallCmd.Use)
 		assert.NotEmpty(t, pluginInstallCmd.Short)
 	})
 
@@ -100,3 +117,167 @@ func TestPluginInfoCommand(t *testing.T) {
 		assert.Error(t, err)
 	})
 }
+
+func TestLoadPluginsIntoRegistryWithCommands(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+
+	pluginDir := filepath.Join(tempHome, ".envswitch", "plugins", "vault")
+	require.NoError(t, os.MkdirAll(pluginDir, 0755))
+	manifest := `metadata:
+  name: vault
+  version: 1.0.0
+  tool_name: vault
+  snapshot_cmd: ./snapshot.sh
+  restore_cmd: ./restore.sh
+`
+	require.NoError(t, os.WriteFile(filepath.Join(pluginDir, "plugin.yaml"), []byte(manifest), 0644))
+	installTestPlugin(t, tempHome, "plain", filepath.Join(tempHome, ".plainrc"))
+
+	registry := tools.NewRegistry()
+	loadPluginsIntoRegistry(registry)
+
+	vault, _ := registry.Get("vault")
+	assert.IsType(t, &tools.ExecPluginTool{}, vault)
+	plain, _ := registry.Get("plain")
+	assert.IsType(t, &tools.GenericTool{}, plain)
+
+	output := captureStdout(t, func() {
+		require.NoError(t, runPluginInfo(pluginInfoCmd, []string{"vault"}))
+	})
+	assert.Contains(t, output, "Commands (timeout 30s):")
+	assert.Contains(t, output, "snapshot: ./snapshot.sh")
+	assert.NotContains(t, output, "validate:")
+}
+
+// writeRegistryPlugin writes the archive of a plugin version next to index
+// and lists it there
+func writeRegistryPlugin(t *testing.T, indexPath, name, version string) {
+	t.Helper()
+	manifest := "metadata:\n  name: " + name + "\n  version: " + version + "\n  tool_name: " + name + "\n"
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "plugin.yaml", Mode: 0644, Size: int64(len(manifest)), Typeflag: tar.TypeReg}))
+	_, err := tw.Write([]byte(manifest))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+
+	archivePath := filepath.Join(filepath.Dir(indexPath), name+"-"+version+".tar.gz")
+	require.NoError(t, os.WriteFile(archivePath, buf.Bytes(), 0644))
+	sum := sha256.Sum256(buf.Bytes())
+
+	index := plugin.Index{}
+	if data, err := os.ReadFile(indexPath); err == nil {
+		require.NoError(t, json.Unmarshal(data, &index))
+	}
+	entry := plugin.IndexEntry{Name: name, Version: version, Description: name + " plugin", URL: archivePath, SHA256: hex.EncodeToString(sum[:])}
+	if existing, ok := index.Find(name); ok {
+		*existing = entry
+	} else {
+		index.Plugins = append(index.Plugins, entry)
+	}
+	data, err := json.Marshal(index)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(indexPath, data, 0644))
+}
+
+func TestPluginRegistryCommands(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+	require.NoError(t, os.MkdirAll(filepath.Join(tempHome, ".envswitch"), 0755))
+
+	indexPath := filepath.Join(t.TempDir(), "index.json")
+	writeRegistryPlugin(t, indexPath, "terraform", "1.0.0")
+	writeRegistryPlugin(t, indexPath, "vim", "2.0.0")
+
+	pluginRegistryURL = indexPath
+	defer func() { pluginRegistryURL = "" }()
+
+	output := captureStdout(t, func() {
+		require.NoError(t, runPluginInstall(pluginInstallCmd, []string{"terraform"}))
+	})
+	assert.Contains(t, output, "Checksum verified")
+	installed, err := plugin.IsPluginInstalled("terraform")
+	require.NoError(t, err)
+	assert.True(t, installed)
+
+	err = runPluginInstall(pluginInstallCmd, []string{"docker"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not found in the registry")
+
+	output = captureStdout(t, func() {
+		require.NoError(t, runPluginSearch(pluginSearchCmd, nil))
+	})
+	assert.Regexp(t, `terraform\s+1\.0\.0\s+1\.0\.0`, output)
+	assert.Regexp(t, `vim\s+2\.0\.0\s+-`, output)
+
+	output = captureStdout(t, func() {
+		require.NoError(t, runPluginUpdate(pluginUpdateCmd, nil))
+	})
+	assert.Contains(t, output, "terraform is up to date (v1.0.0)")
+
+	writeRegistryPlugin(t, indexPath, "terraform", "1.1.0")
+	output = captureStdout(t, func() {
+		require.NoError(t, runPluginUpdate(pluginUpdateCmd, []string{"terraform"}))
+	})
+	assert.Contains(t, output, "terraform updated: v1.0.0 → v1.1.0")
+
+	versions, err := installedPluginVersions()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"terraform": "1.1.0"}, versions)
+
+	assert.Error(t, runPluginUpdate(pluginUpdateCmd, []string{"vim"}))
+}
+
+func TestRunPluginEnableDisable(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+
+	envsDir := filepath.Join(tempHome, ".envswitch", "environments")
+	require.NoError(t, os.MkdirAll(envsDir, 0755))
+	createEnvWithVars(t, envsDir, "work", nil)
+	createEnvWithVars(t, envsDir, "personal", nil)
+	installTestPlugin(t, tempHome, "npm", filepath.Join(tempHome, ".npmrc"))
+
+	pluginEnv = "personal"
+	defer func() { pluginEnv = "" }()
+
+	out := captureStdout(t, func() {
+		require.NoError(t, runPluginDisable(pluginDisableCmd, []string{"npm"}))
+	})
+	assert.Contains(t, out, "✅ Disabled plugin 'npm' in 'personal'")
+
+	out = captureStdout(t, func() {
+		require.NoError(t, runPluginDisable(pluginDisableCmd, []string{"npm"}))
+	})
+	assert.Contains(t, out, "already disabled")
+
+	// Syncing plugins leaves the disabled environment alone
+	require.NoError(t, environment.SyncPluginsToEnvironments())
+	personal, err := environment.LoadEnvironment("personal")
+	require.NoError(t, err)
+	assert.False(t, personal.Tools["npm"].Enabled)
+	assert.Equal(t, map[string]bool{"npm": false}, personal.Plugins)
+
+	work, err := environment.LoadEnvironment("work")
+	require.NoError(t, err)
+	assert.True(t, work.Tools["npm"].Enabled)
+
+	out = captureStdout(t, func() {
+		require.NoError(t, runPluginList(pluginListCmd, nil))
+	})
+	assert.Contains(t, out, "Disabled in: personal")
+
+	out = captureStdout(t, func() {
+		require.NoError(t, runPluginEnable(pluginEnableCmd, []string{"npm"}))
+	})
+	assert.Contains(t, out, "✅ Enabled plugin 'npm' in 'personal'")
+	personal, err = environment.LoadEnvironment("personal")
+	require.NoError(t, err)
+	assert.True(t, personal.Tools["npm"].Enabled)
+
+	assert.ErrorContains(t, runPluginEnable(pluginEnableCmd, []string{"vim"}), "not installed")
+}
@@ -0,0 +1,203 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/hugofrely/envswitch/internal/config"
+	"github.com/hugofrely/envswitch/internal/logger"
+	"github.com/hugofrely/envswitch/internal/shell"
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+const (
+	// promptDirtyMark follows the environment name when its live config
+	// drifted from the snapshot
+	promptDirtyMark = "*"
+	// promptStatusMaxAge is how long the cached drift is shown before it is
+	// computed again
+	promptStatusMaxAge = 30 * time.Second
+)
+
+var (
+	promptRefresh bool
+	promptJSON    bool
+)
+
+var promptCmd = &cobra.Command{
+	Use:   "prompt",
+	Short: "Print the active environment for the shell prompt",
+	Long: `Print the name of the active environment, followed by '*' when its live
+config drifted from the snapshot, and nothing when no environment is active.
+
+The drift is read from a cache refreshed by switch, save and status, so the
+command stays fast enough to run on every prompt. When the cache is older than
+30 seconds it is recomputed in the background and the next prompt shows it.
+
+The scripts of 'envswitch shell init' call this command. With --json it
+prints the environment, its drift and the text of prompt_format as a JSON
+object, for prompt frameworks that read JSON.
+
+'envswitch prompt starship' and 'envswitch prompt oh-my-posh' print a module
+for those prompts calling this command.
+
+Examples:
+  envswitch prompt
+  envswitch prompt --json
+  PS1='$(envswitch prompt) \$ '`,
+	Args: cobra.NoArgs,
+	RunE: runPrompt,
+}
+
+var promptStarshipCmd = &cobra.Command{
+	Use:   shell.PromptStarship,
+	Short: "Print a starship module showing the active environment",
+	Long: `Print a custom module for starship showing the active environment in the
+format of prompt_format and the color of prompt_color.
+
+Starship draws the whole prompt: turn off the prompt of the shell integration
+with 'envswitch config set enable_prompt_integration false', which keeps
+loading the environment variables.
+
+Examples:
+  envswitch prompt starship >> ~/.config/starship.toml`,
+	Args: cobra.NoArgs,
+	RunE: runPromptModule,
+}
+
+var promptOhMyPoshCmd = &cobra.Command{
+	Use:   shell.PromptOhMyPosh,
+	Short: "Print an oh-my-posh segment showing the active environment",
+	Long: `Print a command segment for oh-my-posh showing the active environment in
+the format of prompt_format and the color of prompt_color. Add it to the
+segments of a block of your theme.
+
+oh-my-posh draws the whole prompt: turn off the prompt of the shell
+integration with 'envswitch config set enable_prompt_integration false', which
+keeps loading the environment variables.
+
+Examples:
+  envswitch prompt oh-my-posh`,
+	Args: cobra.NoArgs,
+	RunE: runPromptModule,
+}
+
+func init() {
+	rootCmd.AddCommand(promptCmd)
+	promptCmd.AddCommand(promptStarshipCmd)
+	promptCmd.AddCommand(promptOhMyPoshCmd)
+	promptCmd.Flags().BoolVar(&promptRefresh, "refresh", false, "Recompute the cached drift of the active environment")
+	_ = promptCmd.Flags().MarkHidden("refresh")
+	promptCmd.Flags().BoolVar(&promptJSON, "json", false, "Output as JSON with the drift and prompt text")
+}
+
+// promptInfo is the output of 'envswitch prompt --json'
+type promptInfo struct {
+	Environment string `json:"environment"`
+	Dirty       bool   `json:"dirty"`
+	Text        string `json:"text"`
+}
+
+// runPrompt never fails, a broken prompt is worse than a missing marker
+func runPrompt(cmd *cobra.Command, args []string) error {
+	name, err := environment.GetCurrentEnvironmentName()
+	if err != nil || name == "" {
+		if promptJSON {
+			printPromptJSON(promptInfo{})
+		}
+		return nil
+	}
+
+	if promptRefresh {
+		refreshPromptStatus(name)
+		return nil
+	}
+
+	status, _ := environment.LoadPromptStatus()
+	dirty := status != nil && status.Environment == name && status.Dirty
+	if !status.Fresh(name, promptStatusMaxAge, time.Now()) {
+		// Marked as checked first so that the prompts drawn meanwhile do not
+		// start more refreshes
+		recordPromptStatus(name, dirty)
+		startPromptRefresh()
+	}
+
+	mark := ""
+	if dirty {
+		mark = promptDirtyMark
+	}
+	if promptJSON {
+		cfg, err := config.LoadConfig()
+		if err != nil {
+			cfg = config.DefaultConfig()
+		}
+		printPromptJSON(promptInfo{Environment: name, Dirty: dirty, Text: shell.FormatPrompt(cfg.PromptFormat, name+mark)})
+		return nil
+	}
+	fmt.Println(name + mark)
+	return nil
+}
+
+// printPromptJSON prints the prompt as a single line of JSON
+func printPromptJSON(info promptInfo) {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return
+	}
+	fmt.Println(string(data))
+}
+
+func runPromptModule(cmd *cobra.Command, args []string) error {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		cfg = config.DefaultConfig()
+	}
+
+	module, err := shell.GeneratePromptModule(cmd.Name(), cfg)
+	if err != nil {
+		return err
+	}
+	fmt.Print(module)
+	return nil
+}
+
+// refreshPromptStatus diffs the environment with the live config and caches
+// whether it drifted
+func refreshPromptStatus(name string) {
+	env, err := environment.LoadEnvironment(name)
+	if err != nil {
+		return
+	}
+	recordPromptStatus(name, computeStatus(env).Dirty)
+}
+
+// recordPromptStatus caches the drift of an environment for the shell prompt
+func recordPromptStatus(name string, dirty bool) {
+	status := environment.PromptStatus{Environment: name, Dirty: dirty, CheckedAt: time.Now()}
+	if err := environment.SavePromptStatus(status); err != nil {
+		logger.Debug("Failed to cache prompt status: %v", err)
+	}
+}
+
+// startPromptRefresh runs 'envswitch prompt --refresh' without waiting for it
+var startPromptRefresh = func() {
+	executable, err := os.Executable()
+	if err != nil {
+		return
+	}
+
+	// #nosec G204 - Runs envswitch itself
+	refresh := exec.Command(executable, "prompt", "--refresh")
+	// Not attached to the output of the prompt, which would wait for it
+	refresh.Stdin, refresh.Stdout, refresh.Stderr = nil, nil, nil
+	if err := refresh.Start(); err != nil {
+		logger.Debug("Failed to refresh prompt status: %v", err)
+		return
+	}
+	_ = refresh.Process.Release()
+}
@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+func TestRunPrompt(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+
+	envsDir := filepath.Join(tempHome, ".envswitch", "environments")
+	require.NoError(t, os.MkdirAll(envsDir, 0755))
+
+	refreshes := 0
+	originalStart := startPromptRefresh
+	startPromptRefresh = func() { refreshes++ }
+	defer func() { startPromptRefresh = originalStart }()
+
+	t.Run("without an active environment", func(t *testing.T) {
+		out := captureStdout(t, func() {
+			require.NoError(t, runPrompt(promptCmd, nil))
+		})
+		assert.Empty(t, out)
+		assert.Zero(t, refreshes)
+	})
+
+	configPath := filepath.Join(tempHome, ".testrc")
+	require.NoError(t, os.WriteFile(configPath, []byte("snapshot"), 0644))
+	installTestPlugin(t, tempHome, "testtool", configPath)
+
+	env := createEnvWithVars(t, envsDir, "work", nil)
+	env.Tools["testtool"] = environment.ToolConfig{Enabled: true}
+	snapshotDir := filepath.Join(env.Path, "snapshots", "testtool")
+	require.NoError(t, os.MkdirAll(snapshotDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(snapshotDir, ".testrc"), []byte("snapshot"), 0644))
+	require.NoError(t, env.Save())
+	require.NoError(t, environment.SetCurrentEnvironment("work"))
+
+	t.Run("starts a refresh when nothing is cached", func(t *testing.T) {
+		out := captureStdout(t, func() {
+			require.NoError(t, runPrompt(promptCmd, nil))
+		})
+		assert.Equal(t, "work\n", out)
+		assert.Equal(t, 1, refreshes)
+
+		// The prompts drawn before the refresh ends do not start another one
+		captureStdout(t, func() {
+			require.NoError(t, runPrompt(promptCmd, nil))
+		})
+		assert.Equal(t, 1, refreshes)
+	})
+
+	t.Run("refresh caches the drift", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(configPath, []byte("live"), 0644))
+
+		promptRefresh = true
+		out := captureStdout(t, func() {
+			require.NoError(t, runPrompt(promptCmd, nil))
+		})
+		promptRefresh = false
+		assert.Empty(t, out)
+
+		status, err := environment.LoadPromptStatus()
+		require.NoError(t, err)
+		require.NotNil(t, status)
+		assert.Equal(t, "work", status.Environment)
+		assert.True(t, status.Dirty)
+
+		out = captureStdout(t, func() {
+			require.NoError(t, runPrompt(promptCmd, nil))
+		})
+		assert.Equal(t, "work*\n", out)
+	})
+
+	t.Run("stale cache is shown while it is refreshed", func(t *testing.T) {
+		require.NoError(t, environment.SavePromptStatus(environment.PromptStatus{
+			Environment: "work",
+			Dirty:       true,
+			CheckedAt:   time.Now().Add(-time.Hour),
+		}))
+
+		out := captureStdout(t, func() {
+			require.NoError(t, runPrompt(promptCmd, nil))
+		})
+		assert.Equal(t, "work*\n", out)
+		assert.Equal(t, 2, refreshes)
+	})
+
+	t.Run("json output", func(t *testing.T) {
+		promptJSON = true
+		defer func() { promptJSON = false }()
+
+		out := captureStdout(t, func() {
+			require.NoError(t, runPrompt(promptCmd, nil))
+		})
+		assert.JSONEq(t, `{"environment": "work", "dirty": true, "text": "(work*)"}`, out)
+	})
+
+	t.Run("save marks the environment clean", func(t *testing.T) {
+		captureStdout(t, func() {
+			require.NoError(t, runSave(saveCmd, nil))
+		})
+
+		out := captureStdout(t, func() {
+			require.NoError(t, runPrompt(promptCmd, nil))
+		})
+		assert.Equal(t, "work\n", out)
+	})
+}
+
+func TestRunPromptModule(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	out := captureStdout(t, func() {
+		require.NoError(t, runPromptModule(promptStarshipCmd, nil))
+	})
+	assert.Contains(t, out, "[custom.envswitch]")
+
+	out = captureStdout(t, func() {
+		require.NoError(t, runPromptModule(promptOhMyPoshCmd, nil))
+	})
+	assert.Contains(t, out, `"type": "command"`)
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+var protectCmd = &cobra.Command{
+	Use:   "protect <environment>",
+	Short: "Require a confirmation to switch to, delete or restore an environment",
+	Long: `Protect an environment, such as the one holding production credentials.
+
+Switching to a protected environment, deleting it and restoring a backup over
+it ask you to type its name first, unless --yes is given. Replacing it with
+import or receive --force, or updating it with apply, always asks.
+
+Examples:
+  envswitch protect prod
+  envswitch unprotect prod`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeEnvironmentNames,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setProtected(args[0], true)
+	},
+}
+
+var unprotectCmd = &cobra.Command{
+	Use:               "unprotect <environment>",
+	Short:             "Stop requiring a confirmation for an environment",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeEnvironmentNames,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setProtected(args[0], false)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(protectCmd)
+	rootCmd.AddCommand(unprotectCmd)
+}
+
+// setProtected sets the protected flag of an environment
+func setProtected(name string, protected bool) error {
+	env, err := environment.LoadEnvironment(name)
+	if err != nil {
+		return fmt.Errorf("environment '%s' not found: %w", name, err)
+	}
+
+	if env.Protected == protected {
+		if protected {
+			fmt.Printf("'%s' is already protected\n", env.Name)
+		} else {
+			fmt.Printf("'%s' is not protected\n", env.Name)
+		}
+		return nil
+	}
+
+	env.Protected = protected
+	if err := env.Save(); err != nil {
+		return fmt.Errorf("failed to save environment: %w", err)
+	}
+
+	if protected {
+		fmt.Printf("🔒 '%s' is protected: switching to it, deleting it or restoring over it asks for confirmation\n", env.Name)
+	} else {
+		fmt.Printf("✅ '%s' is no longer protected\n", env.Name)
+	}
+	return nil
+}
+
+// protectedInput is where confirmations of protected environments are read
+var protectedInput io.Reader = os.Stdin
+
+// confirmProtected asks to type the name of a protected environment before
+// an action on it, such as "switch to it"
+func confirmProtected(env *environment.Environment, action string) bool {
+	fmt.Printf("🔒 '%s' is protected. Type its name to %s: ", env.Name, action)
+	response, err := bufio.NewReader(protectedInput).ReadString('\n')
+	if err != nil && response == "" {
+		fmt.Println()
+		return false
+	}
+	return strings.TrimSpace(response) == env.Name
+}
@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+// setProtectedInput answers the confirmations of protected environments
+func setProtectedInput(t *testing.T, answer string) {
+	t.Helper()
+	original := protectedInput
+	protectedInput = strings.NewReader(answer)
+	t.Cleanup(func() { protectedInput = original })
+}
+
+func TestSetProtected(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+	createEnvWithVars(t, filepath.Join(tempHome, ".envswitch", "environments"), "prod", nil)
+
+	captureStdout(t, func() { require.NoError(t, setProtected("prod", true)) })
+	env, err := environment.LoadEnvironment("prod")
+	require.NoError(t, err)
+	assert.True(t, env.Protected)
+
+	captureStdout(t, func() { require.NoError(t, setProtected("prod", false)) })
+	env, err = environment.LoadEnvironment("prod")
+	require.NoError(t, err)
+	assert.False(t, env.Protected)
+
+	assert.Error(t, setProtected("missing", true))
+}
+
+func TestConfirmProtected(t *testing.T) {
+	env := &environment.Environment{Name: "prod"}
+
+	tests := []struct {
+		answer   string
+		expected bool
+	}{
+		{"prod\n", true},
+		{"  prod  \n", true},
+		{"prod", true},
+		{"y\n", false},
+		{"\n", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		setProtectedInput(t, tt.answer)
+		var confirmed bool
+		captureStdout(t, func() { confirmed = confirmProtected(env, "switch to it") })
+		assert.Equal(t, tt.expected, confirmed, "answer %q", tt.answer)
+	}
+}
+
+func TestSwitchToProtected(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("HOME", tempDir)
+	require.NoError(t, runInit(initCmd, []string{}))
+
+	createTestEnv(t, tempDir, "dev")
+	prod := createTestEnv(t, tempDir, "prod")
+	prod.Protected = true
+	require.NoError(t, prod.Save())
+	require.NoError(t, environment.SetCurrentEnvironment("dev"))
+
+	t.Run("cancels without the name", func(t *testing.T) {
+		setProtectedInput(t, "y\n")
+		captureStdout(t, func() {
+			err := switchEnvironment("prod")
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "canceled")
+		})
+		name, _ := environment.GetCurrentEnvironmentName()
+		assert.Equal(t, "dev", name)
+	})
+
+	t.Run("switches after typing the name", func(t *testing.T) {
+		setProtectedInput(t, "prod\n")
+		captureStdout(t, func() { require.NoError(t, switchEnvironment("prod")) })
+		name, _ := environment.GetCurrentEnvironmentName()
+		assert.Equal(t, "prod", name)
+	})
+
+	t.Run("switches with --yes", func(t *testing.T) {
+		require.NoError(t, environment.SetCurrentEnvironment("dev"))
+		setProtectedInput(t, "")
+		switchYes = true
+		defer func() { switchYes = false }()
+
+		captureStdout(t, func() { require.NoError(t, switchEnvironment("prod")) })
+		name, _ := environment.GetCurrentEnvironmentName()
+		assert.Equal(t, "prod", name)
+	})
+}
+
+func TestDeleteProtected(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+	envsDir := filepath.Join(tempHome, ".envswitch", "environments")
+	prod := createEnvWithVars(t, envsDir, "prod", nil)
+	prod.Protected = true
+	require.NoError(t, prod.Save())
+
+	deleteNoArchive = true
+	defer func() { deleteNoArchive = false }()
+
+	setProtectedInput(t, "y\n")
+	captureStdout(t, func() { require.NoError(t, runDelete(deleteCmd, []string{"prod"})) })
+	assert.DirExists(t, prod.Path, "a y/N answer does not delete a protected environment")
+
+	setProtectedInput(t, "prod\n")
+	captureStdout(t, func() { require.NoError(t, runDelete(deleteCmd, []string{"prod"})) })
+	assert.NoDirExists(t, prod.Path)
+}
@@ -0,0 +1,244 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+
+	"github.com/hugofrely/envswitch/internal/archive"
+	"github.com/hugofrely/envswitch/internal/config"
+	"github.com/hugofrely/envswitch/internal/history"
+	"github.com/hugofrely/envswitch/internal/logger"
+	"github.com/hugofrely/envswitch/internal/shell"
+	"github.com/hugofrely/envswitch/internal/storage"
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+var (
+	purgeArchives bool
+	purgeLogs     bool
+	purgeHistory  bool
+	purgeYes      bool
+)
+
+var purgeCmd = &cobra.Command{
+	Use:   "purge",
+	Short: "Remove what envswitch stores, entirely or selectively",
+	Long: `Remove ~/.envswitch entirely: environments, snapshots, backups, archives,
+logs, history and configuration. The backup schedule and the shell integration
+of the shell envswitch runs from are removed too, and the log file when
+log_file points outside ~/.envswitch. Your tool configurations are left as
+they are.
+
+With --archives, --logs or --history, only those are removed:
+  --archives  Backups and archived environments (~/.envswitch/archives)
+  --logs      The log file, its rotated files and the per-switch logs
+  --history   The switch history
+
+What will be removed is listed, with its size, before asking for
+confirmation.
+
+Examples:
+  envswitch purge
+  envswitch purge --logs --history
+  envswitch purge --archives --yes`,
+	Args: cobra.NoArgs,
+	RunE: runPurge,
+}
+
+func init() {
+	rootCmd.AddCommand(purgeCmd)
+	purgeCmd.Flags().BoolVar(&purgeArchives, "archives", false, "Remove the backups and archived environments")
+	purgeCmd.Flags().BoolVar(&purgeLogs, "logs", false, "Remove the log files")
+	purgeCmd.Flags().BoolVar(&purgeHistory, "history", false, "Remove the switch history")
+	purgeCmd.Flags().BoolVarP(&purgeYes, "yes", "y", false, "Skip confirmation")
+}
+
+// purgeItem is something purge removes
+type purgeItem struct {
+	Label  string
+	Paths  []string
+	Remove func() error // removes the item, its paths when nil
+}
+
+// remove removes the item
+func (i purgeItem) remove() error {
+	if i.Remove != nil {
+		return i.Remove()
+	}
+	for _, path := range i.Paths {
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// size returns the disk usage of the paths of the item
+func (i purgeItem) size() uint64 {
+	var size uint64
+	for _, path := range i.Paths {
+		size += storage.PathSize(path)
+	}
+	return size
+}
+
+func runPurge(cmd *cobra.Command, args []string) error {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		cfg = config.DefaultConfig()
+	}
+
+	var items []purgeItem
+	if purgeArchives || purgeLogs || purgeHistory {
+		items, err = selectedPurgeItems(cfg)
+	} else {
+		items, err = allPurgeItems(cfg)
+	}
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		fmt.Println("Nothing to remove.")
+		return nil
+	}
+
+	fmt.Println("The following will be removed:")
+	for _, item := range items {
+		if len(item.Paths) == 0 {
+			fmt.Printf("  %s\n", item.Label)
+			continue
+		}
+		fmt.Printf("  %s (%s)\n", item.Label, humanize.Bytes(item.size()))
+		for _, path := range item.Paths {
+			fmt.Printf("    %s\n", path)
+		}
+	}
+	fmt.Println()
+
+	if !purgeYes && !confirmDeletion("all of the above") {
+		fmt.Println("Canceled.")
+		return nil
+	}
+
+	var failed []string
+	for _, item := range items {
+		if err := item.remove(); err != nil {
+			fmt.Printf("✗ %s: %v\n", item.Label, err)
+			failed = append(failed, item.Label)
+			continue
+		}
+		fmt.Printf("🗑️  Removed %s\n", item.Label)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to remove %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// selectedPurgeItems returns what --archives, --logs and --history remove
+func selectedPurgeItems(cfg *config.Config) ([]purgeItem, error) {
+	var items []purgeItem
+	if purgeArchives {
+		dir, err := archive.GetArchiveDir()
+		if err != nil {
+			return nil, err
+		}
+		if pathExists(dir) {
+			items = append(items, purgeItem{Label: "backups and archived environments", Paths: []string{dir}})
+		}
+	}
+	if purgeLogs {
+		logs := logFiles(cfg.LogFile)
+		dir, err := getSwitchLogDir()
+		if err != nil {
+			return nil, err
+		}
+		if pathExists(dir) {
+			logs = append(logs, dir)
+		}
+		if len(logs) > 0 {
+			items = append(items, purgeItem{Label: "logs", Paths: logs})
+		}
+	}
+	if purgeHistory {
+		files, err := history.Files()
+		if err != nil {
+			return nil, err
+		}
+		if len(files) > 0 {
+			items = append(items, purgeItem{Label: "switch history", Paths: files})
+		}
+	}
+	return items, nil
+}
+
+// allPurgeItems returns what a full purge removes. The backup schedule and
+// the shell integration come first so that neither runs envswitch again
+// once ~/.envswitch is gone.
+func allPurgeItems(cfg *config.Config) ([]purgeItem, error) {
+	var items []purgeItem
+
+	if schedule, err := archive.LoadSchedule(); err == nil && schedule != nil {
+		items = append(items, purgeItem{
+			Label: fmt.Sprintf("backup schedule (%s)", schedule.String()),
+			Remove: func() error {
+				if err := archive.RemoveSchedule(); err != nil && !errors.Is(err, archive.ErrNoSchedule) {
+					return err
+				}
+				return nil
+			},
+		})
+	}
+
+	shellType := shell.DetectShell()
+	if status, err := shell.CheckIntegration(shellType); err == nil && status.Installed {
+		items = append(items, purgeItem{
+			Label: fmt.Sprintf("shell integration in %s", status.ConfigFile),
+			Remove: func() error {
+				_, _, err := shell.UninstallIntegration(shellType)
+				return err
+			},
+		})
+	}
+
+	dir, err := environment.GetEnvswitchDir()
+	if err != nil {
+		return nil, err
+	}
+	if pathExists(dir) {
+		items = append(items, purgeItem{Label: "envswitch data", Paths: []string{dir}})
+	}
+
+	// A log file kept elsewhere goes too
+	if cfg.LogFile != "" && !pathWithin(cfg.LogFile, dir) {
+		if logs := logFiles(cfg.LogFile); len(logs) > 0 {
+			items = append(items, purgeItem{Label: "log file", Paths: logs})
+		}
+	}
+	return items, nil
+}
+
+// logFiles returns the log file at path, when it exists, and its rotated
+// files
+func logFiles(path string) []string {
+	if path == "" {
+		return nil
+	}
+	var files []string
+	if pathExists(path) {
+		files = append(files, path)
+	}
+	return append(files, logger.RotatedFiles(path)...)
+}
+
+// pathWithin reports whether path is dir or inside it
+func pathWithin(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setPurgeFlags sets the purge flags for a test
+func setPurgeFlags(t *testing.T, archives, logs, history bool) {
+	t.Helper()
+	purgeArchives, purgeLogs, purgeHistory, purgeYes = archives, logs, history, true
+	t.Cleanup(func() {
+		purgeArchives, purgeLogs, purgeHistory, purgeYes = false, false, false, false
+	})
+}
+
+func TestPurgeSelected(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+	dir := filepath.Join(tempHome, ".envswitch")
+
+	files := map[string]string{
+		"envswitch.log":                     "log",
+		"logs/switch-20240101-100000.log":   "switch log",
+		"history.jsonl":                     "{}\n",
+		"archives/work-20240101.tar.gz":     "backup",
+		"environments/work/metadata.yaml":   "name: work\n",
+		"archives/environments/old.tar.gz":  "archived",
+		"environments/work/snapshots/x.txt": "snapshot",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+
+	setPurgeFlags(t, false, true, true)
+	out := captureStdout(t, func() {
+		require.NoError(t, runPurge(purgeCmd, nil))
+	})
+	assert.Contains(t, out, "switch history")
+
+	assert.NoFileExists(t, filepath.Join(dir, "envswitch.log"))
+	assert.NoDirExists(t, filepath.Join(dir, "logs"))
+	assert.NoFileExists(t, filepath.Join(dir, "history.jsonl"))
+	assert.FileExists(t, filepath.Join(dir, "archives", "work-20240101.tar.gz"))
+	assert.DirExists(t, filepath.Join(dir, "environments", "work"))
+
+	setPurgeFlags(t, true, false, false)
+	captureStdout(t, func() {
+		require.NoError(t, runPurge(purgeCmd, nil))
+	})
+	assert.NoDirExists(t, filepath.Join(dir, "archives"))
+	assert.DirExists(t, filepath.Join(dir, "environments", "work"))
+
+	out = captureStdout(t, func() {
+		require.NoError(t, runPurge(purgeCmd, nil))
+	})
+	assert.Contains(t, out, "Nothing to remove")
+}
+
+func TestPurgeAll(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+	t.Setenv("SHELL", "/bin/zsh")
+	dir := filepath.Join(tempHome, ".envswitch")
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "environments", "work"), 0755))
+
+	// A log file kept outside ~/.envswitch
+	logFile := filepath.Join(tempHome, "logs", "envswitch.log")
+	require.NoError(t, os.MkdirAll(filepath.Dir(logFile), 0755))
+	require.NoError(t, os.WriteFile(logFile, []byte("log"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "config.yaml"), []byte("log_file: "+logFile+"\n"), 0644))
+
+	zshrc := filepath.Join(tempHome, ".zshrc")
+	require.NoError(t, os.WriteFile(zshrc, []byte("export EDITOR=vim\n\n# envswitch shell integration\neval \"$(envswitch shell init zsh)\"\n"), 0644))
+
+	setPurgeFlags(t, false, false, false)
+	out := captureStdout(t, func() {
+		require.NoError(t, runPurge(purgeCmd, nil))
+	})
+	assert.Contains(t, out, "shell integration in "+zshrc)
+
+	assert.NoDirExists(t, dir)
+	assert.NoFileExists(t, logFile)
+	data, err := os.ReadFile(zshrc)
+	require.NoError(t, err)
+	assert.Equal(t, "export EDITOR=vim\n", string(data))
+}
+
+func TestPathWithin(t *testing.T) {
+	assert.True(t, pathWithin("/home/me/.envswitch/envswitch.log", "/home/me/.envswitch"))
+	assert.True(t, pathWithin("/home/me/.envswitch", "/home/me/.envswitch"))
+	assert.False(t, pathWithin("/home/me/logs/envswitch.log", "/home/me/.envswitch"))
+	assert.False(t, pathWithin("/home/me/.envswitch-old/x", "/home/me/.envswitch"))
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/hugofrely/envswitch/internal/archive"
+	"github.com/hugofrely/envswitch/internal/transfer"
+)
+
+var (
+	receiveCode  string
+	receiveStdin bool
+	receiveForce bool
+)
+
+var receiveCmd = &cobra.Command{
+	Use:   "receive [address]",
+	Short: "Receive environments sent from another machine",
+	Long: `Import the environments 'envswitch send' sends from another machine.
+
+Give the address and pairing code printed by 'envswitch send', the code is
+asked for when --code is not given. 'envswitch send --ssh' runs
+'envswitch receive --stdin' itself.
+
+Examples:
+  envswitch receive 192.168.1.20:7070 --code ABCD-EFGH-JKMN-PQRS
+  envswitch receive 192.168.1.20:7070 --force`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if receiveStdin {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.ExactArgs(1)(cmd, args)
+	},
+	RunE: runReceive,
+}
+
+func init() {
+	rootCmd.AddCommand(receiveCmd)
+	receiveCmd.Flags().StringVar(&receiveCode, "code", "", "Pairing code printed by 'envswitch send'")
+	receiveCmd.Flags().BoolVar(&receiveStdin, "stdin", false, "Read the environments from standard input")
+	receiveCmd.Flags().BoolVarP(&receiveForce, "force", "f", false, "Overwrite environments that already exist")
+	receiveCmd.MarkFlagsMutuallyExclusive("code", "stdin")
+}
+
+func runReceive(cmd *cobra.Command, args []string) error {
+	if receiveStdin {
+		return receiveFrom(os.Stdin)
+	}
+
+	code := receiveCode
+	if code == "" {
+		fmt.Print("Pairing code: ")
+		line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if code = strings.TrimSpace(line); code == "" {
+			return fmt.Errorf("missing pairing code (use --code)")
+		}
+	}
+
+	r, err := transfer.Dial(args[0], code)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return receiveFrom(r)
+}
+
+// receiveFrom imports the archives of a bundle sent by 'envswitch send'
+func receiveFrom(r io.Reader) error {
+	tmpDir, err := os.MkdirTemp("", "envswitch-receive-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	files, err := transfer.ReadBundle(r, tmpDir)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no environments received")
+	}
+
+	result, err := archive.ImportAll(tmpDir, archive.ImportOptions{Force: receiveForce, ConfirmReplace: confirmReplace})
+	if err != nil {
+		return fmt.Errorf("failed to import environments: %w", err)
+	}
+	fmt.Printf("✅ Received %d environment(s)\n", len(result.Imported))
+	if len(result.Failed) > 0 {
+		return fmt.Errorf("failed to import %d environment(s)", len(result.Failed))
+	}
+	return nil
+}
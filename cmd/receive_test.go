@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hugofrely/envswitch/internal/archive"
+	"github.com/hugofrely/envswitch/internal/transfer"
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+func TestSSHReceiveArgs(t *testing.T) {
+	sendSSH = "me@laptop"
+	defer func() {
+		sendSSH = ""
+		sendForce = false
+	}()
+
+	assert.Equal(t, []string{"--", "me@laptop", "envswitch", "receive", "--stdin"}, sshReceiveArgs())
+
+	sendForce = true
+	assert.Equal(t, []string{"--", "me@laptop", "envswitch", "receive", "--stdin", "--force"}, sshReceiveArgs())
+
+	// A destination starting with a dash is not an ssh option
+	sendForce = false
+	sendSSH = "-oProxyCommand=sh"
+	assert.Equal(t, []string{"--", "-oProxyCommand=sh", "envswitch", "receive", "--stdin"}, sshReceiveArgs())
+}
+
+func TestReceiveFrom(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+
+	envsDir := filepath.Join(tempHome, ".envswitch", "environments")
+	require.NoError(t, os.MkdirAll(envsDir, 0755))
+	createEnvWithVars(t, envsDir, "work", map[string]string{"AWS_PROFILE": "work"})
+
+	exportDir := t.TempDir()
+	require.NoError(t, archive.ExportEnvironments([]string{"work"}, exportDir))
+	files, err := filepath.Glob(filepath.Join(exportDir, "*.tar.gz"))
+	require.NoError(t, err)
+	var bundle bytes.Buffer
+	require.NoError(t, transfer.WriteBundle(&bundle, files))
+
+	// The other machine has no environments yet
+	require.NoError(t, os.RemoveAll(envsDir))
+
+	output := captureStdout(t, func() {
+		require.NoError(t, receiveFrom(&bundle))
+	})
+	assert.Contains(t, output, "Received 1 environment(s)")
+
+	env, err := environment.LoadEnvironment("work")
+	require.NoError(t, err)
+	assert.Equal(t, "work", env.Name)
+
+	assert.Error(t, receiveFrom(&bytes.Buffer{}))
+}
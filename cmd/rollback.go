@@ -0,0 +1,266 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/hugofrely/envswitch/internal/archive"
+	"github.com/hugofrely/envswitch/internal/config"
+	"github.com/hugofrely/envswitch/internal/history"
+	"github.com/hugofrely/envswitch/internal/logger"
+	"github.com/hugofrely/envswitch/pkg/environment"
+	"github.com/hugofrely/envswitch/pkg/spinner"
+)
+
+var (
+	rollbackTo  int
+	rollbackYes bool
+)
+
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback",
+	Short: "Undo a switch using its pre-switch backup",
+	Long: `Undo the last switch (or a specific one from history) by restoring the
+backup taken before it ran.
+
+The environment the switch started from is replaced by its backup, its tools
+are restored, and it becomes the active environment again. Hooks are not run.
+The rollback is recorded in history and can itself be rolled back.
+
+Examples:
+  # Undo the last switch
+  envswitch rollback
+
+  # Undo a specific switch (IDs are shown by 'envswitch history')
+  envswitch rollback --to 12
+
+  # Skip the confirmation prompt
+  envswitch rollback --yes`,
+	Args: cobra.NoArgs,
+	RunE: runRollback,
+}
+
+func init() {
+	rootCmd.AddCommand(rollbackCmd)
+	rollbackCmd.Flags().IntVar(&rollbackTo, "to", 0, "History ID of the switch to undo (default: last switch)")
+	rollbackCmd.Flags().BoolVarP(&rollbackYes, "yes", "y", false, "Skip confirmation")
+}
+
+func runRollback(cmd *cobra.Command, args []string) error {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		logger.Warn("Failed to load config, using defaults: %v", err)
+		cfg = config.DefaultConfig()
+	}
+
+	if logErr := logger.InitLogger(cfg); logErr != nil {
+		logger.Warn("Failed to initialize logger: %v", logErr)
+	}
+	defer logger.Close()
+	defer waitForMirrors()
+
+	hist, err := history.LoadHistory()
+	if err != nil {
+		return fmt.Errorf("failed to load history: %w", err)
+	}
+
+	target, err := selectRollbackEntry(hist, rollbackTo)
+	if err != nil {
+		return err
+	}
+
+	if previous, err := environment.LoadEnvironment(target.From); err == nil && previous.Protected && !rollbackYes {
+		if !confirmProtected(previous, "restore it and switch back to it") {
+			fmt.Println("Canceled.")
+			return nil
+		}
+	} else if !rollbackYes {
+		fmt.Printf("⚠️  Roll back switch #%d (%s → %s) and restore '%s' from %s? [y/N]: ",
+			target.ID, target.From, target.To, target.From, filepath.Base(target.BackupPath))
+		var response string
+		if _, err := fmt.Scanln(&response); err != nil {
+			fmt.Println("Canceled.")
+			return nil
+		}
+		if response != "y" && response != "Y" {
+			fmt.Println("Canceled.")
+			return nil
+		}
+	}
+
+	currentEnv, err := environment.GetCurrentEnvironment()
+	if err != nil {
+		return fmt.Errorf("failed to get current environment: %w", err)
+	}
+
+	return performRollback(currentEnv, target, cfg)
+}
+
+// selectRollbackEntry returns the switch to undo: the entry with the given ID,
+// or the last successful switch when id is 0
+func selectRollbackEntry(hist *history.History, id int) (*history.SwitchEntry, error) {
+	var entry *history.SwitchEntry
+	if id != 0 {
+		entry = hist.FindByID(id)
+		if entry == nil {
+			return nil, fmt.Errorf("no history entry with ID %d", id)
+		}
+		if !entry.Success {
+			return nil, fmt.Errorf("switch #%d did not complete, nothing to roll back", id)
+		}
+	} else {
+		entry = hist.GetLastSwitch()
+		if entry == nil {
+			return nil, fmt.Errorf("no switch to roll back")
+		}
+	}
+
+	if entry.From == "" || entry.From == getFromName(nil) {
+		return nil, fmt.Errorf("switch #%d did not start from an environment, nothing to roll back to", entry.ID)
+	}
+	if entry.BackupPath == "" {
+		return nil, fmt.Errorf("switch #%d has no backup (backups were disabled)", entry.ID)
+	}
+	if _, err := os.Stat(entry.BackupPath); err != nil {
+		return nil, fmt.Errorf("backup for switch #%d is no longer available: %s", entry.ID, entry.BackupPath)
+	}
+
+	return entry, nil
+}
+
+func performRollback(currentEnv *environment.Environment, target *history.SwitchEntry, cfg *config.Config) error {
+	startTime := time.Now()
+
+	endSwitch := beginSwitch()
+	defer endSwitch()
+
+	s := spinner.New(fmt.Sprintf("Rolling back switch #%d", target.ID))
+	s.Start()
+
+	historyEntry := history.SwitchEntry{
+		Timestamp: startTime,
+		From:      getFromName(currentEnv),
+		To:        target.From,
+		Rollback:  true,
+	}
+
+	// Back up and save the environment being left so the rollback can be undone too
+	s.Update("Creating backup...")
+	if _, err := createBackup(currentEnv, &historyEntry, cfg); err != nil {
+		s.Error(fmt.Sprintf("Failed to create backup: %v", err))
+		return err
+	}
+
+	if currentEnv != nil && currentEnv.Name != target.From {
+		s.Update("Saving current state...")
+		if err := saveCurrentState(context.Background(), currentEnv, liveToolFilter(currentEnv).apply(getToolRegistry()), spinnerProgress(s, "Saving")); err != nil {
+			s.Error(fmt.Sprintf("Failed to save current state: %v", err))
+			return err
+		}
+	}
+
+	s.Update("Restoring backup...")
+	env, err := restoreEnvironmentFromBackup(target.From, target.From, target.BackupPath)
+	if err != nil {
+		historyEntry.ErrorMsg = err.Error()
+		historyEntry.DurationMs = time.Since(startTime).Milliseconds()
+		recordHistory(&historyEntry)
+		s.Error(fmt.Sprintf("Failed to restore backup: %v", err))
+		return err
+	}
+
+	s.Update("Restoring environment...")
+	ctx := withCopyProgress(context.Background(), spinnerCopyProgress(s, "Restoring"))
+	toolCount, err := restoreEnvironment(ctx, env, getToolRegistry(), spinnerProgress(s, "Restoring"))
+	if err != nil {
+		historyEntry.ErrorMsg = err.Error()
+		historyEntry.DurationMs = time.Since(startTime).Milliseconds()
+		recordHistory(&historyEntry)
+		s.Error(fmt.Sprintf("Failed to restore environment: %v", err))
+		return err
+	}
+	historyEntry.ToolsCount = toolCount
+
+	if err := environment.SetCurrentEnvironment(env.Name); err != nil {
+		s.Error(fmt.Sprintf("Failed to update current environment: %v", err))
+		return fmt.Errorf("failed to update current environment: %w", err)
+	}
+
+	historyEntry.Success = true
+	historyEntry.DurationMs = time.Since(startTime).Milliseconds()
+	recordHistory(&historyEntry)
+
+	s.Success(fmt.Sprintf("Rolled back to '%s' (%.2fs)", env.Name, time.Since(startTime).Seconds()))
+	return nil
+}
+
+// restoreEnvironmentFromBackup replaces the environment directory of name with
+// the copy of archivedName stored in a backup archive
+func restoreEnvironmentFromBackup(archivedName, name, backupPath string) (*environment.Environment, error) {
+	envswitchDir, err := environment.GetEnvswitchDir()
+	if err != nil {
+		return nil, err
+	}
+	envsDir, err := environment.GetEnvironmentsDir()
+	if err != nil {
+		return nil, err
+	}
+
+	// Extract next to the environments so the final move is a rename
+	extractDir, err := os.MkdirTemp(envswitchDir, "rollback-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(extractDir) }()
+
+	if err := archive.RestoreArchive(backupPath, extractDir); err != nil {
+		return nil, fmt.Errorf("failed to extract backup: %w", err)
+	}
+
+	restoredPath := filepath.Join(extractDir, environment.FlatName(archivedName))
+	if _, err := os.Stat(filepath.Join(restoredPath, "metadata.yaml")); err != nil {
+		return nil, fmt.Errorf("backup does not contain environment '%s'", archivedName)
+	}
+
+	envPath := filepath.Join(envsDir, name)
+	if existing, loadErr := environment.LoadEnvironment(name); loadErr == nil {
+		envPath = existing.Path
+	}
+
+	// Keep the current directory aside until the backup is in place
+	previousPath := filepath.Join(extractDir, "previous")
+	hadPrevious := false
+	if _, err := os.Stat(envPath); err == nil {
+		if err := os.Rename(envPath, previousPath); err != nil {
+			return nil, fmt.Errorf("failed to move current environment aside: %w", err)
+		}
+		hadPrevious = true
+	}
+
+	if err := os.MkdirAll(filepath.Dir(envPath), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create environments directory: %w", err)
+	}
+	if err := os.Rename(restoredPath, envPath); err != nil {
+		if hadPrevious {
+			_ = os.Rename(previousPath, envPath)
+		}
+		return nil, fmt.Errorf("failed to restore environment: %w", err)
+	}
+
+	env, err := environment.LoadEnvironment(name)
+	if err != nil || archivedName == name {
+		return env, err
+	}
+
+	// Restored under another name, metadata.yaml still holds the archived one
+	env.Name = name
+	if err := env.Save(); err != nil {
+		return nil, fmt.Errorf("failed to rename restored environment: %w", err)
+	}
+	return env, nil
+}
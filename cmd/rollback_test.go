@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hugofrely/envswitch/internal/archive"
+	"github.com/hugofrely/envswitch/internal/history"
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+func TestRunRollback(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+
+	envsDir := filepath.Join(tempHome, ".envswitch", "environments")
+	require.NoError(t, os.MkdirAll(envsDir, 0755))
+
+	work := createEnvWithVars(t, envsDir, "work", nil)
+	createEnvWithVars(t, envsDir, "home", nil)
+
+	markerPath := filepath.Join(work.Path, "snapshots", "git", "marker")
+	require.NoError(t, os.MkdirAll(filepath.Dir(markerPath), 0755))
+	require.NoError(t, os.WriteFile(markerPath, []byte("before"), 0644))
+
+	// Simulate a switch work → home that backed up work first
+	backup, err := archive.ArchiveEnvironment(work)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(markerPath, []byte("after"), 0644))
+	require.NoError(t, environment.SetCurrentEnvironment("home"))
+
+	hist := &history.History{}
+	require.NoError(t, hist.AddEntry(&history.SwitchEntry{
+		Timestamp:  time.Now(),
+		From:       "work",
+		To:         "home",
+		Success:    true,
+		BackupPath: backup.Path,
+	}))
+
+	rollbackYes = true
+	defer func() { rollbackYes = false }()
+
+	require.NoError(t, runRollback(rollbackCmd, nil))
+
+	current, err := environment.GetCurrentEnvironment()
+	require.NoError(t, err)
+	require.NotNil(t, current)
+	assert.Equal(t, "work", current.Name)
+
+	data, err := os.ReadFile(markerPath)
+	require.NoError(t, err)
+	assert.Equal(t, "before", string(data))
+
+	loaded, err := history.LoadHistory()
+	require.NoError(t, err)
+	require.Len(t, loaded.Entries, 2)
+	latest := loaded.GetLatest()
+	assert.True(t, latest.Rollback)
+	assert.True(t, latest.Success)
+	assert.Equal(t, "home", latest.From)
+	assert.Equal(t, "work", latest.To)
+	assert.NotEmpty(t, latest.BackupPath)
+
+	// Rollbacks are skipped when picking the last switch
+	entry, err := selectRollbackEntry(loaded, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 1, entry.ID)
+}
+
+func TestSelectRollbackEntry(t *testing.T) {
+	backupPath := filepath.Join(t.TempDir(), "work.tar.gz")
+	require.NoError(t, os.WriteFile(backupPath, []byte("archive"), 0644))
+
+	hist := &history.History{
+		Entries: []history.SwitchEntry{
+			{ID: 1, From: "(none)", To: "work", Success: true},
+			{ID: 2, From: "work", To: "home", Success: true, BackupPath: backupPath},
+			{ID: 3, From: "home", To: "work", Success: true},
+			{ID: 4, From: "work", To: "prod", Success: false, BackupPath: backupPath},
+			{ID: 5, From: "work", To: "home", Success: true, BackupPath: filepath.Join(t.TempDir(), "missing.tar.gz")},
+		},
+	}
+
+	t.Run("selects entry by ID", func(t *testing.T) {
+		entry, err := selectRollbackEntry(hist, 2)
+		require.NoError(t, err)
+		assert.Equal(t, 2, entry.ID)
+	})
+
+	t.Run("defaults to the last switch", func(t *testing.T) {
+		_, err := selectRollbackEntry(hist, 0)
+		assert.ErrorContains(t, err, "switch #5")
+	})
+
+	t.Run("rejects unusable entries", func(t *testing.T) {
+		for id, message := range map[int]string{
+			1: "did not start from an environment",
+			3: "has no backup",
+			4: "did not complete",
+			5: "no longer available",
+			9: "no history entry",
+		} {
+			_, err := selectRollbackEntry(hist, id)
+			assert.ErrorContains(t, err, message, "entry %d", id)
+		}
+	})
+
+	t.Run("fails on empty history", func(t *testing.T) {
+		_, err := selectRollbackEntry(&history.History{}, 0)
+		assert.Error(t, err)
+	})
+}
@@ -1,22 +1,39 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
+	"github.com/hugofrely/envswitch/internal/config"
+	"github.com/hugofrely/envswitch/internal/logger"
+	"github.com/hugofrely/envswitch/internal/output"
+	"github.com/hugofrely/envswitch/internal/platform"
 	"github.com/hugofrely/envswitch/internal/updater"
 	"github.com/hugofrely/envswitch/internal/version"
+	"github.com/hugofrely/envswitch/pkg/spinner"
 )
 
 var (
-	cfgFile string
-	verbose bool
-	debug   bool
+	cfgFile    string
+	verbose    bool
+	debug      bool
+	sandboxDir string
+	outputFlag string
+	quiet      bool
+	noColor    bool
 )
 
+// restoreStdio undoes the filtering of stdout and stderr set up for
+// --no-color, nil when there is none
+var restoreStdio func()
+
 var rootCmd = &cobra.Command{
 	Use:   "envswitch",
 	Short: "EnvSwitch - Manage your development environments",
@@ -27,12 +44,63 @@ Think of it as snapshots for your CLI tools: when you switch from one
 environment to another, EnvSwitch automatically saves the current state
 (authentications, configurations, contexts) and restores the exact state
 of the target environment.`,
-	PersistentPreRun: checkForUpdates,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		// Checked first, --no-color replaces stdout with a pipe
+		checkForUpdates(cmd, args)
+		applyOutputModes()
+	},
+}
+
+// exitError makes the process exit with code without printing an error, for
+// commands whose exit status is a result
+type exitError struct {
+	code int
+}
+
+func (e *exitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
+// ExitCode returns the exit status for the error returned by Execute
+func ExitCode(err error) (int, bool) {
+	var exitErr *exitError
+	if errors.As(err, &exitErr) {
+		return exitErr.code, true
+	}
+	return 1, false
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 func Execute() error {
-	return rootCmd.Execute()
+	args, err := prepareArgs(os.Args[1:])
+	if err != nil {
+		return err
+	}
+	rootCmd.SetArgs(args)
+
+	err = rootCmd.Execute()
+	waitForUpdateCheck(updateCheckWait)
+	if restoreStdio != nil {
+		restoreStdio()
+	}
+	return err
+}
+
+// prepareArgs expands the user-defined aliases of config.yaml before cobra
+// parses args. The sandbox is entered first, its config.yaml holds the
+// aliases in effect.
+func prepareArgs(args []string) ([]string, error) {
+	if dir := sandboxArg(args); dir != "" {
+		if err := enterSandbox(dir); err != nil {
+			return nil, err
+		}
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil || len(cfg.Aliases) == 0 {
+		return args, nil
+	}
+	return expandAlias(rootCmd, args, cfg.Aliases)
 }
 
 func init() {
@@ -44,19 +112,33 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.envswitch/config.yaml)")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "verbose output")
 	rootCmd.PersistentFlags().BoolVar(&debug, "debug", false, "debug mode")
+	rootCmd.PersistentFlags().StringVarP(&outputFlag, "output", "o", output.FormatText, "output format of list, history, status, current, switch, doctor, config list and plugin list: text, json or yaml")
+	_ = rootCmd.RegisterFlagCompletionFunc("output", cobra.FixedCompletions(output.Formats, cobra.ShellCompDirectiveNoFileComp))
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "only print results, warnings and errors, without progress")
+	rootCmd.PersistentFlags().BoolVar(&noColor, "no-color", false, "plain output without colors, emoji or animation (also set by NO_COLOR)")
+	rootCmd.PersistentFlags().StringVar(&sandboxDir, "sandbox", "", "use a sandbox directory as home instead of your real tool configs")
 }
 
 func initConfig() {
+	// Enter the sandbox before anything resolves the home directory
+	if sandboxDir != "" {
+		if err := enterSandbox(sandboxDir); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "🧪 Sandbox mode: using %s as home\n", os.Getenv(sandboxEnvVar))
+	}
+
 	if cfgFile != "" {
 		viper.SetConfigFile(cfgFile)
 	} else {
-		home, err := os.UserHomeDir()
+		home, err := platform.HomeDir()
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
 
-		viper.AddConfigPath(home + "/.envswitch")
+		viper.AddConfigPath(filepath.Join(home, ".envswitch"))
 		viper.SetConfigType("yaml")
 		viper.SetConfigName("config")
 	}
@@ -70,38 +152,132 @@ func initConfig() {
 	}
 }
 
+// resultWriter returns the writer of command results in the format picked
+// with --output, to stdout
+func resultWriter() (*output.Writer, error) {
+	format, err := output.ParseFormat(outputFlag)
+	if err != nil {
+		return nil, err
+	}
+	return output.NewWriter(format, os.Stdout), nil
+}
+
+// applyOutputModes sets up --quiet and --no-color. Setting the NO_COLOR
+// variable to any value also disables colors (https://no-color.org).
+func applyOutputModes() {
+	if os.Getenv("NO_COLOR") != "" {
+		noColor = true
+	}
+
+	logger.SetConsoleMode(quiet, noColor)
+	spinner.SetMode(quiet, noColor)
+	if noColor && restoreStdio == nil {
+		restoreStdio = plainStdio()
+	}
+}
+
+// plainStdio routes stdout and stderr through writers removing emoji, and
+// returns the function flushing them and restoring the originals
+func plainStdio() func() {
+	stdout, stderr := os.Stdout, os.Stderr
+	stdoutDone, outErr := pipeThrough(&os.Stdout)
+	stderrDone, errErr := pipeThrough(&os.Stderr)
+	if outErr != nil || errErr != nil {
+		// Emoji are left in rather than losing output
+		os.Stdout, os.Stderr = stdout, stderr
+		return nil
+	}
+
+	return func() {
+		stdoutDone()
+		stderrDone()
+		os.Stdout, os.Stderr = stdout, stderr
+	}
+}
+
+// pipeThrough replaces *file with a pipe copied to it through a
+// PlainWriter, and returns the function closing the pipe once copied
+func pipeThrough(file **os.File) (func(), error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+
+	original := *file
+	*file = w
+	done := make(chan struct{})
+	go func() {
+		_, _ = io.Copy(output.NewPlainWriter(original), r)
+		close(done)
+	}()
+
+	return func() {
+		_ = w.Close()
+		<-done
+	}, nil
+}
+
+// updateCheckWait bounds how long a command waits on exit for the update
+// check it started in the background
+const updateCheckWait = 2 * time.Second
+
+// updateCheckDone is closed once the background update check is over, nil
+// when none was started
+var updateCheckDone chan struct{}
+
+// waitForUpdateCheck waits up to timeout for the background update check,
+// so that it gets to write its cache instead of being killed on exit
+func waitForUpdateCheck(timeout time.Duration) {
+	if updateCheckDone == nil {
+		return
+	}
+	select {
+	case <-updateCheckDone:
+	case <-time.After(timeout):
+	}
+}
+
 // checkForUpdates is called before any command runs to check for new versions
 func checkForUpdates(cmd *cobra.Command, args []string) {
 	// Skip update check for certain commands
-	if cmd.Name() == "update" || cmd.Name() == "version" || cmd.Name() == "completion" || cmd.Name() == "help" {
+	if cmd.Name() == "update" || cmd.Name() == "version" || cmd.Name() == "completion" || cmd.Name() == "help" || cmd.Name() == "prompt" {
 		return
 	}
 
-	// Skip if not in a terminal (e.g., piped output)
-	if !isTerminal() {
+	// Skip in quiet mode and if not in a terminal (e.g., piped output)
+	if quiet || !isTerminal() {
 		return
 	}
 
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return // Silently skip if we can't get home dir
+	if version.Version == version.DevVersion {
+		return
 	}
-
-	configDir := home + "/.envswitch"
-	if !updater.ShouldCheckForUpdate(configDir) {
+	if cfg, err := config.LoadConfig(); err == nil && !cfg.UpdateCheck {
 		return
 	}
 
-	info, err := updater.CheckForUpdate()
+	home, err := platform.HomeDir()
 	if err != nil {
-		// Silently ignore update check failures
-		if debug {
-			fmt.Fprintf(os.Stderr, "Update check failed: %v\n", err)
-		}
-		return
+		return // Silently skip if we can't get home dir
+	}
+	configDir := filepath.Join(home, ".envswitch")
+
+	// The check runs in the background while the command runs, and is waited
+	// for a little on exit; its result is shown from the next command on
+	if updater.ShouldCheckForUpdate(configDir) {
+		stderr := os.Stderr
+		done := make(chan struct{})
+		updateCheckDone = done
+		go func() {
+			defer close(done)
+			// Silently ignore update check failures
+			if err := updater.RefreshCache(configDir); err != nil && debug {
+				fmt.Fprintf(stderr, "Update check failed: %v\n", err)
+			}
+		}()
 	}
 
-	if info.Available {
+	if info := updater.CachedUpdate(configDir); info != nil {
 		fmt.Fprintf(os.Stderr, "\n💡 New version available: %s → %s\n", info.CurrentVersion, info.LatestVersion)
 		fmt.Fprintf(os.Stderr, "   Run 'envswitch update' for update instructions\n\n")
 	}
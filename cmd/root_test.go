@@ -1,11 +1,17 @@
 package cmd
 
 import (
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	"github.com/hugofrely/envswitch/internal/logger"
+	"github.com/hugofrely/envswitch/internal/output"
 	"github.com/hugofrely/envswitch/internal/version"
+	"github.com/hugofrely/envswitch/pkg/spinner"
 )
 
 func TestRootCommand(t *testing.T) {
@@ -138,3 +144,63 @@ func TestInitConfig(t *testing.T) {
 		})
 	})
 }
+
+// setOutputFormat sets --output for the duration of the test
+func setOutputFormat(t *testing.T, format string) {
+	t.Helper()
+	outputFlag = format
+	t.Cleanup(func() { outputFlag = output.FormatText })
+}
+
+func TestResultWriter(t *testing.T) {
+	setOutputFormat(t, "json")
+	out, err := resultWriter()
+	require.NoError(t, err)
+	assert.True(t, out.Structured())
+
+	setOutputFormat(t, "csv")
+	_, err = resultWriter()
+	assert.ErrorContains(t, err, "invalid output format 'csv'")
+}
+
+func TestApplyOutputModes(t *testing.T) {
+	defer func() {
+		quiet, noColor, restoreStdio = false, false, nil
+		logger.SetConsoleMode(false, false)
+		spinner.SetMode(false, false)
+	}()
+
+	t.Setenv("NO_COLOR", "1")
+	out := captureStdout(t, func() {
+		applyOutputModes()
+		require.NotNil(t, restoreStdio)
+		fmt.Println("✅ Environment 'work' created successfully")
+		fmt.Println("⚠️  Warning: backup failed")
+		restoreStdio()
+	})
+
+	assert.True(t, noColor, "NO_COLOR disables colors")
+	assert.False(t, logger.GetLogger().ShouldShowColors())
+	assert.Equal(t, "[ok] Environment 'work' created successfully\n[!] Warning: backup failed\n", out)
+}
+
+func TestWaitForUpdateCheck(t *testing.T) {
+	defer func() { updateCheckDone = nil }()
+
+	// Nothing to wait for
+	updateCheckDone = nil
+	waitForUpdateCheck(time.Hour)
+
+	// A finished check is not waited for
+	updateCheckDone = make(chan struct{})
+	close(updateCheckDone)
+	start := time.Now()
+	waitForUpdateCheck(time.Hour)
+	assert.Less(t, time.Since(start), time.Second)
+
+	// A check still running is waited for up to the timeout
+	updateCheckDone = make(chan struct{})
+	start = time.Now()
+	waitForUpdateCheck(50 * time.Millisecond)
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sandboxEnvVar is exported to hooks and plugins when running in sandbox mode
+const sandboxEnvVar = "ENVSWITCH_SANDBOX"
+
+// sandboxClearedEnvVars point tools at configuration outside the home
+// directory. They are cleared in sandbox mode so nothing escapes the sandbox.
+var sandboxClearedEnvVars = []string{
+	"KUBECONFIG",
+	"AWS_CONFIG_FILE",
+	"AWS_SHARED_CREDENTIALS_FILE",
+	"AWS_PROFILE",
+	"CLOUDSDK_CONFIG",
+	"DOCKER_CONFIG",
+	"GIT_CONFIG_GLOBAL",
+	"TF_CLI_CONFIG_FILE",
+	"TF_WORKSPACE",
+	"NPM_CONFIG_USERCONFIG",
+	"YARN_RC_FILENAME",
+}
+
+// sandboxArg returns the directory given with --sandbox in args, which are
+// not parsed yet, or an empty string. The last one wins, as with cobra.
+func sandboxArg(args []string) string {
+	dir := ""
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "--":
+			return dir
+		case arg == "--sandbox" && i+1 < len(args):
+			dir = args[i+1]
+			i++
+		case strings.HasPrefix(arg, "--sandbox="):
+			dir = strings.TrimPrefix(arg, "--sandbox=")
+		}
+	}
+	return dir
+}
+
+// enterSandbox makes dir the home directory for the rest of the process, so
+// every tool config path and envswitch's own data (~/.envswitch) resolve
+// inside it. Tool CLIs started by envswitch inherit the same environment.
+func enterSandbox(dir string) error {
+	sandboxHome, err := filepath.Abs(dir)
+	if err != nil {
+		return fmt.Errorf("invalid sandbox directory: %w", err)
+	}
+
+	if err := os.MkdirAll(sandboxHome, 0700); err != nil {
+		return fmt.Errorf("failed to create sandbox directory: %w", err)
+	}
+
+	for _, name := range sandboxClearedEnvVars {
+		if err := os.Unsetenv(name); err != nil {
+			return fmt.Errorf("failed to clear %s: %w", name, err)
+		}
+	}
+
+	vars := map[string]string{
+		"HOME":            sandboxHome,
+		"USERPROFILE":     sandboxHome, // home directory on Windows
+		"XDG_CONFIG_HOME": filepath.Join(sandboxHome, ".config"),
+		sandboxEnvVar:     sandboxHome,
+	}
+	for name, value := range vars {
+		if err := os.Setenv(name, value); err != nil {
+			return fmt.Errorf("failed to set %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hugofrely/envswitch/pkg/environment"
+	"github.com/hugofrely/envswitch/pkg/tools"
+)
+
+func TestEnterSandbox(t *testing.T) {
+	// Register the variables so they are restored after the test
+	for _, name := range append([]string{"HOME", "USERPROFILE", "XDG_CONFIG_HOME", sandboxEnvVar}, sandboxClearedEnvVars...) {
+		t.Setenv(name, "/real/"+strings.ToLower(name))
+	}
+
+	sandbox := filepath.Join(t.TempDir(), "demo")
+	require.NoError(t, enterSandbox(sandbox))
+
+	assert.DirExists(t, sandbox)
+	assert.Equal(t, sandbox, os.Getenv("HOME"))
+	assert.Equal(t, sandbox, os.Getenv(sandboxEnvVar))
+	for _, name := range sandboxClearedEnvVars {
+		_, set := os.LookupEnv(name)
+		assert.False(t, set, "%s should be cleared", name)
+	}
+
+	t.Run("tool configs resolve inside the sandbox", func(t *testing.T) {
+		assert.True(t, strings.HasPrefix(tools.NewAWSTool().AWSConfigDir, sandbox))
+		assert.True(t, strings.HasPrefix(tools.NewKubectlTool().KubeConfigDir, sandbox))
+		assert.True(t, strings.HasPrefix(tools.NewGCloudTool().ConfigPath, sandbox))
+	})
+
+	t.Run("envswitch data resolves inside the sandbox", func(t *testing.T) {
+		dir, err := environment.GetEnvswitchDir()
+		require.NoError(t, err)
+		assert.Equal(t, filepath.Join(sandbox, ".envswitch"), dir)
+	})
+}
+
+func TestSandboxArg(t *testing.T) {
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"list"}, ""},
+		{[]string{"--sandbox", "/tmp/demo", "list"}, "/tmp/demo"},
+		{[]string{"sw", "--sandbox=/tmp/demo"}, "/tmp/demo"},
+		{[]string{"--sandbox", "/tmp/a", "--sandbox", "/tmp/b", "list"}, "/tmp/b"},
+		{[]string{"hooks", "add", "--", "--sandbox", "/tmp/demo"}, ""},
+		{[]string{"list", "--sandbox"}, ""},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, sandboxArg(tt.args), "args %v", tt.args)
+	}
+}
+
+func TestPrepareArgsEntersSandboxFirst(t *testing.T) {
+	for _, name := range append([]string{"HOME", "USERPROFILE", "XDG_CONFIG_HOME", sandboxEnvVar}, sandboxClearedEnvVars...) {
+		t.Setenv(name, "")
+	}
+	writeAliases := func(home, expansion string) {
+		require.NoError(t, os.MkdirAll(filepath.Join(home, ".envswitch"), 0700))
+		config := "aliases:\n  here: " + expansion + "\n"
+		require.NoError(t, os.WriteFile(filepath.Join(home, ".envswitch", "config.yaml"), []byte(config), 0600))
+	}
+
+	realHome := t.TempDir()
+	t.Setenv("HOME", realHome)
+	writeAliases(realHome, "switch prod")
+	sandbox := t.TempDir()
+	writeAliases(sandbox, "list")
+
+	args, err := prepareArgs([]string{"--sandbox", sandbox, "here"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"--sandbox", sandbox, "list"}, args, "the aliases of the sandbox apply")
+	assert.Equal(t, sandbox, os.Getenv("HOME"))
+}
@@ -5,9 +5,13 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"github.com/hugofrely/envswitch/internal/config"
+	"github.com/hugofrely/envswitch/internal/logger"
 	"github.com/hugofrely/envswitch/pkg/environment"
 )
 
+var saveMessage string
+
 var saveCmd = &cobra.Command{
 	Use:   "save",
 	Short: "Save the current system state to the active environment",
@@ -19,11 +23,18 @@ The save command works on the currently active environment. It will:
   - Capture current state of all enabled tools (gcloud, kubectl, aws, etc.)
   - Update snapshots in the active environment
   - Preserve tool configurations
+  - Keep the saved state in the snapshot history of the environment
+
+The last snapshot_history_limit saved states are kept (10 by default), see
+'envswitch snapshots list' to find one and 'snapshots restore' to go back to it.
 
 Examples:
   # Save current state to active environment
   envswitch save
 
+  # Describe the state in the snapshot history
+  envswitch save --message "before upgrading the cluster"
+
 Note: You must have an active environment to use this command.
 Use 'envswitch list' to see all environments and which one is active.`,
 	Args: cobra.NoArgs,
@@ -32,6 +43,7 @@ Use 'envswitch list' to see all environments and which one is active.`,
 
 func init() {
 	rootCmd.AddCommand(saveCmd)
+	saveCmd.Flags().StringVarP(&saveMessage, "message", "m", "", "Describe the saved state in the snapshot history")
 }
 
 func runSave(cmd *cobra.Command, args []string) error {
@@ -45,15 +57,45 @@ func runSave(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("no active environment. Use 'envswitch create' to create one first")
 	}
 
+	// Wait for 'envswitch daemon' if it is saving the environment too
+	currentEnv, unlock, err := environment.LockEnvironment(currentEnv.Name)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// Tools left out of the switch that made the environment active hold
+	// another environment's config and are not saved
+	filter := liveToolFilter(currentEnv)
+	if !filter.isEmpty() {
+		fmt.Printf("Saving %s, as set when switching to '%s'\n", filter, currentEnv.Name)
+	}
+
 	// Capture current state using the same function from create.go (which has a spinner)
-	if err := captureCurrentState(currentEnv.Path, currentEnv); err != nil {
+	if err := captureCurrentState(currentEnv.Path, currentEnv, filter); err != nil {
 		return fmt.Errorf("failed to save current state: %w", err)
 	}
+	captureKeychain(currentEnv)
 
 	// Save environment metadata
 	if err := currentEnv.Save(); err != nil {
 		return fmt.Errorf("failed to save environment metadata: %w", err)
 	}
+	recordPromptStatus(currentEnv.Name, false)
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		logger.Warn("Failed to load config, using defaults: %v", err)
+		cfg = config.DefaultConfig()
+	}
+	record, err := currentEnv.RecordSnapshot(saveMessage, cfg.SnapshotHistoryLimit)
+	if err != nil {
+		// The environment is saved, only its history is incomplete
+		snapshotLog.Warn("Failed to record the snapshot history of '%s': %v", currentEnv.Name, err)
+	} else if record != nil {
+		fmt.Printf("Recorded snapshot #%d of '%s'\n", record.ID, currentEnv.Name)
+	}
 
+	autoSync(cfg)
 	return nil
 }
@@ -61,6 +61,8 @@ func TestRunSave(t *testing.T) {
 		require.NoError(t, err)
 
 		// Run save
+		saveMessage = "first save"
+		defer func() { saveMessage = "" }()
 		err = runSave(saveCmd, []string{})
 		assert.NoError(t, err)
 
@@ -69,6 +71,12 @@ func TestRunSave(t *testing.T) {
 		require.NoError(t, err)
 		assert.Equal(t, "test", savedEnv.Name)
 		assert.NotNil(t, savedEnv.Tools)
+
+		// The saved state is in the snapshot history
+		records, err := savedEnv.ListSnapshotHistory()
+		require.NoError(t, err)
+		require.Len(t, records, 1)
+		assert.Equal(t, "first save", records[0].Message)
 	})
 
 	t.Run("updates existing snapshot", func(t *testing.T) {
@@ -128,7 +136,7 @@ func TestRunSave(t *testing.T) {
 		}
 
 		// Initialize multiple tools
-		toolNames := []string{"gcloud", "kubectl", "aws", "docker", "git"}
+		toolNames := []string{"gcloud", "kubectl", "aws", "docker", "git", "terraform", "ssh", "npm"}
 		for _, tool := range toolNames {
 			env.Tools[tool] = environment.ToolConfig{
 				Enabled:      false,
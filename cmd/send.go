@@ -0,0 +1,206 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/hugofrely/envswitch/internal/archive"
+	"github.com/hugofrely/envswitch/internal/transfer"
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+// sendAttempts is how many wrong pairing codes the sender turns away before
+// closing the channel
+const sendAttempts = 3
+
+var (
+	sendAll       bool
+	sendSSH       string
+	sendRemoteBin string
+	sendPort      int
+	sendTimeout   time.Duration
+	sendForce     bool
+)
+
+var sendCmd = &cobra.Command{
+	Use:   "send [environment-name...]",
+	Short: "Send environments to another machine",
+	Long: `Export environments and stream them to another machine, which imports them.
+
+With --ssh, the environments go through SSH to 'envswitch receive --stdin' on
+the remote machine. Otherwise envswitch waits for the other machine on a
+one-time TCP channel, printing the 'envswitch receive' command to run there:
+the transfer is encrypted with its pairing code.
+
+Secret variables encrypted with the keyring key cannot be read on the other
+machine, set ENVSWITCH_PASSPHRASE on both to carry them.
+
+Examples:
+  # Through SSH
+  envswitch send work personal --ssh me@new-laptop
+
+  # On the local network, with a pairing code
+  envswitch send --all
+  envswitch send 'client-a/*' --port 7070`,
+	ValidArgsFunction: completeEnvironmentNameArgs,
+	RunE:              runSend,
+}
+
+func init() {
+	rootCmd.AddCommand(sendCmd)
+	sendCmd.Flags().BoolVar(&sendAll, "all", false, "Send all environments")
+	sendCmd.Flags().StringVar(&sendSSH, "ssh", "", "Send through SSH to this destination, e.g. me@laptop")
+	sendCmd.Flags().StringVar(&sendRemoteBin, "remote-envswitch", "envswitch", "envswitch command on the SSH destination")
+	sendCmd.Flags().IntVar(&sendPort, "port", 0, "TCP port to wait on (default: any free port)")
+	sendCmd.Flags().DurationVar(&sendTimeout, "timeout", 10*time.Minute, "How long to wait for the other machine")
+	sendCmd.Flags().BoolVarP(&sendForce, "force", "f", false, "Overwrite environments that already exist on the other machine (with --ssh)")
+}
+
+func runSend(cmd *cobra.Command, args []string) error {
+	names, err := sendEnvironmentNames(args)
+	if err != nil {
+		return err
+	}
+
+	tmpDir, err := os.MkdirTemp("", "envswitch-send-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	if err := archive.ExportEnvironments(names, tmpDir); err != nil {
+		return fmt.Errorf("failed to export environments: %w", err)
+	}
+	files, err := filepath.Glob(filepath.Join(tmpDir, "*.tar.gz"))
+	if err != nil {
+		return err
+	}
+
+	if sendSSH != "" {
+		return sendOverSSH(files)
+	}
+	return sendOverTCP(files)
+}
+
+// sendEnvironmentNames returns the environments given as arguments or
+// patterns, or all of them with --all
+func sendEnvironmentNames(args []string) ([]string, error) {
+	if sendAll {
+		if len(args) > 0 {
+			return nil, fmt.Errorf("cannot specify environment names with --all flag")
+		}
+		envs, err := environment.ListEnvironments()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list environments: %w", err)
+		}
+		if len(envs) == 0 {
+			return nil, fmt.Errorf("no environments to send")
+		}
+		names := make([]string, 0, len(envs))
+		for _, env := range envs {
+			names = append(names, env.Name)
+		}
+		return names, nil
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("must specify at least one environment name or use --all flag")
+	}
+	return environment.ExpandNames(args)
+}
+
+// sshReceiveArgs returns the arguments of ssh running 'envswitch receive' on
+// the destination. "--" keeps a destination starting with a dash from being
+// read as an ssh option.
+func sshReceiveArgs() []string {
+	args := []string{"--", sendSSH, sendRemoteBin, "receive", "--stdin"}
+	if sendForce {
+		args = append(args, "--force")
+	}
+	return args
+}
+
+// sendOverSSH streams the archives to 'envswitch receive --stdin' on the SSH
+// destination, which prints the imported environments
+func sendOverSSH(files []string) error {
+	ssh := exec.Command("ssh", sshReceiveArgs()...)
+	ssh.Stdout = os.Stdout
+	ssh.Stderr = os.Stderr
+	stdin, err := ssh.StdinPipe()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("📡 Sending %d environment(s) to %s\n", len(files), sendSSH)
+	if err := ssh.Start(); err != nil {
+		return fmt.Errorf("failed to run ssh: %w", err)
+	}
+	writeErr := transfer.WriteBundle(stdin, files)
+	if closeErr := stdin.Close(); writeErr == nil {
+		writeErr = closeErr
+	}
+	if err := ssh.Wait(); err != nil {
+		return fmt.Errorf("receiving on %s failed: %w", sendSSH, err)
+	}
+	if writeErr != nil {
+		return fmt.Errorf("failed to send environments: %w", writeErr)
+	}
+	fmt.Printf("✅ Sent %d environment(s) to %s\n", len(files), sendSSH)
+	return nil
+}
+
+// sendOverTCP waits for 'envswitch receive' with the pairing code, and
+// streams the archives to it
+func sendOverTCP(files []string) error {
+	code, err := transfer.NewPairingCode()
+	if err != nil {
+		return err
+	}
+	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", sendPort))
+	if err != nil {
+		return fmt.Errorf("failed to open the transfer channel: %w", err)
+	}
+	defer ln.Close()
+	if tcp, ok := ln.(*net.TCPListener); ok {
+		_ = tcp.SetDeadline(time.Now().Add(sendTimeout))
+	}
+
+	port := ln.Addr().(*net.TCPAddr).Port
+	addresses := transfer.LocalAddresses()
+	if len(addresses) == 0 {
+		addresses = []string{"<this-machine>"}
+	}
+	fmt.Printf("📡 Ready to send %d environment(s). On the other machine, run:\n\n", len(files))
+	for _, address := range addresses {
+		fmt.Printf("   envswitch receive %s --code %s\n", net.JoinHostPort(address, fmt.Sprint(port)), code)
+	}
+	fmt.Printf("\nWaiting for it (%s)...\n", sendTimeout)
+
+	w, err := transfer.Serve(ln, code, sendAttempts)
+	if err != nil {
+		return err
+	}
+	if err := writeBundleTo(w, files); err != nil {
+		return err
+	}
+	fmt.Printf("✅ Sent %d environment(s)\n", len(files))
+	return nil
+}
+
+// writeBundleTo writes the archives to w and closes it
+func writeBundleTo(w io.WriteCloser, files []string) error {
+	err := transfer.WriteBundle(w, files)
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to send environments: %w", err)
+	}
+	return nil
+}
@@ -7,16 +7,17 @@ import (
 
 	"github.com/hugofrely/envswitch/internal/config"
 	"github.com/hugofrely/envswitch/internal/shell"
+	"github.com/hugofrely/envswitch/pkg/environment"
 )
 
 var shellCmd = &cobra.Command{
 	Use:   "shell",
 	Short: "Shell integration commands",
-	Long:  `Commands for integrating envswitch with your shell (bash, zsh, fish).`,
+	Long:  `Commands for integrating envswitch with your shell (bash, zsh, fish, PowerShell).`,
 }
 
 var shellInitCmd = &cobra.Command{
-	Use:   "init [bash|zsh|fish]",
+	Use:   "init [bash|zsh|fish|powershell]",
 	Short: "Generate shell initialization script",
 	Long: `Generate shell initialization script to enable prompt integration.
 
@@ -24,17 +25,21 @@ Add the output to your shell's configuration file:
   bash: ~/.bashrc or ~/.bash_profile
   zsh:  ~/.zshrc
   fish: ~/.config/fish/config.fish
+  powershell: $PROFILE
 
-Example:
-  envswitch shell init bash >> ~/.bashrc`,
+Examples:
+  envswitch shell init bash >> ~/.bashrc
+
+  # PowerShell
+  Add-Content $PROFILE 'envswitch shell init powershell | Out-String | Invoke-Expression'`,
 	Args:              cobra.ExactArgs(1),
-	ValidArgs:         []string{"bash", "zsh", "fish"},
+	ValidArgs:         []string{"bash", "zsh", "fish", "powershell"},
 	RunE:              runShellInit,
 	DisableAutoGenTag: true,
 }
 
 var shellInstallCmd = &cobra.Command{
-	Use:   "install [bash|zsh|fish]",
+	Use:   "install [bash|zsh|fish|powershell]",
 	Short: "Install shell integration automatically",
 	Long: `Automatically install shell integration by appending the initialization
 script to your shell's configuration file.
@@ -44,15 +49,106 @@ This command will:
   2. Append it to your shell's config file
   3. Display instructions to reload your shell`,
 	Args:              cobra.ExactArgs(1),
-	ValidArgs:         []string{"bash", "zsh", "fish"},
+	ValidArgs:         []string{"bash", "zsh", "fish", "powershell"},
 	RunE:              runShellInstall,
 	DisableAutoGenTag: true,
 }
 
+var shellStatusCmd = &cobra.Command{
+	Use:   "status [bash|zsh|fish|powershell]",
+	Short: "Check the shell integration installed in your shell's configuration file",
+	Long: `Check the shell integration block written by 'envswitch shell install', for the
+shell given or the one envswitch runs from.
+
+Other tools rewriting the configuration file can break the block: its marker
+comment can lose the line loading the integration, or the block can be
+duplicated. The status shows the active shell, the configuration file, where
+the block is and what is wrong with it.
+
+Examples:
+  envswitch shell status
+  envswitch shell status zsh`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgs:         []string{"bash", "zsh", "fish", "powershell"},
+	RunE:              runShellStatus,
+	DisableAutoGenTag: true,
+}
+
+var shellRepairCmd = &cobra.Command{
+	Use:   "repair [bash|zsh|fish|powershell]",
+	Short: "Rewrite a broken or duplicated shell integration block",
+	Long: `Replace the shell integration blocks of your shell's configuration file by a
+single intact one at its end. Only the lines after the envswitch marker are
+edited, the previous content is kept in <file>.envswitch.bak.
+
+Examples:
+  envswitch shell repair
+  envswitch shell repair bash`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgs:         []string{"bash", "zsh", "fish", "powershell"},
+	RunE:              runShellRepair,
+	DisableAutoGenTag: true,
+}
+
+var shellUninstallCmd = &cobra.Command{
+	Use:   "uninstall [bash|zsh|fish|powershell]",
+	Short: "Remove the shell integration from your shell's configuration file",
+	Long: `Remove the shell integration blocks written by 'envswitch shell install' from
+your shell's configuration file. Only the lines after the envswitch marker are
+removed, the previous content is kept in <file>.envswitch.bak.
+
+Examples:
+  envswitch shell uninstall
+  envswitch shell uninstall fish`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgs:         []string{"bash", "zsh", "fish", "powershell"},
+	RunE:              runShellUninstall,
+	DisableAutoGenTag: true,
+}
+
+var shellHistoryCmd = &cobra.Command{
+	Use:   "history [isolated|shared|default]",
+	Short: "Configure shell history isolation for the current environment",
+	Long: `Configure whether the current environment keeps its own shell history.
+
+With isolation, the shell integration points HISTFILE (fish_history for fish)
+to a file inside the environment when you switch to it, and back to your
+default history when you switch away. The history file is only readable by
+you.
+
+  isolated  Keep a separate history for this environment
+  shared    Use the default shell history
+  default   Follow the isolate_shell_history config setting
+
+Without an argument, shows the current setting.
+
+Examples:
+  envswitch shell history isolated
+  envswitch config set isolate_shell_history true`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgs:         []string{environment.ShellHistoryIsolated, environment.ShellHistoryShared, "default"},
+	RunE:              runShellHistory,
+	DisableAutoGenTag: true,
+}
+
+var shellHistoryFileCmd = &cobra.Command{
+	Use:    "history-file [bash|zsh|fish]",
+	Short:  "Print the history file of the current environment",
+	Long:   `Print the history file the shell integration should use, or nothing when the current environment shares the default history.`,
+	Args:   cobra.ExactArgs(1),
+	Hidden: true,
+	RunE:   runShellHistoryFile,
+}
+
 func init() {
 	rootCmd.AddCommand(shellCmd)
 	shellCmd.AddCommand(shellInitCmd)
 	shellCmd.AddCommand(shellInstallCmd)
+	shellCmd.AddCommand(shellStatusCmd)
+	shellCmd.AddCommand(shellRepairCmd)
+	shellCmd.AddCommand(shellUninstallCmd)
+	shellCmd.AddCommand(shellHistoryCmd)
+	shellCmd.AddCommand(shellHistoryFileCmd)
 }
 
 func runShellInit(cmd *cobra.Command, args []string) error {
@@ -98,9 +194,169 @@ func runShellInstall(cmd *cobra.Command, args []string) error {
 		fmt.Printf("  source %s\n", configFile)
 	case "fish":
 		fmt.Printf("  source %s\n", configFile)
+	case "powershell":
+		fmt.Printf("  . $PROFILE\n")
 	}
 
 	fmt.Println("\nOr simply restart your shell.")
 
 	return nil
 }
+
+// shellTypeArg returns the shell named in args, or the one envswitch runs
+// from
+func shellTypeArg(args []string) string {
+	if len(args) > 0 {
+		return args[0]
+	}
+	return shell.DetectShell()
+}
+
+func runShellStatus(cmd *cobra.Command, args []string) error {
+	shellType := shellTypeArg(args)
+	status, err := shell.CheckIntegration(shellType)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Active shell:       %s\n", shell.DetectShell())
+	fmt.Printf("Configuration file: %s\n", status.ConfigFile)
+
+	switch {
+	case !status.Exists:
+		fmt.Printf("Integration:        not installed (the file does not exist)\n")
+	case !status.Installed:
+		fmt.Printf("Integration:        not installed\n")
+	case status.Intact:
+		fmt.Printf("Integration:        ✅ installed, line %d\n", status.Lines[0])
+	default:
+		fmt.Printf("Integration:        ⚠️  broken\n")
+	}
+
+	if len(status.Problems) > 0 {
+		fmt.Println()
+		for _, problem := range status.Problems {
+			fmt.Printf("  ✗ %s\n", problem)
+		}
+	}
+
+	fmt.Println()
+	switch {
+	case !status.Installed:
+		fmt.Printf("Install with: envswitch shell install %s\n", shellType)
+	case !status.Intact:
+		fmt.Printf("Repair with:    envswitch shell repair %s\n", shellType)
+		fmt.Printf("Or remove with: envswitch shell uninstall %s\n", shellType)
+	default:
+		fmt.Printf("Remove with: envswitch shell uninstall %s\n", shellType)
+	}
+	return nil
+}
+
+func runShellRepair(cmd *cobra.Command, args []string) error {
+	shellType := shellTypeArg(args)
+	configFile, err := shell.RepairIntegration(shellType)
+	if err != nil {
+		return fmt.Errorf("failed to repair shell integration: %w", err)
+	}
+
+	fmt.Printf("✅ Shell integration repaired in %s\n", configFile)
+	fmt.Println("Restart your shell to load it.")
+	return nil
+}
+
+func runShellUninstall(cmd *cobra.Command, args []string) error {
+	shellType := shellTypeArg(args)
+	configFile, removed, err := shell.UninstallIntegration(shellType)
+	if err != nil {
+		return fmt.Errorf("failed to uninstall shell integration: %w", err)
+	}
+	if !removed {
+		fmt.Printf("Shell integration is not installed in %s\n", configFile)
+		return nil
+	}
+
+	fmt.Printf("✅ Shell integration removed from %s\n", configFile)
+	fmt.Println("Restart your shell to unload it.")
+	return nil
+}
+
+func runShellHistory(cmd *cobra.Command, args []string) error {
+	env, err := environment.GetCurrentEnvironment()
+	if err != nil {
+		return fmt.Errorf("failed to get current environment: %w", err)
+	}
+	if env == nil {
+		return fmt.Errorf("no active environment")
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		cfg = config.DefaultConfig()
+	}
+
+	if len(args) == 0 {
+		setting := env.ShellHistory
+		if setting == "" {
+			setting = "default"
+		}
+		state := "shared"
+		if env.HistoryIsolated(cfg.IsolateShellHistory) {
+			state = "isolated"
+		}
+		fmt.Printf("Shell history for '%s': %s (%s)\n", env.Name, state, setting)
+		return nil
+	}
+
+	switch args[0] {
+	case environment.ShellHistoryIsolated, environment.ShellHistoryShared:
+		env.ShellHistory = args[0]
+	case "default":
+		env.ShellHistory = ""
+	default:
+		return fmt.Errorf("invalid shell history setting '%s' (expected isolated, shared or default)", args[0])
+	}
+
+	if err := env.Save(); err != nil {
+		return fmt.Errorf("failed to save environment: %w", err)
+	}
+
+	if env.HistoryIsolated(cfg.IsolateShellHistory) {
+		fmt.Printf("✅ Environment '%s' now keeps its own shell history\n", env.Name)
+	} else {
+		fmt.Printf("✅ Environment '%s' now uses the default shell history\n", env.Name)
+	}
+	fmt.Println("   Requires the shell integration (envswitch shell install)")
+
+	return nil
+}
+
+func runShellHistoryFile(cmd *cobra.Command, args []string) error {
+	switch args[0] {
+	case "bash", "zsh", "fish":
+	default:
+		return fmt.Errorf("unsupported shell: %s", args[0])
+	}
+
+	env, err := environment.GetCurrentEnvironment()
+	if err != nil || env == nil {
+		return err
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		cfg = config.DefaultConfig()
+	}
+
+	if !env.HistoryIsolated(cfg.IsolateShellHistory) {
+		return nil
+	}
+
+	historyFile, err := env.ShellHistoryFile(args[0])
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(cmd.OutOrStdout(), historyFile)
+	return nil
+}
@@ -1,14 +1,17 @@
 package cmd
 
 import (
+	"bytes"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/hugofrely/envswitch/internal/config"
+	"github.com/hugofrely/envswitch/pkg/environment"
 )
 
 func TestShellCommand(t *testing.T) {
@@ -291,3 +294,81 @@ func TestShellInstallCommand(t *testing.T) {
 		assert.NoError(t, err)
 	})
 }
+
+func TestShellHistoryCommands(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+
+	envsDir := filepath.Join(tempHome, ".envswitch", "environments")
+	require.NoError(t, os.MkdirAll(envsDir, 0755))
+	createEnvWithVars(t, envsDir, "client", nil)
+	require.NoError(t, environment.SetCurrentEnvironment("client"))
+
+	historyFile := func(shellType string) string {
+		var out bytes.Buffer
+		shellHistoryFileCmd.SetOut(&out)
+		defer shellHistoryFileCmd.SetOut(nil)
+		require.NoError(t, runShellHistoryFile(shellHistoryFileCmd, []string{shellType}))
+		return strings.TrimSpace(out.String())
+	}
+
+	t.Run("shared by default", func(t *testing.T) {
+		assert.Empty(t, historyFile("bash"))
+	})
+
+	t.Run("isolated per environment", func(t *testing.T) {
+		require.NoError(t, runShellHistory(shellHistoryCmd, []string{"isolated"}))
+
+		env, err := environment.LoadEnvironment("client")
+		require.NoError(t, err)
+		assert.Equal(t, environment.ShellHistoryIsolated, env.ShellHistory)
+		assert.Equal(t, filepath.Join(tempHome, ".envswitch", "shell-history", "client", "bash_history"), historyFile("bash"))
+		assert.Equal(t, "envswitch_client", historyFile("fish"))
+	})
+
+	t.Run("follows the config default", func(t *testing.T) {
+		require.NoError(t, runShellHistory(shellHistoryCmd, []string{"default"}))
+
+		cfg := config.DefaultConfig()
+		cfg.IsolateShellHistory = true
+		require.NoError(t, cfg.Save())
+		assert.NotEmpty(t, historyFile("zsh"))
+
+		require.NoError(t, runShellHistory(shellHistoryCmd, []string{"shared"}))
+		assert.Empty(t, historyFile("zsh"))
+	})
+
+	t.Run("rejects invalid values", func(t *testing.T) {
+		assert.Error(t, runShellHistory(shellHistoryCmd, []string{"private"}))
+		assert.Error(t, runShellHistoryFile(shellHistoryFileCmd, []string{"../../tmp"}))
+	})
+}
+
+func TestShellStatusRepairUninstall(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+	zshrc := filepath.Join(tempHome, ".zshrc")
+	require.NoError(t, os.WriteFile(zshrc, []byte("# envswitch shell integration\n"), 0644))
+
+	out := captureStdout(t, func() {
+		require.NoError(t, runShellStatus(shellStatusCmd, []string{"zsh"}))
+	})
+	assert.Contains(t, out, zshrc)
+	assert.Contains(t, out, "broken")
+	assert.Contains(t, out, "envswitch shell repair zsh")
+
+	captureStdout(t, func() {
+		require.NoError(t, runShellRepair(shellRepairCmd, []string{"zsh"}))
+	})
+	out = captureStdout(t, func() {
+		require.NoError(t, runShellStatus(shellStatusCmd, []string{"zsh"}))
+	})
+	assert.Contains(t, out, "installed, line 2")
+
+	captureStdout(t, func() {
+		require.NoError(t, runShellUninstall(shellUninstallCmd, []string{"zsh"}))
+	})
+	data, err := os.ReadFile(zshrc)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "envswitch")
+}
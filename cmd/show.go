@@ -1,27 +1,124 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 
+	"github.com/dustin/go-humanize"
 	"github.com/spf13/cobra"
 
+	"github.com/hugofrely/envswitch/internal/archive"
+	"github.com/hugofrely/envswitch/internal/history"
+	"github.com/hugofrely/envswitch/internal/hooks"
+	"github.com/hugofrely/envswitch/internal/storage"
 	"github.com/hugofrely/envswitch/pkg/environment"
 )
 
+var (
+	showArchive string
+	showJSON    bool
+)
+
 var showCmd = &cobra.Command{
-	Use:               "show <name>",
-	Short:             "Show details of an environment",
-	Long:              `Display detailed information about a specific environment.`,
-	Args:              cobra.ExactArgs(1),
+	Use:   "show <name>",
+	Short: "Show details of an environment",
+	Long: `Display detailed information about a specific environment.
+
+Shows metadata, the size and age of each tool snapshot, environment
+variables (values masked), hooks and the latest switches involving the
+environment.
+
+With --archive, inspect an exported archive without importing it. Only
+the names of environment variables are shown, never their values.
+
+Examples:
+  # Show an installed environment
+  envswitch show work
+
+  # Machine-readable output
+  envswitch show work --json
+
+  # Inspect an archive a colleague sent
+  envswitch show --archive client-a-export.tar.gz`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if showArchive != "" {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.ExactArgs(1)(cmd, args)
+	},
 	ValidArgsFunction: completeEnvironmentNames,
 	RunE:              runShow,
 }
 
 func init() {
 	rootCmd.AddCommand(showCmd)
+	showCmd.Flags().StringVar(&showArchive, "archive", "", "Inspect an export archive instead of an installed environment")
+	showCmd.Flags().BoolVar(&showJSON, "json", false, "Output as JSON")
+	_ = showCmd.MarkFlagFilename("archive", "tar.gz", "tgz")
+}
+
+// showHistoryLimit is the number of switches involving the environment shown
+const showHistoryLimit = 5
+
+// environmentDetails is the summary of an environment printed by show
+type environmentDetails struct {
+	Name         string                `json:"name"`
+	Description  string                `json:"description,omitempty"`
+	Active       bool                  `json:"active"`
+	CreatedAt    time.Time             `json:"created_at"`
+	LastUsed     *time.Time            `json:"last_used,omitempty"`
+	LastSnapshot *time.Time            `json:"last_snapshot,omitempty"`
+	SnapshotSize uint64                `json:"snapshot_size,omitempty"` // as of the last snapshot
+	Tags         []string              `json:"tags,omitempty"`
+	GitIncludes  []string              `json:"git_includes,omitempty"`
+	Protected    bool                  `json:"protected,omitempty"`
+	KubeContexts []string              `json:"allowed_kube_contexts,omitempty"`
+	Tools        []toolDetails         `json:"tools"`
+	EnvVars      map[string]string     `json:"env_vars,omitempty"` // values masked
+	Hooks        []hookDetails         `json:"hooks,omitempty"`
+	Hosts        []string              `json:"machine_snapshots,omitempty"`
+	History      []history.SwitchEntry `json:"recent_switches,omitempty"`
+}
+
+// toolDetails describes the snapshot of one enabled tool
+type toolDetails struct {
+	Name        string                 `json:"name"`
+	Metadata    map[string]interface{} `json:"metadata,omitempty"`
+	HasSnapshot bool                   `json:"has_snapshot"`
+	SizeBytes   uint64                 `json:"size_bytes"`
+	Files       int                    `json:"files"`
+	ModifiedAt  *time.Time             `json:"modified_at,omitempty"`
+
+	// Left out of the snapshot by exclude patterns, e.g. gcloud logs
+	ExcludedFiles int    `json:"excluded_files,omitempty"`
+	ExcludedBytes uint64 `json:"excluded_bytes,omitempty"`
+}
+
+// hookDetails describes a hook and the event that runs it
+type hookDetails struct {
+	Event       string            `json:"event"`
+	Command     string            `json:"command,omitempty"`
+	Script      string            `json:"script,omitempty"`
+	Action      string            `json:"action,omitempty"`
+	With        map[string]string `json:"with,omitempty"`
+	Args        []string          `json:"args,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Timeout     string            `json:"timeout,omitempty"`
+	Cwd         string            `json:"cwd,omitempty"`
+	Env         map[string]string `json:"env,omitempty"`
+	OnFailure   string            `json:"on_failure,omitempty"`
 }
 
 func runShow(cmd *cobra.Command, args []string) error {
+	if showArchive != "" {
+		return showArchiveFile(showArchive)
+	}
+
 	name := args[0]
 
 	env, err := environment.LoadEnvironment(name)
@@ -29,13 +126,277 @@ func runShow(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to load environment '%s': %w", name, err)
 	}
 
+	details := collectEnvironmentDetails(env)
+
+	if showJSON {
+		data, err := json.MarshalIndent(details, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to format environment: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	printEnvironmentDetails(details)
+	return nil
+}
+
+// collectEnvironmentDetails gathers everything show displays about env
+func collectEnvironmentDetails(env *environment.Environment) *environmentDetails {
+	details := &environmentDetails{
+		Name:         env.Name,
+		Description:  env.Description,
+		CreatedAt:    env.CreatedAt,
+		Tags:         env.Tags,
+		GitIncludes:  env.Git.Includes,
+		Protected:    env.Protected,
+		KubeContexts: env.Kubectl.AllowedContexts,
+		Tools:        []toolDetails{},
+		Hosts:        env.ListSnapshotHosts(),
+		SnapshotSize: env.SnapshotSize(),
+	}
+
+	if current, _ := environment.GetCurrentEnvironment(); current != nil {
+		details.Active = current.Name == env.Name
+	}
+	if !env.LastUsed.IsZero() {
+		lastUsed := env.LastUsed
+		details.LastUsed = &lastUsed
+	}
+	if !env.LastSnapshot.IsZero() {
+		lastSnapshot := env.LastSnapshot
+		details.LastSnapshot = &lastSnapshot
+	}
+
+	toolNames := make([]string, 0, len(env.Tools))
+	for toolName, toolConfig := range env.Tools {
+		if toolConfig.Enabled {
+			toolNames = append(toolNames, toolName)
+		}
+	}
+	sort.Strings(toolNames)
+
+	for _, toolName := range toolNames {
+		tool := toolDetails{Name: toolName, Metadata: env.Tools[toolName].Metadata}
+		snapshotPath := filepath.Join(env.Path, "snapshots", toolName)
+		if _, err := os.Stat(snapshotPath); err == nil {
+			tool.HasSnapshot = true
+			tool.SizeBytes, tool.Files, tool.ModifiedAt = snapshotStats(snapshotPath)
+			manifest := storage.LoadManifest(snapshotPath)
+			tool.ExcludedFiles, tool.ExcludedBytes = manifest.ExcludedFiles, uint64(manifest.ExcludedBytes)
+		}
+		details.Tools = append(details.Tools, tool)
+	}
+
+	// Captured values override the ones recorded in the metadata
+	if envVars, err := env.ListEnvVarsMasked(secretMask); err == nil && len(envVars) > 0 {
+		details.EnvVars = make(map[string]string, len(envVars))
+		for _, envVar := range envVars {
+			details.EnvVars[envVar.Key] = maskValue(envVar.Value)
+		}
+	}
+
+	for _, event := range environment.HookEvents {
+		hooks, _ := env.Hooks.ForEvent(event)
+		for _, hook := range *hooks {
+			details.Hooks = append(details.Hooks, hookDetails{
+				Event:       event,
+				Command:     hook.Command,
+				Script:      hook.Script,
+				Action:      hook.Action,
+				With:        hook.With,
+				Args:        hook.Args,
+				Description: hook.Description,
+				Timeout:     hook.Timeout,
+				Cwd:         hook.Cwd,
+				Env:         hook.Env,
+				OnFailure:   hook.OnFailure,
+			})
+		}
+	}
+
+	if hist, err := history.LoadHistory(); err == nil {
+		for i := len(hist.Entries) - 1; i >= 0 && len(details.History) < showHistoryLimit; i-- {
+			entry := hist.Entries[i]
+			if entry.From == env.Name || entry.To == env.Name {
+				details.History = append(details.History, entry)
+			}
+		}
+	}
+
+	return details
+}
+
+// printEnvironmentDetails displays environment details in a human readable form
+func printEnvironmentDetails(details *environmentDetails) {
+	fmt.Printf("Environment: %s", details.Name)
+	if details.Active {
+		fmt.Print(" (active)")
+	}
+	if details.Protected {
+		fmt.Print(" 🔒 protected")
+	}
+	fmt.Println()
+	if details.Description != "" {
+		fmt.Printf("Description: %s\n", details.Description)
+	}
+	fmt.Printf("Created: %s\n", details.CreatedAt.Format("2006-01-02 15:04:05"))
+	if details.LastUsed != nil {
+		fmt.Printf("Last used: %s\n", details.LastUsed.Format("2006-01-02 15:04:05"))
+	}
+	if details.LastSnapshot != nil {
+		fmt.Printf("Last snapshot: %s\n", details.LastSnapshot.Format("2006-01-02 15:04:05"))
+	}
+	if details.SnapshotSize > 0 {
+		fmt.Printf("Snapshot size: %s\n", humanize.Bytes(details.SnapshotSize))
+	}
+	fmt.Println()
+
+	fmt.Println("📸 Snapshot Contents:")
+	fmt.Println()
+
+	for _, tool := range details.Tools {
+		fmt.Printf("  ✓ %s\n", tool.Name)
+		switch {
+		case !tool.HasSnapshot:
+			fmt.Println("    ⚠️  no snapshot")
+		case tool.ModifiedAt != nil:
+			fmt.Printf("    snapshot: %d file(s), %s, updated %s\n", tool.Files, humanize.Bytes(tool.SizeBytes), tool.ModifiedAt.Format("2006-01-02 15:04:05"))
+		default:
+			fmt.Println("    snapshot: empty")
+		}
+		if tool.ExcludedFiles > 0 {
+			fmt.Printf("    excluded: %d file(s), %s left out by exclude patterns\n", tool.ExcludedFiles, humanize.Bytes(tool.ExcludedBytes))
+		}
+		for _, key := range sortedKeys(tool.Metadata) {
+			fmt.Printf("    - %s: %v\n", key, tool.Metadata[key])
+		}
+		fmt.Println()
+	}
+
+	if len(details.EnvVars) > 0 {
+		fmt.Printf("  ✓ Environment Variables (%d)\n", len(details.EnvVars))
+		keys := make([]string, 0, len(details.EnvVars))
+		for key := range details.EnvVars {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fmt.Printf("    %s=%s\n", key, details.EnvVars[key])
+		}
+		fmt.Println()
+	}
+
+	if len(details.Hooks) > 0 {
+		fmt.Println("🪝 Hooks:")
+		for _, hook := range details.Hooks {
+			action := hook.Command
+			switch {
+			case hook.Action != "":
+				action = "action: " + hooks.DescribeAction(environment.Hook{Action: hook.Action, With: hook.With, Args: hook.Args})
+			case action == "":
+				action = hook.Script
+			}
+			fmt.Printf("  %-13s %s", hook.Event, action)
+			if hook.Description != "" {
+				fmt.Printf("  # %s", hook.Description)
+			}
+			fmt.Println()
+			if options := hookOptions(hook); options != "" {
+				fmt.Printf("  %-13s (%s)\n", "", options)
+			}
+		}
+		fmt.Println()
+	}
+
+	if len(details.Tags) > 0 {
+		fmt.Printf("Tags: %v\n", details.Tags)
+	}
+
+	if len(details.KubeContexts) > 0 {
+		fmt.Printf("Allowed kubectl contexts: %s\n", strings.Join(details.KubeContexts, ", "))
+	}
+
+	if len(details.GitIncludes) > 0 {
+		fmt.Printf("Git includes: %s\n", strings.Join(details.GitIncludes, ", "))
+	}
+
+	if len(details.Hosts) > 0 {
+		fmt.Printf("Machine-specific snapshots: %s (this machine: %s)\n", strings.Join(details.Hosts, ", "), environment.CurrentHost())
+	}
+
+	if len(details.History) > 0 {
+		fmt.Println()
+		fmt.Println("🕐 Recent switches:")
+		for i := range details.History {
+			displayHistoryEntry(&details.History[i], false)
+		}
+	}
+}
+
+// snapshotStats returns the total size, the number of files and the time of
+// the latest change of the files under a snapshot directory
+func snapshotStats(snapshotPath string) (uint64, int, *time.Time) {
+	var (
+		size     uint64
+		files    int
+		modified time.Time
+	)
+
+	_ = filepath.Walk(snapshotPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() || info.Name() == storage.ManifestFile {
+			return nil
+		}
+		size += uint64(info.Size())
+		files++
+		if info.ModTime().After(modified) {
+			modified = info.ModTime()
+		}
+		return nil
+	})
+
+	if files == 0 {
+		return 0, 0, nil
+	}
+	return size, files, &modified
+}
+
+// secretMask replaces the values of environment variables that are not shown
+const secretMask = "********"
+
+// maskValue hides an environment variable value, which may be a secret
+func maskValue(value string) string {
+	if value == "" {
+		return ""
+	}
+	return secretMask
+}
+
+// sortedKeys returns the keys of a metadata map in order
+func sortedKeys(values map[string]interface{}) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// showArchiveFile displays the content of an archive without importing it
+func showArchiveFile(archivePath string) error {
+	info, err := archive.InspectArchive(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to read archive '%s': %w", archivePath, err)
+	}
+	env := info.Environment
+
+	fmt.Printf("Archive: %s (%d files, %s)\n", archivePath, info.FileCount, humanize.Bytes(uint64(info.SizeBytes)))
 	fmt.Printf("Environment: %s\n", env.Name)
 	if env.Description != "" {
 		fmt.Printf("Description: %s\n", env.Description)
 	}
-	fmt.Printf("Created: %s\n", env.CreatedAt.Format("2006-01-02 15:04:05"))
-	if !env.LastUsed.IsZero() {
-		fmt.Printf("Last used: %s\n", env.LastUsed.Format("2006-01-02 15:04:05"))
+	if !env.CreatedAt.IsZero() {
+		fmt.Printf("Created: %s\n", env.CreatedAt.Format("2006-01-02 15:04:05"))
 	}
 	if !env.LastSnapshot.IsZero() {
 		fmt.Printf("Last snapshot: %s\n", env.LastSnapshot.Format("2006-01-02 15:04:05"))
@@ -45,25 +406,36 @@ func runShow(cmd *cobra.Command, args []string) error {
 	fmt.Println("📸 Snapshot Contents:")
 	fmt.Println()
 
+	toolNames := make([]string, 0, len(env.Tools))
 	for toolName, toolConfig := range env.Tools {
-		if !toolConfig.Enabled {
-			continue
+		if toolConfig.Enabled {
+			toolNames = append(toolNames, toolName)
 		}
+	}
+	sort.Strings(toolNames)
 
+	for _, toolName := range toolNames {
 		fmt.Printf("  ✓ %s\n", toolName)
-		if len(toolConfig.Metadata) > 0 {
-			for key, value := range toolConfig.Metadata {
-				fmt.Printf("    - %s: %v\n", key, value)
-			}
+		if !containsString(info.Snapshots, toolName) {
+			fmt.Println("    ⚠️  no snapshot in archive")
+		}
+		for key, value := range env.Tools[toolName].Metadata {
+			fmt.Printf("    - %s: %v\n", key, value)
 		}
 		fmt.Println()
 	}
 
-	if len(env.EnvVars) > 0 {
-		fmt.Printf("  ✓ Environment Variables (%d)\n", len(env.EnvVars))
-		for key, value := range env.EnvVars {
-			fmt.Printf("    %s=%s\n", key, value)
+	// Only variable names are displayed: the archive may hold secrets
+	keys := append([]string{}, info.EnvVarKeys...)
+	for key := range env.EnvVars {
+		if !containsString(keys, key) {
+			keys = append(keys, key)
 		}
+	}
+	sort.Strings(keys)
+	if len(keys) > 0 {
+		fmt.Printf("  ✓ Environment Variables (%d)\n", len(keys))
+		fmt.Printf("    %s\n", strings.Join(keys, ", "))
 		fmt.Println()
 	}
 
@@ -71,5 +443,40 @@ func runShow(cmd *cobra.Command, args []string) error {
 		fmt.Printf("Tags: %v\n", env.Tags)
 	}
 
+	fmt.Printf("Use 'envswitch import %s' to install it.\n", archivePath)
 	return nil
 }
+
+// containsString reports whether values contains value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// hookOptions summarizes the timeout, working directory, variables and
+// failure policy of a hook
+func hookOptions(hook hookDetails) string {
+	var options []string
+	if hook.Timeout != "" {
+		options = append(options, "timeout "+hook.Timeout)
+	}
+	if hook.Cwd != "" {
+		options = append(options, "cwd "+hook.Cwd)
+	}
+	if len(hook.Env) > 0 {
+		keys := make([]string, 0, len(hook.Env))
+		for key := range hook.Env {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		options = append(options, "env "+strings.Join(keys, ","))
+	}
+	if hook.OnFailure != "" {
+		options = append(options, "on_failure "+hook.OnFailure)
+	}
+	return strings.Join(options, ", ")
+}
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"encoding/json"
 	"os"
 	"path/filepath"
 	"testing"
@@ -9,6 +10,9 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/hugofrely/envswitch/internal/archive"
+	"github.com/hugofrely/envswitch/internal/history"
+	"github.com/hugofrely/envswitch/internal/storage"
 	"github.com/hugofrely/envswitch/pkg/environment"
 )
 
@@ -217,3 +221,113 @@ func TestShowCommand(t *testing.T) {
 		assert.NoError(t, err)
 	})
 }
+
+func TestRunShowArchive(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+
+	envsDir := filepath.Join(tempHome, ".envswitch", "environments")
+	env := createEnvWithVars(t, envsDir, "client-a", map[string]string{"API_URL": ""})
+	require.NoError(t, env.SaveEnvVars([]environment.EnvVar{{Key: "API_URL", Value: "https://a"}}))
+
+	arch, err := archive.ArchiveEnvironment(env)
+	require.NoError(t, err)
+
+	// Inspecting must work even once the environment is gone
+	require.NoError(t, os.RemoveAll(env.Path))
+
+	showArchive = arch.Path
+	defer func() { showArchive = "" }()
+
+	t.Run("accepts no arguments", func(t *testing.T) {
+		assert.NoError(t, showCmd.Args(showCmd, []string{}))
+		assert.Error(t, showCmd.Args(showCmd, []string{"client-a"}))
+	})
+
+	t.Run("shows archive content", func(t *testing.T) {
+		require.NoError(t, runShow(showCmd, nil))
+
+		_, err := environment.LoadEnvironment("client-a")
+		assert.Error(t, err, "archive should not be imported")
+	})
+
+	t.Run("fails on missing archive", func(t *testing.T) {
+		showArchive = filepath.Join(tempHome, "missing.tar.gz")
+		assert.Error(t, runShow(showCmd, nil))
+	})
+}
+
+func TestCollectEnvironmentDetails(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+
+	envsDir := filepath.Join(tempHome, ".envswitch", "environments")
+	env := &environment.Environment{
+		Name:      "client",
+		CreatedAt: time.Now(),
+		Path:      filepath.Join(envsDir, "client"),
+		Tools: map[string]environment.ToolConfig{
+			"kubectl": {Enabled: true, Metadata: map[string]interface{}{"current_context": "prod"}},
+			"git":     {Enabled: true},
+			"aws":     {Enabled: false},
+		},
+		EnvVars: map[string]string{"API_TOKEN": "secret-token"},
+		Hooks: environment.Hooks{
+			PostSwitch: []environment.Hook{{Command: "kubectl get ns", Description: "check access"}},
+		},
+	}
+	require.NoError(t, os.MkdirAll(filepath.Join(env.Path, "snapshots", "kubectl"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(env.Path, "snapshots", "kubectl", "config"), []byte("12345"), 0600))
+	manifest := &storage.Manifest{Files: map[string]storage.ManifestEntry{}, ExcludedFiles: 3, ExcludedBytes: 2000}
+	require.NoError(t, manifest.Save(filepath.Join(env.Path, "snapshots", "kubectl")))
+	require.NoError(t, env.Save())
+	require.NoError(t, environment.SetCurrentEnvironment("client"))
+
+	hist := &history.History{}
+	require.NoError(t, hist.AddEntry(&history.SwitchEntry{Timestamp: time.Now(), From: "home", To: "client", Success: true}))
+	require.NoError(t, hist.AddEntry(&history.SwitchEntry{Timestamp: time.Now(), From: "home", To: "other", Success: true}))
+
+	details := collectEnvironmentDetails(env)
+
+	assert.True(t, details.Active)
+
+	require.Len(t, details.Tools, 2)
+	assert.Equal(t, "git", details.Tools[0].Name)
+	assert.False(t, details.Tools[0].HasSnapshot)
+	assert.Equal(t, "kubectl", details.Tools[1].Name)
+	assert.True(t, details.Tools[1].HasSnapshot)
+	assert.Equal(t, uint64(5), details.Tools[1].SizeBytes)
+	assert.Equal(t, 1, details.Tools[1].Files)
+	assert.NotNil(t, details.Tools[1].ModifiedAt)
+	assert.Equal(t, 3, details.Tools[1].ExcludedFiles)
+
+	assert.Equal(t, map[string]string{"API_TOKEN": "********"}, details.EnvVars)
+
+	require.Len(t, details.Hooks, 1)
+	assert.Equal(t, "post_switch", details.Hooks[0].Event)
+
+	require.Len(t, details.History, 1)
+	assert.Equal(t, "client", details.History[0].To)
+
+	t.Run("json output never contains secret values", func(t *testing.T) {
+		showJSON = true
+		defer func() { showJSON = false }()
+
+		out := captureStdout(t, func() { require.NoError(t, runShow(showCmd, []string{"client"})) })
+		assert.NotContains(t, out, "secret-token")
+
+		var decoded environmentDetails
+		require.NoError(t, json.Unmarshal([]byte(out), &decoded))
+		assert.Equal(t, "client", decoded.Name)
+	})
+
+	t.Run("text output", func(t *testing.T) {
+		out := captureStdout(t, func() { require.NoError(t, runShow(showCmd, []string{"client"})) })
+		assert.Contains(t, out, "Environment: client (active)")
+		assert.Contains(t, out, "excluded: 3 file(s), 2.0 kB left out by exclude patterns")
+		assert.Contains(t, out, "API_TOKEN=********")
+		assert.Contains(t, out, "post_switch")
+		assert.Contains(t, out, "Recent switches")
+		assert.NotContains(t, out, "secret-token")
+	})
+}
@@ -0,0 +1,177 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+
+	"github.com/hugofrely/envswitch/internal/config"
+	"github.com/hugofrely/envswitch/internal/logger"
+	"github.com/hugofrely/envswitch/pkg/environment"
+	"github.com/hugofrely/envswitch/pkg/spinner"
+)
+
+var snapshotsRestoreYes bool
+
+var snapshotsCmd = &cobra.Command{
+	Use:   "snapshots",
+	Short: "List and restore the saved states of an environment",
+	Long: `Every 'envswitch save' keeps the saved state of the environment in its
+snapshot history, ~/.envswitch/snapshots-history/<environment>, with the
+message given with --message. The last snapshot_history_limit states are kept
+(10 by default, 0 to keep none).
+
+Examples:
+  envswitch snapshots list work
+  envswitch snapshots restore work 12`,
+}
+
+var snapshotsListCmd = &cobra.Command{
+	Use:               "list [environment]",
+	Short:             "List the saved states of an environment",
+	Long:              `List the saved states of env, or of the active environment, the most recent first.`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeEnvironmentNames,
+	RunE:              runSnapshotsList,
+}
+
+var snapshotsRestoreCmd = &cobra.Command{
+	Use:   "restore <environment> <id>",
+	Short: "Restore a saved state of an environment",
+	Long: `Replace the snapshots of env with one of its saved states, as listed by
+'envswitch snapshots list'.
+
+The state being replaced is added to the history first, so a restore can be
+undone. When env is the active environment, its tools are restored too.
+
+Examples:
+  envswitch snapshots restore work 12`,
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: completeSnapshotsRestoreArgs,
+	RunE:              runSnapshotsRestore,
+}
+
+func init() {
+	rootCmd.AddCommand(snapshotsCmd)
+	snapshotsCmd.AddCommand(snapshotsListCmd)
+	snapshotsCmd.AddCommand(snapshotsRestoreCmd)
+
+	snapshotsRestoreCmd.Flags().BoolVarP(&snapshotsRestoreYes, "yes", "y", false, "Skip the confirmation of protected environments")
+}
+
+func runSnapshotsList(cmd *cobra.Command, args []string) error {
+	env, err := hooksTargetEnv(args, 1)
+	if err != nil {
+		return err
+	}
+
+	records, err := env.ListSnapshotHistory()
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		fmt.Printf("No saved states of '%s' yet, 'envswitch save' records them\n", env.Name)
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSAVED\tSIZE\tMESSAGE")
+	for _, record := range records {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", record.ID, formatTimeAgo(record.CreatedAt),
+			humanize.Bytes(record.SizeBytes), record.Message)
+	}
+	return w.Flush()
+}
+
+func runSnapshotsRestore(cmd *cobra.Command, args []string) error {
+	env, err := environment.LoadEnvironment(args[0])
+	if err != nil {
+		return fmt.Errorf("environment '%s' not found: %w", args[0], err)
+	}
+	id, err := strconv.Atoi(args[1])
+	if err != nil || id <= 0 {
+		return fmt.Errorf("invalid snapshot ID '%s'", args[1])
+	}
+	record, err := env.FindSnapshot(id)
+	if err != nil {
+		return err
+	}
+
+	if env.Protected && !snapshotsRestoreYes && !confirmProtected(env, "restore it") {
+		return fmt.Errorf("restore of protected environment '%s' canceled (use --yes to skip the confirmation)", env.Name)
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		logger.Warn("Failed to load config, using defaults: %v", err)
+		cfg = config.DefaultConfig()
+	}
+
+	// Keep the state being replaced, the restore can be undone
+	limit := cfg.SnapshotHistoryLimit
+	if limit > 0 {
+		limit++ // never prunes the record being restored
+	}
+	if _, err := env.RecordSnapshot(fmt.Sprintf("Before restoring #%d", id), limit); err != nil {
+		return fmt.Errorf("failed to record the current state: %w", err)
+	}
+
+	if err := env.RestoreSnapshot(record); err != nil {
+		return fmt.Errorf("failed to restore snapshot #%d: %w", id, err)
+	}
+	fmt.Printf("✅ Restored snapshot #%d of '%s'", id, env.Name)
+	if record.Message != "" {
+		fmt.Printf(": %s", record.Message)
+	}
+	fmt.Println()
+
+	current, err := environment.GetCurrentEnvironment()
+	if err != nil || current == nil || current.Name != env.Name {
+		return nil
+	}
+
+	endSwitch := beginSwitch()
+	defer endSwitch()
+
+	s := spinner.New("Restoring tools")
+	s.Start()
+	ctx := withCopyProgress(context.Background(), spinnerCopyProgress(s, "Restoring"))
+	count, err := restoreEnvironment(ctx, env, liveToolFilter(env).apply(getToolRegistry()), spinnerProgress(s, "Restoring"))
+	if err != nil {
+		s.Error(fmt.Sprintf("Failed to restore tools: %v", err))
+		return err
+	}
+	s.Success(fmt.Sprintf("Restored %d tool(s) of '%s'", count, env.Name))
+	recordPromptStatus(env.Name, false)
+	return nil
+}
+
+// completeSnapshotsRestoreArgs completes the environment, then the IDs of its
+// saved states
+func completeSnapshotsRestoreArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	switch len(args) {
+	case 0:
+		return completeEnvironmentNames(cmd, args, toComplete)
+	case 1:
+		env, err := environment.LoadEnvironment(args[0])
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		records, err := env.ListSnapshotHistory()
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		var ids []string
+		for _, record := range records {
+			ids = append(ids, fmt.Sprintf("%d\t%s", record.ID, record.Message))
+		}
+		return ids, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveKeepOrder
+	default:
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunSnapshots(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+
+	envsDir := filepath.Join(tempHome, ".envswitch", "environments")
+	require.NoError(t, os.MkdirAll(envsDir, 0755))
+	env := createEnvWithVars(t, envsDir, "work", nil)
+	gitconfig := filepath.Join(env.Path, "snapshots", "git", "gitconfig")
+
+	t.Run("lists no states before a save", func(t *testing.T) {
+		out := captureStdout(t, func() {
+			require.NoError(t, runSnapshotsList(snapshotsListCmd, []string{"work"}))
+		})
+		assert.Contains(t, out, "No saved states of 'work' yet")
+	})
+
+	writeFile := func(content string) {
+		require.NoError(t, os.MkdirAll(filepath.Dir(gitconfig), 0755))
+		require.NoError(t, os.WriteFile(gitconfig, []byte(content), 0600))
+	}
+	writeFile("v1")
+	_, err := env.RecordSnapshot("first", 10)
+	require.NoError(t, err)
+	writeFile("v2")
+	_, err = env.RecordSnapshot("", 10)
+	require.NoError(t, err)
+
+	t.Run("lists the states", func(t *testing.T) {
+		out := captureStdout(t, func() {
+			require.NoError(t, runSnapshotsList(snapshotsListCmd, []string{"work"}))
+		})
+		assert.Regexp(t, `ID\s+SAVED\s+SIZE\s+MESSAGE\n2\s.*\n1\s.*first\n`, out)
+	})
+
+	t.Run("restores a state and keeps the replaced one", func(t *testing.T) {
+		writeFile("v3")
+
+		out := captureStdout(t, func() {
+			require.NoError(t, runSnapshotsRestore(snapshotsRestoreCmd, []string{"work", "1"}))
+		})
+		assert.Contains(t, out, "✅ Restored snapshot #1 of 'work': first")
+
+		data, err := os.ReadFile(gitconfig)
+		require.NoError(t, err)
+		assert.Equal(t, "v1", string(data))
+
+		records, err := env.ListSnapshotHistory()
+		require.NoError(t, err)
+		require.Len(t, records, 3)
+		assert.Equal(t, "Before restoring #1", records[0].Message)
+		data, err = os.ReadFile(filepath.Join(records[0].Path, "snapshots", "git", "gitconfig"))
+		require.NoError(t, err)
+		assert.Equal(t, "v3", string(data))
+	})
+
+	t.Run("rejects unknown states", func(t *testing.T) {
+		assert.ErrorContains(t, runSnapshotsRestore(snapshotsRestoreCmd, []string{"work", "9"}), "has no snapshot #9")
+		assert.ErrorContains(t, runSnapshotsRestore(snapshotsRestoreCmd, []string{"work", "one"}), "invalid snapshot ID")
+	})
+
+	t.Run("deleting the environment removes its history", func(t *testing.T) {
+		deleteNoArchive = true
+		defer func() { deleteNoArchive = false }()
+
+		captureStdout(t, func() {
+			require.NoError(t, deleteEnvironment(env, false))
+		})
+		assert.NoDirExists(t, filepath.Join(tempHome, ".envswitch", "snapshots-history", "work"))
+	})
+}
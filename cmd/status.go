@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+var statusExitCode bool
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show whether the live config drifted from the active environment",
+	Long: `Show the active environment, how long ago it was snapshotted, and for
+each enabled tool whether its live config differs from the snapshot.
+
+With --exit-code, the exit status is 1 when a tool drifted and 0 otherwise,
+for shell prompts and CI.
+
+Examples:
+  envswitch status
+  envswitch status --exit-code || echo "unsaved changes"
+  envswitch status --output json`,
+	Args: cobra.NoArgs,
+	RunE: runStatus,
+}
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+	statusCmd.Flags().BoolVar(&statusExitCode, "exit-code", false, "Exit with status 1 when the live config drifted")
+}
+
+// envStatus is the drift of the live config from the active environment
+type envStatus struct {
+	Environment  string       `json:"environment,omitempty" yaml:"environment,omitempty"`
+	LastSnapshot *time.Time   `json:"last_snapshot,omitempty" yaml:"last_snapshot,omitempty"`
+	Dirty        bool         `json:"dirty" yaml:"dirty"`
+	Tools        []toolStatus `json:"tools" yaml:"tools"`
+}
+
+// toolStatus is the drift of a tool from its snapshot
+type toolStatus struct {
+	Tool    string `json:"tool" yaml:"tool"`
+	Dirty   bool   `json:"dirty" yaml:"dirty"`
+	Changes int    `json:"changes" yaml:"changes"`
+	Error   string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+func runStatus(cmd *cobra.Command, args []string) error {
+	out, err := resultWriter()
+	if err != nil {
+		return err
+	}
+
+	env, err := environment.GetCurrentEnvironment()
+	if err != nil {
+		return fmt.Errorf("failed to get current environment: %w", err)
+	}
+
+	status := envStatus{Tools: []toolStatus{}}
+	if env != nil {
+		status = computeStatus(env)
+		recordPromptStatus(env.Name, status.Dirty)
+	}
+
+	if out.Structured() {
+		if err := out.Write(status); err != nil {
+			return err
+		}
+	} else {
+		printStatus(status)
+	}
+
+	if statusExitCode && status.Dirty {
+		cmd.SilenceErrors = true
+		cmd.SilenceUsage = true
+		return &exitError{code: 1}
+	}
+	return nil
+}
+
+// computeStatus diffs every enabled tool of env with its snapshot
+func computeStatus(env *environment.Environment) envStatus {
+	status := envStatus{Environment: env.Name, Tools: []toolStatus{}}
+	if !env.LastSnapshot.IsZero() {
+		lastSnapshot := env.LastSnapshot
+		status.LastSnapshot = &lastSnapshot
+	}
+
+	for _, diff := range diffEnvironment(env, "") {
+		tool := toolStatus{Tool: diff.Tool, Changes: len(diff.Changes), Error: diff.Error}
+		tool.Dirty = diff.Error == "" && len(diff.Changes) > 0
+		if tool.Dirty {
+			status.Dirty = true
+		}
+		status.Tools = append(status.Tools, tool)
+	}
+	return status
+}
+
+// printStatus displays a status in a human readable form
+func printStatus(status envStatus) {
+	if status.Environment == "" {
+		fmt.Println("No active environment.")
+		return
+	}
+
+	fmt.Printf("Active environment: %s\n", status.Environment)
+	if status.LastSnapshot != nil {
+		fmt.Printf("Last snapshot:      %s\n", formatTimeAgo(*status.LastSnapshot))
+	} else {
+		fmt.Println("Last snapshot:      never")
+	}
+	fmt.Println()
+
+	if len(status.Tools) == 0 {
+		fmt.Println("No enabled tools.")
+		return
+	}
+
+	dirty := 0
+	for _, tool := range status.Tools {
+		switch {
+		case tool.Error != "":
+			fmt.Printf("  ⚠️  %s: %s\n", tool.Tool, tool.Error)
+		case tool.Dirty:
+			fmt.Printf("  ✗ %s: %d change(s)\n", tool.Tool, tool.Changes)
+			dirty++
+		default:
+			fmt.Printf("  ✓ %s: clean\n", tool.Tool)
+		}
+	}
+
+	fmt.Println()
+	if dirty == 0 {
+		fmt.Println("The live config matches the snapshot.")
+		return
+	}
+	fmt.Printf("%d tool(s) changed since the last snapshot. Run 'envswitch save' to keep the changes,\n", dirty)
+	fmt.Printf("or 'envswitch diff' to see them.\n")
+}
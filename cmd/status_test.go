@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+func TestRunStatus(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+
+	envsDir := filepath.Join(tempHome, ".envswitch", "environments")
+	require.NoError(t, os.MkdirAll(envsDir, 0755))
+
+	t.Run("without an active environment", func(t *testing.T) {
+		out := captureStdout(t, func() {
+			require.NoError(t, runStatus(statusCmd, nil))
+		})
+		assert.Contains(t, out, "No active environment.")
+	})
+
+	configPath := filepath.Join(tempHome, ".testrc")
+	require.NoError(t, os.WriteFile(configPath, []byte("snapshot"), 0644))
+	installTestPlugin(t, tempHome, "testtool", configPath)
+
+	env := createEnvWithVars(t, envsDir, "work", nil)
+	env.Tools["testtool"] = environment.ToolConfig{Enabled: true}
+	snapshotDir := filepath.Join(env.Path, "snapshots", "testtool")
+	require.NoError(t, os.MkdirAll(snapshotDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(snapshotDir, ".testrc"), []byte("snapshot"), 0644))
+	require.NoError(t, env.Save())
+	require.NoError(t, environment.SetCurrentEnvironment("work"))
+
+	statusExitCode = true
+	defer func() { statusExitCode = false }()
+
+	t.Run("clean", func(t *testing.T) {
+		out := captureStdout(t, func() {
+			require.NoError(t, runStatus(statusCmd, nil))
+		})
+		assert.Contains(t, out, "Active environment: work")
+		assert.Contains(t, out, "Last snapshot:      never")
+		assert.Contains(t, out, "✓ testtool: clean")
+	})
+
+	require.NoError(t, os.WriteFile(configPath, []byte("live"), 0644))
+
+	t.Run("dirty", func(t *testing.T) {
+		var err error
+		out := captureStdout(t, func() {
+			err = runStatus(statusCmd, nil)
+		})
+		code, silent := ExitCode(err)
+		assert.True(t, silent)
+		assert.Equal(t, 1, code)
+		assert.Contains(t, out, "✗ testtool: 1 change(s)")
+		assert.Contains(t, out, "envswitch save")
+	})
+
+	t.Run("structured", func(t *testing.T) {
+		setOutputFormat(t, "json")
+		statusExitCode = false
+
+		out := captureStdout(t, func() {
+			require.NoError(t, runStatus(statusCmd, nil))
+		})
+
+		var status envStatus
+		require.NoError(t, json.Unmarshal([]byte(out), &status), out)
+		assert.Equal(t, "work", status.Environment)
+		assert.True(t, status.Dirty)
+		require.Len(t, status.Tools, 1)
+		assert.Equal(t, toolStatus{Tool: "testtool", Dirty: true, Changes: 1}, status.Tools[0])
+	})
+}
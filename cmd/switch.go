@@ -2,10 +2,17 @@ package cmd
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -14,7 +21,11 @@ import (
 	"github.com/hugofrely/envswitch/internal/config"
 	"github.com/hugofrely/envswitch/internal/history"
 	"github.com/hugofrely/envswitch/internal/hooks"
+	"github.com/hugofrely/envswitch/internal/keyring"
 	"github.com/hugofrely/envswitch/internal/logger"
+	"github.com/hugofrely/envswitch/internal/output"
+	"github.com/hugofrely/envswitch/internal/platform"
+	"github.com/hugofrely/envswitch/internal/storage"
 	"github.com/hugofrely/envswitch/pkg/environment"
 	"github.com/hugofrely/envswitch/pkg/plugin"
 	"github.com/hugofrely/envswitch/pkg/spinner"
@@ -25,19 +36,75 @@ const (
 	debugLogLevel = "debug"
 )
 
+// Subsystem loggers, their levels can be set with log_levels in config.yaml
 var (
-	switchVerify   bool
-	switchDryRun   bool
-	switchNoBackup bool
-	switchNoHooks  bool
+	switchLog   = logger.Named("switch")
+	backupLog   = logger.Named("backup")
+	hooksLog    = logger.Named("hooks")
+	snapshotLog = logger.Named("tools").Named("snapshot")
+	restoreLog  = logger.Named("tools").Named("restore")
+	registryLog = logger.Named("tools").Named("registry")
+	pluginsLog  = logger.Named("plugins")
+	envVarsLog  = logger.Named("envvars")
+)
+
+var (
+	switchVerify      bool
+	switchDryRun      bool
+	switchNoBackup    bool
+	switchNoHooks     bool
+	switchNoSave      bool
+	switchPrintEnv    bool
+	switchStrict      bool
+	switchShowTimings bool
+	switchYes         bool
+	switchInteractive bool
+	switchOnly        []string
+	switchSkip        []string
 )
 
 var switchCmd = &cobra.Command{
-	Use:   "switch <name>",
+	Use:   "switch [name]",
 	Short: "Switch to another environment",
 	Long: `Switch to another environment by saving the current state
-and restoring the target environment's snapshot.`,
-	Args:              cobra.ExactArgs(1),
+and restoring the target environment's snapshot.
+
+A program cannot change the variables of the shell that started it. With
+--print-env, progress goes to stderr and the commands exporting the target's
+variables are printed on stdout for the shell to evaluate. The shell
+integration ('envswitch shell init') does this for you.
+
+The live state is saved into the environment being left according to
+auto_save_before_switch: always with 'true' (the default), never with
+'false', after asking with 'prompt'. --no-save never saves it.
+
+--only and --skip restrict both the save and the restore to some tools. The
+tools left out keep their current configuration, and are not saved into the
+target environment until you switch to it again without a filter.
+
+Switching to a protected environment (see 'envswitch protect') asks you to
+type its name first, unless --yes is given.
+
+--interactive shows a table of what the switch does before applying it: the
+backup created, the tools saved and restored, the variables set and the hooks
+run, and asks for confirmation. Use it for environments with destructive
+hooks.
+
+Without a name, the environments are listed to pick from, narrowed down as
+you type.
+
+Examples:
+  envswitch switch
+  envswitch switch work
+  envswitch switch work --only kubectl,gcloud
+  envswitch switch work --skip docker
+  envswitch switch work --no-save
+  envswitch switch work --timings
+  envswitch switch prod --yes
+  envswitch switch prod --interactive
+  eval "$(envswitch switch work --print-env)"
+  envswitch switch work --output json`,
+	Args:              cobra.MaximumNArgs(1),
 	ValidArgsFunction: completeEnvironmentNames,
 	RunE:              runSwitch,
 }
@@ -48,10 +115,119 @@ func init() {
 	switchCmd.Flags().BoolVar(&switchDryRun, "dry-run", false, "Preview changes without applying")
 	switchCmd.Flags().BoolVar(&switchNoBackup, "no-backup", false, "Skip creating backup archive")
 	switchCmd.Flags().BoolVar(&switchNoHooks, "no-hooks", false, "Skip executing pre/post hooks")
+	switchCmd.Flags().BoolVar(&switchNoSave, "no-save", false, "Do not save the live state into the environment being left")
+	switchCmd.Flags().BoolVar(&switchStrict, "strict", false, "Abort when snapshots do not match their checksums or restore a kubectl context that is not allowed")
+	switchCmd.Flags().BoolVar(&switchPrintEnv, "print-env", false, "Print shell exports for the target's variables on stdout")
+	switchCmd.Flags().BoolVar(&switchShowTimings, "timings", false, "Show how long each phase and tool of the switch took")
+	switchCmd.Flags().BoolVarP(&switchYes, "yes", "y", false, "Switch to a protected environment without confirmation")
+	switchCmd.Flags().BoolVarP(&switchInteractive, "interactive", "i", false, "Show a summary of the switch and ask for confirmation before applying it")
+	switchCmd.Flags().StringSliceVar(&switchOnly, "only", nil, "Only save and restore these tools (comma-separated)")
+	switchCmd.Flags().StringSliceVar(&switchSkip, "skip", nil, "Do not save or restore these tools (comma-separated)")
+	_ = switchCmd.RegisterFlagCompletionFunc("only", completeToolFlag)
+	_ = switchCmd.RegisterFlagCompletionFunc("skip", completeToolFlag)
+}
+
+// switchResult is the structured outcome of a switch, for --output
+type switchResult struct {
+	From       string `json:"from,omitempty" yaml:"from,omitempty"`
+	To         string `json:"to" yaml:"to"`
+	Success    bool   `json:"success" yaml:"success"`
+	DryRun     bool   `json:"dry_run,omitempty" yaml:"dry_run,omitempty"`
+	ToolsCount int    `json:"tools_count" yaml:"tools_count"`
+	DurationMs int64  `json:"duration_ms" yaml:"duration_ms"`
+	BackupPath string `json:"backup_path,omitempty" yaml:"backup_path,omitempty"`
+	Error      string `json:"error,omitempty" yaml:"error,omitempty"`
+
+	Timings []history.PhaseTiming `json:"timings,omitempty" yaml:"timings,omitempty"`
 }
 
 func runSwitch(cmd *cobra.Command, args []string) error {
-	targetName := args[0]
+	out, err := resultWriter()
+	if err != nil {
+		return err
+	}
+
+	var targetName string
+	if len(args) == 1 {
+		targetName = args[0]
+	} else if targetName, err = pickEnvironment("Switch to:"); err != nil {
+		return err
+	}
+	if out.Structured() {
+		if switchPrintEnv {
+			return fmt.Errorf("--print-env cannot be combined with --output %s", out.Format())
+		}
+		return switchWithResult(out, targetName)
+	}
+
+	if !switchPrintEnv {
+		return switchEnvironment(targetName)
+	}
+
+	// Variables of the environment being left are unset by the exports
+	var previous []string
+	if current, err := environment.GetCurrentEnvironment(); err == nil && current != nil {
+		if envVars, err := current.ListEnvVarsMasked(""); err == nil {
+			for _, envVar := range envVars {
+				previous = append(previous, envVar.Key)
+			}
+		}
+	}
+
+	// Keep stdout for the exports, everything else goes to stderr
+	stdout := os.Stdout
+	os.Stdout = os.Stderr
+	err = switchEnvironment(targetName)
+	os.Stdout = stdout
+	if err != nil || switchDryRun {
+		return err
+	}
+
+	target, err := environment.LoadEnvironment(targetName)
+	if err != nil {
+		return fmt.Errorf("failed to load environment '%s': %w", targetName, err)
+	}
+	return writeShellExports(os.Stdout, detectShell(), target, previous)
+}
+
+// switchWithResult switches to targetName with progress on stderr, and
+// writes the outcome on stdout
+func switchWithResult(out *output.Writer, targetName string) error {
+	result := switchResult{To: targetName, DryRun: switchDryRun}
+	if current, err := environment.GetCurrentEnvironment(); err == nil && current != nil {
+		result.From = current.Name
+	}
+	startTime := time.Now()
+
+	stdout := os.Stdout
+	os.Stdout = os.Stderr
+	switchErr := switchEnvironment(targetName)
+	os.Stdout = stdout
+
+	result.Success = switchErr == nil
+	if switchErr != nil {
+		result.Error = switchErr.Error()
+	}
+
+	// Switches that got far enough are recorded with their details
+	if entries, _, err := history.LoadRecent(1); err == nil && len(entries) == 1 {
+		entry := entries[0]
+		if entry.To == targetName && !entry.Timestamp.Before(startTime) {
+			result.ToolsCount = entry.ToolsCount
+			result.DurationMs = entry.DurationMs
+			result.BackupPath = entry.BackupPath
+			result.Timings = entry.Timings
+		}
+	}
+
+	if err := out.Write(result); err != nil {
+		return err
+	}
+	return switchErr
+}
+
+// switchEnvironment switches to the environment named targetName
+func switchEnvironment(targetName string) error {
 
 	// Load configuration
 	cfg, err := config.LoadConfig()
@@ -60,11 +236,13 @@ func runSwitch(cmd *cobra.Command, args []string) error {
 		cfg = config.DefaultConfig()
 	}
 
-	// Override log level if verbose or debug flags are set
+	// Override log level if verbose or debug flags are set, for every subsystem
 	if debug {
 		cfg.LogLevel = debugLogLevel
+		cfg.LogLevels = nil
 	} else if verbose {
 		cfg.LogLevel = debugLogLevel
+		cfg.LogLevels = nil
 	}
 
 	// Initialize logger and output
@@ -72,9 +250,11 @@ func runSwitch(cmd *cobra.Command, args []string) error {
 		logger.Warn("Failed to initialize logger: %v", logErr)
 	}
 	defer logger.Close()
+	defer waitForMirrors()
 
 	// Load target environment
-	if _, loadErr := environment.LoadEnvironment(targetName); loadErr != nil {
+	target, loadErr := environment.LoadEnvironment(targetName)
+	if loadErr != nil {
 		return fmt.Errorf("failed to load environment '%s': %w", targetName, loadErr)
 	}
 
@@ -91,23 +271,82 @@ func runSwitch(cmd *cobra.Command, args []string) error {
 
 	fromName := getFromName(currentEnv)
 
+	filter := toolFilter{Only: switchOnly, Skip: switchSkip}
+	if err := filter.validate(getToolRegistry()); err != nil {
+		return err
+	}
+
 	if switchDryRun {
-		return handleDryRun(fromName, targetName)
+		return handleDryRun(currentEnv, targetName, cfg, filter)
+	}
+
+	if switchInteractive {
+		confirmed, err := confirmSwitch(currentEnv, target, cfg, filter)
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			return fmt.Errorf("switch to '%s' canceled", targetName)
+		}
 	}
 
-	// Check auto-save configuration
-	if currentEnv != nil && cfg.AutoSaveBeforeSwitch == "prompt" {
+	if target.Protected && !switchYes && !confirmProtected(target, "switch to it") {
+		return fmt.Errorf("switch to protected environment '%s' canceled (use --yes to skip the confirmation)", targetName)
+	}
+
+	save := shouldSaveBeforeSwitch(currentEnv, cfg, os.Stdin)
+
+	// Ctrl-C aborts the switch, putting back the tools already restored
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return performSwitch(ctx, currentEnv, targetName, fromName, cfg, filter, save)
+}
+
+// Values of auto_save_before_switch
+const (
+	autoSaveAlways = "true"
+	autoSaveNever  = "false"
+	autoSavePrompt = "prompt"
+)
+
+// autoSaveMode returns whether the environment being left is saved, with
+// --no-save taking precedence over auto_save_before_switch
+func autoSaveMode(cfg *config.Config) string {
+	if switchNoSave {
+		return autoSaveNever
+	}
+	switch cfg.AutoSaveBeforeSwitch {
+	case autoSaveAlways, autoSavePrompt:
+		return cfg.AutoSaveBeforeSwitch
+	default:
+		return autoSaveNever
+	}
+}
+
+// shouldSaveBeforeSwitch reports whether the live state is saved into
+// currentEnv before switching, reading the answer from in when
+// auto_save_before_switch is 'prompt'
+func shouldSaveBeforeSwitch(currentEnv *environment.Environment, cfg *config.Config, in io.Reader) bool {
+	if currentEnv == nil {
+		return false
+	}
+
+	switch autoSaveMode(cfg) {
+	case autoSaveAlways:
+		return true
+	case autoSavePrompt:
 		fmt.Printf("\n💾 Save current environment '%s' before switching? (y/N): ", currentEnv.Name)
-		reader := bufio.NewReader(os.Stdin)
-		response, _ := reader.ReadString('\n')
+		response, _ := bufio.NewReader(in).ReadString('\n')
 		response = strings.ToLower(strings.TrimSpace(response))
-
-		if response != "y" && response != "yes" {
-			logger.Info("Skipping auto-save as per user choice")
+		if response == "y" || response == "yes" {
+			return true
 		}
+		snapshotLog.Info("Not saving '%s' as per user choice", currentEnv.Name)
+		return false
+	default:
+		snapshotLog.Debug("Not saving '%s' (auto-save disabled)", currentEnv.Name)
+		return false
 	}
-
-	return performSwitch(currentEnv, targetName, fromName, cfg)
 }
 
 func getFromName(currentEnv *environment.Environment) string {
@@ -117,22 +356,47 @@ func getFromName(currentEnv *environment.Environment) string {
 	return "(none)"
 }
 
-func handleDryRun(fromName, targetName string) error {
-	fmt.Printf("Preview of changes (DRY RUN):\n\n")
-	fmt.Printf("Would switch: %s → %s\n", fromName, targetName)
-	fmt.Println()
-	fmt.Println("No changes will be applied (use without --dry-run to apply)")
-	return nil
-}
-
-func performSwitch(currentEnv *environment.Environment, targetName, fromName string, cfg *config.Config) error {
+// performSwitch switches from currentEnv to targetName. Once ctx is done the
+// switch stops: before the restore nothing has changed, during the restore
+// the tools already restored are rolled back.
+func performSwitch(ctx context.Context, currentEnv *environment.Environment, targetName, fromName string, cfg *config.Config, filter toolFilter, save bool) (err error) {
 	startTime := time.Now()
 
+	logPath, endLog := startSwitchLog(cfg, startTime)
+	defer func() {
+		if err != nil {
+			switchLog.Error("Switch from '%s' to '%s' failed: %v", fromName, targetName, err)
+		} else {
+			switchLog.Info("Switched from '%s' to '%s' in %s", fromName, targetName, time.Since(startTime).Round(time.Millisecond))
+		}
+		endLog()
+	}()
+	if filter.isEmpty() {
+		switchLog.Debug("Switching from '%s' to '%s'", fromName, targetName)
+	} else {
+		switchLog.Debug("Switching from '%s' to '%s' (%s)", fromName, targetName, filter)
+	}
+
 	targetEnv, err := environment.LoadEnvironment(targetName)
 	if err != nil {
 		return err
 	}
 
+	// Tools left out of the switch that made currentEnv active still hold
+	// another environment's config, they are not saved into currentEnv
+	registry := filter.apply(getToolRegistry())
+	snapshotRegistry := liveToolFilter(currentEnv).apply(registry)
+
+	if err := checkSnapshotIntegrity(targetEnv, registry); err != nil {
+		return err
+	}
+	if err := checkSnapshotKubeContext(targetEnv, registry); err != nil {
+		return err
+	}
+
+	endSwitch := beginSwitch()
+	defer endSwitch()
+
 	// Create and start spinner
 	s := spinner.New(fmt.Sprintf("Switching from '%s' to '%s'", fromName, targetName))
 	s.Start()
@@ -142,43 +406,93 @@ func performSwitch(currentEnv *environment.Environment, targetName, fromName str
 		From:      fromName,
 		To:        targetName,
 		Success:   false,
+		OnlyTools: filter.Only,
+		SkipTools: filter.Skip,
+		LogFile:   logPath,
 	}
+	timings := &switchTimings{entry: &historyEntry}
+	runHooks := !switchNoHooks
 
 	s.Update("Creating backup...")
+	backupDone := timings.start(phaseBackup)
 	backupPath, err := createBackup(currentEnv, &historyEntry, cfg)
 	if err != nil {
 		s.Error(fmt.Sprintf("Failed to create backup: %v", err))
 		return err
 	}
+	if backupPath != "" {
+		backupDone()
+	}
+	if err := checkInterrupted(ctx, &historyEntry, startTime); err != nil {
+		s.Error(err.Error())
+		return err
+	}
 
-	s.Update("Saving current state...")
-	if saveErr := saveCurrentState(currentEnv); saveErr != nil {
-		s.Error(fmt.Sprintf("Failed to save current state: %v", saveErr))
-		return saveErr
+	if save {
+		s.Update("Saving current state...")
+		saveDone := timings.start(phaseSave)
+		saveCtx := withCopyProgress(ctx, switchCopyProgress(s, phaseSave, "Saving"))
+		if saveErr := saveCurrentState(saveCtx, currentEnv, snapshotRegistry, timings.toolProgress(phaseSave, spinnerProgress(s, "Saving"))); saveErr != nil {
+			if err := checkInterrupted(ctx, &historyEntry, startTime); err != nil {
+				s.Error(err.Error())
+				return err
+			}
+			s.Error(fmt.Sprintf("Failed to save current state: %v", saveErr))
+			return saveErr
+		}
+		saveDone()
 	}
 
 	s.Update("Running pre-switch hooks...")
-	if hookErr := executePreSwitchHooks(targetEnv, targetName, &historyEntry, startTime); hookErr != nil {
+	preHooksDone := timings.start(phasePreHooks)
+	if hookErr := executePreSwitchHooks(targetEnv, targetName, cfg, &historyEntry, startTime); hookErr != nil {
 		s.Error(fmt.Sprintf("Pre-switch hook failed: %v", hookErr))
 		return hookErr
 	}
+	if runHooks && len(preSwitchHooks(targetEnv, cfg)) > 0 {
+		preHooksDone()
+	}
+	if err := checkInterrupted(ctx, &historyEntry, startTime); err != nil {
+		s.Error(err.Error())
+		return err
+	}
 
 	s.Update("Restoring environment...")
-	toolCount, err := restoreTargetState(targetEnv, &historyEntry, startTime)
+	restoreDone := timings.start(phaseRestore)
+	restoreCtx := withCopyProgress(ctx, switchCopyProgress(s, phaseRestore, "Restoring"))
+	toolCount, err := restoreTargetState(restoreCtx, targetEnv, currentEnv, registry, &historyEntry, startTime, timings.toolProgress(phaseRestore, spinnerProgress(s, "Restoring")))
 	if err != nil {
 		s.Error(fmt.Sprintf("Failed to restore environment: %v", err))
 		return err
 	}
+	restoreDone()
 	historyEntry.ToolsCount = toolCount
 
 	s.Update("Running post-switch hooks...")
-	executePostSwitchHooks(targetEnv, targetName)
+	postHooksDone := timings.start(phasePostHooks)
+	executePostSwitchHooks(targetEnv, targetName, cfg)
+	if runHooks && len(postSwitchHooks(targetEnv, cfg)) > 0 {
+		postHooksDone()
+	}
 
 	if err := finalizeSwitch(targetEnv, targetName, &historyEntry, startTime, backupPath, s); err != nil {
 		s.Error(fmt.Sprintf("Failed to finalize switch: %v", err))
 		return err
 	}
 
+	if err := checkLiveKubeContext(targetEnv, registry); err != nil {
+		return err
+	}
+
+	if switchShowTimings {
+		fmt.Println()
+		fmt.Println("⏱️  Timings:")
+		if err := printTimings(os.Stdout, historyEntry.Timings, historyEntry.DurationMs); err != nil {
+			return err
+		}
+	}
+
+	autoSync(cfg)
 	return nil
 }
 
@@ -190,46 +504,84 @@ func createBackup(currentEnv *environment.Environment, entry *history.SwitchEntr
 	// Check if backup is disabled via flag or config
 	if switchNoBackup || !cfg.BackupBeforeSwitch {
 		if switchNoBackup {
-			logger.Debug("Backup skipped via --no-backup flag")
+			backupLog.Debug("Backup skipped via --no-backup flag")
 		} else {
-			logger.Debug("Backup disabled in configuration")
+			backupLog.Debug("Backup disabled in configuration")
 		}
 		return "", nil
 	}
 
-	logger.Debug("Creating security backup...")
-	backup, backupErr := archive.ArchiveEnvironment(currentEnv)
+	backupLog.Debug("Creating security backup...")
+	backup, backupErr := archive.BackupEnvironment(currentEnv)
 	if backupErr != nil {
-		logger.Warn("Failed to create backup: %v", backupErr)
-		logger.Debug("Proceeding with switch...")
+		// Without room for the backup, the snapshot would not fit either
+		var spaceErr *storage.InsufficientSpaceError
+		if errors.As(backupErr, &spaceErr) {
+			return "", diskSpaceError(backupErr, "switch")
+		}
+		backupLog.Warn("Failed to create backup: %v", backupErr)
+		backupLog.Debug("Proceeding with switch...")
 		return "", nil
 	}
 
 	entry.BackupPath = backup.Path
-	logger.Debug("Backup created: %s", filepath.Base(backup.Path))
+	backupLog.Debug("Backup created: %s", filepath.Base(backup.Path))
+	mirrorBackup(backup.Path, cfg)
 	return backup.Path, nil
 }
 
-func saveCurrentState(currentEnv *environment.Environment) error {
+// beginSwitch marks a restore of the live config in progress, which keeps
+// 'envswitch daemon' from snapshotting the half restored state. The returned
+// function ends it.
+func beginSwitch() func() {
+	endSwitch, err := environment.BeginSwitch()
+	if err != nil {
+		logger.Debug("Failed to mark the switch in progress: %v", err)
+		return func() {}
+	}
+	return endSwitch
+}
+
+func saveCurrentState(ctx context.Context, currentEnv *environment.Environment, registry map[string]tools.Tool, progress toolProgress) error {
 	if currentEnv == nil {
 		return nil
 	}
 
-	logger.Debug("Saving current state...")
-	if err := snapshotCurrentEnvironment(currentEnv); err != nil {
+	// Wait for 'envswitch daemon' if it is saving the environment
+	unlock, err := currentEnv.Lock()
+	if err != nil {
 		return fmt.Errorf("failed to save current state: %w", err)
 	}
-	logger.Debug("Current state saved")
+	defer unlock()
+
+	snapshotLog.Debug("Saving current state...")
+	if err := snapshotCurrentEnvironment(ctx, currentEnv, registry, progress); err != nil {
+		return fmt.Errorf("failed to save current state: %w", err)
+	}
+	snapshotLog.Debug("Current state saved")
 	return nil
 }
 
-func executePreSwitchHooks(targetEnv *environment.Environment, targetName string, entry *history.SwitchEntry, startTime time.Time) error {
-	if switchNoHooks || len(targetEnv.Hooks.PreSwitch) == 0 {
+// preSwitchHooks returns the global pre-switch hooks of config.yaml followed
+// by those of the target environment
+func preSwitchHooks(targetEnv *environment.Environment, cfg *config.Config) []environment.Hook {
+	return append(append([]environment.Hook{}, cfg.Hooks.PreSwitch...), targetEnv.Hooks.PreSwitch...)
+}
+
+// postSwitchHooks returns the post-switch hooks of the target environment
+// followed by the global ones of config.yaml
+func postSwitchHooks(targetEnv *environment.Environment, cfg *config.Config) []environment.Hook {
+	return append(append([]environment.Hook{}, targetEnv.Hooks.PostSwitch...), cfg.Hooks.PostSwitch...)
+}
+
+func executePreSwitchHooks(targetEnv *environment.Environment, targetName string, cfg *config.Config, entry *history.SwitchEntry, startTime time.Time) error {
+	preSwitch := preSwitchHooks(targetEnv, cfg)
+	if switchNoHooks || len(preSwitch) == 0 {
 		return nil
 	}
 
-	logger.Debug("Running pre-switch hooks...")
-	if err := hooks.ExecuteHooks(targetEnv.Hooks.PreSwitch, targetName); err != nil {
+	hooksLog.Debug("Running pre-switch hooks...")
+	if err := hooks.ExecuteHooks(preSwitch, targetName); err != nil {
 		entry.ErrorMsg = fmt.Sprintf("pre-switch hook failed: %v", err)
 		entry.DurationMs = time.Since(startTime).Milliseconds()
 		recordHistory(entry)
@@ -238,27 +590,111 @@ func executePreSwitchHooks(targetEnv *environment.Environment, targetName string
 	return nil
 }
 
-func restoreTargetState(targetEnv *environment.Environment, entry *history.SwitchEntry, startTime time.Time) (int, error) {
-	logger.Debug("Restoring target environment state...")
-	toolCount, err := restoreEnvironment(targetEnv)
+// switchCopyProgress reports the copies of a phase of the switch as JSON
+// events on stderr with --output json, as a progress bar on s otherwise
+func switchCopyProgress(s *spinner.Spinner, phase, action string) copyProgress {
+	if format, _ := output.ParseFormat(outputFlag); format == output.FormatJSON {
+		return eventCopyProgress(os.Stderr, phase)
+	}
+	return spinnerCopyProgress(s, action)
+}
+
+// checkInterrupted returns an error when ctx is done, recording the switch
+// as interrupted in history. Nothing has been restored yet.
+func checkInterrupted(ctx context.Context, entry *history.SwitchEntry, startTime time.Time) error {
+	if ctx.Err() == nil {
+		return nil
+	}
+	err := fmt.Errorf("switch interrupted, nothing was restored")
+	entry.ErrorMsg = err.Error()
+	entry.DurationMs = time.Since(startTime).Milliseconds()
+	recordHistory(entry)
+	return err
+}
+
+func restoreTargetState(ctx context.Context, targetEnv, currentEnv *environment.Environment, registry map[string]tools.Tool, entry *history.SwitchEntry, startTime time.Time, progress toolProgress) (int, error) {
+	restoreLog.Debug("Restoring target environment state...")
+
+	// What the restore replaces is kept until all tools are restored
+	held := storage.HoldRestoreBackups()
+	defer func() {
+		if err := held.Release(); err != nil {
+			restoreLog.Warn("Failed to remove restore backups: %v", err)
+		}
+	}()
+
+	toolCount, err := restoreEnvironment(ctx, targetEnv, registry, progress)
+	var interrupted *restoreInterruptedError
+	if errors.As(err, &interrupted) {
+		err = rollbackInterruptedRestore(held, currentEnv, registry, interrupted.Tools)
+		entry.ErrorMsg = err.Error()
+		entry.DurationMs = time.Since(startTime).Milliseconds()
+		recordHistory(entry)
+		return 0, err
+	}
 	if err != nil {
 		entry.ErrorMsg = fmt.Sprintf("restore failed: %v", err)
 		entry.DurationMs = time.Since(startTime).Milliseconds()
 		recordHistory(entry)
 		return 0, fmt.Errorf("failed to restore target state: %w", err)
 	}
-	logger.Debug("Restored %d tool(s)", toolCount)
+	restoreLog.Debug("Restored %d tool(s)", toolCount)
 	return toolCount, nil
 }
 
-func executePostSwitchHooks(targetEnv *environment.Environment, targetName string) {
-	if switchNoHooks || len(targetEnv.Hooks.PostSwitch) == 0 {
+// rollbackInterruptedRestore puts the live config of the tools restored
+// before a switch was interrupted back as it was, from the copies held of
+// what their restore replaced. currentEnv, the environment being left, may
+// be nil. It returns the error reporting the interrupted switch.
+func rollbackInterruptedRestore(held *storage.HeldBackups, currentEnv *environment.Environment, registry map[string]tools.Tool, toolNames []string) error {
+	if len(toolNames) == 0 {
+		return fmt.Errorf("switch interrupted, nothing was restored")
+	}
+
+	restoreLog.Info("Switch interrupted, rolling back %s", strings.Join(toolNames, ", "))
+	var rolledBack, kept []string
+	failures := make(map[string]error)
+	for _, toolName := range toolNames {
+		// Tools restoring through commands leave no copy behind
+		provider, ok := registry[toolName].(tools.PathProvider)
+		if !ok || len(provider.ConfigPaths()) == 0 {
+			kept = append(kept, toolName)
+			continue
+		}
+		if err := held.Rollback(provider.ConfigPaths()...); err != nil {
+			failures[toolName] = err
+			continue
+		}
+		rolledBack = append(rolledBack, toolName)
+	}
+
+	var outcomes []string
+	if len(rolledBack) > 0 {
+		outcome := fmt.Sprintf("rolled back %d tool(s)", len(rolledBack))
+		if currentEnv != nil {
+			outcome += fmt.Sprintf(" to '%s'", currentEnv.Name)
+		}
+		outcomes = append(outcomes, outcome)
+	}
+	if len(failures) > 0 {
+		restoreLog.Warn("Failed to roll back %d tool(s): %s", len(failures), formatToolFailures(failures))
+		outcomes = append(outcomes, fmt.Sprintf("could not roll back %s", formatToolFailures(failures)))
+	}
+	if len(kept) > 0 {
+		outcomes = append(outcomes, fmt.Sprintf("%s already restored, they cannot be rolled back", strings.Join(kept, ", ")))
+	}
+	return fmt.Errorf("switch interrupted, %s", strings.Join(outcomes, "; "))
+}
+
+func executePostSwitchHooks(targetEnv *environment.Environment, targetName string, cfg *config.Config) {
+	postSwitch := postSwitchHooks(targetEnv, cfg)
+	if switchNoHooks || len(postSwitch) == 0 {
 		return
 	}
 
-	logger.Debug("Running post-switch hooks...")
-	if err := hooks.ExecuteHooks(targetEnv.Hooks.PostSwitch, targetName); err != nil {
-		logger.Warn("Post-switch hook failed: %v", err)
+	hooksLog.Debug("Running post-switch hooks...")
+	if err := hooks.ExecuteHooks(postSwitch, targetName); err != nil {
+		hooksLog.Warn("Post-switch hook failed: %v", err)
 	}
 }
 
@@ -272,6 +708,8 @@ func finalizeSwitch(targetEnv *environment.Environment, targetName string, entry
 	if err := environment.SetCurrentEnvironment(targetName); err != nil {
 		return fmt.Errorf("failed to update current environment: %w", err)
 	}
+	// The live config was just restored from the snapshot
+	recordPromptStatus(targetName, false)
 
 	targetEnv.LastUsed = time.Now()
 	if err := targetEnv.Save(); err != nil {
@@ -286,16 +724,17 @@ func finalizeSwitch(targetEnv *environment.Environment, targetName string, entry
 	s.Success(fmt.Sprintf("Successfully switched to '%s' (%.2fs)", targetName, time.Since(startTime).Seconds()))
 
 	if backupPath != "" {
-		logger.Debug("Backup: %s", filepath.Base(backupPath))
+		backupLog.Debug("Backup: %s", filepath.Base(backupPath))
 	}
 
 	// Cleanup old backups based on retention policy
 	if cfg.BackupRetention > 0 {
 		deleted, err := archive.CleanupOldArchives(cfg.BackupRetention)
 		if err != nil {
-			logger.Warn("Failed to cleanup old archives: %v", err)
+			backupLog.Warn("Failed to cleanup old archives: %v", err)
 		} else if deleted > 0 {
-			logger.Debug("Cleaned up %d old archive(s)", deleted)
+			backupLog.Debug("Cleaned up %d old archive(s)", deleted)
+			collectBackupGarbage()
 		}
 	}
 
@@ -309,120 +748,247 @@ func finalizeSwitch(targetEnv *environment.Environment, targetName string, entry
 	return nil
 }
 
-// snapshotCurrentEnvironment creates snapshots of all enabled tools in the current environment
-func snapshotCurrentEnvironment(env *environment.Environment) error {
-	toolRegistry := getToolRegistry()
-	snapshotCount := 0
+// snapshotCurrentEnvironment creates snapshots of the enabled tools of the
+// current environment found in toolRegistry, several tools at a time. Once
+// ctx is done the tools left are not saved.
+func snapshotCurrentEnvironment(ctx context.Context, env *environment.Environment, toolRegistry map[string]tools.Tool, progress toolProgress) error {
 
+	var toolNames []string
 	for toolName, config := range env.Tools {
 		if !config.Enabled {
 			continue
 		}
-
-		tool, exists := toolRegistry[toolName]
-		if !exists {
-			logger.Debug("Unknown tool '%s', skipping", toolName)
+		if _, exists := toolRegistry[toolName]; !exists {
+			snapshotLog.Debug("Unknown tool '%s', skipping", toolName)
 			continue
 		}
+		toolNames = append(toolNames, toolName)
+	}
 
+	if err := checkSnapshotSpace(env, toolRegistry, toolNames); err != nil {
+		return err
+	}
+
+	failures := runToolsParallel(ctx, toolNames, loadToolTimeouts(), func(ctx context.Context, toolName string) error {
 		snapshotPath := filepath.Join(env.Path, "snapshots", toolName)
-		if err := os.MkdirAll(snapshotPath, 0755); err != nil {
-			logger.Warn("Failed to create snapshot directory for %s: %v, skipping", toolName, err)
-			continue
+		if err := os.MkdirAll(snapshotPath, 0700); err != nil {
+			return fmt.Errorf("failed to create snapshot directory: %w", err)
 		}
 
-		logger.Debug("Snapshotting %s...", toolName)
-		if err := tool.Snapshot(snapshotPath); err != nil {
-			logger.Warn("Failed to snapshot %s: %v, skipping", toolName, err)
-			continue
+		snapshotLog.Debug("Snapshotting %s...", toolName)
+		tool := toolRegistry[toolName]
+		if err := reportCopies(ctx, toolName, tool, func() error {
+			return tools.SnapshotContext(ctx, tool, snapshotPath)
+		}); err != nil {
+			return err
+		}
+		// Tools copy files with their live permissions, snapshots hold
+		// credentials so only the user may read them
+		if _, err := storage.HardenPermissions(snapshotPath); err != nil {
+			snapshotLog.Warn("Failed to restrict permissions of the %s snapshot: %v", toolName, err)
 		}
+		if err := env.SeparateHostScoped(toolName); err != nil {
+			snapshotLog.Warn("Failed to store machine-specific snapshot of %s: %v", toolName, err)
+		}
+		return nil
+	}, progress)
 
-		// Update snapshot metadata
-		config.SnapshotPath = snapshotPath
+	if len(failures) > 0 {
+		snapshotLog.Warn("Failed to snapshot %d tool(s), skipping: %s", len(failures), formatToolFailures(failures))
+	}
+
+	// Update snapshot metadata once all workers are done
+	var snapshotted []string
+	keyringFiles := sensitiveFiles()
+	for _, toolName := range toolNames {
+		if _, failed := failures[toolName]; failed {
+			continue
+		}
+		storeSensitiveFiles(env, toolName, keyringFiles)
+		config := env.Tools[toolName]
+		config.SnapshotPath = filepath.Join(env.Path, "snapshots", toolName)
+		config.LastSnapshot = time.Now()
+		config.SnapshotSize = env.ToolSnapshotSize(toolName)
 		env.Tools[toolName] = config
-		snapshotCount++
+		snapshotted = append(snapshotted, toolName)
 	}
+	snapshotCount := len(snapshotted)
 
-	// Capture and save environment variables if configured
-	if len(env.EnvVars) > 0 {
-		logger.Debug("Capturing environment variables...")
-		varNames := make([]string, 0, len(env.EnvVars))
-		for varName := range env.EnvVars {
-			varNames = append(varNames, varName)
+	if err := env.UpdateChecksums(snapshotted...); err != nil {
+		snapshotLog.Warn("Failed to record snapshot checksums: %v", err)
+	}
+
+	// Keep the snapshots taken before the interruption
+	if err := ctx.Err(); err != nil {
+		if snapshotCount > 0 {
+			env.LastSnapshot = time.Now()
+		}
+		if saveErr := env.Save(); saveErr != nil {
+			snapshotLog.Warn("Failed to save environment metadata: %v", saveErr)
 		}
+		return fmt.Errorf("interrupted after saving %d of %d tool(s): %w", snapshotCount, len(toolNames), err)
+	}
 
-		capturedVars, captureErr := environment.CaptureEnvVars(varNames)
+	// Capture and save environment variables if configured
+	if patterns, deny := envVarPatterns(env); len(patterns) > 0 {
+		envVarsLog.Debug("Capturing environment variables...")
+		capturedVars, captureErr := environment.CaptureEnvVars(patterns, deny)
+		if captureErr == nil {
+			capturedVars, captureErr = env.KeepSecretReferences(capturedVars)
+		}
 		if captureErr != nil {
-			logger.Warn("Failed to capture environment variables: %v", captureErr)
+			envVarsLog.Warn("Failed to capture environment variables: %v", captureErr)
 		} else {
 			if saveErr := env.SaveEnvVars(capturedVars); saveErr != nil {
-				logger.Warn("Failed to save environment variables: %v", saveErr)
+				envVarsLog.Warn("Failed to save environment variables: %v", saveErr)
 			} else {
-				logger.Debug("Captured %d environment variable(s)", len(capturedVars))
+				envVarsLog.Debug("Captured %d environment variable(s)", len(capturedVars))
 			}
 		}
 	}
 
+	captureKeychain(env)
+
 	if snapshotCount > 0 {
 		env.LastSnapshot = time.Now()
 	}
 	return env.Save()
 }
 
-// restoreEnvironment restores all enabled tools from the target environment
-func restoreEnvironment(env *environment.Environment) (int, error) {
-	toolRegistry := getToolRegistry()
-	restoredCount := 0
+// restoreInterruptedError is returned by restoreEnvironment when ctx is done
+// before all tools are restored
+type restoreInterruptedError struct {
+	Tools []string // tools whose restore started, sorted
+	Err   error
+}
 
-	for toolName, config := range env.Tools {
-		if !config.Enabled {
-			continue
-		}
+func (e *restoreInterruptedError) Error() string {
+	return fmt.Sprintf("interrupted after restoring %d tool(s): %v", len(e.Tools), e.Err)
+}
 
-		tool, exists := toolRegistry[toolName]
-		if !exists {
-			logger.Debug("Unknown tool '%s', skipping", toolName)
-			continue
-		}
+func (e *restoreInterruptedError) Unwrap() error {
+	return e.Err
+}
 
-		snapshotPath := filepath.Join(env.Path, "snapshots", toolName)
+// restoreEnvironment restores the enabled tools of the target environment
+// found in toolRegistry, several tools at a time. Once ctx is done no more
+// tool is restored and a *restoreInterruptedError is returned.
+func restoreEnvironment(ctx context.Context, env *environment.Environment, toolRegistry map[string]tools.Tool, progress toolProgress) (int, error) {
 
-		// Check if snapshot exists and is valid
-		if _, err := os.Stat(snapshotPath); os.IsNotExist(err) {
-			logger.Warn("No snapshot found for %s, skipping", toolName)
+	var toolNames []string
+	for toolName, config := range env.Tools {
+		if !config.Enabled {
 			continue
 		}
-
-		// Validate snapshot before restoring
-		if err := tool.ValidateSnapshot(snapshotPath); err != nil {
-			logger.Warn("Invalid snapshot for %s: %v, skipping", toolName, err)
+		if _, exists := toolRegistry[toolName]; !exists {
+			restoreLog.Debug("Unknown tool '%s', skipping", toolName)
 			continue
 		}
+		toolNames = append(toolNames, toolName)
+	}
 
-		logger.Debug("Restoring %s...", toolName)
-		if err := tool.Restore(snapshotPath); err != nil {
-			logger.Warn("Failed to restore %s: %v, skipping", toolName, err)
-			continue
-		}
-		restoredCount++
+	cleanup := prepareRestore(env, toolRegistry)
+	defer cleanup()
+
+	var (
+		mu      sync.Mutex
+		started []string
+	)
+	failures := runToolsParallel(ctx, toolNames, loadToolTimeouts(), func(ctx context.Context, toolName string) error {
+		mu.Lock()
+		started = append(started, toolName)
+		mu.Unlock()
+		return restoreTool(ctx, env, toolName, toolRegistry[toolName])
+	}, progress)
+
+	if err := ctx.Err(); err != nil {
+		sort.Strings(started)
+		return 0, &restoreInterruptedError{Tools: started, Err: err}
+	}
+
+	if len(failures) > 0 {
+		restoreLog.Warn("Failed to restore %d tool(s), skipping: %s", len(failures), formatToolFailures(failures))
 	}
+	restoredCount := len(toolNames) - len(failures)
 
 	// Restore environment variables if available
 	envVars, loadErr := env.LoadEnvVars()
 	if loadErr != nil {
-		logger.Warn("Failed to load environment variables: %v", loadErr)
+		envVarsLog.Warn("Failed to load environment variables: %v", loadErr)
 	} else if len(envVars) > 0 {
-		logger.Debug("Restoring environment variables...")
+		envVarsLog.Debug("Restoring environment variables...")
+		// References to secret managers are read now, never stored
+		envVars, resolveErr := environment.ResolveEnvVars(envVars)
+		if resolveErr != nil {
+			envVarsLog.Warn("%v", resolveErr)
+		}
 		if restoreErr := environment.RestoreEnvVars(envVars); restoreErr != nil {
-			logger.Warn("Failed to restore environment variables: %v", restoreErr)
+			envVarsLog.Warn("Failed to restore environment variables: %v", restoreErr)
 		} else {
-			logger.Debug("Restored %d environment variable(s)", len(envVars))
+			envVarsLog.Debug("Restored %d environment variable(s)", len(envVars))
 		}
 	}
 
+	restoreKeychain(env)
+
 	return restoredCount, nil
 }
 
+// prepareRestore sets up the tools of toolRegistry that depend on the
+// environment being restored. The returned function releases what it used.
+func prepareRestore(env *environment.Environment, toolRegistry map[string]tools.Tool) func() {
+	gitTool, ok := toolRegistry["git"].(*tools.GitTool)
+	if !ok {
+		return func() {}
+	}
+	gitTool.Includes = env.Git.Includes
+	if gitTool.Mode != tools.GitModeIncludeIf {
+		return func() {}
+	}
+	identities, cleanup := collectGitIdentities()
+	gitTool.Identities = identities
+	return cleanup
+}
+
+// restoreTool restores a single tool, merging the machine-specific snapshot
+// overlay if there is one. Tools with the merge restore strategy merge the
+// snapshot into the live configuration.
+func restoreTool(ctx context.Context, env *environment.Environment, toolName string, tool tools.Tool) error {
+	snapshotPath, cleanup, err := env.ResolveToolSnapshot(toolName)
+	if err != nil {
+		return fmt.Errorf("failed to prepare snapshot: %w", err)
+	}
+	defer cleanup()
+
+	// Check if snapshot exists and is valid
+	if _, err := os.Stat(snapshotPath); os.IsNotExist(err) {
+		return fmt.Errorf("no snapshot found")
+	}
+
+	// Validate snapshot before restoring
+	if err := tool.ValidateSnapshot(snapshotPath); err != nil {
+		return fmt.Errorf("invalid snapshot: %w", err)
+	}
+
+	switch strategy := env.Tools[toolName].RestoreStrategy; strategy {
+	case environment.RestoreStrategyMerge:
+		merger, ok := tool.(tools.Merger)
+		if !ok {
+			return fmt.Errorf("%s cannot merge snapshots (set its restore strategy to %s)", toolName, environment.RestoreStrategyReplace)
+		}
+		restoreLog.Debug("Merging %s...", toolName)
+		return merger.Merge(ctx, snapshotPath)
+	case "", environment.RestoreStrategyReplace:
+	default:
+		return environment.ValidateRestoreStrategy(strategy)
+	}
+
+	restoreLog.Debug("Restoring %s...", toolName)
+	return reportCopies(ctx, toolName, tool, func() error {
+		return tools.RestoreContext(ctx, tool, snapshotPath)
+	})
+}
+
 // verifyEnvironment performs verification checks on the environment
 func verifyEnvironment(env *environment.Environment) {
 	toolRegistry := getToolRegistry()
@@ -438,105 +1004,219 @@ func verifyEnvironment(env *environment.Environment) {
 		}
 
 		// Check if tool is installed
-		if tool.IsInstalled() {
-			fmt.Printf("   ✓ %s is installed\n", toolName)
-		} else {
+		if !tool.IsInstalled() {
 			fmt.Printf("   ✗ %s is NOT installed\n", toolName)
+			continue
+		}
+		fmt.Printf("   ✓ %s is installed\n", toolName)
+
+		if verifier, ok := tool.(tools.Verifier); ok {
+			if err := verifyToolState(env, toolName, verifier); err != nil {
+				fmt.Printf("   ⚠️  %s does not match its snapshot: %v\n", toolName, err)
+			}
 		}
 	}
 }
 
-// recordHistory saves a switch entry to the history
-func recordHistory(entry *history.SwitchEntry) {
-	hist, err := history.LoadHistory()
+// verifyToolState checks the live state of a tool against its snapshot
+func verifyToolState(env *environment.Environment, toolName string, verifier tools.Verifier) error {
+	snapshotPath, cleanup, err := env.ResolveToolSnapshot(toolName)
 	if err != nil {
-		fmt.Printf("⚠️  Warning: Failed to load history: %v\n", err)
-		return
+		return fmt.Errorf("failed to prepare snapshot: %w", err)
 	}
+	defer cleanup()
+
+	return verifier.Verify(snapshotPath)
+}
 
-	if err := hist.AddEntry(entry); err != nil {
+// recordHistory saves a switch entry to the history
+func recordHistory(entry *history.SwitchEntry) {
+	if err := history.Append(entry); err != nil {
 		fmt.Printf("⚠️  Warning: Failed to save history: %v\n", err)
 	}
 }
 
-// getToolRegistry returns a map of all available tools, filtered by config
-func getToolRegistry() map[string]tools.Tool {
-	allTools := map[string]tools.Tool{
-		"git":     tools.NewGitTool(),
-		"aws":     tools.NewAWSTool(),
-		"gcloud":  tools.NewGCloudTool(),
-		"kubectl": tools.NewKubectlTool(),
-		"docker":  tools.NewDockerTool(),
-	}
+// newToolRegistry returns the built-in tools, configured by config.yaml, and
+// the tools of the installed plugins, without the excluded ones
+func newToolRegistry() *tools.Registry {
+	registry := tools.Builtin()
+
+	// The tools with settings in config.yaml replace their defaults
+	registry.Register(newGitTool())
+	registry.Register(newGCloudTool())
+	registry.Register(newKubectlTool())
+	registry.Register(newSSHTool())
 
 	// Load plugins and add them as generic tools
-	loadPluginsIntoRegistry(allTools)
+	loadPluginsIntoRegistry(registry)
 
 	// Load config to check for excluded tools
 	cfg, err := config.LoadConfig()
-	if err != nil || cfg == nil || len(cfg.ExcludeTools) == 0 {
-		return allTools
+	if err != nil || cfg == nil {
+		return registry
 	}
 
-	// Filter out excluded tools
-	filteredTools := make(map[string]tools.Tool)
-	for name, tool := range allTools {
-		excluded := false
-		for _, excludedTool := range cfg.ExcludeTools {
-			if name == excludedTool {
-				excluded = true
-				logger.Debug("Excluding tool '%s' as per configuration", name)
-				break
+	// Leave the configured paths out of directory snapshots
+	if len(cfg.ExcludePatterns) > 0 {
+		for _, tool := range registry.List() {
+			if excluder, ok := tool.(tools.Excluder); ok {
+				excluder.AddExcludePatterns(cfg.ExcludePatterns...)
 			}
 		}
-		if !excluded {
-			filteredTools[name] = tool
+	}
+
+	// Filter out excluded tools
+	for _, name := range cfg.ExcludeTools {
+		if _, exists := registry.Get(name); exists {
+			registryLog.Debug("Excluding tool '%s' as per configuration", name)
+			registry.Unregister(name)
 		}
 	}
 
-	return filteredTools
+	return registry
+}
+
+// getToolRegistry returns a map of all available tools, filtered by config
+func getToolRegistry() map[string]tools.Tool {
+	return newToolRegistry().Tools()
+}
+
+// envVarPatterns returns the names and globs of the variables captured for
+// env, its own and env_vars_include, and the env_vars_deny globs
+func envVarPatterns(env *environment.Environment) (patterns, deny []string) {
+	for varName := range env.EnvVars {
+		patterns = append(patterns, varName)
+	}
+	sort.Strings(patterns)
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return patterns, nil
+	}
+	return append(patterns, cfg.EnvVarsInclude...), cfg.EnvVarsDeny
+}
+
+// sensitiveFiles returns the snapshot files to store in the OS keyring, as
+// set by keyring_storage and keyring_files
+func sensitiveFiles() []string {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return nil
+	}
+	files := cfg.SensitiveFiles()
+	if len(files) > 0 && !keyring.IsSupported() {
+		snapshotLog.Warn("keyring_storage is on but no keyring is available, sensitive files stay in the snapshots")
+		return nil
+	}
+	return files
+}
+
+// storeSensitiveFiles moves the sensitive files of a fresh snapshot of
+// toolName into the OS keyring
+func storeSensitiveFiles(env *environment.Environment, toolName string, files []string) {
+	if err := env.MoveToKeyring(toolName, files); err != nil {
+		snapshotLog.Warn("Failed to store sensitive files of %s in the keyring: %v", toolName, err)
+	}
+}
+
+// newSSHTool returns the SSH tool configured by ssh_include_private_keys
+func newSSHTool() *tools.SSHTool {
+	sshTool := tools.NewSSHTool()
+	if cfg, err := config.LoadConfig(); err == nil && cfg != nil {
+		sshTool.IncludePrivateKeys = cfg.SSHIncludePrivateKeys
+	}
+	return sshTool
+}
+
+// newGitTool returns the git tool configured by git_check_signing and
+// git_mode
+func newGitTool() *tools.GitTool {
+	gitTool := tools.NewGitTool()
+	if cfg, err := config.LoadConfig(); err == nil && cfg != nil {
+		gitTool.CheckSigning = cfg.GitCheckSigning
+		gitTool.Mode = cfg.GitMode
+	}
+	if dir, err := environment.GetEnvswitchDir(); err == nil {
+		gitTool.IdentityDir = filepath.Join(dir, "git")
+	}
+	return gitTool
+}
+
+// newKubectlTool returns the kubectl tool configured by kubectl_mode and
+// kubectl_contexts
+func newKubectlTool() *tools.KubectlTool {
+	kubectlTool := tools.NewKubectlTool()
+	if cfg, err := config.LoadConfig(); err == nil && cfg != nil {
+		kubectlTool.Mode = cfg.KubectlMode
+		kubectlTool.Contexts = cfg.KubectlContexts
+	}
+	return kubectlTool
+}
+
+// newGCloudTool returns the gcloud tool configured by gcloud_include_caches
+func newGCloudTool() *tools.GCloudTool {
+	gcloudTool := tools.NewGCloudTool()
+	if cfg, err := config.LoadConfig(); err == nil && cfg != nil && cfg.GCloudIncludeCaches {
+		gcloudTool.ExcludePatterns = nil
+	}
+	return gcloudTool
 }
 
 // loadPluginsIntoRegistry charge les plugins installés et les ajoute au registre
-func loadPluginsIntoRegistry(registry map[string]tools.Tool) {
+func loadPluginsIntoRegistry(registry *tools.Registry) {
 	plugins, err := plugin.ListInstalledPlugins()
 	if err != nil {
-		logger.Debug("Failed to load plugins: %v", err)
+		pluginsLog.Debug("Failed to load plugins: %v", err)
 		return
 	}
 
-	home, _ := os.UserHomeDir()
+	home, _ := platform.HomeDir()
 
 	for _, p := range plugins {
 		toolName := p.Metadata.ToolName
+		commands := p.Metadata.Commands
 
-		// Cas 1: Multiple paths (config_paths)
+		// Plugins taking their snapshots with snapshot_cmd may have no config path
+		var base tools.Tool
 		if len(p.Metadata.ConfigPaths) > 0 {
+			// Cas 1: Multiple paths (config_paths)
 			// Expand environment variables in all paths
 			expandedPaths := make([]string, len(p.Metadata.ConfigPaths))
 			for i, path := range p.Metadata.ConfigPaths {
 				expandedPaths[i] = os.ExpandEnv(path)
 			}
-			logger.Debug("Using multiple config paths for '%s': %v", toolName, expandedPaths)
-			registry[toolName] = tools.NewMultiPathTool(toolName, expandedPaths)
-		} else {
+			pluginsLog.Debug("Using multiple config paths for '%s': %v", toolName, expandedPaths)
+			base = tools.NewMultiPathTool(toolName, expandedPaths)
+		} else if p.Metadata.ConfigPath != "" || commands.SnapshotCmd == "" {
 			// Cas 2: Single path (config_path or auto-detected)
 			var configPath string
 			if p.Metadata.ConfigPath != "" {
 				// Utiliser le chemin custom fourni dans plugin.yaml
 				configPath = os.ExpandEnv(p.Metadata.ConfigPath)
-				logger.Debug("Using custom config path for '%s': %s", toolName, configPath)
+				pluginsLog.Debug("Using custom config path for '%s': %s", toolName, configPath)
 			} else {
 				// Auto-détection basée sur le nom de l'outil
 				configPath = getConfigPathForTool(home, toolName)
-				logger.Debug("Using auto-detected config path for '%s': %s", toolName, configPath)
+				pluginsLog.Debug("Using auto-detected config path for '%s': %s", toolName, configPath)
 			}
 
 			// Créer un GenericTool pour ce plugin
-			registry[toolName] = tools.NewGenericTool(toolName, configPath)
+			base = tools.NewGenericTool(toolName, configPath)
+		}
+
+		if commands.IsEmpty() {
+			registry.Register(base)
+		} else {
+			execTool, err := tools.NewExecPluginTool(toolName, p.Dir, commands, base)
+			if err != nil {
+				pluginsLog.Warn("Skipping plugin '%s': %v", p.Metadata.Name, err)
+				continue
+			}
+			pluginsLog.Debug("Using commands of plugin '%s' for '%s'", p.Metadata.Name, toolName)
+			registry.Register(execTool)
 		}
 
-		logger.Debug("Loaded plugin '%s' for tool '%s'", p.Metadata.Name, toolName)
+		pluginsLog.Debug("Loaded plugin '%s' for tool '%s'", p.Metadata.Name, toolName)
 	}
 }
 
@@ -1,7 +1,9 @@
 package cmd
 
 import (
+	"context"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"testing"
 	"time"
@@ -12,6 +14,7 @@ import (
 	"github.com/hugofrely/envswitch/internal/archive"
 	"github.com/hugofrely/envswitch/internal/config"
 	"github.com/hugofrely/envswitch/pkg/environment"
+	"github.com/hugofrely/envswitch/pkg/tools"
 )
 
 func TestSwitchWithVerifyAfterSwitch(t *testing.T) {
@@ -138,12 +141,15 @@ func TestGetToolRegistryFiltering(t *testing.T) {
 		require.NoError(t, cfg.Save())
 
 		tools := getToolRegistry()
-		assert.Len(t, tools, 5) // git, aws, gcloud, kubectl, docker
+		assert.Len(t, tools, 8) // git, aws, gcloud, kubectl, docker, terraform, ssh, npm
 		assert.Contains(t, tools, "git")
 		assert.Contains(t, tools, "aws")
 		assert.Contains(t, tools, "gcloud")
 		assert.Contains(t, tools, "kubectl")
 		assert.Contains(t, tools, "docker")
+		assert.Contains(t, tools, "terraform")
+		assert.Contains(t, tools, "ssh")
+		assert.Contains(t, tools, "npm")
 	})
 
 	t.Run("excludes specified tools", func(t *testing.T) {
@@ -152,7 +158,7 @@ func TestGetToolRegistryFiltering(t *testing.T) {
 		require.NoError(t, cfg.Save())
 
 		tools := getToolRegistry()
-		assert.Len(t, tools, 3)
+		assert.Len(t, tools, 6)
 		assert.Contains(t, tools, "git")
 		assert.Contains(t, tools, "aws")
 		assert.Contains(t, tools, "gcloud")
@@ -162,7 +168,7 @@ func TestGetToolRegistryFiltering(t *testing.T) {
 
 	t.Run("excludes all tools", func(t *testing.T) {
 		cfg := config.DefaultConfig()
-		cfg.ExcludeTools = []string{"git", "aws", "gcloud", "kubectl", "docker"}
+		cfg.ExcludeTools = []string{"git", "aws", "gcloud", "kubectl", "docker", "terraform", "ssh", "npm"}
 		require.NoError(t, cfg.Save())
 
 		tools := getToolRegistry()
@@ -170,6 +176,43 @@ func TestGetToolRegistryFiltering(t *testing.T) {
 	})
 }
 
+func TestNewGitTool(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	assert.True(t, newGitTool().CheckSigning, "signing is checked by default")
+
+	cfg := config.DefaultConfig()
+	cfg.GitCheckSigning = false
+	require.NoError(t, cfg.Save())
+	assert.False(t, newGitTool().CheckSigning)
+}
+
+func TestRestoreEnvironmentGitIncludes(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	tempDir := t.TempDir()
+	t.Setenv("HOME", tempDir)
+
+	env := createTestEnv(t, tempDir, "work")
+	snapshotPath := filepath.Join(env.Path, "snapshots", "git")
+	require.NoError(t, os.MkdirAll(snapshotPath, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(snapshotPath, "gitconfig"), []byte("[user]\n\tname = Work\n"), 0644))
+	env.Tools["git"] = environment.ToolConfig{Enabled: true, SnapshotPath: snapshotPath}
+	env.Git.Includes = []string{"~/.config/git/work.gitconfig"}
+
+	gitConfigPath := filepath.Join(tempDir, ".gitconfig")
+	registry := map[string]tools.Tool{"git": &tools.GitTool{GitConfigPath: gitConfigPath}}
+	restored, err := restoreEnvironment(context.Background(), env, registry, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, restored)
+
+	content, err := os.ReadFile(gitConfigPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "name = Work")
+	assert.Contains(t, string(content), "path = ~/.config/git/work.gitconfig")
+}
+
 func TestConfigLoadingInSwitch(t *testing.T) {
 	tempDir := t.TempDir()
 	oldHome := os.Getenv("HOME")
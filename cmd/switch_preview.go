@@ -0,0 +1,230 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hugofrely/envswitch/internal/config"
+	"github.com/hugofrely/envswitch/internal/hooks"
+	"github.com/hugofrely/envswitch/internal/redact"
+	"github.com/hugofrely/envswitch/pkg/environment"
+	"github.com/hugofrely/envswitch/pkg/tools"
+)
+
+// toolRestorePreview describes what restoring a tool's snapshot would change
+type toolRestorePreview struct {
+	Tool    string
+	Changes []tools.Change // from the live system's point of view
+	Files   int            // files in the snapshot, replacing the live ones
+	Error   string
+}
+
+// handleDryRun prints what switching to targetName would do: the tools saved
+// and restored with their changes, the variables set and the hooks run
+func handleDryRun(currentEnv *environment.Environment, targetName string, cfg *config.Config, filter toolFilter) error {
+	targetEnv, err := environment.LoadEnvironment(targetName)
+	if err != nil {
+		return fmt.Errorf("failed to load environment '%s': %w", targetName, err)
+	}
+
+	fmt.Printf("Preview of changes (DRY RUN):\n\n")
+	fmt.Printf("Would switch: %s → %s\n", getFromName(currentEnv), targetName)
+	if !filter.isEmpty() {
+		fmt.Printf("Tools: %s\n", filter)
+	}
+
+	registry := filter.apply(getToolRegistry())
+
+	if currentEnv != nil {
+		fmt.Println()
+		if !switchNoBackup && cfg.BackupBeforeSwitch {
+			fmt.Printf("Would back up '%s'\n", currentEnv.Name)
+		}
+		saved := enabledRegistryTools(currentEnv, liveToolFilter(currentEnv).apply(registry))
+		switch {
+		case autoSaveMode(cfg) == autoSaveNever:
+			fmt.Printf("Would not save '%s', its unsaved changes are discarded\n", currentEnv.Name)
+		case len(saved) == 0:
+			fmt.Printf("Would save no tools into '%s'\n", currentEnv.Name)
+		case autoSaveMode(cfg) == autoSavePrompt:
+			fmt.Printf("Would ask to save into '%s': %s\n", currentEnv.Name, strings.Join(saved, ", "))
+		default:
+			fmt.Printf("Would save into '%s': %s\n", currentEnv.Name, strings.Join(saved, ", "))
+		}
+	}
+
+	fmt.Println()
+	printRestorePreviews(targetEnv.Name, previewRestore(targetEnv, registry))
+
+	fmt.Println()
+	if err := printEnvVarsPreview(targetEnv); err != nil {
+		return err
+	}
+
+	fmt.Println()
+	hookErr := printHooksPreview(targetEnv, cfg)
+
+	fmt.Println()
+	fmt.Println("No changes will be applied (use without --dry-run to apply)")
+	return hookErr
+}
+
+// enabledRegistryTools returns the sorted enabled tools of env found in registry
+func enabledRegistryTools(env *environment.Environment, registry map[string]tools.Tool) []string {
+	var names []string
+	for name, toolConfig := range env.Tools {
+		if _, exists := registry[name]; toolConfig.Enabled && exists {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// previewRestore diffs each tool restoreEnvironment would restore against
+// the live system
+func previewRestore(env *environment.Environment, registry map[string]tools.Tool) []toolRestorePreview {
+	names := enabledRegistryTools(env, registry)
+	previews := make([]toolRestorePreview, 0, len(names))
+	for _, name := range names {
+		previews = append(previews, previewToolRestore(env, name, registry[name]))
+	}
+	return previews
+}
+
+// previewToolRestore diffs a single tool, including the machine-specific
+// snapshot overlay, and turns the changes around: restoring removes what the
+// snapshot lacks and brings back what it has
+func previewToolRestore(env *environment.Environment, name string, tool tools.Tool) toolRestorePreview {
+	preview := toolRestorePreview{Tool: name}
+
+	snapshotPath, cleanup, err := env.ResolveToolSnapshot(name)
+	if err != nil {
+		preview.Error = err.Error()
+		return preview
+	}
+	defer cleanup()
+
+	if _, err := os.Stat(snapshotPath); os.IsNotExist(err) {
+		preview.Error = "no snapshot found, would be skipped"
+		return preview
+	}
+	if err := tool.ValidateSnapshot(snapshotPath); err != nil {
+		preview.Error = fmt.Sprintf("invalid snapshot, would be skipped: %v", err)
+		return preview
+	}
+
+	changes, err := tool.Diff(snapshotPath)
+	if err != nil {
+		preview.Error = redact.String(err.Error())
+		return preview
+	}
+	for _, change := range changes {
+		preview.Changes = append(preview.Changes, reverseChange(change.Redacted()))
+	}
+
+	_ = filepath.Walk(snapshotPath, func(path string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			preview.Files++
+		}
+		return nil
+	})
+
+	return preview
+}
+
+// reverseChange turns a change from the snapshot's point of view, as Diff
+// reports it, into what restoring the snapshot does to the live system
+func reverseChange(change tools.Change) tools.Change {
+	switch change.Type {
+	case tools.ChangeTypeAdded:
+		return tools.Change{Type: tools.ChangeTypeRemoved, Path: change.Path, OldValue: change.NewValue}
+	case tools.ChangeTypeRemoved:
+		return tools.Change{Type: tools.ChangeTypeAdded, Path: change.Path, NewValue: change.OldValue}
+	default:
+		return tools.Change{Type: change.Type, Path: change.Path, OldValue: change.NewValue, NewValue: change.OldValue}
+	}
+}
+
+// printRestorePreviews displays the per-tool summary of a restore
+func printRestorePreviews(envName string, previews []toolRestorePreview) {
+	if len(previews) == 0 {
+		fmt.Printf("No tools to restore from '%s'\n", envName)
+		return
+	}
+
+	fmt.Printf("Tools restored from '%s':\n", envName)
+	for _, preview := range previews {
+		switch {
+		case preview.Error != "":
+			fmt.Printf("  ⚠️  %s: %s\n", preview.Tool, preview.Error)
+		case len(preview.Changes) == 0:
+			fmt.Printf("  ✓ %s: no changes (%d file(s) replaced)\n", preview.Tool, preview.Files)
+		default:
+			fmt.Printf("  ✗ %s: %d change(s) (%d file(s) replaced)\n", preview.Tool, len(preview.Changes), preview.Files)
+			for _, change := range preview.Changes {
+				fmt.Printf("      %s\n", formatChange(change))
+			}
+		}
+	}
+}
+
+// printEnvVarsPreview lists the variables of env the switch would set,
+// without their values as some are secrets
+func printEnvVarsPreview(env *environment.Environment) error {
+	envVars, err := env.LoadEnvVars()
+	if err != nil {
+		return fmt.Errorf("failed to load environment variables: %w", err)
+	}
+	if len(envVars) == 0 {
+		fmt.Println("No environment variables to set")
+		return nil
+	}
+
+	fmt.Println("Environment variables:")
+	for _, envVar := range envVars {
+		live, set := os.LookupEnv(envVar.Key)
+		switch {
+		case !set:
+			fmt.Printf("  + %s (set)\n", envVar.Key)
+		case live != envVar.Value:
+			fmt.Printf("  ~ %s (changed)\n", envVar.Key)
+		default:
+			fmt.Printf("  ✓ %s (unchanged)\n", envVar.Key)
+		}
+	}
+	return nil
+}
+
+// printHooksPreview shows the hooks the switch would run, failing on the
+// first invalid one
+func printHooksPreview(env *environment.Environment, cfg *config.Config) error {
+	if switchNoHooks {
+		fmt.Println("Hooks: skipped (--no-hooks)")
+		return nil
+	}
+
+	preSwitch := preSwitchHooks(env, cfg)
+	postSwitch := postSwitchHooks(env, cfg)
+	if len(preSwitch) == 0 && len(postSwitch) == 0 {
+		fmt.Println("No hooks to run")
+		return nil
+	}
+
+	if len(preSwitch) > 0 {
+		fmt.Println("Pre-switch hooks:")
+		if err := hooks.DryRunHooks(os.Stdout, preSwitch, env.Name); err != nil {
+			return fmt.Errorf("pre-switch hook would fail: %w", err)
+		}
+	}
+	if len(postSwitch) > 0 {
+		fmt.Println("Post-switch hooks:")
+		if err := hooks.DryRunHooks(os.Stdout, postSwitch, env.Name); err != nil {
+			return fmt.Errorf("post-switch hook would fail: %w", err)
+		}
+	}
+	return nil
+}
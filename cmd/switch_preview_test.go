@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hugofrely/envswitch/internal/config"
+	"github.com/hugofrely/envswitch/pkg/environment"
+	"github.com/hugofrely/envswitch/pkg/tools"
+)
+
+func TestReverseChange(t *testing.T) {
+	assert.Equal(t,
+		tools.Change{Type: tools.ChangeTypeRemoved, Path: "user_name", OldValue: "live"},
+		reverseChange(tools.Change{Type: tools.ChangeTypeAdded, Path: "user_name", NewValue: "live"}))
+	assert.Equal(t,
+		tools.Change{Type: tools.ChangeTypeAdded, Path: "user_name", NewValue: "snapshot"},
+		reverseChange(tools.Change{Type: tools.ChangeTypeRemoved, Path: "user_name", OldValue: "snapshot"}))
+	assert.Equal(t,
+		tools.Change{Type: tools.ChangeTypeModified, Path: "user_name", OldValue: "live", NewValue: "snapshot"},
+		reverseChange(tools.Change{Type: tools.ChangeTypeModified, Path: "user_name", OldValue: "snapshot", NewValue: "live"}))
+}
+
+func TestHandleDryRun(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("PREVIEW_SET", "same")
+	require.NoError(t, os.WriteFile(filepath.Join(home, ".gitconfig"), []byte("[user]\n\tname = Personal\n"), 0600))
+
+	envPath := filepath.Join(home, ".envswitch", "environments", "work")
+	writeSnapshotFile := func(rel, content string) {
+		path := filepath.Join(envPath, rel)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0700))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	}
+	writeSnapshotFile("snapshots/git/gitconfig", "[user]\n\tname = Work\n")
+
+	work := &environment.Environment{
+		Name:      "work",
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		Path:      envPath,
+		Tools: map[string]environment.ToolConfig{
+			"git":     {Enabled: true},
+			"kubectl": {Enabled: true},
+		},
+		EnvVars: make(map[string]string),
+		Hooks: environment.Hooks{
+			PostSwitch: []environment.Hook{{Command: "echo switched", Description: "Say hello"}},
+		},
+	}
+	require.NoError(t, work.Save())
+	require.NoError(t, work.SetEnvVar("PREVIEW_NEW", "secret-value"))
+	require.NoError(t, work.SetEnvVar("PREVIEW_SET", "same"))
+
+	cfg := config.DefaultConfig()
+
+	t.Run("shows tool changes, variables and hooks", func(t *testing.T) {
+		var err error
+		out := captureStdout(t, func() {
+			err = handleDryRun(nil, "work", cfg, toolFilter{})
+		})
+		require.NoError(t, err)
+
+		assert.Contains(t, out, "Would switch: (none) → work")
+		assert.Contains(t, out, "✗ git: 1 change(s) (1 file(s) replaced)")
+		assert.Contains(t, out, "~ user_name: Personal → Work")
+		assert.Contains(t, out, "⚠️  kubectl: no snapshot found, would be skipped")
+		assert.Contains(t, out, "+ PREVIEW_NEW (set)")
+		assert.Contains(t, out, "✓ PREVIEW_SET (unchanged)")
+		assert.NotContains(t, out, "secret-value")
+		assert.Contains(t, out, "Post-switch hooks:")
+		assert.Contains(t, out, "$ echo switched")
+	})
+
+	t.Run("applies the tool filter", func(t *testing.T) {
+		out := captureStdout(t, func() {
+			require.NoError(t, handleDryRun(nil, "work", cfg, toolFilter{Skip: []string{"kubectl"}}))
+		})
+		assert.Contains(t, out, "Tools: skipping kubectl")
+		assert.NotContains(t, out, "kubectl:")
+	})
+
+	t.Run("reports what the environment being left saves", func(t *testing.T) {
+		personal := &environment.Environment{
+			Name:  "personal",
+			Tools: map[string]environment.ToolConfig{"git": {Enabled: true}, "docker": {Enabled: false}},
+		}
+		noSaveCfg := config.DefaultConfig()
+		noSaveCfg.AutoSaveBeforeSwitch = "false"
+		out := captureStdout(t, func() {
+			require.NoError(t, handleDryRun(personal, "work", noSaveCfg, toolFilter{}))
+		})
+		assert.Contains(t, out, "Would back up 'personal'")
+		assert.Contains(t, out, "Would not save 'personal', its unsaved changes are discarded")
+
+		saveCfg := config.DefaultConfig()
+		saveCfg.AutoSaveBeforeSwitch = "true"
+		out = captureStdout(t, func() {
+			require.NoError(t, handleDryRun(personal, "work", saveCfg, toolFilter{}))
+		})
+		assert.Contains(t, out, "Would save into 'personal': git")
+
+		saveCfg.AutoSaveBeforeSwitch = "prompt"
+		out = captureStdout(t, func() {
+			require.NoError(t, handleDryRun(personal, "work", saveCfg, toolFilter{}))
+		})
+		assert.Contains(t, out, "Would ask to save into 'personal': git")
+	})
+
+	t.Run("fails on an invalid hook", func(t *testing.T) {
+		work.Hooks.PreSwitch = []environment.Hook{{Description: "empty"}}
+		require.NoError(t, work.Save())
+
+		var err error
+		captureStdout(t, func() {
+			err = handleDryRun(nil, "work", cfg, toolFilter{})
+		})
+		assert.ErrorContains(t, err, "pre-switch hook would fail")
+	})
+}
@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/hugofrely/envswitch/internal/config"
+	"github.com/hugofrely/envswitch/internal/hooks"
+	"github.com/hugofrely/envswitch/pkg/environment"
+	"github.com/hugofrely/envswitch/pkg/tools"
+)
+
+// switchSummaryInput is where the answer to the --interactive summary is read
+var switchSummaryInput io.Reader = os.Stdin
+
+// summaryRow is a line of the table shown by switch --interactive
+type summaryRow struct {
+	Step   string
+	Target string
+	Detail string
+}
+
+// switchSummary lists what switching from currentEnv to targetEnv does with
+// the tools of registry: the backup, the save, the tools restored, the
+// variables set and the hooks run
+func switchSummary(currentEnv, targetEnv *environment.Environment, cfg *config.Config, registry map[string]tools.Tool) ([]summaryRow, error) {
+	var rows []summaryRow
+
+	if currentEnv != nil {
+		switch {
+		case switchNoBackup:
+			rows = append(rows, summaryRow{"backup", currentEnv.Name, "skipped (--no-backup)"})
+		case !cfg.BackupBeforeSwitch:
+			rows = append(rows, summaryRow{"backup", currentEnv.Name, "skipped (backup_before_switch is false)"})
+		default:
+			rows = append(rows, summaryRow{"backup", currentEnv.Name, "archived to ~/.envswitch/archives"})
+		}
+
+		saved := enabledRegistryTools(currentEnv, liveToolFilter(currentEnv).apply(registry))
+		switch {
+		case autoSaveMode(cfg) == autoSaveNever:
+			rows = append(rows, summaryRow{"save", currentEnv.Name, "not saved, unsaved changes are discarded"})
+		case len(saved) == 0:
+			rows = append(rows, summaryRow{"save", currentEnv.Name, "no tools"})
+		case autoSaveMode(cfg) == autoSavePrompt:
+			rows = append(rows, summaryRow{"save", currentEnv.Name, "asked next: " + strings.Join(saved, ", ")})
+		default:
+			rows = append(rows, summaryRow{"save", currentEnv.Name, strings.Join(saved, ", ")})
+		}
+	}
+
+	previews := previewRestore(targetEnv, registry)
+	if len(previews) == 0 {
+		rows = append(rows, summaryRow{"restore", "-", "no tools"})
+	}
+	for _, preview := range previews {
+		switch {
+		case preview.Error != "":
+			rows = append(rows, summaryRow{"restore", preview.Tool, preview.Error})
+		case len(preview.Changes) == 0:
+			rows = append(rows, summaryRow{"restore", preview.Tool, "no changes"})
+		default:
+			rows = append(rows, summaryRow{"restore", preview.Tool, fmt.Sprintf("%d change(s)", len(preview.Changes))})
+		}
+	}
+
+	envVars, err := targetEnv.LoadEnvVars()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load environment variables: %w", err)
+	}
+	// Values are left out, some are secrets
+	for _, envVar := range envVars {
+		live, set := os.LookupEnv(envVar.Key)
+		switch {
+		case !set:
+			rows = append(rows, summaryRow{"set", envVar.Key, "new"})
+		case live != envVar.Value:
+			rows = append(rows, summaryRow{"set", envVar.Key, "changed"})
+		default:
+			rows = append(rows, summaryRow{"set", envVar.Key, "unchanged"})
+		}
+	}
+
+	if switchNoHooks {
+		rows = append(rows, summaryRow{"hooks", "-", "skipped (--no-hooks)"})
+		return rows, nil
+	}
+	for _, hook := range preSwitchHooks(targetEnv, cfg) {
+		rows = append(rows, summaryRow{"hook", "pre-switch", hooks.DescribeHook(hook)})
+	}
+	for _, hook := range postSwitchHooks(targetEnv, cfg) {
+		rows = append(rows, summaryRow{"hook", "post-switch", hooks.DescribeHook(hook)})
+	}
+	return rows, nil
+}
+
+// printSwitchSummary writes the rows of a switch summary as a table
+func printSwitchSummary(w io.Writer, rows []summaryRow) error {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "STEP\tON\tDETAILS")
+	for _, row := range rows {
+		fmt.Fprintf(table, "%s\t%s\t%s\n", row.Step, row.Target, row.Detail)
+	}
+	return table.Flush()
+}
+
+// confirmSwitch shows what switching from currentEnv to targetEnv does and
+// asks whether to go on, false when the answer cannot be read
+func confirmSwitch(currentEnv, targetEnv *environment.Environment, cfg *config.Config, filter toolFilter) (bool, error) {
+	rows, err := switchSummary(currentEnv, targetEnv, cfg, filter.apply(getToolRegistry()))
+	if err != nil {
+		return false, err
+	}
+
+	fmt.Printf("Switch: %s → %s\n", getFromName(currentEnv), targetEnv.Name)
+	if !filter.isEmpty() {
+		fmt.Printf("Tools: %s\n", filter)
+	}
+	fmt.Println()
+	if err := printSwitchSummary(os.Stdout, rows); err != nil {
+		return false, err
+	}
+
+	fmt.Print("\nProceed with the switch? [y/N]: ")
+	response, _ := bufio.NewReader(switchSummaryInput).ReadString('\n')
+	response = strings.ToLower(strings.TrimSpace(response))
+	return response == "y" || response == "yes", nil
+}
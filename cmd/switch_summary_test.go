@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hugofrely/envswitch/internal/config"
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+func TestSwitchSummary(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("SUMMARY_SET", "same")
+	require.NoError(t, os.WriteFile(filepath.Join(home, ".gitconfig"), []byte("[user]\n\tname = Personal\n"), 0600))
+
+	envPath := filepath.Join(home, ".envswitch", "environments", "work")
+	snapshot := filepath.Join(envPath, "snapshots", "git", "gitconfig")
+	require.NoError(t, os.MkdirAll(filepath.Dir(snapshot), 0700))
+	require.NoError(t, os.WriteFile(snapshot, []byte("[user]\n\tname = Work\n"), 0600))
+
+	work := &environment.Environment{
+		Name:      "work",
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		Path:      envPath,
+		Tools:     map[string]environment.ToolConfig{"git": {Enabled: true}},
+		EnvVars:   make(map[string]string),
+		Hooks: environment.Hooks{
+			PreSwitch: []environment.Hook{{Command: "rm -rf ~/.cache/work"}},
+		},
+	}
+	require.NoError(t, work.Save())
+	require.NoError(t, work.SetEnvVar("SUMMARY_NEW", "secret-value"))
+	require.NoError(t, work.SetEnvVar("SUMMARY_SET", "same"))
+
+	personal := &environment.Environment{
+		Name:  "personal",
+		Tools: map[string]environment.ToolConfig{"git": {Enabled: true}},
+	}
+
+	rows, err := switchSummary(personal, work, config.DefaultConfig(), getToolRegistry())
+	require.NoError(t, err)
+	assert.Equal(t, []summaryRow{
+		{"backup", "personal", "archived to ~/.envswitch/archives"},
+		{"save", "personal", "git"},
+		{"restore", "git", "1 change(s)"},
+		{"set", "SUMMARY_NEW", "new"},
+		{"set", "SUMMARY_SET", "unchanged"},
+		{"hook", "pre-switch", "rm -rf ~/.cache/work"},
+	}, rows)
+
+	var out bytes.Buffer
+	require.NoError(t, printSwitchSummary(&out, rows))
+	assert.Contains(t, out.String(), "STEP")
+	assert.NotContains(t, out.String(), "secret-value")
+
+	t.Run("declining cancels the switch", func(t *testing.T) {
+		switchInteractive = true
+		switchSummaryInput = strings.NewReader("n\n")
+		defer func() {
+			switchInteractive = false
+			switchSummaryInput = os.Stdin
+		}()
+
+		var switchErr error
+		captured := captureStdout(t, func() {
+			switchErr = switchEnvironment("work")
+		})
+		assert.ErrorContains(t, switchErr, "switch to 'work' canceled")
+		assert.Contains(t, captured, "Switch: (none) → work")
+		assert.Contains(t, captured, "Proceed with the switch?")
+
+		current, err := environment.GetCurrentEnvironmentName()
+		require.NoError(t, err)
+		assert.Empty(t, current)
+	})
+}
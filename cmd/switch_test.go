@@ -1,15 +1,23 @@
 package cmd
 
 import (
+	"context"
+	"encoding/json"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/hugofrely/envswitch/internal/config"
+	"github.com/hugofrely/envswitch/internal/history"
+	"github.com/hugofrely/envswitch/internal/storage"
 	"github.com/hugofrely/envswitch/pkg/environment"
+	"github.com/hugofrely/envswitch/pkg/plugin"
+	"github.com/hugofrely/envswitch/pkg/tools"
 )
 
 func TestRunSwitch(t *testing.T) {
@@ -407,7 +415,7 @@ func TestRunSwitch(t *testing.T) {
 
 func TestSwitchCommand(t *testing.T) {
 	t.Run("has correct metadata", func(t *testing.T) {
-		assert.Equal(t, "switch <name>", switchCmd.Use)
+		assert.Equal(t, "switch [name]", switchCmd.Use)
 		assert.NotEmpty(t, switchCmd.Short)
 		assert.NotEmpty(t, switchCmd.Long)
 	})
@@ -436,7 +444,7 @@ func TestSwitchCommand(t *testing.T) {
 		assert.Equal(t, "false", flag.DefValue)
 	})
 
-	t.Run("requires exactly one argument", func(t *testing.T) {
+	t.Run("takes at most one argument", func(t *testing.T) {
 		// Setup test environment
 		originalHome := os.Getenv("HOME")
 		tmpDir, err := os.MkdirTemp("", "envswitch-test-*")
@@ -450,9 +458,11 @@ func TestSwitchCommand(t *testing.T) {
 		envsDir := filepath.Join(envswitchDir, "environments")
 		os.MkdirAll(envsDir, 0755)
 
-		// Test with no arguments
+		// Test with no arguments, the environment is picked interactively
 		err = switchCmd.Args(switchCmd, []string{})
-		assert.Error(t, err)
+		assert.NoError(t, err)
+		err = runSwitch(switchCmd, []string{})
+		assert.ErrorContains(t, err, "no environments found")
 
 		// Test with two arguments
 		err = switchCmd.Args(switchCmd, []string{"env1", "env2"})
@@ -463,3 +473,299 @@ func TestSwitchCommand(t *testing.T) {
 		assert.NoError(t, err)
 	})
 }
+
+func TestSwitchHooksOrder(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "hooks.log")
+	logHook := func(name string) environment.Hook {
+		return environment.Hook{Command: "echo " + name + " >> " + logFile}
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.Hooks.PreSwitch = []environment.Hook{logHook("global-pre")}
+	cfg.Hooks.PostSwitch = []environment.Hook{logHook("global-post")}
+
+	env := &environment.Environment{
+		Name: "work",
+		Hooks: environment.Hooks{
+			PreSwitch:  []environment.Hook{logHook("env-pre")},
+			PostSwitch: []environment.Hook{logHook("env-post")},
+		},
+	}
+
+	entry := &history.SwitchEntry{}
+	require.NoError(t, executePreSwitchHooks(env, "work", cfg, entry, time.Now()))
+	executePostSwitchHooks(env, "work", cfg)
+
+	data, err := os.ReadFile(logFile)
+	require.NoError(t, err)
+	assert.Equal(t, "global-pre\nenv-pre\nenv-post\nglobal-post\n", string(data))
+	assert.Len(t, env.Hooks.PreSwitch, 1, "global hooks must not be added to the environment")
+
+	t.Run("global pre-switch failure aborts", func(t *testing.T) {
+		t.Setenv("HOME", t.TempDir())
+		failing := config.DefaultConfig()
+		failing.Hooks.PreSwitch = []environment.Hook{{Command: "exit 1"}}
+
+		err := executePreSwitchHooks(&environment.Environment{Name: "work"}, "work", failing, &history.SwitchEntry{}, time.Now())
+		assert.ErrorContains(t, err, "pre-switch hook failed")
+	})
+
+	t.Run("skipped with --no-hooks", func(t *testing.T) {
+		switchNoHooks = true
+		defer func() { switchNoHooks = false }()
+
+		require.NoError(t, os.Remove(logFile))
+		require.NoError(t, executePreSwitchHooks(env, "work", cfg, entry, time.Now()))
+		executePostSwitchHooks(env, "work", cfg)
+		assert.NoFileExists(t, logFile)
+	})
+}
+
+func TestRunSwitchStructured(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+	envsDir := filepath.Join(tempHome, ".envswitch", "environments")
+	createEnvWithVars(t, envsDir, "work", nil)
+	require.NoError(t, environment.SetCurrentEnvironment("work"))
+	setOutputFormat(t, "json")
+
+	t.Run("reports the outcome", func(t *testing.T) {
+		var err error
+		out := captureStdout(t, func() {
+			err = runSwitch(switchCmd, []string{"missing"})
+		})
+		require.Error(t, err)
+
+		var result switchResult
+		require.NoError(t, json.Unmarshal([]byte(out), &result), out)
+		assert.Equal(t, "work", result.From)
+		assert.Equal(t, "missing", result.To)
+		assert.False(t, result.Success)
+		assert.Contains(t, result.Error, "failed to load environment 'missing'")
+	})
+
+	t.Run("rejects --print-env", func(t *testing.T) {
+		switchPrintEnv = true
+		defer func() { switchPrintEnv = false }()
+
+		err := runSwitch(switchCmd, []string{"work"})
+		assert.ErrorContains(t, err, "--print-env cannot be combined with --output json")
+	})
+}
+
+func TestSwitchAutoSave(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+	envsDir := filepath.Join(tempHome, ".envswitch", "environments")
+
+	configPath := filepath.Join(tempHome, ".testrc")
+	installTestPlugin(t, tempHome, "testtool", configPath)
+
+	for _, name := range []string{"source", "target"} {
+		env := createEnvWithVars(t, envsDir, name, nil)
+		env.Tools["testtool"] = environment.ToolConfig{Enabled: true}
+		snapshotDir := filepath.Join(env.Path, "snapshots", "testtool")
+		require.NoError(t, os.MkdirAll(snapshotDir, 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(snapshotDir, ".testrc"), []byte(name), 0644))
+		require.NoError(t, env.Save())
+	}
+	sourceSnapshot := filepath.Join(envsDir, "source", "snapshots", "testtool", ".testrc")
+
+	// switchFromSource switches from source, whose live state was changed,
+	// and returns the snapshot of source afterwards
+	switchFromSource := func(t *testing.T, autoSave string, noSave bool) string {
+		cfg := config.DefaultConfig()
+		cfg.AutoSaveBeforeSwitch = autoSave
+		require.NoError(t, cfg.Save())
+		require.NoError(t, os.WriteFile(sourceSnapshot, []byte("source"), 0644))
+		require.NoError(t, os.WriteFile(configPath, []byte("unsaved"), 0644))
+		require.NoError(t, environment.SetCurrentEnvironment("source"))
+
+		switchNoSave = noSave
+		defer func() { switchNoSave = false }()
+		captureStdout(t, func() {
+			require.NoError(t, switchEnvironment("target"))
+		})
+
+		restored, err := os.ReadFile(configPath)
+		require.NoError(t, err)
+		assert.Equal(t, "target", string(restored))
+
+		snapshot, err := os.ReadFile(sourceSnapshot)
+		require.NoError(t, err)
+		return string(snapshot)
+	}
+
+	t.Run("the default saves the environment being left", func(t *testing.T) {
+		assert.Equal(t, "unsaved", switchFromSource(t, config.DefaultConfig().AutoSaveBeforeSwitch, false))
+	})
+
+	t.Run("true saves the environment being left", func(t *testing.T) {
+		assert.Equal(t, "unsaved", switchFromSource(t, "true", false))
+	})
+
+	t.Run("false does not save it", func(t *testing.T) {
+		assert.Equal(t, "source", switchFromSource(t, "false", false))
+	})
+
+	t.Run("--no-save takes precedence over true", func(t *testing.T) {
+		assert.Equal(t, "source", switchFromSource(t, "true", true))
+	})
+
+	t.Run("prompt saves when the user agrees", func(t *testing.T) {
+		source := &environment.Environment{Name: "source"}
+		cfg := config.DefaultConfig()
+		cfg.AutoSaveBeforeSwitch = "prompt"
+
+		for answer, want := range map[string]bool{"y\n": true, "YES\n": true, "n\n": false, "\n": false, "": false} {
+			var save bool
+			out := captureStdout(t, func() {
+				save = shouldSaveBeforeSwitch(source, cfg, strings.NewReader(answer))
+			})
+			assert.Equal(t, want, save, "answer %q", answer)
+			assert.Contains(t, out, "Save current environment 'source' before switching? (y/N)")
+		}
+
+		assert.False(t, shouldSaveBeforeSwitch(nil, cfg, strings.NewReader("y\n")), "no environment to save")
+
+		switchNoSave = true
+		defer func() { switchNoSave = false }()
+		out := captureStdout(t, func() {
+			assert.False(t, shouldSaveBeforeSwitch(source, cfg, strings.NewReader("y\n")))
+		})
+		assert.Empty(t, out, "--no-save does not ask")
+	})
+}
+
+func TestEnvVarPatterns(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	env := &environment.Environment{Name: "work", EnvVars: map[string]string{"VAULT_*": "", "AWS_PROFILE": ""}}
+
+	patterns, deny := envVarPatterns(env)
+	assert.Equal(t, []string{"AWS_PROFILE", "VAULT_*"}, patterns)
+	assert.Empty(t, deny)
+
+	cfg := config.DefaultConfig()
+	cfg.EnvVarsInclude = []string{"KUBECONFIG"}
+	cfg.EnvVarsDeny = []string{"*_TOKEN"}
+	require.NoError(t, cfg.Save())
+
+	patterns, deny = envVarPatterns(env)
+	assert.Equal(t, []string{"AWS_PROFILE", "VAULT_*", "KUBECONFIG"}, patterns)
+	assert.Equal(t, []string{"*_TOKEN"}, deny)
+}
+
+// interruptingTool restores like its GenericTool, then cancels the switch
+type interruptingTool struct {
+	*tools.GenericTool
+	cancel context.CancelFunc
+}
+
+func (i *interruptingTool) SnapshotContext(ctx context.Context, snapshotPath string) error {
+	return i.Snapshot(snapshotPath)
+}
+
+func (i *interruptingTool) RestoreContext(ctx context.Context, snapshotPath string) error {
+	defer i.cancel()
+	return i.Restore(snapshotPath)
+}
+
+func TestRestoreTargetStateInterrupted(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("HOME", tempDir)
+
+	configPath := filepath.Join(tempDir, ".toolrc")
+	withSnapshot := func(name, content string) *environment.Environment {
+		env := createTestEnv(t, tempDir, name)
+		snapshotPath := filepath.Join(env.Path, "snapshots", "tool")
+		require.NoError(t, os.MkdirAll(snapshotPath, 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(snapshotPath, ".toolrc"), []byte(content), 0644))
+		env.Tools["tool"] = environment.ToolConfig{Enabled: true, SnapshotPath: snapshotPath}
+		return env
+	}
+	// The live config changed since work was last saved
+	work := withSnapshot("work", "stale")
+	prod := withSnapshot("prod", "prod")
+	require.NoError(t, os.WriteFile(configPath, []byte("live"), 0644))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	registry := map[string]tools.Tool{
+		"tool": &interruptingTool{GenericTool: tools.NewGenericTool("tool", configPath), cancel: cancel},
+	}
+
+	entry := history.SwitchEntry{From: "work", To: "prod"}
+	_, err := restoreTargetState(ctx, prod, work, registry, &entry, time.Now(), nil)
+	require.Error(t, err)
+	assert.Equal(t, "switch interrupted, rolled back 1 tool(s) to 'work'", err.Error())
+	assert.Equal(t, err.Error(), entry.ErrorMsg)
+
+	content, err := os.ReadFile(configPath)
+	require.NoError(t, err)
+	assert.Equal(t, "live", string(content), "the restored tool is rolled back to its live config, not the snapshot")
+
+	entries, err := os.ReadDir(tempDir)
+	require.NoError(t, err)
+	for _, e := range entries {
+		assert.NotContains(t, e.Name(), ".envswitch-restore-", "the restore backups are removed")
+	}
+
+	t.Run("without an environment being left", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		registry["tool"].(*interruptingTool).cancel = cancel
+
+		_, err := restoreTargetState(ctx, prod, nil, registry, &history.SwitchEntry{}, time.Now(), nil)
+		assert.EqualError(t, err, "switch interrupted, rolled back 1 tool(s)")
+		content, err := os.ReadFile(configPath)
+		require.NoError(t, err)
+		assert.Equal(t, "live", string(content))
+	})
+
+	t.Run("tools without config paths", func(t *testing.T) {
+		held := storage.HoldRestoreBackups()
+		defer held.Release()
+		tool, err := tools.NewExecPluginTool("plugin", t.TempDir(), plugin.Commands{SnapshotCmd: "true", RestoreCmd: "true"}, nil)
+		require.NoError(t, err)
+		registry := map[string]tools.Tool{"plugin": tool}
+
+		err = rollbackInterruptedRestore(held, work, registry, []string{"plugin"})
+		assert.EqualError(t, err, "switch interrupted, plugin already restored, they cannot be rolled back")
+	})
+}
+
+func TestRestoreToolMergeStrategy(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("HOME", tempDir)
+
+	env := createTestEnv(t, tempDir, "work")
+	snapshotPath := filepath.Join(env.Path, "snapshots", "kubectl")
+	require.NoError(t, os.MkdirAll(snapshotPath, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(snapshotPath, "config"), []byte(
+		"current-context: work\ncontexts:\n- name: work\n  context:\n    cluster: work\n"), 0600))
+	env.Tools["kubectl"] = environment.ToolConfig{Enabled: true, SnapshotPath: snapshotPath, RestoreStrategy: environment.RestoreStrategyMerge}
+
+	kubeDir := filepath.Join(tempDir, ".kube")
+	require.NoError(t, os.MkdirAll(kubeDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(kubeDir, "config"), []byte(
+		"current-context: home\ncontexts:\n- name: home\n  context:\n    cluster: home\n"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(kubeDir, "home.crt"), []byte("home"), 0600))
+
+	require.NoError(t, restoreTool(context.Background(), env, "kubectl", &tools.KubectlTool{KubeConfigDir: kubeDir}))
+
+	data, err := os.ReadFile(filepath.Join(kubeDir, "config"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "current-context: work")
+	assert.Contains(t, string(data), "name: home", "live contexts are kept")
+	assert.FileExists(t, filepath.Join(kubeDir, "home.crt"), "live files are kept")
+
+	t.Run("tools that cannot merge fail", func(t *testing.T) {
+		genericSnapshot := filepath.Join(env.Path, "snapshots", "tool")
+		require.NoError(t, os.MkdirAll(genericSnapshot, 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(genericSnapshot, ".toolrc"), []byte("work"), 0644))
+		env.Tools["tool"] = environment.ToolConfig{Enabled: true, SnapshotPath: genericSnapshot, RestoreStrategy: environment.RestoreStrategyMerge}
+
+		err := restoreTool(context.Background(), env, "tool", tools.NewGenericTool("tool", filepath.Join(tempDir, ".toolrc")))
+		assert.ErrorContains(t, err, "tool cannot merge snapshots")
+	})
+}
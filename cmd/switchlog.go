@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/hugofrely/envswitch/internal/config"
+	"github.com/hugofrely/envswitch/internal/logger"
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+// switchLogPrefix prefixes the per-switch logs of ~/.envswitch/logs
+const switchLogPrefix = "switch-"
+
+// getSwitchLogDir returns the directory of the per-switch logs
+func getSwitchLogDir() (string, error) {
+	dir, err := environment.GetEnvswitchDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "logs"), nil
+}
+
+// startSwitchLog writes every message of a switch, whatever the log levels,
+// to ~/.envswitch/logs/switch-<timestamp>.log and deletes the logs beyond
+// switch_log_retention. It returns the path of the log, empty when switch
+// logs are disabled or it cannot be written, and the function ending it.
+func startSwitchLog(cfg *config.Config, startTime time.Time) (string, func()) {
+	if cfg.SwitchLogRetention <= 0 {
+		return "", func() {}
+	}
+	dir, err := getSwitchLogDir()
+	if err != nil {
+		return "", func() {}
+	}
+
+	path := filepath.Join(dir, switchLogPrefix+startTime.Format("20060102-150405")+".log")
+	stop, err := logger.StartRunLog(path)
+	if err != nil {
+		switchLog.Debug("Failed to start the switch log: %v", err)
+		return "", func() {}
+	}
+	pruneSwitchLogs(dir, cfg.SwitchLogRetention)
+	return path, stop
+}
+
+// pruneSwitchLogs deletes the oldest switch logs of dir beyond keep
+func pruneSwitchLogs(dir string, keep int) {
+	logs, err := filepath.Glob(filepath.Join(dir, switchLogPrefix+"*.log"))
+	if err != nil {
+		return
+	}
+	// Timestamps sort in the order the logs were written
+	sort.Strings(logs)
+	for len(logs) > keep {
+		if err := os.Remove(logs[0]); err != nil {
+			switchLog.Debug("Failed to delete old switch log %s: %v", filepath.Base(logs[0]), err)
+		}
+		logs = logs[1:]
+	}
+}
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hugofrely/envswitch/internal/history"
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+func TestPruneSwitchLogs(t *testing.T) {
+	dir := t.TempDir()
+	names := []string{
+		"switch-20240101-100000.log",
+		"switch-20240102-100000.log",
+		"switch-20240103-100000.log",
+		"switch-20240104-100000.log",
+	}
+	for _, name := range names {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("log\n"), 0600))
+	}
+	other := filepath.Join(dir, "other.log")
+	require.NoError(t, os.WriteFile(other, []byte("log\n"), 0600))
+
+	pruneSwitchLogs(dir, 2)
+
+	logs, err := filepath.Glob(filepath.Join(dir, switchLogPrefix+"*.log"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "switch-20240103-100000.log"),
+		filepath.Join(dir, "switch-20240104-100000.log"),
+	}, logs)
+	assert.FileExists(t, other, "files that are not switch logs are left alone")
+}
+
+func TestSwitchWritesSwitchLog(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	envsDir := filepath.Join(tmpDir, ".envswitch", "environments")
+
+	for _, name := range []string{"personal", "work"} {
+		env := &environment.Environment{
+			Name:      name,
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+			Tools:     make(map[string]environment.ToolConfig),
+			EnvVars:   make(map[string]string),
+			Path:      filepath.Join(envsDir, name),
+		}
+		require.NoError(t, os.MkdirAll(env.Path, 0700))
+		require.NoError(t, env.Save())
+	}
+	require.NoError(t, environment.SetCurrentEnvironment("personal"))
+
+	require.NoError(t, runSwitch(switchCmd, []string{"work"}))
+
+	hist, err := history.LoadHistory()
+	require.NoError(t, err)
+	entry := hist.GetLastActivation("work")
+	require.NotNil(t, entry)
+	require.NotEmpty(t, entry.LogFile)
+	assert.Equal(t, filepath.Join(tmpDir, ".envswitch", "logs"), filepath.Dir(entry.LogFile))
+
+	data, err := os.ReadFile(entry.LogFile)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "Switching from 'personal' to 'work'", "debug messages are logged whatever log_level")
+	assert.Contains(t, string(data), "Switched from 'personal' to 'work'")
+}
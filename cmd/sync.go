@@ -0,0 +1,283 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/hugofrely/envswitch/internal/config"
+	"github.com/hugofrely/envswitch/internal/logger"
+	envsync "github.com/hugofrely/envswitch/internal/sync"
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+var syncLog = logger.Named("sync")
+
+var (
+	syncInitEncrypt bool
+	syncPullForce   bool
+)
+
+var syncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Share environments between machines through a git repository",
+	Long: `Share environments between machines through a git repository.
+
+The environments are copied to a git working copy in ~/.envswitch/sync,
+committed and pushed to the remote set with 'envswitch sync init'. An
+environment changed on two machines since they last synced is a conflict:
+pull refuses it unless --force is given, the remote version then wins.
+
+With encryption enabled, every file but metadata.yaml is encrypted with the
+key in ~/.envswitch/sync.key before leaving the machine. The key is never
+pushed: copy it to your other machines yourself.
+
+Set auto_sync to true to push after every switch and save.`,
+}
+
+var syncInitCmd = &cobra.Command{
+	Use:   "init <remote>",
+	Short: "Set up sync with a git remote",
+	Long: `Set up sync with a git remote, an empty repository or one another machine
+already pushes to. Environments on the remote that this machine does not
+have are copied in.
+
+Examples:
+  envswitch sync init git@github.com:me/envswitch-envs.git
+  envswitch sync init git@github.com:me/envswitch-envs.git --encrypt`,
+	Args: cobra.ExactArgs(1),
+	RunE: runSyncInit,
+}
+
+var syncPushCmd = &cobra.Command{
+	Use:   "push",
+	Short: "Commit and push the local environments",
+	Long: `Commit the changes to the local environments and push them.
+
+Examples:
+  envswitch sync push`,
+	Args: cobra.NoArgs,
+	RunE: runSyncPush,
+}
+
+var syncPullCmd = &cobra.Command{
+	Use:   "pull",
+	Short: "Pull the environments changed on other machines",
+	Long: `Pull the environments changed on other machines into the local store.
+Local changes are committed first and pushed by the next 'sync push'.
+
+Examples:
+  envswitch sync pull
+  envswitch sync pull --force  # the remote wins for conflicting environments`,
+	Args: cobra.NoArgs,
+	RunE: runSyncPull,
+}
+
+var syncStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the environments changed locally and on the remote",
+	Long: `Show the environments changed on this machine and on the remote since the
+last sync, and those changed on both.
+
+Examples:
+  envswitch sync status`,
+	Args: cobra.NoArgs,
+	RunE: runSyncStatus,
+}
+
+func init() {
+	rootCmd.AddCommand(syncCmd)
+	syncCmd.AddCommand(syncInitCmd)
+	syncCmd.AddCommand(syncPushCmd)
+	syncCmd.AddCommand(syncPullCmd)
+	syncCmd.AddCommand(syncStatusCmd)
+
+	syncInitCmd.Flags().BoolVar(&syncInitEncrypt, "encrypt", false, "Encrypt synced snapshots with a key kept on this machine")
+	syncPullCmd.Flags().BoolVar(&syncPullForce, "force", false, "Take the remote version of conflicting environments")
+}
+
+// newSyncRepo returns the sync repository configured in cfg
+func newSyncRepo(cfg *config.Config) (*envsync.Repo, error) {
+	if cfg.SyncProvider != "" && cfg.SyncProvider != "git" {
+		return nil, fmt.Errorf("unsupported sync provider '%s'", cfg.SyncProvider)
+	}
+	if cfg.SyncRepo == "" {
+		return nil, envsync.ErrNotInitialized
+	}
+	if !envsync.IsAvailable() {
+		return nil, fmt.Errorf("sync needs git, which is not installed")
+	}
+
+	envswitchDir, err := environment.GetEnvswitchDir()
+	if err != nil {
+		return nil, err
+	}
+
+	var key []byte
+	if cfg.SyncEncrypt {
+		if key, err = envsync.LoadKey(filepath.Join(envswitchDir, envsync.KeyFileName)); err != nil {
+			return nil, err
+		}
+	}
+	return envsync.New(envswitchDir, cfg.SyncRepo, key), nil
+}
+
+func runSyncInit(cmd *cobra.Command, args []string) error {
+	if !envsync.IsAvailable() {
+		return fmt.Errorf("sync needs git, which is not installed")
+	}
+
+	cfg, err := config.LoadBaseConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	envswitchDir, err := environment.GetEnvswitchDir()
+	if err != nil {
+		return err
+	}
+
+	var key []byte
+	keyPath := filepath.Join(envswitchDir, envsync.KeyFileName)
+	if syncInitEncrypt {
+		if key, err = envsync.GenerateKey(keyPath); err != nil {
+			return err
+		}
+	}
+
+	imported, err := envsync.New(envswitchDir, args[0], key).Init()
+	if err != nil {
+		return fmt.Errorf("failed to set up sync: %w", err)
+	}
+
+	cfg.SyncProvider = "git"
+	cfg.SyncRepo = args[0]
+	cfg.SyncEncrypt = syncInitEncrypt
+	if err := cfg.Save(); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	fmt.Printf("✅ Sync set up with %s\n", args[0])
+	if len(imported) > 0 {
+		fmt.Printf("   Copied from the remote: %s\n", strings.Join(imported, ", "))
+	}
+	if syncInitEncrypt {
+		fmt.Printf("   Snapshots are encrypted with %s, copy it to your other machines\n", displayStorePath(envswitchDir, keyPath))
+	}
+	fmt.Println("   Run 'envswitch sync push' to share this machine's environments")
+	return nil
+}
+
+func runSyncPush(cmd *cobra.Command, args []string) error {
+	repo, err := loadSyncRepo()
+	if err != nil {
+		return err
+	}
+
+	pushed, err := repo.Push()
+	if err != nil {
+		return fmt.Errorf("failed to push: %w", err)
+	}
+	if len(pushed) == 0 {
+		fmt.Println("Everything is up to date")
+		return nil
+	}
+	fmt.Printf("✅ Pushed %d environment(s): %s\n", len(pushed), strings.Join(pushed, ", "))
+	return nil
+}
+
+func runSyncPull(cmd *cobra.Command, args []string) error {
+	repo, err := loadSyncRepo()
+	if err != nil {
+		return err
+	}
+
+	pulled, err := repo.Pull(syncPullForce)
+	var conflict *envsync.ConflictError
+	if errors.As(err, &conflict) {
+		return fmt.Errorf("%w\nUse 'envswitch sync pull --force' to take the remote version", conflict)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to pull: %w", err)
+	}
+
+	if len(pulled) == 0 {
+		fmt.Println("Everything is up to date")
+		return nil
+	}
+	fmt.Printf("✅ Pulled %d environment(s): %s\n", len(pulled), strings.Join(pulled, ", "))
+
+	if current, err := environment.GetCurrentEnvironment(); err == nil && current != nil && containsString(pulled, current.Name) {
+		fmt.Printf("⚠️  The active environment '%s' changed, its snapshots apply the next time you switch to it\n", current.Name)
+	}
+	return nil
+}
+
+func runSyncStatus(cmd *cobra.Command, args []string) error {
+	repo, err := loadSyncRepo()
+	if err != nil {
+		return err
+	}
+
+	status, err := repo.Status()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Remote: %s\n\n", repo.Remote)
+	printSyncList("Changed here", status.LocalChanges)
+	if status.FetchError != nil {
+		fmt.Printf("⚠️  Remote changes unknown: %v\n", status.FetchError)
+	} else {
+		printSyncList("Changed on the remote", status.RemoteChanges)
+	}
+	if len(status.Conflicts) > 0 {
+		fmt.Println()
+		fmt.Printf("✗ Conflicts: %s\n", strings.Join(status.Conflicts, ", "))
+		fmt.Println("  'envswitch sync pull --force' takes the remote version")
+	}
+	return nil
+}
+
+func loadSyncRepo() (*envsync.Repo, error) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	return newSyncRepo(cfg)
+}
+
+func printSyncList(title string, names []string) {
+	if len(names) == 0 {
+		fmt.Printf("%s: nothing\n", title)
+		return
+	}
+	fmt.Printf("%s:\n", title)
+	for _, name := range names {
+		fmt.Printf("  • %s\n", name)
+	}
+}
+
+// autoSync pushes the environments when auto_sync is on. A failed push is
+// reported but never fails the command.
+func autoSync(cfg *config.Config) {
+	if cfg == nil || !cfg.AutoSync {
+		return
+	}
+
+	repo, err := newSyncRepo(cfg)
+	if err != nil {
+		syncLog.Warn("Auto-sync skipped: %v", err)
+		return
+	}
+	pushed, err := repo.Push()
+	if err != nil {
+		syncLog.Warn("Auto-sync failed: %v", err)
+		return
+	}
+	if len(pushed) > 0 {
+		syncLog.Debug("Auto-sync pushed %s", strings.Join(pushed, ", "))
+	}
+}
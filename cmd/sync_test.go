@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hugofrely/envswitch/internal/config"
+	envsync "github.com/hugofrely/envswitch/internal/sync"
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+func TestNewSyncRepo(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	_, err := newSyncRepo(&config.Config{})
+	assert.ErrorIs(t, err, envsync.ErrNotInitialized)
+
+	_, err = newSyncRepo(&config.Config{SyncProvider: "dropbox", SyncRepo: "remote"})
+	assert.ErrorContains(t, err, "unsupported sync provider 'dropbox'")
+
+	if envsync.IsAvailable() {
+		_, err = newSyncRepo(&config.Config{SyncRepo: "remote", SyncEncrypt: true})
+		assert.ErrorContains(t, err, "sync.key not found")
+	}
+}
+
+func TestSyncCommands(t *testing.T) {
+	if !envsync.IsAvailable() {
+		t.Skip("git is not installed")
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	remote := filepath.Join(home, "remote.git")
+	require.NoError(t, exec.Command("git", "init", "-q", "--bare", remote).Run())
+
+	envsDir := filepath.Join(home, ".envswitch", "environments")
+	env := &environment.Environment{
+		Name:      "work",
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		Tools:     make(map[string]environment.ToolConfig),
+		EnvVars:   make(map[string]string),
+		Path:      filepath.Join(envsDir, "work"),
+	}
+	require.NoError(t, os.MkdirAll(env.Path, 0700))
+	require.NoError(t, env.Save())
+
+	syncInitEncrypt = true
+	defer func() { syncInitEncrypt = false }()
+
+	out := captureStdout(t, func() {
+		require.NoError(t, runSyncInit(syncInitCmd, []string{remote}))
+	})
+	assert.Contains(t, out, "Sync set up with "+remote)
+	assert.FileExists(t, filepath.Join(home, ".envswitch", envsync.KeyFileName))
+
+	cfg, err := config.LoadConfig()
+	require.NoError(t, err)
+	assert.Equal(t, remote, cfg.SyncRepo)
+	assert.True(t, cfg.SyncEncrypt)
+
+	out = captureStdout(t, func() {
+		require.NoError(t, runSyncPush(syncPushCmd, nil))
+	})
+	assert.Contains(t, out, "Pushed 1 environment(s): work")
+
+	out = captureStdout(t, func() {
+		require.NoError(t, runSyncStatus(syncStatusCmd, nil))
+	})
+	assert.Contains(t, out, "Changed here: nothing")
+
+	t.Run("auto-sync pushes after a change", func(t *testing.T) {
+		env.Description = "updated"
+		require.NoError(t, env.Save())
+
+		cfg.AutoSync = true
+		autoSync(cfg)
+
+		out := captureStdout(t, func() {
+			require.NoError(t, runSyncPush(syncPushCmd, nil))
+		})
+		assert.Contains(t, out, "Everything is up to date")
+	})
+}
@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+var tagRemove []string
+
+var tagCmd = &cobra.Command{
+	Use:   "tag <environment> [tag...]",
+	Short: "Add, remove or show the tags of an environment",
+	Long: `Tag environments to filter them and operate on several at once.
+
+A tag is a word (prod) or a key=value pair (client=acme). Adding a key=value
+tag replaces the value of its key. Without tags to add or remove, the tags of
+the environment are printed.
+
+Filter on tags with 'list --tag', 'export --tag' and 'delete --tag'. A filter
+such as client=acme matches that tag, a key alone (client) matches any value.
+
+Examples:
+  envswitch tag acme-prod client=acme type=prod
+  envswitch tag acme-prod --remove type
+  envswitch tag acme-prod
+  envswitch list --tag client=acme`,
+	Args:              cobra.MinimumNArgs(1),
+	ValidArgsFunction: completeEnvironmentNames,
+	RunE:              runTag,
+}
+
+func init() {
+	rootCmd.AddCommand(tagCmd)
+	tagCmd.Flags().StringArrayVar(&tagRemove, "remove", nil, "Tag or key to remove (repeatable)")
+	_ = tagCmd.RegisterFlagCompletionFunc("remove", completeTags)
+}
+
+func runTag(cmd *cobra.Command, args []string) error {
+	env, err := environment.LoadEnvironment(args[0])
+	if err != nil {
+		return fmt.Errorf("environment '%s' not found: %w", args[0], err)
+	}
+
+	tags := args[1:]
+	if len(tags) == 0 && len(tagRemove) == 0 {
+		if len(env.Tags) == 0 {
+			fmt.Printf("'%s' has no tags\n", env.Name)
+			return nil
+		}
+		for _, tag := range env.Tags {
+			fmt.Println(tag)
+		}
+		return nil
+	}
+
+	for _, tag := range append(append([]string{}, tags...), tagRemove...) {
+		if err := environment.ValidateTag(tag); err != nil {
+			return err
+		}
+	}
+
+	removed := env.RemoveTags(tagRemove...)
+	added := env.AddTags(tags...)
+	if !removed && !added {
+		fmt.Printf("Tags of '%s' unchanged\n", env.Name)
+		return nil
+	}
+	if err := env.Save(); err != nil {
+		return fmt.Errorf("failed to save environment: %w", err)
+	}
+
+	if len(env.Tags) == 0 {
+		fmt.Printf("✅ Removed the tags of '%s'\n", env.Name)
+	} else {
+		fmt.Printf("✅ Tags of '%s': %s\n", env.Name, strings.Join(env.Tags, ", "))
+	}
+	return nil
+}
+
+// tagFilteredEnvironments returns the environments matching every tag
+// filter, an error when there is none
+func tagFilteredEnvironments(filters []string) ([]*environment.Environment, error) {
+	envs, err := environment.ListEnvironments()
+	if err != nil {
+		return nil, err
+	}
+	matched := environment.FilterByTags(envs, filters)
+	if len(matched) == 0 {
+		return nil, fmt.Errorf("no environment is tagged %s", strings.Join(filters, " and "))
+	}
+	return matched, nil
+}
+
+// completeTags completes the tags used by environments
+func completeTags(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	envs, err := environment.ListEnvironments()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	seen := make(map[string]bool)
+	var tags []string
+	for _, env := range envs {
+		for _, tag := range env.Tags {
+			if !seen[tag] && strings.HasPrefix(tag, toComplete) {
+				seen[tag] = true
+				tags = append(tags, tag)
+			}
+		}
+	}
+	sort.Strings(tags)
+	return tags, cobra.ShellCompDirectiveNoFileComp
+}
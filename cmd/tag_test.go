@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+// setupTaggedEnvironments creates acme-prod, acme-dev and personal, the first
+// two tagged client=acme
+func setupTaggedEnvironments(t *testing.T) string {
+	t.Helper()
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+
+	envsDir := filepath.Join(tempHome, ".envswitch", "environments")
+	for name, tags := range map[string][]string{
+		"acme-prod": {"client=acme", "type=prod"},
+		"acme-dev":  {"client=acme", "type=dev"},
+		"personal":  nil,
+	} {
+		env := createEnvWithVars(t, envsDir, name, nil)
+		env.Tags = tags
+		require.NoError(t, env.Save())
+	}
+	return envsDir
+}
+
+func TestRunTag(t *testing.T) {
+	setupTaggedEnvironments(t)
+
+	t.Run("adds tags", func(t *testing.T) {
+		out := captureStdout(t, func() {
+			require.NoError(t, runTag(tagCmd, []string{"personal", "type=home", "laptop"}))
+		})
+		assert.Contains(t, out, "laptop, type=home")
+
+		env, err := environment.LoadEnvironment("personal")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"laptop", "type=home"}, env.Tags)
+	})
+
+	t.Run("removes tags", func(t *testing.T) {
+		tagRemove = []string{"type"}
+		defer func() { tagRemove = nil }()
+
+		captureStdout(t, func() { require.NoError(t, runTag(tagCmd, []string{"acme-prod"})) })
+
+		env, err := environment.LoadEnvironment("acme-prod")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"client=acme"}, env.Tags)
+	})
+
+	t.Run("prints the tags", func(t *testing.T) {
+		out := captureStdout(t, func() { require.NoError(t, runTag(tagCmd, []string{"acme-dev"})) })
+		assert.Equal(t, "client=acme\ntype=dev\n", out)
+	})
+
+	t.Run("rejects invalid tags", func(t *testing.T) {
+		assert.Error(t, runTag(tagCmd, []string{"acme-dev", "a,b"}))
+	})
+}
+
+func TestListByTag(t *testing.T) {
+	setupTaggedEnvironments(t)
+	listJSON = true
+	defer func() { listJSON = false }()
+
+	listTags = []string{"client=acme"}
+	defer func() { listTags = nil }()
+
+	out := captureStdout(t, func() { require.NoError(t, runList(listCmd, nil)) })
+	var listings []environmentListing
+	require.NoError(t, json.Unmarshal([]byte(out), &listings))
+	require.Len(t, listings, 2)
+	for _, listing := range listings {
+		assert.Contains(t, listing.Tags, "client=acme")
+	}
+
+	listTags = []string{"client=acme", "type=prod"}
+	out = captureStdout(t, func() { require.NoError(t, runList(listCmd, nil)) })
+	require.NoError(t, json.Unmarshal([]byte(out), &listings))
+	require.Len(t, listings, 1)
+	assert.Equal(t, "acme-prod", listings[0].Name)
+}
+
+func TestExportByTag(t *testing.T) {
+	setupTaggedEnvironments(t)
+	exportTags = []string{"client"}
+	exportOutput = filepath.Join(t.TempDir(), "acme")
+	defer func() { exportTags, exportOutput = nil, "" }()
+
+	captureStdout(t, func() { require.NoError(t, runExport(exportCmd, nil)) })
+
+	entries, err := os.ReadDir(exportOutput)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	assert.Error(t, runExport(exportCmd, []string{"personal"}), "names and --tag are exclusive")
+}
+
+func TestDeleteByTag(t *testing.T) {
+	envsDir := setupTaggedEnvironments(t)
+	deleteTags = []string{"client=acme"}
+	deleteYes = true
+	deleteNoArchive = true
+	defer func() { deleteTags, deleteYes, deleteNoArchive = nil, false, false }()
+
+	t.Run("refuses the active environment", func(t *testing.T) {
+		require.NoError(t, environment.SetCurrentEnvironment("acme-dev"))
+		defer func() { _ = environment.ClearCurrentEnvironment() }()
+
+		captureStdout(t, func() { assert.Error(t, runDelete(deleteCmd, nil)) })
+		assert.DirExists(t, filepath.Join(envsDir, "acme-prod"))
+	})
+
+	t.Run("deletes the tagged environments", func(t *testing.T) {
+		captureStdout(t, func() { require.NoError(t, runDelete(deleteCmd, nil)) })
+
+		assert.NoDirExists(t, filepath.Join(envsDir, "acme-prod"))
+		assert.NoDirExists(t, filepath.Join(envsDir, "acme-dev"))
+		assert.DirExists(t, filepath.Join(envsDir, "personal"))
+	})
+
+	t.Run("fails when no environment matches", func(t *testing.T) {
+		assert.Error(t, runDelete(deleteCmd, nil))
+	})
+
+	t.Run("names and --tag are exclusive", func(t *testing.T) {
+		assert.Error(t, validateDeleteArgs(deleteCmd, []string{"personal"}))
+	})
+}
@@ -0,0 +1,255 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+var (
+	templateSaveForce       bool
+	templateSaveDescription string
+)
+
+var templateCmd = &cobra.Command{
+	Use:   "template",
+	Short: "Manage environment templates",
+	Long: `Templates hold the structure of an environment: its enabled tools, hooks,
+tags and variables, without snapshots and without the values of secret
+variables. Create environments from them with 'envswitch create --template'.
+
+Examples:
+  envswitch template save client-a client
+  envswitch template list
+  envswitch create client-b --template client
+  envswitch template delete client`,
+}
+
+var templateSaveCmd = &cobra.Command{
+	Use:   "save <env> <template>",
+	Short: "Save the structure of an environment as a template",
+	Long: `Save the enabled tools, hooks, tags and variables of an environment as a
+template. Variables marked as secret are saved without their value, which is
+asked for when the template is used; the values of the others become defaults.
+
+Examples:
+  envswitch template save client-a client
+  envswitch template save client-a client --force -d "Client projects"`,
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: completeTemplateSaveArgs,
+	RunE:              runTemplateSave,
+}
+
+var templateListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the templates",
+	Args:  cobra.NoArgs,
+	RunE:  runTemplateList,
+}
+
+var templateDeleteCmd = &cobra.Command{
+	Use:               "delete <template>",
+	Aliases:           []string{"rm"},
+	Short:             "Delete a template",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeTemplateNames,
+	RunE:              runTemplateDelete,
+}
+
+func init() {
+	rootCmd.AddCommand(templateCmd)
+	templateCmd.AddCommand(templateSaveCmd)
+	templateCmd.AddCommand(templateListCmd)
+	templateCmd.AddCommand(templateDeleteCmd)
+
+	templateSaveCmd.Flags().BoolVarP(&templateSaveForce, "force", "f", false, "Replace an existing template")
+	templateSaveCmd.Flags().StringVarP(&templateSaveDescription, "description", "d", "", "Template description (default: the environment's)")
+}
+
+func runTemplateSave(cmd *cobra.Command, args []string) error {
+	envName, templateName := args[0], args[1]
+
+	env, err := environment.LoadEnvironment(envName)
+	if err != nil {
+		return fmt.Errorf("failed to load environment '%s': %w", envName, err)
+	}
+
+	tmpl, err := environment.NewTemplate(env, templateName)
+	if err != nil {
+		return err
+	}
+	if templateSaveDescription != "" {
+		tmpl.Description = templateSaveDescription
+	}
+
+	exists, err := environment.TemplateExists(templateName)
+	if err != nil {
+		return err
+	}
+	if exists && !templateSaveForce {
+		return fmt.Errorf("template '%s' already exists (use --force to replace it)", templateName)
+	}
+
+	if err := tmpl.Save(); err != nil {
+		return err
+	}
+
+	secrets := 0
+	for _, templateVar := range tmpl.EnvVars {
+		if templateVar.Secret {
+			secrets++
+		}
+	}
+	fmt.Printf("✅ Saved template '%s' from '%s': %d tool(s), %d variable(s)\n", templateName, envName, len(tmpl.Tools), len(tmpl.EnvVars))
+	if secrets > 0 {
+		fmt.Printf("   %d secret variable(s) saved without their value\n", secrets)
+	}
+	return nil
+}
+
+func runTemplateList(cmd *cobra.Command, args []string) error {
+	templates, err := environment.ListTemplates()
+	if err != nil {
+		return err
+	}
+	if len(templates) == 0 {
+		fmt.Println("No templates found. Save one with 'envswitch template save <env> <template>'")
+		return nil
+	}
+
+	for _, tmpl := range templates {
+		fmt.Printf("%s", tmpl.Name)
+		if tmpl.Description != "" {
+			fmt.Printf(" - %s", tmpl.Description)
+		}
+		fmt.Println()
+		tools := "none"
+		if len(tmpl.Tools) > 0 {
+			tools = strings.Join(tmpl.Tools, ", ")
+		}
+		fmt.Printf("  Tools: %s\n", tools)
+		if len(tmpl.EnvVars) > 0 {
+			keys := make([]string, 0, len(tmpl.EnvVars))
+			for _, templateVar := range tmpl.EnvVars {
+				key := templateVar.Key
+				if templateVar.Secret {
+					key += " (secret)"
+				}
+				keys = append(keys, key)
+			}
+			fmt.Printf("  Variables: %s\n", strings.Join(keys, ", "))
+		}
+		if tmpl.Source != "" {
+			fmt.Printf("  Saved from: %s\n", tmpl.Source)
+		}
+	}
+	return nil
+}
+
+func runTemplateDelete(cmd *cobra.Command, args []string) error {
+	if err := environment.DeleteTemplate(args[0]); err != nil {
+		return err
+	}
+	fmt.Printf("✅ Deleted template '%s'\n", args[0])
+	return nil
+}
+
+// variableValues returns the values of template variables: the ones given
+// as KEY=value, the defaults, and the values asked on in for secrets
+func variableValues(vars []environment.TemplateVar, assignments []string, in io.Reader) (map[string]string, error) {
+	values := make(map[string]string)
+	for _, assignment := range assignments {
+		key, value, ok := strings.Cut(assignment, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid --set '%s': expected KEY=value", assignment)
+		}
+		if !hasTemplateVar(vars, key) {
+			return nil, fmt.Errorf("unknown variable %s", key)
+		}
+		values[key] = value
+	}
+
+	reader := bufio.NewReader(in)
+	for _, templateVar := range vars {
+		if _, ok := values[templateVar.Key]; ok {
+			continue
+		}
+		if !templateVar.Secret {
+			values[templateVar.Key] = templateVar.Default
+			continue
+		}
+
+		fmt.Printf("Value for %s (secret): ", templateVar.Key)
+		line, err := reader.ReadString('\n')
+		value := strings.TrimSpace(line)
+		if value == "" {
+			if err != nil {
+				fmt.Println()
+			}
+			return nil, fmt.Errorf("missing value for %s (use --set %s=...)", templateVar.Key, templateVar.Key)
+		}
+		values[templateVar.Key] = value
+	}
+	return values, nil
+}
+
+func hasTemplateVar(vars []environment.TemplateVar, key string) bool {
+	for _, templateVar := range vars {
+		if templateVar.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// setTemplateVars sets the variables of vars that have a value in env,
+// marking the secret ones
+func setTemplateVars(env *environment.Environment, vars []environment.TemplateVar, values map[string]string) error {
+	for _, templateVar := range vars {
+		value, ok := values[templateVar.Key]
+		if !ok {
+			continue
+		}
+		if templateVar.Secret {
+			env.SetEnvVarSecret(templateVar.Key, true)
+		}
+		if err := env.SetEnvVar(templateVar.Key, value); err != nil {
+			return fmt.Errorf("failed to set %s: %w", templateVar.Key, err)
+		}
+	}
+	return nil
+}
+
+// completeTemplateNames completes the names of the templates
+func completeTemplateNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	templates, err := environment.ListTemplates()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	names := make([]string, 0, len(templates))
+	for _, tmpl := range templates {
+		names = append(names, tmpl.Name)
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeTemplateSaveArgs completes the environment, then the templates
+// that can be replaced
+func completeTemplateSaveArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	switch len(args) {
+	case 0:
+		return completeEnvironmentNames(cmd, args, toComplete)
+	case 1:
+		return completeTemplateNames(cmd, nil, toComplete)
+	default:
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+}
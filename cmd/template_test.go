@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hugofrely/envswitch/internal/keyring"
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+func TestTemplateCommands(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+	keyring.MockInit() // secret values are encrypted with a key kept in the keyring
+
+	envsDir := filepath.Join(tempHome, ".envswitch", "environments")
+	require.NoError(t, os.MkdirAll(envsDir, 0755))
+
+	source := createEnvWithVars(t, envsDir, "client-a", map[string]string{"REGION": "eu-west-1", "API_TOKEN": "s3cr3t"})
+	source.SetEnvVarSecret("API_TOKEN", true)
+	source.Tools["kubectl"] = environment.ToolConfig{Enabled: true, SnapshotPath: "snapshots/kubectl"}
+	require.NoError(t, source.Save())
+
+	t.Run("saves a template", func(t *testing.T) {
+		output := captureStdout(t, func() {
+			require.NoError(t, runTemplateSave(templateSaveCmd, []string{"client-a", "client"}))
+		})
+		assert.Contains(t, output, "1 secret variable(s) saved without their value")
+
+		err := runTemplateSave(templateSaveCmd, []string{"client-a", "client"})
+		assert.ErrorContains(t, err, "already exists")
+
+		templateSaveForce = true
+		defer func() { templateSaveForce = false }()
+		require.NoError(t, runTemplateSave(templateSaveCmd, []string{"client-a", "client"}))
+	})
+
+	t.Run("lists templates", func(t *testing.T) {
+		output := captureStdout(t, func() {
+			require.NoError(t, runTemplateList(templateListCmd, nil))
+		})
+		assert.Contains(t, output, "client")
+		assert.Contains(t, output, "Tools: kubectl")
+		assert.Contains(t, output, "API_TOKEN (secret), REGION")
+	})
+
+	t.Run("asks for secret values", func(t *testing.T) {
+		tmpl, err := environment.LoadTemplate("client")
+		require.NoError(t, err)
+
+		values, err := variableValues(tmpl.EnvVars, nil, strings.NewReader("t0ken\n"))
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"API_TOKEN": "t0ken", "REGION": "eu-west-1"}, values)
+
+		values, err = variableValues(tmpl.EnvVars, []string{"REGION=us-east-1", "API_TOKEN=given"}, strings.NewReader(""))
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"API_TOKEN": "given", "REGION": "us-east-1"}, values)
+
+		_, err = variableValues(tmpl.EnvVars, nil, strings.NewReader(""))
+		assert.ErrorContains(t, err, "missing value for API_TOKEN")
+
+		_, err = variableValues(tmpl.EnvVars, []string{"OTHER=1"}, strings.NewReader(""))
+		assert.ErrorContains(t, err, "unknown variable OTHER")
+	})
+
+	t.Run("creates an environment from a template", func(t *testing.T) {
+		createTemplate = "client"
+		createSet = []string{"API_TOKEN=t0ken"}
+		defer func() {
+			createTemplate = ""
+			createSet = nil
+		}()
+
+		captureStdout(t, func() {
+			require.NoError(t, runCreate(createCmd, []string{"client-b"}))
+		})
+
+		env, err := environment.LoadEnvironment("client-b")
+		require.NoError(t, err)
+		assert.True(t, env.Tools["kubectl"].Enabled)
+		assert.False(t, env.Tools["aws"].Enabled)
+		assert.True(t, env.IsSecretEnvVar("API_TOKEN"))
+
+		value, ok, err := env.GetEnvVar("API_TOKEN")
+		require.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, "t0ken", value)
+		value, _, err = env.GetEnvVar("REGION")
+		require.NoError(t, err)
+		assert.Equal(t, "eu-west-1", value)
+	})
+
+	t.Run("does not create an environment when a value is missing", func(t *testing.T) {
+		createTemplate = "client"
+		defer func() { createTemplate = "" }()
+
+		oldStdin := os.Stdin
+		devNull, err := os.Open(os.DevNull)
+		require.NoError(t, err)
+		defer devNull.Close()
+		os.Stdin = devNull
+		defer func() { os.Stdin = oldStdin }()
+
+		captureStdout(t, func() {
+			err = runCreate(createCmd, []string{"client-c"})
+		})
+		assert.ErrorContains(t, err, "missing value for API_TOKEN")
+		assert.NoDirExists(t, filepath.Join(envsDir, "client-c"))
+	})
+
+	t.Run("deletes a template", func(t *testing.T) {
+		require.NoError(t, runTemplateDelete(templateDeleteCmd, []string{"client"}))
+		assert.Error(t, runTemplateDelete(templateDeleteCmd, []string{"client"}))
+	})
+}
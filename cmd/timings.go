@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hugofrely/envswitch/internal/history"
+)
+
+// Phases of a switch, as recorded in its history entry
+const (
+	phaseBackup    = "backup"
+	phaseSave      = "save"
+	phasePreHooks  = "pre-hooks"
+	phaseRestore   = "restore"
+	phasePostHooks = "post-hooks"
+)
+
+// switchTimings records in a history entry how long each phase of a switch
+// takes, and each tool saved or restored
+type switchTimings struct {
+	mu    sync.Mutex // tools finish on several workers
+	entry *history.SwitchEntry
+}
+
+func (t *switchTimings) record(phase, toolName string, elapsed time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.entry.Timings = append(t.entry.Timings, history.PhaseTiming{
+		Phase:      phase,
+		Tool:       toolName,
+		DurationMs: elapsed.Milliseconds(),
+	})
+}
+
+// start returns the function recording the time phase took since start
+func (t *switchTimings) start(phase string) func() {
+	begin := time.Now()
+	return func() {
+		t.record(phase, "", time.Since(begin))
+	}
+}
+
+// toolProgress returns progress, also recording the time of each tool of
+// phase
+func (t *switchTimings) toolProgress(phase string, progress toolProgress) toolProgress {
+	return func(done, total int, toolName string, elapsed time.Duration) {
+		t.record(phase, toolName, elapsed)
+		if progress != nil {
+			progress(done, total, toolName, elapsed)
+		}
+	}
+}
+
+// printTimings prints the phases of a switch in order, each followed by its
+// tools from the slowest, then the total
+func printTimings(w io.Writer, timings []history.PhaseTiming, totalMs int64) error {
+	var phases []string
+	phaseMs := make(map[string]int64)
+	toolTimings := make(map[string][]history.PhaseTiming)
+	for _, timing := range timings {
+		if _, seen := phaseMs[timing.Phase]; !seen {
+			phases = append(phases, timing.Phase)
+			// A phase that failed half-way only has its tools
+			phaseMs[timing.Phase] = -1
+		}
+		if timing.Tool == "" {
+			phaseMs[timing.Phase] = timing.DurationMs
+		} else {
+			toolTimings[timing.Phase] = append(toolTimings[timing.Phase], timing)
+		}
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, phase := range phases {
+		duration := "-"
+		if phaseMs[phase] >= 0 {
+			duration = formatDuration(phaseMs[phase])
+		}
+		fmt.Fprintf(tw, "  %s\t%s\n", phase, duration)
+
+		tools := toolTimings[phase]
+		sort.SliceStable(tools, func(i, j int) bool {
+			return tools[i].DurationMs > tools[j].DurationMs
+		})
+		for _, timing := range tools {
+			fmt.Fprintf(tw, "    %s\t%s\n", timing.Tool, formatDuration(timing.DurationMs))
+		}
+	}
+	fmt.Fprintf(tw, "  total\t%s\n", formatDuration(totalMs))
+	return tw.Flush()
+}
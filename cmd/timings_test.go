@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hugofrely/envswitch/internal/history"
+)
+
+func TestSwitchTimings(t *testing.T) {
+	entry := &history.SwitchEntry{}
+	timings := &switchTimings{entry: entry}
+
+	done := timings.start(phaseRestore)
+	var calls []string
+	progress := timings.toolProgress(phaseRestore, func(done, total int, toolName string, elapsed time.Duration) {
+		calls = append(calls, toolName)
+	})
+	progress(1, 2, "git", 40*time.Millisecond)
+	progress(2, 2, "kubectl", 1200*time.Millisecond)
+	done()
+
+	require.Len(t, entry.Timings, 3)
+	assert.Equal(t, history.PhaseTiming{Phase: phaseRestore, Tool: "git", DurationMs: 40}, entry.Timings[0])
+	assert.Equal(t, history.PhaseTiming{Phase: phaseRestore, Tool: "kubectl", DurationMs: 1200}, entry.Timings[1])
+	assert.Equal(t, phaseRestore, entry.Timings[2].Phase)
+	assert.Empty(t, entry.Timings[2].Tool)
+	assert.Equal(t, []string{"git", "kubectl"}, calls)
+
+	t.Run("concurrent tools", func(t *testing.T) {
+		entry := &history.SwitchEntry{}
+		progress := (&switchTimings{entry: entry}).toolProgress(phaseSave, nil)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				progress(0, 20, "tool", time.Millisecond)
+			}()
+		}
+		wg.Wait()
+		assert.Len(t, entry.Timings, 20)
+	})
+}
+
+func TestPrintTimings(t *testing.T) {
+	timings := []history.PhaseTiming{
+		{Phase: phaseBackup, DurationMs: 300},
+		{Phase: phaseRestore, Tool: "git", DurationMs: 40},
+		{Phase: phaseRestore, Tool: "kubectl", DurationMs: 1200},
+		{Phase: phaseRestore, DurationMs: 1250},
+		{Phase: phaseSave, Tool: "aws", DurationMs: 80},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, printTimings(&buf, timings, 1800))
+
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	require.Len(t, lines, 7)
+	assert.Equal(t, []string{"backup", "300ms"}, strings.Fields(lines[0]))
+	assert.Equal(t, []string{"restore", "1.25s"}, strings.Fields(lines[1]))
+	assert.Equal(t, []string{"kubectl", "1.20s"}, strings.Fields(lines[2]))
+	assert.Equal(t, []string{"git", "40ms"}, strings.Fields(lines[3]))
+	assert.True(t, strings.HasPrefix(lines[2], "    "), "tools are indented under their phase")
+	// The save phase failed before it was recorded
+	assert.Equal(t, []string{"save", "-"}, strings.Fields(lines[4]))
+	assert.Equal(t, []string{"aws", "80ms"}, strings.Fields(lines[5]))
+	assert.Equal(t, []string{"total", "1.80s"}, strings.Fields(lines[6]))
+}
+
+func TestHistoryShowTimings(t *testing.T) {
+	entry := history.SwitchEntry{
+		Timestamp:  time.Now(),
+		From:       "dev",
+		To:         "prod",
+		Success:    true,
+		DurationMs: 900,
+		Timings: []history.PhaseTiming{
+			{Phase: phaseRestore, Tool: "gcloud", DurationMs: 700},
+			{Phase: phaseRestore, DurationMs: 750},
+		},
+	}
+
+	output := captureStdout(t, func() { displayHistoryEntry(&entry, true) })
+	assert.NotContains(t, output, "Timings:")
+
+	historyShowTimings = true
+	defer func() { historyShowTimings = false }()
+
+	output = captureStdout(t, func() { displayHistoryEntry(&entry, true) })
+	assert.Contains(t, output, "Timings:")
+	assert.Contains(t, output, "gcloud")
+	assert.Contains(t, output, "total")
+}
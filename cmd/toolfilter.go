@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hugofrely/envswitch/internal/history"
+	"github.com/hugofrely/envswitch/pkg/environment"
+	"github.com/hugofrely/envswitch/pkg/tools"
+)
+
+// toolFilter restricts an operation to some tools, as set by switch --only
+// and --skip. The zero value allows every tool.
+type toolFilter struct {
+	Only []string
+	Skip []string
+}
+
+// isEmpty reports whether the filter allows every tool
+func (f toolFilter) isEmpty() bool {
+	return len(f.Only) == 0 && len(f.Skip) == 0
+}
+
+// allows reports whether the filter lets a tool through
+func (f toolFilter) allows(name string) bool {
+	if len(f.Only) > 0 && !containsString(f.Only, name) {
+		return false
+	}
+	return !containsString(f.Skip, name)
+}
+
+// apply returns the tools of the registry the filter lets through
+func (f toolFilter) apply(registry map[string]tools.Tool) map[string]tools.Tool {
+	if f.isEmpty() {
+		return registry
+	}
+
+	filtered := make(map[string]tools.Tool)
+	for name, tool := range registry {
+		if f.allows(name) {
+			filtered[name] = tool
+		}
+	}
+	return filtered
+}
+
+// validate checks that the filter only names tools of the registry
+func (f toolFilter) validate(registry map[string]tools.Tool) error {
+	for _, name := range append(append([]string{}, f.Only...), f.Skip...) {
+		if _, exists := registry[name]; !exists {
+			var known []string
+			for toolName := range registry {
+				known = append(known, toolName)
+			}
+			sort.Strings(known)
+			return fmt.Errorf("unknown tool '%s' (available: %s)", name, strings.Join(known, ", "))
+		}
+	}
+	return nil
+}
+
+// String describes the filter, e.g. "only kubectl, gcloud"
+func (f toolFilter) String() string {
+	var parts []string
+	if len(f.Only) > 0 {
+		parts = append(parts, "only "+strings.Join(f.Only, ", "))
+	}
+	if len(f.Skip) > 0 {
+		parts = append(parts, "skipping "+strings.Join(f.Skip, ", "))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// liveToolFilter returns the filter of the switch that made env active. Tools
+// that switch left out still hold another environment's config, so they must
+// not be snapshotted into env.
+func liveToolFilter(env *environment.Environment) toolFilter {
+	if env == nil {
+		return toolFilter{}
+	}
+
+	hist, err := history.LoadHistory()
+	if err != nil {
+		return toolFilter{}
+	}
+	entry := hist.GetLastActivation(env.Name)
+	if entry == nil {
+		return toolFilter{}
+	}
+	return toolFilter{Only: entry.OnlyTools, Skip: entry.SkipTools}
+}
@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hugofrely/envswitch/internal/history"
+	"github.com/hugofrely/envswitch/pkg/environment"
+	"github.com/hugofrely/envswitch/pkg/tools"
+)
+
+func TestToolFilter(t *testing.T) {
+	registry := map[string]tools.Tool{
+		"kubectl": tools.NewKubectlTool(),
+		"gcloud":  tools.NewGCloudTool(),
+		"docker":  tools.NewDockerTool(),
+	}
+
+	t.Run("empty filter allows every tool", func(t *testing.T) {
+		filter := toolFilter{}
+		assert.True(t, filter.isEmpty())
+		assert.Len(t, filter.apply(registry), 3)
+		assert.Equal(t, "", filter.String())
+	})
+
+	t.Run("only", func(t *testing.T) {
+		filter := toolFilter{Only: []string{"kubectl", "gcloud"}}
+		filtered := filter.apply(registry)
+		assert.Len(t, filtered, 2)
+		assert.Contains(t, filtered, "kubectl")
+		assert.Contains(t, filtered, "gcloud")
+		assert.Equal(t, "only kubectl, gcloud", filter.String())
+	})
+
+	t.Run("skip", func(t *testing.T) {
+		filter := toolFilter{Skip: []string{"docker"}}
+		filtered := filter.apply(registry)
+		assert.Len(t, filtered, 2)
+		assert.NotContains(t, filtered, "docker")
+		assert.Equal(t, "skipping docker", filter.String())
+	})
+
+	t.Run("skip wins over only", func(t *testing.T) {
+		filter := toolFilter{Only: []string{"kubectl", "docker"}, Skip: []string{"docker"}}
+		assert.True(t, filter.allows("kubectl"))
+		assert.False(t, filter.allows("docker"))
+		assert.False(t, filter.allows("gcloud"))
+	})
+
+	t.Run("validate rejects unknown tools", func(t *testing.T) {
+		assert.NoError(t, toolFilter{Only: []string{"kubectl"}, Skip: []string{"docker"}}.validate(registry))
+
+		err := toolFilter{Skip: []string{"kubeclt"}}.validate(registry)
+		assert.ErrorContains(t, err, "unknown tool 'kubeclt' (available: docker, gcloud, kubectl)")
+	})
+}
+
+func TestSwitchToolFilter(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	envsDir := filepath.Join(tmpDir, ".envswitch", "environments")
+
+	for _, name := range []string{"personal", "work"} {
+		env := &environment.Environment{
+			Name:      name,
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+			Tools:     make(map[string]environment.ToolConfig),
+			EnvVars:   make(map[string]string),
+			Path:      filepath.Join(envsDir, name),
+		}
+		require.NoError(t, os.MkdirAll(env.Path, 0700))
+		require.NoError(t, env.Save())
+	}
+	require.NoError(t, environment.SetCurrentEnvironment("personal"))
+
+	defer func() {
+		switchOnly = nil
+		switchSkip = nil
+	}()
+
+	t.Run("rejects unknown tools", func(t *testing.T) {
+		switchOnly = []string{"not-a-tool"}
+		err := runSwitch(switchCmd, []string{"work"})
+		assert.ErrorContains(t, err, "unknown tool 'not-a-tool'")
+	})
+
+	t.Run("records the filter in history", func(t *testing.T) {
+		switchOnly = []string{"kubectl", "gcloud"}
+		switchSkip = []string{"docker"}
+		require.NoError(t, runSwitch(switchCmd, []string{"work"}))
+
+		hist, err := history.LoadHistory()
+		require.NoError(t, err)
+		entry := hist.GetLastActivation("work")
+		require.NotNil(t, entry)
+		assert.Equal(t, []string{"kubectl", "gcloud"}, entry.OnlyTools)
+		assert.Equal(t, []string{"docker"}, entry.SkipTools)
+
+		work, err := environment.LoadEnvironment("work")
+		require.NoError(t, err)
+		live := liveToolFilter(work)
+		assert.Equal(t, "only kubectl, gcloud, skipping docker", live.String())
+		assert.True(t, liveToolFilter(nil).isEmpty())
+	})
+}
+
+func TestSwitchToolFlagCompletion(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	for _, flag := range []string{"only", "skip"} {
+		t.Run(flag, func(t *testing.T) {
+			complete, ok := switchCmd.GetFlagCompletionFunc(flag)
+			require.True(t, ok)
+
+			// envswitch switch work --<flag> <TAB>: the environment is already given
+			names, directive := complete(switchCmd, []string{"work"}, "")
+			assert.Contains(t, names, "kubectl")
+			assert.Contains(t, names, "gcloud")
+			assert.Equal(t, cobra.ShellCompDirectiveNoFileComp, directive)
+		})
+	}
+}
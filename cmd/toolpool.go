@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hugofrely/envswitch/internal/config"
+	"github.com/hugofrely/envswitch/pkg/spinner"
+)
+
+// maxParallelTools bounds how many tools are snapshotted or restored at once
+const maxParallelTools = 4
+
+// toolProgress is called each time a tool finishes, with the number of tools
+// finished so far and the time the tool took
+type toolProgress func(done, total int, toolName string, elapsed time.Duration)
+
+// spinnerProgress returns a toolProgress that reports on a spinner, or nil
+// when there is no spinner
+func spinnerProgress(s *spinner.Spinner, action string) toolProgress {
+	if s == nil {
+		return nil
+	}
+	return func(done, total int, toolName string, _ time.Duration) {
+		s.Update(fmt.Sprintf("%s tools (%d/%d, %s done)...", action, done, total, toolName))
+	}
+}
+
+// toolTimeouts returns how long a tool may take, 0 for no limit
+type toolTimeouts func(toolName string) time.Duration
+
+// loadToolTimeouts returns the timeouts of tool_timeout and tool_timeouts in
+// config.yaml
+func loadToolTimeouts() toolTimeouts {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		cfg = config.DefaultConfig()
+	}
+	return func(toolName string) time.Duration {
+		timeout, err := cfg.ToolTimeoutFor(toolName)
+		if err != nil {
+			registryLog.Warn("Ignoring the timeout of %s: %v", toolName, err)
+			return 0
+		}
+		return timeout
+	}
+}
+
+// runToolsParallel calls fn for every tool name using a bounded worker pool,
+// each call bounded by the timeout of its tool when timeouts is not nil. It
+// returns the error of each tool that failed, keyed by tool name. Once ctx is
+// done no more tool is started, those left fail with the error of ctx.
+func runToolsParallel(ctx context.Context, toolNames []string, timeouts toolTimeouts, fn func(ctx context.Context, toolName string) error, progress toolProgress) map[string]error {
+	failures := make(map[string]error)
+	if len(toolNames) == 0 {
+		return failures
+	}
+
+	workers := maxParallelTools
+	if len(toolNames) < workers {
+		workers = len(toolNames)
+	}
+
+	jobs := make(chan string)
+	var (
+		mu   sync.Mutex
+		done int
+		wg   sync.WaitGroup
+	)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for toolName := range jobs {
+				start := time.Now()
+				err := runTool(ctx, toolName, timeouts, fn)
+				elapsed := time.Since(start)
+
+				mu.Lock()
+				if err != nil {
+					failures[toolName] = err
+				}
+				done++
+				if progress != nil {
+					progress(done, len(toolNames), toolName, elapsed)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+feed:
+	for i, toolName := range toolNames {
+		select {
+		case jobs <- toolName:
+		case <-ctx.Done():
+			mu.Lock()
+			for _, skipped := range toolNames[i:] {
+				failures[skipped] = ctx.Err()
+			}
+			mu.Unlock()
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	return failures
+}
+
+// runTool calls fn for a tool within its timeout. A tool that outlives it
+// fails with an error saying so, unless ctx itself is done.
+func runTool(ctx context.Context, toolName string, timeouts toolTimeouts, fn func(ctx context.Context, toolName string) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var timeout time.Duration
+	if timeouts != nil {
+		timeout = timeouts(toolName)
+	}
+	if timeout <= 0 {
+		return fn(ctx, toolName)
+	}
+
+	toolCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	err := fn(toolCtx, toolName)
+	if err != nil && ctx.Err() == nil && errors.Is(toolCtx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("timed out after %s: %w", timeout, err)
+	}
+	return err
+}
+
+// formatToolFailures formats per-tool errors as a single sorted line
+func formatToolFailures(failures map[string]error) string {
+	toolNames := make([]string, 0, len(failures))
+	for toolName := range failures {
+		toolNames = append(toolNames, toolName)
+	}
+	sort.Strings(toolNames)
+
+	parts := make([]string, 0, len(toolNames))
+	for _, toolName := range toolNames {
+		parts = append(parts, fmt.Sprintf("%s (%v)", toolName, failures[toolName]))
+	}
+	return strings.Join(parts, ", ")
+}
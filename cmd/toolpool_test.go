@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunToolsParallel(t *testing.T) {
+	t.Run("collects per-tool errors", func(t *testing.T) {
+		toolNames := []string{"git", "aws", "kubectl", "docker", "npm", "ssh"}
+
+		failures := runToolsParallel(context.Background(), toolNames, nil, func(_ context.Context, toolName string) error {
+			if toolName == "aws" || toolName == "docker" {
+				return errors.New("boom")
+			}
+			return nil
+		}, nil)
+
+		assert.Len(t, failures, 2)
+		assert.Contains(t, failures, "aws")
+		assert.Contains(t, failures, "docker")
+		assert.Equal(t, "aws (boom), docker (boom)", formatToolFailures(failures))
+	})
+
+	t.Run("bounds concurrency", func(t *testing.T) {
+		toolNames := []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"}
+
+		var running, peak int32
+		runToolsParallel(context.Background(), toolNames, nil, func(_ context.Context, toolName string) error {
+			current := atomic.AddInt32(&running, 1)
+			for {
+				old := atomic.LoadInt32(&peak)
+				if current <= old || atomic.CompareAndSwapInt32(&peak, old, current) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+			return nil
+		}, nil)
+
+		assert.LessOrEqual(t, int(peak), maxParallelTools)
+		assert.Greater(t, int(peak), 1)
+	})
+
+	t.Run("reports progress for every tool", func(t *testing.T) {
+		toolNames := []string{"git", "aws", "kubectl"}
+
+		var mu sync.Mutex
+		var reported []int
+		runToolsParallel(context.Background(), toolNames, nil, func(context.Context, string) error { return nil }, func(done, total int, toolName string, _ time.Duration) {
+			mu.Lock()
+			defer mu.Unlock()
+			assert.Equal(t, 3, total)
+			reported = append(reported, done)
+		})
+
+		assert.Equal(t, []int{1, 2, 3}, reported)
+	})
+
+	t.Run("handles no tools", func(t *testing.T) {
+		assert.Empty(t, runToolsParallel(context.Background(), nil, nil, func(context.Context, string) error { return nil }, nil))
+	})
+
+	t.Run("stops starting tools once canceled", func(t *testing.T) {
+		toolNames := []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var started int32
+		failures := runToolsParallel(ctx, toolNames, nil, func(ctx context.Context, toolName string) error {
+			if atomic.AddInt32(&started, 1) == 1 {
+				cancel()
+			}
+			<-ctx.Done()
+			return ctx.Err()
+		}, nil)
+
+		assert.Len(t, failures, len(toolNames))
+		assert.LessOrEqual(t, int(started), maxParallelTools)
+		for _, err := range failures {
+			assert.ErrorIs(t, err, context.Canceled)
+		}
+	})
+
+	t.Run("bounds each tool by its timeout", func(t *testing.T) {
+		timeouts := func(toolName string) time.Duration {
+			if toolName == "slow" {
+				return 10 * time.Millisecond
+			}
+			return 0
+		}
+
+		failures := runToolsParallel(context.Background(), []string{"slow", "fast"}, timeouts, func(ctx context.Context, toolName string) error {
+			if toolName == "fast" {
+				_, hasDeadline := ctx.Deadline()
+				assert.False(t, hasDeadline)
+				return nil
+			}
+			<-ctx.Done()
+			return ctx.Err()
+		}, nil)
+
+		require.Len(t, failures, 1)
+		assert.ErrorIs(t, failures["slow"], context.DeadlineExceeded)
+		assert.Contains(t, failures["slow"].Error(), "timed out after 10ms")
+	})
+}
@@ -0,0 +1,313 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/hugofrely/envswitch/pkg/environment"
+	"github.com/hugofrely/envswitch/pkg/tools"
+)
+
+var toolsEnv string
+
+var toolsCmd = &cobra.Command{
+	Use:   "tools",
+	Short: "Choose which tools an environment captures",
+	Long: `List, enable and disable the tools captured by an environment, the active
+one unless --env is given. Plugins are tools too.`,
+}
+
+var toolsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List tools with their state and last snapshot",
+	Long: `List every known tool with whether the environment captures it, whether
+it is installed on this machine and when its snapshot was last taken.
+
+Examples:
+  envswitch tools list
+  envswitch tools list --env work`,
+	Aliases: []string{"ls"},
+	Args:    cobra.NoArgs,
+	RunE:    runToolsList,
+}
+
+var toolsEnableCmd = &cobra.Command{
+	Use:   "enable <tool>",
+	Short: "Capture a tool in an environment",
+	Long: `Start capturing a tool in the environment. Its configuration is saved the
+next time you run 'envswitch save' or switch away from the environment.
+
+Examples:
+  envswitch tools enable kubectl
+  envswitch tools enable terraform --env work`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeToolNames,
+	RunE:              runToolsEnable,
+}
+
+var toolsDisableCmd = &cobra.Command{
+	Use:   "disable <tool>",
+	Short: "Stop capturing a tool in an environment",
+	Long: `Stop capturing and restoring a tool in the environment. Its existing
+snapshot is kept, so enabling the tool again restores it.
+
+Examples:
+  envswitch tools disable docker
+  envswitch tools disable docker --env personal`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeToolNames,
+	RunE:              runToolsDisable,
+}
+
+var toolsStrategyCmd = &cobra.Command{
+	Use:   "strategy <tool> [replace|merge]",
+	Short: "Choose whether a tool's snapshot replaces or merges into the live config",
+	Long: `Set how switching to the environment restores a tool, or show it without a
+strategy.
+
+  replace  The live configuration becomes the snapshot (default)
+  merge    The snapshot is merged into the live configuration: its entries
+           win, the live entries it does not have are kept
+
+Merging is supported by aws (profiles of config and credentials), gcloud
+(configurations) and kubectl (clusters, contexts and users of the
+kubeconfig). Their other files are copied from the snapshot, and live files
+the snapshot does not have are kept.
+
+Examples:
+  envswitch tools strategy aws merge
+  envswitch tools strategy kubectl merge --env work
+  envswitch tools strategy aws`,
+	Args:              cobra.RangeArgs(1, 2),
+	ValidArgsFunction: completeToolStrategyArgs,
+	RunE:              runToolsStrategy,
+}
+
+func init() {
+	rootCmd.AddCommand(toolsCmd)
+	toolsCmd.AddCommand(toolsListCmd)
+	toolsCmd.AddCommand(toolsEnableCmd)
+	toolsCmd.AddCommand(toolsDisableCmd)
+	toolsCmd.AddCommand(toolsStrategyCmd)
+
+	toolsCmd.PersistentFlags().StringVar(&toolsEnv, "env", "", "Environment to use (default: active environment)")
+	_ = toolsCmd.RegisterFlagCompletionFunc("env", completeEnvironmentFlag)
+}
+
+// toolListing describes a tool of an environment
+type toolListing struct {
+	Name         string
+	Enabled      bool
+	Known        bool // in the tool registry, plugins included
+	Installed    bool
+	LastSnapshot time.Time
+}
+
+func runToolsList(cmd *cobra.Command, args []string) error {
+	env, err := resolveEnvTarget(toolsEnv)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Tools of '%s':\n\n", env.Name)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TOOL\tSTATE\tINSTALLED\tLAST SNAPSHOT")
+	for _, tool := range listEnvironmentTools(env, getToolRegistry()) {
+		state := "disabled"
+		if tool.Enabled {
+			state = "enabled"
+		}
+		if !tool.Known {
+			state += " (unknown tool)"
+		}
+
+		installed := "no"
+		if tool.Installed {
+			installed = "yes"
+		}
+
+		lastSnapshot := "never"
+		if !tool.LastSnapshot.IsZero() {
+			lastSnapshot = formatTimeAgo(tool.LastSnapshot)
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", tool.Name, state, installed, lastSnapshot)
+	}
+	return w.Flush()
+}
+
+// listEnvironmentTools lists the registry tools and the tools of the
+// environment that are no longer in the registry, sorted by name
+func listEnvironmentTools(env *environment.Environment, registry map[string]tools.Tool) []toolListing {
+	names := make(map[string]bool)
+	for name := range registry {
+		names[name] = true
+	}
+	for name := range env.Tools {
+		names[name] = true
+	}
+
+	var listings []toolListing
+	for name := range names {
+		tool, known := registry[name]
+		config := env.Tools[name]
+		listings = append(listings, toolListing{
+			Name:         name,
+			Enabled:      config.Enabled,
+			Known:        known,
+			Installed:    known && tool.IsInstalled(),
+			LastSnapshot: toolSnapshotTime(env, name, config),
+		})
+	}
+	sort.Slice(listings, func(i, j int) bool { return listings[i].Name < listings[j].Name })
+	return listings
+}
+
+// toolSnapshotTime returns when the tool was last captured. Environments
+// saved before the time was recorded fall back to the snapshot directory.
+func toolSnapshotTime(env *environment.Environment, name string, config environment.ToolConfig) time.Time {
+	if !config.LastSnapshot.IsZero() {
+		return config.LastSnapshot
+	}
+	info, err := os.Stat(filepath.Join(env.Path, "snapshots", name))
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+func runToolsEnable(cmd *cobra.Command, args []string) error {
+	toolName := args[0]
+	if _, known := getToolRegistry()[toolName]; !known {
+		return fmt.Errorf("unknown tool '%s' (run 'envswitch tools list' to see the available tools)", toolName)
+	}
+
+	env, err := resolveEnvTarget(toolsEnv)
+	if err != nil {
+		return err
+	}
+
+	if env.Tools[toolName].Enabled {
+		fmt.Printf("%s is already enabled in '%s'\n", toolName, env.Name)
+		return nil
+	}
+
+	config := enableEnvironmentTool(env, toolName)
+	if err := env.Save(); err != nil {
+		return fmt.Errorf("failed to save environment: %w", err)
+	}
+
+	fmt.Printf("✅ Enabled %s in '%s'\n", toolName, env.Name)
+	if toolSnapshotTime(env, toolName, config).IsZero() {
+		fmt.Printf("   Its configuration is captured the next time you save '%s' or switch away from it\n", env.Name)
+	}
+	return nil
+}
+
+// enableEnvironmentTool enables a tool in env without saving it, and returns
+// its configuration
+func enableEnvironmentTool(env *environment.Environment, toolName string) environment.ToolConfig {
+	config := env.Tools[toolName]
+	config.Enabled = true
+	if config.SnapshotPath == "" {
+		config.SnapshotPath = filepath.Join("snapshots", toolName)
+	}
+	if env.Tools == nil {
+		env.Tools = make(map[string]environment.ToolConfig)
+	}
+	env.Tools[toolName] = config
+	return config
+}
+
+func runToolsDisable(cmd *cobra.Command, args []string) error {
+	toolName := args[0]
+
+	env, err := resolveEnvTarget(toolsEnv)
+	if err != nil {
+		return err
+	}
+
+	config, exists := env.Tools[toolName]
+	if !exists {
+		if _, known := getToolRegistry()[toolName]; !known {
+			return fmt.Errorf("unknown tool '%s' (run 'envswitch tools list' to see the available tools)", toolName)
+		}
+	}
+	if !config.Enabled {
+		fmt.Printf("%s is already disabled in '%s'\n", toolName, env.Name)
+		return nil
+	}
+
+	config.Enabled = false
+	env.Tools[toolName] = config
+
+	if err := env.Save(); err != nil {
+		return fmt.Errorf("failed to save environment: %w", err)
+	}
+
+	fmt.Printf("✅ Disabled %s in '%s' (its snapshot is kept)\n", toolName, env.Name)
+	return nil
+}
+
+func runToolsStrategy(cmd *cobra.Command, args []string) error {
+	toolName := args[0]
+	tool, known := getToolRegistry()[toolName]
+	if !known {
+		return fmt.Errorf("unknown tool '%s' (run 'envswitch tools list' to see the available tools)", toolName)
+	}
+
+	env, err := resolveEnvTarget(toolsEnv)
+	if err != nil {
+		return err
+	}
+
+	config := env.Tools[toolName]
+	if len(args) == 1 {
+		strategy := config.RestoreStrategy
+		if strategy == "" {
+			strategy = environment.RestoreStrategyReplace
+		}
+		fmt.Printf("%s in '%s': %s\n", toolName, env.Name, strategy)
+		return nil
+	}
+
+	strategy := args[1]
+	if err := environment.ValidateRestoreStrategy(strategy); err != nil {
+		return err
+	}
+	if _, ok := tool.(tools.Merger); strategy == environment.RestoreStrategyMerge && !ok {
+		return fmt.Errorf("%s cannot merge snapshots, only aws, gcloud and kubectl can", toolName)
+	}
+
+	config.RestoreStrategy = strategy
+	if strategy == environment.RestoreStrategyReplace {
+		config.RestoreStrategy = ""
+	}
+	if env.Tools == nil {
+		env.Tools = make(map[string]environment.ToolConfig)
+	}
+	env.Tools[toolName] = config
+	if err := env.Save(); err != nil {
+		return fmt.Errorf("failed to save environment: %w", err)
+	}
+
+	fmt.Printf("✅ %s in '%s' now uses the %s restore strategy\n", toolName, env.Name, strategy)
+	return nil
+}
+
+// completeToolStrategyArgs completes the tool, then the restore strategy
+func completeToolStrategyArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) == 0 {
+		return completeToolNames(cmd, args, toComplete)
+	}
+	if len(args) == 1 {
+		return []string{environment.RestoreStrategyReplace, environment.RestoreStrategyMerge}, cobra.ShellCompDirectiveNoFileComp
+	}
+	return nil, cobra.ShellCompDirectiveNoFileComp
+}
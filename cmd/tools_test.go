@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hugofrely/envswitch/pkg/environment"
+	"github.com/hugofrely/envswitch/pkg/tools"
+)
+
+func TestRunToolsEnableDisable(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+
+	envsDir := filepath.Join(tempHome, ".envswitch", "environments")
+	require.NoError(t, os.MkdirAll(envsDir, 0755))
+	createEnvWithVars(t, envsDir, "work", nil)
+
+	toolsEnv = "work"
+	defer func() { toolsEnv = "" }()
+
+	t.Run("enables a registry tool", func(t *testing.T) {
+		out := captureStdout(t, func() {
+			require.NoError(t, runToolsEnable(toolsEnableCmd, []string{"kubectl"}))
+		})
+		assert.Contains(t, out, "✅ Enabled kubectl in 'work'")
+		assert.Contains(t, out, "captured the next time")
+
+		env, err := environment.LoadEnvironment("work")
+		require.NoError(t, err)
+		assert.True(t, env.Tools["kubectl"].Enabled)
+		assert.Equal(t, filepath.Join("snapshots", "kubectl"), env.Tools["kubectl"].SnapshotPath)
+
+		out = captureStdout(t, func() {
+			require.NoError(t, runToolsEnable(toolsEnableCmd, []string{"kubectl"}))
+		})
+		assert.Contains(t, out, "already enabled")
+	})
+
+	t.Run("rejects unknown tools", func(t *testing.T) {
+		err := runToolsEnable(toolsEnableCmd, []string{"not-a-tool"})
+		assert.ErrorContains(t, err, "unknown tool 'not-a-tool'")
+
+		err = runToolsDisable(toolsDisableCmd, []string{"not-a-tool"})
+		assert.ErrorContains(t, err, "unknown tool 'not-a-tool'")
+	})
+
+	t.Run("disables a tool and keeps its snapshot path", func(t *testing.T) {
+		out := captureStdout(t, func() {
+			require.NoError(t, runToolsDisable(toolsDisableCmd, []string{"kubectl"}))
+		})
+		assert.Contains(t, out, "✅ Disabled kubectl in 'work'")
+
+		env, err := environment.LoadEnvironment("work")
+		require.NoError(t, err)
+		assert.False(t, env.Tools["kubectl"].Enabled)
+		assert.Equal(t, filepath.Join("snapshots", "kubectl"), env.Tools["kubectl"].SnapshotPath)
+
+		out = captureStdout(t, func() {
+			require.NoError(t, runToolsDisable(toolsDisableCmd, []string{"docker"}))
+		})
+		assert.Contains(t, out, "already disabled")
+	})
+}
+
+func TestListEnvironmentTools(t *testing.T) {
+	envPath := t.TempDir()
+	snapshotTime := time.Now().Add(-2 * time.Hour)
+
+	env := &environment.Environment{
+		Name: "work",
+		Path: envPath,
+		Tools: map[string]environment.ToolConfig{
+			"demo":    {Enabled: true, LastSnapshot: snapshotTime},
+			"removed": {Enabled: true},
+		},
+	}
+	require.NoError(t, os.MkdirAll(filepath.Join(envPath, "snapshots", "removed"), 0755))
+
+	registry := map[string]tools.Tool{
+		"demo":  tools.NewGenericTool("demo", filepath.Join(envPath, "missing")),
+		"other": tools.NewGenericTool("other", filepath.Join(envPath, "missing")),
+	}
+
+	listings := listEnvironmentTools(env, registry)
+	require.Len(t, listings, 3)
+
+	assert.Equal(t, "demo", listings[0].Name)
+	assert.True(t, listings[0].Enabled)
+	assert.True(t, listings[0].Known)
+	assert.Equal(t, snapshotTime, listings[0].LastSnapshot)
+
+	assert.Equal(t, "other", listings[1].Name)
+	assert.False(t, listings[1].Enabled)
+	assert.True(t, listings[1].LastSnapshot.IsZero())
+
+	// Tools no longer in the registry are listed, with the snapshot directory time
+	assert.Equal(t, "removed", listings[2].Name)
+	assert.False(t, listings[2].Known)
+	assert.False(t, listings[2].LastSnapshot.IsZero())
+}
+
+func TestRunToolsList(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+
+	envsDir := filepath.Join(tempHome, ".envswitch", "environments")
+	require.NoError(t, os.MkdirAll(envsDir, 0755))
+	env := createEnvWithVars(t, envsDir, "work", nil)
+	env.Tools["git"] = environment.ToolConfig{Enabled: true, LastSnapshot: time.Now().Add(-time.Hour)}
+	require.NoError(t, env.Save())
+
+	toolsEnv = "work"
+	defer func() { toolsEnv = "" }()
+
+	out := captureStdout(t, func() {
+		require.NoError(t, runToolsList(toolsListCmd, nil))
+	})
+	assert.Contains(t, out, "Tools of 'work':")
+	assert.Regexp(t, `git\s+enabled\s+(yes|no)\s+1 hour ago`, out)
+	assert.Regexp(t, `docker\s+disabled\s+(yes|no)\s+never`, out)
+}
+
+func TestRunToolsStrategy(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+
+	envsDir := filepath.Join(tempHome, ".envswitch", "environments")
+	require.NoError(t, os.MkdirAll(envsDir, 0755))
+	createEnvWithVars(t, envsDir, "work", nil)
+
+	toolsEnv = "work"
+	defer func() { toolsEnv = "" }()
+
+	out := captureStdout(t, func() {
+		require.NoError(t, runToolsStrategy(toolsStrategyCmd, []string{"aws"}))
+	})
+	assert.Contains(t, out, "aws in 'work': replace")
+
+	out = captureStdout(t, func() {
+		require.NoError(t, runToolsStrategy(toolsStrategyCmd, []string{"aws", "merge"}))
+	})
+	assert.Contains(t, out, "now uses the merge restore strategy")
+
+	env, err := environment.LoadEnvironment("work")
+	require.NoError(t, err)
+	assert.Equal(t, environment.RestoreStrategyMerge, env.Tools["aws"].RestoreStrategy)
+
+	captureStdout(t, func() {
+		require.NoError(t, runToolsStrategy(toolsStrategyCmd, []string{"aws", "replace"}))
+	})
+	env, err = environment.LoadEnvironment("work")
+	require.NoError(t, err)
+	assert.Empty(t, env.Tools["aws"].RestoreStrategy)
+
+	assert.ErrorContains(t, runToolsStrategy(toolsStrategyCmd, []string{"ssh", "merge"}), "ssh cannot merge snapshots")
+	assert.ErrorContains(t, runToolsStrategy(toolsStrategyCmd, []string{"aws", "append"}), "invalid restore strategy 'append'")
+	assert.ErrorContains(t, runToolsStrategy(toolsStrategyCmd, []string{"not-a-tool"}), "unknown tool")
+}
@@ -0,0 +1,343 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/hugofrely/envswitch/internal/history"
+	"github.com/hugofrely/envswitch/internal/tui"
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+// Rows of the dashboard lists
+const (
+	uiMaxEnvironments = 15
+	uiMaxHistory      = 5
+)
+
+var uiCmd = &cobra.Command{
+	Use:   "ui",
+	Short: "Browse, switch, save and diff environments in a dashboard",
+	Long: `Show a dashboard of the environments with the age of their tool snapshots,
+the active environment and the recent switches.
+
+Keys:
+  ↑/↓ or k/j  select an environment
+  enter       switch to the selected environment
+  s           save the live state into the active environment
+  d           diff the snapshots of the selected environment with the live state
+  r           reload
+  q or esc    quit
+
+Switches, saves and diffs print their output as the commands do, then return
+to the dashboard.
+
+Examples:
+  envswitch ui`,
+	Args: cobra.NoArgs,
+	RunE: runUI,
+}
+
+func init() {
+	rootCmd.AddCommand(uiCmd)
+}
+
+// uiAction is what a key asks the dashboard to do
+type uiAction int
+
+const (
+	uiNone uiAction = iota
+	uiQuit
+	uiSwitch
+	uiSave
+	uiDiff
+	uiReload
+)
+
+// dashboard is the state of 'envswitch ui'
+type dashboard struct {
+	envs    []*environment.Environment
+	current string
+	history []history.SwitchEntry // most recent first
+	cursor  int
+	// status is the outcome of the last action
+	status string
+}
+
+func runUI(cmd *cobra.Command, args []string) error {
+	d := &dashboard{}
+	if err := d.reload(); err != nil {
+		return err
+	}
+	if len(d.envs) == 0 {
+		return fmt.Errorf("no environments found, create one with 'envswitch create <name>'")
+	}
+
+	term, err := tui.Open()
+	if err != nil {
+		return err
+	}
+	defer term.Close()
+
+	// Draw on the alternate screen, the terminal is left as it was
+	fmt.Fprint(term.Out, "\033[?1049h\033[?25l")
+	defer fmt.Fprint(term.Out, "\033[?25h\033[?1049l")
+
+	for {
+		fmt.Fprint(term.Out, "\033[H\033[2J")
+		d.render(term.Out)
+
+		keys, err := tui.ReadKeys(term.In)
+		if err != nil {
+			return nil
+		}
+
+		for _, key := range keys {
+			action := d.handle(key)
+			if action == uiQuit {
+				return nil
+			}
+			if action == uiNone {
+				continue
+			}
+
+			if action != uiReload {
+				d.status = runUIAction(term, d, action)
+			}
+			if err := d.reload(); err != nil {
+				d.status = fmt.Sprintf("Failed to reload: %v", err)
+			}
+			break
+		}
+	}
+}
+
+// runUIAction runs an action with the terminal back in its normal mode, and
+// returns the status line describing its outcome
+func runUIAction(term *tui.Terminal, d *dashboard, action uiAction) string {
+	term.Suspend()
+	fmt.Fprint(term.Out, "\033[H\033[2J\033[?25h")
+
+	env := d.selected()
+	var status string
+	var err error
+	switch action {
+	case uiSwitch:
+		if env.Name == d.current {
+			status = fmt.Sprintf("Already on '%s'", env.Name)
+			break
+		}
+		if err = switchEnvironment(env.Name); err == nil {
+			status = fmt.Sprintf("Switched to '%s'", env.Name)
+		}
+	case uiSave:
+		if d.current == "" {
+			status = "No active environment to save"
+			break
+		}
+		if err = runSave(saveCmd, nil); err == nil {
+			status = fmt.Sprintf("Saved '%s'", d.current)
+		}
+	case uiDiff:
+		printDiffs(env.Name, diffEnvironment(env, ""))
+		status = fmt.Sprintf("Diffed '%s'", env.Name)
+	}
+	if err != nil {
+		status = fmt.Sprintf("Failed: %v", err)
+		fmt.Println(status)
+	}
+
+	fmt.Fprint(term.Out, "\nPress any key to return to the dashboard")
+	if resumeErr := term.Resume(); resumeErr == nil {
+		_, _ = tui.ReadKeys(term.In)
+	}
+	fmt.Fprint(term.Out, "\033[?25l")
+	return status
+}
+
+// reload reads the environments and history again, keeping the selection
+func (d *dashboard) reload() error {
+	selected := ""
+	if env := d.selected(); env != nil {
+		selected = env.Name
+	}
+
+	envs, err := environment.ListEnvironments()
+	if err != nil {
+		return err
+	}
+	sort.SliceStable(envs, func(i, j int) bool { return envs[i].Name < envs[j].Name })
+	d.envs = envs
+
+	d.current = ""
+	if current, err := environment.GetCurrentEnvironment(); err == nil && current != nil {
+		d.current = current.Name
+	}
+	if selected == "" {
+		selected = d.current
+	}
+
+	d.cursor = 0
+	for i, env := range d.envs {
+		if env.Name == selected {
+			d.cursor = i
+		}
+	}
+
+	d.history = nil
+	if entries, _, err := history.LoadRecent(uiMaxHistory); err == nil {
+		for i := len(entries) - 1; i >= 0; i-- {
+			d.history = append(d.history, entries[i])
+		}
+	}
+	return nil
+}
+
+// selected returns the environment under the cursor
+func (d *dashboard) selected() *environment.Environment {
+	if d.cursor < 0 || d.cursor >= len(d.envs) {
+		return nil
+	}
+	return d.envs[d.cursor]
+}
+
+// handle moves the cursor, or returns the action asked by a key
+func (d *dashboard) handle(key tui.Key) uiAction {
+	switch key.Kind {
+	case tui.KeyCancel:
+		return uiQuit
+	case tui.KeyEnter:
+		return uiSwitch
+	case tui.KeyUp:
+		d.move(-1)
+	case tui.KeyDown:
+		d.move(1)
+	case tui.KeyRune:
+		switch key.Rune {
+		case 'q':
+			return uiQuit
+		case 'k':
+			d.move(-1)
+		case 'j':
+			d.move(1)
+		case 's':
+			return uiSave
+		case 'd':
+			return uiDiff
+		case 'r':
+			return uiReload
+		}
+	}
+	return uiNone
+}
+
+func (d *dashboard) move(offset int) {
+	cursor := d.cursor + offset
+	if cursor >= 0 && cursor < len(d.envs) {
+		d.cursor = cursor
+	}
+}
+
+// render draws the dashboard
+func (d *dashboard) render(out io.Writer) {
+	active := d.current
+	if active == "" {
+		active = "none"
+	}
+	fmt.Fprintf(out, "EnvSwitch · active: %s\n\n", active)
+
+	// Scroll so that the cursor is always shown
+	start := 0
+	if d.cursor >= uiMaxEnvironments {
+		start = d.cursor - uiMaxEnvironments + 1
+	}
+	end := start + uiMaxEnvironments
+	if end > len(d.envs) {
+		end = len(d.envs)
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "    ENVIRONMENT\tSNAPSHOTS\tLAST USED")
+	for i := start; i < end; i++ {
+		env := d.envs[i]
+		pointer, mark := "  ", "  "
+		if i == d.cursor {
+			pointer = "> "
+		}
+		if env.Name == d.current {
+			mark = "* "
+		}
+
+		lastUsed := "never"
+		if !env.LastUsed.IsZero() {
+			lastUsed = formatTimeAgo(env.LastUsed)
+		}
+		fmt.Fprintf(w, "%s%s%s\t%s\t%s\n", pointer, mark, env.Name, snapshotAges(env, time.Now()), lastUsed)
+	}
+	_ = w.Flush()
+	if len(d.envs) > end-start {
+		fmt.Fprintf(out, "    (%d/%d)\n", d.cursor+1, len(d.envs))
+	}
+
+	fmt.Fprint(out, "\nRecent switches\n")
+	if len(d.history) == 0 {
+		fmt.Fprint(out, "  none yet\n")
+	}
+	for _, entry := range d.history {
+		mark := "✓"
+		if !entry.Success {
+			mark = "✗"
+		}
+		fmt.Fprintf(out, "  %s %s  %s → %s  %s\n", mark, entry.Timestamp.Format("2006-01-02 15:04"), entry.From, entry.To, formatDuration(entry.DurationMs))
+	}
+
+	if d.status != "" {
+		fmt.Fprintf(out, "\n%s\n", d.status)
+	}
+	fmt.Fprint(out, "\n↑/↓ select · enter switch · s save · d diff · r reload · q quit")
+}
+
+// snapshotAges describes how old the snapshot of each enabled tool of env is
+func snapshotAges(env *environment.Environment, now time.Time) string {
+	var toolNames []string
+	for toolName, toolConfig := range env.Tools {
+		if toolConfig.Enabled {
+			toolNames = append(toolNames, toolName)
+		}
+	}
+	if len(toolNames) == 0 {
+		return "-"
+	}
+	sort.Strings(toolNames)
+
+	ages := make([]string, 0, len(toolNames))
+	for _, toolName := range toolNames {
+		lastSnapshot := env.Tools[toolName].LastSnapshot
+		if lastSnapshot.IsZero() {
+			ages = append(ages, toolName+" never")
+			continue
+		}
+		ages = append(ages, toolName+" "+shortAge(now.Sub(lastSnapshot)))
+	}
+	return strings.Join(ages, " · ")
+}
+
+// shortAge formats a duration in its largest unit: 5m, 3h, 12d
+func shortAge(age time.Duration) string {
+	switch {
+	case age < time.Minute:
+		return "now"
+	case age < time.Hour:
+		return fmt.Sprintf("%dm", int(age.Minutes()))
+	case age < 48*time.Hour:
+		return fmt.Sprintf("%dh", int(age.Hours()))
+	default:
+		return fmt.Sprintf("%dd", int(age.Hours()/24))
+	}
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hugofrely/envswitch/internal/history"
+	"github.com/hugofrely/envswitch/internal/tui"
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+func TestDashboard(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+	envsDir := filepath.Join(tempHome, ".envswitch", "environments")
+
+	work := createEnvWithVars(t, envsDir, "work", nil)
+	work.Tools["gcloud"] = environment.ToolConfig{Enabled: true, LastSnapshot: time.Now().Add(-3 * time.Hour)}
+	work.Tools["kubectl"] = environment.ToolConfig{Enabled: true}
+	require.NoError(t, work.Save())
+	createEnvWithVars(t, envsDir, "personal", nil)
+	require.NoError(t, environment.SetCurrentEnvironment("work"))
+
+	hist := &history.History{}
+	require.NoError(t, hist.AddEntry(&history.SwitchEntry{Timestamp: time.Now(), From: "personal", To: "work", Success: true, DurationMs: 1200}))
+
+	d := &dashboard{}
+	require.NoError(t, d.reload())
+	assert.Equal(t, "work", d.current)
+	assert.Equal(t, "work", d.selected().Name, "the active environment is selected")
+	require.Len(t, d.history, 1)
+
+	var out bytes.Buffer
+	d.render(&out)
+	assert.Contains(t, out.String(), "active: work")
+	assert.Contains(t, out.String(), "> * work")
+	assert.Contains(t, out.String(), "gcloud 3h · kubectl never")
+	assert.Contains(t, out.String(), "personal → work  1.20s")
+
+	t.Run("keys", func(t *testing.T) {
+		assert.Equal(t, uiNone, d.handle(tui.Key{Kind: tui.KeyUp}))
+		assert.Equal(t, "personal", d.selected().Name)
+		assert.Equal(t, uiNone, d.handle(tui.Key{Kind: tui.KeyUp}), "stays on the first environment")
+		assert.Equal(t, "personal", d.selected().Name)
+		d.handle(tui.Key{Kind: tui.KeyRune, Rune: 'j'})
+		assert.Equal(t, "work", d.selected().Name)
+
+		assert.Equal(t, uiSwitch, d.handle(tui.Key{Kind: tui.KeyEnter}))
+		assert.Equal(t, uiSave, d.handle(tui.Key{Kind: tui.KeyRune, Rune: 's'}))
+		assert.Equal(t, uiDiff, d.handle(tui.Key{Kind: tui.KeyRune, Rune: 'd'}))
+		assert.Equal(t, uiQuit, d.handle(tui.Key{Kind: tui.KeyCancel}))
+	})
+
+	t.Run("reload keeps the selection", func(t *testing.T) {
+		d.handle(tui.Key{Kind: tui.KeyUp})
+		require.NoError(t, d.reload())
+		assert.Equal(t, "personal", d.selected().Name)
+	})
+}
+
+func TestShortAge(t *testing.T) {
+	assert.Equal(t, "now", shortAge(10*time.Second))
+	assert.Equal(t, "5m", shortAge(5*time.Minute))
+	assert.Equal(t, "30h", shortAge(30*time.Hour))
+	assert.Equal(t, "3d", shortAge(80*time.Hour))
+}
@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/spf13/cobra"
+
+	"github.com/hugofrely/envswitch/pkg/environment"
+	"github.com/hugofrely/envswitch/pkg/tools"
+)
+
+var verifyAll bool
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify [env]",
+	Short: "Check snapshots against their checksums",
+	Long: `Compare the snapshot files of an environment, the active one by default,
+with the SHA-256 checksums recorded when they were taken. Files that were
+modified, removed or added since are reported, as corrupted or tampered.
+
+Switching also verifies the snapshots it restores: mismatches are warnings,
+or abort the switch with 'envswitch switch --strict'.
+
+Examples:
+  envswitch verify
+  envswitch verify work
+  envswitch verify --all`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeEnvironmentNames,
+	RunE:              runVerify,
+}
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+	verifyCmd.Flags().BoolVar(&verifyAll, "all", false, "Verify every environment")
+}
+
+func runVerify(cmd *cobra.Command, args []string) error {
+	name := ""
+	if len(args) > 0 {
+		name = args[0]
+	}
+	if name != "" && verifyAll {
+		return fmt.Errorf("cannot combine an environment name with --all")
+	}
+
+	envs, err := resolveEnvTargets(name, verifyAll)
+	if err != nil {
+		return err
+	}
+
+	var failed []string
+	for i, env := range envs {
+		if i > 0 {
+			fmt.Println()
+		}
+		ok, err := printVerification(env)
+		if err != nil {
+			return fmt.Errorf("failed to verify '%s': %w", env.Name, err)
+		}
+		if !ok {
+			failed = append(failed, env.Name)
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("snapshot verification failed for %d environment(s)", len(failed))
+	}
+	return nil
+}
+
+// printVerification verifies the snapshots of env and prints the result of
+// each tool. It reports whether they all match.
+func printVerification(env *environment.Environment) (bool, error) {
+	report, err := env.VerifyChecksums()
+	if err != nil {
+		return false, err
+	}
+
+	fmt.Printf("Verifying snapshots of '%s'...\n", env.Name)
+	if len(report.Tools) == 0 {
+		fmt.Println("  No snapshots.")
+		return true, nil
+	}
+
+	for _, tool := range report.Tools {
+		switch {
+		case !tool.Recorded:
+			fmt.Printf("  ⚠️  %s: no checksums recorded (save the environment to record them)\n", tool.Tool)
+		case tool.OK():
+			fmt.Printf("  ✓ %s (%d file(s))\n", tool.Tool, tool.Verified)
+		default:
+			printChecksumFailure(tool)
+		}
+	}
+
+	if !report.OK() {
+		fmt.Printf("\n✗ Some snapshots of '%s' do not match their checksums\n", env.Name)
+		return false, nil
+	}
+	return true, nil
+}
+
+// printChecksumFailure prints the files of a tool snapshot that do not
+// match their checksums
+func printChecksumFailure(tool environment.ToolChecksumReport) {
+	fmt.Printf("  ✗ %s\n", tool.Tool)
+	for _, path := range tool.Modified {
+		fmt.Printf("      modified:   %s\n", path)
+	}
+	for _, path := range tool.Missing {
+		fmt.Printf("      missing:    %s\n", path)
+	}
+	for _, path := range tool.Unexpected {
+		fmt.Printf("      unexpected: %s\n", path)
+	}
+}
+
+// checkSnapshotIntegrity verifies the snapshots a switch is about to restore.
+// Mismatches are printed as a warning, or abort the switch with --strict.
+func checkSnapshotIntegrity(env *environment.Environment, registry map[string]tools.Tool) error {
+	var toolNames []string
+	for toolName, config := range env.Tools {
+		if _, exists := registry[toolName]; exists && config.Enabled {
+			toolNames = append(toolNames, toolName)
+		}
+	}
+	if len(toolNames) == 0 {
+		return nil
+	}
+	sort.Strings(toolNames)
+
+	report, err := env.VerifyChecksums(toolNames...)
+	if err != nil {
+		if switchStrict {
+			return fmt.Errorf("failed to verify the snapshots of '%s': %w", env.Name, err)
+		}
+		snapshotLog.Warn("Failed to verify the snapshots of '%s': %v", env.Name, err)
+		return nil
+	}
+
+	failed := report.Failed()
+	if len(failed) == 0 {
+		return nil
+	}
+
+	fmt.Printf("⚠️  Snapshots of '%s' do not match their checksums:\n", env.Name)
+	for _, tool := range failed {
+		printChecksumFailure(tool)
+	}
+	if switchStrict {
+		return fmt.Errorf("snapshot verification failed for '%s', switch aborted", env.Name)
+	}
+	fmt.Println("   Switching anyway, use --strict to abort instead")
+	return nil
+}
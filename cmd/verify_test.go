@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hugofrely/envswitch/pkg/environment"
+	"github.com/hugofrely/envswitch/pkg/tools"
+)
+
+func TestRunVerify(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+
+	envsDir := filepath.Join(tempHome, ".envswitch", "environments")
+	require.NoError(t, os.MkdirAll(envsDir, 0755))
+	env := createEnvWithVars(t, envsDir, "work", nil)
+	createEnvWithVars(t, envsDir, "personal", nil)
+
+	configPath := filepath.Join(env.Path, "snapshots", "npm", ".npmrc")
+	require.NoError(t, os.MkdirAll(filepath.Dir(configPath), 0755))
+	require.NoError(t, os.WriteFile(configPath, []byte("registry=work"), 0600))
+	require.NoError(t, env.UpdateChecksums("npm"))
+
+	out := captureStdout(t, func() {
+		require.NoError(t, runVerify(verifyCmd, []string{"work"}))
+	})
+	assert.Contains(t, out, "✓ npm (1 file(s))")
+
+	require.NoError(t, os.WriteFile(configPath, []byte("registry=evil"), 0600))
+	out = captureStdout(t, func() {
+		assert.Error(t, runVerify(verifyCmd, []string{"work"}))
+	})
+	assert.Contains(t, out, "✗ npm")
+	assert.Contains(t, out, "modified:   snapshots/npm/.npmrc")
+
+	verifyAll = true
+	defer func() { verifyAll = false }()
+	out = captureStdout(t, func() {
+		err := runVerify(verifyCmd, nil)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "1 environment(s)")
+	})
+	assert.Contains(t, out, "Verifying snapshots of 'personal'")
+	assert.Contains(t, out, "No snapshots.")
+}
+
+func TestCheckSnapshotIntegrity(t *testing.T) {
+	env := &environment.Environment{
+		Name:  "work",
+		Path:  t.TempDir(),
+		Tools: map[string]environment.ToolConfig{"npm": {Enabled: true}},
+	}
+	configPath := filepath.Join(env.Path, "snapshots", "npm", ".npmrc")
+	require.NoError(t, os.MkdirAll(filepath.Dir(configPath), 0755))
+	require.NoError(t, os.WriteFile(configPath, []byte("registry=work"), 0600))
+	require.NoError(t, env.UpdateChecksums("npm"))
+
+	registry := map[string]tools.Tool{"npm": tools.NewNpmTool()}
+	require.NoError(t, checkSnapshotIntegrity(env, registry))
+
+	require.NoError(t, os.WriteFile(configPath, []byte("registry=evil"), 0600))
+	out := captureStdout(t, func() {
+		require.NoError(t, checkSnapshotIntegrity(env, registry))
+	})
+	assert.Contains(t, out, "do not match their checksums")
+	assert.Contains(t, out, "Switching anyway")
+
+	switchStrict = true
+	defer func() { switchStrict = false }()
+	captureStdout(t, func() {
+		err := checkSnapshotIntegrity(env, registry)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "switch aborted")
+	})
+
+	// Tools left out of the switch are not verified
+	require.NoError(t, checkSnapshotIntegrity(env, map[string]tools.Tool{}))
+}
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/hugofrely/envswitch/pkg/environment"
+	"github.com/hugofrely/envswitch/pkg/tools"
+)
+
+var whichCmd = &cobra.Command{
+	Use:   "which <tool>",
+	Short: "Show which environment the live config of a tool matches",
+	Long: `Compare the live configuration of a tool with its snapshot in every
+environment and print the environments it matches.
+
+If no snapshot matches, the live config is reported as modified, along with
+the closest environment.
+
+Examples:
+  envswitch which kubectl
+  envswitch which aws`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeToolNames,
+	RunE:              runWhich,
+}
+
+func init() {
+	rootCmd.AddCommand(whichCmd)
+}
+
+// whichResult describes which environments the live config of a tool matches
+type whichResult struct {
+	Matches        []string // environments whose snapshot equals the live config
+	Closest        string   // environment with the fewest changes when none match
+	ClosestChanges int
+	Compared       int // environments that have a snapshot of the tool
+}
+
+func runWhich(cmd *cobra.Command, args []string) error {
+	toolName := args[0]
+
+	tool, exists := getToolRegistry()[toolName]
+	if !exists {
+		return fmt.Errorf("unknown tool '%s'", toolName)
+	}
+
+	environments, err := environment.ListEnvironments()
+	if err != nil {
+		return err
+	}
+
+	current, _ := environment.GetCurrentEnvironment()
+	var currentName string
+	if current != nil {
+		currentName = current.Name
+	}
+
+	result := whichTool(toolName, tool, environments)
+
+	switch {
+	case len(result.Matches) > 0:
+		names := make([]string, len(result.Matches))
+		for i, name := range result.Matches {
+			names[i] = name
+			if name == currentName {
+				names[i] += " (active)"
+			}
+		}
+		fmt.Printf("%s: %s\n", toolName, strings.Join(names, ", "))
+	case result.Compared == 0:
+		fmt.Printf("%s: unknown (no environment has a %s snapshot)\n", toolName, toolName)
+	default:
+		fmt.Printf("%s: modified/unknown (closest: %s, %d change(s))\n", toolName, result.Closest, result.ClosestChanges)
+		fmt.Printf("   Run 'envswitch diff %s --tool %s' for details\n", result.Closest, toolName)
+	}
+
+	return nil
+}
+
+// whichTool diffs the live config of a tool against its snapshot in each
+// environment that has it enabled
+func whichTool(toolName string, tool tools.Tool, environments []*environment.Environment) whichResult {
+	var result whichResult
+
+	for _, env := range environments {
+		if toolConfig, exists := env.Tools[toolName]; !exists || !toolConfig.Enabled {
+			continue
+		}
+
+		diff := diffToolSnapshot(env, toolName, tool, ToolDiff{Tool: toolName})
+		if diff.Error != "" {
+			continue
+		}
+		result.Compared++
+
+		changes := len(diff.Changes)
+		if changes == 0 {
+			result.Matches = append(result.Matches, env.Name)
+			continue
+		}
+		if result.Closest == "" || changes < result.ClosestChanges {
+			result.Closest = env.Name
+			result.ClosestChanges = changes
+		}
+	}
+
+	sort.Strings(result.Matches)
+	return result
+}
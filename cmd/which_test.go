@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hugofrely/envswitch/pkg/environment"
+	"github.com/hugofrely/envswitch/pkg/tools"
+)
+
+func TestWhichTool(t *testing.T) {
+	tempDir := t.TempDir()
+	livePath := filepath.Join(tempDir, ".democonfig")
+	require.NoError(t, os.WriteFile(livePath, []byte("context=client-a"), 0644))
+
+	tool := tools.NewGenericTool("demo", livePath)
+
+	newEnv := func(name, content string, enabled bool) *environment.Environment {
+		env := &environment.Environment{
+			Name:  name,
+			Path:  filepath.Join(tempDir, "environments", name),
+			Tools: map[string]environment.ToolConfig{"demo": {Enabled: enabled}},
+		}
+		snapshotFile := filepath.Join(env.Path, "snapshots", "demo", ".democonfig")
+		require.NoError(t, os.MkdirAll(filepath.Dir(snapshotFile), 0755))
+		require.NoError(t, os.WriteFile(snapshotFile, []byte(content), 0644))
+		return env
+	}
+
+	clientA := newEnv("client-a", "context=client-a", true)
+	clientACopy := newEnv("client-a-copy", "context=client-a", true)
+	clientB := newEnv("client-b", "context=client-b", true)
+	disabled := newEnv("disabled", "context=client-a", false)
+
+	t.Run("lists matching environments", func(t *testing.T) {
+		result := whichTool("demo", tool, []*environment.Environment{clientB, clientACopy, clientA, disabled})
+		assert.Equal(t, []string{"client-a", "client-a-copy"}, result.Matches)
+		assert.Equal(t, 3, result.Compared)
+	})
+
+	t.Run("reports the closest environment when modified", func(t *testing.T) {
+		result := whichTool("demo", tool, []*environment.Environment{clientB})
+		assert.Empty(t, result.Matches)
+		assert.Equal(t, "client-b", result.Closest)
+		assert.Equal(t, 1, result.ClosestChanges)
+	})
+
+	t.Run("nothing to compare", func(t *testing.T) {
+		result := whichTool("demo", tool, []*environment.Environment{disabled})
+		assert.Zero(t, result.Compared)
+	})
+}
+
+func TestRunWhichUnknownTool(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	err := runWhich(whichCmd, []string{"not-a-tool"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown tool")
+}
@@ -7,18 +7,26 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/hugofrely/envswitch/internal/storage"
 	"github.com/hugofrely/envswitch/pkg/environment"
 )
 
-const gzipExtension = ".gz"
+const (
+	gzipExtension    = ".gz"
+	archiveExtension = ".tar.gz"
+	timestampLayout  = "20060102-150405"
+)
 
 // Archive represents an archived environment
 type Archive struct {
 	Path        string
 	EnvName     string
 	ArchivedAt  time.Time
+	SizeBytes   int64
 	OriginalEnv *environment.Environment
 }
 
@@ -51,35 +59,22 @@ func ArchiveEnvironment(env *environment.Environment) (*Archive, error) {
 		return nil, fmt.Errorf("failed to get archive directory: %w", err)
 	}
 
-	if mkdirErr := os.MkdirAll(archiveDir, 0755); mkdirErr != nil {
+	if mkdirErr := os.MkdirAll(archiveDir, 0700); mkdirErr != nil {
 		return nil, fmt.Errorf("failed to create archive directory: %w", mkdirErr)
 	}
 
-	// Create archive filename with timestamp
-	timestamp := time.Now().Format("20060102-150405")
-	archiveFilename := fmt.Sprintf("%s-%s.tar.gz", env.Name, timestamp)
-	archivePath := filepath.Join(archiveDir, archiveFilename)
-
-	// Create archive file
-	archiveFile, err := os.Create(archivePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create archive file: %w", err)
+	// Compression only makes the archive smaller than the environment
+	if spaceErr := storage.CheckDiskSpace(archiveDir, storage.PathSize(env.Path)); spaceErr != nil {
+		return nil, spaceErr
 	}
-	defer func() { _ = archiveFile.Close() }()
 
-	// Create gzip writer
-	gzipWriter := gzip.NewWriter(archiveFile)
-	defer func() { _ = gzipWriter.Close() }()
-
-	// Create tar writer
-	tarWriter := tar.NewWriter(gzipWriter)
-	defer func() { _ = tarWriter.Close() }()
+	// Create archive filename with timestamp
+	timestamp := time.Now().Format(timestampLayout)
+	archiveFilename := fmt.Sprintf("%s-%s%s", environment.FlatName(env.Name), timestamp, archiveExtension)
+	archivePath := filepath.Join(archiveDir, archiveFilename)
 
-	// Archive the entire environment directory
-	if err := archiveDirectory(tarWriter, env.Path, env.Name); err != nil {
-		// Clean up partial archive on error
-		_ = os.Remove(archivePath)
-		return nil, fmt.Errorf("failed to archive environment: %w", err)
+	if err := writeEnvironmentArchive(env, archivePath); err != nil {
+		return nil, err
 	}
 
 	archive := &Archive{
@@ -92,6 +87,41 @@ func ArchiveEnvironment(env *environment.Environment) (*Archive, error) {
 	return archive, nil
 }
 
+// writeEnvironmentArchive writes the directory of env to a new tar.gz file
+// at archivePath, removing it when it cannot be written completely
+func writeEnvironmentArchive(env *environment.Environment, archivePath string) (err error) {
+	// Create archive file, only readable by the user as it holds credentials
+	archiveFile, err := os.OpenFile(archivePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create archive file: %w", err)
+	}
+	defer func() {
+		if closeErr := archiveFile.Close(); err == nil && closeErr != nil {
+			err = fmt.Errorf("failed to write archive: %w", closeErr)
+		}
+		if err != nil {
+			// Clean up partial archive on error
+			_ = os.Remove(archivePath)
+		}
+	}()
+
+	gzipWriter := gzip.NewWriter(archiveFile)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	// Archive the entire environment directory. Grouped environments are
+	// stored under a flat directory, metadata.yaml keeps the full name.
+	if err := archiveDirectory(tarWriter, env.Path, environment.FlatName(env.Name)); err != nil {
+		return fmt.Errorf("failed to archive environment: %w", err)
+	}
+	if err := tarWriter.Close(); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	return nil
+}
+
 // archiveDirectory recursively adds a directory to a tar archive
 func archiveDirectory(tarWriter *tar.Writer, sourcePath, basePath string) error {
 	return filepath.Walk(sourcePath, func(path string, info os.FileInfo, err error) error {
@@ -157,8 +187,9 @@ func ListArchives() ([]*Archive, error) {
 			continue
 		}
 
-		// Only include .tar.gz files
-		if filepath.Ext(entry.Name()) != gzipExtension {
+		// Only include .tar.gz files and deduplicated backups
+		isManifest := IsManifest(entry.Name())
+		if filepath.Ext(entry.Name()) != gzipExtension && !isManifest {
 			continue
 		}
 
@@ -167,17 +198,57 @@ func ListArchives() ([]*Archive, error) {
 			continue
 		}
 
-		archives = append(archives, &Archive{
+		archive := &Archive{
 			Path:       filepath.Join(archiveDir, entry.Name()),
-			EnvName:    entry.Name(),
+			EnvName:    envNameFromFileName(entry.Name()),
 			ArchivedAt: info.ModTime(),
-		})
+			SizeBytes:  info.Size(),
+		}
+		// The blobs of a deduplicated backup are shared, report its content
+		if isManifest {
+			if manifest, err := ReadManifest(archive.Path); err == nil {
+				archive.SizeBytes = manifest.contentSize()
+			}
+		}
+		archives = append(archives, archive)
 	}
 
 	return archives, nil
 }
 
-// DeleteArchive removes an archive file
+// envNameFromFileName returns the environment of an archive named
+// <env>-<timestamp>.tar.gz (or .manifest.json), or the file name without
+// extension for archives named otherwise
+func envNameFromFileName(fileName string) string {
+	name := strings.TrimSuffix(strings.TrimSuffix(fileName, archiveExtension), manifestExtension)
+	suffix := len(timestampLayout) + 1
+	if len(name) > suffix && name[len(name)-suffix] == '-' {
+		if _, err := time.Parse(timestampLayout, name[len(name)-suffix+1:]); err == nil {
+			name = name[:len(name)-suffix]
+		}
+	}
+	return strings.ReplaceAll(name, "__", environment.GroupSeparator)
+}
+
+// ArchivesToPrune returns the archives to delete to keep at most keep of them
+// and none older than maxAge, newest first. Zero disables a limit.
+func ArchivesToPrune(archives []*Archive, keep int, maxAge time.Duration, now time.Time) []*Archive {
+	sorted := append([]*Archive{}, archives...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].ArchivedAt.After(sorted[j].ArchivedAt)
+	})
+
+	var prune []*Archive
+	for i, archive := range sorted {
+		if (keep > 0 && i >= keep) || (maxAge > 0 && now.Sub(archive.ArchivedAt) > maxAge) {
+			prune = append(prune, archive)
+		}
+	}
+	return prune
+}
+
+// DeleteArchive removes an archive file. The blobs of a deduplicated backup
+// stay until CollectGarbage.
 func DeleteArchive(archivePath string) error {
 	if err := os.Remove(archivePath); err != nil {
 		return fmt.Errorf("failed to delete archive: %w", err)
@@ -224,8 +295,12 @@ func CleanupOldArchives(retentionCount int) (int, error) {
 	return deletedCount, nil
 }
 
-// RestoreArchive extracts an archived environment (for future use)
+// RestoreArchive extracts an archived environment or a deduplicated backup
 func RestoreArchive(archivePath, destPath string) error {
+	if IsManifest(archivePath) {
+		return restoreManifest(archivePath, destPath)
+	}
+
 	// Open archive file
 	archiveFile, err := os.Open(archivePath)
 	if err != nil {
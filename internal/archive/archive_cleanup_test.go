@@ -159,6 +159,29 @@ func TestCleanupWithNoArchives(t *testing.T) {
 	assert.Equal(t, 0, deleted)
 }
 
+func TestArchivesToPrune(t *testing.T) {
+	now := time.Now()
+	archives := []*Archive{
+		{Path: "2-days", ArchivedAt: now.Add(-48 * time.Hour)},
+		{Path: "1-hour", ArchivedAt: now.Add(-time.Hour)},
+		{Path: "40-days", ArchivedAt: now.Add(-40 * 24 * time.Hour)},
+		{Path: "10-days", ArchivedAt: now.Add(-10 * 24 * time.Hour)},
+	}
+	paths := func(archives []*Archive) []string {
+		var result []string
+		for _, archive := range archives {
+			result = append(result, archive.Path)
+		}
+		return result
+	}
+
+	assert.Empty(t, ArchivesToPrune(archives, 0, 0, now))
+	assert.Equal(t, []string{"10-days", "40-days"}, paths(ArchivesToPrune(archives, 2, 0, now)))
+	assert.Equal(t, []string{"10-days", "40-days"}, paths(ArchivesToPrune(archives, 0, 7*24*time.Hour, now)))
+	assert.Equal(t, []string{"2-days", "10-days", "40-days"}, paths(ArchivesToPrune(archives, 3, 24*time.Hour, now)))
+	assert.Equal(t, "2-days", archives[0].Path, "the input is not reordered")
+}
+
 // Helper function to create a test archive with specific timestamp
 func createTestArchive(t *testing.T, dir string, timestamp time.Time) string {
 	t.Helper()
@@ -1,8 +1,12 @@
 package archive
 
 import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -165,6 +169,29 @@ func TestListArchives(t *testing.T) {
 	if len(archives) != 2 {
 		t.Errorf("Expected 2 archives, got: %d", len(archives))
 	}
+	for _, archive := range archives {
+		if archive.EnvName != "env1" && archive.EnvName != "env2" {
+			t.Errorf("Unexpected env name: %s", archive.EnvName)
+		}
+		if archive.SizeBytes != 4 {
+			t.Errorf("Expected size 4, got: %d", archive.SizeBytes)
+		}
+	}
+}
+
+func TestEnvNameFromFileName(t *testing.T) {
+	tests := map[string]string{
+		"work-20240101-120000.tar.gz":           "work",
+		"my-env-20240101-120000.tar.gz":         "my-env",
+		"client-a__prod-20240101-120000.tar.gz": "client-a/prod",
+		"exported.tar.gz":                       "exported",
+		"work-notadate-000000.tar.gz":           "work-notadate-000000",
+	}
+	for fileName, want := range tests {
+		if got := envNameFromFileName(fileName); got != want {
+			t.Errorf("envNameFromFileName(%q) = %q, want %q", fileName, got, want)
+		}
+	}
 }
 
 func TestDeleteArchive(t *testing.T) {
@@ -307,3 +334,105 @@ func TestArchiveDirectory(t *testing.T) {
 	// This is an internal function, so we test it via ArchiveEnvironment
 	// The test above (TestRestoreArchive) already validates this functionality
 }
+
+func TestImportGroupedEnvironment(t *testing.T) {
+	tmpHome := t.TempDir()
+	t.Setenv("HOME", tmpHome)
+
+	originalFunc := getArchiveDirFunc
+	getArchiveDirFunc = func() (string, error) { return filepath.Join(tmpHome, "archives"), nil }
+	defer func() { getArchiveDirFunc = originalFunc }()
+
+	envPath := filepath.Join(tmpHome, ".envswitch", "environments", "client-a", "prod")
+	env := &environment.Environment{Name: "client-a/prod", Path: envPath, Tools: map[string]environment.ToolConfig{}}
+	if err := os.MkdirAll(envPath, 0755); err != nil {
+		t.Fatalf("Failed to create environment: %v", err)
+	}
+	if err := env.Save(); err != nil {
+		t.Fatalf("Failed to save environment: %v", err)
+	}
+
+	arch, err := ArchiveEnvironment(env)
+	if err != nil {
+		t.Fatalf("ArchiveEnvironment failed: %v", err)
+	}
+	if !strings.HasPrefix(filepath.Base(arch.Path), "client-a__prod-") {
+		t.Errorf("Expected flat archive name, got %s", filepath.Base(arch.Path))
+	}
+
+	if err := os.RemoveAll(filepath.Join(tmpHome, ".envswitch")); err != nil {
+		t.Fatalf("Failed to remove environment: %v", err)
+	}
+
+	if err := ImportEnvironment(arch.Path, ImportOptions{ArchivePath: arch.Path}); err != nil {
+		t.Fatalf("ImportEnvironment failed: %v", err)
+	}
+
+	imported, err := environment.LoadEnvironment("client-a/prod")
+	if err != nil {
+		t.Fatalf("Failed to load imported environment: %v", err)
+	}
+	if imported.Path != envPath {
+		t.Errorf("Expected environment at %s, got %s", envPath, imported.Path)
+	}
+}
+
+func TestArchiveEnvironmentLeavesShellHistoryOut(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	originalFunc := getArchiveDirFunc
+	getArchiveDirFunc = func() (string, error) { return filepath.Join(home, "archives"), nil }
+	defer func() { getArchiveDirFunc = originalFunc }()
+
+	env := &environment.Environment{
+		Name:      "work",
+		Path:      filepath.Join(home, ".envswitch", "environments", "work"),
+		CreatedAt: time.Now(),
+	}
+	if err := os.MkdirAll(env.Path, 0700); err != nil {
+		t.Fatalf("Failed to create env directory: %v", err)
+	}
+	if err := env.Save(); err != nil {
+		t.Fatalf("Failed to save environment: %v", err)
+	}
+	historyFile, err := env.ShellHistoryFile("zsh")
+	if err != nil {
+		t.Fatalf("ShellHistoryFile failed: %v", err)
+	}
+	if err := os.WriteFile(historyFile, []byte("export GITHUB_TOKEN=ghp_typed_secret\n"), 0600); err != nil {
+		t.Fatalf("Failed to write history: %v", err)
+	}
+
+	arch, err := ArchiveEnvironment(env)
+	if err != nil {
+		t.Fatalf("ArchiveEnvironment failed: %v", err)
+	}
+
+	file, err := os.Open(arch.Path)
+	if err != nil {
+		t.Fatalf("Failed to open archive: %v", err)
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatalf("Failed to read archive: %v", err)
+	}
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Failed to read archive: %v", err)
+		}
+		if strings.Contains(header.Name, "history") {
+			t.Errorf("Archive contains %s", header.Name)
+		}
+		content, _ := io.ReadAll(tr)
+		if strings.Contains(string(content), "ghp_typed_secret") {
+			t.Errorf("Archive entry %s holds the shell history", header.Name)
+		}
+	}
+}
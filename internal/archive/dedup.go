@@ -0,0 +1,481 @@
+package archive
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hugofrely/envswitch/internal/storage"
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+const (
+	manifestExtension = ".manifest.json"
+	manifestVersion   = 1
+	blobsDirName      = "blobs"
+
+	// blobGracePeriod protects the blobs of a backup being written, whose
+	// manifest is not there yet, from a concurrent garbage collection
+	blobGracePeriod = time.Hour
+)
+
+// Manifest lists the files of a deduplicated backup. Their content is stored
+// once in the blob store of the archive directory, by SHA-256, and shared by
+// every backup holding the same content.
+type Manifest struct {
+	Version   int             `json:"version"`
+	EnvName   string          `json:"env_name"`
+	CreatedAt time.Time       `json:"created_at"`
+	Entries   []ManifestEntry `json:"entries"`
+}
+
+// ManifestEntry is a file or directory of a deduplicated backup
+type ManifestEntry struct {
+	Path string      `json:"path"` // <env>/<relative path>, as in a tar backup
+	Mode os.FileMode `json:"mode"`
+	Size int64       `json:"size,omitempty"`
+	Hash string      `json:"hash,omitempty"` // SHA-256 of the content, empty for directories
+}
+
+// GCResult describes the blobs removed (or that would be) by CollectGarbage
+type GCResult struct {
+	Blobs     int
+	Bytes     int64
+	Remaining int
+}
+
+// IsManifest reports whether path is a deduplicated backup
+func IsManifest(path string) bool {
+	return strings.HasSuffix(path, manifestExtension)
+}
+
+// blobsDir returns the blob store of the archive directory
+func blobsDir() (string, error) {
+	archiveDir, err := GetArchiveDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(archiveDir, blobsDirName), nil
+}
+
+// blobPath returns where the content with hash is stored, sharded by the
+// first two characters so no directory grows too large
+func blobPath(dir, hash string) string {
+	return filepath.Join(dir, hash[:2], hash)
+}
+
+// BackupEnvironment backs up an environment as a manifest in the archive
+// directory. Only the files whose content is not in the blob store yet are
+// written, so backups taken on every switch share what did not change.
+func BackupEnvironment(env *environment.Environment) (*Archive, error) {
+	if env == nil {
+		return nil, fmt.Errorf("environment cannot be nil")
+	}
+
+	archiveDir, err := GetArchiveDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get archive directory: %w", err)
+	}
+	blobs := filepath.Join(archiveDir, blobsDirName)
+	if mkdirErr := os.MkdirAll(blobs, 0700); mkdirErr != nil {
+		return nil, fmt.Errorf("failed to create archive directory: %w", mkdirErr)
+	}
+
+	// Unchanged files take no room, but there is no telling before hashing
+	if spaceErr := storage.CheckDiskSpace(archiveDir, storage.PathSize(env.Path)); spaceErr != nil {
+		return nil, spaceErr
+	}
+
+	manifest := &Manifest{
+		Version:   manifestVersion,
+		EnvName:   env.Name,
+		CreatedAt: time.Now(),
+	}
+	base := environment.FlatName(env.Name)
+
+	walkErr := filepath.Walk(env.Path, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(env.Path, filePath)
+		if err != nil {
+			return fmt.Errorf("failed to get relative path: %w", err)
+		}
+		entry := ManifestEntry{
+			Path: path.Join(base, filepath.ToSlash(relPath)),
+			Mode: info.Mode(),
+		}
+		if !info.IsDir() {
+			entry.Size = info.Size()
+			if entry.Hash, err = storeBlob(blobs, filePath); err != nil {
+				return err
+			}
+		}
+		manifest.Entries = append(manifest.Entries, entry)
+		return nil
+	})
+	if walkErr != nil {
+		return nil, fmt.Errorf("failed to back up environment: %w", walkErr)
+	}
+
+	manifestPath := filepath.Join(archiveDir, base+"-"+manifest.CreatedAt.Format(timestampLayout)+manifestExtension)
+	if err := writeManifest(manifestPath, manifest); err != nil {
+		return nil, err
+	}
+
+	return &Archive{
+		Path:        manifestPath,
+		EnvName:     env.Name,
+		ArchivedAt:  manifest.CreatedAt,
+		SizeBytes:   manifest.contentSize(),
+		OriginalEnv: env,
+	}, nil
+}
+
+// storeBlob adds the content of the file at filePath to the blob store unless
+// it is already there, and returns its hash
+func storeBlob(dir, filePath string) (string, error) {
+	hash, err := hashFile(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	target := blobPath(dir, hash)
+	if _, statErr := os.Stat(target); statErr == nil {
+		// Reused content is as recent as the backup for the garbage collector
+		now := time.Now()
+		_ = os.Chtimes(target, now, now)
+		return hash, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+		return "", fmt.Errorf("failed to create blob directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".tmp-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create blob: %w", err)
+	}
+	tmpPath := tmp.Name()
+	defer func() { _ = os.Remove(tmpPath) }()
+
+	if err := compressFile(tmp, filePath); err != nil {
+		_ = tmp.Close()
+		return "", fmt.Errorf("failed to write blob of %s: %w", filepath.Base(filePath), err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("failed to write blob of %s: %w", filepath.Base(filePath), err)
+	}
+	if err := os.Rename(tmpPath, target); err != nil {
+		return "", fmt.Errorf("failed to move blob into place: %w", err)
+	}
+	return hash, nil
+}
+
+// hashFile returns the SHA-256 of the content of a file
+func hashFile(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// compressFile writes the gzipped content of the file at filePath to w
+func compressFile(w io.Writer, filePath string) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+
+	gzipWriter := gzip.NewWriter(w)
+	if _, err := io.Copy(gzipWriter, file); err != nil {
+		return err
+	}
+	return gzipWriter.Close()
+}
+
+// openBlob returns a reader of the content stored with hash
+func openBlob(dir, hash string) (io.ReadCloser, error) {
+	if len(hash) < 2 {
+		return nil, fmt.Errorf("invalid blob hash '%s'", hash)
+	}
+	file, err := os.Open(blobPath(dir, hash))
+	if err != nil {
+		return nil, fmt.Errorf("missing blob %s: %w", hash, err)
+	}
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("corrupted blob %s: %w", hash, err)
+	}
+	return &blobReader{Reader: gzipReader, file: file}, nil
+}
+
+// blobReader closes the blob file along with its gzip reader
+type blobReader struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (r *blobReader) Close() error {
+	_ = r.Reader.Close()
+	return r.file.Close()
+}
+
+// writeManifest writes a manifest under a temporary name and renames it, so
+// the archive directory never holds a partial backup
+func writeManifest(manifestPath string, manifest *Manifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+
+	tmpPath := manifestPath + ".partial"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	if err := os.Rename(tmpPath, manifestPath); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("failed to move manifest into place: %w", err)
+	}
+	return nil
+}
+
+// ReadManifest reads the manifest of a deduplicated backup
+func ReadManifest(manifestPath string) (*Manifest, error) {
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	if manifest.Version > manifestVersion {
+		return nil, fmt.Errorf("manifest version %d is not supported, update envswitch", manifest.Version)
+	}
+	return &manifest, nil
+}
+
+// contentSize returns the size of the files of a backup, before compression
+// and deduplication
+func (m *Manifest) contentSize() int64 {
+	var size int64
+	for _, entry := range m.Entries {
+		size += entry.Size
+	}
+	return size
+}
+
+// manifestBlobsDir returns the blob store of a manifest, next to it
+func manifestBlobsDir(manifestPath string) string {
+	return filepath.Join(filepath.Dir(manifestPath), blobsDirName)
+}
+
+// restoreManifest extracts a deduplicated backup into destPath
+func restoreManifest(manifestPath, destPath string) error {
+	manifest, err := ReadManifest(manifestPath)
+	if err != nil {
+		return err
+	}
+	blobs := manifestBlobsDir(manifestPath)
+
+	for _, entry := range manifest.Entries {
+		// #nosec G305 - Backups are written by envswitch itself
+		targetPath := filepath.Join(destPath, filepath.FromSlash(entry.Path))
+
+		if entry.Mode.IsDir() {
+			if err := os.MkdirAll(targetPath, entry.Mode.Perm()); err != nil {
+				return fmt.Errorf("failed to create directory: %w", err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+			return fmt.Errorf("failed to create parent directory: %w", err)
+		}
+		if err := extractBlob(blobs, entry, targetPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// extractBlob writes the content of a manifest entry to targetPath
+func extractBlob(blobs string, entry ManifestEntry, targetPath string) error {
+	blob, err := openBlob(blobs, entry.Hash)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = blob.Close() }()
+
+	outFile, err := os.OpenFile(targetPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, entry.Mode.Perm())
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	// #nosec G110 - Blobs are written by envswitch itself
+	if _, err := io.Copy(outFile, blob); err != nil {
+		_ = outFile.Close()
+		return fmt.Errorf("failed to write file content: %w", err)
+	}
+	return outFile.Close()
+}
+
+// writeManifestTar writes a deduplicated backup as a self-contained tar.gz,
+// as ArchiveEnvironment would have
+func writeManifestTar(w io.Writer, manifestPath string) error {
+	manifest, err := ReadManifest(manifestPath)
+	if err != nil {
+		return err
+	}
+	blobs := manifestBlobsDir(manifestPath)
+
+	gzipWriter := gzip.NewWriter(w)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for _, entry := range manifest.Entries {
+		header := &tar.Header{
+			Name:    entry.Path,
+			Mode:    int64(entry.Mode.Perm()),
+			ModTime: manifest.CreatedAt,
+		}
+		if entry.Mode.IsDir() {
+			header.Typeflag = tar.TypeDir
+			header.Name += "/"
+		} else {
+			header.Typeflag = tar.TypeReg
+			header.Size = entry.Size
+		}
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return fmt.Errorf("failed to write tar header: %w", err)
+		}
+		if entry.Mode.IsDir() {
+			continue
+		}
+
+		blob, err := openBlob(blobs, entry.Hash)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(tarWriter, blob)
+		_ = blob.Close()
+		if err != nil {
+			return fmt.Errorf("failed to write file content: %w", err)
+		}
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		return err
+	}
+	return gzipWriter.Close()
+}
+
+// CollectGarbage removes the blobs no backup manifest references anymore,
+// once backups were deleted or pruned. Nothing is removed with dryRun.
+func CollectGarbage(dryRun bool) (*GCResult, error) {
+	archiveDir, err := GetArchiveDir()
+	if err != nil {
+		return nil, err
+	}
+	blobs := filepath.Join(archiveDir, blobsDirName)
+	result := &GCResult{}
+	if _, statErr := os.Stat(blobs); os.IsNotExist(statErr) {
+		return result, nil
+	}
+
+	referenced, err := referencedBlobs(archiveDir)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	shards, err := os.ReadDir(blobs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blob store: %w", err)
+	}
+	for _, shard := range shards {
+		if !shard.IsDir() {
+			continue
+		}
+		shardDir := filepath.Join(blobs, shard.Name())
+		files, err := os.ReadDir(shardDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read blob store: %w", err)
+		}
+
+		kept := 0
+		for _, file := range files {
+			info, err := file.Info()
+			if err != nil {
+				continue
+			}
+			if referenced[file.Name()] || now.Sub(info.ModTime()) < blobGracePeriod {
+				kept++
+				continue
+			}
+
+			result.Blobs++
+			result.Bytes += info.Size()
+			if dryRun {
+				continue
+			}
+			if err := os.Remove(filepath.Join(shardDir, file.Name())); err != nil {
+				return result, fmt.Errorf("failed to remove blob: %w", err)
+			}
+		}
+		result.Remaining += kept
+
+		if kept == 0 && !dryRun {
+			_ = os.Remove(shardDir)
+		}
+	}
+
+	return result, nil
+}
+
+// referencedBlobs returns the hashes referenced by the manifests of the
+// archive directory. A manifest that cannot be read fails the collection
+// rather than losing the content of its backup.
+func referencedBlobs(archiveDir string) (map[string]bool, error) {
+	entries, err := os.ReadDir(archiveDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive directory: %w", err)
+	}
+
+	referenced := make(map[string]bool)
+	for _, entry := range entries {
+		if entry.IsDir() || !IsManifest(entry.Name()) {
+			continue
+		}
+		manifest, err := ReadManifest(filepath.Join(archiveDir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		for _, file := range manifest.Entries {
+			if file.Hash != "" {
+				referenced[file.Hash] = true
+			}
+		}
+	}
+	return referenced, nil
+}
@@ -0,0 +1,270 @@
+package archive
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+// setupDedupTest returns an environment with a few files and points the
+// archive directory to a temporary one
+func setupDedupTest(t *testing.T) (*environment.Environment, string) {
+	t.Helper()
+	tmpDir := t.TempDir()
+
+	envPath := filepath.Join(tmpDir, "environments", "work")
+	files := map[string]string{
+		"metadata.yaml":              "name: work\n",
+		"snapshots/git/gitconfig":    "[user]\n\tname = Work\n",
+		"snapshots/env-vars.env":     "AWS_PROFILE=work\nEDITOR=vim\n",
+		"snapshots/kubectl/config":   "apiVersion: v1\n",
+		"snapshots/kubectl/config.2": "apiVersion: v1\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(envPath, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	archiveDir := filepath.Join(tmpDir, "archives")
+	originalGetArchiveDirFunc := getArchiveDirFunc
+	getArchiveDirFunc = func() (string, error) {
+		return archiveDir, nil
+	}
+	t.Cleanup(func() { getArchiveDirFunc = originalGetArchiveDirFunc })
+
+	return &environment.Environment{Name: "work", Path: envPath}, archiveDir
+}
+
+// countBlobs returns the number of blobs in the blob store
+func countBlobs(t *testing.T, archiveDir string) int {
+	t.Helper()
+	count := 0
+	_ = filepath.Walk(filepath.Join(archiveDir, blobsDirName), func(_ string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			count++
+		}
+		return nil
+	})
+	return count
+}
+
+// ageBlobs moves the blobs past the garbage collection grace period
+func ageBlobs(t *testing.T, archiveDir string) {
+	t.Helper()
+	old := time.Now().Add(-2 * blobGracePeriod)
+	_ = filepath.Walk(filepath.Join(archiveDir, blobsDirName), func(path string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			_ = os.Chtimes(path, old, old)
+		}
+		return nil
+	})
+}
+
+func TestBackupEnvironmentSharesContent(t *testing.T) {
+	env, archiveDir := setupDedupTest(t)
+
+	first, err := BackupEnvironment(env)
+	if err != nil {
+		t.Fatalf("BackupEnvironment failed: %v", err)
+	}
+	if !IsManifest(first.Path) {
+		t.Errorf("Expected a manifest, got: %s", first.Path)
+	}
+	// The two kubectl configs have the same content
+	if got := countBlobs(t, archiveDir); got != 4 {
+		t.Errorf("Expected 4 blobs, got: %d", got)
+	}
+
+	// A second backup only stores what changed
+	if err := os.WriteFile(filepath.Join(env.Path, "snapshots", "git", "gitconfig"), []byte("[user]\n\tname = Other\n"), 0600); err != nil {
+		t.Fatalf("Failed to update file: %v", err)
+	}
+	time.Sleep(time.Second) // backups are named to the second
+	second, err := BackupEnvironment(env)
+	if err != nil {
+		t.Fatalf("BackupEnvironment failed: %v", err)
+	}
+	if first.Path == second.Path {
+		t.Fatal("Expected two manifests")
+	}
+	if got := countBlobs(t, archiveDir); got != 5 {
+		t.Errorf("Expected 5 blobs, got: %d", got)
+	}
+
+	archives, err := ListArchives()
+	if err != nil {
+		t.Fatalf("ListArchives failed: %v", err)
+	}
+	if len(archives) != 2 {
+		t.Fatalf("Expected 2 backups, got: %d", len(archives))
+	}
+	for _, archive := range archives {
+		if archive.EnvName != "work" {
+			t.Errorf("Expected env name 'work', got: %s", archive.EnvName)
+		}
+		if archive.SizeBytes == 0 {
+			t.Error("Expected the content size of the backup")
+		}
+	}
+}
+
+func TestRestoreAndInspectManifest(t *testing.T) {
+	env, _ := setupDedupTest(t)
+
+	backup, err := BackupEnvironment(env)
+	if err != nil {
+		t.Fatalf("BackupEnvironment failed: %v", err)
+	}
+
+	restorePath := filepath.Join(t.TempDir(), "restored")
+	if err := RestoreArchive(backup.Path, restorePath); err != nil {
+		t.Fatalf("RestoreArchive failed: %v", err)
+	}
+	restoredFile := filepath.Join(restorePath, "work", "snapshots", "git", "gitconfig")
+	content, err := os.ReadFile(restoredFile)
+	if err != nil {
+		t.Fatalf("Failed to read restored file: %v", err)
+	}
+	if string(content) != "[user]\n\tname = Work\n" {
+		t.Errorf("Unexpected restored content: %q", content)
+	}
+	if info, err := os.Stat(restoredFile); err == nil && info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600, got: %v", info.Mode().Perm())
+	}
+
+	info, err := InspectArchive(backup.Path)
+	if err != nil {
+		t.Fatalf("InspectArchive failed: %v", err)
+	}
+	if info.Environment.Name != "work" {
+		t.Errorf("Expected environment 'work', got: %s", info.Environment.Name)
+	}
+	if info.FileCount != 5 {
+		t.Errorf("Expected 5 files, got: %d", info.FileCount)
+	}
+	if len(info.Snapshots) != 2 || info.Snapshots[0] != "git" || info.Snapshots[1] != "kubectl" {
+		t.Errorf("Unexpected snapshots: %v", info.Snapshots)
+	}
+	if len(info.EnvVarKeys) != 2 || info.EnvVarKeys[0] != "AWS_PROFILE" {
+		t.Errorf("Unexpected variables: %v", info.EnvVarKeys)
+	}
+}
+
+func TestMirrorManifest(t *testing.T) {
+	env, _ := setupDedupTest(t)
+
+	backup, err := BackupEnvironment(env)
+	if err != nil {
+		t.Fatalf("BackupEnvironment failed: %v", err)
+	}
+
+	mirrorPath, err := MirrorArchive(backup.Path, filepath.Join(t.TempDir(), "mirror"))
+	if err != nil {
+		t.Fatalf("MirrorArchive failed: %v", err)
+	}
+	if filepath.Ext(mirrorPath) != gzipExtension {
+		t.Errorf("Expected a tar.gz mirror, got: %s", mirrorPath)
+	}
+
+	// The mirror holds the content, not references to the blob store
+	restorePath := filepath.Join(t.TempDir(), "restored")
+	if err := RestoreArchive(mirrorPath, restorePath); err != nil {
+		t.Fatalf("RestoreArchive failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(restorePath, "work", "snapshots", "kubectl", "config")); err != nil {
+		t.Errorf("Mirrored file not found: %v", err)
+	}
+}
+
+func TestCollectGarbage(t *testing.T) {
+	env, archiveDir := setupDedupTest(t)
+
+	first, err := BackupEnvironment(env)
+	if err != nil {
+		t.Fatalf("BackupEnvironment failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(env.Path, "snapshots", "git", "gitconfig"), []byte("changed"), 0600); err != nil {
+		t.Fatalf("Failed to update file: %v", err)
+	}
+	time.Sleep(time.Second)
+	second, err := BackupEnvironment(env)
+	if err != nil {
+		t.Fatalf("BackupEnvironment failed: %v", err)
+	}
+
+	// Recent blobs may belong to a backup being taken
+	if err := DeleteArchive(first.Path); err != nil {
+		t.Fatalf("DeleteArchive failed: %v", err)
+	}
+	result, err := CollectGarbage(false)
+	if err != nil {
+		t.Fatalf("CollectGarbage failed: %v", err)
+	}
+	if result.Blobs != 0 {
+		t.Errorf("Expected recent blobs to be kept, removed: %d", result.Blobs)
+	}
+
+	ageBlobs(t, archiveDir)
+	result, err = CollectGarbage(true)
+	if err != nil {
+		t.Fatalf("CollectGarbage failed: %v", err)
+	}
+	if result.Blobs != 1 || result.Bytes == 0 {
+		t.Errorf("Expected 1 unused blob, got: %+v", result)
+	}
+	if got := countBlobs(t, archiveDir); got != 5 {
+		t.Errorf("Expected a dry run to keep the blobs, got: %d", got)
+	}
+
+	result, err = CollectGarbage(false)
+	if err != nil {
+		t.Fatalf("CollectGarbage failed: %v", err)
+	}
+	if result.Blobs != 1 || result.Remaining != 4 {
+		t.Errorf("Expected 1 removed and 4 remaining blobs, got: %+v", result)
+	}
+
+	// The remaining backup is whole
+	if err := RestoreArchive(second.Path, filepath.Join(t.TempDir(), "restored")); err != nil {
+		t.Errorf("RestoreArchive failed after garbage collection: %v", err)
+	}
+}
+
+func TestCollectGarbageUnreadableManifest(t *testing.T) {
+	env, archiveDir := setupDedupTest(t)
+
+	if _, err := BackupEnvironment(env); err != nil {
+		t.Fatalf("BackupEnvironment failed: %v", err)
+	}
+	ageBlobs(t, archiveDir)
+	if err := os.WriteFile(filepath.Join(archiveDir, "other-20240101-120000"+manifestExtension), []byte("{"), 0600); err != nil {
+		t.Fatalf("Failed to write manifest: %v", err)
+	}
+
+	if _, err := CollectGarbage(false); err == nil {
+		t.Error("Expected an error for an unreadable manifest")
+	}
+	if got := countBlobs(t, archiveDir); got != 4 {
+		t.Errorf("Expected no blob to be removed, got: %d", got)
+	}
+}
+
+func TestCollectGarbageNoBlobs(t *testing.T) {
+	setupDedupTest(t)
+
+	result, err := CollectGarbage(false)
+	if err != nil {
+		t.Fatalf("CollectGarbage failed: %v", err)
+	}
+	if result.Blobs != 0 {
+		t.Errorf("Expected nothing to collect, got: %+v", result)
+	}
+}
@@ -38,7 +38,7 @@ func ExportEnvironment(envName, outputPath string) error {
 
 	// If no output path specified, use current directory
 	if outputPath == "" {
-		outputPath = fmt.Sprintf("%s-export.tar.gz", envName)
+		outputPath = fmt.Sprintf("%s-export.tar.gz", environment.FlatName(envName))
 	}
 
 	// Copy archive to output path
@@ -18,6 +18,9 @@ type ImportOptions struct {
 	ArchivePath string // Path to archive file
 	NewName     string // Optional: new name for the environment
 	Force       bool   // Overwrite existing environment
+	// ConfirmReplace is asked before a protected environment is overwritten,
+	// which is refused when it is nil
+	ConfirmReplace func(existing *environment.Environment) bool
 }
 
 // ImportEnvironment imports an environment from an archive file
@@ -73,12 +76,28 @@ func ImportEnvironment(archivePath string, options ImportOptions) error {
 		return err
 	}
 
-	// Use new name if specified
-	finalEnvName := envName
+	spin.Update("Validating metadata...")
+	if err := validateMetadata(filepath.Join(tempDir, envName)); err != nil {
+		spin.Error("Invalid environment metadata")
+		return err
+	}
+
+	// Use new name if specified. Grouped environments are archived under a
+	// flat directory, their full name comes from the metadata.
+	finalEnvName := archivedName(filepath.Join(tempDir, envName), envName)
+	archivedEnvName := finalEnvName
 	if options.NewName != "" {
 		finalEnvName = options.NewName
 	}
 
+	if err := environment.ValidateName(finalEnvName); err != nil {
+		spin.Error("Invalid environment name")
+		if options.NewName == "" {
+			return fmt.Errorf("%w (use --name to import under another name)", err)
+		}
+		return err
+	}
+
 	// Check if environment already exists
 	envDir, err := environment.GetEnvironmentsDir()
 	if err != nil {
@@ -86,12 +105,24 @@ func ImportEnvironment(archivePath string, options ImportOptions) error {
 		return fmt.Errorf("failed to get environments directory: %w", err)
 	}
 
+	if err := environment.CheckGroups(finalEnvName); err != nil {
+		spin.Error("Invalid environment name")
+		return err
+	}
+
 	finalEnvPath := filepath.Join(envDir, finalEnvName)
 	if _, err := os.Stat(finalEnvPath); err == nil {
 		if !options.Force {
 			spin.Error(fmt.Sprintf("Environment '%s' already exists", finalEnvName))
 			return fmt.Errorf("environment '%s' already exists (use --force to overwrite)", finalEnvName)
 		}
+		if existing, err := environment.LoadEnvironment(finalEnvName); err == nil && existing.Protected {
+			spin.Stop()
+			if options.ConfirmReplace == nil || !options.ConfirmReplace(existing) {
+				return fmt.Errorf("environment '%s' is protected, it was not replaced", finalEnvName)
+			}
+			spin.Start()
+		}
 		// Remove existing environment
 		spin.Update(fmt.Sprintf("Removing existing environment '%s'", finalEnvName))
 		if err := os.RemoveAll(finalEnvPath); err != nil {
@@ -103,6 +134,10 @@ func ImportEnvironment(archivePath string, options ImportOptions) error {
 	// Move from temp to final location
 	spin.Update(fmt.Sprintf("Installing environment '%s'", finalEnvName))
 	extractedPath := filepath.Join(tempDir, envName)
+	if err := os.MkdirAll(filepath.Dir(finalEnvPath), 0755); err != nil {
+		spin.Error("Failed to install environment")
+		return fmt.Errorf("failed to create environment group: %w", err)
+	}
 	if err := os.Rename(extractedPath, finalEnvPath); err != nil {
 		// If rename fails (cross-device), copy instead
 		if err := copyDir(extractedPath, finalEnvPath); err != nil {
@@ -112,7 +147,7 @@ func ImportEnvironment(archivePath string, options ImportOptions) error {
 	}
 
 	// Update metadata if name changed
-	if options.NewName != "" && options.NewName != envName {
+	if options.NewName != "" && options.NewName != archivedEnvName {
 		env, err := environment.LoadEnvironment(finalEnvName)
 		if err == nil {
 			env.Name = finalEnvName
@@ -124,24 +159,38 @@ func ImportEnvironment(archivePath string, options ImportOptions) error {
 		}
 	}
 
+	// The installed environment must load like any other
+	if _, err := environment.LoadEnvironment(finalEnvName); err != nil {
+		_ = os.RemoveAll(finalEnvPath)
+		spin.Error("Imported environment is invalid")
+		return fmt.Errorf("imported environment '%s' is invalid: %w", finalEnvName, err)
+	}
+
 	spin.Success(fmt.Sprintf("Imported environment '%s'", finalEnvName))
 	return nil
 }
 
-// ImportAll imports all archives from a directory
-func ImportAll(dirPath string, force bool) error {
+// ImportResult lists the archives ImportAll imported and those it could not
+type ImportResult struct {
+	Imported []string
+	Failed   map[string]error
+}
+
+// ImportAll imports all archives from a directory, reporting its progress.
+// The archives are imported with the Force and ConfirmReplace of options.
+func ImportAll(dirPath string, options ImportOptions) (*ImportResult, error) {
 	// Check if directory exists
 	if _, err := os.Stat(dirPath); os.IsNotExist(err) {
-		return fmt.Errorf("directory not found: %s", dirPath)
+		return nil, fmt.Errorf("directory not found: %s", dirPath)
 	}
 
 	// Find all .tar.gz files
 	entries, err := os.ReadDir(dirPath)
 	if err != nil {
-		return fmt.Errorf("failed to read directory: %w", err)
+		return nil, fmt.Errorf("failed to read directory: %w", err)
 	}
 
-	imported := 0
+	result := &ImportResult{Failed: make(map[string]error)}
 	archives := []string{}
 
 	// First, collect all archives
@@ -157,30 +206,87 @@ func ImportAll(dirPath string, force bool) error {
 		archives = append(archives, name)
 	}
 
+	if len(archives) == 0 {
+		return nil, fmt.Errorf("no .tar.gz or .tgz archives in %s", dirPath)
+	}
+
 	// Import each archive with progress
 	for i, name := range archives {
 		archivePath := filepath.Join(dirPath, name)
-		options := ImportOptions{
-			ArchivePath: archivePath,
-			Force:       force,
-		}
+		archiveOptions := options
+		archiveOptions.ArchivePath = archivePath
+		archiveOptions.NewName = ""
 
 		// ImportEnvironment has its own spinner
-		if err := ImportEnvironment(archivePath, options); err != nil {
-			fmt.Printf("✗ [%d/%d] Failed to import %s\n", i+1, len(archives), name)
+		fmt.Printf("[%d/%d] %s\n", i+1, len(archives), name)
+		if err := ImportEnvironment(archivePath, archiveOptions); err != nil {
+			fmt.Printf("✗ [%d/%d] Failed to import %s: %v\n", i+1, len(archives), name, err)
+			result.Failed[name] = err
 			continue
 		}
 
-		imported++
+		result.Imported = append(result.Imported, name)
+	}
+
+	if len(result.Imported) == 0 {
+		return result, fmt.Errorf("no archives were imported successfully")
 	}
 
-	if imported == 0 {
-		return fmt.Errorf("no archives were imported successfully")
+	return result, nil
+}
+
+// validateMetadata checks the metadata.yaml of an extracted environment before
+// it is installed
+func validateMetadata(extractedPath string) error {
+	file, err := os.Open(filepath.Join(extractedPath, "metadata.yaml"))
+	if os.IsNotExist(err) {
+		return fmt.Errorf("archive does not contain environment metadata (metadata.yaml)")
 	}
+	if err != nil {
+		return fmt.Errorf("failed to read metadata: %w", err)
+	}
+	defer file.Close()
 
+	env, err := readArchivedEnvironment(file)
+	if err != nil {
+		return err
+	}
+
+	hooks := []struct {
+		kind  string
+		hooks []environment.Hook
+	}{
+		{"pre_switch", env.Hooks.PreSwitch},
+		{"post_switch", env.Hooks.PostSwitch},
+		{"pre_snapshot", env.Hooks.PreSnapshot},
+		{"post_snapshot", env.Hooks.PostSnapshot},
+	}
+	for _, list := range hooks {
+		for i, hook := range list.hooks {
+			if err := hook.Validate(); err != nil {
+				return fmt.Errorf("invalid metadata: hooks.%s[%d]: %w", list.kind, i, err)
+			}
+		}
+	}
 	return nil
 }
 
+// archivedName returns the environment name recorded in the metadata of an
+// extracted environment, or fallback when it is missing or invalid
+func archivedName(extractedPath, fallback string) string {
+	file, err := os.Open(filepath.Join(extractedPath, "metadata.yaml"))
+	if err != nil {
+		return fallback
+	}
+	defer file.Close()
+
+	env, err := readArchivedEnvironment(file)
+	if err != nil || environment.ValidateName(env.Name) != nil {
+		return fallback
+	}
+	return env.Name
+}
+
 // extractTarArchive extracts a tar archive and returns the environment name
 func extractTarArchive(tarReader *tar.Reader, tempDir string) (string, error) {
 	var envName string
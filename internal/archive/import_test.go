@@ -0,0 +1,127 @@
+package archive
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+// writeTestArchive writes a tar.gz holding files, by path in the archive
+func writeTestArchive(t *testing.T, archivePath string, files map[string]string) {
+	t.Helper()
+	file, err := os.Create(archivePath)
+	if err != nil {
+		t.Fatalf("Failed to create archive: %v", err)
+	}
+	defer file.Close()
+
+	gzipWriter := gzip.NewWriter(file)
+	tarWriter := tar.NewWriter(gzipWriter)
+	// The environment directory comes first, as in an export
+	dirs := make(map[string]bool)
+	for name := range files {
+		dir := strings.SplitN(name, "/", 2)[0]
+		if dirs[dir] {
+			continue
+		}
+		dirs[dir] = true
+		if err := tarWriter.WriteHeader(&tar.Header{Name: dir + "/", Mode: 0755, Typeflag: tar.TypeDir}); err != nil {
+			t.Fatalf("Failed to write header: %v", err)
+		}
+	}
+	for name, content := range files {
+		header := &tar.Header{Name: name, Mode: 0600, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if err := tarWriter.WriteHeader(header); err != nil {
+			t.Fatalf("Failed to write header: %v", err)
+		}
+		if _, err := tarWriter.Write([]byte(content)); err != nil {
+			t.Fatalf("Failed to write content: %v", err)
+		}
+	}
+	if err := tarWriter.Close(); err != nil {
+		t.Fatalf("Failed to close tar: %v", err)
+	}
+	if err := gzipWriter.Close(); err != nil {
+		t.Fatalf("Failed to close gzip: %v", err)
+	}
+}
+
+func TestImportEnvironmentValidatesMetadata(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		wantErr string
+	}{
+		{
+			name:    "missing metadata",
+			files:   map[string]string{"work/snapshots/env-vars.env": "A=1\n"},
+			wantErr: "does not contain environment metadata",
+		},
+		{
+			name:    "unparsable metadata",
+			files:   map[string]string{"work/metadata.yaml": "name: [work\n"},
+			wantErr: "failed to parse metadata",
+		},
+		{
+			name:    "invalid hook",
+			files:   map[string]string{"work/metadata.yaml": "name: work\nhooks:\n  post_switch:\n    - command: echo\n      on_failure: maybe\n"},
+			wantErr: "hooks.post_switch[0]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpHome := t.TempDir()
+			t.Setenv("HOME", tmpHome)
+
+			archivePath := filepath.Join(t.TempDir(), "work.tar.gz")
+			writeTestArchive(t, archivePath, tt.files)
+
+			err := ImportEnvironment(archivePath, ImportOptions{ArchivePath: archivePath})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Expected an error containing %q, got: %v", tt.wantErr, err)
+			}
+			if _, statErr := os.Stat(filepath.Join(tmpHome, ".envswitch", "environments", "work")); !os.IsNotExist(statErr) {
+				t.Error("Expected nothing to be installed")
+			}
+		})
+	}
+}
+
+func TestImportAll(t *testing.T) {
+	tmpHome := t.TempDir()
+	t.Setenv("HOME", tmpHome)
+
+	dir := t.TempDir()
+	writeTestArchive(t, filepath.Join(dir, "work.tar.gz"), map[string]string{"work/metadata.yaml": "name: work\n"})
+	writeTestArchive(t, filepath.Join(dir, "home.tgz"), map[string]string{"home/metadata.yaml": "name: home\n"})
+	writeTestArchive(t, filepath.Join(dir, "broken.tar.gz"), map[string]string{"broken/notes.txt": "no metadata"})
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("not an archive"), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	result, err := ImportAll(dir, ImportOptions{})
+	if err != nil {
+		t.Fatalf("ImportAll failed: %v", err)
+	}
+	if len(result.Imported) != 2 {
+		t.Errorf("Expected 2 imported archives, got: %v", result.Imported)
+	}
+	if _, failed := result.Failed["broken.tar.gz"]; !failed || len(result.Failed) != 1 {
+		t.Errorf("Expected broken.tar.gz to fail, got: %v", result.Failed)
+	}
+	for _, name := range []string{"work", "home"} {
+		if _, err := environment.LoadEnvironment(name); err != nil {
+			t.Errorf("Failed to load imported environment %s: %v", name, err)
+		}
+	}
+
+	if _, err := ImportAll(t.TempDir(), ImportOptions{}); err == nil {
+		t.Error("Expected an error for a directory without archives")
+	}
+}
@@ -0,0 +1,208 @@
+package archive
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+// ArchiveInfo describes the content of an archive without extracting it
+type ArchiveInfo struct {
+	Environment *environment.Environment
+	Snapshots   []string // tools with a snapshot directory
+	EnvVarKeys  []string // keys of the captured environment variables
+	FileCount   int
+	SizeBytes   int64
+}
+
+// InspectArchive reads an environment archive (from export or a backup) and
+// returns its metadata. Nothing is written to disk.
+func InspectArchive(archivePath string) (*ArchiveInfo, error) {
+	if IsManifest(archivePath) {
+		return inspectManifest(archivePath)
+	}
+
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gzip reader: %w", err)
+	}
+	defer func() { _ = gzipReader.Close() }()
+
+	inspector := newArchiveInspector()
+	tarReader := tar.NewReader(gzipReader)
+
+	for {
+		header, nextErr := tarReader.Next()
+		if nextErr == io.EOF {
+			break
+		}
+		if nextErr != nil {
+			return nil, fmt.Errorf("failed to read tar: %w", nextErr)
+		}
+
+		if header.Typeflag != tar.TypeDir && header.Typeflag != tar.TypeReg {
+			inspector.addPath(header.Name, false)
+			continue
+		}
+		if err := inspector.add(header.Name, header.Typeflag == tar.TypeDir, header.Size, tarReader); err != nil {
+			return nil, err
+		}
+	}
+
+	return inspector.result()
+}
+
+// inspectManifest reads the metadata of a deduplicated backup
+func inspectManifest(manifestPath string) (*ArchiveInfo, error) {
+	manifest, err := ReadManifest(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+	blobs := manifestBlobsDir(manifestPath)
+
+	inspector := newArchiveInspector()
+	for _, entry := range manifest.Entries {
+		if entry.Mode.IsDir() || !inspector.wantsContent(entry.Path) {
+			if err := inspector.add(entry.Path, entry.Mode.IsDir(), entry.Size, nil); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		blob, err := openBlob(blobs, entry.Hash)
+		if err != nil {
+			return nil, err
+		}
+		err = inspector.add(entry.Path, false, entry.Size, blob)
+		_ = blob.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return inspector.result()
+}
+
+// archiveInspector gathers the metadata of the entries of an archive
+type archiveInspector struct {
+	info      *ArchiveInfo
+	snapshots map[string]bool
+}
+
+func newArchiveInspector() *archiveInspector {
+	return &archiveInspector{info: &ArchiveInfo{}, snapshots: make(map[string]bool)}
+}
+
+// relPath returns the path of an entry stored as <env>/<relative path>
+func relPath(name string) (string, bool) {
+	parts := strings.SplitN(path.Clean(name), "/", 2)
+	if len(parts) != 2 {
+		return "", false
+	}
+	return parts[1], true
+}
+
+// wantsContent reports whether the content of the file entry name is read
+func (a *archiveInspector) wantsContent(name string) bool {
+	rel, ok := relPath(name)
+	return ok && (rel == "metadata.yaml" || rel == "snapshots/env-vars.env")
+}
+
+// addPath records the tool snapshot an entry belongs to
+func (a *archiveInspector) addPath(name string, dir bool) {
+	rel, ok := relPath(name)
+	if !ok || !strings.HasPrefix(rel, "snapshots/") {
+		return
+	}
+	if tool := strings.SplitN(strings.TrimPrefix(rel, "snapshots/"), "/", 2); len(tool) == 2 || dir {
+		a.snapshots[tool[0]] = true
+	}
+}
+
+// add records an entry, reading the content of the files wantsContent
+// selects from r
+func (a *archiveInspector) add(name string, dir bool, size int64, r io.Reader) error {
+	a.addPath(name, dir)
+	if _, ok := relPath(name); !ok || dir {
+		return nil
+	}
+	a.info.FileCount++
+	a.info.SizeBytes += size
+
+	if r == nil || !a.wantsContent(name) {
+		return nil
+	}
+	switch rel, _ := relPath(name); rel {
+	case "metadata.yaml":
+		env, err := readArchivedEnvironment(r)
+		if err != nil {
+			return err
+		}
+		a.info.Environment = env
+	case "snapshots/env-vars.env":
+		a.info.EnvVarKeys = readEnvVarKeys(r)
+	}
+	return nil
+}
+
+// result returns the metadata of the archive once every entry was added
+func (a *archiveInspector) result() (*ArchiveInfo, error) {
+	if a.info.Environment == nil {
+		return nil, fmt.Errorf("archive does not contain environment metadata")
+	}
+
+	for tool := range a.snapshots {
+		a.info.Snapshots = append(a.info.Snapshots, tool)
+	}
+	sort.Strings(a.info.Snapshots)
+
+	return a.info, nil
+}
+
+// readArchivedEnvironment parses metadata.yaml from an archive entry
+func readArchivedEnvironment(r io.Reader) (*environment.Environment, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metadata: %w", err)
+	}
+
+	var env environment.Environment
+	if err := yaml.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("failed to parse metadata: %w", err)
+	}
+
+	return &env, nil
+}
+
+// readEnvVarKeys returns the variable names of an env-vars.env entry, never the values
+func readEnvVarKeys(r io.Reader) []string {
+	var keys []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if key, _, found := strings.Cut(line, "="); found {
+			keys = append(keys, strings.TrimSpace(key))
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,90 @@
+package archive
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+func TestInspectArchive(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	originalFunc := getArchiveDirFunc
+	getArchiveDirFunc = func() (string, error) { return filepath.Join(tmpDir, "archives"), nil }
+	defer func() { getArchiveDirFunc = originalFunc }()
+
+	env := &environment.Environment{
+		Name:        "client-a",
+		Description: "Client A",
+		CreatedAt:   time.Now(),
+		Tools: map[string]environment.ToolConfig{
+			"git":     {Enabled: true, Metadata: map[string]interface{}{"user.email": "a@client.com"}},
+			"kubectl": {Enabled: true},
+		},
+		EnvVars: map[string]string{"API_URL": ""},
+		Path:    filepath.Join(tmpDir, "environments", "client-a"),
+	}
+	if err := os.MkdirAll(filepath.Join(env.Path, "snapshots", "git"), 0755); err != nil {
+		t.Fatalf("Failed to create snapshot dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(env.Path, "snapshots", "git", "gitconfig"), []byte("[user]\n"), 0644); err != nil {
+		t.Fatalf("Failed to write snapshot: %v", err)
+	}
+	if err := env.Save(); err != nil {
+		t.Fatalf("Failed to save environment: %v", err)
+	}
+	if err := env.SaveEnvVars([]environment.EnvVar{{Key: "API_URL", Value: "https://a"}, {Key: "API_TOKEN", Value: "secret"}}); err != nil {
+		t.Fatalf("Failed to save env vars: %v", err)
+	}
+
+	arch, err := ArchiveEnvironment(env)
+	if err != nil {
+		t.Fatalf("ArchiveEnvironment failed: %v", err)
+	}
+
+	info, err := InspectArchive(arch.Path)
+	if err != nil {
+		t.Fatalf("InspectArchive failed: %v", err)
+	}
+
+	if info.Environment.Name != "client-a" || info.Environment.Description != "Client A" {
+		t.Errorf("Unexpected environment: %+v", info.Environment)
+	}
+	if !info.Environment.Tools["git"].Enabled {
+		t.Error("Expected git to be enabled")
+	}
+	if !reflect.DeepEqual(info.Snapshots, []string{"git"}) {
+		t.Errorf("Expected snapshots [git], got %v", info.Snapshots)
+	}
+	if !reflect.DeepEqual(info.EnvVarKeys, []string{"API_TOKEN", "API_URL"}) {
+		t.Errorf("Expected env var keys [API_TOKEN API_URL], got %v", info.EnvVarKeys)
+	}
+	if info.FileCount != 3 {
+		t.Errorf("Expected 3 files, got %d", info.FileCount)
+	}
+
+	// Nothing is installed
+	if _, err := os.Stat(filepath.Join(tmpDir, ".envswitch")); !os.IsNotExist(err) {
+		t.Error("Expected inspection not to extract anything")
+	}
+}
+
+func TestInspectArchive_Invalid(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	if _, err := InspectArchive(filepath.Join(tmpDir, "missing.tar.gz")); err == nil {
+		t.Error("Expected error for missing archive")
+	}
+
+	notGzip := filepath.Join(tmpDir, "bad.tar.gz")
+	if err := os.WriteFile(notGzip, []byte("not an archive"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if _, err := InspectArchive(notGzip); err == nil {
+		t.Error("Expected error for invalid archive")
+	}
+}
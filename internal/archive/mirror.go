@@ -0,0 +1,79 @@
+package archive
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hugofrely/envswitch/internal/storage"
+)
+
+// MirrorArchive copies an archive into mirrorDir, e.g. an external disk or a
+// mounted share. The copy is written under a temporary name and renamed, so
+// the mirror never holds a partial archive. A deduplicated backup is mirrored
+// as a self-contained tar.gz.
+func MirrorArchive(archivePath, mirrorDir string) (string, error) {
+	if err := os.MkdirAll(mirrorDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create mirror directory: %w", err)
+	}
+	if IsManifest(archivePath) {
+		return mirrorManifest(archivePath, mirrorDir)
+	}
+
+	info, err := os.Stat(archivePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to stat archive: %w", err)
+	}
+	if err := storage.CheckDiskSpace(mirrorDir, uint64(info.Size())); err != nil {
+		return "", err
+	}
+
+	mirrorPath := filepath.Join(mirrorDir, filepath.Base(archivePath))
+	tmpPath := mirrorPath + ".partial"
+
+	if err := storage.CopyFile(archivePath, tmpPath); err != nil {
+		_ = os.Remove(tmpPath)
+		return "", err
+	}
+	if err := os.Rename(tmpPath, mirrorPath); err != nil {
+		_ = os.Remove(tmpPath)
+		return "", fmt.Errorf("failed to move mirrored archive into place: %w", err)
+	}
+
+	return mirrorPath, nil
+}
+
+// mirrorManifest writes a deduplicated backup into mirrorDir as a tar.gz
+func mirrorManifest(manifestPath, mirrorDir string) (string, error) {
+	manifest, err := ReadManifest(manifestPath)
+	if err != nil {
+		return "", err
+	}
+	// Compression only makes the archive smaller than the content
+	if err := storage.CheckDiskSpace(mirrorDir, uint64(manifest.contentSize())); err != nil {
+		return "", err
+	}
+
+	mirrorPath := filepath.Join(mirrorDir, strings.TrimSuffix(filepath.Base(manifestPath), manifestExtension)+archiveExtension)
+	tmpPath := mirrorPath + ".partial"
+
+	file, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return "", fmt.Errorf("failed to create mirrored archive: %w", err)
+	}
+	writeErr := writeManifestTar(file, manifestPath)
+	if closeErr := file.Close(); writeErr == nil {
+		writeErr = closeErr
+	}
+	if writeErr != nil {
+		_ = os.Remove(tmpPath)
+		return "", writeErr
+	}
+	if err := os.Rename(tmpPath, mirrorPath); err != nil {
+		_ = os.Remove(tmpPath)
+		return "", fmt.Errorf("failed to move mirrored archive into place: %w", err)
+	}
+
+	return mirrorPath, nil
+}
@@ -0,0 +1,56 @@
+package archive
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMirrorArchive(t *testing.T) {
+	tmpDir := t.TempDir()
+	archivePath := filepath.Join(tmpDir, "work-20240101-120000.tar.gz")
+	if err := os.WriteFile(archivePath, []byte("archive data"), 0644); err != nil {
+		t.Fatalf("Failed to write archive: %v", err)
+	}
+
+	mirrorDir := filepath.Join(tmpDir, "external", "envswitch")
+	mirrorPath, err := MirrorArchive(archivePath, mirrorDir)
+	if err != nil {
+		t.Fatalf("MirrorArchive failed: %v", err)
+	}
+
+	if mirrorPath != filepath.Join(mirrorDir, filepath.Base(archivePath)) {
+		t.Errorf("Unexpected mirror path: %s", mirrorPath)
+	}
+	data, err := os.ReadFile(mirrorPath)
+	if err != nil {
+		t.Fatalf("Failed to read mirrored archive: %v", err)
+	}
+	if string(data) != "archive data" {
+		t.Errorf("Mirrored content = %q, want %q", data, "archive data")
+	}
+
+	if _, err := os.Stat(mirrorPath + ".partial"); !os.IsNotExist(err) {
+		t.Error("Temporary file was left in the mirror")
+	}
+}
+
+func TestMirrorArchiveErrors(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	if _, err := MirrorArchive(filepath.Join(tmpDir, "missing.tar.gz"), filepath.Join(tmpDir, "mirror")); err == nil {
+		t.Error("Expected error for missing archive")
+	}
+
+	archivePath := filepath.Join(tmpDir, "work.tar.gz")
+	if err := os.WriteFile(archivePath, []byte("data"), 0644); err != nil {
+		t.Fatalf("Failed to write archive: %v", err)
+	}
+	blocker := filepath.Join(tmpDir, "not-a-dir")
+	if err := os.WriteFile(blocker, []byte("file"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if _, err := MirrorArchive(archivePath, blocker); err == nil {
+		t.Error("Expected error when the mirror directory cannot be created")
+	}
+}
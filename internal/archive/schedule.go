@@ -0,0 +1,405 @@
+package archive
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/hugofrely/envswitch/internal/storage"
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+// Frequencies of a backup schedule
+const (
+	ScheduleHourly = "hourly"
+	ScheduleDaily  = "daily"
+	ScheduleWeekly = "weekly" // on Sundays
+)
+
+// ScheduleFrequencies are the accepted values of Schedule.Every
+var ScheduleFrequencies = []string{ScheduleHourly, ScheduleDaily, ScheduleWeekly}
+
+const (
+	// scheduleFileName records the installed schedule in ~/.envswitch
+	scheduleFileName = "backup-schedule.yaml"
+
+	// scheduleName names the cron entry, systemd units, launchd job and
+	// Windows task of the schedule
+	scheduleName = "envswitch-backup"
+	launchdLabel = "com.envswitch.backup"
+	cronMarker   = "# " + scheduleName
+)
+
+// ErrNoSchedule is returned when no backup schedule is installed
+var ErrNoSchedule = errors.New("no backup schedule installed")
+
+// Schedule runs a command periodically, typically 'envswitch backup create
+// --all', through the scheduler of the system: a launchd job on macOS, a
+// systemd timer (or a cron entry without systemd) on Linux, a task of the
+// Task Scheduler on Windows
+type Schedule struct {
+	Every       string    `yaml:"every"`               // hourly, daily or weekly
+	At          string    `yaml:"at"`                  // HH:MM, only the minute for hourly
+	Command     []string  `yaml:"command"`             // program and arguments
+	LogFile     string    `yaml:"log_file,omitempty"`  // output of the command, except on Windows
+	Installer   string    `yaml:"installer,omitempty"` // launchd, systemd, cron or schtasks
+	Path        string    `yaml:"path,omitempty"`      // file or task the installer created
+	InstalledAt time.Time `yaml:"installed_at,omitempty"`
+}
+
+// scheduler installs schedules in a scheduler of the system
+type scheduler interface {
+	name() string
+	install(s *Schedule) (string, error)
+	remove(s *Schedule) error
+	installed(s *Schedule) bool
+}
+
+// schedulerFor returns the scheduler called name, the one of the system when
+// name is empty. Replaced in tests.
+var schedulerFor = systemScheduler
+
+// Validate checks the frequency and time of the schedule
+func (s Schedule) Validate() error {
+	valid := false
+	for _, every := range ScheduleFrequencies {
+		valid = valid || s.Every == every
+	}
+	if !valid {
+		return fmt.Errorf("invalid frequency '%s' (valid: %s)", s.Every, strings.Join(ScheduleFrequencies, ", "))
+	}
+	if _, _, err := s.clock(); err != nil {
+		return err
+	}
+	if len(s.Command) == 0 {
+		return fmt.Errorf("schedule has no command")
+	}
+	return nil
+}
+
+// clock returns the hour and minute of At
+func (s Schedule) clock() (int, int, error) {
+	at, err := time.Parse("15:04", s.At)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid time '%s': expected HH:MM", s.At)
+	}
+	return at.Hour(), at.Minute(), nil
+}
+
+// String describes when the schedule runs
+func (s Schedule) String() string {
+	hour, minute, _ := s.clock()
+	switch s.Every {
+	case ScheduleHourly:
+		return fmt.Sprintf("hourly at minute %02d", minute)
+	case ScheduleWeekly:
+		return fmt.Sprintf("weekly on Sunday at %02d:%02d", hour, minute)
+	default:
+		return fmt.Sprintf("daily at %02d:%02d", hour, minute)
+	}
+}
+
+// InstallSchedule installs s, replacing the schedule installed before
+func InstallSchedule(s Schedule) (*Schedule, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+
+	if previous, err := LoadSchedule(); err == nil && previous != nil {
+		if err := RemoveSchedule(); err != nil {
+			return nil, fmt.Errorf("failed to remove the previous schedule: %w", err)
+		}
+	}
+
+	backend, err := schedulerFor("")
+	if err != nil {
+		return nil, err
+	}
+	path, err := backend.install(&s)
+	if err != nil {
+		return nil, fmt.Errorf("failed to install %s schedule: %w", backend.name(), err)
+	}
+	s.Installer = backend.name()
+	s.Path = path
+	s.InstalledAt = time.Now()
+
+	if err := saveSchedule(&s); err != nil {
+		_ = backend.remove(&s)
+		return nil, err
+	}
+	return &s, nil
+}
+
+// RemoveSchedule uninstalls the backup schedule, ErrNoSchedule when there
+// is none
+func RemoveSchedule() error {
+	s, err := LoadSchedule()
+	if err != nil {
+		return err
+	}
+	if s == nil {
+		return ErrNoSchedule
+	}
+
+	backend, err := schedulerFor(s.Installer)
+	if err != nil {
+		return err
+	}
+	if err := backend.remove(s); err != nil {
+		return fmt.Errorf("failed to remove %s schedule: %w", backend.name(), err)
+	}
+
+	path, err := schedulePath()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove %s: %w", scheduleFileName, err)
+	}
+	return nil
+}
+
+// LoadSchedule returns the installed backup schedule, nil when there is none
+func LoadSchedule() (*Schedule, error) {
+	path, err := schedulePath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read backup schedule: %w", err)
+	}
+
+	var s Schedule
+	if err := yaml.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return &s, nil
+}
+
+// ScheduleInstalled reports whether the scheduler of the system still holds
+// s, which can be removed behind envswitch's back
+func ScheduleInstalled(s *Schedule) bool {
+	backend, err := schedulerFor(s.Installer)
+	if err != nil {
+		return false
+	}
+	return backend.installed(s)
+}
+
+// saveSchedule records the installed schedule
+func saveSchedule(s *Schedule) error {
+	path, err := schedulePath()
+	if err != nil {
+		return err
+	}
+	if err := storage.MkdirPrivate(filepath.Dir(path)); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	data, err := yaml.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("failed to marshal backup schedule: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write backup schedule: %w", err)
+	}
+	return nil
+}
+
+// schedulePath returns the path of the file recording the installed schedule
+func schedulePath() (string, error) {
+	dir, err := environment.GetEnvswitchDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, scheduleFileName), nil
+}
+
+// cronEntry returns the crontab line of the schedule
+func cronEntry(s *Schedule) string {
+	hour, minute, _ := s.clock()
+	var when string
+	switch s.Every {
+	case ScheduleHourly:
+		when = fmt.Sprintf("%d * * * *", minute)
+	case ScheduleWeekly:
+		when = fmt.Sprintf("%d %d * * 0", minute, hour)
+	default:
+		when = fmt.Sprintf("%d %d * * *", minute, hour)
+	}
+
+	command := shellJoin(s.Command)
+	if s.LogFile != "" {
+		command += " >> " + shellQuote(s.LogFile) + " 2>&1"
+	}
+	// cron turns % into newlines
+	return when + " " + strings.ReplaceAll(command, "%", `\%`) + " " + cronMarker
+}
+
+// updateCrontab returns crontab without the entry of the schedule, with entry
+// added when it is not empty
+func updateCrontab(crontab, entry string) string {
+	var lines []string
+	if crontab = strings.TrimRight(crontab, "\n"); crontab != "" {
+		for _, line := range strings.Split(crontab, "\n") {
+			if !strings.HasSuffix(line, cronMarker) {
+				lines = append(lines, line)
+			}
+		}
+	}
+	if entry != "" {
+		lines = append(lines, entry)
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// systemdUnits returns the service and timer units of the schedule
+func systemdUnits(s *Schedule) (string, string) {
+	hour, minute, _ := s.clock()
+	var calendar string
+	switch s.Every {
+	case ScheduleHourly:
+		calendar = fmt.Sprintf("*-*-* *:%02d:00", minute)
+	case ScheduleWeekly:
+		calendar = fmt.Sprintf("Sun *-*-* %02d:%02d:00", hour, minute)
+	default:
+		calendar = fmt.Sprintf("*-*-* %02d:%02d:00", hour, minute)
+	}
+
+	service := fmt.Sprintf(`[Unit]
+Description=envswitch backups
+
+[Service]
+Type=oneshot
+ExecStart=%s
+`, systemdJoin(s.Command))
+	if s.LogFile != "" {
+		service += fmt.Sprintf("StandardOutput=append:%[1]s\nStandardError=append:%[1]s\n", s.LogFile)
+	}
+
+	// Persistent runs a backup missed while the machine was off
+	timer := fmt.Sprintf(`[Unit]
+Description=envswitch backups, %s
+
+[Timer]
+OnCalendar=%s
+Persistent=true
+
+[Install]
+WantedBy=timers.target
+`, s, calendar)
+
+	return service, timer
+}
+
+// launchdPlist returns the launchd job of the schedule
+func launchdPlist(s *Schedule) string {
+	hour, minute, _ := s.clock()
+	interval := fmt.Sprintf("\t\t<key>Minute</key>\n\t\t<integer>%d</integer>\n", minute)
+	if s.Every != ScheduleHourly {
+		interval = fmt.Sprintf("\t\t<key>Hour</key>\n\t\t<integer>%d</integer>\n", hour) + interval
+	}
+	if s.Every == ScheduleWeekly {
+		interval = "\t\t<key>Weekday</key>\n\t\t<integer>0</integer>\n" + interval
+	}
+
+	var args strings.Builder
+	for _, arg := range s.Command {
+		fmt.Fprintf(&args, "\t\t<string>%s</string>\n", xmlEscape(arg))
+	}
+
+	var logs string
+	if s.LogFile != "" {
+		logs = fmt.Sprintf("\t<key>StandardOutPath</key>\n\t<string>%[1]s</string>\n\t<key>StandardErrorPath</key>\n\t<string>%[1]s</string>\n", xmlEscape(s.LogFile))
+	}
+
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>%s</string>
+	<key>ProgramArguments</key>
+	<array>
+%s	</array>
+	<key>StartCalendarInterval</key>
+	<dict>
+%s	</dict>
+%s</dict>
+</plist>
+`, launchdLabel, args.String(), interval, logs)
+}
+
+// schtasksArgs returns the arguments of schtasks creating the task of the
+// schedule
+func schtasksArgs(s *Schedule) []string {
+	hour, minute, _ := s.clock()
+	args := []string{"/Create", "/F", "/TN", scheduleName, "/TR", windowsJoin(s.Command)}
+	switch s.Every {
+	case ScheduleHourly:
+		// Hourly tasks start at the given time, the next hour with that minute
+		return append(args, "/SC", "HOURLY", "/ST", fmt.Sprintf("%02d:%02d", (time.Now().Hour()+1)%24, minute))
+	case ScheduleWeekly:
+		return append(args, "/SC", "WEEKLY", "/D", "SUN", "/ST", fmt.Sprintf("%02d:%02d", hour, minute))
+	default:
+		return append(args, "/SC", "DAILY", "/ST", fmt.Sprintf("%02d:%02d", hour, minute))
+	}
+}
+
+// shellJoin quotes args for sh
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shellQuote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// shellQuote quotes a value for sh when it needs it
+func shellQuote(value string) string {
+	if value != "" && !strings.ContainsAny(value, " \t\n'\"\\$`*?[]{}()<>|&;#~%") {
+		return value
+	}
+	return "'" + strings.ReplaceAll(value, "'", `'"'"'`) + "'"
+}
+
+// systemdJoin quotes args for ExecStart
+func systemdJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = arg
+		if arg == "" || strings.ContainsAny(arg, " \t\"'\\%$") {
+			escaped := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "%", "%%", "$", "$$").Replace(arg)
+			quoted[i] = `"` + escaped + `"`
+		}
+	}
+	return strings.Join(quoted, " ")
+}
+
+// windowsJoin quotes args for a Windows command line
+func windowsJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = arg
+		if arg == "" || strings.ContainsAny(arg, " \t\"") {
+			quoted[i] = `"` + strings.ReplaceAll(arg, `"`, `\"`) + `"`
+		}
+	}
+	return strings.Join(quoted, " ")
+}
+
+// xmlEscape escapes a value for a plist string
+func xmlEscape(value string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(value)
+}
@@ -0,0 +1,76 @@
+package archive
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/hugofrely/envswitch/internal/platform"
+)
+
+// launchdScheduler installs the schedule as a launchd agent of the user
+type launchdScheduler struct{}
+
+func systemScheduler(name string) (scheduler, error) {
+	if name != "" && name != "launchd" {
+		return nil, fmt.Errorf("schedule installed with %s, not available on macOS", name)
+	}
+	return launchdScheduler{}, nil
+}
+
+func (launchdScheduler) name() string { return "launchd" }
+
+func (launchdScheduler) install(s *Schedule) (string, error) {
+	path, err := launchdPlistPath()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create LaunchAgents directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(launchdPlist(s)), 0644); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	// Unloaded first so that a changed plist is picked up
+	_ = exec.Command("launchctl", "unload", path).Run()
+	// #nosec G204 - The path is envswitch's own LaunchAgents plist
+	if output, err := exec.Command("launchctl", "load", "-w", path).CombinedOutput(); err != nil {
+		_ = os.Remove(path)
+		return "", fmt.Errorf("launchctl load: %s: %w", strings.TrimSpace(string(output)), err)
+	}
+	return path, nil
+}
+
+func (launchdScheduler) remove(s *Schedule) error {
+	path, err := launchdPlistPath()
+	if err != nil {
+		return err
+	}
+	// #nosec G204 - The path is envswitch's own LaunchAgents plist
+	_ = exec.Command("launchctl", "unload", "-w", path).Run()
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (launchdScheduler) installed(s *Schedule) bool {
+	path, err := launchdPlistPath()
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(path)
+	return err == nil
+}
+
+// launchdPlistPath returns the path of the plist of the launchd agent
+func launchdPlistPath() (string, error) {
+	home, err := platform.HomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, "Library", "LaunchAgents", launchdLabel+".plist"), nil
+}
@@ -0,0 +1,154 @@
+package archive
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeScheduler records the schedules installed instead of installing them
+type fakeScheduler struct {
+	schedules map[string]*Schedule
+	failure   error
+}
+
+func (f *fakeScheduler) name() string { return "fake" }
+
+func (f *fakeScheduler) install(s *Schedule) (string, error) {
+	if f.failure != nil {
+		return "", f.failure
+	}
+	f.schedules[s.Every] = s
+	return "/fake/" + s.Every, nil
+}
+
+func (f *fakeScheduler) remove(s *Schedule) error {
+	delete(f.schedules, s.Every)
+	return nil
+}
+
+func (f *fakeScheduler) installed(s *Schedule) bool {
+	_, ok := f.schedules[s.Every]
+	return ok
+}
+
+func TestScheduleValidate(t *testing.T) {
+	command := []string{"envswitch", "backup", "create", "--all"}
+	assert.NoError(t, Schedule{Every: ScheduleDaily, At: "03:00", Command: command}.Validate())
+	assert.ErrorContains(t, Schedule{Every: "monthly", At: "03:00", Command: command}.Validate(), "invalid frequency 'monthly'")
+	assert.ErrorContains(t, Schedule{Every: ScheduleDaily, At: "25:00", Command: command}.Validate(), "invalid time '25:00'")
+	assert.ErrorContains(t, Schedule{Every: ScheduleDaily, At: "03:00"}.Validate(), "no command")
+
+	assert.Equal(t, "hourly at minute 15", Schedule{Every: ScheduleHourly, At: "00:15"}.String())
+	assert.Equal(t, "weekly on Sunday at 22:30", Schedule{Every: ScheduleWeekly, At: "22:30"}.String())
+}
+
+func TestInstallSchedule(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	fake := &fakeScheduler{schedules: make(map[string]*Schedule)}
+	original := schedulerFor
+	schedulerFor = func(name string) (scheduler, error) { return fake, nil }
+	t.Cleanup(func() { schedulerFor = original })
+
+	s, err := LoadSchedule()
+	require.NoError(t, err)
+	assert.Nil(t, s)
+	assert.ErrorIs(t, RemoveSchedule(), ErrNoSchedule)
+
+	command := []string{"envswitch", "backup", "create", "--all"}
+	s, err = InstallSchedule(Schedule{Every: ScheduleDaily, At: "03:00", Command: command})
+	require.NoError(t, err)
+	assert.Equal(t, "fake", s.Installer)
+	assert.Equal(t, "/fake/daily", s.Path)
+
+	t.Run("replaces the installed schedule", func(t *testing.T) {
+		_, err := InstallSchedule(Schedule{Every: ScheduleWeekly, At: "22:30", Command: command})
+		require.NoError(t, err)
+		assert.NotContains(t, fake.schedules, ScheduleDaily)
+		assert.Contains(t, fake.schedules, ScheduleWeekly)
+
+		loaded, err := LoadSchedule()
+		require.NoError(t, err)
+		assert.Equal(t, ScheduleWeekly, loaded.Every)
+		assert.Equal(t, command, loaded.Command)
+		assert.True(t, ScheduleInstalled(loaded))
+	})
+
+	t.Run("rejects invalid schedules", func(t *testing.T) {
+		_, err := InstallSchedule(Schedule{Every: ScheduleDaily, At: "noon", Command: command})
+		assert.Error(t, err)
+	})
+
+	t.Run("removes the schedule", func(t *testing.T) {
+		require.NoError(t, RemoveSchedule())
+		assert.Empty(t, fake.schedules)
+
+		loaded, err := LoadSchedule()
+		require.NoError(t, err)
+		assert.Nil(t, loaded)
+	})
+
+	t.Run("keeps nothing when the installer fails", func(t *testing.T) {
+		fake.failure = assert.AnError
+		defer func() { fake.failure = nil }()
+
+		_, err := InstallSchedule(Schedule{Every: ScheduleDaily, At: "03:00", Command: command})
+		assert.ErrorContains(t, err, "failed to install fake schedule")
+
+		loaded, err := LoadSchedule()
+		require.NoError(t, err)
+		assert.Nil(t, loaded)
+	})
+}
+
+func TestScheduleEntries(t *testing.T) {
+	s := &Schedule{
+		Every:   ScheduleDaily,
+		At:      "03:05",
+		Command: []string{"/opt/my tools/envswitch", "backup", "create", "--all"},
+		LogFile: "/home/me/.envswitch/logs/backup-schedule.log",
+	}
+
+	t.Run("cron", func(t *testing.T) {
+		assert.Equal(t, "5 3 * * * '/opt/my tools/envswitch' backup create --all >> /home/me/.envswitch/logs/backup-schedule.log 2>&1 # envswitch-backup", cronEntry(s))
+
+		hourly := *s
+		hourly.Every = ScheduleHourly
+		assert.True(t, strings.HasPrefix(cronEntry(&hourly), "5 * * * * "))
+
+		weekly := *s
+		weekly.Every = ScheduleWeekly
+		assert.True(t, strings.HasPrefix(cronEntry(&weekly), "5 3 * * 0 "))
+	})
+
+	t.Run("crontab keeps the other entries", func(t *testing.T) {
+		crontab := "MAILTO=me\n\n0 * * * * other\n1 2 * * * old # envswitch-backup\n"
+		assert.Equal(t, "MAILTO=me\n\n0 * * * * other\nnew # envswitch-backup\n", updateCrontab(crontab, "new # envswitch-backup"))
+		assert.Equal(t, "MAILTO=me\n\n0 * * * * other\n", updateCrontab(crontab, ""))
+		assert.Equal(t, "", updateCrontab("1 2 * * * old # envswitch-backup\n", ""))
+	})
+
+	t.Run("systemd", func(t *testing.T) {
+		service, timer := systemdUnits(s)
+		assert.Contains(t, service, `ExecStart="/opt/my tools/envswitch" backup create --all`)
+		assert.Contains(t, service, "StandardOutput=append:/home/me/.envswitch/logs/backup-schedule.log")
+		assert.Contains(t, timer, "OnCalendar=*-*-* 03:05:00\nPersistent=true")
+	})
+
+	t.Run("launchd", func(t *testing.T) {
+		plist := launchdPlist(s)
+		assert.Contains(t, plist, "<string>com.envswitch.backup</string>")
+		assert.Contains(t, plist, "<string>/opt/my tools/envswitch</string>\n\t\t<string>backup</string>")
+		assert.Contains(t, plist, "<key>Hour</key>\n\t\t<integer>3</integer>\n\t\t<key>Minute</key>\n\t\t<integer>5</integer>")
+		assert.NotContains(t, plist, "Weekday")
+	})
+
+	t.Run("schtasks", func(t *testing.T) {
+		args := schtasksArgs(s)
+		assert.Equal(t, []string{"/Create", "/F", "/TN", "envswitch-backup", "/TR", `"/opt/my tools/envswitch" backup create --all`,
+			"/SC", "DAILY", "/ST", "03:05"}, args)
+	})
+}
@@ -0,0 +1,169 @@
+//go:build !darwin && !windows
+
+package archive
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/hugofrely/envswitch/internal/platform"
+)
+
+// systemScheduler returns a systemd timer of the user when systemd runs one,
+// a cron entry otherwise
+func systemScheduler(name string) (scheduler, error) {
+	switch name {
+	case "systemd":
+		return systemdScheduler{}, nil
+	case "cron":
+		return cronScheduler{}, nil
+	case "":
+		if systemdUserAvailable() {
+			return systemdScheduler{}, nil
+		}
+		if _, err := exec.LookPath("crontab"); err == nil {
+			return cronScheduler{}, nil
+		}
+		return nil, fmt.Errorf("neither systemd nor crontab is available to schedule backups")
+	default:
+		return nil, fmt.Errorf("schedule installed with %s, not available on this system", name)
+	}
+}
+
+// systemdUserAvailable reports whether the user has a systemd instance
+func systemdUserAvailable() bool {
+	if _, err := exec.LookPath("systemctl"); err != nil {
+		return false
+	}
+	return exec.Command("systemctl", "--user", "show-environment").Run() == nil
+}
+
+// systemdScheduler installs the schedule as a timer of the user's systemd
+type systemdScheduler struct{}
+
+func (systemdScheduler) name() string { return "systemd" }
+
+func (systemdScheduler) install(s *Schedule) (string, error) {
+	dir, err := systemdUnitsDir()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	service, timer := systemdUnits(s)
+	timerPath := filepath.Join(dir, scheduleName+".timer")
+	if err := os.WriteFile(filepath.Join(dir, scheduleName+".service"), []byte(service), 0644); err != nil {
+		return "", fmt.Errorf("failed to write service unit: %w", err)
+	}
+	if err := os.WriteFile(timerPath, []byte(timer), 0644); err != nil {
+		return "", fmt.Errorf("failed to write timer unit: %w", err)
+	}
+
+	if err := systemctl("daemon-reload"); err != nil {
+		return "", err
+	}
+	if err := systemctl("enable", "--now", scheduleName+".timer"); err != nil {
+		return "", err
+	}
+	return timerPath, nil
+}
+
+func (systemdScheduler) remove(s *Schedule) error {
+	dir, err := systemdUnitsDir()
+	if err != nil {
+		return err
+	}
+	_ = systemctl("disable", "--now", scheduleName+".timer")
+	for _, unit := range []string{scheduleName + ".timer", scheduleName + ".service"} {
+		if err := os.Remove(filepath.Join(dir, unit)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return systemctl("daemon-reload")
+}
+
+func (systemdScheduler) installed(s *Schedule) bool {
+	return exec.Command("systemctl", "--user", "is-enabled", "--quiet", scheduleName+".timer").Run() == nil
+}
+
+// systemctl runs systemctl on the user's systemd
+func systemctl(args ...string) error {
+	// #nosec G204 - Arguments are envswitch's own unit names
+	output, err := exec.Command("systemctl", append([]string{"--user"}, args...)...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("systemctl %s: %s: %w", strings.Join(args, " "), strings.TrimSpace(string(output)), err)
+	}
+	return nil
+}
+
+// systemdUnitsDir returns the directory of the user's systemd units
+func systemdUnitsDir() (string, error) {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "systemd", "user"), nil
+	}
+	home, err := platform.HomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "systemd", "user"), nil
+}
+
+// cronScheduler installs the schedule as an entry of the user's crontab
+type cronScheduler struct{}
+
+func (cronScheduler) name() string { return "cron" }
+
+func (cronScheduler) install(s *Schedule) (string, error) {
+	crontab, err := readCrontab()
+	if err != nil {
+		return "", err
+	}
+	if err := writeCrontab(updateCrontab(crontab, cronEntry(s))); err != nil {
+		return "", err
+	}
+	return "crontab", nil
+}
+
+func (cronScheduler) remove(s *Schedule) error {
+	crontab, err := readCrontab()
+	if err != nil {
+		return err
+	}
+	return writeCrontab(updateCrontab(crontab, ""))
+}
+
+func (cronScheduler) installed(s *Schedule) bool {
+	crontab, err := readCrontab()
+	return err == nil && strings.Contains(crontab, cronMarker)
+}
+
+// readCrontab returns the user's crontab, empty when there is none. Any other
+// failure is an error, so that the entries of the user are never dropped.
+func readCrontab() (string, error) {
+	var stderr strings.Builder
+	cmd := exec.Command("crontab", "-l")
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		if strings.Contains(stderr.String(), "no crontab") {
+			return "", nil
+		}
+		return "", fmt.Errorf("crontab -l: %s: %w", strings.TrimSpace(stderr.String()), err)
+	}
+	return string(output), nil
+}
+
+// writeCrontab replaces the user's crontab
+func writeCrontab(crontab string) error {
+	cmd := exec.Command("crontab", "-")
+	cmd.Stdin = strings.NewReader(crontab)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("crontab: %s: %w", strings.TrimSpace(string(output)), err)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package archive
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// schtasksScheduler installs the schedule as a task of the Task Scheduler
+type schtasksScheduler struct{}
+
+func systemScheduler(name string) (scheduler, error) {
+	if name != "" && name != "schtasks" {
+		return nil, fmt.Errorf("schedule installed with %s, not available on Windows", name)
+	}
+	return schtasksScheduler{}, nil
+}
+
+func (schtasksScheduler) name() string { return "schtasks" }
+
+func (schtasksScheduler) install(s *Schedule) (string, error) {
+	// #nosec G204 - The task runs envswitch's own command
+	if output, err := exec.Command("schtasks", schtasksArgs(s)...).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("schtasks: %s: %w", strings.TrimSpace(string(output)), err)
+	}
+	return scheduleName, nil
+}
+
+func (schtasksScheduler) remove(s *Schedule) error {
+	if output, err := exec.Command("schtasks", "/Delete", "/F", "/TN", scheduleName).CombinedOutput(); err != nil {
+		return fmt.Errorf("schtasks: %s: %w", strings.TrimSpace(string(output)), err)
+	}
+	return nil
+}
+
+func (schtasksScheduler) installed(s *Schedule) bool {
+	return exec.Command("schtasks", "/Query", "/TN", scheduleName).Run() == nil
+}
@@ -0,0 +1,155 @@
+package archive
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hugofrely/envswitch/internal/storage"
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+// shelvedDirName is the directory of the archive store holding the
+// environments put away with 'envswitch archive'
+const shelvedDirName = "environments"
+
+// GetShelvedDir returns the directory of the archived environments. It is
+// apart from the backups so that pruning them never removes one.
+func GetShelvedDir() (string, error) {
+	archiveDir, err := GetArchiveDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(archiveDir, shelvedDirName), nil
+}
+
+// shelvedPath returns the archive of the environment name in the shelved
+// directory
+func shelvedPath(dir, name string) string {
+	return filepath.Join(dir, environment.FlatName(name)+archiveExtension)
+}
+
+// ShelveEnvironment moves an environment into the archive store: it is
+// written to a tar.gz archive, checked, then removed from the environments.
+// Its snapshot history stays for when it is unarchived.
+func ShelveEnvironment(env *environment.Environment) (*Archive, error) {
+	if env == nil {
+		return nil, fmt.Errorf("environment cannot be nil")
+	}
+
+	dir, err := GetShelvedDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get archive directory: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create archive directory: %w", err)
+	}
+
+	archivePath := shelvedPath(dir, env.Name)
+	if _, err := os.Stat(archivePath); err == nil {
+		return nil, fmt.Errorf("an archived environment '%s' already exists (unarchive it first)", env.Name)
+	}
+
+	if err := storage.CheckDiskSpace(dir, storage.PathSize(env.Path)); err != nil {
+		return nil, err
+	}
+	if err := writeEnvironmentArchive(env, archivePath); err != nil {
+		return nil, err
+	}
+
+	// The environment is only removed once its archive reads back
+	if _, err := InspectArchive(archivePath); err != nil {
+		_ = os.Remove(archivePath)
+		return nil, fmt.Errorf("archive of '%s' is unreadable, nothing was removed: %w", env.Name, err)
+	}
+	if err := os.RemoveAll(env.Path); err != nil {
+		return nil, fmt.Errorf("failed to remove environment: %w", err)
+	}
+	environment.RemoveEmptyGroups(env.Path)
+
+	archive := &Archive{
+		Path:        archivePath,
+		EnvName:     env.Name,
+		ArchivedAt:  time.Now(),
+		OriginalEnv: env,
+	}
+	if info, err := os.Stat(archivePath); err == nil {
+		archive.SizeBytes = info.Size()
+	}
+	return archive, nil
+}
+
+// ListShelved returns the archived environments, sorted by name
+func ListShelved() ([]*Archive, error) {
+	dir, err := GetShelvedDir()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return []*Archive{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive directory: %w", err)
+	}
+
+	archives := make([]*Archive, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), archiveExtension) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		archives = append(archives, &Archive{
+			Path:       filepath.Join(dir, entry.Name()),
+			EnvName:    envNameFromFileName(entry.Name()),
+			ArchivedAt: info.ModTime(),
+			SizeBytes:  info.Size(),
+		})
+	}
+
+	sort.Slice(archives, func(i, j int) bool {
+		return archives[i].EnvName < archives[j].EnvName
+	})
+	return archives, nil
+}
+
+// UnshelveEnvironment restores an archived environment, under newName when
+// it is not empty, and removes its archive
+func UnshelveEnvironment(name, newName string) error {
+	dir, err := GetShelvedDir()
+	if err != nil {
+		return err
+	}
+
+	archivePath := shelvedPath(dir, name)
+	if _, err := os.Stat(archivePath); os.IsNotExist(err) {
+		return fmt.Errorf("no archived environment '%s' (see 'envswitch list --archived')", name)
+	}
+
+	target := name
+	if newName != "" {
+		target = newName
+	}
+	exists, err := environment.EnvironmentExists(target)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("environment '%s' already exists (use --name to unarchive under another name)", target)
+	}
+
+	if err := ImportEnvironment(archivePath, ImportOptions{NewName: newName}); err != nil {
+		return err
+	}
+	if err := os.Remove(archivePath); err != nil {
+		return fmt.Errorf("environment '%s' restored but its archive could not be removed: %w", target, err)
+	}
+	return nil
+}
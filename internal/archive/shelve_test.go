@@ -0,0 +1,112 @@
+package archive
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+func TestShelveEnvironment(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+
+	envDir := filepath.Join(tempHome, ".envswitch", "environments")
+	envPath := filepath.Join(envDir, "client-a", "prod")
+	if err := os.MkdirAll(filepath.Join(envPath, "snapshots", "kubectl"), 0755); err != nil {
+		t.Fatalf("Failed to create environment: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(envPath, "snapshots", "kubectl", "config"), []byte("kubeconfig"), 0600); err != nil {
+		t.Fatalf("Failed to write snapshot: %v", err)
+	}
+	env := &environment.Environment{Name: "client-a/prod", Path: envPath, Tools: map[string]environment.ToolConfig{}}
+	if err := env.Save(); err != nil {
+		t.Fatalf("Failed to save environment: %v", err)
+	}
+
+	arch, err := ShelveEnvironment(env)
+	if err != nil {
+		t.Fatalf("ShelveEnvironment failed: %v", err)
+	}
+	if _, err := os.Stat(envPath); !os.IsNotExist(err) {
+		t.Error("Expected the environment to be removed")
+	}
+	if _, err := os.Stat(filepath.Join(envDir, "client-a")); !os.IsNotExist(err) {
+		t.Error("Expected the empty group to be removed")
+	}
+
+	// Backups never include it, so pruning them leaves it
+	backups, err := ListArchives()
+	if err != nil {
+		t.Fatalf("ListArchives failed: %v", err)
+	}
+	if len(backups) != 0 {
+		t.Errorf("Expected no backups, got %d", len(backups))
+	}
+
+	shelved, err := ListShelved()
+	if err != nil {
+		t.Fatalf("ListShelved failed: %v", err)
+	}
+	if len(shelved) != 1 || shelved[0].EnvName != "client-a/prod" || shelved[0].Path != arch.Path {
+		t.Fatalf("Expected client-a/prod to be archived, got %+v", shelved)
+	}
+
+	if err := UnshelveEnvironment("client-a/prod", ""); err != nil {
+		t.Fatalf("UnshelveEnvironment failed: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(envPath, "snapshots", "kubectl", "config"))
+	if err != nil || string(data) != "kubeconfig" {
+		t.Errorf("Expected the snapshot to be restored, got %q (%v)", data, err)
+	}
+	if _, err := os.Stat(arch.Path); !os.IsNotExist(err) {
+		t.Error("Expected the archive to be removed")
+	}
+
+	err = UnshelveEnvironment("client-a/prod", "")
+	if err == nil || !strings.Contains(err.Error(), "no archived environment") {
+		t.Errorf("Expected a missing archive error, got %v", err)
+	}
+}
+
+func TestUnshelveEnvironmentRefusesExisting(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+
+	envPath := filepath.Join(tempHome, ".envswitch", "environments", "old")
+	if err := os.MkdirAll(envPath, 0755); err != nil {
+		t.Fatalf("Failed to create environment: %v", err)
+	}
+	env := &environment.Environment{Name: "old", Path: envPath, Tools: map[string]environment.ToolConfig{}}
+	if err := env.Save(); err != nil {
+		t.Fatalf("Failed to save environment: %v", err)
+	}
+	if _, err := ShelveEnvironment(env); err != nil {
+		t.Fatalf("ShelveEnvironment failed: %v", err)
+	}
+
+	// A new environment took the name
+	if err := os.MkdirAll(envPath, 0755); err != nil {
+		t.Fatalf("Failed to create environment: %v", err)
+	}
+	if err := env.Save(); err != nil {
+		t.Fatalf("Failed to save environment: %v", err)
+	}
+
+	err := UnshelveEnvironment("old", "")
+	if err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("Expected an existing environment error, got %v", err)
+	}
+	if err := UnshelveEnvironment("old", "old-2023"); err != nil {
+		t.Fatalf("UnshelveEnvironment with a new name failed: %v", err)
+	}
+	restored, err := environment.LoadEnvironment("old-2023")
+	if err != nil {
+		t.Fatalf("Failed to load restored environment: %v", err)
+	}
+	if restored.Name != "old-2023" {
+		t.Errorf("Expected the restored environment to be renamed, got %s", restored.Name)
+	}
+}
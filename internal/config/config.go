@@ -4,10 +4,113 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
+
+	"github.com/hugofrely/envswitch/internal/features"
+	"github.com/hugofrely/envswitch/internal/platform"
+	"github.com/hugofrely/envswitch/internal/storage"
+	"github.com/hugofrely/envswitch/pkg/environment"
 )
 
+// logLevelsPrefix prefixes the keys of per-subsystem log levels (log_levels.hooks)
+const logLevelsPrefix = "log_levels."
+
+// aliasesPrefix prefixes the keys of user-defined command aliases (aliases.sw)
+const aliasesPrefix = "aliases."
+
+// featuresPrefix prefixes the keys of feature flags (features.symlink_mode)
+const featuresPrefix = "features."
+
+// toolTimeoutsPrefix prefixes the keys of per-tool timeouts (tool_timeouts.gcloud)
+const toolTimeoutsPrefix = "tool_timeouts."
+
+// currentVersion is the version of config.yaml written by this release, see
+// migrate
+const currentVersion = "1.1"
+
+// DefaultAutoSnapshotDebounce is how long tool configs must stay unchanged
+// before the daemon refreshes their snapshots, when auto_snapshot_debounce is
+// not set
+const DefaultAutoSnapshotDebounce = 10 * time.Second
+
+// envOverridePrefix prefixes the environment variables overriding settings,
+// e.g. ENVSWITCH_LOG_LEVEL
+const envOverridePrefix = "ENVSWITCH_"
+
+// Keys are the settings that can be set by name, and overridden with
+// ENVSWITCH_<KEY> environment variables
+var Keys = []string{
+	"auto_save_before_switch",
+	"verify_after_switch",
+	"backup_before_switch",
+	"backup_retention",
+	"backup_mirror_dir",
+	"auto_snapshot",
+	"auto_snapshot_debounce",
+	"enable_prompt_integration",
+	"prompt_format",
+	"prompt_color",
+	"isolate_shell_history",
+	"log_level",
+	"log_format",
+	"log_max_size_mb",
+	"log_rotate_daily",
+	"log_max_files",
+	"switch_log_retention",
+	"snapshot_history_limit",
+	"ssh_include_private_keys",
+	"gcloud_include_caches",
+	"git_check_signing",
+	"git_mode",
+	"kubectl_mode",
+	"tool_timeout",
+	"keyring_storage",
+	"color_output",
+	"show_timestamps",
+	"default_editor",
+	"update_check",
+	"auto_sync",
+	"sync_provider",
+	"sync_repo",
+	"sync_encrypt",
+	"plugin_registry",
+}
+
+// KeyValues returns the values accepted for a key of Keys, nil when it takes
+// any value
+func KeyValues(key string) []string {
+	switch key {
+	case "auto_save_before_switch":
+		return []string{"true", "false", "prompt"}
+	case "prompt_color":
+		return PromptColors
+	case "kubectl_mode":
+		return KubectlModes
+	case "git_mode":
+		return GitModes
+	case "log_level":
+		return LogLevels
+	case "log_format":
+		return LogFormats
+	case "sync_provider":
+		return []string{"git"}
+	}
+	if value, err := DefaultConfig().Get(key); err == nil {
+		if _, ok := value.(bool); ok {
+			return []string{"true", "false"}
+		}
+	}
+	return nil
+}
+
+// DefaultKeyringFiles are the snapshot files stored in the keyring when
+// keyring_storage is on and keyring_files is not set
+var DefaultKeyringFiles = []string{"aws/credentials", "docker/config.json"}
+
 // Config represents the global configuration for envswitch
 type Config struct {
 	Version string `yaml:"version"`
@@ -17,30 +120,104 @@ type Config struct {
 	VerifyAfterSwitch    bool   `yaml:"verify_after_switch"`
 	BackupBeforeSwitch   bool   `yaml:"backup_before_switch"`
 	BackupRetention      int    `yaml:"backup_retention"`
+	BackupMirrorDir      string `yaml:"backup_mirror_dir,omitempty"` // second copy of every backup
+
+	// Snapshots refreshed by 'envswitch daemon' when tool configs change
+	AutoSnapshot         bool   `yaml:"auto_snapshot"`
+	AutoSnapshotDebounce string `yaml:"auto_snapshot_debounce,omitempty"` // quiet time before refreshing, e.g. 10s
 
 	// Shell integration
 	EnablePromptIntegration bool   `yaml:"enable_prompt_integration"`
 	PromptFormat            string `yaml:"prompt_format"`
 	PromptColor             string `yaml:"prompt_color"`
+	IsolateShellHistory     bool   `yaml:"isolate_shell_history"` // per-environment HISTFILE
 
 	// Logging
-	LogLevel string `yaml:"log_level"` // debug | info | warn | error
-	LogFile  string `yaml:"log_file"`
+	LogLevel  string            `yaml:"log_level"`            // debug | info | warn | error
+	LogLevels map[string]string `yaml:"log_levels,omitempty"` // per subsystem, e.g. tools.restore: debug
+	LogFile   string            `yaml:"log_file"`
+	LogFormat string            `yaml:"log_format"` // text | json, of the log file and switch logs
+
+	// Rotation of the log file: it is renamed to <log_file>.<timestamp> once
+	// larger than LogMaxSizeMB (0: no limit), or on the first write of a new
+	// day with LogRotateDaily, keeping the LogMaxFiles most recent ones
+	LogMaxSizeMB   int  `yaml:"log_max_size_mb"`
+	LogRotateDaily bool `yaml:"log_rotate_daily"`
+	LogMaxFiles    int  `yaml:"log_max_files"`
+
+	// Number of per-switch logs kept in ~/.envswitch/logs, 0 to not write them
+	SwitchLogRetention int `yaml:"switch_log_retention"`
+
+	// Number of saved states kept per environment by 'envswitch save', 0 to
+	// not keep any
+	SnapshotHistoryLimit int `yaml:"snapshot_history_limit"`
 
 	// Tools
-	ExcludeTools []string `yaml:"exclude_tools"`
+	ExcludeTools          []string `yaml:"exclude_tools"`
+	ExcludePatterns       []string `yaml:"exclude_patterns,omitempty"` // globs left out of tool snapshots, e.g. **/*.log
+	SSHIncludePrivateKeys bool     `yaml:"ssh_include_private_keys"`
+	GCloudIncludeCaches   bool     `yaml:"gcloud_include_caches"`      // also snapshot gcloud logs and caches
+	GitCheckSigning       bool     `yaml:"git_check_signing"`          // check the git signing key after a switch
+	GitMode               string   `yaml:"git_mode,omitempty"`         // "full" (default) or "includeif"
+	KubectlMode           string   `yaml:"kubectl_mode,omitempty"`     // "full" (default) or "context"
+	KubectlContexts       []string `yaml:"kubectl_contexts,omitempty"` // contexts carried besides the current one in context mode
+
+	// How long a tool may take to snapshot or restore, e.g. 2m, no limit when
+	// empty: the commands it runs are killed past it, file copies finish.
+	// ToolTimeouts overrides it for some tools, by tool name.
+	ToolTimeout  string            `yaml:"tool_timeout,omitempty"`
+	ToolTimeouts map[string]string `yaml:"tool_timeouts,omitempty"`
+
+	// Environment variables captured for every environment, and the ones never
+	// captured unless named exactly, as names or globs like AWS_* and *_TOKEN
+	EnvVarsInclude []string `yaml:"env_vars_include,omitempty"`
+	EnvVarsDeny    []string `yaml:"env_vars_deny,omitempty"`
+
+	// Sensitive snapshot files stored in the OS keyring, the snapshots only
+	// keeping a reference
+	KeyringStorage bool     `yaml:"keyring_storage"`
+	KeyringFiles   []string `yaml:"keyring_files,omitempty"` // tool/path, DefaultKeyringFiles when empty
+
+	// Plugins
+	PluginRegistry string `yaml:"plugin_registry,omitempty"` // URL or path of the plugin index
+
+	// Sync of the environments through a git repository
+	AutoSync     bool   `yaml:"auto_sync"`               // push after each switch and save
+	SyncProvider string `yaml:"sync_provider,omitempty"` // "git", the only provider
+	SyncRepo     string `yaml:"sync_repo,omitempty"`     // remote URL
+	SyncEncrypt  bool   `yaml:"sync_encrypt"`            // encrypt synced snapshots with ~/.envswitch/sync.key
 
 	// UI
-	ColorOutput    bool `yaml:"color_output"`
-	ShowTimestamps bool `yaml:"show_timestamps"`
+	ColorOutput    bool   `yaml:"color_output"`
+	ShowTimestamps bool   `yaml:"show_timestamps"`
+	DefaultEditor  string `yaml:"default_editor,omitempty"` // editor of 'config edit', $VISUAL or $EDITOR when empty
+	UpdateCheck    bool   `yaml:"update_check"`             // check for new versions once a day, in the background
+
+	// Command aliases, e.g. sw: switch --verify
+	Aliases map[string]string `yaml:"aliases,omitempty"`
+
+	// Feature flags that differ from their default, e.g. symlink_mode: true
+	Features map[string]bool `yaml:"features,omitempty"`
+
+	// Hooks run on every switch, around the hooks of the target environment
+	Hooks GlobalHooks `yaml:"hooks,omitempty"`
+
+	// overrides are the layers applied over config.yaml, see LoadConfig
+	overrides []string
+}
+
+// GlobalHooks are the switch hooks shared by all environments
+type GlobalHooks struct {
+	PreSwitch  []environment.Hook `yaml:"pre_switch,omitempty"`
+	PostSwitch []environment.Hook `yaml:"post_switch,omitempty"`
 }
 
 // DefaultConfig returns a config with default values
 func DefaultConfig() *Config {
-	home, _ := os.UserHomeDir()
+	home, _ := platform.HomeDir()
 	return &Config{
-		Version:                 "1.0",
-		AutoSaveBeforeSwitch:    "false",
+		Version:                 currentVersion,
+		AutoSaveBeforeSwitch:    "true",
 		VerifyAfterSwitch:       false,
 		BackupBeforeSwitch:      true,
 		BackupRetention:         10,
@@ -49,20 +226,72 @@ func DefaultConfig() *Config {
 		PromptColor:             "blue",
 		LogLevel:                "warn",
 		LogFile:                 filepath.Join(home, ".envswitch", "envswitch.log"),
+		LogFormat:               "text",
+		LogMaxSizeMB:            10,
+		LogMaxFiles:             5,
+		SwitchLogRetention:      20,
+		SnapshotHistoryLimit:    environment.DefaultSnapshotHistoryLimit,
 		ExcludeTools:            []string{},
+		SSHIncludePrivateKeys:   false,
+		GitCheckSigning:         true,
 		ColorOutput:             true,
 		ShowTimestamps:          true,
+		UpdateCheck:             true,
 	}
 }
 
 // GetConfigPath returns the path to the config file
 func GetConfigPath() string {
-	home, _ := os.UserHomeDir()
+	home, _ := platform.HomeDir()
 	return filepath.Join(home, ".envswitch", "config.yaml")
 }
 
-// LoadConfig loads the configuration from file
+// GetLocalConfigPath returns the path to the per-machine config file, whose
+// settings override the ones of config.yaml
+func GetLocalConfigPath() string {
+	return filepath.Join(filepath.Dir(GetConfigPath()), "config.local.yaml")
+}
+
+// LoadConfig loads the configuration in layers, each overriding the previous
+// one: the defaults, config.yaml, config.local.yaml, then ENVSWITCH_<KEY>
+// environment variables. Lists in config.local.yaml replace the ones of
+// config.yaml, maps are merged. A config with overrides cannot be saved,
+// change config.yaml through LoadBaseConfig.
 func LoadConfig() (*Config, error) {
+	config, err := LoadBaseConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	localPath := GetLocalConfigPath()
+	data, err := os.ReadFile(localPath)
+	if err == nil {
+		if err := yaml.Unmarshal(data, config); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", filepath.Base(localPath), err)
+		}
+		config.overrides = append(config.overrides, filepath.Base(localPath))
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read %s: %w", filepath.Base(localPath), err)
+	}
+
+	for _, key := range Keys {
+		name := envOverridePrefix + strings.ToUpper(key)
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		if err := config.Set(key, ParseValue(key, value)); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", name, err)
+		}
+		config.overrides = append(config.overrides, name)
+	}
+
+	return config, nil
+}
+
+// LoadBaseConfig loads config.yaml alone, without the overrides of
+// config.local.yaml and the environment, to change and save it
+func LoadBaseConfig() (*Config, error) {
 	configPath := GetConfigPath()
 
 	// If config doesn't exist, return default config
@@ -76,20 +305,48 @@ func LoadConfig() (*Config, error) {
 	}
 
 	config := DefaultConfig()
+	config.Version = ""
 	if err := yaml.Unmarshal(data, config); err != nil {
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
+	config.migrate()
 
 	return config, nil
 }
 
-// Save saves the configuration to file
+// migrate upgrades a config.yaml written by an earlier release to
+// currentVersion, keeping its behavior
+func (c *Config) migrate() {
+	if c.Version == currentVersion {
+		return
+	}
+	// Before 1.1 switch saved the environment being left whatever
+	// auto_save_before_switch said, and 'envswitch init' wrote false
+	if c.Version == "" || c.Version == "1.0" {
+		if c.AutoSaveBeforeSwitch == "false" {
+			c.AutoSaveBeforeSwitch = "true"
+		}
+	}
+	c.Version = currentVersion
+}
+
+// Overrides returns the layers applied over config.yaml: config.local.yaml
+// and the ENVSWITCH_<KEY> environment variables that are set
+func (c *Config) Overrides() []string {
+	return c.overrides
+}
+
+// Save saves the configuration to config.yaml. It fails for a config loaded
+// with overrides, which would otherwise end up in config.yaml.
 func (c *Config) Save() error {
+	if len(c.overrides) > 0 {
+		return fmt.Errorf("config has overrides (%s), load it with LoadBaseConfig to save it", strings.Join(c.overrides, ", "))
+	}
 	configPath := GetConfigPath()
 
 	// Create directory if it doesn't exist
 	configDir := filepath.Dir(configPath)
-	if err := os.MkdirAll(configDir, 0755); err != nil {
+	if err := storage.MkdirPrivate(configDir); err != nil {
 		return fmt.Errorf("failed to create config directory: %w", err)
 	}
 
@@ -98,7 +355,7 @@ func (c *Config) Save() error {
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
 
-	if err := os.WriteFile(configPath, data, 0644); err != nil {
+	if err := os.WriteFile(configPath, data, 0600); err != nil {
 		return fmt.Errorf("failed to write config file: %w", err)
 	}
 
@@ -116,21 +373,96 @@ func (c *Config) Get(key string) (interface{}, error) {
 		return c.BackupBeforeSwitch, nil
 	case "backup_retention":
 		return c.BackupRetention, nil
+	case "backup_mirror_dir":
+		return c.BackupMirrorDir, nil
+	case "auto_snapshot":
+		return c.AutoSnapshot, nil
+	case "auto_snapshot_debounce":
+		return c.AutoSnapshotDebounce, nil
 	case "enable_prompt_integration":
 		return c.EnablePromptIntegration, nil
 	case "prompt_format":
 		return c.PromptFormat, nil
 	case "prompt_color":
 		return c.PromptColor, nil
+	case "isolate_shell_history":
+		return c.IsolateShellHistory, nil
 	case "log_level":
 		return c.LogLevel, nil
 	case "log_file":
 		return c.LogFile, nil
+	case "log_format":
+		return c.LogFormat, nil
+	case "log_max_size_mb":
+		return c.LogMaxSizeMB, nil
+	case "log_rotate_daily":
+		return c.LogRotateDaily, nil
+	case "log_max_files":
+		return c.LogMaxFiles, nil
+	case "switch_log_retention":
+		return c.SwitchLogRetention, nil
+	case "snapshot_history_limit":
+		return c.SnapshotHistoryLimit, nil
+	case "ssh_include_private_keys":
+		return c.SSHIncludePrivateKeys, nil
+	case "gcloud_include_caches":
+		return c.GCloudIncludeCaches, nil
+	case "git_check_signing":
+		return c.GitCheckSigning, nil
+	case "git_mode":
+		return c.GitMode, nil
+	case "kubectl_mode":
+		return c.KubectlMode, nil
+	case "tool_timeout":
+		return c.ToolTimeout, nil
+	case "keyring_storage":
+		return c.KeyringStorage, nil
 	case "color_output":
 		return c.ColorOutput, nil
 	case "show_timestamps":
 		return c.ShowTimestamps, nil
+	case "default_editor":
+		return c.DefaultEditor, nil
+	case "update_check":
+		return c.UpdateCheck, nil
+	case "auto_sync":
+		return c.AutoSync, nil
+	case "sync_provider":
+		return c.SyncProvider, nil
+	case "sync_repo":
+		return c.SyncRepo, nil
+	case "sync_encrypt":
+		return c.SyncEncrypt, nil
+	case "plugin_registry":
+		return c.PluginRegistry, nil
 	default:
+		if subsystem, ok := strings.CutPrefix(key, logLevelsPrefix); ok {
+			level, exists := c.LogLevels[subsystem]
+			if !exists {
+				return c.LogLevel, nil
+			}
+			return level, nil
+		}
+		if toolName, ok := strings.CutPrefix(key, toolTimeoutsPrefix); ok {
+			timeout, exists := c.ToolTimeouts[toolName]
+			if !exists {
+				return c.ToolTimeout, nil
+			}
+			return timeout, nil
+		}
+		if name, ok := strings.CutPrefix(key, aliasesPrefix); ok {
+			expansion, exists := c.Aliases[name]
+			if !exists {
+				return nil, fmt.Errorf("alias not defined: %s", name)
+			}
+			return expansion, nil
+		}
+		if name, ok := strings.CutPrefix(key, featuresPrefix); ok {
+			if err := features.Validate(name); err != nil {
+				return nil, err
+			}
+			return c.FeatureEnabled(name), nil
+		}
 		return nil, fmt.Errorf("unknown config key: %s", key)
 	}
 }
@@ -146,23 +478,99 @@ func (c *Config) Set(key string, value interface{}) error {
 		return c.setBoolValue(&c.BackupBeforeSwitch, value, key)
 	case "backup_retention":
 		return c.setIntValue(&c.BackupRetention, value, key)
+	case "backup_mirror_dir":
+		return c.setStringValue(&c.BackupMirrorDir, value, key)
+	case "auto_snapshot":
+		return c.setBoolValue(&c.AutoSnapshot, value, key)
+	case "auto_snapshot_debounce":
+		return c.setAutoSnapshotDebounce(value)
 	case "enable_prompt_integration":
 		return c.setBoolValue(&c.EnablePromptIntegration, value, key)
 	case "prompt_format":
 		return c.setStringValue(&c.PromptFormat, value, key)
 	case "prompt_color":
 		return c.setStringValue(&c.PromptColor, value, key)
+	case "isolate_shell_history":
+		return c.setBoolValue(&c.IsolateShellHistory, value, key)
 	case "log_level":
 		return c.setLogLevel(value)
+	case "log_format":
+		return c.setLogFormat(value)
+	case "log_max_size_mb":
+		return c.setIntValue(&c.LogMaxSizeMB, value, key)
+	case "log_rotate_daily":
+		return c.setBoolValue(&c.LogRotateDaily, value, key)
+	case "log_max_files":
+		return c.setIntValue(&c.LogMaxFiles, value, key)
+	case "switch_log_retention":
+		return c.setIntValue(&c.SwitchLogRetention, value, key)
+	case "snapshot_history_limit":
+		return c.setIntValue(&c.SnapshotHistoryLimit, value, key)
+	case "ssh_include_private_keys":
+		return c.setBoolValue(&c.SSHIncludePrivateKeys, value, key)
+	case "gcloud_include_caches":
+		return c.setBoolValue(&c.GCloudIncludeCaches, value, key)
+	case "git_check_signing":
+		return c.setBoolValue(&c.GitCheckSigning, value, key)
+	case "git_mode":
+		return c.setGitMode(value)
+	case "kubectl_mode":
+		return c.setKubectlMode(value)
+	case "tool_timeout":
+		return c.setToolTimeout(value)
+	case "keyring_storage":
+		return c.setBoolValue(&c.KeyringStorage, value, key)
 	case "color_output":
 		return c.setBoolValue(&c.ColorOutput, value, key)
 	case "show_timestamps":
 		return c.setBoolValue(&c.ShowTimestamps, value, key)
+	case "default_editor":
+		return c.setStringValue(&c.DefaultEditor, value, key)
+	case "update_check":
+		return c.setBoolValue(&c.UpdateCheck, value, key)
+	case "auto_sync":
+		return c.setBoolValue(&c.AutoSync, value, key)
+	case "sync_provider":
+		return c.setSyncProvider(value)
+	case "sync_repo":
+		return c.setStringValue(&c.SyncRepo, value, key)
+	case "sync_encrypt":
+		return c.setBoolValue(&c.SyncEncrypt, value, key)
+	case "plugin_registry":
+		return c.setStringValue(&c.PluginRegistry, value, key)
 	default:
+		if subsystem, ok := strings.CutPrefix(key, logLevelsPrefix); ok && subsystem != "" {
+			return c.setSubsystemLogLevel(subsystem, value)
+		}
+		if toolName, ok := strings.CutPrefix(key, toolTimeoutsPrefix); ok && toolName != "" {
+			return c.setPerToolTimeout(toolName, value)
+		}
+		if name, ok := strings.CutPrefix(key, aliasesPrefix); ok && name != "" {
+			return c.setAlias(name, value)
+		}
+		if name, ok := strings.CutPrefix(key, featuresPrefix); ok && name != "" {
+			return c.setFeature(name, value)
+		}
 		return fmt.Errorf("unknown or read-only config key: %s", key)
 	}
 }
 
+// ParseValue converts a value given as text, on the command line or in an
+// environment variable, to the type Set expects for key
+func ParseValue(key, value string) interface{} {
+	// auto_save_before_switch takes "true" and "false" as strings
+	if key == "auto_save_before_switch" {
+		return value
+	}
+	if value == "true" || value == "false" {
+		return value == "true"
+	}
+	if intVal, err := strconv.Atoi(value); err == nil {
+		return intVal
+	}
+	return value
+}
+
 func (c *Config) setAutoSaveBeforeSwitch(value interface{}) error {
 	v, ok := value.(string)
 	if !ok {
@@ -175,18 +583,239 @@ func (c *Config) setAutoSaveBeforeSwitch(value interface{}) error {
 	return nil
 }
 
+func (c *Config) setAutoSnapshotDebounce(value interface{}) error {
+	v, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("invalid type for auto_snapshot_debounce: expected a duration like 10s")
+	}
+	if v != "" {
+		if _, err := parseDuration(v); err != nil {
+			return fmt.Errorf("invalid value for auto_snapshot_debounce: %w", err)
+		}
+	}
+	c.AutoSnapshotDebounce = v
+	return nil
+}
+
+// SensitiveFiles returns the snapshot files to store in the keyring, as
+// tool/path, none when keyring_storage is off
+func (c *Config) SensitiveFiles() []string {
+	if !c.KeyringStorage {
+		return nil
+	}
+	if len(c.KeyringFiles) > 0 {
+		return c.KeyringFiles
+	}
+	return DefaultKeyringFiles
+}
+
+// AutoSnapshotDelay returns how long tool configs must stay unchanged before
+// the daemon refreshes their snapshots
+func (c *Config) AutoSnapshotDelay() (time.Duration, error) {
+	if c.AutoSnapshotDebounce == "" {
+		return DefaultAutoSnapshotDebounce, nil
+	}
+	return parseDuration(c.AutoSnapshotDebounce)
+}
+
+// parseDuration parses a positive duration like 10s
+func parseDuration(value string) (time.Duration, error) {
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("expected a duration like 10s, got '%s'", value)
+	}
+	if duration <= 0 {
+		return 0, fmt.Errorf("must be positive, got '%s'", value)
+	}
+	return duration, nil
+}
+
+// ToolTimeoutFor returns how long the tool may take to snapshot or restore,
+// 0 when there is no limit
+func (c *Config) ToolTimeoutFor(toolName string) (time.Duration, error) {
+	value, exists := c.ToolTimeouts[toolName]
+	if !exists {
+		value = c.ToolTimeout
+	}
+	if value == "" {
+		return 0, nil
+	}
+	return parseDuration(value)
+}
+
+func (c *Config) setToolTimeout(value interface{}) error {
+	v, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("invalid type for tool_timeout: expected a duration like 2m")
+	}
+	if v != "" {
+		if _, err := parseDuration(v); err != nil {
+			return fmt.Errorf("invalid value for tool_timeout: %w", err)
+		}
+	}
+	c.ToolTimeout = v
+	return nil
+}
+
+// setPerToolTimeout sets the timeout of one tool; an empty value removes the
+// override
+func (c *Config) setPerToolTimeout(toolName string, value interface{}) error {
+	key := toolTimeoutsPrefix + toolName
+	v, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("invalid type for %s: expected a duration like 2m", key)
+	}
+	if v == "" {
+		delete(c.ToolTimeouts, toolName)
+		return nil
+	}
+	if _, err := parseDuration(v); err != nil {
+		return fmt.Errorf("invalid value for %s: %w", key, err)
+	}
+	if c.ToolTimeouts == nil {
+		c.ToolTimeouts = make(map[string]string)
+	}
+	c.ToolTimeouts[toolName] = v
+	return nil
+}
+
+func (c *Config) setSyncProvider(value interface{}) error {
+	v, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("invalid type for sync_provider: expected string")
+	}
+	if v != "" && v != "git" {
+		return fmt.Errorf("invalid value for sync_provider: must be 'git'")
+	}
+	c.SyncProvider = v
+	return nil
+}
+
+func (c *Config) setKubectlMode(value interface{}) error {
+	v, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("invalid type for kubectl_mode: expected string")
+	}
+	if v != "" && !containsValue(KubectlModes, v) {
+		return fmt.Errorf("invalid value for kubectl_mode: must be 'full' or 'context'")
+	}
+	c.KubectlMode = v
+	return nil
+}
+
+func (c *Config) setGitMode(value interface{}) error {
+	v, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("invalid type for git_mode: expected string")
+	}
+	if v != "" && !containsValue(GitModes, v) {
+		return fmt.Errorf("invalid value for git_mode: must be 'full' or 'includeif'")
+	}
+	c.GitMode = v
+	return nil
+}
+
 func (c *Config) setLogLevel(value interface{}) error {
 	v, ok := value.(string)
 	if !ok {
 		return fmt.Errorf("invalid type for log_level: expected string")
 	}
-	if v != "debug" && v != "info" && v != "warn" && v != "error" {
+	if !isValidLogLevel(v) {
 		return fmt.Errorf("invalid value for log_level: must be 'debug', 'info', 'warn', or 'error'")
 	}
 	c.LogLevel = v
 	return nil
 }
 
+func (c *Config) setLogFormat(value interface{}) error {
+	v, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("invalid type for log_format: expected string")
+	}
+	if !containsValue(LogFormats, v) {
+		return fmt.Errorf("invalid value for log_format: must be 'text' or 'json'")
+	}
+	c.LogFormat = v
+	return nil
+}
+
+// setSubsystemLogLevel sets the level of one subsystem; an empty value
+// removes the override
+func (c *Config) setSubsystemLogLevel(subsystem string, value interface{}) error {
+	key := logLevelsPrefix + subsystem
+	v, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("invalid type for %s: expected string", key)
+	}
+	if v == "" {
+		delete(c.LogLevels, subsystem)
+		return nil
+	}
+	if !isValidLogLevel(v) {
+		return fmt.Errorf("invalid value for %s: must be 'debug', 'info', 'warn', or 'error'", key)
+	}
+	if c.LogLevels == nil {
+		c.LogLevels = make(map[string]string)
+	}
+	c.LogLevels[subsystem] = v
+	return nil
+}
+
+// setAlias defines a command alias; an empty value removes it
+func (c *Config) setAlias(name string, value interface{}) error {
+	key := aliasesPrefix + name
+	v, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("invalid type for %s: expected string", key)
+	}
+	if strings.ContainsAny(name, " \t") || strings.HasPrefix(name, "-") {
+		return fmt.Errorf("invalid alias name: %s", name)
+	}
+	v = strings.TrimSpace(v)
+	if v == "" {
+		delete(c.Aliases, name)
+		return nil
+	}
+	if c.Aliases == nil {
+		c.Aliases = make(map[string]string)
+	}
+	c.Aliases[name] = v
+	return nil
+}
+
+// setFeature turns a feature flag on or off. Only values that differ from the
+// feature's default are kept in the file.
+func (c *Config) setFeature(name string, value interface{}) error {
+	key := featuresPrefix + name
+	v, ok := value.(bool)
+	if !ok {
+		return fmt.Errorf("invalid type for %s: expected bool", key)
+	}
+	if err := features.Validate(name); err != nil {
+		return err
+	}
+
+	feature, _ := features.Lookup(name)
+	if v == feature.Default {
+		delete(c.Features, name)
+		return nil
+	}
+	if c.Features == nil {
+		c.Features = make(map[string]bool)
+	}
+	c.Features[name] = v
+	return nil
+}
+
+// FeatureEnabled reports whether a feature flag is on for this user
+func (c *Config) FeatureEnabled(name string) bool {
+	return features.Enabled(name, c.Features)
+}
+
+func isValidLogLevel(level string) bool {
+	return containsValue(LogLevels, level)
+}
+
 func (c *Config) setStringValue(field *string, value interface{}, key string) error {
 	v, ok := value.(string)
 	if !ok {
@@ -4,20 +4,27 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/hugofrely/envswitch/internal/features"
 )
 
+func init() {
+	features.Register(features.Feature{Name: "config_test_experimental", Stage: features.StageExperimental})
+}
+
 func TestDefaultConfig(t *testing.T) {
 	cfg := DefaultConfig()
 
 	t.Run("has correct version", func(t *testing.T) {
-		assert.Equal(t, "1.0", cfg.Version)
+		assert.Equal(t, "1.1", cfg.Version)
 	})
 
 	t.Run("has sensible defaults", func(t *testing.T) {
-		assert.Equal(t, "false", cfg.AutoSaveBeforeSwitch) // Changed to false to match new workflow
+		assert.Equal(t, "true", cfg.AutoSaveBeforeSwitch)
 		assert.False(t, cfg.VerifyAfterSwitch)
 		assert.True(t, cfg.BackupBeforeSwitch)
 		assert.Equal(t, 10, cfg.BackupRetention)
@@ -27,6 +34,9 @@ func TestDefaultConfig(t *testing.T) {
 		assert.Equal(t, "warn", cfg.LogLevel)
 		assert.True(t, cfg.ColorOutput)
 		assert.True(t, cfg.ShowTimestamps)
+		assert.False(t, cfg.SSHIncludePrivateKeys)
+		assert.True(t, cfg.UpdateCheck)
+		assert.True(t, cfg.GitCheckSigning)
 	})
 
 	t.Run("has empty exclude tools", func(t *testing.T) {
@@ -79,6 +89,44 @@ func TestLoadConfig(t *testing.T) {
 		assert.Equal(t, 20, loadedCfg.BackupRetention)
 	})
 
+	t.Run("loads global hooks", func(t *testing.T) {
+		configPath := filepath.Join(tempDir, ".envswitch", "config.yaml")
+		require.NoError(t, os.WriteFile(configPath, []byte(`hooks:
+  post_switch:
+    - command: "pkill -f gopls || true"
+      description: Restart language servers
+      on_failure: ignore
+`), 0600))
+
+		cfg, err := LoadConfig()
+		require.NoError(t, err)
+		require.Len(t, cfg.Hooks.PostSwitch, 1)
+		assert.Equal(t, "Restart language servers", cfg.Hooks.PostSwitch[0].Description)
+		assert.Equal(t, "ignore", cfg.Hooks.PostSwitch[0].OnFailure)
+		assert.Empty(t, cfg.Hooks.PreSwitch)
+	})
+
+	t.Run("migrates configs of earlier releases", func(t *testing.T) {
+		configPath := filepath.Join(tempDir, ".envswitch", "config.yaml")
+
+		// Switch used to save whatever auto_save_before_switch said
+		require.NoError(t, os.WriteFile(configPath, []byte("version: \"1.0\"\nauto_save_before_switch: false\n"), 0600))
+		cfg, err := LoadConfig()
+		require.NoError(t, err)
+		assert.Equal(t, "1.1", cfg.Version)
+		assert.Equal(t, "true", cfg.AutoSaveBeforeSwitch)
+
+		require.NoError(t, os.WriteFile(configPath, []byte("auto_save_before_switch: prompt\n"), 0600))
+		cfg, err = LoadConfig()
+		require.NoError(t, err)
+		assert.Equal(t, "prompt", cfg.AutoSaveBeforeSwitch)
+
+		require.NoError(t, os.WriteFile(configPath, []byte("version: \"1.1\"\nauto_save_before_switch: false\n"), 0600))
+		cfg, err = LoadConfig()
+		require.NoError(t, err)
+		assert.Equal(t, "false", cfg.AutoSaveBeforeSwitch, "set since 1.1, false is honored")
+	})
+
 	t.Run("returns error for invalid YAML", func(t *testing.T) {
 		// Create invalid config file
 		configPath := GetConfigPath()
@@ -92,6 +140,66 @@ func TestLoadConfig(t *testing.T) {
 	})
 }
 
+func TestLoadConfigLayers(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	base := DefaultConfig()
+	base.PromptColor = "green"
+	base.BackupRetention = 20
+	base.Aliases = map[string]string{"sw": "switch"}
+	base.ExcludeTools = []string{"docker"}
+	require.NoError(t, base.Save())
+
+	require.NoError(t, os.WriteFile(GetLocalConfigPath(), []byte(`prompt_color: red
+log_level: info
+aliases:
+  st: status
+exclude_tools: [aws]
+`), 0600))
+	t.Setenv("ENVSWITCH_LOG_LEVEL", "debug")
+	t.Setenv("ENVSWITCH_VERIFY_AFTER_SWITCH", "true")
+
+	cfg, err := LoadConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "red", cfg.PromptColor, "config.local.yaml overrides config.yaml")
+	assert.Equal(t, 20, cfg.BackupRetention, "config.yaml overrides the defaults")
+	assert.Equal(t, "debug", cfg.LogLevel, "the environment overrides config.local.yaml")
+	assert.True(t, cfg.VerifyAfterSwitch)
+	assert.Equal(t, map[string]string{"sw": "switch", "st": "status"}, cfg.Aliases)
+	assert.Equal(t, []string{"aws"}, cfg.ExcludeTools)
+	assert.Equal(t, []string{"config.local.yaml", "ENVSWITCH_VERIFY_AFTER_SWITCH", "ENVSWITCH_LOG_LEVEL"}, cfg.Overrides())
+
+	t.Run("a config with overrides cannot be saved", func(t *testing.T) {
+		err := cfg.Save()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "LoadBaseConfig")
+	})
+
+	t.Run("the base config leaves the overrides out", func(t *testing.T) {
+		baseCfg, err := LoadBaseConfig()
+		require.NoError(t, err)
+		assert.Equal(t, "green", baseCfg.PromptColor)
+		assert.Equal(t, "warn", baseCfg.LogLevel)
+		assert.Empty(t, baseCfg.Overrides())
+		require.NoError(t, baseCfg.Save())
+	})
+
+	t.Run("rejects invalid overrides", func(t *testing.T) {
+		t.Setenv("ENVSWITCH_BACKUP_RETENTION", "many")
+
+		_, err := LoadConfig()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "ENVSWITCH_BACKUP_RETENTION")
+	})
+}
+
+func TestParseValue(t *testing.T) {
+	assert.Equal(t, true, ParseValue("verify_after_switch", "true"))
+	assert.Equal(t, 15, ParseValue("backup_retention", "15"))
+	assert.Equal(t, "blue", ParseValue("prompt_color", "blue"))
+	assert.Equal(t, "false", ParseValue("auto_save_before_switch", "false"))
+}
+
 func TestConfigSave(t *testing.T) {
 	// Setup temp home directory
 	tempDir := t.TempDir()
@@ -187,8 +295,32 @@ func TestConfigGet(t *testing.T) {
 			"prompt_color",
 			"log_level",
 			"log_file",
+			"ssh_include_private_keys",
+			"gcloud_include_caches",
+			"kubectl_mode",
 			"color_output",
 			"show_timestamps",
+			"isolate_shell_history",
+			"backup_mirror_dir",
+			"auto_sync",
+			"sync_provider",
+			"sync_repo",
+			"sync_encrypt",
+			"plugin_registry",
+			"auto_snapshot",
+			"auto_snapshot_debounce",
+			"keyring_storage",
+			"default_editor",
+			"update_check",
+			"git_check_signing",
+			"git_mode",
+			"log_format",
+			"log_max_size_mb",
+			"log_rotate_daily",
+			"log_max_files",
+			"switch_log_retention",
+			"snapshot_history_limit",
+			"tool_timeout",
 		}
 
 		for _, key := range keys {
@@ -199,6 +331,113 @@ func TestConfigGet(t *testing.T) {
 }
 
 func TestConfigSet(t *testing.T) {
+	t.Run("sets the auto-snapshot debounce", func(t *testing.T) {
+		cfg := DefaultConfig()
+		delay, err := cfg.AutoSnapshotDelay()
+		require.NoError(t, err)
+		assert.Equal(t, DefaultAutoSnapshotDebounce, delay)
+
+		require.NoError(t, cfg.Set("auto_snapshot_debounce", "2m"))
+		delay, err = cfg.AutoSnapshotDelay()
+		require.NoError(t, err)
+		assert.Equal(t, 2*time.Minute, delay)
+
+		assert.Error(t, cfg.Set("auto_snapshot_debounce", "-5s"))
+		assert.Error(t, cfg.Set("auto_snapshot_debounce", 10))
+		assert.Equal(t, "2m", cfg.AutoSnapshotDebounce)
+	})
+
+	t.Run("sets subsystem log levels", func(t *testing.T) {
+		cfg := DefaultConfig()
+		require.NoError(t, cfg.Set("log_levels.tools.restore", "debug"))
+		assert.Equal(t, map[string]string{"tools.restore": "debug"}, cfg.LogLevels)
+
+		value, err := cfg.Get("log_levels.tools.restore")
+		require.NoError(t, err)
+		assert.Equal(t, "debug", value)
+
+		// Unset subsystems fall back to log_level
+		value, err = cfg.Get("log_levels.hooks")
+		require.NoError(t, err)
+		assert.Equal(t, cfg.LogLevel, value)
+
+		assert.Error(t, cfg.Set("log_levels.hooks", "verbose"))
+		assert.Error(t, cfg.Set("log_levels.", "debug"))
+
+		require.NoError(t, cfg.Set("log_levels.tools.restore", ""))
+		assert.Empty(t, cfg.LogLevels)
+	})
+
+	t.Run("sets tool timeouts", func(t *testing.T) {
+		cfg := DefaultConfig()
+		timeout, err := cfg.ToolTimeoutFor("gcloud")
+		require.NoError(t, err)
+		assert.Zero(t, timeout)
+
+		require.NoError(t, cfg.Set("tool_timeout", "2m"))
+		require.NoError(t, cfg.Set("tool_timeouts.gcloud", "30s"))
+		assert.Equal(t, map[string]string{"gcloud": "30s"}, cfg.ToolTimeouts)
+
+		timeout, err = cfg.ToolTimeoutFor("gcloud")
+		require.NoError(t, err)
+		assert.Equal(t, 30*time.Second, timeout)
+		timeout, err = cfg.ToolTimeoutFor("docker")
+		require.NoError(t, err)
+		assert.Equal(t, 2*time.Minute, timeout)
+
+		// Tools without their own timeout fall back to tool_timeout
+		value, err := cfg.Get("tool_timeouts.docker")
+		require.NoError(t, err)
+		assert.Equal(t, "2m", value)
+
+		assert.Error(t, cfg.Set("tool_timeout", "0s"))
+		assert.Error(t, cfg.Set("tool_timeouts.gcloud", "soon"))
+		assert.Error(t, cfg.Set("tool_timeouts.", "1m"))
+
+		require.NoError(t, cfg.Set("tool_timeouts.gcloud", ""))
+		assert.Empty(t, cfg.ToolTimeouts)
+	})
+
+	t.Run("sets command aliases", func(t *testing.T) {
+		cfg := DefaultConfig()
+		require.NoError(t, cfg.Set("aliases.sw", "switch --verify"))
+		assert.Equal(t, map[string]string{"sw": "switch --verify"}, cfg.Aliases)
+
+		value, err := cfg.Get("aliases.sw")
+		require.NoError(t, err)
+		assert.Equal(t, "switch --verify", value)
+
+		_, err = cfg.Get("aliases.missing")
+		assert.Error(t, err)
+
+		assert.Error(t, cfg.Set("aliases.", "switch"))
+		assert.Error(t, cfg.Set("aliases.my alias", "switch"))
+		assert.Error(t, cfg.Set("aliases.sw", 3))
+
+		require.NoError(t, cfg.Set("aliases.sw", ""))
+		assert.Empty(t, cfg.Aliases)
+	})
+
+	t.Run("sets feature flags", func(t *testing.T) {
+		cfg := DefaultConfig()
+		assert.False(t, cfg.FeatureEnabled("config_test_experimental"))
+
+		require.NoError(t, cfg.Set("features.config_test_experimental", true))
+		assert.Equal(t, map[string]bool{"config_test_experimental": true}, cfg.Features)
+		value, err := cfg.Get("features.config_test_experimental")
+		require.NoError(t, err)
+		assert.Equal(t, true, value)
+
+		// Setting a flag back to its default removes it from the file
+		require.NoError(t, cfg.Set("features.config_test_experimental", false))
+		assert.Empty(t, cfg.Features)
+
+		assert.Error(t, cfg.Set("features.config_test_experimental", "yes"))
+		assert.ErrorContains(t, cfg.Set("features.not_a_feature", true), "unknown feature")
+		_, err = cfg.Get("features.not_a_feature")
+		assert.Error(t, err)
+	})
+
 	t.Run("sets auto_save_before_switch with valid values", func(t *testing.T) {
 		cfg := DefaultConfig()
 		validValues := []string{"true", "false", "prompt"}
@@ -291,6 +530,27 @@ func TestConfigSet(t *testing.T) {
 		assert.Contains(t, err.Error(), "invalid value")
 	})
 
+	t.Run("sets git_mode", func(t *testing.T) {
+		cfg := DefaultConfig()
+		assert.NoError(t, cfg.Set("git_mode", "includeif"))
+		assert.Equal(t, "includeif", cfg.GitMode)
+
+		err := cfg.Set("git_mode", "context")
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid value")
+	})
+
+	t.Run("sets log_format", func(t *testing.T) {
+		cfg := DefaultConfig()
+		assert.Equal(t, "text", cfg.LogFormat)
+		assert.NoError(t, cfg.Set("log_format", "json"))
+		assert.Equal(t, "json", cfg.LogFormat)
+
+		err := cfg.Set("log_format", "xml")
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid value")
+	})
+
 	t.Run("sets backup_before_switch", func(t *testing.T) {
 		cfg := DefaultConfig()
 
@@ -0,0 +1,195 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/hugofrely/envswitch/internal/features"
+	"github.com/hugofrely/envswitch/internal/storage"
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+// PromptColors are the values accepted for prompt_color
+var PromptColors = []string{"black", "red", "green", "yellow", "blue", "magenta", "cyan", "white", "default"}
+
+// LogLevels are the values accepted for log_level and log_levels
+var LogLevels = []string{"debug", "info", "warn", "error"}
+
+// LogFormats are the values accepted for log_format
+var LogFormats = []string{"text", "json"}
+
+// KubectlModes are the values accepted for kubectl_mode
+var KubectlModes = []string{"full", "context"}
+
+// GitModes are the values accepted for git_mode
+var GitModes = []string{"full", "includeif"}
+
+// Validate checks the values of the config and returns an error for each
+// invalid one, none when the config is valid
+func (c *Config) Validate() []error {
+	var errs []error
+
+	if c.AutoSaveBeforeSwitch != "true" && c.AutoSaveBeforeSwitch != "false" && c.AutoSaveBeforeSwitch != "prompt" {
+		errs = append(errs, fmt.Errorf("auto_save_before_switch: invalid value '%s' (valid: true, false, prompt)", c.AutoSaveBeforeSwitch))
+	}
+	if c.BackupRetention < 0 {
+		errs = append(errs, fmt.Errorf("backup_retention: must not be negative, got %d", c.BackupRetention))
+	}
+	if _, err := c.AutoSnapshotDelay(); err != nil {
+		errs = append(errs, fmt.Errorf("auto_snapshot_debounce: %w", err))
+	}
+	if c.PromptColor != "" && !containsValue(PromptColors, c.PromptColor) {
+		errs = append(errs, fmt.Errorf("prompt_color: invalid value '%s' (valid: %s)", c.PromptColor, strings.Join(PromptColors, ", ")))
+	}
+	if !isValidLogLevel(c.LogLevel) {
+		errs = append(errs, fmt.Errorf("log_level: invalid value '%s' (valid: debug, info, warn, error)", c.LogLevel))
+	}
+	if c.LogFormat != "" && !containsValue(LogFormats, c.LogFormat) {
+		errs = append(errs, fmt.Errorf("log_format: invalid value '%s' (valid: %s)", c.LogFormat, strings.Join(LogFormats, ", ")))
+	}
+	if c.LogMaxSizeMB < 0 {
+		errs = append(errs, fmt.Errorf("log_max_size_mb: must not be negative, got %d", c.LogMaxSizeMB))
+	}
+	if c.LogMaxFiles < 0 {
+		errs = append(errs, fmt.Errorf("log_max_files: must not be negative, got %d", c.LogMaxFiles))
+	}
+	if c.SwitchLogRetention < 0 {
+		errs = append(errs, fmt.Errorf("switch_log_retention: must not be negative, got %d", c.SwitchLogRetention))
+	}
+	if c.SnapshotHistoryLimit < 0 {
+		errs = append(errs, fmt.Errorf("snapshot_history_limit: must not be negative, got %d", c.SnapshotHistoryLimit))
+	}
+	for _, subsystem := range sortedKeys(c.LogLevels) {
+		if level := c.LogLevels[subsystem]; !isValidLogLevel(level) {
+			errs = append(errs, fmt.Errorf("%s%s: invalid value '%s' (valid: debug, info, warn, error)", logLevelsPrefix, subsystem, level))
+		}
+	}
+	for i, pattern := range c.ExcludePatterns {
+		if err := storage.ValidatePattern(pattern); err != nil {
+			errs = append(errs, fmt.Errorf("exclude_patterns[%d]: %w", i, err))
+		}
+	}
+	for i, pattern := range c.EnvVarsInclude {
+		if err := environment.ValidateEnvVarPattern(pattern); err != nil {
+			errs = append(errs, fmt.Errorf("env_vars_include[%d]: %w", i, err))
+		}
+	}
+	for i, pattern := range c.EnvVarsDeny {
+		if err := environment.ValidateEnvVarPattern(pattern); err != nil {
+			errs = append(errs, fmt.Errorf("env_vars_deny[%d]: %w", i, err))
+		}
+	}
+	if c.KubectlMode != "" && !containsValue(KubectlModes, c.KubectlMode) {
+		errs = append(errs, fmt.Errorf("kubectl_mode: invalid value '%s' (valid: %s)", c.KubectlMode, strings.Join(KubectlModes, ", ")))
+	}
+	if c.GitMode != "" && !containsValue(GitModes, c.GitMode) {
+		errs = append(errs, fmt.Errorf("git_mode: invalid value '%s' (valid: %s)", c.GitMode, strings.Join(GitModes, ", ")))
+	}
+	if c.ToolTimeout != "" {
+		if _, err := parseDuration(c.ToolTimeout); err != nil {
+			errs = append(errs, fmt.Errorf("tool_timeout: %w", err))
+		}
+	}
+	for _, toolName := range sortedKeys(c.ToolTimeouts) {
+		if _, err := parseDuration(c.ToolTimeouts[toolName]); err != nil {
+			errs = append(errs, fmt.Errorf("%s%s: %w", toolTimeoutsPrefix, toolName, err))
+		}
+	}
+	for i, file := range c.KeyringFiles {
+		if tool, path, ok := strings.Cut(filepath.ToSlash(file), "/"); !ok || tool == "" || path == "" {
+			errs = append(errs, fmt.Errorf("keyring_files[%d]: '%s' is not a tool/path", i, file))
+		}
+	}
+	if c.SyncProvider != "" && c.SyncProvider != "git" {
+		errs = append(errs, fmt.Errorf("sync_provider: invalid value '%s' (valid: git)", c.SyncProvider))
+	}
+	for _, name := range sortedKeys(c.Aliases) {
+		if strings.ContainsAny(name, " \t") || strings.HasPrefix(name, "-") {
+			errs = append(errs, fmt.Errorf("%s%s: invalid alias name", aliasesPrefix, name))
+		}
+	}
+	for _, name := range sortedKeys(c.Features) {
+		if err := features.Validate(name); err != nil {
+			errs = append(errs, fmt.Errorf("%s%s: %w", featuresPrefix, name, err))
+		}
+	}
+	for i, hook := range c.Hooks.PreSwitch {
+		if err := hook.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("hooks.pre_switch[%d]: %w", i, err))
+		}
+	}
+	for i, hook := range c.Hooks.PostSwitch {
+		if err := hook.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("hooks.post_switch[%d]: %w", i, err))
+		}
+	}
+
+	return errs
+}
+
+// unknownFieldError matches the error yaml returns for an unknown key
+var unknownFieldError = regexp.MustCompile(`^(line \d+: )field (\S+) not found in type \S+$`)
+
+// ValidateFile checks a config file: its syntax, unknown keys, values of the
+// wrong type and invalid values. It returns an error for each problem, none
+// when the file is valid or does not exist.
+func ValidateFile(path string) ([]error, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	return ValidateData(data), nil
+}
+
+// ValidateData checks the content of a config file, see ValidateFile
+func ValidateData(data []byte) []error {
+	cfg := DefaultConfig()
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	var errs []error
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		var typeErr *yaml.TypeError
+		if !errors.As(err, &typeErr) {
+			// A syntax error, the values cannot be checked
+			return []error{err}
+		}
+		// The keys that did decode are still checked below
+		for _, msg := range typeErr.Errors {
+			msg = unknownFieldError.ReplaceAllString(msg, "${1}unknown key '$2'")
+			errs = append(errs, errors.New(msg))
+		}
+	}
+	return append(errs, cfg.Validate()...)
+}
+
+func containsValue(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedKeys returns the keys of m in order, so errors are stable
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
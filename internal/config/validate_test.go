@@ -0,0 +1,92 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+func TestConfigValidate(t *testing.T) {
+	assert.Empty(t, DefaultConfig().Validate())
+
+	cfg := DefaultConfig()
+	cfg.AutoSaveBeforeSwitch = "always"
+	cfg.BackupRetention = -1
+	cfg.AutoSnapshotDebounce = "soon"
+	cfg.PromptColor = "purple"
+	cfg.LogLevels = map[string]string{"hooks": "debug", "tools": "loud"}
+	cfg.ExcludePatterns = []string{"**/*.log", "logs/["}
+	cfg.EnvVarsDeny = []string{"*_TOKEN", "AWS_[A-"}
+	cfg.ToolTimeouts = map[string]string{"gcloud": "30s", "docker": "forever"}
+	cfg.KeyringFiles = []string{"aws/credentials", "credentials"}
+	cfg.SyncProvider = "s3"
+	cfg.Aliases = map[string]string{"-x": "switch"}
+	cfg.Features = map[string]bool{"no_such_feature": true}
+	cfg.Hooks.PostSwitch = []environment.Hook{{Command: "true", OnFailure: "retry"}}
+
+	errs := cfg.Validate()
+	var messages []string
+	for _, err := range errs {
+		messages = append(messages, err.Error())
+	}
+	require.Len(t, messages, 13)
+	assert.Contains(t, messages[0], "auto_save_before_switch")
+	assert.Contains(t, messages[1], "backup_retention")
+	assert.Contains(t, messages[2], "auto_snapshot_debounce: expected a duration like 10s, got 'soon'")
+	assert.Contains(t, messages[3], "prompt_color: invalid value 'purple'")
+	assert.Contains(t, messages[4], "log_levels.tools")
+	assert.Contains(t, messages[5], "exclude_patterns[1]")
+	assert.Contains(t, messages[6], "env_vars_deny[1]")
+	assert.Contains(t, messages[7], "tool_timeouts.docker: expected a duration like 10s, got 'forever'")
+	assert.Contains(t, messages[8], "keyring_files[1]")
+	assert.Contains(t, messages[9], "sync_provider")
+	assert.Contains(t, messages[10], "aliases.-x")
+	assert.Contains(t, messages[11], "features.no_such_feature")
+	assert.Contains(t, messages[12], "hooks.post_switch[0]")
+}
+
+func TestValidateData(t *testing.T) {
+	t.Run("valid config", func(t *testing.T) {
+		assert.Empty(t, ValidateData([]byte("prompt_color: red\nbackup_retention: 5\n")))
+		assert.Empty(t, ValidateData(nil))
+	})
+
+	t.Run("reports unknown keys, wrong types and invalid values", func(t *testing.T) {
+		errs := ValidateData([]byte("promt_color: red\nbackup_retention: lots\nprompt_color: purple\n"))
+
+		var messages []string
+		for _, err := range errs {
+			messages = append(messages, err.Error())
+		}
+		require.Len(t, messages, 3)
+		assert.Equal(t, "line 1: unknown key 'promt_color'", messages[0])
+		assert.Contains(t, messages[1], "line 2: cannot unmarshal")
+		assert.Contains(t, messages[2], "prompt_color: invalid value 'purple'")
+	})
+
+	t.Run("reports syntax errors", func(t *testing.T) {
+		errs := ValidateData([]byte("prompt_color: [red\n"))
+		require.Len(t, errs, 1)
+		assert.Contains(t, errs[0].Error(), "line")
+	})
+}
+
+func TestValidateFile(t *testing.T) {
+	dir := t.TempDir()
+
+	errs, err := ValidateFile(filepath.Join(dir, "missing.yaml"))
+	require.NoError(t, err)
+	assert.Empty(t, errs)
+
+	path := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("log_level: loud\n"), 0600))
+	errs, err = ValidateFile(path)
+	require.NoError(t, err)
+	require.Len(t, errs, 1)
+	assert.Contains(t, errs[0].Error(), "log_level")
+}
@@ -0,0 +1,115 @@
+package features
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/hugofrely/envswitch/internal/version"
+)
+
+// NoWarningsEnvVar silences deprecation warnings when set to a non-empty value
+const NoWarningsEnvVar = "ENVSWITCH_NO_DEPRECATION_WARNINGS"
+
+// Deprecation announces that a command, flag or setting is going away
+type Deprecation struct {
+	ID          string // e.g. "flag:switch --force"
+	Description string // what is deprecated
+	Replacement string // what to use instead, if anything
+	Since       string // version that deprecated it
+	RemovedIn   string // version that removes it
+}
+
+var (
+	deprecations = make(map[string]Deprecation)
+	warned       sync.Map
+)
+
+// RegisterDeprecation announces a deprecation. It panics when the ID is
+// taken, as that is a programming error.
+func RegisterDeprecation(d Deprecation) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, exists := deprecations[d.ID]; exists {
+		panic(fmt.Sprintf("features: deprecation %s registered twice", d.ID))
+	}
+	deprecations[d.ID] = d
+}
+
+// Deprecations returns the registered deprecations, the earliest removal first
+func Deprecations() []Deprecation {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	list := make([]Deprecation, 0, len(deprecations))
+	for _, d := range deprecations {
+		list = append(list, d)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if c := version.Compare(list[i].RemovedIn, list[j].RemovedIn); c != 0 {
+			return c < 0
+		}
+		return list[i].ID < list[j].ID
+	})
+	return list
+}
+
+// Removed reports whether the running version is past the removal version
+func (d Deprecation) Removed() bool {
+	return d.RemovedIn != "" && version.Reached(d.RemovedIn)
+}
+
+// String describes the deprecation and its timeline
+func (d Deprecation) String() string {
+	msg := d.Description + " is deprecated"
+	if d.Since != "" {
+		msg += " since v" + d.Since
+	}
+	if d.RemovedIn != "" {
+		msg += " and will be removed in v" + d.RemovedIn
+	}
+	if d.Replacement != "" {
+		msg += "; use " + d.Replacement + " instead"
+	}
+	return msg
+}
+
+// Use records that deprecated behavior is used. Once the running version
+// reaches the removal version an error is returned; before that a warning is
+// written to w once per process, unless NoWarningsEnvVar is set.
+func Use(w io.Writer, id string) error {
+	mu.RLock()
+	d, ok := deprecations[id]
+	mu.RUnlock()
+	if !ok {
+		return nil
+	}
+
+	if d.Removed() {
+		msg := fmt.Sprintf("%s was removed in v%s", d.Description, d.RemovedIn)
+		if d.Replacement != "" {
+			msg += "; use " + d.Replacement + " instead"
+		}
+		return fmt.Errorf("%s", msg)
+	}
+
+	if os.Getenv(NoWarningsEnvVar) != "" {
+		return nil
+	}
+	if _, already := warned.LoadOrStore(id, true); already {
+		return nil
+	}
+	fmt.Fprintf(w, "⚠️  %s\n", d)
+	return nil
+}
+
+// unregisterDeprecation removes a deprecation, for tests
+func unregisterDeprecation(id string) {
+	mu.Lock()
+	defer mu.Unlock()
+	delete(deprecations, id)
+	warned.Delete(id)
+}
@@ -0,0 +1,83 @@
+package features
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hugofrely/envswitch/internal/version"
+)
+
+func registerDeprecationForTest(t *testing.T, d Deprecation) {
+	t.Helper()
+	RegisterDeprecation(d)
+	t.Cleanup(func() { unregisterDeprecation(d.ID) })
+}
+
+func setVersion(t *testing.T, v string) {
+	t.Helper()
+	old := version.Version
+	version.Version = v
+	t.Cleanup(func() { version.Version = old })
+}
+
+func TestUseWarnsOnce(t *testing.T) {
+	setVersion(t, "1.5.0")
+	t.Setenv(NoWarningsEnvVar, "")
+	registerDeprecationForTest(t, Deprecation{
+		ID:          "flag:test --old",
+		Description: "the --old flag",
+		Replacement: "--new",
+		Since:       "1.4",
+		RemovedIn:   "2.0",
+	})
+
+	var out bytes.Buffer
+	require.NoError(t, Use(&out, "flag:test --old"))
+	require.NoError(t, Use(&out, "flag:test --old"))
+	assert.Equal(t, "⚠️  the --old flag is deprecated since v1.4 and will be removed in v2.0; use --new instead\n", out.String())
+}
+
+func TestUseSilenced(t *testing.T) {
+	setVersion(t, "1.5.0")
+	t.Setenv(NoWarningsEnvVar, "1")
+	registerDeprecationForTest(t, Deprecation{ID: "test-silenced", Description: "x", RemovedIn: "2.0"})
+
+	var out bytes.Buffer
+	require.NoError(t, Use(&out, "test-silenced"))
+	assert.Empty(t, out.String())
+}
+
+func TestUseAfterRemoval(t *testing.T) {
+	setVersion(t, "2.1.0")
+	registerDeprecationForTest(t, Deprecation{ID: "test-removed", Description: "the old layout", Replacement: "the XDG layout", RemovedIn: "2.0"})
+
+	var out bytes.Buffer
+	err := Use(&out, "test-removed")
+	assert.EqualError(t, err, "the old layout was removed in v2.0; use the XDG layout instead")
+	assert.Empty(t, out.String())
+}
+
+func TestUseDevBuildNeverRemoved(t *testing.T) {
+	setVersion(t, version.DevVersion)
+	t.Setenv(NoWarningsEnvVar, "")
+	registerDeprecationForTest(t, Deprecation{ID: "test-dev", Description: "x", RemovedIn: "0.1"})
+
+	var out bytes.Buffer
+	require.NoError(t, Use(&out, "test-dev"))
+	assert.Contains(t, out.String(), "x is deprecated")
+}
+
+func TestDeprecationsOrder(t *testing.T) {
+	registerDeprecationForTest(t, Deprecation{ID: "test-late", RemovedIn: "3.0"})
+	registerDeprecationForTest(t, Deprecation{ID: "test-soon", RemovedIn: "2.10"})
+	registerDeprecationForTest(t, Deprecation{ID: "test-sooner", RemovedIn: "2.2"})
+
+	var ids []string
+	for _, d := range Deprecations() {
+		ids = append(ids, d.ID)
+	}
+	assert.Equal(t, []string{"test-sooner", "test-soon", "test-late"}, ids)
+}
@@ -0,0 +1,111 @@
+// Package features lets subsystems ship behind feature flags and announce
+// deprecations with a removal timeline.
+//
+// A subsystem registers its flag from an init function and checks it with
+// Enabled, passing the overrides from the features section of config.yaml:
+//
+//	features:
+//	  symlink_mode: true
+package features
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Stage describes how mature a feature is
+type Stage string
+
+// Feature stages
+const (
+	StageExperimental Stage = "experimental" // may change or go away, off by default
+	StageBeta         Stage = "beta"         // complete but still being tried out
+	StageStable       Stage = "stable"       // on by default, the flag only opts out
+)
+
+// Feature is a subsystem that can be toggled in config.yaml
+type Feature struct {
+	Name        string
+	Description string
+	Stage       Stage
+	Default     bool // state when config.yaml does not set the flag
+}
+
+var (
+	mu       sync.RWMutex
+	registry = make(map[string]Feature)
+)
+
+// Register makes a feature flag known. It panics when the name is taken, as
+// that is a programming error.
+func Register(feature Feature) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if feature.Name == "" {
+		panic("features: feature without a name")
+	}
+	if _, exists := registry[feature.Name]; exists {
+		panic(fmt.Sprintf("features: feature %s registered twice", feature.Name))
+	}
+	registry[feature.Name] = feature
+}
+
+// Lookup returns the registered feature called name
+func Lookup(name string) (Feature, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	feature, ok := registry[name]
+	return feature, ok
+}
+
+// All returns the registered features sorted by name
+func All() []Feature {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	list := make([]Feature, 0, len(registry))
+	for _, feature := range registry {
+		list = append(list, feature)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Enabled reports whether a feature is on, given the flags set in
+// config.yaml. Unknown features are off.
+func Enabled(name string, overrides map[string]bool) bool {
+	feature, ok := Lookup(name)
+	if !ok {
+		return false
+	}
+	if enabled, set := overrides[name]; set {
+		return enabled
+	}
+	return feature.Default
+}
+
+// Validate checks that name is a registered feature
+func Validate(name string) error {
+	if _, ok := Lookup(name); ok {
+		return nil
+	}
+
+	var names []string
+	for _, feature := range All() {
+		names = append(names, feature.Name)
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("unknown feature '%s' (no feature flags are available in this version)", name)
+	}
+	return fmt.Errorf("unknown feature '%s' (available: %v)", name, names)
+}
+
+// unregister removes a feature, for tests
+func unregister(name string) {
+	mu.Lock()
+	defer mu.Unlock()
+	delete(registry, name)
+}
@@ -0,0 +1,50 @@
+package features
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func registerForTest(t *testing.T, feature Feature) {
+	t.Helper()
+	Register(feature)
+	t.Cleanup(func() { unregister(feature.Name) })
+}
+
+func TestEnabled(t *testing.T) {
+	registerForTest(t, Feature{Name: "test_experimental", Stage: StageExperimental})
+	registerForTest(t, Feature{Name: "test_stable", Stage: StageStable, Default: true})
+
+	assert.False(t, Enabled("test_experimental", nil))
+	assert.True(t, Enabled("test_experimental", map[string]bool{"test_experimental": true}))
+	assert.True(t, Enabled("test_stable", nil))
+	assert.False(t, Enabled("test_stable", map[string]bool{"test_stable": false}))
+	assert.False(t, Enabled("test_unknown", map[string]bool{"test_unknown": true}))
+}
+
+func TestRegisterTwicePanics(t *testing.T) {
+	registerForTest(t, Feature{Name: "test_dup"})
+	assert.Panics(t, func() { Register(Feature{Name: "test_dup"}) })
+}
+
+func TestValidate(t *testing.T) {
+	registerForTest(t, Feature{Name: "test_flag"})
+
+	require.NoError(t, Validate("test_flag"))
+	err := Validate("nope")
+	assert.ErrorContains(t, err, "unknown feature 'nope'")
+	assert.ErrorContains(t, err, "test_flag")
+}
+
+func TestAllSorted(t *testing.T) {
+	registerForTest(t, Feature{Name: "test_b"})
+	registerForTest(t, Feature{Name: "test_a"})
+
+	var names []string
+	for _, feature := range All() {
+		names = append(names, feature.Name)
+	}
+	assert.Equal(t, []string{"test_a", "test_b"}, names)
+}
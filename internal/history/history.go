@@ -1,25 +1,35 @@
 package history
 
 import (
-	"encoding/json"
-	"fmt"
-	"os"
-	"path/filepath"
+	"sort"
 	"time"
-
-	"github.com/hugofrely/envswitch/pkg/environment"
 )
 
 // SwitchEntry represents a single switch operation in history
 type SwitchEntry struct {
-	Timestamp  time.Time `json:"timestamp"`
-	From       string    `json:"from"`
-	To         string    `json:"to"`
-	Success    bool      `json:"success"`
-	ErrorMsg   string    `json:"error_msg,omitempty"`
-	BackupPath string    `json:"backup_path,omitempty"`
-	ToolsCount int       `json:"tools_count"`
-	DurationMs int64     `json:"duration_ms"`
+	ID         int       `json:"id,omitempty" yaml:"id,omitempty"`
+	Timestamp  time.Time `json:"timestamp" yaml:"timestamp"`
+	From       string    `json:"from" yaml:"from"`
+	To         string    `json:"to" yaml:"to"`
+	Success    bool      `json:"success" yaml:"success"`
+	ErrorMsg   string    `json:"error_msg,omitempty" yaml:"error_msg,omitempty"`
+	BackupPath string    `json:"backup_path,omitempty" yaml:"backup_path,omitempty"`
+	ToolsCount int       `json:"tools_count" yaml:"tools_count"`
+	DurationMs int64     `json:"duration_ms" yaml:"duration_ms"`
+	Rollback   bool      `json:"rollback,omitempty" yaml:"rollback,omitempty"`
+	OnlyTools  []string  `json:"only_tools,omitempty" yaml:"only_tools,omitempty"` // switch --only
+	SkipTools  []string  `json:"skip_tools,omitempty" yaml:"skip_tools,omitempty"` // switch --skip
+	LogFile    string    `json:"log_file,omitempty" yaml:"log_file,omitempty"`     // everything logged during the switch
+
+	Timings []PhaseTiming `json:"timings,omitempty" yaml:"timings,omitempty"` // phases of the switch, in order
+}
+
+// PhaseTiming is how long a phase of a switch took ("restore"), or a tool
+// within that phase when Tool is set
+type PhaseTiming struct {
+	Phase      string `json:"phase" yaml:"phase"`
+	Tool       string `json:"tool,omitempty" yaml:"tool,omitempty"`
+	DurationMs int64  `json:"duration_ms" yaml:"duration_ms"`
 }
 
 // History manages the switch history
@@ -27,82 +37,165 @@ type History struct {
 	Entries []SwitchEntry `json:"entries"`
 }
 
-// GetHistoryPath returns the path to the history file
-func GetHistoryPath() (string, error) {
-	dir, err := environment.GetEnvswitchDir()
-	if err != nil {
-		return "", err
+// GetLast returns the last N entries
+func (h *History) GetLast(n int) []SwitchEntry {
+	if n <= 0 {
+		return []SwitchEntry{}
+	}
+
+	if n > len(h.Entries) {
+		n = len(h.Entries)
 	}
-	return filepath.Join(dir, "history.json"), nil
+
+	return h.Entries[len(h.Entries)-n:]
 }
 
-// LoadHistory loads the switch history from disk
-func LoadHistory() (*History, error) {
-	historyPath, err := GetHistoryPath()
-	if err != nil {
-		return nil, err
+// GetLatest returns the most recent switch entry, or nil if history is empty
+func (h *History) GetLatest() *SwitchEntry {
+	if len(h.Entries) == 0 {
+		return nil
 	}
+	return &h.Entries[len(h.Entries)-1]
+}
 
-	// If history file doesn't exist, return empty history
-	if _, statErr := os.Stat(historyPath); os.IsNotExist(statErr) {
-		return &History{Entries: []SwitchEntry{}}, nil
+// FindByID returns the entry with the given ID, or nil if there is none
+func (h *History) FindByID(id int) *SwitchEntry {
+	for i := range h.Entries {
+		if h.Entries[i].ID == id {
+			return &h.Entries[i]
+		}
 	}
+	return nil
+}
 
-	data, err := os.ReadFile(historyPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read history: %w", err)
+// GetLastSwitch returns the most recent successful switch that was not
+// itself a rollback, or nil if there is none
+func (h *History) GetLastSwitch() *SwitchEntry {
+	for i := len(h.Entries) - 1; i >= 0; i-- {
+		if h.Entries[i].Success && !h.Entries[i].Rollback {
+			return &h.Entries[i]
+		}
 	}
+	return nil
+}
 
-	var history History
-	if err := json.Unmarshal(data, &history); err != nil {
-		return nil, fmt.Errorf("failed to parse history: %w", err)
+// GetLastActivation returns the most recent successful switch to the
+// environment called name, or nil if there is none
+func (h *History) GetLastActivation(name string) *SwitchEntry {
+	for i := len(h.Entries) - 1; i >= 0; i-- {
+		if h.Entries[i].Success && h.Entries[i].To == name {
+			return &h.Entries[i]
+		}
 	}
+	return nil
+}
 
-	return &history, nil
+// Filter selects history entries. Zero fields match every entry.
+type Filter struct {
+	Env        string    // switches from or to this environment
+	Since      time.Time // switches at or after this time
+	FailedOnly bool
 }
 
-// Save saves the history to disk
-func (h *History) Save() error {
-	historyPath, err := GetHistoryPath()
-	if err != nil {
-		return err
-	}
+// IsEmpty reports whether the filter matches every entry
+func (f Filter) IsEmpty() bool {
+	return f.Env == "" && f.Since.IsZero() && !f.FailedOnly
+}
 
-	data, err := json.MarshalIndent(h, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal history: %w", err)
+// Matches reports whether entry is selected by the filter
+func (f Filter) Matches(entry *SwitchEntry) bool {
+	if f.Env != "" && entry.From != f.Env && entry.To != f.Env {
+		return false
 	}
+	if !f.Since.IsZero() && entry.Timestamp.Before(f.Since) {
+		return false
+	}
+	return !f.FailedOnly || !entry.Success
+}
 
-	if err := os.WriteFile(historyPath, data, 0644); err != nil {
-		return fmt.Errorf("failed to write history: %w", err)
+// Search returns the entries matching filter, oldest first
+func (h *History) Search(filter Filter) []SwitchEntry {
+	matches := []SwitchEntry{}
+	for i := range h.Entries {
+		if filter.Matches(&h.Entries[i]) {
+			matches = append(matches, h.Entries[i])
+		}
 	}
+	return matches
+}
 
-	return nil
+// PairStats summarizes the switches between two environments
+type PairStats struct {
+	From            string
+	To              string
+	Count           int
+	Failures        int
+	TotalDurationMs int64
 }
 
-// AddEntry adds a new switch entry to the history
-func (h *History) AddEntry(entry *SwitchEntry) error {
-	h.Entries = append(h.Entries, *entry)
-	return h.Save()
+// AverageDurationMs returns the mean duration of the switches
+func (p PairStats) AverageDurationMs() int64 {
+	if p.Count == 0 {
+		return 0
+	}
+	return p.TotalDurationMs / int64(p.Count)
 }
 
-// GetLast returns the last N entries
-func (h *History) GetLast(n int) []SwitchEntry {
-	if n <= 0 {
-		return []SwitchEntry{}
+// FailureRate returns the share of failed switches, between 0 and 1
+func (p PairStats) FailureRate() float64 {
+	if p.Count == 0 {
+		return 0
 	}
+	return float64(p.Failures) / float64(p.Count)
+}
 
-	if n > len(h.Entries) {
-		n = len(h.Entries)
+// Stats summarizes a set of switches
+type Stats struct {
+	Total PairStats   // every switch, From and To are empty
+	Pairs []PairStats // per environment pair, most frequent first
+}
+
+// ComputeStats summarizes entries overall and per environment pair
+func ComputeStats(entries []SwitchEntry) Stats {
+	var stats Stats
+	index := make(map[[2]string]int)
+	for _, entry := range entries {
+		key := [2]string{entry.From, entry.To}
+		i, exists := index[key]
+		if !exists {
+			i = len(stats.Pairs)
+			index[key] = i
+			stats.Pairs = append(stats.Pairs, PairStats{From: entry.From, To: entry.To})
+		}
+
+		for _, pair := range []*PairStats{&stats.Total, &stats.Pairs[i]} {
+			pair.Count++
+			pair.TotalDurationMs += entry.DurationMs
+			if !entry.Success {
+				pair.Failures++
+			}
+		}
 	}
 
-	return h.Entries[len(h.Entries)-n:]
+	sort.SliceStable(stats.Pairs, func(i, j int) bool {
+		if stats.Pairs[i].Count != stats.Pairs[j].Count {
+			return stats.Pairs[i].Count > stats.Pairs[j].Count
+		}
+		if stats.Pairs[i].From != stats.Pairs[j].From {
+			return stats.Pairs[i].From < stats.Pairs[j].From
+		}
+		return stats.Pairs[i].To < stats.Pairs[j].To
+	})
+	return stats
 }
 
-// GetLatest returns the most recent switch entry, or nil if history is empty
-func (h *History) GetLatest() *SwitchEntry {
-	if len(h.Entries) == 0 {
-		return nil
+// nextID returns the ID to assign to a new entry
+func (h *History) nextID() int {
+	maxID := 0
+	for _, entry := range h.Entries {
+		if entry.ID > maxID {
+			maxID = entry.ID
+		}
 	}
-	return &h.Entries[len(h.Entries)-1]
+	return maxID + 1
 }
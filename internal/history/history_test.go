@@ -118,6 +118,11 @@ func TestHistoryAddEntry(t *testing.T) {
 	loaded, err := LoadHistory()
 	require.NoError(t, err)
 	assert.Len(t, loaded.Entries, 1)
+	assert.Equal(t, 1, loaded.Entries[0].ID)
+
+	second := SwitchEntry{From: "env2", To: "env1", Success: true}
+	require.NoError(t, loaded.AddEntry(&second))
+	assert.Equal(t, 2, second.ID)
 }
 
 func TestHistoryGetLast(t *testing.T) {
@@ -168,3 +173,127 @@ func TestHistoryGetLatest(t *testing.T) {
 		assert.Nil(t, history.GetLatest())
 	})
 }
+
+func TestLoadHistoryNumbersLegacyEntries(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	history := &History{
+		Entries: []SwitchEntry{
+			{From: "env1", To: "env2"},
+			{From: "env2", To: "env3"},
+		},
+	}
+	require.NoError(t, os.MkdirAll(filepath.Join(os.Getenv("HOME"), ".envswitch"), 0755))
+	require.NoError(t, history.Save())
+
+	loaded, err := LoadHistory()
+	require.NoError(t, err)
+	assert.Equal(t, 1, loaded.Entries[0].ID)
+	assert.Equal(t, 2, loaded.Entries[1].ID)
+}
+
+func TestHistoryFindByID(t *testing.T) {
+	history := &History{
+		Entries: []SwitchEntry{
+			{ID: 1, From: "env1", To: "env2"},
+			{ID: 2, From: "env2", To: "env3"},
+		},
+	}
+
+	entry := history.FindByID(2)
+	require.NotNil(t, entry)
+	assert.Equal(t, "env3", entry.To)
+	assert.Nil(t, history.FindByID(3))
+}
+
+func TestHistoryGetLastSwitch(t *testing.T) {
+	t.Run("skips failed switches and rollbacks", func(t *testing.T) {
+		history := &History{
+			Entries: []SwitchEntry{
+				{ID: 1, From: "env1", To: "env2", Success: true},
+				{ID: 2, From: "env2", To: "env3", Success: false},
+				{ID: 3, From: "env2", To: "env1", Success: true, Rollback: true},
+			},
+		}
+
+		entry := history.GetLastSwitch()
+		require.NotNil(t, entry)
+		assert.Equal(t, 1, entry.ID)
+	})
+
+	t.Run("returns nil without switches", func(t *testing.T) {
+		history := &History{Entries: []SwitchEntry{}}
+		assert.Nil(t, history.GetLastSwitch())
+	})
+}
+
+func TestHistoryGetLastActivation(t *testing.T) {
+	history := &History{
+		Entries: []SwitchEntry{
+			{ID: 1, From: "env1", To: "env2", Success: true, OnlyTools: []string{"kubectl"}},
+			{ID: 2, From: "env2", To: "env1", Success: true},
+			{ID: 3, From: "env1", To: "env2", Success: false},
+		},
+	}
+
+	entry := history.GetLastActivation("env2")
+	require.NotNil(t, entry)
+	assert.Equal(t, 1, entry.ID)
+	assert.Equal(t, []string{"kubectl"}, entry.OnlyTools)
+
+	assert.Nil(t, history.GetLastActivation("env3"))
+}
+
+func TestHistorySearch(t *testing.T) {
+	now := time.Now()
+	h := &History{Entries: []SwitchEntry{
+		{ID: 1, Timestamp: now.Add(-10 * 24 * time.Hour), From: "dev", To: "prod", Success: true},
+		{ID: 2, Timestamp: now.Add(-2 * 24 * time.Hour), From: "prod", To: "dev", Success: false},
+		{ID: 3, Timestamp: now.Add(-time.Hour), From: "dev", To: "staging", Success: true},
+		{ID: 4, Timestamp: now, From: "staging", To: "prod", Success: false},
+	}}
+
+	ids := func(entries []SwitchEntry) []int {
+		result := []int{}
+		for _, entry := range entries {
+			result = append(result, entry.ID)
+		}
+		return result
+	}
+
+	assert.True(t, Filter{}.IsEmpty())
+	assert.Equal(t, []int{1, 2, 3, 4}, ids(h.Search(Filter{})))
+	assert.Equal(t, []int{1, 2, 4}, ids(h.Search(Filter{Env: "prod"})))
+	assert.Equal(t, []int{2, 3, 4}, ids(h.Search(Filter{Since: now.Add(-7 * 24 * time.Hour)})))
+	assert.Equal(t, []int{2, 4}, ids(h.Search(Filter{FailedOnly: true})))
+	assert.Equal(t, []int{4}, ids(h.Search(Filter{Env: "prod", Since: now.Add(-24 * time.Hour), FailedOnly: true})))
+	assert.Empty(t, h.Search(Filter{Env: "other"}))
+}
+
+func TestComputeStats(t *testing.T) {
+	stats := ComputeStats([]SwitchEntry{
+		{From: "dev", To: "prod", Success: true, DurationMs: 1000},
+		{From: "dev", To: "prod", Success: false, DurationMs: 3000},
+		{From: "prod", To: "dev", Success: true, DurationMs: 500},
+		{From: "dev", To: "prod", Success: true, DurationMs: 2000},
+	})
+
+	assert.Equal(t, 4, stats.Total.Count)
+	assert.Equal(t, 1, stats.Total.Failures)
+	assert.Equal(t, int64(1625), stats.Total.AverageDurationMs())
+	assert.InDelta(t, 0.25, stats.Total.FailureRate(), 0.001)
+
+	require.Len(t, stats.Pairs, 2)
+	assert.Equal(t, "dev", stats.Pairs[0].From)
+	assert.Equal(t, "prod", stats.Pairs[0].To)
+	assert.Equal(t, 3, stats.Pairs[0].Count)
+	assert.Equal(t, int64(2000), stats.Pairs[0].AverageDurationMs())
+	assert.InDelta(t, 1.0/3, stats.Pairs[0].FailureRate(), 0.001)
+	assert.Equal(t, "prod", stats.Pairs[1].From)
+	assert.Equal(t, 1, stats.Pairs[1].Count)
+
+	empty := ComputeStats(nil)
+	assert.Zero(t, empty.Total.Count)
+	assert.Zero(t, empty.Total.FailureRate())
+	assert.Empty(t, empty.Pairs)
+}
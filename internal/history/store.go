@@ -0,0 +1,386 @@
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/hugofrely/envswitch/internal/redact"
+	"github.com/hugofrely/envswitch/internal/storage"
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+// The history is an append-only log of JSON lines. Once the log grows past
+// maxLogSize it is rotated to history.1.jsonl, shifting older logs up to
+// maxRotatedLogs; the oldest is dropped. An index holds the next ID and the
+// latest entries, so recording a switch never reads the logs.
+const (
+	logFileName    = "history.jsonl"
+	indexFileName  = "history.index.json"
+	legacyFileName = "history.json" // single JSON document, before logs
+	maxRotatedLogs = 5
+	recentEntries  = 100 // entries kept in the index
+)
+
+// maxLogSize is a variable so tests can rotate small logs
+var maxLogSize int64 = 1 << 20
+
+// index summarizes the logs
+type index struct {
+	NextID int           `json:"next_id"`
+	Count  int           `json:"count"`
+	Recent []SwitchEntry `json:"recent"` // oldest first
+}
+
+// GetHistoryPath returns the path to the log new entries are appended to
+func GetHistoryPath() (string, error) {
+	dir, err := environment.GetEnvswitchDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, logFileName), nil
+}
+
+// rotatedLogPath returns the path of the nth rotated log, 1 being the newest
+func rotatedLogPath(dir string, n int) string {
+	return filepath.Join(dir, fmt.Sprintf("history.%d.jsonl", n))
+}
+
+// Files returns the existing files of the history: the logs, the index and
+// a history.json left by earlier versions
+func Files() ([]string, error) {
+	dir, err := environment.GetEnvswitchDir()
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := []string{filepath.Join(dir, logFileName)}
+	for n := 1; n <= maxRotatedLogs; n++ {
+		candidates = append(candidates, rotatedLogPath(dir, n))
+	}
+	candidates = append(candidates, filepath.Join(dir, indexFileName), filepath.Join(dir, legacyFileName))
+
+	var files []string
+	for _, path := range candidates {
+		if _, err := os.Stat(path); err == nil {
+			files = append(files, path)
+		}
+	}
+	return files, nil
+}
+
+// LoadHistory loads the whole switch history from disk
+func LoadHistory() (*History, error) {
+	dir, err := environment.GetEnvswitchDir()
+	if err != nil {
+		return nil, err
+	}
+	if err := migrateLegacyHistory(dir); err != nil {
+		return nil, err
+	}
+
+	entries, err := readLogs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Entries recorded before IDs existed are numbered by position
+	for i := range entries {
+		if entries[i].ID == 0 {
+			entries[i].ID = i + 1
+		}
+	}
+
+	return &History{Entries: entries}, nil
+}
+
+// LoadRecent returns the last n entries and the number of entries in the
+// history, reading the logs only when the index does not hold enough entries
+func LoadRecent(n int) ([]SwitchEntry, int, error) {
+	dir, err := environment.GetEnvswitchDir()
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := migrateLegacyHistory(dir); err != nil {
+		return nil, 0, err
+	}
+
+	idx, err := loadIndex(dir)
+	if err != nil {
+		return nil, 0, err
+	}
+	if n <= len(idx.Recent) || len(idx.Recent) == idx.Count {
+		recent := &History{Entries: idx.Recent}
+		return recent.GetLast(n), idx.Count, nil
+	}
+
+	hist, err := LoadHistory()
+	if err != nil {
+		return nil, 0, err
+	}
+	return hist.GetLast(n), len(hist.Entries), nil
+}
+
+// Save replaces the whole history on disk with the entries of h
+func (h *History) Save() error {
+	dir, err := environment.GetEnvswitchDir()
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	for i := range h.Entries {
+		line, err := json.Marshal(&h.Entries[i])
+		if err != nil {
+			return fmt.Errorf("failed to marshal history: %w", err)
+		}
+		buf.Write(append(line, '\n'))
+	}
+
+	if err := storage.WriteFileAtomic(filepath.Join(dir, logFileName), buf.Bytes(), 0600); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	for n := 1; n <= maxRotatedLogs; n++ {
+		if err := os.Remove(rotatedLogPath(dir, n)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove rotated history: %w", err)
+		}
+	}
+	_ = os.Remove(filepath.Join(dir, legacyFileName))
+
+	return saveIndex(dir, newIndex(h.Entries))
+}
+
+// Append numbers entry and appends it to the history log, without reading
+// the history
+func Append(entry *SwitchEntry) error {
+	return (&History{}).AddEntry(entry)
+}
+
+// AddEntry numbers entry, appends it to the history log and to h
+func (h *History) AddEntry(entry *SwitchEntry) error {
+	dir, err := environment.GetEnvswitchDir()
+	if err != nil {
+		return err
+	}
+	if err := migrateLegacyHistory(dir); err != nil {
+		return err
+	}
+
+	idx, err := loadIndex(dir)
+	if err != nil {
+		return err
+	}
+
+	// h may hold entries not on disk yet, or none when not loaded
+	entry.ID = idx.NextID
+	if id := h.nextID(); id > entry.ID {
+		entry.ID = id
+	}
+
+	// Errors of failed switches may quote credentials
+	entry.ErrorMsg = redact.String(entry.ErrorMsg)
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal history entry: %w", err)
+	}
+	if err := appendLine(filepath.Join(dir, logFileName), line); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+
+	idx.NextID = entry.ID + 1
+	idx.Count++
+	idx.Recent = append(idx.Recent, *entry)
+	if len(idx.Recent) > recentEntries {
+		idx.Recent = idx.Recent[len(idx.Recent)-recentEntries:]
+	}
+
+	dropped, err := rotateLogs(dir)
+	if err != nil {
+		return err
+	}
+	idx.Count -= dropped
+
+	h.Entries = append(h.Entries, *entry)
+	return saveIndex(dir, idx)
+}
+
+// readLogs returns the entries of every log, oldest first
+func readLogs(dir string) ([]SwitchEntry, error) {
+	entries := []SwitchEntry{}
+	for n := maxRotatedLogs; n >= 0; n-- {
+		path := filepath.Join(dir, logFileName)
+		if n > 0 {
+			path = rotatedLogPath(dir, n)
+		}
+
+		logEntries, err := readLog(path)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, logEntries...)
+	}
+	return entries, nil
+}
+
+// readLog returns the entries of one log, none when it does not exist
+func readLog(path string) ([]SwitchEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	var entries []SwitchEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		// A line cut short by a crash while appending is skipped
+		var entry SwitchEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+	return entries, nil
+}
+
+// appendLine appends line to the log at path
+func appendLine(path string, line []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// rotateLogs rotates the active log once it is larger than maxLogSize and
+// returns the number of entries dropped with the oldest log
+func rotateLogs(dir string) (int, error) {
+	activePath := filepath.Join(dir, logFileName)
+	info, err := os.Stat(activePath)
+	if err != nil || info.Size() < maxLogSize {
+		return 0, nil
+	}
+
+	oldest := rotatedLogPath(dir, maxRotatedLogs)
+	dropped, err := readLog(oldest)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.Remove(oldest); err != nil && !os.IsNotExist(err) {
+		return 0, fmt.Errorf("failed to rotate history: %w", err)
+	}
+
+	for n := maxRotatedLogs - 1; n >= 1; n-- {
+		if err := os.Rename(rotatedLogPath(dir, n), rotatedLogPath(dir, n+1)); err != nil && !os.IsNotExist(err) {
+			return 0, fmt.Errorf("failed to rotate history: %w", err)
+		}
+	}
+	if err := os.Rename(activePath, rotatedLogPath(dir, 1)); err != nil {
+		return 0, fmt.Errorf("failed to rotate history: %w", err)
+	}
+	return len(dropped), nil
+}
+
+// newIndex builds the index of entries
+func newIndex(entries []SwitchEntry) *index {
+	idx := &index{NextID: 1, Count: len(entries)}
+	for i, entry := range entries {
+		id := entry.ID
+		if id == 0 {
+			id = i + 1
+		}
+		if id >= idx.NextID {
+			idx.NextID = id + 1
+		}
+	}
+
+	recent := (&History{Entries: entries}).GetLast(recentEntries)
+	idx.Recent = append([]SwitchEntry{}, recent...)
+	return idx
+}
+
+// loadIndex reads the index, rebuilding it from the logs when it is missing
+// or unreadable
+func loadIndex(dir string) (*index, error) {
+	data, err := os.ReadFile(filepath.Join(dir, indexFileName))
+	if err == nil {
+		var idx index
+		if json.Unmarshal(data, &idx) == nil && idx.NextID > 0 {
+			return &idx, nil
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read history index: %w", err)
+	}
+
+	entries, err := readLogs(dir)
+	if err != nil {
+		return nil, err
+	}
+	idx := newIndex(entries)
+	if len(entries) > 0 {
+		if err := saveIndex(dir, idx); err != nil {
+			return nil, err
+		}
+	}
+	return idx, nil
+}
+
+func saveIndex(dir string, idx *index) error {
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return fmt.Errorf("failed to marshal history index: %w", err)
+	}
+	if err := storage.WriteFileAtomic(filepath.Join(dir, indexFileName), data, 0600); err != nil {
+		return fmt.Errorf("failed to write history index: %w", err)
+	}
+	return nil
+}
+
+// migrateLegacyHistory converts history.json, written by earlier versions,
+// to a log
+func migrateLegacyHistory(dir string) error {
+	legacyPath := filepath.Join(dir, legacyFileName)
+	data, err := os.ReadFile(legacyPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read history: %w", err)
+	}
+
+	// Logs written since win over a history.json left behind
+	if _, err := os.Stat(filepath.Join(dir, logFileName)); err == nil {
+		return os.Remove(legacyPath)
+	}
+
+	var legacy History
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return fmt.Errorf("failed to parse history: %w", err)
+	}
+	for i := range legacy.Entries {
+		if legacy.Entries[i].ID == 0 {
+			legacy.Entries[i].ID = i + 1
+		}
+	}
+	return legacy.Save()
+}
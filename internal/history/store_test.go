@@ -0,0 +1,206 @@
+package history
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupHistoryDir(t *testing.T) string {
+	t.Helper()
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	dir := filepath.Join(tmpDir, ".envswitch")
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	return dir
+}
+
+func TestAppendWritesOneLinePerEntry(t *testing.T) {
+	dir := setupHistoryDir(t)
+
+	for i := 0; i < 3; i++ {
+		entry := SwitchEntry{Timestamp: time.Now(), From: "a", To: "b", Success: true}
+		require.NoError(t, Append(&entry))
+		assert.Equal(t, i+1, entry.ID)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, logFileName))
+	require.NoError(t, err)
+	lines := 0
+	for _, b := range data {
+		if b == '\n' {
+			lines++
+		}
+	}
+	assert.Equal(t, 3, lines)
+
+	loaded, err := LoadHistory()
+	require.NoError(t, err)
+	require.Len(t, loaded.Entries, 3)
+	assert.Equal(t, 3, loaded.Entries[2].ID)
+}
+
+func TestAppendMasksSecrets(t *testing.T) {
+	dir := setupHistoryDir(t)
+
+	entry := SwitchEntry{Timestamp: time.Now(), From: "a", To: "b", ErrorMsg: "hook failed: Authorization: Bearer abc.def"}
+	require.NoError(t, Append(&entry))
+
+	data, err := os.ReadFile(filepath.Join(dir, logFileName))
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "abc.def")
+
+	loaded, err := LoadHistory()
+	require.NoError(t, err)
+	require.Len(t, loaded.Entries, 1)
+	assert.Equal(t, "hook failed: Authorization: Bearer [REDACTED]", loaded.Entries[0].ErrorMsg)
+}
+
+func TestAddEntryRotatesLogs(t *testing.T) {
+	dir := setupHistoryDir(t)
+
+	previous := maxLogSize
+	maxLogSize = 1
+	defer func() { maxLogSize = previous }()
+
+	// Every entry fills a log, the oldest ones are dropped
+	for i := 0; i < maxRotatedLogs+3; i++ {
+		require.NoError(t, Append(&SwitchEntry{From: "a", To: "b"}))
+	}
+
+	for n := 1; n <= maxRotatedLogs; n++ {
+		assert.FileExists(t, rotatedLogPath(dir, n))
+	}
+	assert.NoFileExists(t, rotatedLogPath(dir, maxRotatedLogs+1))
+
+	loaded, err := LoadHistory()
+	require.NoError(t, err)
+	require.Len(t, loaded.Entries, maxRotatedLogs)
+	assert.Equal(t, 4, loaded.Entries[0].ID)
+	assert.Equal(t, maxRotatedLogs+3, loaded.Entries[maxRotatedLogs-1].ID)
+
+	recent, total, err := LoadRecent(2)
+	require.NoError(t, err)
+	assert.Equal(t, maxRotatedLogs, total)
+	require.Len(t, recent, 2)
+	assert.Equal(t, maxRotatedLogs+3, recent[1].ID)
+
+	// IDs keep growing after rotation
+	entry := SwitchEntry{From: "a", To: "b"}
+	require.NoError(t, Append(&entry))
+	assert.Equal(t, maxRotatedLogs+4, entry.ID)
+}
+
+func TestLoadRecent(t *testing.T) {
+	dir := setupHistoryDir(t)
+
+	for i := 0; i < recentEntries+5; i++ {
+		require.NoError(t, Append(&SwitchEntry{From: "a", To: "b"}))
+	}
+
+	recent, total, err := LoadRecent(3)
+	require.NoError(t, err)
+	assert.Equal(t, recentEntries+5, total)
+	require.Len(t, recent, 3)
+	assert.Equal(t, recentEntries+5, recent[2].ID)
+
+	// More than the index holds reads the logs
+	all, total, err := LoadRecent(recentEntries + 10)
+	require.NoError(t, err)
+	assert.Equal(t, recentEntries+5, total)
+	assert.Len(t, all, recentEntries+5)
+
+	// A lost index is rebuilt from the logs
+	require.NoError(t, os.Remove(filepath.Join(dir, indexFileName)))
+	recent, total, err = LoadRecent(1)
+	require.NoError(t, err)
+	assert.Equal(t, recentEntries+5, total)
+	require.Len(t, recent, 1)
+	assert.Equal(t, recentEntries+5, recent[0].ID)
+	assert.FileExists(t, filepath.Join(dir, indexFileName))
+}
+
+func TestLoadHistorySkipsTruncatedLines(t *testing.T) {
+	dir := setupHistoryDir(t)
+
+	require.NoError(t, Append(&SwitchEntry{From: "a", To: "b"}))
+	f, err := os.OpenFile(filepath.Join(dir, logFileName), os.O_WRONLY|os.O_APPEND, 0600)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"id":2,"from":"b","to`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	loaded, err := LoadHistory()
+	require.NoError(t, err)
+	require.Len(t, loaded.Entries, 1)
+	assert.Equal(t, "a", loaded.Entries[0].From)
+}
+
+func TestLegacyHistoryIsMigrated(t *testing.T) {
+	dir := setupHistoryDir(t)
+
+	legacy := History{Entries: []SwitchEntry{
+		{From: "env1", To: "env2", Success: true},
+		{From: "env2", To: "env3", Success: true},
+	}}
+	data, err := json.MarshalIndent(legacy, "", "  ")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, legacyFileName), data, 0600))
+
+	entry := SwitchEntry{From: "env3", To: "env1"}
+	require.NoError(t, Append(&entry))
+	assert.Equal(t, 3, entry.ID)
+	assert.NoFileExists(t, filepath.Join(dir, legacyFileName))
+
+	loaded, err := LoadHistory()
+	require.NoError(t, err)
+	require.Len(t, loaded.Entries, 3)
+	assert.Equal(t, 1, loaded.Entries[0].ID)
+	assert.Equal(t, "env2", loaded.Entries[1].From)
+}
+
+func TestSaveReplacesRotatedLogs(t *testing.T) {
+	dir := setupHistoryDir(t)
+
+	previous := maxLogSize
+	maxLogSize = 1
+	defer func() { maxLogSize = previous }()
+	for i := 0; i < 3; i++ {
+		require.NoError(t, Append(&SwitchEntry{From: "a", To: "b"}))
+	}
+
+	require.NoError(t, (&History{}).Save())
+	assert.NoFileExists(t, rotatedLogPath(dir, 1))
+
+	loaded, err := LoadHistory()
+	require.NoError(t, err)
+	assert.Empty(t, loaded.Entries)
+
+	entry := SwitchEntry{From: "a", To: "b"}
+	require.NoError(t, Append(&entry))
+	assert.Equal(t, 1, entry.ID)
+}
+
+func TestFiles(t *testing.T) {
+	dir := setupHistoryDir(t)
+
+	files, err := Files()
+	require.NoError(t, err)
+	assert.Empty(t, files)
+
+	require.NoError(t, Append(&SwitchEntry{From: "a", To: "b"}))
+	require.NoError(t, os.WriteFile(rotatedLogPath(dir, 1), []byte("{}\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "config.yaml"), []byte("{}"), 0644))
+
+	files, err = Files()
+	require.NoError(t, err)
+	assert.Contains(t, files, filepath.Join(dir, logFileName))
+	assert.Contains(t, files, rotatedLogPath(dir, 1))
+	assert.Contains(t, files, filepath.Join(dir, indexFileName))
+	assert.NotContains(t, files, filepath.Join(dir, "config.yaml"))
+}
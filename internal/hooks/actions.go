@@ -0,0 +1,175 @@
+package hooks
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+// actionCommand is the program a built-in action runs
+type actionCommand struct {
+	args     []string
+	stdinVar string // variable whose value is written to the program's stdin
+}
+
+// actionHost is what the command of an action depends on, the system it
+// runs on
+type actionHost struct {
+	goos string
+	uid  int
+}
+
+// actionBuilders build the command of each built-in action from its
+// parameters
+var actionBuilders = map[string]func(action environment.HookAction, hook environment.Hook, envName string, host actionHost) actionCommand{
+	environment.HookActionNotify:         notifyCommand,
+	environment.HookActionExec:           execCommand,
+	environment.HookActionDockerLogin:    dockerLoginCommand,
+	environment.HookActionRestartService: restartServiceCommand,
+	environment.HookActionRunMake:        runMakeCommand,
+}
+
+// buildActionCommand returns the command the action of a hook runs on host
+func buildActionCommand(hook environment.Hook, envName string, host actionHost) (actionCommand, error) {
+	action, err := environment.LookupHookAction(hook.Action)
+	if err != nil {
+		return actionCommand{}, err
+	}
+	build, ok := actionBuilders[action.Name]
+	if !ok {
+		return actionCommand{}, fmt.Errorf("action '%s' cannot run on this version", action.Name)
+	}
+	return build(action, hook, envName, host), nil
+}
+
+// notifyCommand shows a desktop notification with notify-send, osascript on
+// macOS and a tray balloon on Windows
+func notifyCommand(action environment.HookAction, hook environment.Hook, envName string, host actionHost) actionCommand {
+	message := strings.ReplaceAll(action.Param(hook.With, "message"), "{env}", envName)
+	title := action.Param(hook.With, "title")
+
+	switch host.goos {
+	case "darwin":
+		script := fmt.Sprintf("display notification %s with title %s", appleScriptQuote(message), appleScriptQuote(title))
+		return actionCommand{args: []string{"osascript", "-e", script}}
+	case "windows":
+		script := "Add-Type -AssemblyName System.Windows.Forms; " +
+			"$n = New-Object System.Windows.Forms.NotifyIcon; " +
+			"$n.Icon = [System.Drawing.SystemIcons]::Information; $n.Visible = $true; " +
+			fmt.Sprintf("$n.ShowBalloonTip(5000, %s, %s, 'Info'); ", powerShellQuote(title), powerShellQuote(message)) +
+			"Start-Sleep -Seconds 5; $n.Dispose()"
+		return actionCommand{args: []string{"powershell", "-NoProfile", "-NonInteractive", "-Command", script}}
+	default:
+		return actionCommand{args: []string{"notify-send", title, message}}
+	}
+}
+
+// execCommand runs the program of args
+func execCommand(action environment.HookAction, hook environment.Hook, envName string, host actionHost) actionCommand {
+	args := append([]string{expandPath(hook.Args[0])}, hook.Args[1:]...)
+	return actionCommand{args: args}
+}
+
+// dockerLoginCommand logs in to a registry, the password is passed on stdin
+// so that it never shows in the process list
+func dockerLoginCommand(action environment.HookAction, hook environment.Hook, envName string, host actionHost) actionCommand {
+	return actionCommand{
+		args: []string{"docker", "login", action.Param(hook.With, "registry"),
+			"--username", action.Param(hook.With, "username"), "--password-stdin"},
+		stdinVar: action.Param(hook.With, "password_var"),
+	}
+}
+
+// restartServiceCommand restarts a service with the service manager of the
+// system
+func restartServiceCommand(action environment.HookAction, hook environment.Hook, envName string, host actionHost) actionCommand {
+	service := action.Param(hook.With, "service")
+	user := action.Param(hook.With, "user") == "true"
+
+	switch host.goos {
+	case "darwin":
+		domain := "system"
+		if user {
+			domain = "gui/" + strconv.Itoa(host.uid)
+		}
+		return actionCommand{args: []string{"launchctl", "kickstart", "-k", domain + "/" + service}}
+	case "windows":
+		return actionCommand{args: []string{"powershell", "-NoProfile", "-NonInteractive", "-Command",
+			"Restart-Service -Name " + powerShellQuote(service)}}
+	default:
+		args := []string{"systemctl"}
+		if user {
+			args = append(args, "--user")
+		}
+		return actionCommand{args: append(args, "restart", service)}
+	}
+}
+
+// runMakeCommand runs make targets in the working directory of the hook
+func runMakeCommand(action environment.HookAction, hook environment.Hook, envName string, host actionHost) actionCommand {
+	args := []string{"make"}
+	if file := action.Param(hook.With, "file"); file != "" {
+		args = append(args, "-f", expandPath(file))
+	}
+	return actionCommand{args: append(args, strings.Fields(action.Param(hook.With, "target"))...)}
+}
+
+// stdin returns what the command reads on stdin, from the variables of the
+// hook or of envswitch
+func (c actionCommand) stdin(hook environment.Hook) (string, error) {
+	if c.stdinVar == "" {
+		return "", nil
+	}
+	value, ok := hook.Env[c.stdinVar]
+	if !ok {
+		value = os.Getenv(c.stdinVar)
+	}
+	if value == "" {
+		return "", fmt.Errorf("%s is not set (envswitch env set %s=... --secret)", c.stdinVar, c.stdinVar)
+	}
+	return value, nil
+}
+
+// String returns the command as it would be typed in sh
+func (c actionCommand) String() string {
+	quoted := make([]string, len(c.args))
+	for i, arg := range c.args {
+		quoted[i] = arg
+		if arg == "" || strings.ContainsAny(arg, " \t\n'\"\\$`*?[]{}()<>|&;#~") {
+			quoted[i] = shellQuote(arg)
+		}
+	}
+	return strings.Join(quoted, " ")
+}
+
+// DescribeAction describes the action of a hook with its parameters
+func DescribeAction(hook environment.Hook) string {
+	parts := []string{hook.Action}
+	if hook.Action == environment.HookActionExec {
+		parts = append(parts, hook.Args...)
+	}
+	names := make([]string, 0, len(hook.With))
+	for name := range hook.With {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		parts = append(parts, name+"="+hook.With[name])
+	}
+	return strings.Join(parts, " ")
+}
+
+// appleScriptQuote quotes a value as an AppleScript string
+func appleScriptQuote(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	return `"` + strings.ReplaceAll(value, `"`, `\"`) + `"`
+}
+
+// powerShellQuote quotes a value as a single-quoted PowerShell string
+func powerShellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
@@ -0,0 +1,124 @@
+package hooks
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+func TestBuildActionCommand(t *testing.T) {
+	linux := actionHost{goos: "linux", uid: 1000}
+	darwin := actionHost{goos: "darwin", uid: 501}
+	windows := actionHost{goos: "windows"}
+
+	t.Run("every action can run", func(t *testing.T) {
+		for _, name := range environment.HookActionNames() {
+			assert.Contains(t, actionBuilders, name)
+		}
+	})
+
+	t.Run("notify", func(t *testing.T) {
+		hook := environment.Hook{Action: "notify", With: map[string]string{"message": `On "{env}"`}}
+
+		command, err := buildActionCommand(hook, "work", linux)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"notify-send", "envswitch", `On "work"`}, command.args)
+
+		command, err = buildActionCommand(hook, "work", darwin)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"osascript", "-e", `display notification "On \"work\"" with title "envswitch"`}, command.args)
+
+		command, err = buildActionCommand(hook, "work", windows)
+		require.NoError(t, err)
+		assert.Equal(t, "powershell", command.args[0])
+		assert.Contains(t, command.args[len(command.args)-1], `ShowBalloonTip(5000, 'envswitch', 'On "work"', 'Info')`)
+	})
+
+	t.Run("restart-service", func(t *testing.T) {
+		hook := environment.Hook{Action: "restart-service", With: map[string]string{"service": "com.corp.vpn", "user": "true"}}
+
+		command, err := buildActionCommand(hook, "work", linux)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"systemctl", "--user", "restart", "com.corp.vpn"}, command.args)
+
+		command, err = buildActionCommand(hook, "work", darwin)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"launchctl", "kickstart", "-k", "gui/501/com.corp.vpn"}, command.args)
+
+		hook.With["user"] = "false"
+		command, err = buildActionCommand(hook, "work", darwin)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"launchctl", "kickstart", "-k", "system/com.corp.vpn"}, command.args)
+	})
+
+	t.Run("run-make", func(t *testing.T) {
+		command, err := buildActionCommand(environment.Hook{Action: "run-make", With: map[string]string{"target": "login  env"}}, "work", linux)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"make", "login", "env"}, command.args)
+	})
+
+	t.Run("docker-login reads the password from a variable", func(t *testing.T) {
+		hook := environment.Hook{Action: "docker-login", With: map[string]string{"registry": "ghcr.io", "username": "me", "password_var": "GHCR_TOKEN"}}
+
+		command, err := buildActionCommand(hook, "work", linux)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"docker", "login", "ghcr.io", "--username", "me", "--password-stdin"}, command.args)
+		assert.NotContains(t, command.String(), "secret")
+
+		t.Setenv("GHCR_TOKEN", "")
+		_, err = command.stdin(hook)
+		assert.ErrorContains(t, err, "GHCR_TOKEN is not set")
+
+		t.Setenv("GHCR_TOKEN", "secret")
+		stdin, err := command.stdin(hook)
+		require.NoError(t, err)
+		assert.Equal(t, "secret", stdin)
+
+		hook.Env = map[string]string{"GHCR_TOKEN": "from-hook"}
+		stdin, err = command.stdin(hook)
+		require.NoError(t, err)
+		assert.Equal(t, "from-hook", stdin)
+	})
+}
+
+func TestExecuteActionHooks(t *testing.T) {
+	dir := t.TempDir()
+	marker := filepath.Join(dir, "it's here")
+
+	t.Run("exec runs the program without a shell", func(t *testing.T) {
+		hooks := []environment.Hook{{Action: "exec", Args: []string{"touch", marker}}}
+		require.NoError(t, ExecuteHooks(hooks, "work"))
+		assert.FileExists(t, marker)
+	})
+
+	t.Run("a failing action applies the failure policy", func(t *testing.T) {
+		hooks := []environment.Hook{{Action: "exec", Args: []string{"false"}}}
+		assert.ErrorContains(t, ExecuteHooks(hooks, "work"), "hook failed")
+
+		hooks[0].OnFailure = environment.HookFailWarn
+		assert.NoError(t, ExecuteHooks(hooks, "work"))
+	})
+
+	t.Run("invalid actions are rejected", func(t *testing.T) {
+		hooks := []environment.Hook{{Action: "notify", With: map[string]string{"colour": "red"}}}
+		assert.ErrorContains(t, ExecuteHooks(hooks, "work"), "has no parameter 'colour'")
+	})
+
+	t.Run("dry run shows the command", func(t *testing.T) {
+		hooks := []environment.Hook{
+			{Action: "exec", Args: []string{"touch", marker}},
+			{Action: "docker-login", With: map[string]string{"username": "me"}},
+		}
+
+		var out strings.Builder
+		require.NoError(t, DryRunHooks(&out, hooks, "work"))
+		assert.Contains(t, out.String(), "Hook 1/2: exec touch "+marker)
+		assert.Contains(t, out.String(), "    $ touch '"+dir+"/it'\"'\"'s here'\n")
+		assert.Contains(t, out.String(), "Hook 2/2: docker-login username=me\n    $ docker login docker.io --username me --password-stdin\n    stdin: $DOCKER_PASSWORD\n")
+	})
+}
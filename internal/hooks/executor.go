@@ -1,64 +1,132 @@
 package hooks
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/hugofrely/envswitch/internal/platform"
 	"github.com/hugofrely/envswitch/pkg/environment"
 )
 
+// waitDelay bounds how long a timed out hook may keep its output open, e.g.
+// through background processes it started
+var waitDelay = 2 * time.Second
+
 // ExecuteHooks executes a list of hooks
 func ExecuteHooks(hooks []environment.Hook, envName string) error {
+	return ExecuteHooksContext(context.Background(), hooks, envName)
+}
+
+// ExecuteHooksContext executes a list of hooks, stopping at the first one
+// that fails with the abort policy. Hooks are killed when ctx is done.
+func ExecuteHooksContext(ctx context.Context, hooks []environment.Hook, envName string) error {
 	for i, hook := range hooks {
-		if err := executeHook(hook, envName, i+1, len(hooks)); err != nil {
+		if err := executeHook(ctx, hook, envName, i+1, len(hooks)); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-// executeHook executes a single hook
-func executeHook(hook environment.Hook, envName string, index, total int) error {
-	description := hook.Description
-	if description == "" {
-		if hook.Command != "" {
-			description = hook.Command
+// DryRunHooks prints what ExecuteHooks would run for each hook, without
+// running anything. It fails on the first invalid hook.
+func DryRunHooks(w io.Writer, hooks []environment.Hook, envName string) error {
+	for i, hook := range hooks {
+		fmt.Fprintf(w, "  Hook %d/%d: %s\n", i+1, len(hooks), DescribeHook(hook))
+		if err := hook.Validate(); err != nil {
+			fmt.Fprintf(w, "    ✗ Invalid hook: %v\n", err)
+			return fmt.Errorf("invalid hook '%s': %w", DescribeHook(hook), err)
+		}
+
+		if hook.Action != "" {
+			command, err := buildActionCommand(hook, envName, currentHost())
+			if err != nil {
+				fmt.Fprintf(w, "    ✗ Invalid hook: %v\n", err)
+				return fmt.Errorf("invalid hook '%s': %w", DescribeHook(hook), err)
+			}
+			fmt.Fprintf(w, "    $ %s\n", command)
+			if command.stdinVar != "" {
+				fmt.Fprintf(w, "    stdin: $%s\n", command.stdinVar)
+			}
 		} else {
-			description = "custom script"
+			script := hook.Command
+			if script == "" {
+				script = hook.Script
+			}
+			for _, line := range strings.Split(script, "\n") {
+				fmt.Fprintf(w, "    $ %s\n", line)
+			}
+		}
+		if hook.Cwd != "" {
+			fmt.Fprintf(w, "    cwd: %s\n", expandPath(hook.Cwd))
+		}
+		if hook.Timeout != "" {
+			fmt.Fprintf(w, "    timeout: %s\n", hook.Timeout)
 		}
+		fmt.Fprintf(w, "    env: ENVSWITCH_ENV=%s", envName)
+		keys := make([]string, 0, len(hook.Env))
+		for key := range hook.Env {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fmt.Fprintf(w, " %s=%s", key, hook.Env[key])
+		}
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "    on failure: %s\n", hook.FailurePolicy())
 	}
+	return nil
+}
 
-	fmt.Printf("  Running hook %d/%d: %s\n", index, total, description)
-
-	var cmd *exec.Cmd
+// DescribeHook returns the description of a hook, or what it runs
+func DescribeHook(hook environment.Hook) string {
 	switch {
+	case hook.Description != "":
+		return hook.Description
 	case hook.Command != "":
-		// Execute as shell command
-		// #nosec G204 - Command execution from trusted user configuration is intentional
-		cmd = exec.Command("sh", "-c", hook.Command)
-	case hook.Script != "":
-		// Execute as inline script
-		// #nosec G204 - Script execution from trusted user configuration is intentional
-		cmd = exec.Command("sh", "-c", hook.Script)
+		return hook.Command
+	case hook.Action != "":
+		return DescribeAction(hook)
 	default:
-		return fmt.Errorf("hook has neither command nor script")
+		return "custom script"
 	}
+}
 
-	// Set environment variables
-	cmd.Env = append(os.Environ(),
-		fmt.Sprintf("ENVSWITCH_ENV=%s", envName),
-	)
+// executeHook executes a single hook, applying its failure policy
+func executeHook(ctx context.Context, hook environment.Hook, envName string, index, total int) error {
+	description := DescribeHook(hook)
 
-	// Capture output
-	output, err := cmd.CombinedOutput()
+	fmt.Printf("  Running hook %d/%d: %s\n", index, total, description)
+
+	if err := hook.Validate(); err != nil {
+		fmt.Printf("    ✗ Invalid hook: %v\n", err)
+		return fmt.Errorf("invalid hook '%s': %w", description, err)
+	}
+
+	output, err := runHook(ctx, hook, envName)
 	if err != nil {
-		fmt.Printf("    ✗ Hook failed: %v\n", err)
-		if len(output) > 0 {
-			fmt.Printf("    Output: %s\n", strings.TrimSpace(string(output)))
+		switch hook.FailurePolicy() {
+		case environment.HookFailIgnore:
+			fmt.Printf("    ✓ Failed (ignored): %v\n", err)
+			return nil
+		case environment.HookFailWarn:
+			fmt.Printf("    ⚠️  Hook failed: %v\n", err)
+			printOutput(output)
+			return nil
+		default:
+			fmt.Printf("    ✗ Hook failed: %v\n", err)
+			printOutput(output)
+			return fmt.Errorf("hook failed: %w", err)
 		}
-		return fmt.Errorf("hook failed: %w", err)
 	}
 
 	if hook.Verify {
@@ -69,3 +137,99 @@ func executeHook(hook environment.Hook, envName string, index, total int) error
 
 	return nil
 }
+
+// runHook runs the command or script of a hook within its timeout, working
+// directory and environment, and returns its combined output
+func runHook(ctx context.Context, hook environment.Hook, envName string) ([]byte, error) {
+	timeout, err := hook.TimeoutDuration()
+	if err != nil {
+		return nil, err
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	var cmd *exec.Cmd
+	if hook.Action != "" {
+		command, err := buildActionCommand(hook, envName, currentHost())
+		if err != nil {
+			return nil, err
+		}
+		stdin, err := command.stdin(hook)
+		if err != nil {
+			return nil, err
+		}
+		// #nosec G204 - Built-in actions run programs from trusted user configuration
+		cmd = exec.CommandContext(ctx, command.args[0], command.args[1:]...)
+		if stdin != "" {
+			cmd.Stdin = strings.NewReader(stdin)
+		}
+	} else {
+		script := hook.Command
+		if script == "" {
+			script = hook.Script
+		}
+
+		// #nosec G204 - Command execution from trusted user configuration is intentional
+		cmd = exec.CommandContext(ctx, "sh", "-c", script)
+	}
+	killProcessGroup(cmd)
+	cmd.WaitDelay = waitDelay
+
+	if hook.Cwd != "" {
+		dir := expandPath(hook.Cwd)
+		if info, statErr := os.Stat(dir); statErr != nil || !info.IsDir() {
+			return nil, fmt.Errorf("working directory %s does not exist", dir)
+		}
+		cmd.Dir = dir
+	}
+
+	// Hook variables come before ENVSWITCH_ENV so they cannot override it
+	cmd.Env = os.Environ()
+	keys := make([]string, 0, len(hook.Env))
+	for key := range hook.Env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		cmd.Env = append(cmd.Env, key+"="+hook.Env[key])
+	}
+	cmd.Env = append(cmd.Env, fmt.Sprintf("ENVSWITCH_ENV=%s", envName))
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		if timeout > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return output, fmt.Errorf("timed out after %s", timeout)
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return output, ctxErr
+		}
+		return output, err
+	}
+	return output, nil
+}
+
+// currentHost returns the system built-in actions run on
+func currentHost() actionHost {
+	return actionHost{goos: runtime.GOOS, uid: os.Getuid()}
+}
+
+// printOutput shows the output of a failed hook
+func printOutput(output []byte) {
+	if len(output) > 0 {
+		fmt.Printf("    Output: %s\n", strings.TrimSpace(string(output)))
+	}
+}
+
+// expandPath expands environment variables and a leading ~ in a hook path
+func expandPath(path string) string {
+	path = os.ExpandEnv(path)
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := platform.HomeDir(); err == nil {
+			path = filepath.Join(home, strings.TrimPrefix(path, "~"))
+		}
+	}
+	return path
+}
@@ -1,7 +1,11 @@
 package hooks
 
 import (
+	"context"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -75,6 +79,28 @@ func TestExecuteHooks(t *testing.T) {
 		assert.Error(t, err)
 	})
 
+	t.Run("warn and ignore policies continue after a failure", func(t *testing.T) {
+		marker := filepath.Join(t.TempDir(), "ran")
+		hooks := []environment.Hook{
+			{Command: "exit 1", OnFailure: environment.HookFailWarn},
+			{Command: "exit 2", OnFailure: environment.HookFailIgnore},
+			{Command: "touch " + marker},
+		}
+
+		err := ExecuteHooks(hooks, "test-env")
+		require.NoError(t, err)
+		assert.FileExists(t, marker)
+	})
+
+	t.Run("rejects an invalid failure policy", func(t *testing.T) {
+		hooks := []environment.Hook{
+			{Command: "echo hi", OnFailure: "retry"},
+		}
+
+		err := ExecuteHooks(hooks, "test-env")
+		assert.ErrorContains(t, err, "invalid on_failure")
+	})
+
 	t.Run("handles empty hooks list", func(t *testing.T) {
 		err := ExecuteHooks([]environment.Hook{}, "test-env")
 		assert.NoError(t, err)
@@ -87,7 +113,7 @@ func TestExecuteHook(t *testing.T) {
 			Command: "test \"$ENVSWITCH_ENV\" = \"my-env\"",
 		}
 
-		err := executeHook(hook, "my-env", 1, 1)
+		err := executeHook(context.Background(), hook, "my-env", 1, 1)
 		require.NoError(t, err)
 	})
 
@@ -97,7 +123,7 @@ func TestExecuteHook(t *testing.T) {
 			Description: "Custom description",
 		}
 
-		err := executeHook(hook, "test-env", 1, 1)
+		err := executeHook(context.Background(), hook, "test-env", 1, 1)
 		assert.NoError(t, err)
 	})
 
@@ -106,7 +132,92 @@ func TestExecuteHook(t *testing.T) {
 			Command: "echo 'test'",
 		}
 
-		err := executeHook(hook, "test-env", 1, 1)
+		err := executeHook(context.Background(), hook, "test-env", 1, 1)
+		assert.NoError(t, err)
+	})
+}
+
+func TestExecuteHookOptions(t *testing.T) {
+	t.Run("kills the hook after its timeout", func(t *testing.T) {
+		hook := environment.Hook{Command: "sleep 5", Timeout: "100ms"}
+
+		start := time.Now()
+		err := executeHook(context.Background(), hook, "test-env", 1, 1)
+		assert.ErrorContains(t, err, "timed out after 100ms")
+		assert.Less(t, time.Since(start), 4*time.Second)
+	})
+
+	t.Run("timeout with warn policy does not fail", func(t *testing.T) {
+		hook := environment.Hook{Command: "sleep 5", Timeout: "100ms", OnFailure: environment.HookFailWarn}
+
+		err := executeHook(context.Background(), hook, "test-env", 1, 1)
+		assert.NoError(t, err)
+	})
+
+	t.Run("rejects an invalid timeout", func(t *testing.T) {
+		hook := environment.Hook{Command: "true", Timeout: "soon"}
+
+		err := executeHook(context.Background(), hook, "test-env", 1, 1)
+		assert.ErrorContains(t, err, "invalid timeout")
+	})
+
+	t.Run("runs in the working directory", func(t *testing.T) {
+		dir := t.TempDir()
+		hook := environment.Hook{Command: "touch here", Cwd: dir}
+
+		err := executeHook(context.Background(), hook, "test-env", 1, 1)
+		require.NoError(t, err)
+		assert.FileExists(t, filepath.Join(dir, "here"))
+	})
+
+	t.Run("fails when the working directory is missing", func(t *testing.T) {
+		hook := environment.Hook{Command: "true", Cwd: filepath.Join(t.TempDir(), "missing")}
+
+		err := executeHook(context.Background(), hook, "test-env", 1, 1)
+		assert.ErrorContains(t, err, "does not exist")
+	})
+
+	t.Run("injects env without overriding ENVSWITCH_ENV", func(t *testing.T) {
+		hook := environment.Hook{
+			Command: `test "$REGION" = "eu-west-1" && test "$ENVSWITCH_ENV" = "my-env"`,
+			Env:     map[string]string{"REGION": "eu-west-1", "ENVSWITCH_ENV": "other"},
+		}
+
+		err := executeHook(context.Background(), hook, "my-env", 1, 1)
 		assert.NoError(t, err)
 	})
+
+	t.Run("stops when the context is cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		err := ExecuteHooksContext(ctx, []environment.Hook{{Command: "sleep 5"}}, "test-env")
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestDryRunHooks(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "ran")
+	hooks := []environment.Hook{
+		{
+			Command:   "touch " + marker,
+			Timeout:   "10s",
+			Cwd:       "/tmp",
+			Env:       map[string]string{"STAGE": "prod"},
+			OnFailure: environment.HookFailWarn,
+		},
+		{Script: "echo one\necho two", Description: "Two lines"},
+	}
+
+	var out strings.Builder
+	require.NoError(t, DryRunHooks(&out, hooks, "work"))
+	assert.NoFileExists(t, marker)
+	assert.Contains(t, out.String(), "Hook 1/2: touch "+marker)
+	assert.Contains(t, out.String(), "    $ touch "+marker)
+	assert.Contains(t, out.String(), "    cwd: /tmp\n    timeout: 10s\n    env: ENVSWITCH_ENV=work STAGE=prod\n    on failure: warn")
+	assert.Contains(t, out.String(), "Hook 2/2: Two lines\n    $ echo one\n    $ echo two\n")
+	assert.Contains(t, out.String(), "on failure: abort")
+
+	err := DryRunHooks(&out, []environment.Hook{{Command: "true", OnFailure: "retry"}}, "work")
+	assert.ErrorContains(t, err, "invalid on_failure")
 }
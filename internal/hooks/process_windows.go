@@ -0,0 +1,9 @@
+//go:build windows
+
+package hooks
+
+import "os/exec"
+
+// killProcessGroup is a no-op on Windows, where only the hook process itself
+// is killed when its context is done
+func killProcessGroup(cmd *exec.Cmd) {}
@@ -0,0 +1,191 @@
+package hooks
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+// TemplateParam is a value a hook template asks for
+type TemplateParam struct {
+	Name     string
+	Prompt   string
+	Default  string
+	Validate func(value string) error
+}
+
+// Template is a ready-made hook, filled in with the user's parameters
+type Template struct {
+	Name        string
+	Summary     string
+	Event       string
+	Params      []TemplateParam
+	description string // text/template for Hook.Description
+	script      string // text/template for Hook.Script, values go through quote
+}
+
+var templates = map[string]Template{
+	"vpn-switch": {
+		Name:    "vpn-switch",
+		Summary: "Connect to a VPN after switching (NetworkManager or macOS)",
+		Event:   environment.HookPostSwitch,
+		Params: []TemplateParam{
+			{Name: "connection", Prompt: "VPN connection name", Validate: requireValue},
+		},
+		description: "Connect VPN {{.connection}}",
+		script: `if command -v nmcli >/dev/null 2>&1; then
+    nmcli connection up id {{quote .connection}}
+elif command -v scutil >/dev/null 2>&1; then
+    scutil --nc start {{quote .connection}}
+else
+    echo "no supported VPN client found (nmcli, scutil)" >&2
+    exit 1
+fi`,
+	},
+	"slack-notify": {
+		Name:    "slack-notify",
+		Summary: "Post a message to a Slack incoming webhook after switching",
+		Event:   environment.HookPostSwitch,
+		Params: []TemplateParam{
+			{Name: "webhook_url", Prompt: "Slack webhook URL", Validate: validateWebhookURL},
+			{Name: "message", Prompt: "Message ({env} and {host} are replaced)", Default: "Switched to {env} on {host}", Validate: validateJSONText},
+		},
+		description: "Notify Slack",
+		script: `msg=$(printf '%s' {{quote .message}} | sed -e "s|{env}|$ENVSWITCH_ENV|g" -e "s|{host}|$(hostname)|g")
+curl -fsS -X POST -H 'Content-Type: application/json' \
+    --data "{\"text\": \"$msg\"}" {{quote .webhook_url}} >/dev/null`,
+	},
+	"docker-login": {
+		Name:    "docker-login",
+		Summary: "Log in to a Docker registry after switching",
+		Event:   environment.HookPostSwitch,
+		Params: []TemplateParam{
+			{Name: "registry", Prompt: "Registry", Default: "docker.io", Validate: requireValue},
+			{Name: "username", Prompt: "Username", Validate: requireValue},
+			{Name: "password_var", Prompt: "Variable holding the password or token", Default: "DOCKER_PASSWORD", Validate: environment.ValidateEnvVarKey},
+		},
+		description: "Docker login to {{.registry}} as {{.username}}",
+		script: `if [ -z "{{printf "${%s:-}" .password_var}}" ]; then
+    echo "{{.password_var}} is not set (envswitch env set {{.password_var}}=... --secret)" >&2
+    exit 1
+fi
+printf '%s' "${{.password_var}}" | docker login {{quote .registry}} --username {{quote .username}} --password-stdin`,
+	},
+}
+
+// Templates returns the available hook templates sorted by name
+func Templates() []Template {
+	list := make([]Template, 0, len(templates))
+	for _, tmpl := range templates {
+		list = append(list, tmpl)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// TemplateNames returns the names of the available hook templates
+func TemplateNames() []string {
+	var names []string
+	for _, tmpl := range Templates() {
+		names = append(names, tmpl.Name)
+	}
+	return names
+}
+
+// LookupTemplate returns the hook template called name
+func LookupTemplate(name string) (Template, error) {
+	tmpl, ok := templates[name]
+	if !ok {
+		return Template{}, fmt.Errorf("unknown hook template '%s' (available: %s)", name, strings.Join(TemplateNames(), ", "))
+	}
+	return tmpl, nil
+}
+
+// Render builds the hook from the template with values, which must contain
+// every parameter
+func (t Template) Render(values map[string]string) (environment.Hook, error) {
+	for _, param := range t.Params {
+		value, ok := values[param.Name]
+		if !ok {
+			return environment.Hook{}, fmt.Errorf("missing value for %s", param.Name)
+		}
+		if param.Validate != nil {
+			if err := param.Validate(value); err != nil {
+				return environment.Hook{}, fmt.Errorf("invalid %s: %w", param.Name, err)
+			}
+		}
+	}
+	for name := range values {
+		if !t.hasParam(name) {
+			return environment.Hook{}, fmt.Errorf("template '%s' has no parameter '%s'", t.Name, name)
+		}
+	}
+
+	description, err := renderText(t.description, values)
+	if err != nil {
+		return environment.Hook{}, err
+	}
+	script, err := renderText(t.script, values)
+	if err != nil {
+		return environment.Hook{}, err
+	}
+
+	return environment.Hook{Script: script, Description: description}, nil
+}
+
+// hasParam reports whether the template takes a parameter called name
+func (t Template) hasParam(name string) bool {
+	for _, param := range t.Params {
+		if param.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// renderText executes a template text with the parameter values
+func renderText(text string, values map[string]string) (string, error) {
+	tmpl, err := template.New("hook").Funcs(template.FuncMap{"quote": shellQuote}).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid hook template: %w", err)
+	}
+
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, values); err != nil {
+		return "", fmt.Errorf("failed to render hook template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// shellQuote quotes a value for sh
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'"'"'`) + "'"
+}
+
+// requireValue rejects empty values
+func requireValue(value string) error {
+	if strings.TrimSpace(value) == "" {
+		return fmt.Errorf("a value is required")
+	}
+	return nil
+}
+
+// validateWebhookURL accepts Slack-style HTTPS webhook URLs
+func validateWebhookURL(value string) error {
+	if !strings.HasPrefix(value, "https://") {
+		return fmt.Errorf("webhook URL must start with https://")
+	}
+	return nil
+}
+
+// validateJSONText rejects characters that would need escaping in the JSON
+// payload built by the hook
+func validateJSONText(value string) error {
+	if strings.ContainsAny(value, "\"\\\n") {
+		return fmt.Errorf("message cannot contain double quotes, backslashes or newlines")
+	}
+	return requireValue(value)
+}
@@ -0,0 +1,77 @@
+package hooks
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+func TestLookupTemplate(t *testing.T) {
+	tmpl, err := LookupTemplate("vpn-switch")
+	require.NoError(t, err)
+	assert.Equal(t, environment.HookPostSwitch, tmpl.Event)
+
+	_, err = LookupTemplate("unknown")
+	assert.ErrorContains(t, err, "available: docker-login, slack-notify, vpn-switch")
+}
+
+func TestTemplateRender(t *testing.T) {
+	values := map[string]map[string]string{
+		"vpn-switch":   {"connection": "work vpn"},
+		"slack-notify": {"webhook_url": "https://hooks.slack.com/services/x", "message": "Switched to {env} on {host}"},
+		"docker-login": {"registry": "ghcr.io", "username": "me", "password_var": "GHCR_TOKEN"},
+	}
+
+	for _, tmpl := range Templates() {
+		t.Run(tmpl.Name, func(t *testing.T) {
+			hook, err := tmpl.Render(values[tmpl.Name])
+			require.NoError(t, err)
+			assert.NotEmpty(t, hook.Description)
+			assert.NotEmpty(t, hook.Script)
+
+			// The generated script must be valid sh
+			out, err := exec.Command("sh", "-n", "-c", hook.Script).CombinedOutput()
+			assert.NoError(t, err, string(out))
+		})
+	}
+
+	t.Run("quotes values", func(t *testing.T) {
+		tmpl, err := LookupTemplate("vpn-switch")
+		require.NoError(t, err)
+
+		hook, err := tmpl.Render(map[string]string{"connection": "it's; rm -rf ~"})
+		require.NoError(t, err)
+		assert.Contains(t, hook.Script, `nmcli connection up id 'it'"'"'s; rm -rf ~'`)
+	})
+
+	t.Run("docker login reads the password variable", func(t *testing.T) {
+		tmpl, err := LookupTemplate("docker-login")
+		require.NoError(t, err)
+
+		hook, err := tmpl.Render(values["docker-login"])
+		require.NoError(t, err)
+		assert.Contains(t, hook.Script, `[ -z "${GHCR_TOKEN:-}" ]`)
+		assert.Contains(t, hook.Script, `printf '%s' "$GHCR_TOKEN" | docker login 'ghcr.io'`)
+	})
+
+	t.Run("validates values", func(t *testing.T) {
+		tmpl, err := LookupTemplate("slack-notify")
+		require.NoError(t, err)
+
+		_, err = tmpl.Render(map[string]string{"webhook_url": "http://insecure", "message": "hi"})
+		assert.ErrorContains(t, err, "https://")
+
+		_, err = tmpl.Render(map[string]string{"webhook_url": "https://x", "message": `say "hi"`})
+		assert.ErrorContains(t, err, "double quotes")
+
+		_, err = tmpl.Render(map[string]string{"webhook_url": "https://x"})
+		assert.ErrorContains(t, err, "missing value for message")
+
+		_, err = tmpl.Render(map[string]string{"webhook_url": "https://x", "message": "hi", "channel": "#ops"})
+		assert.ErrorContains(t, err, "no parameter 'channel'")
+	})
+}
@@ -0,0 +1,218 @@
+package keychain
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/hugofrely/envswitch/internal/keyring"
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+const (
+	// KindGeneric is a generic password item (security add-generic-password)
+	KindGeneric = "generic"
+	// KindInternet is an internet password item, as used by docker-credential-osxkeychain
+	KindInternet = "internet"
+
+	secretsFileName = "keychain.json"
+)
+
+// Secret is a captured Keychain item
+type Secret struct {
+	Service  string `json:"service"`
+	Account  string `json:"account"`
+	Kind     string `json:"kind"`
+	Password string `json:"password"`
+}
+
+// runSecurity runs the macOS security CLI and returns its output
+var runSecurity = func(args ...string) (string, error) {
+	// #nosec G204 - Arguments come from the user's allowlist
+	output, err := exec.Command("security", args...).Output()
+	return strings.TrimRight(string(output), "\n"), err
+}
+
+// runSecurityCommands runs command lines with security -i
+var runSecurityCommands = keyring.RunSecurityCommands
+
+// IsSupported reports whether Keychain integration is available on this system
+func IsSupported() bool {
+	if runtime.GOOS != "darwin" {
+		return false
+	}
+	_, err := exec.LookPath("security")
+	return err == nil
+}
+
+// ValidKind reports whether kind is a supported Keychain item kind
+func ValidKind(kind string) bool {
+	return kind == "" || kind == KindGeneric || kind == KindInternet
+}
+
+// Capture reads the allowlisted items from the Keychain. Items that cannot be
+// found are skipped with a warning.
+func Capture(items []environment.KeychainItem) ([]Secret, error) {
+	secrets := make([]Secret, 0, len(items))
+
+	for _, item := range items {
+		kind := kindOf(item.Kind)
+		if !ValidKind(kind) {
+			return nil, fmt.Errorf("unknown keychain item kind '%s' for %s", item.Kind, item.Service)
+		}
+
+		account := item.Account
+		if account == "" {
+			attributes, err := runSecurity(findArgs(kind, item.Service, "")...)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: keychain item '%s' not found, skipping\n", item.Service)
+				continue
+			}
+			account = parseAccount(attributes)
+		}
+
+		password, err := runSecurity(append(findArgs(kind, item.Service, account), "-w")...)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to read keychain item '%s': %v\n", item.Service, err)
+			continue
+		}
+
+		secrets = append(secrets, Secret{
+			Service:  item.Service,
+			Account:  account,
+			Kind:     kind,
+			Password: password,
+		})
+	}
+
+	return secrets, nil
+}
+
+// Restore writes the secrets back into the Keychain, updating existing items.
+// Passwords go through security -i on stdin, never on a command line.
+func Restore(secrets []Secret) error {
+	for _, secret := range secrets {
+		class := keyring.GenericPassword
+		if kindOf(secret.Kind) == KindInternet {
+			class = keyring.InternetPassword
+		}
+
+		command := keyring.SecurityAddCommand(class, secret.Service, secret.Account, secret.Password)
+		if err := runSecurityCommands(command); err != nil {
+			return fmt.Errorf("failed to restore keychain item '%s': %w", secret.Service, err)
+		}
+	}
+	return nil
+}
+
+// Save stores captured secrets in the environment's snapshot directory, with
+// their passwords encrypted like the values of secret variables. The file is
+// only readable by the current user; saving no secrets removes it.
+func Save(env *environment.Environment, secrets []Secret) error {
+	secretsPath := filepath.Join(env.Path, "snapshots", secretsFileName)
+
+	if len(secrets) == 0 {
+		if err := os.Remove(secretsPath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove keychain items: %w", err)
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(secretsPath), 0700); err != nil {
+		return fmt.Errorf("failed to create snapshots directory: %w", err)
+	}
+
+	sealed := make([]Secret, 0, len(secrets))
+	for _, secret := range secrets {
+		if !environment.IsSealedSecret(secret.Password) {
+			password, err := environment.SealSecret(secretLabel(secret), secret.Password)
+			if err != nil {
+				return fmt.Errorf("failed to encrypt keychain item '%s': %w", secret.Service, err)
+			}
+			secret.Password = password
+		}
+		sealed = append(sealed, secret)
+	}
+
+	data, err := json.MarshalIndent(sealed, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal keychain items: %w", err)
+	}
+
+	if err := os.WriteFile(secretsPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write keychain items: %w", err)
+	}
+
+	return nil
+}
+
+// Load reads the secrets captured for an environment, decrypting their
+// passwords. Passwords saved in plain text by older versions are kept as is.
+func Load(env *environment.Environment) ([]Secret, error) {
+	data, err := os.ReadFile(filepath.Join(env.Path, "snapshots", secretsFileName))
+	if os.IsNotExist(err) {
+		return []Secret{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keychain items: %w", err)
+	}
+
+	var secrets []Secret
+	if err := json.Unmarshal(data, &secrets); err != nil {
+		return nil, fmt.Errorf("failed to parse keychain items: %w", err)
+	}
+
+	for i, secret := range secrets {
+		if !environment.IsSealedSecret(secret.Password) {
+			continue
+		}
+		if secrets[i].Password, err = environment.OpenSecret(secretLabel(secret), secret.Password); err != nil {
+			return nil, fmt.Errorf("failed to decrypt keychain item '%s': %w", secret.Service, err)
+		}
+	}
+
+	return secrets, nil
+}
+
+// secretLabel binds an encrypted password to its item
+func secretLabel(secret Secret) string {
+	return "keychain:" + kindOf(secret.Kind) + ":" + secret.Service + ":" + secret.Account
+}
+
+// findArgs builds the security arguments to look up an item
+func findArgs(kind, service, account string) []string {
+	command := "find-" + keyring.GenericPassword
+	if kind == KindInternet {
+		command = "find-" + keyring.InternetPassword
+	}
+
+	args := []string{command, "-s", service}
+	if account != "" {
+		args = append(args, "-a", account)
+	}
+	return args
+}
+
+// parseAccount extracts the account attribute from security find-* output
+func parseAccount(attributes string) string {
+	for _, line := range strings.Split(attributes, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, `"acct"<blob>="`) {
+			continue
+		}
+		return strings.TrimSuffix(strings.TrimPrefix(line, `"acct"<blob>="`), `"`)
+	}
+	return ""
+}
+
+// kindOf returns the item kind, defaulting to generic
+func kindOf(kind string) string {
+	if kind == "" {
+		return KindGeneric
+	}
+	return kind
+}
@@ -0,0 +1,157 @@
+package keychain
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+// stubSecurity replaces the security CLI with a fake keychain
+func stubSecurity(t *testing.T, items map[string]Secret) *[][]string {
+	var calls [][]string
+	original := runSecurity
+	t.Cleanup(func() { runSecurity = original })
+
+	runSecurity = func(args ...string) (string, error) {
+		calls = append(calls, args)
+
+		service := ""
+		for i, arg := range args {
+			if arg == "-s" && i+1 < len(args) {
+				service = args[i+1]
+			}
+		}
+
+		if strings.HasPrefix(args[0], "add-") {
+			return "", nil
+		}
+
+		item, ok := items[service]
+		if !ok {
+			return "", fmt.Errorf("item not found")
+		}
+		if args[len(args)-1] == "-w" {
+			return item.Password, nil
+		}
+		return fmt.Sprintf("keychain: \"login.keychain-db\"\nattributes:\n    \"acct\"<blob>=\"%s\"\n    \"svce\"<blob>=\"%s\"", item.Account, service), nil
+	}
+
+	return &calls
+}
+
+func TestCapture(t *testing.T) {
+	calls := stubSecurity(t, map[string]Secret{
+		"api-token":       {Account: "me", Password: "s3cret"},
+		"index.docker.io": {Account: "docker-user", Password: "dckr"},
+	})
+
+	secrets, err := Capture([]environment.KeychainItem{
+		{Service: "api-token"},
+		{Service: "index.docker.io", Account: "docker-user", Kind: KindInternet},
+		{Service: "missing"},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []Secret{
+		{Service: "api-token", Account: "me", Kind: KindGeneric, Password: "s3cret"},
+		{Service: "index.docker.io", Account: "docker-user", Kind: KindInternet, Password: "dckr"},
+	}, secrets)
+	assert.Contains(t, *calls, []string{"find-internet-password", "-s", "index.docker.io", "-a", "docker-user", "-w"})
+}
+
+func TestCaptureUnknownKind(t *testing.T) {
+	stubSecurity(t, nil)
+
+	_, err := Capture([]environment.KeychainItem{{Service: "x", Kind: "certificate"}})
+	assert.Error(t, err)
+}
+
+func TestRestore(t *testing.T) {
+	calls := stubSecurity(t, nil)
+
+	var commands []string
+	original := runSecurityCommands
+	t.Cleanup(func() { runSecurityCommands = original })
+	runSecurityCommands = func(input string) error {
+		commands = append(commands, input)
+		return nil
+	}
+
+	err := Restore([]Secret{
+		{Service: "api-token", Account: "me", Kind: KindGeneric, Password: "s3cret"},
+		{Service: "index.docker.io", Account: "docker-user", Kind: KindInternet, Password: "dckr"},
+	})
+	require.NoError(t, err)
+
+	// Passwords are hex encoded on stdin, never arguments of security
+	assert.Empty(t, *calls)
+	assert.Equal(t, []string{
+		"add-generic-password -U -s \"api-token\" -a \"me\" -X 733363726574\n",
+		"add-internet-password -U -s \"index.docker.io\" -a \"docker-user\" -X 64636b72\n",
+	}, commands)
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	t.Setenv(environment.PassphraseVariable, "correct horse")
+	env := &environment.Environment{Name: "work", Path: t.TempDir()}
+
+	secrets, err := Load(env)
+	require.NoError(t, err)
+	assert.Empty(t, secrets)
+
+	saved := []Secret{{Service: "api-token", Account: "me", Kind: KindGeneric, Password: "s3cret"}}
+	require.NoError(t, Save(env, saved))
+
+	info, err := os.Stat(filepath.Join(env.Path, "snapshots", secretsFileName))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// Passwords are never written in plain text
+	data, err := os.ReadFile(filepath.Join(env.Path, "snapshots", secretsFileName))
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "s3cret")
+	assert.Contains(t, string(data), "envswitch-secret:passphrase:")
+
+	loaded, err := Load(env)
+	require.NoError(t, err)
+	assert.Equal(t, saved, loaded)
+
+	// Saving nothing removes the file
+	require.NoError(t, Save(env, nil))
+	assert.NoFileExists(t, filepath.Join(env.Path, "snapshots", secretsFileName))
+}
+
+func TestLoadWrongPassphrase(t *testing.T) {
+	t.Setenv(environment.PassphraseVariable, "correct horse")
+	env := &environment.Environment{Name: "work", Path: t.TempDir()}
+	require.NoError(t, Save(env, []Secret{{Service: "api-token", Account: "me", Kind: KindGeneric, Password: "s3cret"}}))
+
+	t.Setenv(environment.PassphraseVariable, "wrong")
+	_, err := Load(env)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "api-token")
+}
+
+func TestLoadPlaintext(t *testing.T) {
+	env := &environment.Environment{Name: "work", Path: t.TempDir()}
+	require.NoError(t, os.MkdirAll(filepath.Join(env.Path, "snapshots"), 0700))
+	legacy := `[{"service": "api-token", "account": "me", "kind": "generic", "password": "s3cret"}]`
+	require.NoError(t, os.WriteFile(filepath.Join(env.Path, "snapshots", secretsFileName), []byte(legacy), 0600))
+
+	loaded, err := Load(env)
+	require.NoError(t, err)
+	assert.Equal(t, []Secret{{Service: "api-token", Account: "me", Kind: KindGeneric, Password: "s3cret"}}, loaded)
+}
+
+func TestParseAccount(t *testing.T) {
+	output := "attributes:\n    \"acct\"<blob>=\"alice@example.com\"\n    \"svce\"<blob>=\"token\""
+	assert.Equal(t, "alice@example.com", parseAccount(output))
+	assert.Equal(t, "", parseAccount("attributes:\n    \"acct\"<blob>=<NULL>"))
+}
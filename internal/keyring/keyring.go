@@ -0,0 +1,98 @@
+// Package keyring stores secrets in the keyring of the operating system: the
+// Keychain on macOS, the Secret Service (through secret-tool) on Linux and
+// the Credential Manager on Windows.
+package keyring
+
+import (
+	"errors"
+	"sync"
+)
+
+var (
+	// ErrNotFound is returned when the keyring has no secret for a service
+	// and user
+	ErrNotFound = errors.New("secret not found in keyring")
+	// ErrUnsupported is returned when no keyring is available
+	ErrUnsupported = errors.New("no keyring available on this system")
+)
+
+// provider is a keyring backend
+type provider interface {
+	available() bool
+	set(service, user, secret string) error
+	get(service, user string) (string, error)
+	delete(service, user string) error
+}
+
+// backend is the keyring of the operating system, replaced by MockInit
+var backend provider = systemProvider{}
+
+// IsSupported reports whether a keyring is available
+func IsSupported() bool {
+	return backend.available()
+}
+
+// Set stores the secret of user for service, replacing the previous one
+func Set(service, user, secret string) error {
+	if !backend.available() {
+		return ErrUnsupported
+	}
+	return backend.set(service, user, secret)
+}
+
+// Get returns the secret of user for service, ErrNotFound when there is none
+func Get(service, user string) (string, error) {
+	if !backend.available() {
+		return "", ErrUnsupported
+	}
+	return backend.get(service, user)
+}
+
+// Delete removes the secret of user for service, ErrNotFound when there is
+// none
+func Delete(service, user string) error {
+	if !backend.available() {
+		return ErrUnsupported
+	}
+	return backend.delete(service, user)
+}
+
+// MockInit replaces the keyring with an in-memory one, for tests
+func MockInit() {
+	backend = &memoryProvider{secrets: make(map[string]string)}
+}
+
+// memoryProvider keeps secrets in memory
+type memoryProvider struct {
+	mu      sync.Mutex
+	secrets map[string]string
+}
+
+func (m *memoryProvider) available() bool { return true }
+
+func (m *memoryProvider) set(service, user, secret string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.secrets[service+"\x00"+user] = secret
+	return nil
+}
+
+func (m *memoryProvider) get(service, user string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	secret, ok := m.secrets[service+"\x00"+user]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return secret, nil
+}
+
+func (m *memoryProvider) delete(service, user string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.secrets[service+"\x00"+user]; !ok {
+		return ErrNotFound
+	}
+	delete(m.secrets, service+"\x00"+user)
+	return nil
+}
@@ -0,0 +1,49 @@
+package keyring
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// securityNotFound is the exit code of the security CLI for missing items
+const securityNotFound = 44
+
+// systemProvider stores secrets as generic passwords of the login Keychain
+type systemProvider struct{}
+
+func (systemProvider) available() bool {
+	_, err := exec.LookPath("security")
+	return err == nil
+}
+
+func (systemProvider) set(service, user, secret string) error {
+	return RunSecurityCommands(SecurityAddCommand(GenericPassword, service, user, secret))
+}
+
+func (systemProvider) get(service, user string) (string, error) {
+	// #nosec G204 - Arguments are envswitch's own service and account names
+	output, err := exec.Command("security", "find-generic-password", "-s", service, "-a", user, "-w").Output()
+	if err != nil {
+		return "", securityError(err)
+	}
+	return strings.TrimSuffix(string(output), "\n"), nil
+}
+
+func (systemProvider) delete(service, user string) error {
+	// #nosec G204 - Arguments are envswitch's own service and account names
+	if err := exec.Command("security", "delete-generic-password", "-s", service, "-a", user).Run(); err != nil {
+		return securityError(err)
+	}
+	return nil
+}
+
+// securityError turns the exit code of a missing item into ErrNotFound
+func securityError(err error) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == securityNotFound {
+		return ErrNotFound
+	}
+	return fmt.Errorf("security: %w", err)
+}
@@ -0,0 +1,56 @@
+//go:build !darwin && !windows
+
+package keyring
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// systemProvider stores secrets in the Secret Service (GNOME Keyring,
+// KWallet...) through secret-tool
+type systemProvider struct{}
+
+func (systemProvider) available() bool {
+	_, err := exec.LookPath("secret-tool")
+	return err == nil
+}
+
+func (systemProvider) set(service, user, secret string) error {
+	// #nosec G204 - Arguments are envswitch's own service and account names
+	cmd := exec.Command("secret-tool", "store", "--label", service+" "+user, "service", service, "username", user)
+	// Read from stdin so the secret is not in the process list
+	cmd.Stdin = strings.NewReader(secret)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("secret-tool: %s: %w", strings.TrimSpace(string(output)), err)
+	}
+	return nil
+}
+
+func (systemProvider) get(service, user string) (string, error) {
+	// #nosec G204 - Arguments are envswitch's own service and account names
+	cmd := exec.Command("secret-tool", "lookup", "service", service, "username", user)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		// secret-tool exits with 1 without output for missing secrets
+		if stdout.Len() == 0 && stderr.Len() == 0 {
+			return "", ErrNotFound
+		}
+		return "", fmt.Errorf("secret-tool: %s: %w", strings.TrimSpace(stderr.String()), err)
+	}
+	return stdout.String(), nil
+}
+
+func (systemProvider) delete(service, user string) error {
+	if _, err := (systemProvider{}).get(service, user); err != nil {
+		return err
+	}
+	// #nosec G204 - Arguments are envswitch's own service and account names
+	if output, err := exec.Command("secret-tool", "clear", "service", service, "username", user).CombinedOutput(); err != nil {
+		return fmt.Errorf("secret-tool: %s: %w", strings.TrimSpace(string(output)), err)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package keyring
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMockKeyring(t *testing.T) {
+	MockInit()
+	require.True(t, IsSupported())
+
+	_, err := Get("envswitch", "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, Set("envswitch", "user", "secret"))
+	require.NoError(t, Set("envswitch", "user", "replaced"))
+	secret, err := Get("envswitch", "user")
+	require.NoError(t, err)
+	assert.Equal(t, "replaced", secret)
+
+	require.NoError(t, Delete("envswitch", "user"))
+	assert.ErrorIs(t, Delete("envswitch", "user"), ErrNotFound)
+}
+
+type unavailableProvider struct{ memoryProvider }
+
+func (*unavailableProvider) available() bool { return false }
+
+func TestUnsupportedKeyring(t *testing.T) {
+	original := backend
+	backend = &unavailableProvider{}
+	t.Cleanup(func() { backend = original })
+
+	assert.False(t, IsSupported())
+	assert.ErrorIs(t, Set("envswitch", "user", "secret"), ErrUnsupported)
+	_, err := Get("envswitch", "user")
+	assert.ErrorIs(t, err, ErrUnsupported)
+}
+
+func TestSecurityAddCommand(t *testing.T) {
+	assert.Equal(t, "add-generic-password -U -s \"envswitch\" -a \"a \\\"b\\\"\" -X 7365637265742d31\n",
+		SecurityAddCommand(GenericPassword, "envswitch", `a "b"`, "secret-1"))
+	assert.Equal(t, "add-internet-password -U -s \"index.docker.io\" -X 78\n",
+		SecurityAddCommand(InternetPassword, "index.docker.io", "", "x"))
+}
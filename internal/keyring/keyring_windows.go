@@ -0,0 +1,116 @@
+package keyring
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	credTypeGeneric         = 1
+	credPersistLocalMachine = 2
+	// credMaxBlobSize is the largest secret the Credential Manager holds
+	credMaxBlobSize = 5 * 512
+	errorNotFound   = syscall.Errno(1168)
+)
+
+var (
+	advapi32    = syscall.NewLazyDLL("advapi32.dll")
+	credWriteW  = advapi32.NewProc("CredWriteW")
+	credReadW   = advapi32.NewProc("CredReadW")
+	credDeleteW = advapi32.NewProc("CredDeleteW")
+	credFree    = advapi32.NewProc("CredFree")
+)
+
+// credential is the CREDENTIALW structure
+type credential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        syscall.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+// systemProvider stores secrets as generic credentials of the Credential
+// Manager
+type systemProvider struct{}
+
+func (systemProvider) available() bool {
+	return credWriteW.Find() == nil
+}
+
+func (systemProvider) set(service, user, secret string) error {
+	if len(secret) > credMaxBlobSize {
+		return fmt.Errorf("secret of %d bytes exceeds the %d bytes the Credential Manager holds", len(secret), credMaxBlobSize)
+	}
+	target, err := syscall.UTF16PtrFromString(service + ":" + user)
+	if err != nil {
+		return err
+	}
+	userName, err := syscall.UTF16PtrFromString(user)
+	if err != nil {
+		return err
+	}
+
+	blob := []byte(secret)
+	cred := credential{
+		Type:               credTypeGeneric,
+		TargetName:         target,
+		CredentialBlobSize: uint32(len(blob)),
+		Persist:            credPersistLocalMachine,
+		UserName:           userName,
+	}
+	if len(blob) > 0 {
+		cred.CredentialBlob = &blob[0]
+	}
+
+	if ret, _, err := credWriteW.Call(uintptr(unsafe.Pointer(&cred)), 0); ret == 0 {
+		return fmt.Errorf("CredWrite: %w", err)
+	}
+	return nil
+}
+
+func (systemProvider) get(service, user string) (string, error) {
+	target, err := syscall.UTF16PtrFromString(service + ":" + user)
+	if err != nil {
+		return "", err
+	}
+
+	var cred *credential
+	if ret, _, err := credReadW.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&cred))); ret == 0 {
+		return "", credError("CredRead", err)
+	}
+	defer credFree.Call(uintptr(unsafe.Pointer(cred))) // #nosec G104
+
+	if cred.CredentialBlobSize == 0 {
+		return "", nil
+	}
+	return string(unsafe.Slice(cred.CredentialBlob, cred.CredentialBlobSize)), nil
+}
+
+func (systemProvider) delete(service, user string) error {
+	target, err := syscall.UTF16PtrFromString(service + ":" + user)
+	if err != nil {
+		return err
+	}
+	if ret, _, err := credDeleteW.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0); ret == 0 {
+		return credError("CredDelete", err)
+	}
+	return nil
+}
+
+// credError turns the error of a missing credential into ErrNotFound
+func credError(call string, err error) error {
+	if errors.Is(err, errorNotFound) {
+		return ErrNotFound
+	}
+	return fmt.Errorf("%s: %w", call, err)
+}
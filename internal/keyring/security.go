@@ -0,0 +1,46 @@
+package keyring
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// The macOS security CLI is also used by internal/keychain for the Keychain
+// items captured with environments, so that both write secrets the same way.
+
+const (
+	// GenericPassword is the Keychain class of generic password items
+	GenericPassword = "generic-password"
+	// InternetPassword is the Keychain class of internet password items
+	InternetPassword = "internet-password"
+)
+
+// SecurityAddCommand returns the security -i command line adding the password
+// secret of account for service to the Keychain, or updating it. class is
+// GenericPassword or InternetPassword, the account may be empty. The secret is
+// hex encoded: fed on stdin, it is neither in the process list nor subject to
+// quoting.
+func SecurityAddCommand(class, service, account, secret string) string {
+	command := fmt.Sprintf("add-%s -U -s %s", class, shellQuote(service))
+	if account != "" {
+		command += " -a " + shellQuote(account)
+	}
+	return command + " -X " + hex.EncodeToString([]byte(secret)) + "\n"
+}
+
+// RunSecurityCommands runs command lines with security -i, on stdin
+func RunSecurityCommands(commands string) error {
+	cmd := exec.Command("security", "-i")
+	cmd.Stdin = strings.NewReader(commands)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("security: %s: %w", strings.TrimSpace(string(output)), err)
+	}
+	return nil
+}
+
+// shellQuote quotes a value for the command line of security -i
+func shellQuote(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
@@ -1,13 +1,17 @@
 package logger
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/hugofrely/envswitch/internal/config"
+	"github.com/hugofrely/envswitch/internal/redact"
 )
 
 // LogLevel represents logging levels
@@ -20,49 +24,157 @@ const (
 	LevelError
 )
 
+// Log formats of the log file, see log_format
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
 // Logger handles application logging
 type Logger struct {
 	level      LogLevel
+	levels     map[string]LogLevel // per subsystem overrides
 	file       *os.File
 	showColors bool
 	showTime   bool
+	mu         sync.Mutex // serializes writes from concurrent tool workers
+
+	// The log file, rotated on write
+	path      string
+	format    string
+	rotation  rotation
+	size      int64
+	lastWrite time.Time
+
+	// runFile receives every message whatever the levels, see StartRunLog
+	runFile *os.File
 }
 
 var (
 	globalLogger *Logger
 )
 
+// Console overrides set by --quiet and --no-color, whatever the config says.
+// The log file is not affected.
+var (
+	consoleQuiet   bool
+	consoleNoColor bool
+)
+
+// SetConsoleMode only shows warnings and errors on the console when quiet is
+// set, and never colors them when noColor is set
+func SetConsoleMode(quiet, noColor bool) {
+	consoleQuiet = quiet
+	consoleNoColor = noColor
+}
+
 // InitLogger initializes the global logger from config
 func InitLogger(cfg *config.Config) error {
 	level := parseLogLevel(cfg.LogLevel)
 
-	var file *os.File
-	var err error
+	levels := make(map[string]LogLevel, len(cfg.LogLevels))
+	for subsystem, subsystemLevel := range cfg.LogLevels {
+		levels[subsystem] = parseLogLevel(subsystemLevel)
+	}
+
+	l := &Logger{
+		level:      level,
+		levels:     levels,
+		showColors: cfg.ColorOutput,
+		showTime:   cfg.ShowTimestamps,
+		path:       cfg.LogFile,
+		format:     cfg.LogFormat,
+		rotation: rotation{
+			maxSize:  int64(cfg.LogMaxSizeMB) * 1024 * 1024,
+			daily:    cfg.LogRotateDaily,
+			maxFiles: cfg.LogMaxFiles,
+		},
+	}
 
 	if cfg.LogFile != "" {
 		// Create log directory if it doesn't exist
 		logDir := filepath.Dir(cfg.LogFile)
-		if mkdirErr := os.MkdirAll(logDir, 0755); mkdirErr != nil {
+		if mkdirErr := os.MkdirAll(logDir, 0700); mkdirErr != nil {
 			return fmt.Errorf("failed to create log directory: %w", mkdirErr)
 		}
 
-		// Open log file in append mode
-		file, err = os.OpenFile(cfg.LogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-		if err != nil {
-			return fmt.Errorf("failed to open log file: %w", err)
+		if info, err := os.Stat(cfg.LogFile); err == nil && l.rotation.due(info.Size(), info.ModTime(), time.Now()) {
+			if err := rotateFile(cfg.LogFile, info.ModTime(), l.rotation.maxFiles); err != nil {
+				return err
+			}
+		}
+		if err := l.openFile(); err != nil {
+			return err
 		}
 	}
 
-	globalLogger = &Logger{
-		level:      level,
-		file:       file,
-		showColors: cfg.ColorOutput,
-		showTime:   cfg.ShowTimestamps,
-	}
+	globalLogger = l
+	return nil
+}
 
+// openFile opens the log file in append mode. Messages can hold details of
+// the environments, so the file is only readable by the current user, even
+// when an older version created it.
+func (l *Logger) openFile() error {
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	if err := file.Chmod(0600); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to restrict log file permissions: %w", err)
+	}
+	l.file = file
+	l.size, l.lastWrite = 0, time.Now()
+	if info, err := file.Stat(); err == nil {
+		l.size, l.lastWrite = info.Size(), info.ModTime()
+	}
 	return nil
 }
 
+// rotateIfDue rotates the log file before writing to it at now, when it
+// grew too large or was last written another day
+func (l *Logger) rotateIfDue(now time.Time) {
+	if !l.rotation.due(l.size, l.lastWrite, now) {
+		return
+	}
+	_ = l.file.Close()
+	l.file = nil
+	if err := rotateFile(l.path, l.lastWrite, l.rotation.maxFiles); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	if err := l.openFile(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+}
+
+// StartRunLog writes every message to a new file at path, whatever the log
+// levels, until the returned function is called. It keeps the details of
+// one run, e.g. of a switch, for post-mortem debugging.
+func StartRunLog(path string) (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open run log: %w", err)
+	}
+
+	l := GetLogger()
+	l.mu.Lock()
+	l.runFile = file
+	l.mu.Unlock()
+
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if l.runFile == file {
+			l.runFile = nil
+		}
+		_ = file.Close()
+	}, nil
+}
+
 // GetLogger returns the global logger instance
 func GetLogger() *Logger {
 	if globalLogger == nil {
@@ -78,8 +190,15 @@ func GetLogger() *Logger {
 
 // Close closes the log file if open
 func Close() error {
-	if globalLogger != nil && globalLogger.file != nil {
-		return globalLogger.file.Close()
+	if globalLogger == nil {
+		return nil
+	}
+	globalLogger.mu.Lock()
+	defer globalLogger.mu.Unlock()
+	if globalLogger.file != nil {
+		err := globalLogger.file.Close()
+		globalLogger.file = nil
+		return err
 	}
 	return nil
 }
@@ -104,32 +223,136 @@ func Error(format string, args ...interface{}) {
 	GetLogger().log(LevelError, format, args...)
 }
 
+// NamedLogger logs on behalf of a subsystem (e.g. "tools.restore"). Its
+// level can be set in config with log_levels; subsystems inherit the level of
+// their parent ("tools"), then the global log_level.
+type NamedLogger struct {
+	name string
+}
+
+// Named returns a logger for the given subsystem
+func Named(name string) *NamedLogger {
+	return &NamedLogger{name: name}
+}
+
+// Named returns a logger for a child subsystem
+func (n *NamedLogger) Named(name string) *NamedLogger {
+	return &NamedLogger{name: n.name + "." + name}
+}
+
+// Debug logs a debug message
+func (n *NamedLogger) Debug(format string, args ...interface{}) {
+	GetLogger().logNamed(n.name, LevelDebug, format, args...)
+}
+
+// Info logs an info message
+func (n *NamedLogger) Info(format string, args ...interface{}) {
+	GetLogger().logNamed(n.name, LevelInfo, format, args...)
+}
+
+// Warn logs a warning message
+func (n *NamedLogger) Warn(format string, args ...interface{}) {
+	GetLogger().logNamed(n.name, LevelWarn, format, args...)
+}
+
+// Error logs an error message
+func (n *NamedLogger) Error(format string, args ...interface{}) {
+	GetLogger().logNamed(n.name, LevelError, format, args...)
+}
+
+// levelFor returns the effective level of a subsystem
+func (l *Logger) levelFor(name string) LogLevel {
+	for name != "" {
+		if level, ok := l.levels[name]; ok {
+			return level
+		}
+		idx := strings.LastIndex(name, ".")
+		if idx < 0 {
+			break
+		}
+		name = name[:idx]
+	}
+	return l.level
+}
+
 // log performs the actual logging
 func (l *Logger) log(level LogLevel, format string, args ...interface{}) {
-	if level < l.level {
+	l.logNamed("", level, format, args...)
+}
+
+// logNamed logs a message of a subsystem, prefixed with its name
+func (l *Logger) logNamed(name string, level LogLevel, format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	enabled := level >= l.levelFor(name)
+	if !enabled && l.runFile == nil {
 		return
 	}
 
-	msg := fmt.Sprintf(format, args...)
-	timestamp := ""
+	// Masked before writing anywhere, errors may quote credentials
+	msg := redact.String(fmt.Sprintf(format, args...))
+	now := time.Now()
+	// Strip colors for file output, always timestamped so it can be
+	// filtered by `envswitch logs --since`
+	fileOutput := formatLine(l.format, now, level, name, msg)
 
-	if l.showTime {
-		timestamp = time.Now().Format("2006-01-02 15:04:05") + " "
+	if l.runFile != nil {
+		_, _ = l.runFile.WriteString(fileOutput)
+	}
+	if !enabled {
+		return
 	}
 
-	levelStr := levelString(level, l.showColors)
-	output := fmt.Sprintf("%s%s %s\n", timestamp, levelStr, msg)
+	if name != "" {
+		msg = fmt.Sprintf("[%s] %s", name, msg)
+	}
+	timestamp := ""
+	if l.showTime {
+		timestamp = now.Format(TimeFormat) + " "
+	}
 
 	// Write to stdout/stderr
-	writer := l.getWriter(level)
-	fmt.Fprint(writer, output)
+	if !consoleQuiet || level >= LevelWarn {
+		levelStr := levelString(level, l.ShouldShowColors())
+		fmt.Fprintf(l.getWriter(level), "%s%s %s\n", timestamp, levelStr, msg)
+	}
 
 	// Write to file if configured
 	if l.file != nil {
-		// Strip colors for file output
-		fileOutput := fmt.Sprintf("%s%s %s\n", timestamp, levelStringPlain(level), msg)
-		l.file.WriteString(fileOutput)
+		l.rotateIfDue(now)
 	}
+	if l.file != nil {
+		n, _ := l.file.WriteString(fileOutput)
+		l.size += int64(n)
+		l.lastWrite = now
+	}
+}
+
+// formatLine returns the line of a message in the log file, in the text
+// format or as a JSON object
+func formatLine(format string, now time.Time, level LogLevel, name, msg string) string {
+	if format == FormatJSON {
+		data, err := json.Marshal(jsonEntry{
+			Time:      now.Format(time.RFC3339),
+			Level:     levelName(level),
+			Subsystem: name,
+			Message:   msg,
+		})
+		if err == nil {
+			return string(data) + "\n"
+		}
+	}
+
+	if name != "" {
+		msg = fmt.Sprintf("[%s] %s", name, msg)
+	}
+	return fmt.Sprintf("%s %s %s\n", now.Format(TimeFormat), levelStringPlain(level), msg)
+}
+
+// levelName returns the name of a level, as in config
+func levelName(level LogLevel) string {
+	return strings.ToLower(strings.Trim(levelStringPlain(level), "[]"))
 }
 
 // getWriter returns the appropriate output writer for the log level
@@ -194,7 +417,7 @@ func parseLogLevel(level string) LogLevel {
 
 // Colorize returns a colored string if colors are enabled
 func (l *Logger) Colorize(color, text string) string {
-	if !l.showColors {
+	if !l.ShouldShowColors() {
 		return text
 	}
 
@@ -217,5 +440,5 @@ func (l *Logger) Colorize(color, text string) string {
 
 // ShouldShowColors returns whether colors should be shown
 func (l *Logger) ShouldShowColors() bool {
-	return l.showColors
+	return l.showColors && !consoleNoColor
 }
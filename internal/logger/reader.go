@@ -0,0 +1,103 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TimeFormat is the layout of timestamps in log lines
+const TimeFormat = "2006-01-02 15:04:05"
+
+// Entry is a parsed line of the log file
+type Entry struct {
+	Time      time.Time // zero for lines written without a timestamp
+	Level     LogLevel
+	Subsystem string // empty for messages of the global logger
+	Message   string
+	Line      string // the line as written
+}
+
+// jsonEntry is a line of the log file in the json format
+type jsonEntry struct {
+	Time      string `json:"time"`
+	Level     string `json:"level"`
+	Subsystem string `json:"subsystem,omitempty"`
+	Message   string `json:"msg"`
+}
+
+// ParseEntry parses a line of the log file, in the text or json format. It
+// returns false for lines that are not log messages, e.g. continuation lines
+// of a multi-line message.
+func ParseEntry(line string) (Entry, bool) {
+	entry := Entry{Line: line}
+	if strings.HasPrefix(line, "{") {
+		return parseJSONEntry(entry)
+	}
+	rest := line
+
+	if len(rest) > len(TimeFormat) && rest[len(TimeFormat)] == ' ' {
+		if t, err := time.ParseInLocation(TimeFormat, rest[:len(TimeFormat)], time.Local); err == nil {
+			entry.Time = t
+			rest = rest[len(TimeFormat)+1:]
+		}
+	}
+
+	levelEnd := strings.Index(rest, "] ")
+	if !strings.HasPrefix(rest, "[") || levelEnd < 0 {
+		return entry, false
+	}
+	level, err := ParseLevel(strings.ToLower(rest[1:levelEnd]))
+	if err != nil {
+		return entry, false
+	}
+	entry.Level = level
+	rest = rest[levelEnd+2:]
+
+	if strings.HasPrefix(rest, "[") {
+		if end := strings.Index(rest, "] "); end > 0 {
+			entry.Subsystem = rest[1:end]
+			rest = rest[end+2:]
+		}
+	}
+	entry.Message = rest
+
+	return entry, true
+}
+
+// parseJSONEntry parses a line of the log file in the json format
+func parseJSONEntry(entry Entry) (Entry, bool) {
+	var line jsonEntry
+	if err := json.Unmarshal([]byte(entry.Line), &line); err != nil {
+		return entry, false
+	}
+	level, err := ParseLevel(line.Level)
+	if err != nil {
+		return entry, false
+	}
+
+	entry.Level = level
+	entry.Subsystem = line.Subsystem
+	entry.Message = line.Message
+	if t, err := time.Parse(time.RFC3339, line.Time); err == nil {
+		entry.Time = t
+	}
+	return entry, true
+}
+
+// ParseLevel converts a level name (debug, info, warn or error) to a LogLevel
+func ParseLevel(name string) (LogLevel, error) {
+	switch name {
+	case "debug":
+		return LevelDebug, nil
+	case "info":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	default:
+		return LevelInfo, fmt.Errorf("invalid log level '%s' (expected debug, info, warn or error)", name)
+	}
+}
@@ -0,0 +1,46 @@
+package logger
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEntry(t *testing.T) {
+	t.Run("timestamped line with subsystem", func(t *testing.T) {
+		entry, ok := ParseEntry("2024-03-01 09:15:00 [WARN] [tools.restore] Failed to restore aws")
+		require.True(t, ok)
+		assert.Equal(t, time.Date(2024, 3, 1, 9, 15, 0, 0, time.Local), entry.Time)
+		assert.Equal(t, LevelWarn, entry.Level)
+		assert.Equal(t, "tools.restore", entry.Subsystem)
+		assert.Equal(t, "Failed to restore aws", entry.Message)
+	})
+
+	t.Run("line without timestamp", func(t *testing.T) {
+		entry, ok := ParseEntry("[INFO] switched to work")
+		require.True(t, ok)
+		assert.True(t, entry.Time.IsZero())
+		assert.Equal(t, LevelInfo, entry.Level)
+		assert.Empty(t, entry.Subsystem)
+		assert.Equal(t, "switched to work", entry.Message)
+	})
+
+	t.Run("not a log message", func(t *testing.T) {
+		_, ok := ParseEntry("  continuation of a previous message")
+		assert.False(t, ok)
+
+		_, ok = ParseEntry("[LOUD] unknown level")
+		assert.False(t, ok)
+	})
+}
+
+func TestParseLevel(t *testing.T) {
+	level, err := ParseLevel("warn")
+	require.NoError(t, err)
+	assert.Equal(t, LevelWarn, level)
+
+	_, err = ParseLevel("verbose")
+	assert.Error(t, err)
+}
@@ -0,0 +1,81 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// rotatedTimeFormat is the layout of the timestamp suffixing rotated files
+const rotatedTimeFormat = "20060102-150405"
+
+// rotation is when the log file is rotated
+type rotation struct {
+	maxSize  int64 // in bytes, 0 for no limit
+	daily    bool  // also rotate on the first write of a new day
+	maxFiles int   // rotated files kept, 0 to keep them all
+}
+
+// due reports whether a log file of size bytes, last written at lastWrite,
+// is rotated before writing to it at now
+func (r rotation) due(size int64, lastWrite, now time.Time) bool {
+	if r.maxSize > 0 && size >= r.maxSize {
+		return true
+	}
+	if r.daily && size > 0 {
+		y1, m1, d1 := lastWrite.Date()
+		y2, m2, d2 := now.Date()
+		return y1 != y2 || m1 != m2 || d1 != d2
+	}
+	return false
+}
+
+// rotateFile renames the log file at path to path.<timestamp of its last
+// write>, then deletes the oldest rotated files beyond maxFiles
+func rotateFile(path string, lastWrite time.Time, maxFiles int) error {
+	rotated := path + "." + lastWrite.Format(rotatedTimeFormat)
+	for i := 1; ; i++ {
+		if _, err := os.Stat(rotated); os.IsNotExist(err) {
+			break
+		}
+		rotated = fmt.Sprintf("%s.%s-%d", path, lastWrite.Format(rotatedTimeFormat), i)
+	}
+	if err := os.Rename(path, rotated); err != nil {
+		// Another envswitch process rotated it first
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+
+	if maxFiles <= 0 {
+		return nil
+	}
+	files := RotatedFiles(path)
+	for len(files) > maxFiles {
+		_ = os.Remove(files[0])
+		files = files[1:]
+	}
+	return nil
+}
+
+// RotatedFiles returns the rotated files of the log file at path, oldest
+// first
+func RotatedFiles(path string) []string {
+	matches, _ := filepath.Glob(path + ".*")
+	var files []string
+	for _, match := range matches {
+		suffix := strings.TrimPrefix(match, path+".")
+		if len(suffix) < len(rotatedTimeFormat) {
+			continue
+		}
+		if _, err := time.Parse(rotatedTimeFormat, suffix[:len(rotatedTimeFormat)]); err == nil {
+			files = append(files, match)
+		}
+	}
+	sort.Strings(files)
+	return files
+}
@@ -0,0 +1,141 @@
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hugofrely/envswitch/internal/config"
+)
+
+func TestRotationDue(t *testing.T) {
+	now := time.Date(2024, 1, 15, 9, 0, 0, 0, time.Local)
+	yesterday := now.Add(-24 * time.Hour)
+
+	bySize := rotation{maxSize: 100}
+	assert.False(t, bySize.due(99, yesterday, now))
+	assert.True(t, bySize.due(100, now, now))
+
+	daily := rotation{daily: true}
+	assert.True(t, daily.due(10, yesterday, now))
+	assert.False(t, daily.due(10, now.Add(-time.Hour), now))
+	assert.False(t, daily.due(0, yesterday, now), "an empty file is not rotated")
+}
+
+func TestRotateFileKeepsMaxFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "envswitch.log")
+	start := time.Date(2024, 1, 15, 9, 0, 0, 0, time.Local)
+
+	for i := 0; i < 4; i++ {
+		require.NoError(t, os.WriteFile(path, []byte("line\n"), 0644))
+		require.NoError(t, rotateFile(path, start.Add(time.Duration(i)*time.Hour), 2))
+	}
+
+	rotated := RotatedFiles(path)
+	require.Len(t, rotated, 2)
+	assert.Equal(t, path+".20240115-110000", rotated[0])
+	assert.Equal(t, path+".20240115-120000", rotated[1])
+	assert.NoFileExists(t, path)
+}
+
+func TestInitLoggerRotatesLargeFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "envswitch.log")
+	require.NoError(t, os.WriteFile(path, []byte(strings.Repeat("x", 2*1024*1024)), 0644))
+
+	cfg := config.DefaultConfig()
+	cfg.LogFile = path
+	cfg.LogMaxSizeMB = 1
+	require.NoError(t, InitLogger(cfg))
+	defer Close()
+
+	assert.Len(t, RotatedFiles(path), 1)
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Zero(t, info.Size())
+}
+
+func TestLoggerRotatesOnWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "envswitch.log")
+
+	cfg := config.DefaultConfig()
+	cfg.LogFile = path
+	cfg.LogLevel = "warn"
+	require.NoError(t, InitLogger(cfg))
+	defer Close()
+	// A tiny limit, the second message goes to a new file
+	globalLogger.rotation.maxSize = 10
+
+	SetConsoleMode(true, true)
+	defer SetConsoleMode(false, false)
+	Error("first message")
+	Error("second message")
+
+	rotated := RotatedFiles(path)
+	require.Len(t, rotated, 1)
+	data, err := os.ReadFile(rotated[0])
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "first message")
+	data, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "second message")
+	assert.NotContains(t, string(data), "first message")
+}
+
+func TestJSONLogFormat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "envswitch.log")
+
+	cfg := config.DefaultConfig()
+	cfg.LogFile = path
+	cfg.LogLevel = "warn"
+	cfg.LogFormat = FormatJSON
+	require.NoError(t, InitLogger(cfg))
+	defer Close()
+
+	SetConsoleMode(true, true)
+	defer SetConsoleMode(false, false)
+	Named("tools").Named("restore").Warn("aws restore took %ds", 3)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	line := strings.TrimSpace(string(data))
+	assert.True(t, strings.HasPrefix(line, `{"time":"`), line)
+
+	entry, ok := ParseEntry(line)
+	require.True(t, ok)
+	assert.Equal(t, LevelWarn, entry.Level)
+	assert.Equal(t, "tools.restore", entry.Subsystem)
+	assert.Equal(t, "aws restore took 3s", entry.Message)
+	assert.WithinDuration(t, time.Now(), entry.Time, time.Minute)
+}
+
+func TestStartRunLog(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.DefaultConfig()
+	cfg.LogFile = filepath.Join(dir, "envswitch.log")
+	cfg.LogLevel = "warn"
+	require.NoError(t, InitLogger(cfg))
+	defer Close()
+
+	runPath := filepath.Join(dir, "logs", "switch-20240115-090000.log")
+	stop, err := StartRunLog(runPath)
+	require.NoError(t, err)
+	Named("switch").Debug("restoring aws")
+	stop()
+	Debug("after the run")
+
+	// The run log has every message, the log file only the enabled ones
+	data, err := os.ReadFile(runPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "[DEBUG] [switch] restoring aws")
+	assert.NotContains(t, string(data), "after the run")
+
+	data, err = os.ReadFile(cfg.LogFile)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "restoring aws")
+}
@@ -0,0 +1,120 @@
+// Package output writes the results of commands in the format picked with
+// the --output flag: text for people, JSON or YAML for scripts.
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+)
+
+// Formats are the valid values of --output
+var Formats = []string{FormatText, FormatJSON, FormatYAML}
+
+// ParseFormat returns the output format named by s, text when s is empty
+func ParseFormat(s string) (string, error) {
+	format := strings.ToLower(strings.TrimSpace(s))
+	if format == "" {
+		return FormatText, nil
+	}
+	for _, valid := range Formats {
+		if format == valid {
+			return format, nil
+		}
+	}
+	return "", fmt.Errorf("invalid output format '%s' (valid: %s)", s, strings.Join(Formats, ", "))
+}
+
+// Writer writes command results in one format
+type Writer struct {
+	format string
+	out    io.Writer
+}
+
+// NewWriter returns a writer of results in format to out
+func NewWriter(format string, out io.Writer) *Writer {
+	return &Writer{format: format, out: out}
+}
+
+// Format returns the format of the writer
+func (w *Writer) Format() string {
+	return w.format
+}
+
+// Structured reports whether results are written as JSON or YAML. Commands
+// print their own text output when it is not.
+func (w *Writer) Structured() bool {
+	return w.format == FormatJSON || w.format == FormatYAML
+}
+
+// Write encodes result in the format of the writer
+func (w *Writer) Write(result interface{}) error {
+	switch w.format {
+	case FormatJSON:
+		data, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to format output: %w", err)
+		}
+		_, err = fmt.Fprintln(w.out, string(data))
+		return err
+	case FormatYAML:
+		data, err := yaml.Marshal(result)
+		if err != nil {
+			return fmt.Errorf("failed to format output: %w", err)
+		}
+		_, err = w.out.Write(data)
+		return err
+	default:
+		return fmt.Errorf("%s output is printed by the command", w.format)
+	}
+}
+
+// WriteEvent writes event to w as JSON on a single line, for progress
+// reported while a command runs
+func WriteEvent(w io.Writer, event interface{}) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to format event: %w", err)
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+// yamlTagged encodes its value to JSON with the keys of its yaml tags
+type yamlTagged struct {
+	value interface{}
+}
+
+// YAMLTagged wraps a value whose fields only have yaml tags so that its JSON
+// uses the same keys as its YAML
+func YAMLTagged(value interface{}) interface{} {
+	return yamlTagged{value: value}
+}
+
+// MarshalJSON encodes the value through its YAML form
+func (y yamlTagged) MarshalJSON() ([]byte, error) {
+	data, err := yaml.Marshal(y.value)
+	if err != nil {
+		return nil, err
+	}
+	var generic interface{}
+	if err := yaml.Unmarshal(data, &generic); err != nil {
+		return nil, err
+	}
+	return json.Marshal(generic)
+}
+
+// MarshalYAML encodes the value itself
+func (y yamlTagged) MarshalYAML() (interface{}, error) {
+	return y.value, nil
+}
@@ -0,0 +1,61 @@
+package output
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFormat(t *testing.T) {
+	for input, expected := range map[string]string{"": FormatText, "text": FormatText, "JSON": FormatJSON, " yaml ": FormatYAML} {
+		format, err := ParseFormat(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, expected, format, input)
+	}
+
+	_, err := ParseFormat("xml")
+	assert.ErrorContains(t, err, "valid: text, json, yaml")
+}
+
+func TestWriter(t *testing.T) {
+	type result struct {
+		Name   string `json:"name" yaml:"name"`
+		Active bool   `json:"active" yaml:"active"`
+	}
+
+	t.Run("json", func(t *testing.T) {
+		var buf bytes.Buffer
+		w := NewWriter(FormatJSON, &buf)
+		assert.True(t, w.Structured())
+		require.NoError(t, w.Write(result{Name: "work", Active: true}))
+		assert.Equal(t, "{\n  \"name\": \"work\",\n  \"active\": true\n}\n", buf.String())
+	})
+
+	t.Run("yaml", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, NewWriter(FormatYAML, &buf).Write(result{Name: "work"}))
+		assert.Equal(t, "name: work\nactive: false\n", buf.String())
+	})
+
+	t.Run("text is printed by commands", func(t *testing.T) {
+		w := NewWriter(FormatText, &bytes.Buffer{})
+		assert.False(t, w.Structured())
+		assert.Error(t, w.Write(result{}))
+	})
+}
+
+func TestYAMLTagged(t *testing.T) {
+	type settings struct {
+		LogLevel string `yaml:"log_level"`
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, NewWriter(FormatJSON, &buf).Write(YAMLTagged(settings{LogLevel: "info"})))
+	assert.JSONEq(t, `{"log_level": "info"}`, buf.String())
+
+	buf.Reset()
+	require.NoError(t, NewWriter(FormatYAML, &buf).Write(YAMLTagged(settings{LogLevel: "info"})))
+	assert.Equal(t, "log_level: info\n", buf.String())
+}
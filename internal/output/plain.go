@@ -0,0 +1,91 @@
+package output
+
+import (
+	"io"
+	"unicode"
+	"unicode/utf8"
+)
+
+// plainMarkers are the ASCII replacements of status symbols, other emoji are
+// dropped
+var plainMarkers = map[rune]string{
+	'✓': "[ok]",
+	'✔': "[ok]",
+	'✅': "[ok]",
+	'✗': "[x]",
+	'✘': "[x]",
+	'❌': "[x]",
+	'⚠': "[!]",
+}
+
+// PlainWriter replaces the status symbols of the text written to it with
+// ASCII markers and drops other emoji, for --no-color and NO_COLOR
+type PlainWriter struct {
+	w io.Writer
+	// pending holds a rune split across writes
+	pending []byte
+	// afterSymbol is set after a symbol, the spaces aligning the text that
+	// follows it are collapsed
+	afterSymbol bool
+	// spaceDue is set after a marker, a space separates it from the text
+	spaceDue bool
+}
+
+// NewPlainWriter returns a writer removing emoji from what it writes to w
+func NewPlainWriter(w io.Writer) *PlainWriter {
+	return &PlainWriter{w: w}
+}
+
+// Write writes b to the underlying writer without emoji
+func (p *PlainWriter) Write(b []byte) (int, error) {
+	data := append(p.pending, b...)
+	p.pending = nil
+
+	out := make([]byte, 0, len(data))
+	for len(data) > 0 {
+		r, size := utf8.DecodeRune(data)
+		if r == utf8.RuneError && !utf8.FullRune(data) {
+			p.pending = append([]byte{}, data...)
+			break
+		}
+		data = data[size:]
+
+		switch {
+		case isEmoji(r):
+			if marker, ok := plainMarkers[r]; ok {
+				if p.spaceDue {
+					out = append(out, ' ')
+				}
+				out = append(out, marker...)
+				p.spaceDue = true
+			}
+			p.afterSymbol = true
+		case r == '\uFE0F' || r == '\u200D':
+			// Variation selectors and joiners of emoji
+		case r == ' ' && p.afterSymbol:
+		default:
+			if p.spaceDue && r != '\n' {
+				out = append(out, ' ')
+			}
+			p.afterSymbol = false
+			p.spaceDue = false
+			out = utf8.AppendRune(out, r)
+		}
+	}
+
+	if _, err := p.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// isEmoji reports whether r is a pictograph or a dingbat
+func isEmoji(r rune) bool {
+	switch {
+	case r >= 0x1F300 && r <= 0x1FAFF, // pictographs, emoticons, transport
+		r >= 0x2600 && r <= 0x27BF, // miscellaneous symbols and dingbats
+		r >= 0x2B00 && r <= 0x2BFF: // arrows and stars
+		return true
+	}
+	return unicode.Is(unicode.So, r) && r > 0xFFFF
+}
@@ -0,0 +1,45 @@
+package output
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlainWriter(t *testing.T) {
+	tests := map[string]string{
+		"✓ permissions\n":                     "[ok] permissions\n",
+		"✅ Switched to 'work'\n":              "[ok] Switched to 'work'\n",
+		"✗ config: 2 problem(s)\n":            "[x] config: 2 problem(s)\n",
+		"⚠️  Warning: backup failed\n":        "[!] Warning: backup failed\n",
+		"📦 Downloading terraform v1.2.0...\n": "Downloading terraform v1.2.0...\n",
+		"   🔧 Cleared current.lock\n":         "   Cleared current.lock\n",
+		"dev → prod • 2 tools ─ done\n":       "dev → prod • 2 tools ─ done\n",
+		"Done ✓\n":                            "Done [ok]\n",
+		"💾 Save current environment? (y/N): ": "Save current environment? (y/N): ",
+		"👩‍💻 ready\n":                         "ready\n",
+	}
+
+	for input, expected := range tests {
+		var buf bytes.Buffer
+		n, err := NewPlainWriter(&buf).Write([]byte(input))
+		require.NoError(t, err)
+		assert.Equal(t, len(input), n)
+		assert.Equal(t, expected, buf.String(), input)
+	}
+}
+
+func TestPlainWriterSplitRunes(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewPlainWriter(&buf)
+
+	// A symbol split across writes, and the spaces after it
+	input := []byte("✅ done\n")
+	for _, chunk := range [][]byte{input[:1], input[1:3], input[3:4], input[4:]} {
+		_, err := w.Write(chunk)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, "[ok] done\n", buf.String())
+}
@@ -0,0 +1,225 @@
+// Package picker lets the user choose an item of a list in the terminal,
+// narrowing the list down as they type.
+package picker
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/hugofrely/envswitch/internal/tui"
+)
+
+var (
+	// ErrNotInteractive is returned when there is no terminal to draw on
+	ErrNotInteractive = tui.ErrNotInteractive
+	// ErrCancelled is returned when the user leaves without choosing
+	ErrCancelled = errors.New("selection cancelled")
+)
+
+// maxRows is the number of items shown at once
+const maxRows = 10
+
+// Item is an entry of the list
+type Item struct {
+	// Label is what the query is matched against
+	Label string
+	// Detail is shown after the label
+	Detail string
+	// Marked items are flagged with a *, such as the active environment
+	Marked bool
+}
+
+// Pick shows items under title on the terminal and returns the index of the
+// one the user chose
+func Pick(title string, items []Item) (int, error) {
+	if len(items) == 0 {
+		return -1, fmt.Errorf("nothing to choose from")
+	}
+
+	term, err := tui.Open()
+	if err != nil {
+		return -1, err
+	}
+	defer term.Close()
+
+	return run(term.In, term.Out, title, items)
+}
+
+// run reads keys from in and draws the list on out until an item is chosen
+func run(in io.Reader, out io.Writer, title string, items []Item) (int, error) {
+	s := newState(items)
+	drawn := 0
+	clear := func() {
+		if drawn > 0 {
+			fmt.Fprintf(out, "\r\033[%dA\033[J", drawn)
+		}
+	}
+	defer clear()
+
+	for {
+		clear()
+		drawn = s.render(out, title)
+
+		keys, err := tui.ReadKeys(in)
+		if err == io.EOF {
+			return -1, ErrCancelled
+		}
+		if err != nil {
+			return -1, err
+		}
+
+		for _, k := range keys {
+			done, err := s.handle(k)
+			if err != nil {
+				return -1, err
+			}
+			if done {
+				return s.matches[s.cursor], nil
+			}
+		}
+	}
+}
+
+// state is the query and selection of the picker
+type state struct {
+	items   []Item
+	query   []rune
+	matches []int // indexes of the items matching the query, best first
+	cursor  int   // index in matches
+}
+
+func newState(items []Item) *state {
+	s := &state{items: items}
+	s.filter()
+	return s
+}
+
+// handle applies a key press, and reports whether an item was chosen
+func (s *state) handle(k tui.Key) (bool, error) {
+	switch k.Kind {
+	case tui.KeyCancel:
+		return false, ErrCancelled
+	case tui.KeyEnter:
+		return len(s.matches) > 0, nil
+	case tui.KeyUp:
+		if s.cursor > 0 {
+			s.cursor--
+		}
+	case tui.KeyDown:
+		if s.cursor < len(s.matches)-1 {
+			s.cursor++
+		}
+	case tui.KeyBackspace:
+		if len(s.query) > 0 {
+			s.query = s.query[:len(s.query)-1]
+			s.filter()
+		}
+	case tui.KeyClear:
+		s.query = nil
+		s.filter()
+	case tui.KeyRune:
+		s.query = append(s.query, k.Rune)
+		s.filter()
+	}
+	return false, nil
+}
+
+// filter matches the items against the query and moves the cursor to the
+// best match
+func (s *state) filter() {
+	type match struct {
+		index, score int
+	}
+	var matches []match
+	for i, item := range s.items {
+		if score, ok := fuzzyMatch(string(s.query), item.Label); ok {
+			matches = append(matches, match{index: i, score: score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].score < matches[j].score })
+
+	s.matches = s.matches[:0]
+	for _, m := range matches {
+		s.matches = append(s.matches, m.index)
+	}
+	s.cursor = 0
+}
+
+// fuzzyMatch reports whether the letters of query appear in text in order,
+// ignoring case. Lower scores are better matches: the letters are close to
+// each other and to the start of text.
+func fuzzyMatch(query, text string) (int, bool) {
+	if query == "" {
+		return 0, true
+	}
+
+	target := []rune(strings.ToLower(text))
+	score, last := 0, -1
+	for _, r := range strings.ToLower(query) {
+		found := -1
+		for i := last + 1; i < len(target); i++ {
+			if target[i] == r {
+				found = i
+				break
+			}
+		}
+		if found < 0 {
+			return 0, false
+		}
+		if last < 0 {
+			score += found
+		} else {
+			score += found - last - 1
+		}
+		last = found
+	}
+	return score, true
+}
+
+// render draws the picker and returns the number of lines it took
+func (s *state) render(out io.Writer, title string) int {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n", title)
+	fmt.Fprintf(&b, "Filter: %s\n", string(s.query))
+
+	width := 0
+	for _, index := range s.matches {
+		if w := utf8.RuneCountInString(s.items[index].Label); w > width {
+			width = w
+		}
+	}
+
+	// Scroll so that the cursor is always shown
+	start := 0
+	if s.cursor >= maxRows {
+		start = s.cursor - maxRows + 1
+	}
+	end := start + maxRows
+	if end > len(s.matches) {
+		end = len(s.matches)
+	}
+
+	for i := start; i < end; i++ {
+		item := s.items[s.matches[i]]
+		pointer, mark := "  ", "  "
+		if i == s.cursor {
+			pointer = "> "
+		}
+		if item.Marked {
+			mark = "* "
+		}
+		line := pointer + mark + item.Label
+		if item.Detail != "" {
+			line += strings.Repeat(" ", width-utf8.RuneCountInString(item.Label)) + "  " + item.Detail
+		}
+		fmt.Fprintf(&b, "%s\n", line)
+	}
+	fmt.Fprintf(&b, "  %d/%d (↑/↓ to move, enter to choose, esc to cancel)", len(s.matches), len(s.items))
+
+	fmt.Fprint(out, b.String())
+	return end - start + 2
+}
@@ -0,0 +1,74 @@
+package picker
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testItems = []Item{
+	{Label: "personal", Detail: "Side projects"},
+	{Label: "work", Detail: "Work environment", Marked: true},
+	{Label: "client-a/prod"},
+	{Label: "client-a/dev"},
+}
+
+func TestFuzzyMatch(t *testing.T) {
+	score, ok := fuzzyMatch("", "anything")
+	assert.True(t, ok)
+	assert.Zero(t, score)
+
+	_, ok = fuzzyMatch("wk", "work")
+	assert.True(t, ok)
+	_, ok = fuzzyMatch("WORK", "work")
+	assert.True(t, ok, "matching ignores case")
+	_, ok = fuzzyMatch("kw", "work")
+	assert.False(t, ok, "letters must appear in order")
+
+	prefix, _ := fuzzyMatch("pro", "prod")
+	scattered, _ := fuzzyMatch("pro", "personal-project")
+	assert.Less(t, prefix, scattered)
+}
+
+func TestRun(t *testing.T) {
+	t.Run("chooses the best match", func(t *testing.T) {
+		var out bytes.Buffer
+		index, err := run(strings.NewReader("prod\r"), &out, "Switch to:", testItems)
+		require.NoError(t, err)
+		assert.Equal(t, "client-a/prod", testItems[index].Label)
+		assert.Contains(t, out.String(), "Switch to:")
+	})
+
+	t.Run("moves the cursor", func(t *testing.T) {
+		index, err := run(strings.NewReader("\x1b[B\x1b[B\x1b[A\r"), &bytes.Buffer{}, "", testItems)
+		require.NoError(t, err)
+		assert.Equal(t, 1, index)
+	})
+
+	t.Run("ignores enter without matches", func(t *testing.T) {
+		index, err := run(strings.NewReader("zzz\r\x15w\r"), &bytes.Buffer{}, "", testItems)
+		require.NoError(t, err)
+		assert.Equal(t, "work", testItems[index].Label)
+	})
+
+	t.Run("cancels", func(t *testing.T) {
+		_, err := run(strings.NewReader("\x03"), &bytes.Buffer{}, "", testItems)
+		assert.ErrorIs(t, err, ErrCancelled)
+
+		_, err = run(strings.NewReader("wo"), &bytes.Buffer{}, "", testItems)
+		assert.ErrorIs(t, err, ErrCancelled, "end of input")
+	})
+}
+
+func TestRender(t *testing.T) {
+	var out bytes.Buffer
+	lines := newState(testItems).render(&out, "Switch to:")
+
+	assert.Equal(t, len(testItems)+2, lines)
+	assert.Contains(t, out.String(), ">   personal       Side projects\n")
+	assert.Contains(t, out.String(), "  * work           Work environment\n")
+	assert.Contains(t, out.String(), "4/4")
+}
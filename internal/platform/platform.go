@@ -0,0 +1,56 @@
+package platform
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// goos is the operating system paths are resolved for, stubbed in tests
+var goos = runtime.GOOS
+
+// IsWindows reports whether envswitch runs on Windows
+func IsWindows() bool {
+	return goos == "windows"
+}
+
+// HomeDir returns the home directory of the user. $HOME wins on every
+// platform, as Git for Windows and the tests set it, then %USERPROFILE% and
+// %HOMEDRIVE%%HOMEPATH% on Windows.
+func HomeDir() (string, error) {
+	if home := os.Getenv("HOME"); home != "" {
+		return home, nil
+	}
+
+	if IsWindows() {
+		if profile := os.Getenv("USERPROFILE"); profile != "" {
+			return profile, nil
+		}
+		if drive, path := os.Getenv("HOMEDRIVE"), os.Getenv("HOMEPATH"); drive != "" && path != "" {
+			return drive + path, nil
+		}
+		return "", errors.New("%USERPROFILE% is not defined")
+	}
+
+	return os.UserHomeDir()
+}
+
+// ConfigDir returns the directory tools keep their per-user configuration
+// in: %APPDATA% on Windows, ~/.config elsewhere
+func ConfigDir() (string, error) {
+	if IsWindows() {
+		if appData := os.Getenv("APPDATA"); appData != "" {
+			return appData, nil
+		}
+	}
+
+	home, err := HomeDir()
+	if err != nil {
+		return "", err
+	}
+	if IsWindows() {
+		return filepath.Join(home, "AppData", "Roaming"), nil
+	}
+	return filepath.Join(home, ".config"), nil
+}
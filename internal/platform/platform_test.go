@@ -0,0 +1,91 @@
+package platform
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func stubGOOS(t *testing.T, name string) {
+	t.Helper()
+	original := goos
+	goos = name
+	t.Cleanup(func() { goos = original })
+}
+
+func TestHomeDir(t *testing.T) {
+	t.Run("prefers HOME", func(t *testing.T) {
+		stubGOOS(t, "windows")
+		t.Setenv("HOME", "/home/alice")
+		t.Setenv("USERPROFILE", `C:\Users\alice`)
+
+		home, err := HomeDir()
+		require.NoError(t, err)
+		assert.Equal(t, "/home/alice", home)
+	})
+
+	t.Run("uses USERPROFILE on Windows", func(t *testing.T) {
+		stubGOOS(t, "windows")
+		t.Setenv("HOME", "")
+		t.Setenv("USERPROFILE", `C:\Users\alice`)
+
+		home, err := HomeDir()
+		require.NoError(t, err)
+		assert.Equal(t, `C:\Users\alice`, home)
+	})
+
+	t.Run("falls back to HOMEDRIVE and HOMEPATH", func(t *testing.T) {
+		stubGOOS(t, "windows")
+		t.Setenv("HOME", "")
+		t.Setenv("USERPROFILE", "")
+		t.Setenv("HOMEDRIVE", "D:")
+		t.Setenv("HOMEPATH", `\Users\alice`)
+
+		home, err := HomeDir()
+		require.NoError(t, err)
+		assert.Equal(t, `D:\Users\alice`, home)
+	})
+
+	t.Run("fails without a home on Windows", func(t *testing.T) {
+		stubGOOS(t, "windows")
+		for _, key := range []string{"HOME", "USERPROFILE", "HOMEDRIVE", "HOMEPATH"} {
+			t.Setenv(key, "")
+		}
+
+		_, err := HomeDir()
+		assert.Error(t, err)
+	})
+}
+
+func TestConfigDir(t *testing.T) {
+	t.Run("uses APPDATA on Windows", func(t *testing.T) {
+		stubGOOS(t, "windows")
+		t.Setenv("APPDATA", `C:\Users\alice\AppData\Roaming`)
+
+		dir, err := ConfigDir()
+		require.NoError(t, err)
+		assert.Equal(t, `C:\Users\alice\AppData\Roaming`, dir)
+	})
+
+	t.Run("defaults to the roaming AppData of the home", func(t *testing.T) {
+		stubGOOS(t, "windows")
+		t.Setenv("APPDATA", "")
+		t.Setenv("HOME", "home")
+
+		dir, err := ConfigDir()
+		require.NoError(t, err)
+		assert.Equal(t, filepath.Join("home", "AppData", "Roaming"), dir)
+	})
+
+	t.Run("uses ~/.config elsewhere", func(t *testing.T) {
+		stubGOOS(t, "linux")
+		t.Setenv("APPDATA", "ignored")
+		t.Setenv("HOME", "/home/alice")
+
+		dir, err := ConfigDir()
+		require.NoError(t, err)
+		assert.Equal(t, filepath.Join("/home/alice", ".config"), dir)
+	})
+}
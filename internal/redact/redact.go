@@ -0,0 +1,98 @@
+// Package redact masks secrets, such as cloud credentials, tokens and
+// private keys, in text written to logs, the history or diff output.
+package redact
+
+import (
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Mask replaces the secrets
+const Mask = "[REDACTED]"
+
+// A pattern replaces the secrets it matches with its replacement, where
+// ${1}... refer to the parts kept
+type pattern struct {
+	re          *regexp.Regexp
+	replacement string
+}
+
+// secretKeyNames matches the keys assigned secrets in configs
+const secretKeyNames = `[a-z0-9_.-]*(?:secret|token|password|passwd|api[_-]?key|secret[_-]?access[_-]?key|private[_-]?key|key[_-]data)`
+
+var patterns = []pattern{
+	// PEM private keys, whole or cut off as in a single diff line
+	{
+		regexp.MustCompile(`(?s)(-----BEGIN [A-Z0-9 ]*PRIVATE KEY-----).*?(-----END [A-Z0-9 ]*PRIVATE KEY-----|\z)`),
+		"${1}" + Mask + "${2}",
+	},
+	// AWS access key IDs, the prefix tells the kind of key
+	{
+		regexp.MustCompile(`\b(AKIA|ASIA|ABIA|ACCA|AGPA|AIDA|AIPA|ANPA|ANVA|AROA|APKA)[A-Z0-9]{16}\b`),
+		"${1}" + Mask,
+	},
+	// GitHub tokens
+	{
+		regexp.MustCompile(`\b(gh[pousr]_)[A-Za-z0-9]{36,}\b`),
+		"${1}" + Mask,
+	},
+	// Authorization headers, and bearer tokens long enough not to be a word
+	{
+		regexp.MustCompile(`(?i)\b((?:proxy-)?authorization\s*[:=]\s*["']?(?:(?:bearer|basic|token)\s+)?)[^\s"']+`),
+		"${1}" + Mask,
+	},
+	{
+		regexp.MustCompile(`(?i)\b(bearer)\s+[A-Za-z0-9\-._~+/]{20,}=*`),
+		"${1} " + Mask,
+	},
+	// Passwords in URLs
+	{
+		regexp.MustCompile(`\b([a-zA-Z][a-zA-Z0-9+.-]*://[^/\s:@]+):[^/\s@]+@`),
+		"${1}:" + Mask + "@",
+	},
+	// Assignments to secret keys, as in INI, JSON, YAML or the environment
+	{
+		regexp.MustCompile(`(?i)\b(` + secretKeyNames + `)(\s*=\s*["']?|["']?\s*:\s*["'])[^\s"',;}]+`),
+		"${1}${2}" + Mask,
+	},
+	// Unquoted YAML values, long enough not to be the words of a sentence
+	// such as "token: 5 tools restored"
+	{
+		regexp.MustCompile(`(?i)\b(` + secretKeyNames + `)(:\s*)[^\s"',;}]{6,}`),
+		"${1}${2}" + Mask,
+	},
+}
+
+// secretKey matches the names of keys holding secrets
+var secretKey = regexp.MustCompile(`(?i)(secret|token|password|passwd|api[_-]?key|secret[_-]?access[_-]?key|private[_-]?key|key[_-]data|credentials?)$`)
+
+// String returns s with the secrets it contains masked
+func String(s string) string {
+	for _, p := range patterns {
+		s = p.re.ReplaceAllString(s, p.replacement)
+	}
+	return s
+}
+
+// IsSecretKey reports whether the last element of a key path, such as
+// "default/aws_secret_access_key" or "users.admin.token", names a secret
+func IsSecretKey(key string) bool {
+	name := path.Base(strings.ReplaceAll(key, "\\", "/"))
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	return secretKey.MatchString(name)
+}
+
+// Value returns the value of key masked when the key names a secret, and
+// with the secrets it contains masked otherwise
+func Value(key, value string) string {
+	if value == "" {
+		return value
+	}
+	if IsSecretKey(key) {
+		return Mask
+	}
+	return String(value)
+}
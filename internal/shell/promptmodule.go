@@ -0,0 +1,137 @@
+package shell
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hugofrely/envswitch/internal/config"
+)
+
+// Prompt frameworks envswitch generates a module for
+const (
+	PromptStarship = "starship"
+	PromptOhMyPosh = "oh-my-posh"
+)
+
+// PromptModules lists the prompt frameworks of GeneratePromptModule
+var PromptModules = []string{PromptStarship, PromptOhMyPosh}
+
+// GeneratePromptModule generates the config snippet showing the active
+// environment in a prompt framework, in the format of prompt_format and the
+// color of prompt_color
+func GeneratePromptModule(framework string, cfg *config.Config) (string, error) {
+	switch framework {
+	case PromptStarship:
+		return generateStarshipModule(cfg), nil
+	case PromptOhMyPosh:
+		return generateOhMyPoshSegment(cfg)
+	default:
+		return "", fmt.Errorf("unsupported prompt framework: %s (expected %s)", framework, strings.Join(PromptModules, " or "))
+	}
+}
+
+// FormatPrompt returns the text of the prompt for the environment name, in
+// the format of prompt_format
+func FormatPrompt(format, name string) string {
+	prefix, suffix := splitPromptFormat(format)
+	return prefix + name + suffix
+}
+
+// splitPromptFormat returns what prompt_format shows before and after the
+// environment name
+func splitPromptFormat(format string) (string, string) {
+	prefix, suffix, _ := strings.Cut(parsePromptFormat(format), "%s")
+	return prefix, suffix
+}
+
+// generateStarshipModule generates a custom module for starship.toml. The
+// conditional group hides it when no environment is active.
+func generateStarshipModule(cfg *config.Config) string {
+	prefix, suffix := splitPromptFormat(cfg.PromptFormat)
+	format := "(" + escapeStarship(prefix) + "$output" + escapeStarship(suffix) + ")"
+	if style := starshipStyle(cfg.PromptColor); style != "" {
+		format = "([" + escapeStarship(prefix) + "$output" + escapeStarship(suffix) + "](" + style + "))"
+	}
+
+	var module strings.Builder
+	module.WriteString("# envswitch module for starship, add it to ~/.config/starship.toml and\n")
+	module.WriteString("# $custom (or ${custom.envswitch}) to your format when it is set.\n")
+	module.WriteString("# envswitch prompt prints the active environment, with a * when its live\n")
+	module.WriteString("# config drifted from the snapshot.\n")
+	module.WriteString("[custom.envswitch]\n")
+	module.WriteString("description = \"The active envswitch environment\"\n")
+	module.WriteString("command = \"envswitch prompt\"\n")
+	module.WriteString("when = true\n")
+	module.WriteString("format = " + tomlQuote(format) + "\n")
+	return module.String()
+}
+
+// escapeStarship escapes the characters starship format strings give a
+// meaning to
+func escapeStarship(text string) string {
+	var escaped strings.Builder
+	for _, r := range text {
+		if strings.ContainsRune(`\[]()$`, r) {
+			escaped.WriteRune('\\')
+		}
+		escaped.WriteRune(r)
+	}
+	return escaped.String()
+}
+
+// starshipStyle converts color names to starship styles
+func starshipStyle(color string) string {
+	switch color {
+	case "", "default":
+		return ""
+	case "magenta":
+		return "purple"
+	default:
+		return color
+	}
+}
+
+// tomlQuote quotes a value as a TOML basic string
+func tomlQuote(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	return `"` + value + `"`
+}
+
+// ohMyPoshSegment is a command segment of an oh-my-posh theme
+type ohMyPoshSegment struct {
+	Type       string            `json:"type"`
+	Style      string            `json:"style"`
+	Foreground string            `json:"foreground,omitempty"`
+	Template   string            `json:"template"`
+	Properties map[string]string `json:"properties"`
+}
+
+// generateOhMyPoshSegment generates a command segment for an oh-my-posh
+// theme. oh-my-posh hides command segments whose output is empty, when no
+// environment is active.
+func generateOhMyPoshSegment(cfg *config.Config) (string, error) {
+	prefix, suffix := splitPromptFormat(cfg.PromptFormat)
+	segment := ohMyPoshSegment{
+		Type:       "command",
+		Style:      "plain",
+		Foreground: ohMyPoshColor(cfg.PromptColor),
+		Template:   prefix + "{{ .Output }}" + suffix,
+		Properties: map[string]string{"command": "envswitch prompt"},
+	}
+
+	data, err := json.MarshalIndent(segment, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data) + "\n", nil
+}
+
+// ohMyPoshColor converts color names to oh-my-posh colors
+func ohMyPoshColor(color string) string {
+	if color == "default" {
+		return ""
+	}
+	return color
+}
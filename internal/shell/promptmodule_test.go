@@ -0,0 +1,50 @@
+package shell
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hugofrely/envswitch/internal/config"
+)
+
+func TestGeneratePromptModule(t *testing.T) {
+	t.Run("starship module", func(t *testing.T) {
+		module, err := GeneratePromptModule("starship", &config.Config{PromptFormat: "[{name}] ", PromptColor: "magenta"})
+		require.NoError(t, err)
+		assert.Contains(t, module, "[custom.envswitch]")
+		assert.Contains(t, module, `command = "envswitch prompt"`)
+		assert.Contains(t, module, `format = "([\\[$output\\] ](purple))"`)
+	})
+
+	t.Run("starship module without color", func(t *testing.T) {
+		module, err := GeneratePromptModule("starship", &config.Config{PromptFormat: "({env}) ", PromptColor: "default"})
+		require.NoError(t, err)
+		assert.Contains(t, module, `format = "(\\($output\\) )"`)
+	})
+
+	t.Run("oh-my-posh segment", func(t *testing.T) {
+		module, err := GeneratePromptModule("oh-my-posh", &config.Config{PromptFormat: "env:{name} ", PromptColor: "cyan"})
+		require.NoError(t, err)
+
+		var segment map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(module), &segment))
+		assert.Equal(t, "command", segment["type"])
+		assert.Equal(t, "cyan", segment["foreground"])
+		assert.Equal(t, "env:{{ .Output }} ", segment["template"])
+		assert.Equal(t, map[string]interface{}{"command": "envswitch prompt"}, segment["properties"])
+	})
+
+	t.Run("unsupported framework", func(t *testing.T) {
+		_, err := GeneratePromptModule("powerline", &config.Config{})
+		assert.ErrorContains(t, err, "unsupported prompt framework")
+	})
+}
+
+func TestFormatPrompt(t *testing.T) {
+	assert.Equal(t, "(work*) ", FormatPrompt("", "work*"))
+	assert.Equal(t, "[work]", FormatPrompt("[{env}]", "work"))
+	assert.Equal(t, "env: work", FormatPrompt("env: {name}", "work"))
+}
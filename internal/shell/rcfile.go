@@ -0,0 +1,204 @@
+package shell
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hugofrely/envswitch/internal/storage"
+)
+
+// integrationMarker is the comment written by 'envswitch shell install' on
+// the line before the one loading the integration
+const integrationMarker = "# envswitch shell integration"
+
+// initCommand is what every line loading the integration runs
+const initCommand = "envswitch shell init "
+
+// integrationLine returns the line of the rc file loading the integration
+func integrationLine(shellType string) string {
+	switch shellType {
+	case shellFish:
+		return "envswitch shell init fish | source"
+	case shellPowerShell:
+		return "envswitch shell init powershell | Out-String | Invoke-Expression"
+	default:
+		return "eval \"$(envswitch shell init " + shellType + ")\""
+	}
+}
+
+// Integration describes the integration block of a shell rc file
+type Integration struct {
+	Shell      string
+	ConfigFile string
+	Exists     bool     // the rc file exists
+	Installed  bool     // a marker was found
+	Intact     bool     // exactly one marker, followed by the expected line
+	Lines      []int    // 1-based lines of the markers
+	Problems   []string // what is wrong with the block, empty when intact
+}
+
+// CheckIntegration inspects the rc file of shellType for the integration
+// block written by 'envswitch shell install'
+func CheckIntegration(shellType string) (*Integration, error) {
+	configFile, err := getShellConfigFile(shellType)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &Integration{Shell: shellType, ConfigFile: configFile}
+	data, err := os.ReadFile(configFile)
+	if os.IsNotExist(err) {
+		return status, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", configFile, err)
+	}
+	status.Exists = true
+
+	expected := integrationLine(shellType)
+	lines := splitLines(string(data))
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == integrationMarker:
+			status.Lines = append(status.Lines, i+1)
+			next := ""
+			if i+1 < len(lines) {
+				next = strings.TrimSpace(lines[i+1])
+			}
+			if next != expected {
+				status.Problems = append(status.Problems, fmt.Sprintf("line %d: the marker is not followed by '%s'", i+1, expected))
+			}
+		case strings.Contains(trimmed, initCommand) && !strings.HasPrefix(trimmed, "#") && (i == 0 || strings.TrimSpace(lines[i-1]) != integrationMarker):
+			status.Problems = append(status.Problems, fmt.Sprintf("line %d: '%s' without the marker, 'envswitch shell repair' leaves it alone", i+1, trimmed))
+		}
+	}
+
+	status.Installed = len(status.Lines) > 0
+	if len(status.Lines) > 1 {
+		status.Problems = append(status.Problems, fmt.Sprintf("installed %d times, the integration would load more than once", len(status.Lines)))
+	}
+	status.Intact = len(status.Lines) == 1 && len(status.Problems) == 0
+	return status, nil
+}
+
+// UninstallIntegration removes the integration blocks from the rc file of
+// shellType: each marker, the line loading the integration after it and the
+// blank line install added before it. It returns the rc file and whether
+// anything was removed.
+func UninstallIntegration(shellType string) (string, bool, error) {
+	configFile, err := getShellConfigFile(shellType)
+	if err != nil {
+		return "", false, err
+	}
+
+	data, err := os.ReadFile(configFile)
+	if os.IsNotExist(err) {
+		return configFile, false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read %s: %w", configFile, err)
+	}
+
+	content, removed := removeIntegrationBlocks(string(data))
+	if !removed {
+		return configFile, false, nil
+	}
+	if err := rewriteConfigFile(configFile, data, content); err != nil {
+		return "", false, err
+	}
+	return configFile, true, nil
+}
+
+// RepairIntegration replaces the integration blocks of the rc file of
+// shellType, broken or duplicated, by a single intact one at the end
+func RepairIntegration(shellType string) (string, error) {
+	configFile, err := getShellConfigFile(shellType)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := os.ReadFile(configFile)
+	if err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("failed to read %s: %w", configFile, err)
+	}
+
+	content, _ := removeIntegrationBlocks(string(data))
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	content += integrationBlock(shellType)
+	if err := rewriteConfigFile(configFile, data, content); err != nil {
+		return "", err
+	}
+	return configFile, nil
+}
+
+// integrationBlock returns the block 'envswitch shell install' appends
+func integrationBlock(shellType string) string {
+	return "\n" + integrationMarker + "\n" + integrationLine(shellType) + "\n"
+}
+
+// removeIntegrationBlocks removes the marked integration blocks of content.
+// Lines loading the integration without a marker are left alone.
+func removeIntegrationBlocks(content string) (string, bool) {
+	lines := splitLines(content)
+	kept := make([]string, 0, len(lines))
+	removed := false
+	for i := 0; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) != integrationMarker {
+			kept = append(kept, lines[i])
+			continue
+		}
+		removed = true
+		// The blank line install wrote before the marker
+		if n := len(kept); n > 0 && strings.TrimSpace(kept[n-1]) == "" {
+			kept = kept[:n-1]
+		}
+		if i+1 < len(lines) && strings.Contains(lines[i+1], initCommand) {
+			i++
+		}
+	}
+
+	result := strings.Join(kept, "\n")
+	if result != "" && strings.HasSuffix(content, "\n") {
+		result += "\n"
+	}
+	return result, removed
+}
+
+// splitLines splits content in lines, without the empty one after a final
+// newline
+func splitLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+}
+
+// rewriteConfigFile replaces the content of an rc file, through the symlink
+// if it is one as dotfile managers do. The previous content is kept in
+// <file>.envswitch.bak.
+func rewriteConfigFile(configFile string, previous []byte, content string) error {
+	target, err := filepath.EvalSymlinks(configFile)
+	if os.IsNotExist(err) {
+		target = configFile
+	} else if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", configFile, err)
+	}
+
+	perm := os.FileMode(0644)
+	if info, err := os.Stat(target); err == nil {
+		perm = info.Mode().Perm()
+		if err := os.WriteFile(target+".envswitch.bak", previous, perm); err != nil {
+			return fmt.Errorf("failed to back up %s: %w", configFile, err)
+		}
+	}
+
+	if err := storage.WriteFileAtomic(target, []byte(content), perm); err != nil {
+		return fmt.Errorf("failed to write %s: %w", configFile, err)
+	}
+	return nil
+}
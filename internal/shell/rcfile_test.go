@@ -0,0 +1,121 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hugofrely/envswitch/internal/config"
+)
+
+func TestCheckIntegration(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	zshrc := filepath.Join(home, ".zshrc")
+
+	status, err := CheckIntegration("zsh")
+	require.NoError(t, err)
+	assert.False(t, status.Exists)
+	assert.False(t, status.Installed)
+
+	require.NoError(t, os.WriteFile(zshrc, []byte("export EDITOR=vim\n"), 0644))
+	_, err = InstallShellIntegration("zsh", &config.Config{})
+	require.NoError(t, err)
+
+	status, err = CheckIntegration("zsh")
+	require.NoError(t, err)
+	assert.True(t, status.Intact)
+	assert.Equal(t, []int{3}, status.Lines)
+	assert.Empty(t, status.Problems)
+
+	// Another tool rewrote the line after the marker and added a second block
+	broken := "export EDITOR=vim\n\n# envswitch shell integration\nalias ll='ls -l'\n\n# envswitch shell integration\neval \"$(envswitch shell init zsh)\"\n"
+	require.NoError(t, os.WriteFile(zshrc, []byte(broken), 0644))
+
+	status, err = CheckIntegration("zsh")
+	require.NoError(t, err)
+	assert.True(t, status.Installed)
+	assert.False(t, status.Intact)
+	assert.Equal(t, []int{3, 6}, status.Lines)
+	assert.Len(t, status.Problems, 2)
+}
+
+func TestUninstallIntegration(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	zshrc := filepath.Join(home, ".zshrc")
+
+	original := "export EDITOR=vim\n"
+	require.NoError(t, os.WriteFile(zshrc, []byte(original), 0600))
+	_, err := InstallShellIntegration("zsh", &config.Config{})
+	require.NoError(t, err)
+
+	configFile, removed, err := UninstallIntegration("zsh")
+	require.NoError(t, err)
+	assert.True(t, removed)
+	assert.Equal(t, zshrc, configFile)
+
+	data, err := os.ReadFile(zshrc)
+	require.NoError(t, err)
+	assert.Equal(t, original, string(data))
+
+	info, err := os.Stat(zshrc)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	backup, err := os.ReadFile(zshrc + ".envswitch.bak")
+	require.NoError(t, err)
+	assert.Contains(t, string(backup), integrationMarker)
+
+	_, removed, err = UninstallIntegration("zsh")
+	require.NoError(t, err)
+	assert.False(t, removed)
+}
+
+func TestUninstallIntegrationKeepsUnmarkedLines(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	zshrc := filepath.Join(home, ".zshrc")
+
+	content := "eval \"$(envswitch shell init zsh)\"\n\n# envswitch shell integration\neval \"$(envswitch shell init zsh)\"\nexport PATH=$PATH:/opt/bin\n"
+	require.NoError(t, os.WriteFile(zshrc, []byte(content), 0644))
+
+	_, removed, err := UninstallIntegration("zsh")
+	require.NoError(t, err)
+	assert.True(t, removed)
+
+	data, err := os.ReadFile(zshrc)
+	require.NoError(t, err)
+	assert.Equal(t, "eval \"$(envswitch shell init zsh)\"\nexport PATH=$PATH:/opt/bin\n", string(data))
+}
+
+func TestRepairIntegration(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	// The rc file is a symlink into a dotfiles repository
+	dotfiles := filepath.Join(home, "dotfiles")
+	require.NoError(t, os.MkdirAll(dotfiles, 0755))
+	target := filepath.Join(dotfiles, "zshrc")
+	broken := "export EDITOR=vim\n\n# envswitch shell integration\n\n# envswitch shell integration\neval \"$(envswitch shell init zsh)\""
+	require.NoError(t, os.WriteFile(target, []byte(broken), 0644))
+	require.NoError(t, os.Symlink(target, filepath.Join(home, ".zshrc")))
+
+	_, err := RepairIntegration("zsh")
+	require.NoError(t, err)
+
+	info, err := os.Lstat(filepath.Join(home, ".zshrc"))
+	require.NoError(t, err)
+	assert.NotZero(t, info.Mode()&os.ModeSymlink, "the symlink must be kept")
+
+	data, err := os.ReadFile(target)
+	require.NoError(t, err)
+	assert.Equal(t, "export EDITOR=vim\n\n# envswitch shell integration\neval \"$(envswitch shell init zsh)\"\n", string(data))
+
+	status, err := CheckIntegration("zsh")
+	require.NoError(t, err)
+	assert.True(t, status.Intact)
+}
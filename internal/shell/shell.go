@@ -4,25 +4,65 @@ import (
 	"bufio"
 	"fmt"
 	"os"
+	"os/exec"
+	"path"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"text/template"
 
 	"github.com/hugofrely/envswitch/internal/config"
+	"github.com/hugofrely/envswitch/internal/platform"
 )
 
 const (
-	shellBash = "bash"
-	shellZsh  = "zsh"
-	shellFish = "fish"
+	shellBash       = "bash"
+	shellZsh        = "zsh"
+	shellFish       = "fish"
+	shellPowerShell = "powershell"
+	shellCmd        = "cmd"
 )
 
-// GenerateInitScript generates the shell initialization script for the specified shell
-func GenerateInitScript(shellType string, cfg *config.Config) (string, error) {
-	if !cfg.EnablePromptIntegration {
-		return "# Prompt integration is disabled in config\n", nil
+// DetectShell returns the shell envswitch runs from: the one named by $SHELL,
+// else PowerShell or cmd on Windows, bash when it is unknown
+func DetectShell() string {
+	return detectShell(runtime.GOOS, os.Getenv)
+}
+
+func detectShell(goos string, getenv func(string) string) string {
+	shellPath := getenv("SHELL")
+	if shellPath == "" && goos == "windows" {
+		// cmd defines %PROMPT%, PowerShell does not
+		if getenv("PROMPT") != "" {
+			return shellCmd
+		}
+		return shellPowerShell
 	}
 
+	// Git Bash and MSYS may give Windows paths like C:\Program Files\Git\bin\bash.exe
+	name := path.Base(strings.ReplaceAll(shellPath, `\`, "/"))
+	switch strings.TrimSuffix(strings.ToLower(name), ".exe") {
+	case shellZsh:
+		return shellZsh
+	case shellFish:
+		return shellFish
+	case "pwsh", shellPowerShell:
+		return shellPowerShell
+	case shellCmd:
+		return shellCmd
+	default:
+		return shellBash
+	}
+}
+
+// promptDisabledComment replaces the prompt part of the scripts when
+// enable_prompt_integration is off, e.g. for a starship or oh-my-posh module
+const promptDisabledComment = "# Prompt integration is disabled in config (enable_prompt_integration), the\n# prompt is left as it is\n"
+
+// GenerateInitScript generates the shell initialization script for the
+// specified shell. Without enable_prompt_integration the script leaves the
+// prompt alone but still loads variables and switches shell history.
+func GenerateInitScript(shellType string, cfg *config.Config) (string, error) {
 	switch shellType {
 	case shellBash:
 		return generateBashScript(cfg)
@@ -30,6 +70,10 @@ func GenerateInitScript(shellType string, cfg *config.Config) (string, error) {
 		return generateZshScript(cfg)
 	case shellFish:
 		return generateFishScript(cfg)
+	case shellPowerShell:
+		return generatePowerShellScript(cfg)
+	case shellCmd:
+		return "", fmt.Errorf("cmd has no prompt integration, use PowerShell or load variables with 'envswitch env export --shell cmd'")
 	default:
 		return "", fmt.Errorf("unsupported shell: %s", shellType)
 	}
@@ -55,16 +99,7 @@ func InstallShellIntegration(shellType string, cfg *config.Config) (string, erro
 	}
 	defer file.Close()
 
-	// Add simple eval line
-	var evalLine string
-	switch shellType {
-	case shellBash, shellZsh:
-		evalLine = "\n# envswitch shell integration\neval \"$(envswitch shell init " + shellType + ")\"\n"
-	case shellFish:
-		evalLine = "\n# envswitch shell integration\nenvswitch shell init fish | source\n"
-	}
-
-	if _, err := file.WriteString(evalLine); err != nil {
+	if _, err := file.WriteString(integrationBlock(shellType)); err != nil {
 		return "", fmt.Errorf("failed to write integration: %w", err)
 	}
 
@@ -73,7 +108,7 @@ func InstallShellIntegration(shellType string, cfg *config.Config) (string, erro
 
 // getShellConfigFile returns the path to the shell configuration file
 func getShellConfigFile(shellType string) (string, error) {
-	home, err := os.UserHomeDir()
+	home, err := platform.HomeDir()
 	if err != nil {
 		return "", fmt.Errorf("failed to get home directory: %w", err)
 	}
@@ -94,11 +129,34 @@ func getShellConfigFile(shellType string) (string, error) {
 			return "", fmt.Errorf("failed to create fish config directory: %w", err)
 		}
 		return filepath.Join(configDir, "config.fish"), nil
+	case shellPowerShell:
+		profile := powerShellProfile(home)
+		if err := os.MkdirAll(filepath.Dir(profile), 0755); err != nil {
+			return "", fmt.Errorf("failed to create PowerShell profile directory: %w", err)
+		}
+		return profile, nil
 	default:
 		return "", fmt.Errorf("unsupported shell: %s", shellType)
 	}
 }
 
+// powerShellProfile returns the $PROFILE of the installed PowerShell, or the
+// default location of the PowerShell 7 profile when PowerShell cannot tell
+var powerShellProfile = func(home string) string {
+	for _, executable := range []string{"pwsh", "powershell"} {
+		// #nosec G204 - Fixed executable names and arguments
+		output, err := exec.Command(executable, "-NoProfile", "-NonInteractive", "-Command", "$PROFILE").Output()
+		if profile := strings.TrimSpace(string(output)); err == nil && profile != "" {
+			return profile
+		}
+	}
+
+	if runtime.GOOS == "windows" {
+		return filepath.Join(home, "Documents", "PowerShell", "Microsoft.PowerShell_profile.ps1")
+	}
+	return filepath.Join(home, ".config", "powershell", "Microsoft.PowerShell_profile.ps1")
+}
+
 // isAlreadyInstalled checks if envswitch integration is already in the config file
 func isAlreadyInstalled(configFile string) bool {
 	file, err := os.Open(configFile)
@@ -109,7 +167,7 @@ func isAlreadyInstalled(configFile string) bool {
 
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
-		if strings.Contains(scanner.Text(), "envswitch shell integration") {
+		if strings.Contains(scanner.Text(), integrationMarker) {
 			return true
 		}
 	}
@@ -120,8 +178,10 @@ func isAlreadyInstalled(configFile string) bool {
 // generateBashScript generates the bash initialization script
 func generateBashScript(cfg *config.Config) (string, error) {
 	tmpl := `# envswitch prompt integration for bash
+{{if .Prompt}}# envswitch prompt prints the active environment, with a * when its live
+# config drifted from the snapshot
 __envswitch_prompt() {
-    local env_name=$(cat ~/.envswitch/current.lock 2>/dev/null)
+    local env_name=$(command envswitch prompt 2>/dev/null)
     if [ -n "$env_name" ]; then
         {{if .Color}}printf "\033[{{.Color}}m"{{end}}
         printf "{{.Format}}" "$env_name"
@@ -140,31 +200,60 @@ if [[ "$PROMPT_COMMAND" != *__envswitch_update_ps1* ]]; then
     fi
     export PROMPT_COMMAND="__envswitch_update_ps1${PROMPT_COMMAND:+; $PROMPT_COMMAND}"
 fi
-
-# Auto-load environment variables on switch
+{{else}}{{.Disabled}}{{end}}
+# Apply the variables of the active environment to this shell, unsetting the
+# ones exported for the previous environment
 __envswitch_load_vars() {
+    eval "$(command envswitch env export --shell bash --previous "${__ENVSWITCH_VARS-}" 2>/dev/null)"
+}
+
+# Reload variables whenever a command changes the active environment, since
+# envswitch itself cannot modify this shell
+envswitch() {
+    local previous_env=$(cat ~/.envswitch/current.lock 2>/dev/null)
+    command envswitch "$@"
+    local exit_status=$?
+    local current_env=$(cat ~/.envswitch/current.lock 2>/dev/null)
+    if [ "$current_env" != "$previous_env" ] || [ "$1" = "env" ]; then
+        __envswitch_load_vars
+    fi
+    return $exit_status
+}
+
+__envswitch_load_vars
+
+# Per-environment shell history, switched when the active environment changes
+__envswitch_sync_history() {
     local env_name=$(cat ~/.envswitch/current.lock 2>/dev/null)
-    if [ -n "$env_name" ]; then
-        local env_file="$HOME/.envswitch/environments/$env_name/snapshots/env-vars.env"
-        if [ -f "$env_file" ]; then
-            while IFS='=' read -r key value; do
-                # Skip comments and empty lines
-                [[ "$key" =~ ^#.*$ ]] && continue
-                [[ -z "$key" ]] && continue
-                # Export the variable
-                export "$key=$value"
-            done < "$env_file"
-        fi
+    [ "$env_name" = "${__ENVSWITCH_HISTORY_ENV-}" ] && return
+    __ENVSWITCH_HISTORY_ENV="$env_name"
+    if [ -z "${__ENVSWITCH_DEFAULT_HISTFILE+x}" ]; then
+        __ENVSWITCH_DEFAULT_HISTFILE="${HISTFILE:-$HOME/.bash_history}"
     fi
+    local histfile=$(command envswitch shell history-file bash 2>/dev/null)
+    histfile="${histfile:-$__ENVSWITCH_DEFAULT_HISTFILE}"
+    [ "$histfile" = "$HISTFILE" ] && return
+    history -a
+    HISTFILE="$histfile"
+    history -c
+    history -r
 }
+
+if [[ "$PROMPT_COMMAND" != *__envswitch_sync_history* ]]; then
+    export PROMPT_COMMAND="__envswitch_sync_history${PROMPT_COMMAND:+; $PROMPT_COMMAND}"
+fi
 `
 
 	data := struct {
-		Format string
-		Color  string
+		Prompt   bool
+		Disabled string
+		Format   string
+		Color    string
 	}{
-		Format: parsePromptFormat(cfg.PromptFormat),
-		Color:  parsePromptColor(cfg.PromptColor),
+		Prompt:   cfg.EnablePromptIntegration,
+		Disabled: promptDisabledComment,
+		Format:   parsePromptFormat(cfg.PromptFormat),
+		Color:    parsePromptColor(cfg.PromptColor),
 	}
 
 	t, err := template.New("bash").Parse(tmpl)
@@ -186,9 +275,59 @@ func generateZshScript(cfg *config.Config) (string, error) {
 	var script strings.Builder
 
 	script.WriteString("# envswitch prompt integration for zsh\n")
+	if cfg.EnablePromptIntegration {
+		writeZshPrompt(&script, cfg)
+	} else {
+		script.WriteString(promptDisabledComment + "\n")
+	}
+	script.WriteString("# Apply the variables of the active environment to this shell, unsetting the\n")
+	script.WriteString("# ones exported for the previous environment\n")
+	script.WriteString("__envswitch_load_vars() {\n")
+	script.WriteString("    eval \"$(command envswitch env export --shell zsh --previous \"${__ENVSWITCH_VARS-}\" 2>/dev/null)\"\n")
+	script.WriteString("}\n\n")
+	script.WriteString("# Reload variables whenever a command changes the active environment, since\n")
+	script.WriteString("# envswitch itself cannot modify this shell\n")
+	script.WriteString("envswitch() {\n")
+	script.WriteString("    local previous_env=$(cat ~/.envswitch/current.lock 2>/dev/null)\n")
+	script.WriteString("    command envswitch \"$@\"\n")
+	script.WriteString("    local exit_status=$?\n")
+	script.WriteString("    local current_env=$(cat ~/.envswitch/current.lock 2>/dev/null)\n")
+	script.WriteString("    if [[ \"$current_env\" != \"$previous_env\" || \"$1\" == \"env\" ]]; then\n")
+	script.WriteString("        __envswitch_load_vars\n")
+	script.WriteString("    fi\n")
+	script.WriteString("    return $exit_status\n")
+	script.WriteString("}\n\n")
+	script.WriteString("__envswitch_load_vars\n\n")
+	script.WriteString("# Per-environment shell history, switched when the active environment changes\n")
+	script.WriteString("__envswitch_sync_history() {\n")
+	script.WriteString("    local env_name=$(cat ~/.envswitch/current.lock 2>/dev/null)\n")
+	script.WriteString("    [[ \"$env_name\" == \"${__ENVSWITCH_HISTORY_ENV-}\" ]] && return\n")
+	script.WriteString("    __ENVSWITCH_HISTORY_ENV=\"$env_name\"\n")
+	script.WriteString("    local histfile=$(command envswitch shell history-file zsh 2>/dev/null)\n")
+	script.WriteString("    # fc -p pushes the current history and switches HISTFILE, fc -P restores it\n")
+	script.WriteString("    if (( ${__ENVSWITCH_HISTORY_PUSHED:-0} )); then\n")
+	script.WriteString("        fc -P\n")
+	script.WriteString("        __ENVSWITCH_HISTORY_PUSHED=0\n")
+	script.WriteString("    fi\n")
+	script.WriteString("    if [[ -n \"$histfile\" ]]; then\n")
+	script.WriteString("        fc -p \"$histfile\" \"${HISTSIZE:-1000}\" \"${SAVEHIST:-1000}\"\n")
+	script.WriteString("        __ENVSWITCH_HISTORY_PUSHED=1\n")
+	script.WriteString("    fi\n")
+	script.WriteString("}\n\n")
+	script.WriteString("autoload -Uz add-zsh-hook\n")
+	script.WriteString("add-zsh-hook precmd __envswitch_sync_history\n")
+
+	return script.String(), nil
+}
+
+// writeZshPrompt writes the part of the zsh script adding the active
+// environment to PROMPT
+func writeZshPrompt(script *strings.Builder, cfg *config.Config) {
 	script.WriteString("setopt PROMPT_SUBST\n\n")
+	script.WriteString("# envswitch prompt prints the active environment, with a * when its live\n")
+	script.WriteString("# config drifted from the snapshot\n")
 	script.WriteString("__envswitch_prompt() {\n")
-	script.WriteString("    local env_name=$(cat ~/.envswitch/current.lock 2>/dev/null)\n")
+	script.WriteString("    local env_name=$(command envswitch prompt 2>/dev/null)\n")
 	script.WriteString("    if [[ -n \"$env_name\" ]]; then\n")
 
 	color := parseZshColor(cfg.PromptColor)
@@ -209,31 +348,15 @@ func generateZshScript(cfg *config.Config) (string, error) {
 	script.WriteString("if [[ \"$PROMPT\" != *__envswitch_prompt* ]]; then\n")
 	script.WriteString("    export PROMPT='$(__envswitch_prompt)'\"$PROMPT\"\n")
 	script.WriteString("fi\n\n")
-	script.WriteString("# Auto-load environment variables on switch\n")
-	script.WriteString("__envswitch_load_vars() {\n")
-	script.WriteString("    local env_name=$(cat ~/.envswitch/current.lock 2>/dev/null)\n")
-	script.WriteString("    if [[ -n \"$env_name\" ]]; then\n")
-	script.WriteString("        local env_file=\"$HOME/.envswitch/environments/$env_name/snapshots/env-vars.env\"\n")
-	script.WriteString("        if [[ -f \"$env_file\" ]]; then\n")
-	script.WriteString("            while IFS='=' read -r key value; do\n")
-	script.WriteString("                # Skip comments and empty lines\n")
-	script.WriteString("                [[ \"$key\" =~ ^#.*$ ]] && continue\n")
-	script.WriteString("                [[ -z \"$key\" ]] && continue\n")
-	script.WriteString("                # Export the variable\n")
-	script.WriteString("                export \"$key=$value\"\n")
-	script.WriteString("            done < \"$env_file\"\n")
-	script.WriteString("        fi\n")
-	script.WriteString("    fi\n")
-	script.WriteString("}\n")
-
-	return script.String(), nil
 }
 
 // generateFishScript generates the fish initialization script
 func generateFishScript(cfg *config.Config) (string, error) {
 	tmpl := `# envswitch prompt integration for fish
+{{if .Prompt}}# envswitch prompt prints the active environment, with a * when its live
+# config drifted from the snapshot
 function __envswitch_prompt
-    set -l env_name (cat ~/.envswitch/current.lock 2>/dev/null)
+    set -l env_name (command envswitch prompt 2>/dev/null)
     if test -n "$env_name"
         {{if .Color}}set_color {{.Color}}{{end}}
         printf "{{.Format}}" "$env_name"
@@ -246,35 +369,60 @@ function fish_prompt
     echo -n (__envswitch_prompt)
     # Your original prompt here
 end
-
-# Auto-load environment variables on switch
+{{else}}{{.Disabled}}{{end}}
+# Apply the variables of the active environment to this shell, unsetting the
+# ones exported for the previous environment
 function __envswitch_load_vars
+    set -q __envswitch_vars; or set -g __envswitch_vars
+    command envswitch env export --shell fish --previous "$__envswitch_vars" 2>/dev/null | source
+end
+
+# Reload variables whenever a command changes the active environment, since
+# envswitch itself cannot modify this shell
+function envswitch --wraps envswitch
+    set -l previous_env (cat ~/.envswitch/current.lock 2>/dev/null)
+    command envswitch $argv
+    set -l exit_status $status
+    set -l current_env (cat ~/.envswitch/current.lock 2>/dev/null)
+    if test "$current_env" != "$previous_env"; or test "$argv[1]" = env
+        __envswitch_load_vars
+    end
+    return $exit_status
+end
+
+__envswitch_load_vars
+
+# Per-environment shell history, switched when the active environment changes
+function __envswitch_sync_history --on-event fish_prompt
     set -l env_name (cat ~/.envswitch/current.lock 2>/dev/null)
-    if test -n "$env_name"
-        set -l env_file "$HOME/.envswitch/environments/$env_name/snapshots/env-vars.env"
-        if test -f "$env_file"
-            while read -l line
-                # Skip comments and empty lines
-                if string match -qr '^#' "$line"; or test -z "$line"
-                    continue
-                end
-                # Parse KEY=VALUE
-                set -l parts (string split -m 1 '=' $line)
-                if test (count $parts) -eq 2
-                    set -gx $parts[1] $parts[2]
-                end
-            end < "$env_file"
-        end
+    if set -q __envswitch_history_env; and test "$env_name" = "$__envswitch_history_env"
+        return
+    end
+    set -g __envswitch_history_env "$env_name"
+    if not set -q __envswitch_default_history
+        set -g __envswitch_default_history fish
+        set -q fish_history; and set __envswitch_default_history $fish_history
+    end
+    set -l session (command envswitch shell history-file fish 2>/dev/null)
+    test -z "$session"; and set session $__envswitch_default_history
+    if test "$session" != "$fish_history"
+        history save
+        set -g fish_history $session
+        history merge
     end
 end
 `
 
 	data := struct {
-		Format string
-		Color  string
+		Prompt   bool
+		Disabled string
+		Format   string
+		Color    string
 	}{
-		Format: parsePromptFormat(cfg.PromptFormat),
-		Color:  parseFishColor(cfg.PromptColor),
+		Prompt:   cfg.EnablePromptIntegration,
+		Disabled: promptDisabledComment,
+		Format:   parsePromptFormat(cfg.PromptFormat),
+		Color:    parseFishColor(cfg.PromptColor),
 	}
 
 	t, err := template.New("fish").Parse(tmpl)
@@ -290,6 +438,78 @@ end
 	return buf.String(), nil
 }
 
+// generatePowerShellScript generates the PowerShell initialization script
+func generatePowerShellScript(cfg *config.Config) (string, error) {
+	tmpl := `# envswitch prompt integration for powershell
+$global:__envswitch_exe = (Get-Command envswitch -CommandType Application -ErrorAction SilentlyContinue | Select-Object -First 1).Source
+
+function global:__envswitch_current {
+    $lock = Join-Path $HOME '.envswitch/current.lock'
+    if (Test-Path $lock) { (Get-Content $lock -Raw).Trim() }
+}
+
+{{if .Prompt}}# Prefix the existing prompt with the active environment, followed by a * when
+# its live config drifted from the snapshot
+if (-not $global:__envswitch_original_prompt) {
+    $global:__envswitch_original_prompt = $function:prompt
+}
+function global:prompt {
+    $envName = $null
+    if ($global:__envswitch_exe) { $envName = & $global:__envswitch_exe prompt 2>$null }
+    if ($envName) {
+        Write-Host ({{.Format}}.Replace('%s', $envName)) -NoNewline{{if .Color}} -ForegroundColor {{.Color}}{{end}}
+    }
+    & $global:__envswitch_original_prompt
+}
+{{else}}{{.Disabled}}{{end}}
+# Apply the variables of the active environment to this session, removing the
+# ones set for the previous environment
+function global:__envswitch_load_vars {
+    if (-not $global:__envswitch_exe) { return }
+    $exports = & $global:__envswitch_exe env export --shell powershell --previous "$global:__envswitch_vars" 2>$null
+    if ($exports) { Invoke-Expression ($exports -join [Environment]::NewLine) }
+}
+
+# Reload variables whenever a command changes the active environment, since
+# envswitch itself cannot modify this session
+function global:envswitch {
+    $previousEnv = __envswitch_current
+    & $global:__envswitch_exe @args
+    $exitStatus = $LASTEXITCODE
+    if ((__envswitch_current) -ne $previousEnv -or $args[0] -eq 'env') {
+        __envswitch_load_vars
+    }
+    $global:LASTEXITCODE = $exitStatus
+}
+
+__envswitch_load_vars
+`
+
+	data := struct {
+		Prompt   bool
+		Disabled string
+		Format   string
+		Color    string
+	}{
+		Prompt:   cfg.EnablePromptIntegration,
+		Disabled: promptDisabledComment,
+		Format:   powerShellQuote(parsePromptFormat(cfg.PromptFormat)),
+		Color:    parsePowerShellColor(cfg.PromptColor),
+	}
+
+	t, err := template.New("powershell").Parse(tmpl)
+	if err != nil {
+		return "", err
+	}
+
+	var buf strings.Builder
+	if err := t.Execute(&buf, data); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
 // parsePromptFormat converts the config prompt format to shell-compatible format
 func parsePromptFormat(format string) string {
 	if format == "" {
@@ -338,3 +558,23 @@ func parseFishColor(color string) string {
 	}
 	return color
 }
+
+// parsePowerShellColor converts color names to PowerShell console colors
+func parsePowerShellColor(color string) string {
+	colors := map[string]string{
+		"black":   "Black",
+		"red":     "Red",
+		"green":   "Green",
+		"yellow":  "Yellow",
+		"blue":    "Blue",
+		"magenta": "Magenta",
+		"cyan":    "Cyan",
+		"white":   "White",
+	}
+	return colors[color]
+}
+
+// powerShellQuote quotes a value as a single-quoted PowerShell string
+func powerShellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
@@ -23,7 +23,7 @@ func TestGenerateInitScript(t *testing.T) {
 		script, err := GenerateInitScript("bash", cfg)
 		require.NoError(t, err)
 		assert.Contains(t, script, "__envswitch_prompt")
-		assert.Contains(t, script, "cat ~/.envswitch/current.lock")
+		assert.Contains(t, script, "env_name=$(command envswitch prompt 2>/dev/null)")
 		assert.Contains(t, script, "PS1")
 		assert.Contains(t, script, "32") // green color code
 	})
@@ -32,7 +32,7 @@ func TestGenerateInitScript(t *testing.T) {
 		script, err := GenerateInitScript("zsh", cfg)
 		require.NoError(t, err)
 		assert.Contains(t, script, "__envswitch_prompt")
-		assert.Contains(t, script, "cat ~/.envswitch/current.lock")
+		assert.Contains(t, script, "env_name=$(command envswitch prompt 2>/dev/null)")
 		assert.Contains(t, script, "PROMPT")
 		assert.Contains(t, script, "green")
 	})
@@ -41,13 +41,36 @@ func TestGenerateInitScript(t *testing.T) {
 		script, err := GenerateInitScript("fish", cfg)
 		require.NoError(t, err)
 		assert.Contains(t, script, "__envswitch_prompt")
-		assert.Contains(t, script, "cat ~/.envswitch/current.lock")
+		assert.Contains(t, script, "set -l env_name (command envswitch prompt 2>/dev/null)")
 		assert.Contains(t, script, "fish_prompt")
 		assert.Contains(t, script, "green")
 	})
 
+	t.Run("powershell script generation", func(t *testing.T) {
+		script, err := GenerateInitScript("powershell", &config.Config{
+			EnablePromptIntegration: true,
+			PromptFormat:            "[{env}'s] ",
+			PromptColor:             "green",
+		})
+		require.NoError(t, err)
+		assert.Contains(t, script, "function global:prompt")
+		assert.Contains(t, script, "& $global:__envswitch_exe prompt 2>$null")
+		assert.Contains(t, script, `Write-Host ('[%s''s] '.Replace('%s', $envName)) -NoNewline -ForegroundColor Green`)
+		assert.Contains(t, script, "env export --shell powershell")
+		assert.Contains(t, script, "function global:envswitch")
+	})
+
+	t.Run("scripts switch shell history", func(t *testing.T) {
+		for _, shellType := range []string{"bash", "zsh", "fish"} {
+			script, err := GenerateInitScript(shellType, cfg)
+			require.NoError(t, err)
+			assert.Contains(t, script, "__envswitch_sync_history", shellType)
+			assert.Contains(t, script, "envswitch shell history-file "+shellType, shellType)
+		}
+	})
+
 	t.Run("unsupported shell returns error", func(t *testing.T) {
-		_, err := GenerateInitScript("powershell", cfg)
+		_, err := GenerateInitScript("tcsh", cfg)
 		assert.Error(t, err)
 	})
 
@@ -59,6 +82,19 @@ func TestGenerateInitScript(t *testing.T) {
 		require.NoError(t, err)
 		assert.Contains(t, script, "disabled")
 	})
+
+	t.Run("disabled prompt integration still loads variables", func(t *testing.T) {
+		disabledCfg := &config.Config{
+			EnablePromptIntegration: false,
+		}
+		for _, shellType := range []string{"bash", "zsh", "fish", "powershell"} {
+			script, err := GenerateInitScript(shellType, disabledCfg)
+			require.NoError(t, err)
+			assert.NotContains(t, script, "__envswitch_prompt", shellType)
+			assert.NotContains(t, script, "prompt 2>", shellType)
+			assert.Contains(t, script, "env export --shell "+shellType, shellType)
+		}
+	})
 }
 
 func TestParsePromptFormat(t *testing.T) {
@@ -238,8 +274,66 @@ func TestScriptIntegration(t *testing.T) {
 				// Verify essential components
 				assert.Contains(t, script, "__envswitch_prompt")
 				assert.Contains(t, script, "current.lock")
-				assert.Contains(t, script, "env-vars.env")
+				assert.Contains(t, script, "command envswitch env export --shell "+shell)
+				assert.Contains(t, script, "\n__envswitch_load_vars\n")
 			})
 		}
 	})
 }
+
+func TestInstallPowerShellIntegration(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	// Without pwsh on PATH the default profile location is used
+	t.Setenv("PATH", "")
+
+	configFile, err := InstallShellIntegration("powershell", &config.Config{EnablePromptIntegration: true})
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(configFile)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "envswitch shell init powershell | Out-String | Invoke-Expression")
+	assert.True(t, strings.HasPrefix(configFile, home))
+	assert.Equal(t, "Microsoft.PowerShell_profile.ps1", filepath.Base(configFile))
+
+	_, err = InstallShellIntegration("powershell", &config.Config{EnablePromptIntegration: true})
+	assert.ErrorContains(t, err, "already installed")
+}
+
+func TestParsePowerShellColor(t *testing.T) {
+	assert.Equal(t, "Cyan", parsePowerShellColor("cyan"))
+	assert.Equal(t, "", parsePowerShellColor("default"))
+	assert.Equal(t, "", parsePowerShellColor(""))
+}
+
+func TestDetectShell(t *testing.T) {
+	env := func(vars map[string]string) func(string) string {
+		return func(key string) string { return vars[key] }
+	}
+
+	tests := []struct {
+		name string
+		goos string
+		vars map[string]string
+		want string
+	}{
+		{"zsh from SHELL", "linux", map[string]string{"SHELL": "/usr/bin/zsh"}, "zsh"},
+		{"bash without SHELL", "linux", nil, "bash"},
+		{"pwsh on Linux", "linux", map[string]string{"SHELL": "/usr/bin/pwsh"}, "powershell"},
+		{"Git Bash on Windows", "windows", map[string]string{"SHELL": `C:\Program Files\Git\bin\bash.exe`}, "bash"},
+		{"PowerShell on Windows", "windows", nil, "powershell"},
+		{"cmd on Windows", "windows", map[string]string{"PROMPT": "$P$G"}, "cmd"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, detectShell(tt.goos, env(tt.vars)))
+		})
+	}
+}
+
+func TestGenerateInitScriptRejectsCmd(t *testing.T) {
+	_, err := GenerateInitScript("cmd", &config.Config{EnablePromptIntegration: true})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "env export --shell cmd")
+}
@@ -9,6 +9,17 @@ import (
 
 // CopyDir recursively copies a directory from src to dst
 func CopyDir(src, dst string) error {
+	return CopyDirProgress(src, dst, nil)
+}
+
+// CopyDirProgress copies a directory like CopyDir, telling progress about
+// each file copied when it is not nil
+func CopyDirProgress(src, dst string, progress Progress) error {
+	return copyTree(src, dst, newProgressCounter(src, progress, nil))
+}
+
+// copyTree recursively copies the directory src to dst
+func copyTree(src, dst string, counter *progressCounter) error {
 	// Get source directory info
 	srcInfo, err := os.Stat(src)
 	if err != nil {
@@ -36,7 +47,7 @@ func CopyDir(src, dst string) error {
 
 		if entry.IsDir() {
 			// Recursively copy subdirectory
-			if err := CopyDir(srcPath, dstPath); err != nil {
+			if err := copyTree(srcPath, dstPath, counter); err != nil {
 				return err
 			}
 		} else {
@@ -44,6 +55,11 @@ func CopyDir(src, dst string) error {
 			if err := CopyFile(srcPath, dstPath); err != nil {
 				return err
 			}
+			if counter != nil {
+				if info, err := entry.Info(); err == nil {
+					counter.add(info.Size())
+				}
+			}
 		}
 	}
 
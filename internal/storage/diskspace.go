@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/dustin/go-humanize"
+)
+
+// spaceReserve is kept free on top of the estimated size, so an operation
+// does not leave the filesystem completely full
+const spaceReserve = 64 * 1024 * 1024
+
+// InsufficientSpaceError is returned when a filesystem does not have room
+// for an operation
+type InsufficientSpaceError struct {
+	Path      string
+	Required  uint64
+	Available uint64
+}
+
+func (e *InsufficientSpaceError) Error() string {
+	return fmt.Sprintf("not enough disk space on %s: %s needed, %s available",
+		e.Path, humanize.Bytes(e.Required), humanize.Bytes(e.Available))
+}
+
+// CheckDiskSpace returns an *InsufficientSpaceError if the filesystem holding
+// path (or its closest existing parent) has less than required bytes free,
+// plus a safety reserve. Filesystems whose free space cannot be read are
+// assumed to have enough.
+func CheckDiskSpace(path string, required uint64) error {
+	if required == 0 {
+		return nil
+	}
+
+	existing := existingParent(path)
+	available, err := availableBytes(existing)
+	if err != nil {
+		return nil
+	}
+
+	if available < required+spaceReserve {
+		return &InsufficientSpaceError{Path: existing, Required: required + spaceReserve, Available: available}
+	}
+	return nil
+}
+
+// PathSize returns the total size of the regular files under path, which may
+// be a file or a directory. Missing paths have a size of zero.
+func PathSize(path string) uint64 {
+	var size uint64
+	_ = filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.Mode().IsRegular() {
+			size += uint64(info.Size())
+		}
+		return nil
+	})
+	return size
+}
+
+// existingParent returns path or its closest ancestor that exists
+func existingParent(path string) string {
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path
+		}
+		path = parent
+	}
+}
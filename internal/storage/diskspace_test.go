@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestPathSize(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeSyncFile(t, filepath.Join(tmpDir, "config"), "12345")
+	writeSyncFile(t, filepath.Join(tmpDir, "nested", "credentials"), "123")
+
+	if got := PathSize(tmpDir); got != 8 {
+		t.Errorf("PathSize(dir) = %d, want 8", got)
+	}
+	if got := PathSize(filepath.Join(tmpDir, "config")); got != 5 {
+		t.Errorf("PathSize(file) = %d, want 5", got)
+	}
+	if got := PathSize(filepath.Join(tmpDir, "missing")); got != 0 {
+		t.Errorf("PathSize(missing) = %d, want 0", got)
+	}
+}
+
+func TestCheckDiskSpace(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	if err := CheckDiskSpace(tmpDir, 0); err != nil {
+		t.Errorf("CheckDiskSpace(0) = %v, want nil", err)
+	}
+	if err := CheckDiskSpace(tmpDir, 1024); err != nil {
+		t.Errorf("CheckDiskSpace(1 KiB) = %v, want nil", err)
+	}
+
+	// No filesystem has an exabyte to spare; paths that do not exist yet are
+	// checked on their closest existing parent
+	missing := filepath.Join(tmpDir, "not", "created", "yet")
+	err := CheckDiskSpace(missing, 1<<60)
+
+	var spaceErr *InsufficientSpaceError
+	if !errors.As(err, &spaceErr) {
+		t.Fatalf("CheckDiskSpace(1 EiB) = %v, want *InsufficientSpaceError", err)
+	}
+	if spaceErr.Path != tmpDir {
+		t.Errorf("Path = %s, want %s", spaceErr.Path, tmpDir)
+	}
+	if spaceErr.Required <= 1<<60 {
+		t.Errorf("Required = %d, want the estimate plus a reserve", spaceErr.Required)
+	}
+}
@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// ValidatePattern checks the syntax of an exclude pattern
+func ValidatePattern(pattern string) error {
+	if strings.TrimSpace(pattern) == "" {
+		return fmt.Errorf("empty exclude pattern")
+	}
+	for _, segment := range strings.Split(pattern, "/") {
+		if _, err := path.Match(segment, ""); err != nil {
+			return fmt.Errorf("invalid exclude pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// IsExcluded reports whether the slash-separated relative path rel matches
+// one of the exclude patterns. Patterns are globs matched segment by
+// segment, where ** matches any number of directories: logs/**, **/*.log.
+// A pattern without a slash matches the name of a file or directory at any
+// depth, as in .gitignore.
+func IsExcluded(rel string, patterns []string) bool {
+	if rel == "" || rel == "." || len(patterns) == 0 {
+		return false
+	}
+
+	segments := strings.Split(rel, "/")
+	for _, pattern := range patterns {
+		pattern = strings.Trim(pattern, "/")
+		if pattern == "" {
+			continue
+		}
+		if !strings.Contains(pattern, "/") {
+			if ok, _ := path.Match(pattern, segments[len(segments)-1]); ok {
+				return true
+			}
+			continue
+		}
+		if matchSegments(strings.Split(pattern, "/"), segments) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchSegments matches path segments against pattern segments
+func matchSegments(pattern, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchSegments(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(segments) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], segments[1:])
+}
@@ -0,0 +1,42 @@
+package storage
+
+import "testing"
+
+func TestIsExcluded(t *testing.T) {
+	tests := []struct {
+		rel      string
+		patterns []string
+		want     bool
+	}{
+		{"logs", []string{"logs/**"}, true},
+		{"logs/2024.01.01/gcloud.log", []string{"logs/**"}, true},
+		{"configurations/logs", []string{"logs/**"}, false},
+		{"app.log", []string{"**/*.log"}, true},
+		{"a/b/app.log", []string{"**/*.log"}, true},
+		{"a/b/app.txt", []string{"**/*.log"}, false},
+		{"a/cache", []string{"cache"}, true},
+		{"a/cache/file", []string{"*.json", "cache"}, false},
+		{"credentials", []string{"*.log"}, false},
+		{".", []string{"*"}, false},
+		{"config", nil, false},
+	}
+
+	for _, tt := range tests {
+		if got := IsExcluded(tt.rel, tt.patterns); got != tt.want {
+			t.Errorf("IsExcluded(%q, %q) = %v, want %v", tt.rel, tt.patterns, got, tt.want)
+		}
+	}
+}
+
+func TestValidatePattern(t *testing.T) {
+	for _, pattern := range []string{"**/*.log", "logs/**", "cache", "[ab]*"} {
+		if err := ValidatePattern(pattern); err != nil {
+			t.Errorf("ValidatePattern(%q) = %v, want nil", pattern, err)
+		}
+	}
+	for _, pattern := range []string{"", "logs/[", " "} {
+		if err := ValidatePattern(pattern); err == nil {
+			t.Errorf("ValidatePattern(%q) = nil, want an error", pattern)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// LockFile takes an exclusive advisory lock on the file at path, created when
+// missing, waiting while another process holds it. The lock is released by
+// calling unlock, or when the process exits.
+func LockFile(path string) (unlock func(), err error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(file); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return func() {
+		_ = unlockFile(file)
+		_ = file.Close()
+	}, nil
+}
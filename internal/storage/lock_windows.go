@@ -0,0 +1,49 @@
+//go:build windows
+
+package storage
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const lockfileExclusiveLock = 0x2
+
+var (
+	procLockFileEx   = syscall.NewLazyDLL("kernel32.dll").NewProc("LockFileEx")
+	procUnlockFileEx = syscall.NewLazyDLL("kernel32.dll").NewProc("UnlockFileEx")
+)
+
+// lockFile waits for an exclusive lock on the first byte of file
+func lockFile(file *os.File) error {
+	var overlapped syscall.Overlapped
+	ret, _, callErr := procLockFileEx.Call(
+		file.Fd(),
+		lockfileExclusiveLock,
+		0,
+		1,
+		0,
+		uintptr(unsafe.Pointer(&overlapped)),
+	)
+	if ret == 0 {
+		return callErr
+	}
+	return nil
+}
+
+// unlockFile releases the lock on file
+func unlockFile(file *os.File) error {
+	var overlapped syscall.Overlapped
+	ret, _, callErr := procUnlockFileEx.Call(
+		file.Fd(),
+		0,
+		1,
+		0,
+		uintptr(unsafe.Pointer(&overlapped)),
+	)
+	if ret == 0 {
+		return callErr
+	}
+	return nil
+}
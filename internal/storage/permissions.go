@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// Permissions of the envswitch store, which holds credentials: only the
+// owner may read it
+const (
+	PrivateDirMode  os.FileMode = 0700
+	PrivateFileMode os.FileMode = 0600
+)
+
+// PermissionIssue is a file or directory other users can access
+type PermissionIssue struct {
+	Path  string
+	Mode  os.FileMode // current permission bits
+	Fixed os.FileMode // permission bits without group and other access
+}
+
+// WorldReadable reports whether any user can read the path
+func (p PermissionIssue) WorldReadable() bool {
+	return p.Mode&0004 != 0
+}
+
+// FindLoosePermissions lists the files and directories under root, root
+// included, that group members or other users can access. Symlinks are not
+// followed. Windows does not use these permission bits, nothing is reported.
+func FindLoosePermissions(root string) ([]PermissionIssue, error) {
+	if runtime.GOOS == "windows" {
+		return nil, nil
+	}
+
+	var issues []PermissionIssue
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return nil
+		}
+
+		perm := info.Mode().Perm()
+		if perm&0077 == 0 {
+			return nil
+		}
+
+		fixed := perm &^ 0077
+		if info.IsDir() {
+			fixed |= PrivateDirMode
+		} else {
+			fixed |= PrivateFileMode
+		}
+		issues = append(issues, PermissionIssue{Path: path, Mode: perm, Fixed: fixed})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to check permissions of %s: %w", root, err)
+	}
+
+	return issues, nil
+}
+
+// HardenPermissions removes group and other access from root and everything
+// under it, keeping the owner's execute bits, and returns what it changed
+func HardenPermissions(root string) ([]PermissionIssue, error) {
+	issues, err := FindLoosePermissions(root)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, issue := range issues {
+		if err := os.Chmod(issue.Path, issue.Fixed); err != nil {
+			return issues[:i], fmt.Errorf("failed to restrict permissions of %s: %w", issue.Path, err)
+		}
+	}
+
+	return issues, nil
+}
+
+// MkdirPrivate creates a directory and its parents readable only by the owner,
+// restricting it when it already exists with looser permissions
+func MkdirPrivate(path string) error {
+	if err := os.MkdirAll(path, PrivateDirMode); err != nil {
+		return err
+	}
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		return os.Chmod(path, info.Mode().Perm()&^0077|PrivateDirMode)
+	}
+	return nil
+}
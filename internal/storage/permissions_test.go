@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestHardenPermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permission bits are not used on Windows")
+	}
+
+	root := t.TempDir()
+	if err := os.Chmod(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+	sub := filepath.Join(root, "snapshots")
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	secret := filepath.Join(sub, "credentials")
+	if err := os.WriteFile(secret, []byte("token"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	script := filepath.Join(sub, "helper.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\n"), 0750); err != nil {
+		t.Fatal(err)
+	}
+	private := filepath.Join(sub, "private")
+	if err := os.WriteFile(private, []byte("ok"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	issues, err := FindLoosePermissions(root)
+	if err != nil {
+		t.Fatalf("FindLoosePermissions failed: %v", err)
+	}
+	if len(issues) != 4 {
+		t.Fatalf("Expected 4 issues, got %d: %v", len(issues), issues)
+	}
+
+	if _, err := HardenPermissions(root); err != nil {
+		t.Fatalf("HardenPermissions failed: %v", err)
+	}
+
+	want := map[string]os.FileMode{
+		root:    0700,
+		sub:     0700,
+		secret:  0600,
+		script:  0700,
+		private: 0600,
+	}
+	for path, mode := range want {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != mode {
+			t.Errorf("%s: got mode %o, want %o", path, info.Mode().Perm(), mode)
+		}
+	}
+
+	issues, err = FindLoosePermissions(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(issues) != 0 {
+		t.Errorf("Expected no issues after hardening, got %v", issues)
+	}
+}
+
+func TestMkdirPrivate(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permission bits are not used on Windows")
+	}
+
+	dir := filepath.Join(t.TempDir(), "store")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := MkdirPrivate(filepath.Join(dir, "env")); err != nil {
+		t.Fatalf("MkdirPrivate failed: %v", err)
+	}
+	if err := MkdirPrivate(dir); err != nil {
+		t.Fatalf("MkdirPrivate failed: %v", err)
+	}
+
+	for _, path := range []string{dir, filepath.Join(dir, "env")} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != 0700 {
+			t.Errorf("%s: got mode %o, want 700", path, info.Mode().Perm())
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// CopyProgress is how far a copy got
+type CopyProgress struct {
+	Files      int   // files processed so far, copied or found unchanged
+	Bytes      int64 // bytes of the files processed so far
+	TotalFiles int
+	TotalBytes int64
+}
+
+// Done reports whether every file was processed
+func (p CopyProgress) Done() bool {
+	return p.Files >= p.TotalFiles
+}
+
+// Progress is told how a copy advances: once with the totals before the
+// first file, then after each file
+type Progress interface {
+	CopyProgress(p CopyProgress)
+}
+
+// ProgressFunc adapts a function to Progress
+type ProgressFunc func(p CopyProgress)
+
+// CopyProgress calls f
+func (f ProgressFunc) CopyProgress(p CopyProgress) {
+	f(p)
+}
+
+// progressCounter counts the files processed by a copy for its Progress. A
+// nil counter counts nothing.
+type progressCounter struct {
+	progress Progress
+	state    CopyProgress
+}
+
+// newProgressCounter returns a counter of the files copied from src, leaving
+// out the paths matching the exclude patterns, or nil without progress
+func newProgressCounter(src string, progress Progress, exclude []string) *progressCounter {
+	if progress == nil {
+		return nil
+	}
+	c := &progressCounter{progress: progress}
+	c.state.TotalFiles, c.state.TotalBytes = countTree(src, "", exclude)
+	progress.CopyProgress(c.state)
+	return c
+}
+
+// add records a file of size bytes as processed
+func (c *progressCounter) add(size int64) {
+	if c == nil {
+		return
+	}
+	c.state.Files++
+	c.state.Bytes += size
+	c.progress.CopyProgress(c.state)
+}
+
+// countTree returns the number and size of the files under dir a copy
+// processes
+func countTree(dir, rel string, exclude []string) (int, int64) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, 0
+	}
+
+	var files int
+	var bytes int64
+	for _, entry := range entries {
+		entryRel := filepath.ToSlash(filepath.Join(rel, entry.Name()))
+		if entryRel == ManifestFile || IsExcluded(entryRel, exclude) {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if info.IsDir() {
+			subFiles, subBytes := countTree(path, entryRel, exclude)
+			files += subFiles
+			bytes += subBytes
+			continue
+		}
+		files++
+		bytes += info.Size()
+	}
+	return files, bytes
+}
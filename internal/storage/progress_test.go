@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestCopyDirProgress(t *testing.T) {
+	src := t.TempDir()
+	writeSyncFile(t, filepath.Join(src, "a.txt"), "hello")
+	writeSyncFile(t, filepath.Join(src, "sub", "b.txt"), "world!")
+
+	var reports []CopyProgress
+	err := CopyDirProgress(src, filepath.Join(t.TempDir(), "dst"), ProgressFunc(func(p CopyProgress) {
+		reports = append(reports, p)
+	}))
+	if err != nil {
+		t.Fatalf("CopyDirProgress failed: %v", err)
+	}
+
+	if len(reports) != 3 {
+		t.Fatalf("Expected the totals then one report per file, got %d reports", len(reports))
+	}
+	if reports[0].Files != 0 || reports[0].TotalFiles != 2 || reports[0].TotalBytes != 11 {
+		t.Errorf("Unexpected totals: %+v", reports[0])
+	}
+	last := reports[2]
+	if !last.Done() || last.Bytes != 11 {
+		t.Errorf("Expected every file processed, got %+v", last)
+	}
+}
+
+func TestSyncDirProgress(t *testing.T) {
+	src := t.TempDir()
+	dst := t.TempDir()
+	writeSyncFile(t, filepath.Join(src, "config"), "value")
+	writeSyncFile(t, filepath.Join(src, "logs", "debug.log"), "noise")
+
+	var last CopyProgress
+	progress := ProgressFunc(func(p CopyProgress) { last = p })
+
+	if _, err := SnapshotDirProgress(src, dst, progress, "logs"); err != nil {
+		t.Fatalf("SnapshotDirProgress failed: %v", err)
+	}
+	if last.TotalFiles != 1 || !last.Done() {
+		t.Errorf("Expected the excluded files left out of the totals, got %+v", last)
+	}
+
+	// Unchanged files count as processed, the manifest does not
+	live := t.TempDir()
+	if _, err := SyncDirProgress(dst, live, progress); err != nil {
+		t.Fatalf("SyncDirProgress failed: %v", err)
+	}
+	if last.TotalFiles != 1 || last.Files != 1 || last.Bytes != 5 {
+		t.Errorf("Unexpected progress of the restore: %+v", last)
+	}
+}
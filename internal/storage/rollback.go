@@ -0,0 +1,290 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// RestoreBackup keeps what a restore replaces in a temporary directory until
+// the restore succeeds, so that a restore failing halfway can put the live
+// config back as it was instead of leaving a partial copy behind.
+//
+// The temporary directory is created inside the restored directory, which
+// keeps the moves on one filesystem when the directory is a mount point, or
+// next to it when the directory itself is replaced.
+type RestoreBackup struct {
+	root    string // the restored file or directory
+	dir     string // the temporary directory, created on first use
+	entries []backupEntry
+}
+
+// backupEntry is a live path the restore writes. Paths that did not exist
+// have no backup and are removed on rollback.
+type backupEntry struct {
+	live   string
+	backup string
+	copied bool // the backup is a copy, put back in place
+}
+
+// NewRestoreBackup returns an empty backup of the restore of root
+func NewRestoreBackup(root string) *RestoreBackup {
+	return &RestoreBackup{root: root}
+}
+
+// ReplaceSafely runs restore to replace the file or directory at path. What
+// path holds is set aside first and put back if restore fails: a directory
+// is moved, a file is copied so that restore overwrites it in place, through
+// symlinks.
+func ReplaceSafely(path string, restore func() error) error {
+	backup := NewRestoreBackup(path)
+	save := backup.Save
+	if info, err := os.Stat(path); err == nil && !info.IsDir() {
+		save = backup.Overwrite
+	}
+	if err := save(path); err != nil {
+		return err
+	}
+	return backup.Finish(restore())
+}
+
+// Save moves path aside before the restore writes or removes it. A path that
+// does not exist is recorded as well, to be removed on rollback.
+func (b *RestoreBackup) Save(path string) error {
+	if _, err := os.Lstat(path); os.IsNotExist(err) {
+		b.entries = append(b.entries, backupEntry{live: path})
+		return nil
+	}
+
+	if err := b.createDir(path); err != nil {
+		return err
+	}
+	backupPath := filepath.Join(b.dir, strconv.Itoa(len(b.entries)))
+	if err := os.Rename(path, backupPath); err != nil {
+		return fmt.Errorf("failed to move %s aside: %w", path, err)
+	}
+	b.entries = append(b.entries, backupEntry{live: path, backup: backupPath})
+	return nil
+}
+
+// Overwrite copies the content of the file at path aside before the restore
+// overwrites it in place. A path that does not exist is recorded as well, to
+// be removed on rollback.
+func (b *RestoreBackup) Overwrite(path string) error {
+	if _, err := os.Lstat(path); os.IsNotExist(err) {
+		b.entries = append(b.entries, backupEntry{live: path})
+		return nil
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		// A dangling symlink, writing creates its target
+		return nil
+	}
+
+	if err := b.createDir(path); err != nil {
+		return err
+	}
+	backupPath := filepath.Join(b.dir, strconv.Itoa(len(b.entries)))
+	if err := CopyFile(path, backupPath); err != nil {
+		return fmt.Errorf("failed to copy %s aside: %w", path, err)
+	}
+	b.entries = append(b.entries, backupEntry{live: path, backup: backupPath, copied: true})
+	return nil
+}
+
+// createDir creates the temporary directory, next to the root when path is
+// the root itself
+func (b *RestoreBackup) createDir(path string) error {
+	if b.dir != "" {
+		return nil
+	}
+
+	parent := b.root
+	if filepath.Clean(path) == filepath.Clean(b.root) {
+		parent = filepath.Dir(b.root)
+	}
+	name := ".envswitch-restore-" + strings.TrimPrefix(filepath.Base(b.root), ".") + "-*"
+	dir, err := os.MkdirTemp(parent, name)
+	if err != nil {
+		return fmt.Errorf("failed to create restore backup directory: %w", err)
+	}
+	b.dir = dir
+	return nil
+}
+
+// Dir returns the temporary directory, empty until something was moved
+// aside. A restore writing into the root must leave it alone.
+func (b *RestoreBackup) Dir() string {
+	return b.dir
+}
+
+// Finish discards the backup when the restore succeeded, or hands it to the
+// HeldBackups in effect, or rolls the live
+// paths back when it failed with err. It returns err.
+func (b *RestoreBackup) Finish(err error) error {
+	if err == nil {
+		if held := currentHold(); held != nil {
+			held.add(b)
+			return nil
+		}
+		// The restore succeeded, a leftover directory only wastes room
+		_ = b.Discard()
+		return nil
+	}
+	if rollbackErr := b.Rollback(); rollbackErr != nil {
+		return fmt.Errorf("%w (and failed to put back the previous config: %v)", err, rollbackErr)
+	}
+	return err
+}
+
+// Rollback removes what the restore wrote and moves the saved paths back,
+// most recent first
+func (b *RestoreBackup) Rollback() error {
+	var errs []error
+	for i := len(b.entries) - 1; i >= 0; i-- {
+		entry := b.entries[i]
+		if entry.copied {
+			if err := putBack(entry.backup, entry.live); err != nil {
+				errs = append(errs, fmt.Errorf("failed to put %s back: %w", entry.live, err))
+			}
+			continue
+		}
+		if err := os.RemoveAll(entry.live); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if entry.backup == "" {
+			continue
+		}
+		if err := os.Rename(entry.backup, entry.live); err != nil {
+			errs = append(errs, fmt.Errorf("failed to move %s back: %w", entry.live, err))
+		}
+	}
+	b.entries = nil
+
+	if len(errs) > 0 {
+		if b.dir == "" {
+			return errors.Join(errs...)
+		}
+		// Keep what could not be moved back for the user to recover
+		return fmt.Errorf("%w, what remains of it is in %s", errors.Join(errs...), b.dir)
+	}
+	return b.Discard()
+}
+
+// putBack copies a file saved by Overwrite back over the live file, with its
+// mode
+func putBack(backupPath, livePath string) error {
+	info, err := os.Stat(backupPath)
+	if err != nil {
+		return err
+	}
+	if err := CopyFile(backupPath, livePath); err != nil {
+		return err
+	}
+	return os.Chmod(livePath, info.Mode().Perm())
+}
+
+// Discard deletes the saved paths
+func (b *RestoreBackup) Discard() error {
+	b.entries = nil
+	if b.dir == "" {
+		return nil
+	}
+	if err := os.RemoveAll(b.dir); err != nil {
+		return fmt.Errorf("failed to remove restore backup directory: %w", err)
+	}
+	b.dir = ""
+	return nil
+}
+
+// HeldBackups keeps the backups of the restores that succeed while it is in
+// effect, instead of discarding them: a switch restoring several tools can
+// then roll back those already restored when it is interrupted.
+type HeldBackups struct {
+	mu      sync.Mutex
+	backups []*RestoreBackup
+}
+
+var (
+	holdMu sync.Mutex
+	hold   *HeldBackups
+)
+
+// HoldRestoreBackups keeps the backups of the restores that succeed from now
+// on, until Release
+func HoldRestoreBackups() *HeldBackups {
+	holdMu.Lock()
+	defer holdMu.Unlock()
+	hold = &HeldBackups{}
+	return hold
+}
+
+// currentHold returns the HeldBackups in effect, nil when there is none
+func currentHold() *HeldBackups {
+	holdMu.Lock()
+	defer holdMu.Unlock()
+	return hold
+}
+
+func (h *HeldBackups) add(b *RestoreBackup) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.backups = append(h.backups, b)
+}
+
+// Rollback puts back what the held restores of paths, or of files below
+// them, replaced, most recent first
+func (h *HeldBackups) Rollback(paths ...string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var errs []error
+	var kept []*RestoreBackup
+	for i := len(h.backups) - 1; i >= 0; i-- {
+		b := h.backups[i]
+		if !isBelowAny(b.root, paths) {
+			kept = append([]*RestoreBackup{b}, kept...)
+			continue
+		}
+		if err := b.Rollback(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	h.backups = kept
+	return errors.Join(errs...)
+}
+
+// Release stops holding backups and discards those not rolled back
+func (h *HeldBackups) Release() error {
+	holdMu.Lock()
+	if hold == h {
+		hold = nil
+	}
+	holdMu.Unlock()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var errs []error
+	for _, b := range h.backups {
+		if err := b.Discard(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	h.backups = nil
+	return errors.Join(errs...)
+}
+
+// isBelowAny reports whether path is one of dirs or below one of them
+func isBelowAny(path string, dirs []string) bool {
+	for _, dir := range dirs {
+		rel, err := filepath.Rel(dir, path)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
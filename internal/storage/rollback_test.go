@@ -0,0 +1,224 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// assertNoRestoreBackups fails if a restore left its temporary directory
+// behind in dir
+func assertNoRestoreBackups(t *testing.T, dir string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Failed to read directory: %v", err)
+	}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".envswitch-restore-") {
+			t.Errorf("Restore backup %s was left behind", entry.Name())
+		}
+	}
+}
+
+func TestReplaceSafely(t *testing.T) {
+	tmpDir := t.TempDir()
+	live := filepath.Join(tmpDir, "config")
+	writeSyncFile(t, filepath.Join(live, "credentials"), "old")
+
+	err := ReplaceSafely(live, func() error {
+		writeSyncFile(t, filepath.Join(live, "credentials"), "new")
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ReplaceSafely failed: %v", err)
+	}
+
+	if got := readSyncFile(t, filepath.Join(live, "credentials")); got != "new" {
+		t.Errorf("credentials = %q, want %q", got, "new")
+	}
+	assertNoRestoreBackups(t, tmpDir)
+}
+
+func TestReplaceSafelyRollsBack(t *testing.T) {
+	tmpDir := t.TempDir()
+	live := filepath.Join(tmpDir, "config")
+	writeSyncFile(t, filepath.Join(live, "credentials"), "old")
+	writeSyncFile(t, filepath.Join(live, "profiles", "work"), "work")
+
+	failure := errors.New("copy failed")
+	err := ReplaceSafely(live, func() error {
+		// Fail halfway through the copy
+		writeSyncFile(t, filepath.Join(live, "credentials"), "new")
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("ReplaceSafely error = %v, want %v", err, failure)
+	}
+
+	if got := readSyncFile(t, filepath.Join(live, "credentials")); got != "old" {
+		t.Errorf("credentials = %q, want %q", got, "old")
+	}
+	if got := readSyncFile(t, filepath.Join(live, "profiles", "work")); got != "work" {
+		t.Errorf("profiles/work = %q, want %q", got, "work")
+	}
+	assertNoRestoreBackups(t, tmpDir)
+}
+
+func TestReplaceSafelyMissingPath(t *testing.T) {
+	tmpDir := t.TempDir()
+	live := filepath.Join(tmpDir, "config")
+
+	err := ReplaceSafely(live, func() error {
+		writeSyncFile(t, filepath.Join(live, "credentials"), "new")
+		return errors.New("copy failed")
+	})
+	if err == nil {
+		t.Fatal("ReplaceSafely should fail")
+	}
+
+	// What the failed restore wrote is removed
+	if _, err := os.Stat(live); !os.IsNotExist(err) {
+		t.Errorf("%s should not exist after the rollback", live)
+	}
+}
+
+func TestReplaceSafelyFileThroughSymlink(t *testing.T) {
+	tmpDir := t.TempDir()
+	target := filepath.Join(tmpDir, "dotfiles", "gitconfig")
+	writeSyncFile(t, target, "old")
+	live := filepath.Join(tmpDir, ".gitconfig")
+	if err := os.Symlink(target, live); err != nil {
+		t.Skipf("Symlinks not supported: %v", err)
+	}
+
+	err := ReplaceSafely(live, func() error {
+		writeSyncFile(t, live, "partial")
+		return errors.New("copy failed")
+	})
+	if err == nil {
+		t.Fatal("ReplaceSafely should fail")
+	}
+	if got := readSyncFile(t, target); got != "old" {
+		t.Errorf("target = %q, want %q", got, "old")
+	}
+
+	if err := ReplaceSafely(live, func() error {
+		writeSyncFile(t, live, "new")
+		return nil
+	}); err != nil {
+		t.Fatalf("ReplaceSafely failed: %v", err)
+	}
+
+	// The file is written in place, the symlink is kept
+	if info, err := os.Lstat(live); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("%s should still be a symlink", live)
+	}
+	if got := readSyncFile(t, target); got != "new" {
+		t.Errorf("target = %q, want %q", got, "new")
+	}
+	assertNoRestoreBackups(t, tmpDir)
+}
+
+func TestSyncDirRollsBack(t *testing.T) {
+	tmpDir := t.TempDir()
+	live := filepath.Join(tmpDir, "live")
+	snapshot := filepath.Join(tmpDir, "snapshot")
+
+	writeSyncFile(t, filepath.Join(live, "b-config"), "old")
+	writeSyncFile(t, filepath.Join(live, "c-extra"), "extra")
+	writeSyncFile(t, filepath.Join(snapshot, "a-new"), "new")
+	writeSyncFile(t, filepath.Join(snapshot, "b-config"), "restored")
+	// Files are synced in name order, the sync fails after a-new and b-config
+	if err := os.Symlink(filepath.Join(tmpDir, "missing"), filepath.Join(snapshot, "z-broken")); err != nil {
+		t.Skipf("Symlinks not supported: %v", err)
+	}
+
+	if _, err := SyncDir(snapshot, live); err == nil {
+		t.Fatal("SyncDir should fail on a broken symlink")
+	}
+
+	if got := readSyncFile(t, filepath.Join(live, "b-config")); got != "old" {
+		t.Errorf("b-config = %q, want %q", got, "old")
+	}
+	if got := readSyncFile(t, filepath.Join(live, "c-extra")); got != "extra" {
+		t.Errorf("c-extra = %q, want %q", got, "extra")
+	}
+	if _, err := os.Stat(filepath.Join(live, "a-new")); !os.IsNotExist(err) {
+		t.Error("a-new should be removed by the rollback")
+	}
+	assertNoRestoreBackups(t, live)
+	assertNoRestoreBackups(t, tmpDir)
+}
+
+func TestSyncDirLeavesNoBackup(t *testing.T) {
+	tmpDir := t.TempDir()
+	live := filepath.Join(tmpDir, "live")
+	snapshot := filepath.Join(tmpDir, "snapshot")
+
+	writeSyncFile(t, filepath.Join(live, "config"), "old")
+	writeSyncFile(t, filepath.Join(live, "stale", "file"), "stale")
+	writeSyncFile(t, filepath.Join(snapshot, "config"), "new")
+
+	stats, err := SyncDir(snapshot, live)
+	if err != nil {
+		t.Fatalf("SyncDir failed: %v", err)
+	}
+	if stats.Copied != 1 || stats.Removed != 1 {
+		t.Errorf("Stats = %+v, want 1 copied and 1 removed", stats)
+	}
+	if _, err := os.Stat(filepath.Join(live, "stale")); !os.IsNotExist(err) {
+		t.Error("stale should be removed")
+	}
+	assertNoRestoreBackups(t, live)
+}
+
+func TestHeldBackups(t *testing.T) {
+	tmpDir := t.TempDir()
+	kube := filepath.Join(tmpDir, ".kube")
+	aws := filepath.Join(tmpDir, ".aws")
+	gitconfig := filepath.Join(tmpDir, ".gitconfig")
+	writeSyncFile(t, filepath.Join(kube, "config"), "kube")
+	writeSyncFile(t, filepath.Join(aws, "credentials"), "aws")
+
+	held := HoldRestoreBackups()
+	restore := func(path, content string) {
+		t.Helper()
+		if err := ReplaceSafely(path, func() error {
+			writeSyncFile(t, path, content)
+			return nil
+		}); err != nil {
+			t.Fatalf("ReplaceSafely failed: %v", err)
+		}
+	}
+	restore(filepath.Join(kube, "config"), "restored")
+	restore(filepath.Join(kube, "config"), "restored twice")
+	restore(filepath.Join(aws, "credentials"), "restored")
+	restore(gitconfig, "restored")
+
+	if err := held.Rollback(kube, gitconfig); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+	if got := readSyncFile(t, filepath.Join(kube, "config")); got != "kube" {
+		t.Errorf("kube config = %q, want %q", got, "kube")
+	}
+	if _, err := os.Stat(gitconfig); !os.IsNotExist(err) {
+		t.Errorf("%s did not exist before the restore, it should be removed", gitconfig)
+	}
+
+	if err := held.Release(); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	if got := readSyncFile(t, filepath.Join(aws, "credentials")); got != "restored" {
+		t.Errorf("aws credentials = %q, want %q", got, "restored")
+	}
+	assertNoRestoreBackups(t, kube)
+	assertNoRestoreBackups(t, aws)
+	assertNoRestoreBackups(t, tmpDir)
+
+	// Once released, backups are discarded again
+	restore(filepath.Join(aws, "credentials"), "after")
+	assertNoRestoreBackups(t, aws)
+}
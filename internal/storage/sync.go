@@ -0,0 +1,402 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ManifestFile is the name of the content-hash manifest kept at the root of
+// snapshot directories. It is never copied to or from the live system.
+const ManifestFile = ".envswitch-manifest.json"
+
+// racyWindow is how long before the manifest was written a file must have
+// been modified for its recorded hash to be trusted. A file modified within
+// the same timestamp tick could change again without its time changing.
+const racyWindow = 2 * time.Second
+
+// ManifestEntry describes a file of a snapshot directory
+type ManifestEntry struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	Hash    string    `json:"sha256"`
+}
+
+// Manifest maps slash-separated relative paths to their entry. It also
+// records the size of what exclude patterns left out of the snapshot.
+type Manifest struct {
+	WrittenAt     time.Time                `json:"written_at"`
+	Files         map[string]ManifestEntry `json:"files"`
+	ExcludedFiles int                      `json:"excluded_files,omitempty"`
+	ExcludedBytes int64                    `json:"excluded_bytes,omitempty"`
+}
+
+// SyncStats reports what a sync did
+type SyncStats struct {
+	Copied    int
+	Unchanged int
+	Removed   int
+}
+
+// LoadManifest reads the manifest of dir. A missing or unreadable manifest
+// yields an empty one, files are then hashed instead.
+func LoadManifest(dir string) *Manifest {
+	manifest := &Manifest{Files: make(map[string]ManifestEntry)}
+
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		return manifest
+	}
+	if err := json.Unmarshal(data, manifest); err != nil || manifest.Files == nil {
+		return &Manifest{Files: make(map[string]ManifestEntry)}
+	}
+	return manifest
+}
+
+// Save writes the manifest to the root of dir
+func (m *Manifest) Save(dir string) error {
+	m.WrittenAt = time.Now()
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ManifestFile), data, 0644); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return nil
+}
+
+// SnapshotDir makes the snapshot directory dst an exact copy of src, copying
+// only files whose content changed, and records a manifest of content hashes
+// in dst so the next snapshot can skip unchanged files without reading them.
+// Paths matching the exclude patterns are left out, and removed from dst.
+func SnapshotDir(src, dst string, exclude ...string) (SyncStats, error) {
+	return SnapshotDirProgress(src, dst, nil, exclude...)
+}
+
+// SnapshotDirProgress snapshots like SnapshotDir, telling progress about
+// each file processed when it is not nil
+func SnapshotDirProgress(src, dst string, progress Progress, exclude ...string) (SyncStats, error) {
+	return syncDir(src, dst, nil, progress, exclude)
+}
+
+// SyncDir makes dst an exact copy of src, only rewriting files whose content
+// differs and removing files that are not in src. It is used to restore a
+// snapshot directory; the manifest of src, if any, avoids hashing its files.
+// Paths matching the exclude patterns are neither copied nor removed.
+//
+// The files it replaces or removes are moved aside until the sync succeeds,
+// and put back if it fails, so dst is never left half restored.
+func SyncDir(src, dst string, exclude ...string) (SyncStats, error) {
+	return SyncDirProgress(src, dst, nil, exclude...)
+}
+
+// SyncDirProgress restores like SyncDir, telling progress about each file
+// processed when it is not nil
+func SyncDirProgress(src, dst string, progress Progress, exclude ...string) (SyncStats, error) {
+	backup := NewRestoreBackup(dst)
+	stats, err := syncDir(src, dst, backup, progress, exclude)
+	return stats, backup.Finish(err)
+}
+
+// syncDir syncs dst with src. Snapshots have no backup: they write a
+// manifest and overwrite files in place.
+func syncDir(src, dst string, backup *RestoreBackup, progress Progress, exclude []string) (SyncStats, error) {
+	var stats SyncStats
+	writeManifest := backup == nil
+
+	srcInfo, err := os.Stat(src)
+	if err != nil {
+		return stats, fmt.Errorf("failed to stat source: %w", err)
+	}
+	if !srcInfo.IsDir() {
+		return stats, fmt.Errorf("source is not a directory: %s", src)
+	}
+
+	s := &syncer{
+		srcManifest:  LoadManifest(src),
+		dstManifest:  LoadManifest(dst),
+		manifest:     &Manifest{Files: make(map[string]ManifestEntry)},
+		keep:         map[string]bool{ManifestFile: writeManifest},
+		exclude:      exclude,
+		keepExcluded: !writeManifest,
+		backup:       backup,
+		stats:        &stats,
+		progress:     newProgressCounter(src, progress, exclude),
+	}
+
+	if err := s.syncTree(src, dst, ""); err != nil {
+		return stats, err
+	}
+	if err := s.prune(dst, ""); err != nil {
+		return stats, err
+	}
+
+	if writeManifest {
+		if err := s.manifest.Save(dst); err != nil {
+			return stats, err
+		}
+	}
+
+	return stats, nil
+}
+
+type syncer struct {
+	srcManifest  *Manifest
+	dstManifest  *Manifest
+	manifest     *Manifest       // manifest of dst after the sync
+	keep         map[string]bool // relative paths present in src
+	exclude      []string        // patterns of paths left out of the sync
+	keepExcluded bool            // a restore leaves the excluded live files alone
+	backup       *RestoreBackup  // what a restore replaces, nil for snapshots
+	stats        *SyncStats
+	progress     *progressCounter
+}
+
+// remove removes path, or moves it aside during a restore
+func (s *syncer) remove(path string) error {
+	if s.backup != nil {
+		return s.backup.Save(path)
+	}
+	return os.RemoveAll(path)
+}
+
+// prepare records the directory path before a restore creates it
+func (s *syncer) prepare(path string) error {
+	if s.backup != nil {
+		return s.backup.Save(path)
+	}
+	return nil
+}
+
+// overwrite copies the file at path aside before a restore overwrites it,
+// snapshots overwrite it in place
+func (s *syncer) overwrite(path string) error {
+	if s.backup != nil {
+		return s.backup.Overwrite(path)
+	}
+	return nil
+}
+
+// syncTree copies the changed files of the src directory to dst
+func (s *syncer) syncTree(src, dst, rel string) error {
+	srcInfo, err := os.Stat(src)
+	if err != nil {
+		return fmt.Errorf("failed to stat source: %w", err)
+	}
+
+	if dstInfo, statErr := os.Stat(dst); statErr == nil && !dstInfo.IsDir() {
+		if err := s.remove(dst); err != nil {
+			return fmt.Errorf("failed to replace %s: %w", dst, err)
+		}
+	} else if statErr != nil {
+		if err := s.prepare(dst); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(dst, srcInfo.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to create destination directory: %w", err)
+	}
+
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		entryRel := filepath.ToSlash(filepath.Join(rel, entry.Name()))
+		if entryRel == ManifestFile {
+			continue
+		}
+
+		srcPath := filepath.Join(src, entry.Name())
+		dstPath := filepath.Join(dst, entry.Name())
+
+		if IsExcluded(entryRel, s.exclude) {
+			if !s.keepExcluded {
+				s.recordExcluded(srcPath)
+			}
+			continue
+		}
+		s.keep[entryRel] = true
+
+		info, err := os.Stat(srcPath)
+		if err != nil {
+			return fmt.Errorf("failed to stat source file: %w", err)
+		}
+
+		if info.IsDir() {
+			if err := s.syncTree(srcPath, dstPath, entryRel); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := s.syncFile(srcPath, dstPath, entryRel, info); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// recordExcluded adds the files under path to the excluded totals of the
+// manifest
+func (s *syncer) recordExcluded(path string) {
+	_ = filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			s.manifest.ExcludedFiles++
+			s.manifest.ExcludedBytes += info.Size()
+		}
+		return nil
+	})
+}
+
+// syncFile copies src to dst unless dst already has the same content.
+// Contents are compared by hash, hashes recorded in a manifest are reused
+// while the file still has the recorded size and modification time.
+func (s *syncer) syncFile(src, dst, rel string, srcInfo os.FileInfo) error {
+	// The destination manifest also describes the source files as they were
+	// when last synced, which lets unchanged live files skip hashing
+	srcHash, err := hashWithManifests(src, rel, srcInfo, s.srcManifest, s.dstManifest)
+	if err != nil {
+		return err
+	}
+
+	dstInfo, err := os.Lstat(dst)
+	unchanged := false
+	if err == nil && dstInfo.Mode().IsRegular() && dstInfo.Size() == srcInfo.Size() {
+		dstHash, err := hashWithManifests(dst, rel, dstInfo, s.dstManifest)
+		if err != nil {
+			return err
+		}
+		unchanged = srcHash == dstHash
+	} else if err == nil && dstInfo.IsDir() {
+		if err := s.remove(dst); err != nil {
+			return fmt.Errorf("failed to replace %s: %w", dst, err)
+		}
+	}
+
+	if unchanged {
+		s.stats.Unchanged++
+		if dstInfo.Mode().Perm() != srcInfo.Mode().Perm() {
+			if err := os.Chmod(dst, srcInfo.Mode().Perm()); err != nil {
+				return fmt.Errorf("failed to update mode of %s: %w", dst, err)
+			}
+		}
+	} else {
+		if err := s.overwrite(dst); err != nil {
+			return err
+		}
+		if err := CopyFile(src, dst); err != nil {
+			return err
+		}
+		// CopyFile keeps the mode of an existing file, apply the source mode
+		if err := os.Chmod(dst, srcInfo.Mode().Perm()); err != nil {
+			return fmt.Errorf("failed to update mode of %s: %w", dst, err)
+		}
+		s.stats.Copied++
+	}
+
+	// Keep the source modification time so the manifest entry describes both
+	// copies and the next sync can reuse its hash
+	if err := os.Chtimes(dst, srcInfo.ModTime(), srcInfo.ModTime()); err != nil {
+		return fmt.Errorf("failed to update times of %s: %w", dst, err)
+	}
+
+	s.manifest.Files[rel] = ManifestEntry{Size: srcInfo.Size(), ModTime: srcInfo.ModTime(), Hash: srcHash}
+	s.progress.add(srcInfo.Size())
+
+	return nil
+}
+
+// hashWithManifests returns the content hash of a file, taken from the first
+// manifest whose entry still matches the file size and modification time
+func hashWithManifests(path, rel string, info os.FileInfo, manifests ...*Manifest) (string, error) {
+	for _, m := range manifests {
+		if hash, ok := m.lookup(rel, info); ok {
+			return hash, nil
+		}
+	}
+	return HashFile(path)
+}
+
+// Hash returns the content hash of the file at path, the one recorded for rel
+// while the file still has the recorded size and modification time. A nil
+// manifest hashes the file.
+func (m *Manifest) Hash(path, rel string, info os.FileInfo) (string, error) {
+	if m == nil {
+		return HashFile(path)
+	}
+	return hashWithManifests(path, rel, info, m)
+}
+
+// lookup returns the recorded hash of rel if the entry matches info and is
+// old enough to be trusted
+func (m *Manifest) lookup(rel string, info os.FileInfo) (string, bool) {
+	entry, ok := m.Files[rel]
+	if !ok || entry.Size != info.Size() || !entry.ModTime.Equal(info.ModTime()) {
+		return "", false
+	}
+	if !entry.ModTime.Before(m.WrittenAt.Add(-racyWindow)) {
+		return "", false
+	}
+	return entry.Hash, true
+}
+
+// prune removes everything under dst that is not in src
+func (s *syncer) prune(dst, rel string) error {
+	entries, err := os.ReadDir(dst)
+	if err != nil {
+		return fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		entryRel := filepath.ToSlash(filepath.Join(rel, name))
+		dstPath := filepath.Join(dst, name)
+		if s.backup != nil && dstPath == s.backup.Dir() {
+			continue
+		}
+
+		if !s.keep[entryRel] {
+			if s.keepExcluded && IsExcluded(entryRel, s.exclude) {
+				continue
+			}
+			if err := s.remove(dstPath); err != nil {
+				return fmt.Errorf("failed to remove %s: %w", dstPath, err)
+			}
+			s.stats.Removed++
+			continue
+		}
+
+		if entry.IsDir() {
+			if err := s.prune(dstPath, entryRel); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// HashFile returns the hex-encoded SHA-256 of a file's content
+func HashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = file.Close() }()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
@@ -0,0 +1,256 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeSyncFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+}
+
+func readSyncFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	return string(data)
+}
+
+func TestSnapshotDir(t *testing.T) {
+	tmpDir := t.TempDir()
+	live := filepath.Join(tmpDir, "live")
+	snapshot := filepath.Join(tmpDir, "snapshot")
+
+	writeSyncFile(t, filepath.Join(live, "config"), "profile=work")
+	writeSyncFile(t, filepath.Join(live, "cache", "big.json"), "cached")
+
+	stats, err := SnapshotDir(live, snapshot)
+	if err != nil {
+		t.Fatalf("SnapshotDir failed: %v", err)
+	}
+	if stats.Copied != 2 {
+		t.Errorf("Copied = %d, want 2", stats.Copied)
+	}
+
+	manifest := LoadManifest(snapshot)
+	if len(manifest.Files) != 2 {
+		t.Fatalf("Manifest has %d files, want 2", len(manifest.Files))
+	}
+	if manifest.Files["cache/big.json"].Hash == "" {
+		t.Error("Manifest entry has no hash")
+	}
+
+	// A second snapshot copies nothing
+	stats, err = SnapshotDir(live, snapshot)
+	if err != nil {
+		t.Fatalf("SnapshotDir failed: %v", err)
+	}
+	if stats.Copied != 0 || stats.Unchanged != 2 {
+		t.Errorf("Stats = %+v, want 0 copied and 2 unchanged", stats)
+	}
+
+	// Only the changed file is copied, deleted files are dropped
+	writeSyncFile(t, filepath.Join(live, "config"), "profile=home")
+	if err := os.RemoveAll(filepath.Join(live, "cache")); err != nil {
+		t.Fatalf("Failed to remove cache: %v", err)
+	}
+
+	stats, err = SnapshotDir(live, snapshot)
+	if err != nil {
+		t.Fatalf("SnapshotDir failed: %v", err)
+	}
+	if stats.Copied != 1 || stats.Removed != 1 {
+		t.Errorf("Stats = %+v, want 1 copied and 1 removed", stats)
+	}
+	if got := readSyncFile(t, filepath.Join(snapshot, "config")); got != "profile=home" {
+		t.Errorf("Snapshot content = %q, want %q", got, "profile=home")
+	}
+	if _, err := os.Stat(filepath.Join(snapshot, "cache")); !os.IsNotExist(err) {
+		t.Error("Deleted directory still in snapshot")
+	}
+	if _, err := os.Stat(filepath.Join(snapshot, ManifestFile)); err != nil {
+		t.Error("Manifest was removed")
+	}
+}
+
+func TestSnapshotDirDetectsSameSizeChange(t *testing.T) {
+	tmpDir := t.TempDir()
+	live := filepath.Join(tmpDir, "live")
+	snapshot := filepath.Join(tmpDir, "snapshot")
+
+	writeSyncFile(t, filepath.Join(live, "token"), "aaaa")
+	if _, err := SnapshotDir(live, snapshot); err != nil {
+		t.Fatalf("SnapshotDir failed: %v", err)
+	}
+
+	// Same size, different content and time
+	writeSyncFile(t, filepath.Join(live, "token"), "bbbb")
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(filepath.Join(live, "token"), later, later); err != nil {
+		t.Fatalf("Failed to update times: %v", err)
+	}
+
+	stats, err := SnapshotDir(live, snapshot)
+	if err != nil {
+		t.Fatalf("SnapshotDir failed: %v", err)
+	}
+	if stats.Copied != 1 {
+		t.Errorf("Copied = %d, want 1", stats.Copied)
+	}
+	if got := readSyncFile(t, filepath.Join(snapshot, "token")); got != "bbbb" {
+		t.Errorf("Snapshot content = %q, want %q", got, "bbbb")
+	}
+}
+
+func TestSyncDir(t *testing.T) {
+	tmpDir := t.TempDir()
+	live := filepath.Join(tmpDir, "live")
+	snapshot := filepath.Join(tmpDir, "snapshot")
+
+	writeSyncFile(t, filepath.Join(live, "config"), "profile=work")
+	writeSyncFile(t, filepath.Join(live, "credentials"), "secret")
+	if _, err := SnapshotDir(live, snapshot); err != nil {
+		t.Fatalf("SnapshotDir failed: %v", err)
+	}
+
+	// Touch one file without changing it, modify another and add a new one
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(filepath.Join(live, "config"), later, later); err != nil {
+		t.Fatalf("Failed to update times: %v", err)
+	}
+	writeSyncFile(t, filepath.Join(live, "credentials"), "other-secret")
+	writeSyncFile(t, filepath.Join(live, "extra"), "new")
+
+	stats, err := SyncDir(snapshot, live)
+	if err != nil {
+		t.Fatalf("SyncDir failed: %v", err)
+	}
+	if stats.Copied != 1 || stats.Unchanged != 1 || stats.Removed != 1 {
+		t.Errorf("Stats = %+v, want 1 copied, 1 unchanged and 1 removed", stats)
+	}
+	if got := readSyncFile(t, filepath.Join(live, "credentials")); got != "secret" {
+		t.Errorf("Live content = %q, want %q", got, "secret")
+	}
+	if _, err := os.Stat(filepath.Join(live, "extra")); !os.IsNotExist(err) {
+		t.Error("File missing from the snapshot was not removed")
+	}
+	if _, err := os.Stat(filepath.Join(live, ManifestFile)); !os.IsNotExist(err) {
+		t.Error("Manifest was copied to the live directory")
+	}
+}
+
+func TestSyncDirExcludePatterns(t *testing.T) {
+	tmpDir := t.TempDir()
+	live := filepath.Join(tmpDir, "live")
+	snapshot := filepath.Join(tmpDir, "snapshot")
+	exclude := []string{"logs/**", "**/*.log"}
+
+	writeSyncFile(t, filepath.Join(live, "config"), "profile=work")
+	writeSyncFile(t, filepath.Join(live, "logs", "2024", "run.txt"), "log")
+	writeSyncFile(t, filepath.Join(live, "sub", "debug.log"), "log")
+	// Left over by a snapshot taken without the patterns
+	writeSyncFile(t, filepath.Join(snapshot, "logs", "old.txt"), "log")
+
+	stats, err := SnapshotDir(live, snapshot, exclude...)
+	if err != nil {
+		t.Fatalf("SnapshotDir failed: %v", err)
+	}
+	if stats.Copied != 1 {
+		t.Errorf("Copied = %d, want 1", stats.Copied)
+	}
+	for _, rel := range []string{"logs", filepath.Join("sub", "debug.log")} {
+		if _, err := os.Stat(filepath.Join(snapshot, rel)); !os.IsNotExist(err) {
+			t.Errorf("Excluded %s is in the snapshot", rel)
+		}
+	}
+	if manifest := LoadManifest(snapshot); manifest.ExcludedFiles != 2 || manifest.ExcludedBytes != 6 {
+		t.Errorf("Manifest excluded %d file(s) of %d bytes, want 2 of 6", manifest.ExcludedFiles, manifest.ExcludedBytes)
+	}
+
+	// Restoring leaves the excluded live files alone
+	writeSyncFile(t, filepath.Join(live, "config"), "profile=personal")
+	if _, err := SyncDir(snapshot, live, exclude...); err != nil {
+		t.Fatalf("SyncDir failed: %v", err)
+	}
+	if got := readSyncFile(t, filepath.Join(live, "config")); got != "profile=work" {
+		t.Errorf("Live content = %q, want %q", got, "profile=work")
+	}
+	for _, rel := range []string{filepath.Join("logs", "2024", "run.txt"), filepath.Join("sub", "debug.log")} {
+		if _, err := os.Stat(filepath.Join(live, rel)); err != nil {
+			t.Errorf("Excluded live file %s was removed: %v", rel, err)
+		}
+	}
+}
+
+func TestSyncDirNonExistent(t *testing.T) {
+	tmpDir := t.TempDir()
+	if _, err := SyncDir(filepath.Join(tmpDir, "missing"), filepath.Join(tmpDir, "dst")); err == nil {
+		t.Error("Expected error for missing source")
+	}
+}
+
+func TestSnapshotDirReusesManifestHashes(t *testing.T) {
+	tmpDir := t.TempDir()
+	live := filepath.Join(tmpDir, "live")
+	snapshot := filepath.Join(tmpDir, "snapshot")
+
+	livePath := filepath.Join(live, "config")
+	writeSyncFile(t, livePath, "aaaa")
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(livePath, old, old); err != nil {
+		t.Fatalf("Failed to update times: %v", err)
+	}
+
+	if _, err := SnapshotDir(live, snapshot); err != nil {
+		t.Fatalf("SnapshotDir failed: %v", err)
+	}
+
+	// Alter the snapshot behind the manifest's back: the recorded hash is
+	// trusted, so the file is not read again
+	snapshotPath := filepath.Join(snapshot, "config")
+	writeSyncFile(t, snapshotPath, "zzzz")
+	if err := os.Chtimes(snapshotPath, old, old); err != nil {
+		t.Fatalf("Failed to update times: %v", err)
+	}
+
+	stats, err := SnapshotDir(live, snapshot)
+	if err != nil {
+		t.Fatalf("SnapshotDir failed: %v", err)
+	}
+	if stats.Unchanged != 1 {
+		t.Errorf("Unchanged = %d, want 1", stats.Unchanged)
+	}
+}
+
+func TestManifestLookup(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "file")
+	writeSyncFile(t, path, "content")
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Failed to stat file: %v", err)
+	}
+
+	entry := ManifestEntry{Size: info.Size(), ModTime: info.ModTime(), Hash: "recorded"}
+
+	// Written right after the file changed: racy, must be rehashed
+	racy := &Manifest{WrittenAt: info.ModTime(), Files: map[string]ManifestEntry{"file": entry}}
+	if _, ok := racy.lookup("file", info); ok {
+		t.Error("Racy entry was trusted")
+	}
+
+	settled := &Manifest{WrittenAt: info.ModTime().Add(time.Minute), Files: map[string]ManifestEntry{"file": entry}}
+	if hash, ok := settled.lookup("file", info); !ok || hash != "recorded" {
+		t.Errorf("lookup = %q, %v, want recorded hash", hash, ok)
+	}
+}
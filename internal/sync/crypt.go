@@ -0,0 +1,121 @@
+package sync
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hugofrely/envswitch/internal/storage"
+)
+
+// KeyFileName is the file of ~/.envswitch holding the encryption key. It is
+// never synced: copy it to the other machines yourself.
+const KeyFileName = "sync.key"
+
+const keySize = 32
+
+// encryptedMagic starts every encrypted file, followed by the nonce
+var encryptedMagic = []byte("ESE1")
+
+// GenerateKey creates a random key at path, or loads the existing one
+func GenerateKey(path string) ([]byte, error) {
+	if _, err := os.Stat(path); err == nil {
+		return LoadKey(path)
+	}
+
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	if err := storage.MkdirPrivate(filepath.Dir(path)); err != nil {
+		return nil, fmt.Errorf("failed to create key directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(hex.EncodeToString(key)+"\n"), storage.PrivateFileMode); err != nil {
+		return nil, fmt.Errorf("failed to write key: %w", err)
+	}
+	return key, nil
+}
+
+// LoadKey reads a key written by GenerateKey
+func LoadKey(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("encryption key %s not found: copy it from the machine that set up sync", path)
+		}
+		return nil, fmt.Errorf("failed to read key: %w", err)
+	}
+
+	key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(key) != keySize {
+		return nil, fmt.Errorf("invalid encryption key in %s", path)
+	}
+	return key, nil
+}
+
+// encrypt seals data with AES-GCM, bound to its path in the repository. The
+// nonce is derived from the path and the content, so an unchanged file
+// encrypts to the same bytes and does not show up as a change in git.
+func encrypt(key []byte, path string, data []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	mac := hmac.New(sha256.New, subkey(key, "nonce"))
+	mac.Write([]byte(path))
+	mac.Write([]byte{0})
+	mac.Write(data)
+	nonce := mac.Sum(nil)[:aead.NonceSize()]
+
+	out := append(append([]byte{}, encryptedMagic...), nonce...)
+	return aead.Seal(out, nonce, data, []byte(path)), nil
+}
+
+// decrypt opens data sealed by encrypt for the same path
+func decrypt(key []byte, path string, data []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	headerSize := len(encryptedMagic) + aead.NonceSize()
+	if len(data) < headerSize || !bytes.Equal(data[:len(encryptedMagic)], encryptedMagic) {
+		return nil, fmt.Errorf("%s is not an encrypted sync file", path)
+	}
+
+	nonce := data[len(encryptedMagic):headerSize]
+	plain, err := aead.Open(nil, nonce, data[headerSize:], []byte(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt %s: wrong key or corrupted file", path)
+	}
+	return plain, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != keySize {
+		return nil, fmt.Errorf("invalid encryption key size")
+	}
+	block, err := aes.NewCipher(subkey(key, "encrypt"))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// subkey derives a key for one purpose, so the same bytes never serve both
+// as the cipher key and the nonce key
+func subkey(key []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
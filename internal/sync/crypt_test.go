@@ -0,0 +1,61 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), KeyFileName)
+
+	key, err := GenerateKey(path)
+	require.NoError(t, err)
+	assert.Len(t, key, keySize)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	again, err := GenerateKey(path)
+	require.NoError(t, err)
+	assert.Equal(t, key, again, "an existing key is kept")
+
+	_, err = LoadKey(filepath.Join(t.TempDir(), KeyFileName))
+	assert.ErrorContains(t, err, "copy it from the machine that set up sync")
+
+	require.NoError(t, os.WriteFile(path, []byte("not hex"), 0600))
+	_, err = LoadKey(path)
+	assert.ErrorContains(t, err, "invalid encryption key")
+}
+
+func TestEncrypt(t *testing.T) {
+	key := make([]byte, keySize)
+	key[0] = 1
+	plain := []byte("aws_secret_access_key = abc")
+
+	sealed, err := encrypt(key, "work/snapshots/aws/credentials", plain)
+	require.NoError(t, err)
+	assert.NotContains(t, string(sealed), "aws_secret_access_key")
+
+	again, err := encrypt(key, "work/snapshots/aws/credentials", plain)
+	require.NoError(t, err)
+	assert.Equal(t, sealed, again, "same content encrypts to the same bytes")
+
+	opened, err := decrypt(key, "work/snapshots/aws/credentials", sealed)
+	require.NoError(t, err)
+	assert.Equal(t, plain, opened)
+
+	_, err = decrypt(key, "personal/snapshots/aws/credentials", sealed)
+	assert.ErrorContains(t, err, "wrong key or corrupted file", "files cannot be moved to another path")
+
+	other := make([]byte, keySize)
+	_, err = decrypt(other, "work/snapshots/aws/credentials", sealed)
+	assert.Error(t, err)
+
+	_, err = decrypt(key, "work/metadata.yaml", []byte("name: work"))
+	assert.ErrorContains(t, err, "not an encrypted sync file")
+}
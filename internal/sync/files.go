@@ -0,0 +1,230 @@
+package sync
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hugofrely/envswitch/internal/storage"
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+// export mirrors the local environments into the working copy, encrypting
+// the files when the repository has a key. Files the working copy already
+// holds with the same content are left alone.
+func (r *Repo) export() error {
+	names, err := localEnvironments(r.EnvsDir, "")
+	if err != nil {
+		return fmt.Errorf("failed to read environments: %w", err)
+	}
+
+	dst := filepath.Join(r.Dir, envsDirName)
+	written := make(map[string]bool)
+	for _, name := range names {
+		err := filepath.Walk(filepath.Join(r.EnvsDir, filepath.FromSlash(name)), func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			// Manifests hold modification times of this machine only
+			if !info.Mode().IsRegular() || info.Name() == storage.ManifestFile {
+				return nil
+			}
+
+			rel, err := filepath.Rel(r.EnvsDir, path)
+			if err != nil {
+				return err
+			}
+			rel = filepath.ToSlash(rel)
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+
+			target := rel
+			if r.Key != nil && rel != name+"/"+metadataFileName {
+				if data, err = encrypt(r.Key, rel, data); err != nil {
+					return fmt.Errorf("failed to encrypt %s: %w", rel, err)
+				}
+				target += encryptedSuffix
+			}
+
+			written[target] = true
+			return writeIfChanged(filepath.Join(dst, filepath.FromSlash(target)), data)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to copy environment '%s' to the sync directory: %w", name, err)
+		}
+	}
+
+	return prune(dst, written)
+}
+
+// localEnvironments lists the environments below dir, by slash-separated
+// name, descending into group directories
+func localEnvironments(dir, group string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		name := entry.Name()
+		if group != "" {
+			name = group + "/" + name
+		}
+
+		entryPath := filepath.Join(dir, entry.Name())
+		if _, err := os.Stat(filepath.Join(entryPath, metadataFileName)); err == nil {
+			names = append(names, name)
+			continue
+		}
+		grouped, err := localEnvironments(entryPath, name)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, grouped...)
+	}
+	return names, nil
+}
+
+// importEnvironments replaces the local environments with their copy in the
+// working copy, decrypting their files. Environments no longer in the
+// working copy are deleted.
+func (r *Repo) importEnvironments(names []string) error {
+	for _, name := range names {
+		src := filepath.Join(r.Dir, envsDirName, filepath.FromSlash(name))
+		dst := filepath.Join(r.EnvsDir, filepath.FromSlash(name))
+
+		if _, err := os.Stat(src); os.IsNotExist(err) {
+			if err := os.RemoveAll(dst); err != nil {
+				return fmt.Errorf("failed to delete environment '%s': %w", name, err)
+			}
+			environment.RemoveEmptyGroups(dst)
+			continue
+		}
+
+		// Build the new copy outside the environments directory, so a failure
+		// leaves the environment untouched and listings never see it
+		tmp, err := os.MkdirTemp(filepath.Dir(r.EnvsDir), ".sync-import-")
+		if err != nil {
+			return fmt.Errorf("failed to create import directory: %w", err)
+		}
+		if err := r.decryptTree(src, tmp, name); err != nil {
+			_ = os.RemoveAll(tmp)
+			return fmt.Errorf("failed to copy environment '%s' from the sync directory: %w", name, err)
+		}
+		if err := os.RemoveAll(dst); err != nil {
+			_ = os.RemoveAll(tmp)
+			return fmt.Errorf("failed to replace environment '%s': %w", name, err)
+		}
+		if err := storage.MkdirPrivate(filepath.Dir(dst)); err != nil {
+			_ = os.RemoveAll(tmp)
+			return fmt.Errorf("failed to create group of environment '%s': %w", name, err)
+		}
+		if err := os.Rename(tmp, dst); err != nil {
+			return fmt.Errorf("failed to replace environment '%s': %w", name, err)
+		}
+	}
+	return nil
+}
+
+// decryptTree copies the environment name from src to dst, decrypting the
+// encrypted files
+func (r *Repo) decryptTree(src, dst, name string) error {
+	if err := os.RemoveAll(dst); err != nil {
+		return err
+	}
+
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		if info.IsDir() {
+			return storage.MkdirPrivate(target)
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if strings.HasSuffix(rel, encryptedSuffix) {
+			if r.Key == nil {
+				return fmt.Errorf("%s is encrypted, enable sync_encrypt and copy the key from another machine", filepath.ToSlash(rel))
+			}
+			rel = strings.TrimSuffix(rel, encryptedSuffix)
+			target = strings.TrimSuffix(target, encryptedSuffix)
+			if data, err = decrypt(r.Key, name+"/"+filepath.ToSlash(rel), data); err != nil {
+				return err
+			}
+		}
+		return os.WriteFile(target, data, storage.PrivateFileMode)
+	})
+}
+
+// writeIfChanged writes data to path unless it already holds it
+func writeIfChanged(path string, data []byte) error {
+	if existing, err := os.ReadFile(path); err == nil && bytes.Equal(existing, data) {
+		return nil
+	}
+	if err := storage.MkdirPrivate(filepath.Dir(path)); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, storage.PrivateFileMode)
+}
+
+// prune removes the files under dir that were not written, and the
+// directories left empty
+func prune(dir string, written map[string]bool) error {
+	var dirs []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() {
+			if path != dir {
+				dirs = append(dirs, path)
+			}
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if !written[filepath.ToSlash(rel)] {
+			return os.Remove(path)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to clean the sync directory: %w", err)
+	}
+
+	// Deepest directories first, removing fails harmlessly when not empty
+	for i := len(dirs) - 1; i >= 0; i-- {
+		_ = os.Remove(dirs[i])
+	}
+	return nil
+}
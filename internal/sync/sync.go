@@ -0,0 +1,547 @@
+// Package sync shares environments between machines through a git
+// repository. A working copy under ~/.envswitch/sync mirrors
+// ~/.envswitch/environments: local changes are copied in and committed
+// before being pushed, and pulled commits are copied back out.
+package sync
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hugofrely/envswitch/internal/storage"
+	"github.com/hugofrely/envswitch/pkg/environment"
+)
+
+const (
+	// DirName is the directory of ~/.envswitch holding the working copy
+	DirName = "sync"
+
+	envsDirName      = "environments"
+	metadataFileName = "metadata.yaml"
+	encryptedSuffix  = ".enc"
+	branch           = "main"
+	remoteRef        = "refs/remotes/origin/" + branch
+)
+
+// ErrNotInitialized is returned when the working copy was not set up
+var ErrNotInitialized = errors.New("sync is not set up, run 'envswitch sync init <remote>'")
+
+// ConflictError lists the environments whose metadata.yaml changed both on
+// this machine and on the remote since the last sync
+type ConflictError struct {
+	Environments []string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("environments changed both here and on the remote: %s", strings.Join(e.Environments, ", "))
+}
+
+// Repo is the sync working copy of the local environments
+type Repo struct {
+	Dir     string // working copy, ~/.envswitch/sync
+	EnvsDir string // local environments, ~/.envswitch/environments
+	Remote  string
+	Key     []byte // when set, every file but metadata.yaml is encrypted
+}
+
+// Status describes what push and pull would do
+type Status struct {
+	LocalChanges  []string // environments changed here since the last sync
+	RemoteChanges []string // environments changed on the remote
+	Conflicts     []string
+	FetchError    error // the remote could not be reached, remote changes are unknown
+}
+
+// New returns the sync repository of the envswitch directory
+func New(envswitchDir, remote string, key []byte) *Repo {
+	return &Repo{
+		Dir:     filepath.Join(envswitchDir, DirName),
+		EnvsDir: filepath.Join(envswitchDir, envsDirName),
+		Remote:  remote,
+		Key:     key,
+	}
+}
+
+// IsAvailable reports whether git is installed
+func IsAvailable() bool {
+	_, err := exec.LookPath("git")
+	return err == nil
+}
+
+// Initialized reports whether the working copy exists
+func (r *Repo) Initialized() bool {
+	_, err := os.Stat(filepath.Join(r.Dir, ".git"))
+	return err == nil
+}
+
+// Init creates the working copy and points it at the remote. When the remote
+// already holds environments, those this machine does not have are copied
+// into the local store and returned.
+func (r *Repo) Init() ([]string, error) {
+	if !r.Initialized() {
+		if err := storage.MkdirPrivate(r.Dir); err != nil {
+			return nil, fmt.Errorf("failed to create sync directory: %w", err)
+		}
+		if _, err := r.git("init", "-q"); err != nil {
+			return nil, err
+		}
+		if _, err := r.git("symbolic-ref", "HEAD", "refs/heads/"+branch); err != nil {
+			return nil, err
+		}
+		// The objects hold the snapshots, only the user may read them
+		if _, err := r.git("config", "core.sharedRepository", "0600"); err != nil {
+			return nil, err
+		}
+		if _, err := storage.HardenPermissions(r.Dir); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := r.git("remote", "get-url", "origin"); err == nil {
+		if _, err := r.git("remote", "set-url", "origin", r.Remote); err != nil {
+			return nil, err
+		}
+	} else if _, err := r.git("remote", "add", "origin", r.Remote); err != nil {
+		return nil, err
+	}
+
+	if err := r.fetch(); err != nil {
+		return nil, err
+	}
+	if r.hasCommit("HEAD") || !r.hasCommit(remoteRef) {
+		return nil, nil
+	}
+
+	// Start from the remote history, local environments become changes on
+	// top of it
+	if _, err := r.git("checkout", "-q", "-B", branch, remoteRef); err != nil {
+		return nil, err
+	}
+	remote, err := r.trackedEnvironments("HEAD")
+	if err != nil {
+		return nil, err
+	}
+	var missing []string
+	for _, name := range remote {
+		if _, err := os.Stat(filepath.Join(r.EnvsDir, name)); os.IsNotExist(err) {
+			missing = append(missing, name)
+		}
+	}
+	return missing, r.importEnvironments(missing)
+}
+
+// Push commits the local changes and pushes them. It fails when the remote
+// has commits this machine does not have yet, they must be pulled first.
+func (r *Repo) Push() ([]string, error) {
+	if !r.Initialized() {
+		return nil, ErrNotInitialized
+	}
+
+	if _, err := r.commitLocalChanges(); err != nil {
+		return nil, err
+	}
+	if err := r.fetch(); err != nil {
+		return nil, err
+	}
+	if !r.hasCommit("HEAD") {
+		return nil, nil
+	}
+
+	var pushed []string
+	if r.hasCommit(remoteRef) {
+		behind, err := r.countCommits("HEAD.." + remoteRef)
+		if err != nil {
+			return nil, err
+		}
+		if behind > 0 {
+			return nil, fmt.Errorf("the remote has changes this machine does not have, run 'envswitch sync pull' first")
+		}
+		if pushed, err = r.changedEnvironments(remoteRef, "HEAD", ""); err != nil {
+			return nil, err
+		}
+	} else {
+		var err error
+		if pushed, err = r.trackedEnvironments("HEAD"); err != nil {
+			return nil, err
+		}
+	}
+	if len(pushed) == 0 {
+		return nil, nil
+	}
+
+	if _, err := r.git("push", "-q", "origin", "HEAD:refs/heads/"+branch); err != nil {
+		return nil, err
+	}
+	return pushed, nil
+}
+
+// Pull merges the remote changes and copies the environments they touch
+// into the local store. Local changes are committed first, so they are
+// never lost. With force, the remote wins for conflicting environments.
+func (r *Repo) Pull(force bool) ([]string, error) {
+	if !r.Initialized() {
+		return nil, ErrNotInitialized
+	}
+
+	if _, err := r.commitLocalChanges(); err != nil {
+		return nil, err
+	}
+	if err := r.fetch(); err != nil {
+		return nil, err
+	}
+	if !r.hasCommit(remoteRef) {
+		return nil, nil
+	}
+
+	if !r.hasCommit("HEAD") {
+		if _, err := r.git("checkout", "-q", "-B", branch, remoteRef); err != nil {
+			return nil, err
+		}
+		pulled, err := r.trackedEnvironments("HEAD")
+		if err != nil {
+			return nil, err
+		}
+		return pulled, r.importEnvironments(pulled)
+	}
+
+	before, err := r.git("rev-parse", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+
+	conflicts, err := r.conflicts()
+	if err != nil {
+		return nil, err
+	}
+	if len(conflicts) > 0 {
+		if !force {
+			return nil, &ConflictError{Environments: conflicts}
+		}
+		if err := r.discardLocal(conflicts); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := r.git("merge", "-q", "--no-edit", "--allow-unrelated-histories", remoteRef); err != nil {
+		_, _ = r.git("merge", "--abort")
+		return nil, fmt.Errorf("failed to merge the remote changes: %w", err)
+	}
+
+	pulled, err := r.changedEnvironments(before, "HEAD", "")
+	if err != nil {
+		return nil, err
+	}
+	return pulled, r.importEnvironments(pulled)
+}
+
+// Status reports the local and remote changes without committing, pushing
+// or copying anything out. An unreachable remote is reported in FetchError.
+func (r *Repo) Status() (*Status, error) {
+	if !r.Initialized() {
+		return nil, ErrNotInitialized
+	}
+
+	status := &Status{}
+	if err := r.export(); err != nil {
+		return nil, err
+	}
+	if _, err := r.git("add", "-A", "--", envsDirName); err != nil {
+		return nil, err
+	}
+	status.FetchError = r.fetch()
+
+	base := r.mergeBase()
+	local, err := r.changedEnvironments(base, "", "")
+	if err != nil {
+		return nil, err
+	}
+	status.LocalChanges = local
+
+	if r.hasCommit(remoteRef) {
+		if status.RemoteChanges, err = r.changedEnvironments(base, remoteRef, ""); err != nil {
+			return nil, err
+		}
+
+		localMeta, err := r.changedEnvironments(base, "", metadataFileName)
+		if err != nil {
+			return nil, err
+		}
+		remoteMeta, err := r.changedEnvironments(base, remoteRef, metadataFileName)
+		if err != nil {
+			return nil, err
+		}
+		status.Conflicts = intersect(localMeta, remoteMeta)
+	}
+
+	return status, nil
+}
+
+// commitLocalChanges copies the local environments into the working copy
+// and commits them, reporting whether there was anything to commit
+func (r *Repo) commitLocalChanges() (bool, error) {
+	if err := r.export(); err != nil {
+		return false, err
+	}
+	if _, err := r.git("add", "-A", "--", envsDirName); err != nil {
+		return false, err
+	}
+	if _, err := r.git("diff", "--cached", "--quiet"); err == nil {
+		return false, nil
+	}
+
+	if _, err := r.git("commit", "-q", "-m", "Sync from "+environment.CurrentHost()); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// conflicts lists the environments whose metadata.yaml changed in both HEAD
+// and the remote since they diverged
+func (r *Repo) conflicts() ([]string, error) {
+	base := r.mergeBase()
+	local, err := r.changedEnvironments(base, "HEAD", metadataFileName)
+	if err != nil {
+		return nil, err
+	}
+	remote, err := r.changedEnvironments(base, remoteRef, metadataFileName)
+	if err != nil {
+		return nil, err
+	}
+	return intersect(local, remote), nil
+}
+
+// discardLocal commits the environments back to their state at the merge
+// base, so the merge takes the remote version of them
+func (r *Repo) discardLocal(names []string) error {
+	base := r.mergeBase()
+	for _, name := range names {
+		envPath := envsDirName + "/" + name
+		if _, err := r.git("rm", "-r", "-q", "--ignore-unmatch", "--", envPath); err != nil {
+			return err
+		}
+		if base != "" {
+			if tracked, _ := r.git("ls-tree", "--name-only", base, "--", envPath); tracked != "" {
+				if _, err := r.git("checkout", base, "--", envPath); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	_, err := r.git("commit", "-q", "--allow-empty", "-m", "Discard local changes to "+strings.Join(names, ", "))
+	return err
+}
+
+// changedEnvironments lists the environments with files changed between two
+// commits, or between a commit and the index when to is empty. An empty from
+// means everything. With file, only that file of each environment counts.
+func (r *Repo) changedEnvironments(from, to, file string) ([]string, error) {
+	refs := []string{to}
+	if from != "" {
+		refs = append(refs, from)
+	}
+	roots, err := r.environmentRoots(refs...)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	if from == "" {
+		paths, err = r.files(to)
+	} else {
+		args := []string{"diff", "--name-only", from, to}
+		if to == "" {
+			args = []string{"diff", "--name-only", "--cached", from}
+		}
+		var output string
+		output, err = r.git(append(args, "--", envsDirName)...)
+		paths = splitLines(output)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return environmentsOf(paths, roots, file), nil
+}
+
+// trackedEnvironments lists the environments in a commit
+func (r *Repo) trackedEnvironments(ref string) ([]string, error) {
+	return r.environmentRoots(ref)
+}
+
+// environmentRoots lists the environments, by name, of the given commits,
+// the index standing for an empty one. An environment is a directory with a
+// metadata.yaml, grouped environments are nested in group directories.
+func (r *Repo) environmentRoots(refs ...string) ([]string, error) {
+	var roots []string
+	for _, ref := range refs {
+		paths, err := r.files(ref)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range paths {
+			if path.Base(p) == metadataFileName {
+				roots = append(roots, strings.TrimPrefix(path.Dir(p), envsDirName+"/"))
+			}
+		}
+	}
+
+	// metadata.yaml files inside an environment, e.g. in snapshots, do not
+	// make it a group
+	sort.Strings(roots)
+	var outer []string
+	for _, root := range roots {
+		if len(outer) > 0 {
+			last := outer[len(outer)-1]
+			if root == last || strings.HasPrefix(root, last+"/") {
+				continue
+			}
+		}
+		outer = append(outer, root)
+	}
+	return outer, nil
+}
+
+// files lists the files of the environments in a commit, or in the index
+// when ref is empty. A commit that does not exist has no files.
+func (r *Repo) files(ref string) ([]string, error) {
+	var output string
+	var err error
+	switch {
+	case ref == "":
+		output, err = r.git("ls-files", "--", envsDirName)
+	case !r.hasCommit(ref):
+		return nil, nil
+	default:
+		output, err = r.git("ls-tree", "-r", "--name-only", ref, "--", envsDirName)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return splitLines(output), nil
+}
+
+// environmentsOf returns the sorted environments, among roots, holding the
+// repository paths. With file, only paths to that file of an environment
+// count.
+func environmentsOf(paths, roots []string, file string) []string {
+	seen := make(map[string]bool)
+	for _, p := range paths {
+		rel := strings.TrimPrefix(p, envsDirName+"/")
+		for _, root := range roots {
+			if !strings.HasPrefix(rel, root+"/") {
+				continue
+			}
+			if file == "" || rel == root+"/"+file {
+				seen[root] = true
+			}
+			break
+		}
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func splitLines(output string) []string {
+	if output == "" {
+		return nil
+	}
+	return strings.Split(output, "\n")
+}
+
+// mergeBase returns the last commit shared with the remote, empty when there
+// is none
+func (r *Repo) mergeBase() string {
+	if !r.hasCommit("HEAD") || !r.hasCommit(remoteRef) {
+		return ""
+	}
+	base, err := r.git("merge-base", "HEAD", remoteRef)
+	if err != nil {
+		return ""
+	}
+	return base
+}
+
+func (r *Repo) fetch() error {
+	if _, err := r.git("fetch", "-q", "origin"); err != nil {
+		return fmt.Errorf("failed to reach %s: %w", r.Remote, err)
+	}
+	return nil
+}
+
+func (r *Repo) hasCommit(ref string) bool {
+	_, err := r.git("rev-parse", "-q", "--verify", ref+"^{commit}")
+	return err == nil
+}
+
+func (r *Repo) countCommits(revRange string) (int, error) {
+	output, err := r.git("rev-list", "--count", revRange)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(output)
+}
+
+// git runs a git command in the working copy and returns its trimmed output.
+// Commits are authored by envswitch, whatever the user's git identity.
+func (r *Repo) git(args ...string) (string, error) {
+	subcommand := args[0]
+	args = append([]string{
+		"-C", r.Dir,
+		"-c", "user.name=envswitch",
+		"-c", "user.email=envswitch@" + environment.CurrentHost(),
+		"-c", "commit.gpgsign=false",
+	}, args...)
+
+	// #nosec G204 - Arguments are built by envswitch, the remote comes from the user's config
+	cmd := exec.Command("git", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return "", fmt.Errorf("git %s: %s", subcommand, message)
+		}
+		return "", fmt.Errorf("git %s: %w", subcommand, err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+func intersect(a, b []string) []string {
+	in := make(map[string]bool, len(b))
+	for _, value := range b {
+		in[value] = true
+	}
+	var both []string
+	for _, value := range a {
+		if in[value] {
+			both = append(both, value)
+		}
+	}
+	return both
+}
+
+func union(a, b []string) []string {
+	seen := make(map[string]bool)
+	var all []string
+	for _, value := range append(append([]string{}, a...), b...) {
+		if !seen[value] {
+			seen[value] = true
+			all = append(all, value)
+		}
+	}
+	sort.Strings(all)
+	return all
+}
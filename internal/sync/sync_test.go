@@ -0,0 +1,192 @@
+package sync
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// machine is an envswitch directory sharing a remote with other machines
+type machine struct {
+	t    *testing.T
+	home string
+	repo *Repo
+}
+
+func newMachine(t *testing.T, remote string, key []byte) *machine {
+	home := t.TempDir()
+	return &machine{t: t, home: home, repo: New(filepath.Join(home, ".envswitch"), remote, key)}
+}
+
+// use makes the machine the current one, as the environment package
+// resolves paths from $HOME
+func (m *machine) use() *Repo {
+	m.t.Setenv("HOME", m.home)
+	return m.repo
+}
+
+func (m *machine) write(rel, content string) {
+	path := filepath.Join(m.repo.EnvsDir, filepath.FromSlash(rel))
+	require.NoError(m.t, os.MkdirAll(filepath.Dir(path), 0700))
+	require.NoError(m.t, os.WriteFile(path, []byte(content), 0600))
+}
+
+func (m *machine) read(rel string) string {
+	data, err := os.ReadFile(filepath.Join(m.repo.EnvsDir, filepath.FromSlash(rel)))
+	require.NoError(m.t, err)
+	return string(data)
+}
+
+func newRemote(t *testing.T) string {
+	if !IsAvailable() {
+		t.Skip("git is not installed")
+	}
+	remote := filepath.Join(t.TempDir(), "remote.git")
+	require.NoError(t, exec.Command("git", "init", "-q", "--bare", remote).Run())
+	return remote
+}
+
+func TestSync(t *testing.T) {
+	remote := newRemote(t)
+	laptop := newMachine(t, remote, nil)
+	desktop := newMachine(t, remote, nil)
+
+	laptop.write("work/metadata.yaml", "name: work\n")
+	laptop.write("work/snapshots/git/gitconfig", "[user]\n\tname = Work\n")
+	laptop.write("client/prod/metadata.yaml", "name: client/prod\n")
+
+	_, err := laptop.use().Init()
+	require.NoError(t, err)
+	pushed, err := laptop.repo.Push()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"client/prod", "work"}, pushed)
+
+	pushed, err = laptop.repo.Push()
+	require.NoError(t, err)
+	assert.Empty(t, pushed, "nothing left to push")
+
+	t.Run("init copies the remote environments", func(t *testing.T) {
+		desktop.write("personal/metadata.yaml", "name: personal\n")
+
+		imported, err := desktop.use().Init()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"client/prod", "work"}, imported)
+		assert.Equal(t, "[user]\n\tname = Work\n", desktop.read("work/snapshots/git/gitconfig"))
+
+		status, err := desktop.repo.Status()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"personal"}, status.LocalChanges)
+		assert.Empty(t, status.RemoteChanges)
+	})
+
+	t.Run("pulls the changes of another machine", func(t *testing.T) {
+		desktop.write("work/metadata.yaml", "name: work\ndescription: updated\n")
+		desktop.write("work/snapshots/git/gitconfig", "[user]\n\tname = Desktop\n")
+		_, err := desktop.use().Push()
+		require.NoError(t, err)
+
+		status, err := laptop.use().Status()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"personal", "work"}, status.RemoteChanges)
+		assert.Empty(t, status.Conflicts)
+
+		_, err = laptop.repo.Push()
+		assert.ErrorContains(t, err, "run 'envswitch sync pull' first")
+
+		pulled, err := laptop.repo.Pull(false)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"personal", "work"}, pulled)
+		assert.Equal(t, "[user]\n\tname = Desktop\n", laptop.read("work/snapshots/git/gitconfig"))
+		assert.Equal(t, "name: personal\n", laptop.read("personal/metadata.yaml"))
+	})
+
+	t.Run("detects conflicting metadata", func(t *testing.T) {
+		desktop.write("work/metadata.yaml", "name: work\ndescription: desktop\n")
+		_, err := desktop.use().Pull(false)
+		require.NoError(t, err)
+		_, err = desktop.repo.Push()
+		require.NoError(t, err)
+
+		laptop.write("work/metadata.yaml", "name: work\ndescription: laptop\n")
+		status, err := laptop.use().Status()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"work"}, status.Conflicts)
+
+		_, err = laptop.repo.Pull(false)
+		var conflict *ConflictError
+		require.ErrorAs(t, err, &conflict)
+		assert.Equal(t, []string{"work"}, conflict.Environments)
+		assert.Equal(t, "name: work\ndescription: laptop\n", laptop.read("work/metadata.yaml"), "a refused pull changes nothing")
+
+		pulled, err := laptop.repo.Pull(true)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"work"}, pulled)
+		assert.Equal(t, "name: work\ndescription: desktop\n", laptop.read("work/metadata.yaml"))
+	})
+
+	t.Run("deletes environments deleted on another machine", func(t *testing.T) {
+		require.NoError(t, os.RemoveAll(filepath.Join(laptop.repo.EnvsDir, "client")))
+		_, err := laptop.use().Push()
+		require.NoError(t, err)
+
+		pulled, err := desktop.use().Pull(false)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"client/prod"}, pulled)
+		assert.NoDirExists(t, filepath.Join(desktop.repo.EnvsDir, "client"))
+	})
+}
+
+func TestSyncEncrypted(t *testing.T) {
+	remote := newRemote(t)
+	key, err := GenerateKey(filepath.Join(t.TempDir(), KeyFileName))
+	require.NoError(t, err)
+
+	laptop := newMachine(t, remote, key)
+	laptop.write("work/metadata.yaml", "name: work\n")
+	laptop.write("work/snapshots/aws/credentials", "aws_secret_access_key = abc\n")
+
+	_, err = laptop.use().Init()
+	require.NoError(t, err)
+	_, err = laptop.repo.Push()
+	require.NoError(t, err)
+
+	workCopy := filepath.Join(laptop.repo.Dir, envsDirName, "work")
+	assert.FileExists(t, filepath.Join(workCopy, "metadata.yaml"), "metadata stays readable")
+	assert.NoFileExists(t, filepath.Join(workCopy, "snapshots", "aws", "credentials"))
+	sealed, err := os.ReadFile(filepath.Join(workCopy, "snapshots", "aws", "credentials.enc"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(sealed), "aws_secret_access_key")
+
+	t.Run("unchanged files are not pushed again", func(t *testing.T) {
+		pushed, err := laptop.repo.Push()
+		require.NoError(t, err)
+		assert.Empty(t, pushed)
+	})
+
+	t.Run("needs the key to pull", func(t *testing.T) {
+		withoutKey := newMachine(t, remote, nil)
+		_, err := withoutKey.use().Init()
+		assert.ErrorContains(t, err, "is encrypted")
+	})
+
+	t.Run("decrypts with the key", func(t *testing.T) {
+		desktop := newMachine(t, remote, key)
+		_, err := desktop.use().Init()
+		require.NoError(t, err)
+		assert.Equal(t, "aws_secret_access_key = abc\n", desktop.read("work/snapshots/aws/credentials"))
+	})
+}
+
+func TestRequiresInit(t *testing.T) {
+	repo := New(t.TempDir(), "unused", nil)
+	_, err := repo.Push()
+	assert.ErrorIs(t, err, ErrNotInitialized)
+	_, err = repo.Pull(false)
+	assert.ErrorIs(t, err, ErrNotInitialized)
+	_, err = repo.Status()
+	assert.ErrorIs(t, err, ErrNotInitialized)
+}
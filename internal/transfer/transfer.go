@@ -0,0 +1,400 @@
+// Package transfer streams exported environments from one machine to another,
+// through SSH or a one-time TCP channel secured by a pairing code.
+package transfer
+
+import (
+	"archive/tar"
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// codeAlphabet leaves out the characters read one for another: 0/O, 1/I/L, U/V
+	codeAlphabet = "ABCDEFGHJKMNPQRSTWXYZ23456789"
+	codeGroups   = 4
+	codeGroupLen = 4
+
+	saltSize = 16
+	// chunkSize is the most plaintext sealed in one frame
+	chunkSize = 64 * 1024
+	// maxFrameSize bounds the frames read, sealed chunks and their tag
+	maxFrameSize = chunkSize + 64
+	// finalFrame flags the length of the last frame of a stream
+	finalFrame = 1 << 31
+)
+
+// handshakeMagic starts the channel, followed by the salt
+var handshakeMagic = []byte("ESXFER1")
+
+// ErrWrongCode is returned when the two sides do not share the pairing code
+var ErrWrongCode = errors.New("wrong pairing code")
+
+// NewPairingCode returns a random code like ABCD-EFGH-JKMN-PQRS, about 78
+// bits that both sides need to open the channel
+func NewPairingCode() (string, error) {
+	groups := make([]string, codeGroups)
+	max := big.NewInt(int64(len(codeAlphabet)))
+	for i := range groups {
+		var group strings.Builder
+		for j := 0; j < codeGroupLen; j++ {
+			n, err := rand.Int(rand.Reader, max)
+			if err != nil {
+				return "", fmt.Errorf("failed to generate pairing code: %w", err)
+			}
+			group.WriteByte(codeAlphabet[n.Int64()])
+		}
+		groups[i] = group.String()
+	}
+	return strings.Join(groups, "-"), nil
+}
+
+// normalizeCode makes the code typed by the user comparable: case, dashes
+// and spaces do not matter
+func normalizeCode(code string) string {
+	code = strings.ToUpper(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// sessionKey derives the key of the channel from the pairing code and the
+// salt of the sender
+func sessionKey(code string, salt []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(normalizeCode(code)))
+	mac.Write([]byte("envswitch transfer"))
+	mac.Write(salt)
+	return mac.Sum(nil)
+}
+
+// proof is what the receiver sends to show it has the pairing code
+func proof(key, salt []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("receiver"))
+	mac.Write(salt)
+	return mac.Sum(nil)
+}
+
+// Serve waits on ln for a receiver with the pairing code, and returns a
+// writer encrypting to it. Receivers with a wrong code are turned away, up to
+// attempts times. Closing the writer ends the transfer.
+func Serve(ln net.Listener, code string, attempts int) (io.WriteCloser, error) {
+	for attempt := 0; attempt < attempts; attempt++ {
+		conn, err := ln.Accept()
+		if err != nil {
+			return nil, fmt.Errorf("no receiver connected: %w", err)
+		}
+
+		key, err := serverHandshake(conn, code)
+		if err != nil {
+			_ = conn.Close()
+			if errors.Is(err, ErrWrongCode) {
+				continue
+			}
+			return nil, err
+		}
+		writer, err := newSealWriter(conn, key)
+		if err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		return writer, nil
+	}
+	return nil, fmt.Errorf("%w given %d times, channel closed", ErrWrongCode, attempts)
+}
+
+func serverHandshake(conn net.Conn, code string) ([]byte, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	if _, err := conn.Write(append(append([]byte{}, handshakeMagic...), salt...)); err != nil {
+		return nil, fmt.Errorf("failed to greet receiver: %w", err)
+	}
+
+	key := sessionKey(code, salt)
+	received := make([]byte, sha256.Size)
+	if _, err := io.ReadFull(conn, received); err != nil {
+		return nil, fmt.Errorf("receiver left during the handshake: %w", err)
+	}
+	if !hmac.Equal(received, proof(key, salt)) {
+		_, _ = conn.Write([]byte{0})
+		return nil, ErrWrongCode
+	}
+	if _, err := conn.Write([]byte{1}); err != nil {
+		return nil, fmt.Errorf("failed to accept receiver: %w", err)
+	}
+	return key, nil
+}
+
+// Dial connects to the sender at addr with the pairing code, and returns a
+// reader decrypting what it sends
+func Dial(addr, code string) (io.ReadCloser, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+
+	greeting := make([]byte, len(handshakeMagic)+saltSize)
+	if _, err := io.ReadFull(conn, greeting); err != nil || string(greeting[:len(handshakeMagic)]) != string(handshakeMagic) {
+		_ = conn.Close()
+		return nil, fmt.Errorf("%s is not an envswitch sender", addr)
+	}
+	salt := greeting[len(handshakeMagic):]
+
+	key := sessionKey(code, salt)
+	if _, err := conn.Write(proof(key, salt)); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("failed to send pairing proof: %w", err)
+	}
+	accepted := make([]byte, 1)
+	if _, err := io.ReadFull(conn, accepted); err != nil || accepted[0] != 1 {
+		_ = conn.Close()
+		return nil, ErrWrongCode
+	}
+
+	reader, err := newOpenReader(conn, key)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return reader, nil
+}
+
+// sealWriter encrypts a stream in frames: a big-endian uint32 length, then
+// a chunk sealed with AES-GCM. The nonce counts the frames, and the last one
+// is flagged, in its length and authenticated data, so that a cut stream is
+// detected.
+type sealWriter struct {
+	conn    io.WriteCloser
+	aead    cipher.AEAD
+	counter uint64
+	buf     []byte
+}
+
+func newSealWriter(conn io.WriteCloser, key []byte) (*sealWriter, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	return &sealWriter{conn: conn, aead: aead, buf: make([]byte, 0, chunkSize)}, nil
+}
+
+func (w *sealWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := copy(w.buf[len(w.buf):chunkSize], p)
+		w.buf = w.buf[:len(w.buf)+n]
+		p = p[n:]
+		written += n
+		if len(w.buf) == chunkSize {
+			if err := w.flush(false); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// Close sends the last frame and closes the connection
+func (w *sealWriter) Close() error {
+	err := w.flush(true)
+	if closeErr := w.conn.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (w *sealWriter) flush(final bool) error {
+	sealed := w.aead.Seal(nil, frameNonce(w.aead, w.counter), w.buf, frameFlag(final))
+	w.counter++
+	w.buf = w.buf[:0]
+
+	length := uint32(len(sealed))
+	if final {
+		length |= finalFrame
+	}
+	var header [4]byte
+	binary.BigEndian.PutUint32(header[:], length)
+	if _, err := w.conn.Write(append(header[:], sealed...)); err != nil {
+		return fmt.Errorf("failed to send: %w", err)
+	}
+	return nil
+}
+
+// openReader decrypts the frames of a sealWriter
+type openReader struct {
+	conn    io.ReadCloser
+	r       *bufio.Reader
+	aead    cipher.AEAD
+	counter uint64
+	plain   []byte
+	done    bool
+}
+
+func newOpenReader(conn io.ReadCloser, key []byte) (*openReader, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	return &openReader{conn: conn, r: bufio.NewReader(conn), aead: aead}, nil
+}
+
+func (r *openReader) Read(p []byte) (int, error) {
+	for len(r.plain) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.plain)
+	r.plain = r.plain[n:]
+	return n, nil
+}
+
+func (r *openReader) next() error {
+	var header [4]byte
+	if _, err := io.ReadFull(r.r, header[:]); err != nil {
+		return fmt.Errorf("transfer interrupted: %w", io.ErrUnexpectedEOF)
+	}
+	length := binary.BigEndian.Uint32(header[:])
+	final, size := length&finalFrame != 0, length&^finalFrame
+	if size > maxFrameSize {
+		return fmt.Errorf("invalid transfer frame of %d bytes", size)
+	}
+	sealed := make([]byte, size)
+	if _, err := io.ReadFull(r.r, sealed); err != nil {
+		return fmt.Errorf("transfer interrupted: %w", io.ErrUnexpectedEOF)
+	}
+
+	plain, err := r.aead.Open(nil, frameNonce(r.aead, r.counter), sealed, frameFlag(final))
+	if err != nil {
+		return fmt.Errorf("corrupted transfer frame")
+	}
+	r.counter++
+	r.plain, r.done = plain, final
+	return nil
+}
+
+func (r *openReader) Close() error {
+	return r.conn.Close()
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func frameNonce(aead cipher.AEAD, counter uint64) []byte {
+	nonce := make([]byte, aead.NonceSize())
+	binary.BigEndian.PutUint64(nonce[len(nonce)-8:], counter)
+	return nonce
+}
+
+func frameFlag(final bool) []byte {
+	if final {
+		return []byte{1}
+	}
+	return []byte{0}
+}
+
+// WriteBundle writes the files at paths to w as a tar stream, under their
+// base names
+func WriteBundle(w io.Writer, paths []string) error {
+	tw := tar.NewWriter(w)
+	for _, path := range paths {
+		if err := addBundleFile(tw, path); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+func addBundleFile(tw *tar.Writer, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	header := &tar.Header{Name: filepath.Base(path), Mode: 0600, Size: info.Size(), ModTime: info.ModTime()}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to send %s: %w", filepath.Base(path), err)
+	}
+	if _, err := io.Copy(tw, file); err != nil {
+		return fmt.Errorf("failed to send %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
+
+// ReadBundle extracts a bundle written by WriteBundle into dir, and returns
+// the paths of the files
+func ReadBundle(r io.Reader, dir string) ([]string, error) {
+	var paths []string
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return paths, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read transfer: %w", err)
+		}
+
+		name := filepath.Base(filepath.Clean(header.Name))
+		if header.Typeflag != tar.TypeReg || name != header.Name || name == "." || name == ".." {
+			return nil, fmt.Errorf("unexpected entry '%s' in transfer", header.Name)
+		}
+
+		path := filepath.Join(dir, name)
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err != nil {
+			return nil, err
+		}
+		_, copyErr := io.Copy(file, tr)
+		if closeErr := file.Close(); copyErr == nil {
+			copyErr = closeErr
+		}
+		if copyErr != nil {
+			return nil, fmt.Errorf("failed to receive %s: %w", name, copyErr)
+		}
+		paths = append(paths, path)
+	}
+}
+
+// LocalAddresses returns the IPv4 addresses of the machine a receiver on the
+// network may connect to
+func LocalAddresses() []string {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil
+	}
+	var ips []string
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || ipNet.IP.IsLoopback() || ipNet.IP.To4() == nil {
+			continue
+		}
+		ips = append(ips, ipNet.IP.String())
+	}
+	return ips
+}
@@ -0,0 +1,145 @@
+package transfer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPairingCode(t *testing.T) {
+	code, err := NewPairingCode()
+	require.NoError(t, err)
+	assert.Regexp(t, regexp.MustCompile(`^[`+codeAlphabet+`]{4}(-[`+codeAlphabet+`]{4}){3}$`), code)
+
+	other, err := NewPairingCode()
+	require.NoError(t, err)
+	assert.NotEqual(t, code, other)
+}
+
+// serve runs Serve on a local port and sends data to the receiver
+func serve(t *testing.T, code string, attempts int, data []byte) (string, chan error) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = ln.Close() })
+
+	done := make(chan error, 1)
+	go func() {
+		w, err := Serve(ln, code, attempts)
+		if err != nil {
+			done <- err
+			return
+		}
+		if _, err := w.Write(data); err != nil {
+			done <- err
+			return
+		}
+		done <- w.Close()
+	}()
+	return ln.Addr().String(), done
+}
+
+func TestServeDial(t *testing.T) {
+	// Several frames, the last one partial
+	data := make([]byte, 2*chunkSize+123)
+	_, err := rand.Read(data)
+	require.NoError(t, err)
+
+	t.Run("transfers with the pairing code", func(t *testing.T) {
+		addr, done := serve(t, "ABCD-EFGH-JKMN-PQRS", 1, data)
+
+		r, err := Dial(addr, "abcd efgh jkmn pqrs")
+		require.NoError(t, err)
+		received, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.NoError(t, r.Close())
+
+		assert.Equal(t, data, received)
+		assert.NoError(t, <-done)
+	})
+
+	t.Run("turns away a wrong code", func(t *testing.T) {
+		addr, done := serve(t, "ABCD-EFGH-JKMN-PQRS", 2, data)
+
+		_, err := Dial(addr, "ABCD-EFGH-JKMN-PQRT")
+		assert.ErrorIs(t, err, ErrWrongCode)
+
+		// The channel stays open for the next attempt
+		r, err := Dial(addr, "ABCD-EFGH-JKMN-PQRS")
+		require.NoError(t, err)
+		received, err := io.ReadAll(r)
+		require.NoError(t, err)
+		assert.Equal(t, data, received)
+		assert.NoError(t, <-done)
+	})
+
+	t.Run("closes after too many wrong codes", func(t *testing.T) {
+		addr, done := serve(t, "ABCD-EFGH-JKMN-PQRS", 1, data)
+
+		_, err := Dial(addr, "WRONG")
+		assert.ErrorIs(t, err, ErrWrongCode)
+		assert.ErrorIs(t, <-done, ErrWrongCode)
+	})
+}
+
+// nopCloser is a buffer standing for a connection
+type nopCloser struct{ *bytes.Buffer }
+
+func (nopCloser) Close() error { return nil }
+
+func TestSealedStream(t *testing.T) {
+	key := sessionKey("code", []byte("salt"))
+	var sent bytes.Buffer
+	w, err := newSealWriter(nopCloser{&sent}, key)
+	require.NoError(t, err)
+	_, err = w.Write(bytes.Repeat([]byte("x"), chunkSize+10))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	stream := sent.Bytes()
+
+	t.Run("detects a cut stream", func(t *testing.T) {
+		r, err := newOpenReader(nopCloser{bytes.NewBuffer(stream[:chunkSize+20])}, key)
+		require.NoError(t, err)
+		_, err = io.ReadAll(r)
+		assert.True(t, errors.Is(err, io.ErrUnexpectedEOF))
+	})
+
+	t.Run("detects a tampered frame", func(t *testing.T) {
+		tampered := append([]byte{}, stream...)
+		tampered[10] ^= 1
+		r, err := newOpenReader(nopCloser{bytes.NewBuffer(tampered)}, key)
+		require.NoError(t, err)
+		_, err = io.ReadAll(r)
+		assert.ErrorContains(t, err, "corrupted")
+	})
+}
+
+func TestBundle(t *testing.T) {
+	src := t.TempDir()
+	var paths []string
+	for name, content := range map[string]string{"work.tar.gz": "work", "client-a__prod.tar.gz": "prod"} {
+		path := filepath.Join(src, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+		paths = append(paths, path)
+	}
+
+	var bundle bytes.Buffer
+	require.NoError(t, WriteBundle(&bundle, paths))
+
+	dst := t.TempDir()
+	received, err := ReadBundle(&bundle, dst)
+	require.NoError(t, err)
+	require.Len(t, received, 2)
+	data, err := os.ReadFile(filepath.Join(dst, "work.tar.gz"))
+	require.NoError(t, err)
+	assert.Equal(t, "work", string(data))
+}
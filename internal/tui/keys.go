@@ -0,0 +1,83 @@
+package tui
+
+import (
+	"io"
+	"unicode"
+	"unicode/utf8"
+)
+
+// KeyKind is the kind of a key press
+type KeyKind int
+
+// Kinds of keys
+const (
+	KeyRune KeyKind = iota
+	KeyUp
+	KeyDown
+	KeyEnter
+	KeyBackspace
+	KeyClear
+	KeyCancel
+)
+
+// Key is a key press
+type Key struct {
+	Kind KeyKind
+	// Rune is the character typed, for KeyRune
+	Rune rune
+}
+
+// ReadKeys waits for keys and returns those typed
+func ReadKeys(r io.Reader) ([]Key, error) {
+	buf := make([]byte, 64)
+	n, err := r.Read(buf)
+	if n == 0 && err != nil {
+		return nil, err
+	}
+	return ParseKeys(buf[:n]), nil
+}
+
+// ParseKeys decodes the key presses read from a terminal in raw mode
+func ParseKeys(data []byte) []Key {
+	var keys []Key
+	for len(data) > 0 {
+		switch data[0] {
+		case 3, 4: // Ctrl-C, Ctrl-D
+			keys = append(keys, Key{Kind: KeyCancel})
+		case '\r', '\n':
+			keys = append(keys, Key{Kind: KeyEnter})
+		case 127, 8: // Backspace
+			keys = append(keys, Key{Kind: KeyBackspace})
+		case 21: // Ctrl-U
+			keys = append(keys, Key{Kind: KeyClear})
+		case 16: // Ctrl-P
+			keys = append(keys, Key{Kind: KeyUp})
+		case 14: // Ctrl-N
+			keys = append(keys, Key{Kind: KeyDown})
+		case 27:
+			// Arrows are ESC [ A or ESC O A, a lone ESC leaves
+			if len(data) >= 3 && (data[1] == '[' || data[1] == 'O') {
+				switch data[2] {
+				case 'A':
+					keys = append(keys, Key{Kind: KeyUp})
+				case 'B':
+					keys = append(keys, Key{Kind: KeyDown})
+				}
+				data = data[3:]
+				continue
+			}
+			if len(data) == 1 {
+				keys = append(keys, Key{Kind: KeyCancel})
+			}
+		default:
+			r, size := utf8.DecodeRune(data)
+			if unicode.IsPrint(r) {
+				keys = append(keys, Key{Kind: KeyRune, Rune: r})
+			}
+			data = data[size:]
+			continue
+		}
+		data = data[1:]
+	}
+	return keys
+}
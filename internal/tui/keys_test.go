@@ -0,0 +1,20 @@
+package tui
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseKeys(t *testing.T) {
+	keys := ParseKeys([]byte("a\x1b[B\x1bOA\x7f\x15\r"))
+	kinds := make([]KeyKind, 0, len(keys))
+	for _, k := range keys {
+		kinds = append(kinds, k.Kind)
+	}
+	assert.Equal(t, []KeyKind{KeyRune, KeyDown, KeyUp, KeyBackspace, KeyClear, KeyEnter}, kinds)
+	assert.Equal(t, 'a', keys[0].Rune)
+
+	assert.Equal(t, []Key{{Kind: KeyCancel}}, ParseKeys([]byte{27}))
+	assert.Equal(t, []Key{{Kind: KeyRune, Rune: 'é'}}, ParseKeys([]byte("é")))
+}
@@ -0,0 +1,60 @@
+// Package tui gives the interactive commands raw access to the terminal and
+// decodes the keys typed in it.
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrNotInteractive is returned when there is no terminal to draw on
+var ErrNotInteractive = errors.New("not running in an interactive terminal")
+
+// Terminal is the terminal interactive commands read keys from and draw
+// on. It is the controlling terminal rather than stdin and stdout, which the
+// shell integration captures.
+type Terminal struct {
+	In, Out *os.File
+	// makeRaw switches the terminal to raw mode, restore back to the mode
+	// it had when opened
+	makeRaw func() error
+	restore func()
+}
+
+// Open opens the terminal in raw mode: keys are read one at a time, without
+// echo
+func Open() (*Terminal, error) {
+	t, err := openTerminal()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotInteractive, err)
+	}
+	if err := t.makeRaw(); err != nil {
+		t.closeFiles()
+		return nil, fmt.Errorf("%w: %v", ErrNotInteractive, err)
+	}
+	return t, nil
+}
+
+// Suspend restores the mode the terminal had, for commands printing to it
+func (t *Terminal) Suspend() {
+	t.restore()
+}
+
+// Resume switches the terminal back to raw mode
+func (t *Terminal) Resume() error {
+	return t.makeRaw()
+}
+
+// Close restores the terminal and closes it
+func (t *Terminal) Close() {
+	t.restore()
+	t.closeFiles()
+}
+
+func (t *Terminal) closeFiles() {
+	_ = t.In.Close()
+	if t.Out != t.In {
+		_ = t.Out.Close()
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly && !windows
+
+package tui
+
+import "fmt"
+
+func openTerminal() (*Terminal, error) {
+	return nil, fmt.Errorf("terminals are not supported on this platform")
+}
@@ -0,0 +1,49 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package tui
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+func openTerminal() (*Terminal, error) {
+	if _, err := unix.IoctlGetTermios(int(os.Stdin.Fd()), ioctlGetTermios); err != nil {
+		return nil, fmt.Errorf("stdin is not a terminal")
+	}
+
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	fd := int(tty.Fd())
+	original, err := unix.IoctlGetTermios(fd, ioctlGetTermios)
+	if err != nil {
+		_ = tty.Close()
+		return nil, err
+	}
+
+	// Raw input, one key at a time without echo. Output processing is kept so
+	// that newlines return to the first column.
+	raw := *original
+	raw.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON
+	raw.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+	raw.Cflag &^= unix.CSIZE | unix.PARENB
+	raw.Cflag |= unix.CS8
+	raw.Cc[unix.VMIN] = 1
+	raw.Cc[unix.VTIME] = 0
+
+	return &Terminal{
+		In:  tty,
+		Out: tty,
+		makeRaw: func() error {
+			return unix.IoctlSetTermios(fd, ioctlSetTermios, &raw)
+		},
+		restore: func() {
+			_ = unix.IoctlSetTermios(fd, ioctlSetTermios, original)
+		},
+	}, nil
+}
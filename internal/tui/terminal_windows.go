@@ -0,0 +1,55 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+func openTerminal() (*Terminal, error) {
+	var mode uint32
+	if err := windows.GetConsoleMode(windows.Handle(os.Stdin.Fd()), &mode); err != nil {
+		return nil, fmt.Errorf("stdin is not a console")
+	}
+
+	in, err := os.OpenFile("CONIN$", os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	out, err := os.OpenFile("CONOUT$", os.O_RDWR, 0)
+	if err != nil {
+		_ = in.Close()
+		return nil, err
+	}
+
+	inHandle, outHandle := windows.Handle(in.Fd()), windows.Handle(out.Fd())
+	var inMode, outMode uint32
+	if err := windows.GetConsoleMode(inHandle, &inMode); err != nil {
+		_ = in.Close()
+		_ = out.Close()
+		return nil, err
+	}
+	if err := windows.GetConsoleMode(outHandle, &outMode); err != nil {
+		_ = in.Close()
+		_ = out.Close()
+		return nil, err
+	}
+
+	return &Terminal{
+		In:  in,
+		Out: out,
+		// Keys as escape sequences without echo, and escape sequences drawn
+		makeRaw: func() error {
+			rawIn := inMode&^(windows.ENABLE_ECHO_INPUT|windows.ENABLE_LINE_INPUT|windows.ENABLE_PROCESSED_INPUT) | windows.ENABLE_VIRTUAL_TERMINAL_INPUT
+			if err := windows.SetConsoleMode(inHandle, rawIn); err != nil {
+				return err
+			}
+			return windows.SetConsoleMode(outHandle, outMode|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING)
+		},
+		restore: func() {
+			_ = windows.SetConsoleMode(inHandle, inMode)
+			_ = windows.SetConsoleMode(outHandle, outMode)
+		},
+	}, nil
+}
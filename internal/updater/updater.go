@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"os"
+	"path/filepath"
 	"runtime"
 	"strings"
 	"time"
@@ -14,8 +16,11 @@ import (
 
 const (
 	defaultGitHubAPIURL = "https://api.github.com/repos/hugofrely/envswitch/releases/latest"
-	updateCheckFile     = ".last_update_check"
 	checkInterval       = 24 * time.Hour // Check once per day
+
+	// CacheFileName is the file of the envswitch directory holding the
+	// result of the last update check
+	CacheFileName = "update-check.json"
 )
 
 // apiURL is the GitHub API URL used for fetching releases
@@ -134,9 +139,98 @@ func GetUpdateCommand() string {
 	return "curl -fsSL https://raw.githubusercontent.com/hugofrely/envswitch/main/install.sh | bash"
 }
 
-// ShouldCheckForUpdate determines if we should check for updates based on last check time
+// Cache is the result of the last update check
+type Cache struct {
+	CheckedAt     time.Time `json:"checked_at"`
+	LatestVersion string    `json:"latest_version,omitempty"`
+	ReleaseURL    string    `json:"release_url,omitempty"`
+	DownloadURL   string    `json:"download_url,omitempty"`
+}
+
+// LoadCache returns the result of the last update check, nil when there is
+// none or it cannot be read
+func LoadCache(configDir string) *Cache {
+	data, err := os.ReadFile(filepath.Join(configDir, CacheFileName))
+	if err != nil {
+		return nil
+	}
+	var cache Cache
+	if err := json.Unmarshal(data, &cache); err != nil {
+		return nil
+	}
+	return &cache
+}
+
+// ShouldCheckForUpdate reports whether the last update check is more than a
+// day old
 func ShouldCheckForUpdate(configDir string) bool {
-	// For now, always return true. You can implement caching logic here
-	// by storing the last check time in configDir/.last_update_check
-	return true
+	cache := LoadCache(configDir)
+	return cache == nil || time.Since(cache.CheckedAt) >= checkInterval
+}
+
+// CachedUpdate returns the update found by the last check, nil when it found
+// none newer than the running version
+func CachedUpdate(configDir string) *UpdateInfo {
+	if version.Version == version.DevVersion {
+		return nil
+	}
+	cache := LoadCache(configDir)
+	if cache == nil || cache.LatestVersion == "" || cache.LatestVersion == strings.TrimPrefix(version.Version, "v") {
+		return nil
+	}
+	return &UpdateInfo{
+		Available:      true,
+		CurrentVersion: version.Version,
+		LatestVersion:  cache.LatestVersion,
+		DownloadURL:    cache.DownloadURL,
+		ReleaseURL:     cache.ReleaseURL,
+	}
+}
+
+// RefreshCache checks for an update and records the result. A failed check
+// is recorded too, keeping the previous result, so a machine offline is not
+// checked on every command.
+func RefreshCache(configDir string) error {
+	cache := LoadCache(configDir)
+	if cache == nil {
+		cache = &Cache{}
+	}
+	cache.CheckedAt = time.Now()
+
+	info, err := CheckForUpdate()
+	if err == nil {
+		cache.LatestVersion = info.LatestVersion
+		cache.ReleaseURL = info.ReleaseURL
+		cache.DownloadURL = info.DownloadURL
+	}
+	if saveErr := saveCache(configDir, cache); saveErr != nil && err == nil {
+		err = saveErr
+	}
+	return err
+}
+
+// saveCache writes the cache through a temporary file, the process may exit
+// while the check still runs
+func saveCache(configDir string, cache *Cache) error {
+	data, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(configDir, 0700); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(configDir, "."+CacheFileName+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(configDir, CacheFileName))
 }
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"runtime"
 	"testing"
 	"time"
@@ -189,9 +191,56 @@ func TestGetUpdateCommand(t *testing.T) {
 }
 
 func TestShouldCheckForUpdate(t *testing.T) {
-	// Currently always returns true
-	result := ShouldCheckForUpdate("/tmp/test")
-	assert.True(t, result)
+	configDir := t.TempDir()
+	assert.True(t, ShouldCheckForUpdate(configDir), "never checked")
+
+	require.NoError(t, saveCache(configDir, &Cache{CheckedAt: time.Now().Add(-time.Hour)}))
+	assert.False(t, ShouldCheckForUpdate(configDir), "checked an hour ago")
+
+	require.NoError(t, saveCache(configDir, &Cache{CheckedAt: time.Now().Add(-25 * time.Hour)}))
+	assert.True(t, ShouldCheckForUpdate(configDir), "checked yesterday")
+
+	require.NoError(t, os.WriteFile(filepath.Join(configDir, CacheFileName), []byte("{"), 0600))
+	assert.True(t, ShouldCheckForUpdate(configDir), "unreadable cache")
+}
+
+func TestRefreshCache(t *testing.T) {
+	oldVersion := version.Version
+	version.Version = "1.0.0"
+	defer func() { version.Version = oldVersion }()
+
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(Release{TagName: "v1.1.0", HTMLURL: "https://example.com/v1.1.0"})
+	}))
+	defer server.Close()
+	oldAPIURL := apiURL
+	apiURL = server.URL
+	defer func() { apiURL = oldAPIURL }()
+
+	configDir := t.TempDir()
+	assert.Nil(t, CachedUpdate(configDir))
+
+	require.NoError(t, RefreshCache(configDir))
+	assert.False(t, ShouldCheckForUpdate(configDir))
+	info := CachedUpdate(configDir)
+	require.NotNil(t, info)
+	assert.Equal(t, "1.0.0", info.CurrentVersion)
+	assert.Equal(t, "1.1.0", info.LatestVersion)
+	assert.Equal(t, "https://example.com/v1.1.0", info.ReleaseURL)
+
+	t.Run("a failed check keeps the previous result", func(t *testing.T) {
+		status = http.StatusInternalServerError
+		assert.Error(t, RefreshCache(configDir))
+		assert.False(t, ShouldCheckForUpdate(configDir))
+		require.NotNil(t, CachedUpdate(configDir))
+	})
+
+	t.Run("no update once running the latest version", func(t *testing.T) {
+		version.Version = "v1.1.0"
+		assert.Nil(t, CachedUpdate(configDir))
+	})
 }
 
 func TestUpdateInfo(t *testing.T) {
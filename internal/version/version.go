@@ -1,5 +1,10 @@
 package version
 
+import (
+	"strconv"
+	"strings"
+)
+
 const (
 	// DevVersion is the default version string for development builds
 	DevVersion = "dev"
@@ -11,3 +16,52 @@ var (
 	GitCommit = "unknown"
 	BuildDate = "unknown"
 )
+
+// Compare compares two dotted versions such as "1.4" and "v1.10.2", returning
+// -1, 0 or 1. Missing parts count as 0 and pre-release suffixes are ignored.
+func Compare(a, b string) int {
+	aParts, bParts := versionParts(a), versionParts(b)
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		var x, y int
+		if i < len(aParts) {
+			x = aParts[i]
+		}
+		if i < len(bParts) {
+			y = bParts[i]
+		}
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+	}
+	return 0
+}
+
+// Reached reports whether the running build is at or after target. Development
+// builds never reach a version.
+func Reached(target string) bool {
+	if Version == DevVersion {
+		return false
+	}
+	return Compare(Version, target) >= 0
+}
+
+// versionParts parses the numeric parts of a version
+func versionParts(v string) []int {
+	v = strings.TrimPrefix(v, "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+
+	var parts []int
+	for _, field := range strings.Split(v, ".") {
+		n, err := strconv.Atoi(field)
+		if err != nil {
+			break
+		}
+		parts = append(parts, n)
+	}
+	return parts
+}
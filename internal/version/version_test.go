@@ -43,3 +43,23 @@ func TestVersionDevDefault(t *testing.T) {
 		assert.Equal(t, "dev", Version)
 	}
 }
+
+func TestCompare(t *testing.T) {
+	assert.Equal(t, 0, Compare("1.4", "v1.4.0"))
+	assert.Equal(t, -1, Compare("1.4.2", "1.10"))
+	assert.Equal(t, 1, Compare("2.0.0-rc1", "1.99"))
+	assert.Equal(t, -1, Compare("0.9", "1"))
+}
+
+func TestReached(t *testing.T) {
+	oldVersion := Version
+	defer func() { Version = oldVersion }()
+
+	Version = DevVersion
+	assert.False(t, Reached("0.1"))
+
+	Version = "1.5.0"
+	assert.True(t, Reached("1.5"))
+	assert.True(t, Reached("1.4.9"))
+	assert.False(t, Reached("2.0"))
+}
@@ -0,0 +1,251 @@
+package environment
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hugofrely/envswitch/internal/storage"
+)
+
+// ChecksumsFile records the SHA-256 of every snapshot file, written each
+// time a tool is snapshotted so corrupted or tampered files can be detected
+const ChecksumsFile = "checksums.json"
+
+// Checksums maps the files of each tool snapshot, relative to the
+// environment directory with slashes, to their SHA-256
+type Checksums struct {
+	UpdatedAt time.Time                    `json:"updated_at"`
+	Tools     map[string]map[string]string `json:"tools"`
+}
+
+// ChecksumReport is the result of verifying the snapshots of an environment
+type ChecksumReport struct {
+	Tools []ToolChecksumReport
+}
+
+// ToolChecksumReport is the result of verifying the snapshot of one tool
+type ToolChecksumReport struct {
+	Tool       string
+	Recorded   bool // false when no checksums were recorded for the tool
+	Verified   int  // files matching their checksum
+	Modified   []string
+	Missing    []string
+	Unexpected []string // files added since the snapshot
+}
+
+// OK reports whether the snapshot matches its checksums
+func (r ToolChecksumReport) OK() bool {
+	return len(r.Modified) == 0 && len(r.Missing) == 0 && len(r.Unexpected) == 0
+}
+
+// OK reports whether every verified snapshot matches its checksums
+func (r *ChecksumReport) OK() bool {
+	for _, tool := range r.Tools {
+		if !tool.OK() {
+			return false
+		}
+	}
+	return true
+}
+
+// Failed returns the reports of the tools whose snapshot does not match
+func (r *ChecksumReport) Failed() []ToolChecksumReport {
+	var failed []ToolChecksumReport
+	for _, tool := range r.Tools {
+		if !tool.OK() {
+			failed = append(failed, tool)
+		}
+	}
+	return failed
+}
+
+// LoadChecksums reads the checksums of the environment, empty when none
+// were recorded
+func (e *Environment) LoadChecksums() (*Checksums, error) {
+	checksums := &Checksums{Tools: make(map[string]map[string]string)}
+
+	data, err := os.ReadFile(filepath.Join(e.Path, ChecksumsFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return checksums, nil
+		}
+		return nil, fmt.Errorf("failed to read checksums: %w", err)
+	}
+	if err := json.Unmarshal(data, checksums); err != nil {
+		return nil, fmt.Errorf("failed to parse checksums: %w", err)
+	}
+	if checksums.Tools == nil {
+		checksums.Tools = make(map[string]map[string]string)
+	}
+	return checksums, nil
+}
+
+func (e *Environment) saveChecksums(checksums *Checksums) error {
+	checksums.UpdatedAt = time.Now()
+	data, err := json.MarshalIndent(checksums, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal checksums: %w", err)
+	}
+	if err := storage.WriteFileAtomic(filepath.Join(e.Path, ChecksumsFile), data, 0600); err != nil {
+		return fmt.Errorf("failed to write checksums: %w", err)
+	}
+	return nil
+}
+
+// UpdateChecksums records the checksums of the snapshots just taken of
+// toolNames. The overlays of other machines keep their recorded checksums.
+func (e *Environment) UpdateChecksums(toolNames ...string) error {
+	if len(toolNames) == 0 {
+		return nil
+	}
+
+	checksums, err := e.LoadChecksums()
+	if err != nil {
+		// Unreadable checksums are replaced
+		checksums = &Checksums{Tools: make(map[string]map[string]string)}
+	}
+
+	hostPrefix := filepath.Base(e.HostSnapshotsDir()) + "/"
+	for _, toolName := range toolNames {
+		files := make(map[string]string)
+		for path, sum := range checksums.Tools[toolName] {
+			if strings.HasPrefix(path, hostSnapshotsPrefix) && !strings.HasPrefix(path, hostPrefix) {
+				files[path] = sum
+			}
+		}
+
+		dirs := []string{
+			filepath.Join(e.Path, "snapshots", toolName),
+			filepath.Join(e.HostSnapshotsDir(), toolName),
+		}
+		for _, dir := range dirs {
+			if err := hashSnapshotFiles(e.Path, dir, files); err != nil {
+				return err
+			}
+		}
+		checksums.Tools[toolName] = files
+	}
+
+	return e.saveChecksums(checksums)
+}
+
+// VerifyChecksums compares the snapshots of toolNames with their recorded
+// checksums, every tool with a snapshot or checksums when none is given
+func (e *Environment) VerifyChecksums(toolNames ...string) (*ChecksumReport, error) {
+	checksums, err := e.LoadChecksums()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(toolNames) == 0 {
+		toolNames = e.snapshotToolNames(checksums)
+	}
+
+	report := &ChecksumReport{}
+	for _, toolName := range toolNames {
+		toolReport, err := e.verifyTool(toolName, checksums)
+		if err != nil {
+			return nil, err
+		}
+		report.Tools = append(report.Tools, toolReport)
+	}
+	return report, nil
+}
+
+func (e *Environment) verifyTool(toolName string, checksums *Checksums) (ToolChecksumReport, error) {
+	report := ToolChecksumReport{Tool: toolName}
+	recorded, ok := checksums.Tools[toolName]
+	if !ok {
+		return report, nil
+	}
+	report.Recorded = true
+
+	// Snapshot directories of every machine
+	dirs := []string{filepath.Join(e.Path, "snapshots", toolName)}
+	for _, host := range e.ListSnapshotHosts() {
+		dirs = append(dirs, filepath.Join(e.Path, hostSnapshotsPrefix+host, toolName))
+	}
+	actual := make(map[string]string)
+	for _, dir := range dirs {
+		if err := hashSnapshotFiles(e.Path, dir, actual); err != nil {
+			return report, err
+		}
+	}
+
+	for path, sum := range recorded {
+		actualSum, exists := actual[path]
+		switch {
+		case !exists:
+			report.Missing = append(report.Missing, path)
+		case actualSum != sum:
+			report.Modified = append(report.Modified, path)
+		default:
+			report.Verified++
+		}
+	}
+	for path := range actual {
+		if _, exists := recorded[path]; !exists {
+			report.Unexpected = append(report.Unexpected, path)
+		}
+	}
+
+	sort.Strings(report.Modified)
+	sort.Strings(report.Missing)
+	sort.Strings(report.Unexpected)
+	return report, nil
+}
+
+// snapshotToolNames returns the tools with checksums or a snapshot directory
+func (e *Environment) snapshotToolNames(checksums *Checksums) []string {
+	names := make(map[string]bool)
+	for toolName := range checksums.Tools {
+		names[toolName] = true
+	}
+	if entries, err := os.ReadDir(filepath.Join(e.Path, "snapshots")); err == nil {
+		for _, entry := range entries {
+			if entry.IsDir() {
+				names[entry.Name()] = true
+			}
+		}
+	}
+
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// hashSnapshotFiles adds the SHA-256 of the files under dir to files, keyed
+// by their slash-separated path relative to root. A missing dir adds nothing.
+func hashSnapshotFiles(root, dir string, files map[string]string) error {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return nil
+	}
+
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		sum, err := storage.HashFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to hash %s: %w", path, err)
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = sum
+		return nil
+	})
+}
@@ -0,0 +1,69 @@
+package environment
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyChecksums(t *testing.T) {
+	env := &Environment{Name: "work", Path: t.TempDir()}
+	kubeDir := filepath.Join(env.Path, "snapshots", "kubectl")
+	writeSnapshotFile(t, filepath.Join(kubeDir, "config"), "context: work")
+	writeSnapshotFile(t, filepath.Join(kubeDir, "cache", "token"), "abc")
+	writeSnapshotFile(t, filepath.Join(env.Path, "snapshots", "npm", ".npmrc"), "registry")
+
+	require.NoError(t, env.UpdateChecksums("kubectl"))
+
+	report, err := env.VerifyChecksums()
+	require.NoError(t, err)
+	require.Len(t, report.Tools, 2)
+	assert.True(t, report.OK())
+	assert.Equal(t, "kubectl", report.Tools[0].Tool)
+	assert.Equal(t, 2, report.Tools[0].Verified)
+	assert.False(t, report.Tools[1].Recorded, "npm has no checksums yet")
+
+	writeSnapshotFile(t, filepath.Join(kubeDir, "config"), "context: prod")
+	require.NoError(t, os.Remove(filepath.Join(kubeDir, "cache", "token")))
+	writeSnapshotFile(t, filepath.Join(kubeDir, "extra"), "x")
+
+	report, err = env.VerifyChecksums("kubectl")
+	require.NoError(t, err)
+	require.False(t, report.OK())
+	failed := report.Failed()
+	require.Len(t, failed, 1)
+	assert.Equal(t, []string{"snapshots/kubectl/config"}, failed[0].Modified)
+	assert.Equal(t, []string{"snapshots/kubectl/cache/token"}, failed[0].Missing)
+	assert.Equal(t, []string{"snapshots/kubectl/extra"}, failed[0].Unexpected)
+
+	// A new snapshot records the new content
+	require.NoError(t, env.UpdateChecksums("kubectl"))
+	report, err = env.VerifyChecksums("kubectl")
+	require.NoError(t, err)
+	assert.True(t, report.OK())
+}
+
+func TestUpdateChecksumsKeepsOtherHosts(t *testing.T) {
+	env := &Environment{Name: "work", Path: t.TempDir(), HostScoped: []string{"kubectl"}}
+	writeSnapshotFile(t, filepath.Join(env.Path, "snapshots@laptop", "kubectl", "config"), "laptop")
+	writeSnapshotFile(t, filepath.Join(env.Path, "snapshots@desktop", "kubectl", "config"), "desktop")
+
+	stubHostname(t, "laptop")
+	require.NoError(t, env.UpdateChecksums("kubectl"))
+
+	// The desktop snapshot arrives through sync and is recorded there
+	stubHostname(t, "desktop")
+	require.NoError(t, env.UpdateChecksums("kubectl"))
+
+	checksums, err := env.LoadChecksums()
+	require.NoError(t, err)
+	assert.Len(t, checksums.Tools["kubectl"], 2)
+
+	report, err := env.VerifyChecksums("kubectl")
+	require.NoError(t, err)
+	assert.True(t, report.OK())
+	assert.Equal(t, 2, report.Tools[0].Verified)
+}
@@ -0,0 +1,7 @@
+package environment
+
+import "errors"
+
+// ErrCorrupted is returned when metadata.yaml or current.lock cannot be
+// trusted, typically after a crash or power loss with an older version
+var ErrCorrupted = errors.New("run 'envswitch doctor' to find and repair it")
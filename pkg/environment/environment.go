@@ -1,29 +1,46 @@
 package environment
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"gopkg.in/yaml.v3"
+
+	"github.com/hugofrely/envswitch/internal/platform"
+	"github.com/hugofrely/envswitch/internal/storage"
 )
 
 // Environment represents a saved development environment
 type Environment struct {
-	Name         string                `yaml:"name"`
-	Description  string                `yaml:"description"`
-	CreatedAt    time.Time             `yaml:"created_at"`
-	UpdatedAt    time.Time             `yaml:"updated_at"`
-	LastUsed     time.Time             `yaml:"last_used"`
-	LastSnapshot time.Time             `yaml:"last_snapshot"`
-	Tools        map[string]ToolConfig `yaml:"tools"`
-	EnvVars      map[string]string     `yaml:"environment_variables"`
-	Hooks        Hooks                 `yaml:"hooks,omitempty"`
-	Tags         []string              `yaml:"tags,omitempty"`
-	Metadata     MetadataInfo          `yaml:"metadata,omitempty"`
-	SnapshotInfo SnapshotInfo          `yaml:"snapshot_info,omitempty"`
-	Path         string                `yaml:"-"`
+	Name          string                `yaml:"name"`
+	Description   string                `yaml:"description"`
+	CreatedAt     time.Time             `yaml:"created_at"`
+	UpdatedAt     time.Time             `yaml:"updated_at"`
+	LastUsed      time.Time             `yaml:"last_used"`
+	LastSnapshot  time.Time             `yaml:"last_snapshot"`
+	Tools         map[string]ToolConfig `yaml:"tools"`
+	EnvVars       map[string]string     `yaml:"environment_variables"`
+	SecretEnvVars []string              `yaml:"secret_env_vars,omitempty"` // masked in list and show output
+	Hooks         Hooks                 `yaml:"hooks,omitempty"`
+	Keychain      []KeychainItem        `yaml:"keychain,omitempty"`
+	HostScoped    []string              `yaml:"host_scoped,omitempty"`   // tools or tool/path kept per machine
+	KeyringFiles  []string              `yaml:"keyring_files,omitempty"` // tool/path of snapshot files stored in the OS keyring
+	ShellHistory  string                `yaml:"shell_history,omitempty"` // "isolated" | "shared", default from config
+	Tags          []string              `yaml:"tags,omitempty"`
+	Protected     bool                  `yaml:"protected,omitempty"` // switch, delete and restore ask for confirmation
+	Git           GitSettings           `yaml:"git,omitempty"`
+	Kubectl       KubectlSettings       `yaml:"kubectl,omitempty"`
+	Plugins       map[string]bool       `yaml:"plugins,omitempty"` // plugins enabled or disabled here, unlisted ones are enabled
+	Metadata      MetadataInfo          `yaml:"metadata,omitempty"`
+	SnapshotInfo  SnapshotInfo          `yaml:"snapshot_info,omitempty"`
+	Path          string                `yaml:"-"`
 }
 
 // ToolConfig represents configuration for a specific tool
@@ -31,6 +48,28 @@ type ToolConfig struct {
 	Enabled      bool                   `yaml:"enabled"`
 	SnapshotPath string                 `yaml:"snapshot_path"`
 	Metadata     map[string]interface{} `yaml:"metadata,omitempty"`
+	LastSnapshot time.Time              `yaml:"last_snapshot,omitempty"`
+	SnapshotSize uint64                 `yaml:"snapshot_size,omitempty"` // bytes, as of LastSnapshot
+
+	// RestoreStrategy is how the snapshot is restored, RestoreStrategyReplace
+	// when empty
+	RestoreStrategy string `yaml:"restore_strategy,omitempty"`
+}
+
+// Values of ToolConfig.RestoreStrategy
+const (
+	RestoreStrategyReplace = "replace" // the live configuration becomes the snapshot
+	RestoreStrategyMerge   = "merge"   // the snapshot is merged into the live configuration
+)
+
+// ValidateRestoreStrategy checks a restore strategy, empty for the default
+func ValidateRestoreStrategy(strategy string) error {
+	switch strategy {
+	case "", RestoreStrategyReplace, RestoreStrategyMerge:
+		return nil
+	default:
+		return fmt.Errorf("invalid restore strategy '%s' (must be %s or %s)", strategy, RestoreStrategyReplace, RestoreStrategyMerge)
+	}
 }
 
 // Hooks represents pre/post hooks for environment operations
@@ -43,10 +82,44 @@ type Hooks struct {
 
 // Hook represents a single hook command or script
 type Hook struct {
-	Command     string `yaml:"command,omitempty"`
-	Script      string `yaml:"script,omitempty"`
-	Description string `yaml:"description,omitempty"`
-	Verify      bool   `yaml:"verify,omitempty"`
+	Command     string            `yaml:"command,omitempty"`
+	Script      string            `yaml:"script,omitempty"`
+	Action      string            `yaml:"action,omitempty"` // built-in action run instead of a command
+	With        map[string]string `yaml:"with,omitempty"`   // parameters of the action
+	Args        []string          `yaml:"args,omitempty"`   // program and arguments of the exec action
+	Description string            `yaml:"description,omitempty"`
+	Verify      bool              `yaml:"verify,omitempty"`
+	Timeout     string            `yaml:"timeout,omitempty"`    // duration such as "30s", no limit when empty
+	Cwd         string            `yaml:"cwd,omitempty"`        // working directory, ~ and $VARS are expanded
+	Env         map[string]string `yaml:"env,omitempty"`        // extra variables for the hook
+	OnFailure   string            `yaml:"on_failure,omitempty"` // abort (default), warn or ignore
+}
+
+// GitSettings are the git settings applied on top of the snapshot of the
+// git config when switching to the environment
+type GitSettings struct {
+	Includes []string `yaml:"includes,omitempty"` // files added to include.path of ~/.gitconfig
+
+	// In git_mode includeif, the repositories below Directories use the
+	// identity of the environment, its snapshot overridden by the fields below
+	Directories []string `yaml:"directories,omitempty"`
+	UserName    string   `yaml:"user_name,omitempty"`
+	UserEmail   string   `yaml:"user_email,omitempty"`
+	SigningKey  string   `yaml:"signing_key,omitempty"`
+}
+
+// KubectlSettings are the kubectl safety settings of the environment
+type KubectlSettings struct {
+	// Contexts the environment may use, as names or wildcard patterns such as
+	// staging-*; any context when empty
+	AllowedContexts []string `yaml:"allowed_contexts,omitempty"`
+}
+
+// KeychainItem is a macOS Keychain entry the user allowed envswitch to capture
+type KeychainItem struct {
+	Service string `yaml:"service"`
+	Account string `yaml:"account,omitempty"`
+	Kind    string `yaml:"kind,omitempty"` // "generic" (default) or "internet"
 }
 
 // MetadataInfo contains additional metadata about the environment
@@ -64,7 +137,7 @@ type SnapshotInfo struct {
 
 // GetEnvswitchDir returns the path to the .envswitch directory
 func GetEnvswitchDir() (string, error) {
-	home, err := os.UserHomeDir()
+	home, err := platform.HomeDir()
 	if err != nil {
 		return "", fmt.Errorf("failed to get home directory: %w", err)
 	}
@@ -87,7 +160,7 @@ func LoadEnvironment(name string) (*Environment, error) {
 		return nil, err
 	}
 
-	envPath := filepath.Join(envDir, name)
+	envPath := filepath.Join(envDir, resolveEnvironmentDir(envDir, name))
 	metadataPath := filepath.Join(envPath, "metadata.yaml")
 
 	data, err := os.ReadFile(metadataPath)
@@ -95,16 +168,22 @@ func LoadEnvironment(name string) (*Environment, error) {
 		return nil, fmt.Errorf("failed to read metadata: %w", err)
 	}
 
+	// A crash while an older version wrote it can leave it empty
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, fmt.Errorf("failed to parse metadata: %s is empty: %w", metadataPath, ErrCorrupted)
+	}
+
 	var env Environment
 	if err := yaml.Unmarshal(data, &env); err != nil {
-		return nil, fmt.Errorf("failed to parse metadata: %w", err)
+		return nil, fmt.Errorf("failed to parse metadata: %v: %w", err, ErrCorrupted)
 	}
 
 	env.Path = envPath
 	return &env, nil
 }
 
-// Save saves the environment metadata to disk
+// Save saves the environment metadata to disk. The file is replaced
+// atomically, a crash never leaves it half written.
 func (e *Environment) Save() error {
 	metadataPath := filepath.Join(e.Path, "metadata.yaml")
 
@@ -115,21 +194,22 @@ func (e *Environment) Save() error {
 		return fmt.Errorf("failed to marshal metadata: %w", err)
 	}
 
-	if err := os.WriteFile(metadataPath, data, 0644); err != nil {
+	if err := storage.WriteFileAtomic(metadataPath, data, 0600); err != nil {
 		return fmt.Errorf("failed to write metadata: %w", err)
 	}
 
 	return nil
 }
 
-// ListEnvironments returns all available environments
+// ListEnvironments returns all available environments, including the ones
+// inside groups
 func ListEnvironments() ([]*Environment, error) {
 	envDir, err := GetEnvironmentsDir()
 	if err != nil {
 		return nil, err
 	}
 
-	entries, err := os.ReadDir(envDir)
+	environments, err := listEnvironmentsIn(envDir, "", nil)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return []*Environment{}, nil
@@ -137,15 +217,69 @@ func ListEnvironments() ([]*Environment, error) {
 		return nil, fmt.Errorf("failed to read environments directory: %w", err)
 	}
 
+	return environments, nil
+}
+
+// InvalidEnvironment is an environment whose metadata cannot be loaded
+type InvalidEnvironment struct {
+	Name string
+	Err  error
+}
+
+// FindInvalidEnvironments returns the environments ListEnvironments skips
+// because their metadata.yaml cannot be read or parsed
+func FindInvalidEnvironments() ([]InvalidEnvironment, error) {
+	envDir, err := GetEnvironmentsDir()
+	if err != nil {
+		return nil, err
+	}
+
+	var invalid []InvalidEnvironment
+	if _, err := listEnvironmentsIn(envDir, "", &invalid); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read environments directory: %w", err)
+	}
+	return invalid, nil
+}
+
+// listEnvironmentsIn loads the environments below dir, descending into the
+// directories that are groups rather than environments. Environments that
+// fail to load are skipped, and added to invalid when it is not nil.
+func listEnvironmentsIn(dir, group string, invalid *[]InvalidEnvironment) ([]*Environment, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
 	var environments []*Environment
 	for _, entry := range entries {
 		if !entry.IsDir() {
 			continue
 		}
 
-		env, err := LoadEnvironment(entry.Name())
+		name := entry.Name()
+		if group != "" {
+			name = group + GroupSeparator + name
+		}
+
+		entryPath := filepath.Join(dir, entry.Name())
+		if !isEnvironmentDir(entryPath) {
+			if strings.HasPrefix(entry.Name(), ".") {
+				continue
+			}
+			grouped, err := listEnvironmentsIn(entryPath, name, invalid)
+			if err != nil {
+				continue
+			}
+			environments = append(environments, grouped...)
+			continue
+		}
+
+		env, err := LoadEnvironment(name)
 		if err != nil {
 			// Skip invalid environments
+			if invalid != nil {
+				*invalid = append(*invalid, InvalidEnvironment{Name: name, Err: err})
+			}
 			continue
 		}
 
@@ -155,33 +289,131 @@ func ListEnvironments() ([]*Environment, error) {
 	return environments, nil
 }
 
-// GetCurrentEnvironment returns the currently active environment
+const (
+	currentLockFile = "current.lock"
+	staleLockFile   = "current.lock.stale"
+)
+
+// GetCurrentEnvironment returns the currently active environment.
+// If current.lock points to an environment that no longer exists, the stale
+// pointer is quarantined to current.lock.stale and nil is returned.
 func GetCurrentEnvironment() (*Environment, error) {
 	dir, err := GetEnvswitchDir()
 	if err != nil {
 		return nil, err
 	}
 
-	lockPath := filepath.Join(dir, "current.lock")
+	lockPath := filepath.Join(dir, currentLockFile)
+	name, err := readCurrentLock(lockPath)
+	if err != nil || name == "" {
+		return nil, err
+	}
+
+	env, err := LoadEnvironment(name)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+
+		fmt.Fprintf(os.Stderr, "Warning: active environment '%s' no longer exists, continuing with no active environment\n", name)
+		if quarantineErr := os.Rename(lockPath, filepath.Join(dir, staleLockFile)); quarantineErr != nil {
+			_ = os.Remove(lockPath)
+		}
+		return nil, nil
+	}
+
+	return env, nil
+}
+
+// GetCurrentEnvironmentName returns the name recorded in current.lock without
+// loading the environment, an empty string when there is none
+func GetCurrentEnvironmentName() (string, error) {
+	dir, err := GetEnvswitchDir()
+	if err != nil {
+		return "", err
+	}
+
+	return readCurrentLock(filepath.Join(dir, currentLockFile))
+}
+
+// readCurrentLock returns the environment name recorded in current.lock, an
+// empty string when there is no such file. An empty or garbled file, as a
+// crash while an older version wrote it leaves behind, is an error.
+func readCurrentLock(lockPath string) (string, error) {
 	data, err := os.ReadFile(lockPath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return nil, nil
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to read %s: %w", currentLockFile, err)
+	}
+
+	name := strings.TrimSpace(string(data))
+	if name == "" {
+		return "", fmt.Errorf("%s is empty: %w", currentLockFile, ErrCorrupted)
+	}
+	if !utf8.ValidString(name) || strings.IndexFunc(name, unicode.IsControl) >= 0 {
+		return "", fmt.Errorf("%s does not hold an environment name: %w", currentLockFile, ErrCorrupted)
+	}
+	return name, nil
+}
+
+// GetStaleCurrentEnvironment returns the name of the environment referenced by
+// a quarantined current.lock, or an empty string if there is none
+func GetStaleCurrentEnvironment() (string, error) {
+	dir, err := GetEnvswitchDir()
+	if err != nil {
+		return "", err
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, staleLockFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
 		}
-		return nil, fmt.Errorf("failed to read current.lock: %w", err)
+		return "", fmt.Errorf("failed to read %s: %w", staleLockFile, err)
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+// ClearStaleCurrentEnvironment removes the quarantined current.lock, if any
+func ClearStaleCurrentEnvironment() error {
+	dir, err := GetEnvswitchDir()
+	if err != nil {
+		return err
 	}
 
-	name := string(data)
-	return LoadEnvironment(name)
+	if err := os.Remove(filepath.Join(dir, staleLockFile)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove %s: %w", staleLockFile, err)
+	}
+	return nil
 }
 
-// SetCurrentEnvironment sets the currently active environment
+// ClearCurrentEnvironment removes current.lock, leaving no active environment
+func ClearCurrentEnvironment() error {
+	dir, err := GetEnvswitchDir()
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(filepath.Join(dir, currentLockFile)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove %s: %w", currentLockFile, err)
+	}
+	return nil
+}
+
+// SetCurrentEnvironment sets the currently active environment, replacing
+// current.lock atomically
 func SetCurrentEnvironment(name string) error {
 	dir, err := GetEnvswitchDir()
 	if err != nil {
 		return err
 	}
 
-	lockPath := filepath.Join(dir, "current.lock")
-	return os.WriteFile(lockPath, []byte(name), 0644)
+	lockPath := filepath.Join(dir, currentLockFile)
+	if err := storage.WriteFileAtomic(lockPath, []byte(name), 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", currentLockFile, err)
+	}
+	return nil
 }
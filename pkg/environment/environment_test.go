@@ -3,6 +3,7 @@ package environment
 import (
 	"os"
 	"path/filepath"
+	"runtime"
 	"testing"
 	"time"
 
@@ -234,15 +235,86 @@ func TestCurrentEnvironment(t *testing.T) {
 		assert.Equal(t, "current-test", current.Name)
 	})
 
-	t.Run("returns error when current environment doesn't exist", func(t *testing.T) {
+	t.Run("quarantines lock when current environment doesn't exist", func(t *testing.T) {
 		err := SetCurrentEnvironment("non-existent")
 		require.NoError(t, err)
 
-		_, err = GetCurrentEnvironment()
-		assert.Error(t, err)
+		current, err := GetCurrentEnvironment()
+		require.NoError(t, err)
+		assert.Nil(t, current)
+
+		// The stale pointer is moved aside and remembered
+		assert.NoFileExists(t, filepath.Join(envswitchDir, "current.lock"))
+		stale, err := GetStaleCurrentEnvironment()
+		require.NoError(t, err)
+		assert.Equal(t, "non-existent", stale)
+
+		// Subsequent calls behave as "no active environment"
+		current, err = GetCurrentEnvironment()
+		require.NoError(t, err)
+		assert.Nil(t, current)
+
+		require.NoError(t, ClearStaleCurrentEnvironment())
+		stale, err = GetStaleCurrentEnvironment()
+		require.NoError(t, err)
+		assert.Empty(t, stale)
+	})
+
+	t.Run("reports a corrupted lock", func(t *testing.T) {
+		lockPath := filepath.Join(envswitchDir, "current.lock")
+		for _, content := range []string{"", "\x00\x00\x00"} {
+			require.NoError(t, os.WriteFile(lockPath, []byte(content), 0600))
+
+			_, err := GetCurrentEnvironment()
+			assert.ErrorIs(t, err, ErrCorrupted)
+			assert.ErrorContains(t, err, "envswitch doctor")
+		}
+		require.NoError(t, ClearCurrentEnvironment())
 	})
 }
 
+func TestLoadEnvironmentCorrupted(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+
+	envPath := filepath.Join(tempHome, ".envswitch", "environments", "work")
+	require.NoError(t, os.MkdirAll(envPath, 0700))
+
+	// An empty file, as a crash mid-write used to leave, and a garbled one
+	for _, content := range []string{"", "name: work\ntools: [\n"} {
+		require.NoError(t, os.WriteFile(filepath.Join(envPath, "metadata.yaml"), []byte(content), 0600))
+
+		_, err := LoadEnvironment("work")
+		assert.ErrorIs(t, err, ErrCorrupted)
+		assert.ErrorContains(t, err, "failed to parse metadata")
+	}
+}
+
+func TestSaveReplacesMetadataAtomically(t *testing.T) {
+	envPath := t.TempDir()
+	env := &Environment{Name: "work", Path: envPath}
+	require.NoError(t, env.Save())
+
+	env.Description = "Work"
+	require.NoError(t, env.Save())
+
+	data, err := os.ReadFile(filepath.Join(envPath, "metadata.yaml"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "description: Work")
+
+	info, err := os.Stat(filepath.Join(envPath, "metadata.yaml"))
+	require.NoError(t, err)
+	if runtime.GOOS != "windows" {
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	}
+
+	// No temporary file is left behind
+	entries, err := os.ReadDir(envPath)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "metadata.yaml", entries[0].Name())
+}
+
 func TestToolConfig(t *testing.T) {
 	t.Run("creates tool config with defaults", func(t *testing.T) {
 		config := ToolConfig{
@@ -3,37 +3,121 @@ package environment
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
+
+	"github.com/hugofrely/envswitch/internal/storage"
 )
 
 const envVarsFileName = "env-vars.env"
 
+// envVarKeyPattern matches the names shells accept for variables
+var envVarKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
 // EnvVar represents an environment variable
 type EnvVar struct {
 	Key   string `json:"key"`
 	Value string `json:"value"`
 }
 
-// CaptureEnvVars captures specified environment variables
-func CaptureEnvVars(varNames []string) ([]EnvVar, error) {
-	var envVars []EnvVar
+// CaptureEnvVars captures the set variables matching patterns, which are
+// names or globs like AWS_*. Variables matching a deny pattern are skipped,
+// unless patterns list them by their exact name.
+func CaptureEnvVars(patterns, deny []string) ([]EnvVar, error) {
+	for _, pattern := range append(append([]string{}, patterns...), deny...) {
+		if err := ValidateEnvVarPattern(pattern); err != nil {
+			return nil, err
+		}
+	}
+
+	explicit := make(map[string]bool)
+	for _, pattern := range patterns {
+		if !isEnvVarGlob(pattern) {
+			explicit[pattern] = true
+		}
+	}
 
-	for _, name := range varNames {
+	var envVars []EnvVar
+	seen := make(map[string]bool)
+	capture := func(name string) {
 		value := os.Getenv(name)
 		// Only capture if the variable is set
-		if value != "" {
-			envVars = append(envVars, EnvVar{
-				Key:   name,
-				Value: value,
-			})
+		if value == "" || seen[name] {
+			return
+		}
+		if !explicit[name] && matchesEnvVarPattern(name, deny) {
+			return
+		}
+		seen[name] = true
+		envVars = append(envVars, EnvVar{
+			Key:   name,
+			Value: value,
+		})
+	}
+
+	var environ []string
+	for _, pattern := range patterns {
+		if !isEnvVarGlob(pattern) {
+			capture(pattern)
+			continue
+		}
+		if environ == nil {
+			environ = environNames()
+		}
+		for _, name := range environ {
+			if matched, _ := path.Match(pattern, name); matched {
+				capture(name)
+			}
 		}
 	}
 
 	return envVars, nil
 }
 
+// ValidateEnvVarPattern checks a variable name or glob, like AWS_*
+func ValidateEnvVarPattern(pattern string) error {
+	if strings.TrimSpace(pattern) == "" {
+		return fmt.Errorf("empty variable pattern")
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid variable pattern %q: %w", pattern, err)
+	}
+	return nil
+}
+
+// isEnvVarGlob reports whether pattern matches variables by glob rather than
+// naming one
+func isEnvVarGlob(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?[\\")
+}
+
+// matchesEnvVarPattern reports whether name matches one of patterns
+func matchesEnvVarPattern(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// environNames returns the names of the variables of the process, sorted
+func environNames() []string {
+	var names []string
+	for _, entry := range os.Environ() {
+		if name, _, ok := strings.Cut(entry, "="); ok && name != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
 // SaveEnvVars saves environment variables to a file in the environment's snapshot directory
 func (e *Environment) SaveEnvVars(envVars []EnvVar) error {
 	if len(envVars) == 0 {
@@ -44,33 +128,52 @@ func (e *Environment) SaveEnvVars(envVars []EnvVar) error {
 
 	// Create snapshots directory if it doesn't exist
 	snapshotsDir := filepath.Dir(envFilePath)
-	if err := os.MkdirAll(snapshotsDir, 0755); err != nil {
+	if err := os.MkdirAll(snapshotsDir, 0700); err != nil {
 		return fmt.Errorf("failed to create snapshots directory: %w", err)
 	}
 
-	file, err := os.Create(envFilePath)
-	if err != nil {
-		return fmt.Errorf("failed to create env vars file: %w", err)
-	}
-	defer file.Close()
-
-	writer := bufio.NewWriter(file)
-	defer writer.Flush()
-
+	var content strings.Builder
 	for _, envVar := range envVars {
-		// Escape values that contain special characters
-		value := escapeEnvValue(envVar.Value)
-		line := fmt.Sprintf("%s=%s\n", envVar.Key, value)
-		if _, err := writer.WriteString(line); err != nil {
-			return fmt.Errorf("failed to write env var: %w", err)
+		value := envVar.Value
+		// Secret values are only written encrypted
+		if e.IsSecretEnvVar(envVar.Key) && !isSealedValue(value) {
+			sealed, err := sealSecretValue(envVar.Key, value)
+			if err != nil {
+				return fmt.Errorf("failed to encrypt %s: %w", envVar.Key, err)
+			}
+			value = sealed
 		}
+		// Escape values that contain special characters
+		fmt.Fprintf(&content, "%s=%s\n", envVar.Key, escapeEnvValue(value))
 	}
 
+	if err := storage.WriteFileAtomic(envFilePath, []byte(content.String()), 0600); err != nil {
+		return fmt.Errorf("failed to write env vars file: %w", err)
+	}
 	return nil
 }
 
-// LoadEnvVars loads environment variables from the environment's snapshot directory
+// LoadEnvVars loads environment variables from the environment's snapshot
+// directory, decrypting the values of secret variables
 func (e *Environment) LoadEnvVars() ([]EnvVar, error) {
+	envVars, err := e.loadSealedEnvVars()
+	if err != nil {
+		return nil, err
+	}
+	for i, envVar := range envVars {
+		if !isSealedValue(envVar.Value) {
+			continue
+		}
+		if envVars[i].Value, err = openSecretValue(envVar.Key, envVar.Value); err != nil {
+			return nil, err
+		}
+	}
+	return envVars, nil
+}
+
+// loadSealedEnvVars loads environment variables from the environment's
+// snapshot directory, leaving the values of secret variables encrypted
+func (e *Environment) loadSealedEnvVars() ([]EnvVar, error) {
 	envFilePath := filepath.Join(e.Path, "snapshots", envVarsFileName)
 
 	// If file doesn't exist, return empty slice (not an error)
@@ -84,8 +187,40 @@ func (e *Environment) LoadEnvVars() ([]EnvVar, error) {
 	}
 	defer file.Close()
 
+	return parseEnvVars(file)
+}
+
+// SealEnvVars rewrites the captured variables so that the values of the
+// variables marked as secret since they were written are encrypted
+func (e *Environment) SealEnvVars() error {
+	envVars, err := e.loadSealedEnvVars()
+	if err != nil {
+		return err
+	}
+	for _, envVar := range envVars {
+		if e.IsSecretEnvVar(envVar.Key) && !isSealedValue(envVar.Value) {
+			return e.SaveEnvVars(envVars)
+		}
+	}
+	return nil
+}
+
+// ReadEnvFile reads variables from a .env file. Lines may start with
+// "export", and values may be wrapped in single or double quotes.
+func ReadEnvFile(path string) ([]EnvVar, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	return parseEnvVars(file)
+}
+
+// parseEnvVars parses KEY=VALUE lines, skipping comments and malformed lines
+func parseEnvVars(r io.Reader) ([]EnvVar, error) {
 	var envVars []EnvVar
-	scanner := bufio.NewScanner(file)
+	scanner := bufio.NewScanner(r)
 
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
@@ -94,6 +229,7 @@ func (e *Environment) LoadEnvVars() ([]EnvVar, error) {
 		if line == "" || strings.HasPrefix(line, "#") {
 			continue
 		}
+		line = strings.TrimPrefix(line, "export ")
 
 		// Parse KEY=VALUE format
 		parts := strings.SplitN(line, "=", 2)
@@ -102,7 +238,13 @@ func (e *Environment) LoadEnvVars() ([]EnvVar, error) {
 		}
 
 		key := strings.TrimSpace(parts[0])
-		value := unescapeEnvValue(strings.TrimSpace(parts[1]))
+		rawValue := strings.TrimSpace(parts[1])
+		var value string
+		if len(rawValue) >= 2 && rawValue[0] == '\'' && rawValue[len(rawValue)-1] == '\'' {
+			value = rawValue[1 : len(rawValue)-1]
+		} else {
+			value = unescapeEnvValue(rawValue)
+		}
 
 		envVars = append(envVars, EnvVar{
 			Key:   key,
@@ -142,6 +284,398 @@ func GenerateShellExports(envVars []EnvVar) string {
 	return builder.String()
 }
 
+// Shell variables recording the keys exported by FormatShellExports
+const (
+	ExportedVarsVariable           = "__ENVSWITCH_VARS"
+	FishExportedVarsVariable       = "__envswitch_vars"
+	PowerShellExportedVarsVariable = "__envswitch_vars"
+)
+
+// FormatShellExports returns the commands applying envVars to a bash, zsh,
+// fish, PowerShell or cmd session. Keys from previous that are not in envVars are
+// unset, and the exported keys are recorded so the next call can unset them.
+// Keys that are not valid shell variable names are skipped.
+func FormatShellExports(shell string, envVars []EnvVar, previous []string) (string, error) {
+	var unsetFormat, exportFormat string
+	quote := shellQuote
+	switch shell {
+	case "bash", "zsh":
+		unsetFormat, exportFormat = "unset %s\n", "export %s=%s\n"
+	case "fish":
+		unsetFormat, exportFormat = "set -e %s\n", "set -gx %s %s\n"
+		quote = fishQuote
+	case "powershell":
+		unsetFormat, exportFormat = "Remove-Item Env:%s -ErrorAction SilentlyContinue\n", "$env:%s = %s\n"
+		quote = powershellQuote
+	case "cmd":
+		unsetFormat, exportFormat = "set \"%s=\"\n", "set \"%s=%s\"\n"
+		quote = cmdValue
+	default:
+		return "", fmt.Errorf("unsupported shell: %s (supported: bash, zsh, fish, powershell, cmd)", shell)
+	}
+
+	var builder strings.Builder
+	var keys []string
+	current := make(map[string]bool)
+	for _, envVar := range envVars {
+		if ValidateEnvVarKey(envVar.Key) == nil {
+			current[envVar.Key] = true
+		}
+	}
+
+	for _, key := range previous {
+		if current[key] || ValidateEnvVarKey(key) != nil {
+			continue
+		}
+		builder.WriteString(fmt.Sprintf(unsetFormat, key))
+	}
+
+	for _, envVar := range envVars {
+		if !current[envVar.Key] {
+			continue
+		}
+		keys = append(keys, envVar.Key)
+		builder.WriteString(fmt.Sprintf(exportFormat, envVar.Key, quote(envVar.Value)))
+	}
+
+	// fish keeps the keys as a list, the other shells as a string
+	recorded := strings.Join(keys, " ")
+	switch shell {
+	case "fish":
+		builder.WriteString(strings.TrimSpace("set -g "+FishExportedVarsVariable+" "+recorded) + "\n")
+	case "powershell":
+		builder.WriteString(fmt.Sprintf("$global:%s = %s\n", PowerShellExportedVarsVariable, quote(recorded)))
+	case "cmd":
+		builder.WriteString(fmt.Sprintf(exportFormat, ExportedVarsVariable, recorded))
+	default:
+		builder.WriteString(fmt.Sprintf("%s=%s\n", ExportedVarsVariable, quote(recorded)))
+	}
+
+	return builder.String(), nil
+}
+
+// RenameEnvVar renames a variable both in the environment's tracked variables
+// and in its captured env-vars file. It returns false if the variable was not
+// present. An existing newKey is only replaced when overwrite is true.
+func (e *Environment) RenameEnvVar(oldKey, newKey string, overwrite bool) (bool, error) {
+	captured, err := e.LoadEnvVars()
+	if err != nil {
+		return false, err
+	}
+
+	_, inMetadata := e.EnvVars[oldKey]
+	capturedIndex := findEnvVar(captured, oldKey)
+	if !inMetadata && capturedIndex < 0 {
+		return false, nil
+	}
+
+	if !overwrite && e.hasEnvVar(captured, newKey) {
+		return false, fmt.Errorf("variable %s already exists in environment '%s'", newKey, e.Name)
+	}
+
+	if e.IsSecretEnvVar(oldKey) {
+		e.SetEnvVarSecret(oldKey, false)
+		e.SetEnvVarSecret(newKey, true)
+	}
+	if inMetadata {
+		value := e.EnvVars[oldKey]
+		delete(e.EnvVars, oldKey)
+		e.EnvVars[newKey] = value
+	}
+
+	if capturedIndex >= 0 {
+		value := captured[capturedIndex].Value
+		captured = removeEnvVar(captured, oldKey)
+		captured = setEnvVar(captured, newKey, value)
+		if err := e.SaveEnvVars(captured); err != nil {
+			return false, err
+		}
+	}
+
+	if err := e.Save(); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// CopyEnvVarTo copies a variable (tracked value and captured value) from this
+// environment to dst. An existing variable in dst is only replaced when
+// overwrite is true.
+func (e *Environment) CopyEnvVarTo(dst *Environment, key string, overwrite bool) error {
+	srcCaptured, err := e.LoadEnvVars()
+	if err != nil {
+		return err
+	}
+
+	metadataValue, inMetadata := e.EnvVars[key]
+	capturedIndex := findEnvVar(srcCaptured, key)
+	if !inMetadata && capturedIndex < 0 {
+		return fmt.Errorf("variable %s not found in environment '%s'", key, e.Name)
+	}
+
+	dstCaptured, err := dst.LoadEnvVars()
+	if err != nil {
+		return err
+	}
+
+	if !overwrite && dst.hasEnvVar(dstCaptured, key) {
+		return fmt.Errorf("variable %s already exists in environment '%s'", key, dst.Name)
+	}
+
+	if dst.EnvVars == nil {
+		dst.EnvVars = make(map[string]string)
+	}
+	if e.IsSecretEnvVar(key) {
+		dst.SetEnvVarSecret(key, true)
+		dst.EnvVars[key] = ""
+	} else if inMetadata {
+		dst.EnvVars[key] = metadataValue
+	} else {
+		dst.EnvVars[key] = ""
+	}
+
+	if capturedIndex >= 0 {
+		dstCaptured = setEnvVar(dstCaptured, key, srcCaptured[capturedIndex].Value)
+		if err := dst.SaveEnvVars(dstCaptured); err != nil {
+			return err
+		}
+	}
+
+	return dst.Save()
+}
+
+// ValidateEnvVarKey checks that key can be used as a shell variable name
+func ValidateEnvVarKey(key string) error {
+	if !envVarKeyPattern.MatchString(key) {
+		return fmt.Errorf("invalid variable name '%s': use letters, digits and underscores, not starting with a digit", key)
+	}
+	return nil
+}
+
+// SetEnvVar sets the value of a variable, tracking it if it was not already
+func (e *Environment) SetEnvVar(key, value string) error {
+	if err := ValidateEnvVarKey(key); err != nil {
+		return err
+	}
+
+	captured, err := e.LoadEnvVars()
+	if err != nil {
+		return err
+	}
+
+	if e.EnvVars == nil {
+		e.EnvVars = make(map[string]string)
+	}
+	// The value of a secret is only kept, encrypted, in the env-vars file
+	if e.IsSecretEnvVar(key) {
+		e.EnvVars[key] = ""
+	} else {
+		e.EnvVars[key] = value
+	}
+
+	if err := e.writeEnvVars(setEnvVar(captured, key, value)); err != nil {
+		return err
+	}
+	return e.Save()
+}
+
+// GetEnvVar returns the value of a variable, preferring the captured value
+// over the tracked one
+func (e *Environment) GetEnvVar(key string) (string, bool, error) {
+	captured, err := e.LoadEnvVars()
+	if err != nil {
+		return "", false, err
+	}
+
+	if i := findEnvVar(captured, key); i >= 0 {
+		return captured[i].Value, true, nil
+	}
+	value, ok := e.EnvVars[key]
+	return value, ok, nil
+}
+
+// UnsetEnvVar stops tracking a variable and removes its captured value. It
+// returns false if the variable was not present.
+func (e *Environment) UnsetEnvVar(key string) (bool, error) {
+	captured, err := e.LoadEnvVars()
+	if err != nil {
+		return false, err
+	}
+
+	_, inMetadata := e.EnvVars[key]
+	capturedIndex := findEnvVar(captured, key)
+	if !inMetadata && capturedIndex < 0 {
+		return false, nil
+	}
+
+	delete(e.EnvVars, key)
+	e.SecretEnvVars = removeString(e.SecretEnvVars, key)
+
+	if capturedIndex >= 0 {
+		if err := e.writeEnvVars(removeEnvVar(captured, key)); err != nil {
+			return false, err
+		}
+	}
+
+	if err := e.Save(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// TrackEnvVar adds a variable name or glob, like AWS_*, to the variables
+// captured from the shell on each save. It returns false if it was already
+// tracked.
+func (e *Environment) TrackEnvVar(pattern string) (bool, error) {
+	if err := ValidateEnvVarPattern(pattern); err != nil {
+		return false, err
+	}
+	if !isEnvVarGlob(pattern) {
+		if err := ValidateEnvVarKey(pattern); err != nil {
+			return false, err
+		}
+	}
+	if _, tracked := e.EnvVars[pattern]; tracked {
+		return false, nil
+	}
+
+	if e.EnvVars == nil {
+		e.EnvVars = make(map[string]string)
+	}
+	e.EnvVars[pattern] = ""
+	return true, e.Save()
+}
+
+// ListEnvVars returns every tracked or captured variable sorted by name,
+// with captured values taking precedence
+func (e *Environment) ListEnvVars() ([]EnvVar, error) {
+	captured, err := e.LoadEnvVars()
+	if err != nil {
+		return nil, err
+	}
+	return e.mergeEnvVars(captured), nil
+}
+
+// ListEnvVarsMasked is ListEnvVars without decrypting anything: the values
+// of secret variables are replaced with mask
+func (e *Environment) ListEnvVarsMasked(mask string) ([]EnvVar, error) {
+	captured, err := e.loadSealedEnvVars()
+	if err != nil {
+		return nil, err
+	}
+	envVars := e.mergeEnvVars(captured)
+	for i, envVar := range envVars {
+		if envVar.Value != "" && (e.IsSecretEnvVar(envVar.Key) || isSealedValue(envVar.Value)) {
+			envVars[i].Value = mask
+		}
+	}
+	return envVars, nil
+}
+
+// mergeEnvVars adds the tracked variables missing from captured, sorted by
+// name
+func (e *Environment) mergeEnvVars(captured []EnvVar) []EnvVar {
+	envVars := append([]EnvVar{}, captured...)
+	for key, value := range e.EnvVars {
+		// Globs only select the variables to capture
+		if isEnvVarGlob(key) {
+			continue
+		}
+		if findEnvVar(envVars, key) < 0 {
+			envVars = append(envVars, EnvVar{Key: key, Value: value})
+		}
+	}
+
+	sort.Slice(envVars, func(i, j int) bool {
+		return envVars[i].Key < envVars[j].Key
+	})
+	return envVars
+}
+
+// IsSecretEnvVar reports whether a variable is marked as secret
+func (e *Environment) IsSecretEnvVar(key string) bool {
+	for _, secret := range e.SecretEnvVars {
+		if secret == key {
+			return true
+		}
+	}
+	return false
+}
+
+// SetEnvVarSecret marks or unmarks a variable as secret. The environment is
+// not saved.
+func (e *Environment) SetEnvVarSecret(key string, secret bool) {
+	e.SecretEnvVars = removeString(e.SecretEnvVars, key)
+	if secret {
+		e.SecretEnvVars = append(e.SecretEnvVars, key)
+		sort.Strings(e.SecretEnvVars)
+	}
+}
+
+// writeEnvVars saves the captured variables, removing the file once the
+// last one is gone
+func (e *Environment) writeEnvVars(envVars []EnvVar) error {
+	if len(envVars) > 0 {
+		return e.SaveEnvVars(envVars)
+	}
+
+	envFilePath := filepath.Join(e.Path, "snapshots", envVarsFileName)
+	if err := os.Remove(envFilePath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove env vars file: %w", err)
+	}
+	return nil
+}
+
+// removeString returns values without value
+func removeString(values []string, value string) []string {
+	var result []string
+	for _, v := range values {
+		if v != value {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// hasEnvVar reports whether key is tracked or captured in the environment
+func (e *Environment) hasEnvVar(captured []EnvVar, key string) bool {
+	if _, ok := e.EnvVars[key]; ok {
+		return true
+	}
+	return findEnvVar(captured, key) >= 0
+}
+
+// findEnvVar returns the index of key in envVars, or -1
+func findEnvVar(envVars []EnvVar, key string) int {
+	for i, envVar := range envVars {
+		if envVar.Key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// setEnvVar replaces the value of key in envVars, appending it if missing
+func setEnvVar(envVars []EnvVar, key, value string) []EnvVar {
+	if i := findEnvVar(envVars, key); i >= 0 {
+		envVars[i].Value = value
+		return envVars
+	}
+	return append(envVars, EnvVar{Key: key, Value: value})
+}
+
+// removeEnvVar returns envVars without key
+func removeEnvVar(envVars []EnvVar, key string) []EnvVar {
+	result := make([]EnvVar, 0, len(envVars))
+	for _, envVar := range envVars {
+		if envVar.Key != key {
+			result = append(result, envVar)
+		}
+	}
+	return result
+}
+
 // escapeEnvValue escapes special characters in environment variable values
 func escapeEnvValue(value string) string {
 	// If value contains spaces, newlines, or quotes, wrap in quotes and escape
@@ -172,3 +706,24 @@ func shellQuote(value string) string {
 	escaped := strings.ReplaceAll(value, "'", "'\"'\"'")
 	return "'" + escaped + "'"
 }
+
+// fishQuote quotes a value for fish, where only backslashes and single quotes
+// are special inside single quotes
+func fishQuote(value string) string {
+	escaped := strings.ReplaceAll(value, "\\", "\\\\")
+	escaped = strings.ReplaceAll(escaped, "'", "\\'")
+	return "'" + escaped + "'"
+}
+
+// powershellQuote quotes a value for PowerShell, where single quotes are
+// doubled inside single-quoted strings
+func powershellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+// cmdValue prepares a value for set "KEY=value" in cmd, where everything up
+// to the last quote is taken literally but a command cannot span lines, so
+// line breaks become spaces
+func cmdValue(value string) string {
+	return strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ").Replace(value)
+}
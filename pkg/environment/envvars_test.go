@@ -17,7 +17,7 @@ func TestCaptureEnvVars(t *testing.T) {
 		defer os.Unsetenv("TEST_VAR_1")
 		defer os.Unsetenv("TEST_VAR_2")
 
-		envVars, err := CaptureEnvVars([]string{"TEST_VAR_1", "TEST_VAR_2"})
+		envVars, err := CaptureEnvVars([]string{"TEST_VAR_1", "TEST_VAR_2"}, nil)
 
 		require.NoError(t, err)
 		assert.Len(t, envVars, 2)
@@ -31,15 +31,42 @@ func TestCaptureEnvVars(t *testing.T) {
 		os.Setenv("TEST_VAR_EXISTS", "exists")
 		defer os.Unsetenv("TEST_VAR_EXISTS")
 
-		envVars, err := CaptureEnvVars([]string{"TEST_VAR_EXISTS", "TEST_VAR_DOES_NOT_EXIST"})
+		envVars, err := CaptureEnvVars([]string{"TEST_VAR_EXISTS", "TEST_VAR_DOES_NOT_EXIST"}, nil)
 
 		require.NoError(t, err)
 		assert.Len(t, envVars, 1)
 		assert.Equal(t, "TEST_VAR_EXISTS", envVars[0].Key)
 	})
 
+	t.Run("expands globs in name order", func(t *testing.T) {
+		t.Setenv("GLOBTEST_B", "b")
+		t.Setenv("GLOBTEST_A", "a")
+		t.Setenv("OTHER_GLOBTEST", "other")
+
+		envVars, err := CaptureEnvVars([]string{"GLOBTEST_*", "GLOBTEST_A"}, nil)
+
+		require.NoError(t, err)
+		assert.Equal(t, []EnvVar{{Key: "GLOBTEST_A", Value: "a"}, {Key: "GLOBTEST_B", Value: "b"}}, envVars)
+	})
+
+	t.Run("skips denied variables unless named exactly", func(t *testing.T) {
+		t.Setenv("DENYTEST_REGION", "eu-west-1")
+		t.Setenv("DENYTEST_TOKEN", "secret")
+		t.Setenv("DENYTEST_CI_TOKEN", "ci")
+
+		envVars, err := CaptureEnvVars([]string{"DENYTEST_*", "DENYTEST_CI_TOKEN"}, []string{"*_TOKEN"})
+
+		require.NoError(t, err)
+		assert.Equal(t, []EnvVar{{Key: "DENYTEST_CI_TOKEN", Value: "ci"}, {Key: "DENYTEST_REGION", Value: "eu-west-1"}}, envVars)
+	})
+
+	t.Run("rejects invalid patterns", func(t *testing.T) {
+		_, err := CaptureEnvVars([]string{"AWS_[A-"}, nil)
+		assert.Error(t, err)
+	})
+
 	t.Run("handles empty variable list", func(t *testing.T) {
-		envVars, err := CaptureEnvVars([]string{})
+		envVars, err := CaptureEnvVars([]string{}, nil)
 
 		require.NoError(t, err)
 		assert.Len(t, envVars, 0)
@@ -331,7 +358,7 @@ func TestEnvVarsIntegration(t *testing.T) {
 		defer os.Unsetenv("INTEGRATION_TEST_2")
 
 		// Capture
-		captured, err := CaptureEnvVars([]string{"INTEGRATION_TEST_1", "INTEGRATION_TEST_2"})
+		captured, err := CaptureEnvVars([]string{"INTEGRATION_TEST_1", "INTEGRATION_TEST_2"}, nil)
 		require.NoError(t, err)
 		assert.Len(t, captured, 2)
 
@@ -361,3 +388,266 @@ func TestEnvVarsIntegration(t *testing.T) {
 		os.Unsetenv("INTEGRATION_TEST_2")
 	})
 }
+
+func TestRenameEnvVar(t *testing.T) {
+	newEnv := func(t *testing.T) *Environment {
+		env := &Environment{
+			Name:    "rename-env",
+			Path:    t.TempDir(),
+			EnvVars: map[string]string{"OLD_KEY": "tracked", "OTHER": "x"},
+		}
+		require.NoError(t, env.SaveEnvVars([]EnvVar{{Key: "OLD_KEY", Value: "captured"}, {Key: "OTHER", Value: "y"}}))
+		return env
+	}
+
+	t.Run("renames tracked and captured variable", func(t *testing.T) {
+		env := newEnv(t)
+
+		found, err := env.RenameEnvVar("OLD_KEY", "NEW_KEY", false)
+		require.NoError(t, err)
+		assert.True(t, found)
+
+		assert.NotContains(t, env.EnvVars, "OLD_KEY")
+		assert.Equal(t, "tracked", env.EnvVars["NEW_KEY"])
+
+		captured, err := env.LoadEnvVars()
+		require.NoError(t, err)
+		assert.Equal(t, []EnvVar{{Key: "OTHER", Value: "y"}, {Key: "NEW_KEY", Value: "captured"}}, captured)
+	})
+
+	t.Run("reports missing variable", func(t *testing.T) {
+		env := newEnv(t)
+
+		found, err := env.RenameEnvVar("MISSING", "NEW_KEY", false)
+		require.NoError(t, err)
+		assert.False(t, found)
+	})
+
+	t.Run("refuses to overwrite without force", func(t *testing.T) {
+		env := newEnv(t)
+
+		_, err := env.RenameEnvVar("OLD_KEY", "OTHER", false)
+		assert.Error(t, err)
+		assert.Equal(t, "tracked", env.EnvVars["OLD_KEY"])
+
+		found, err := env.RenameEnvVar("OLD_KEY", "OTHER", true)
+		require.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, "tracked", env.EnvVars["OTHER"])
+	})
+}
+
+func TestCopyEnvVarTo(t *testing.T) {
+	src := &Environment{
+		Name:    "src",
+		Path:    t.TempDir(),
+		EnvVars: map[string]string{"API_URL": "https://api"},
+	}
+	require.NoError(t, src.SaveEnvVars([]EnvVar{{Key: "API_URL", Value: "https://captured"}}))
+
+	t.Run("copies variable to target", func(t *testing.T) {
+		dst := &Environment{Name: "dst", Path: t.TempDir()}
+
+		require.NoError(t, src.CopyEnvVarTo(dst, "API_URL", false))
+		assert.Equal(t, "https://api", dst.EnvVars["API_URL"])
+
+		captured, err := dst.LoadEnvVars()
+		require.NoError(t, err)
+		assert.Equal(t, []EnvVar{{Key: "API_URL", Value: "https://captured"}}, captured)
+	})
+
+	t.Run("fails on unknown variable", func(t *testing.T) {
+		dst := &Environment{Name: "dst", Path: t.TempDir()}
+		assert.Error(t, src.CopyEnvVarTo(dst, "MISSING", false))
+	})
+
+	t.Run("refuses to overwrite without force", func(t *testing.T) {
+		dst := &Environment{
+			Name:    "dst",
+			Path:    t.TempDir(),
+			EnvVars: map[string]string{"API_URL": "keep"},
+		}
+
+		assert.Error(t, src.CopyEnvVarTo(dst, "API_URL", false))
+		assert.Equal(t, "keep", dst.EnvVars["API_URL"])
+
+		require.NoError(t, src.CopyEnvVarTo(dst, "API_URL", true))
+		assert.Equal(t, "https://api", dst.EnvVars["API_URL"])
+	})
+}
+
+func TestReadEnvFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	content := "# comment\nexport API_URL=https://api\nTOKEN='a b$c'\nNAME=\"quoted\"\nmalformed\n"
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+
+	envVars, err := ReadEnvFile(path)
+
+	require.NoError(t, err)
+	assert.Equal(t, []EnvVar{
+		{Key: "API_URL", Value: "https://api"},
+		{Key: "TOKEN", Value: "a b$c"},
+		{Key: "NAME", Value: "quoted"},
+	}, envVars)
+}
+
+func TestValidateEnvVarKey(t *testing.T) {
+	for _, key := range []string{"API_URL", "_private", "a1"} {
+		assert.NoError(t, ValidateEnvVarKey(key), key)
+	}
+	for _, key := range []string{"", "1ABC", "API-URL", "A B", "A=B"} {
+		assert.Error(t, ValidateEnvVarKey(key), key)
+	}
+}
+
+func TestSetGetUnsetEnvVar(t *testing.T) {
+	t.Run("sets tracked and captured value", func(t *testing.T) {
+		env := &Environment{Name: "test", Path: t.TempDir()}
+
+		require.NoError(t, env.SetEnvVar("API_URL", "https://api"))
+
+		assert.Equal(t, "https://api", env.EnvVars["API_URL"])
+		value, ok, err := env.GetEnvVar("API_URL")
+		require.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, "https://api", value)
+		assert.FileExists(t, filepath.Join(env.Path, "metadata.yaml"))
+	})
+
+	t.Run("rejects invalid key", func(t *testing.T) {
+		env := &Environment{Name: "test", Path: t.TempDir()}
+		assert.Error(t, env.SetEnvVar("BAD-KEY", "x"))
+		assert.Empty(t, env.EnvVars)
+	})
+
+	t.Run("get prefers captured value", func(t *testing.T) {
+		env := &Environment{
+			Name:    "test",
+			Path:    t.TempDir(),
+			EnvVars: map[string]string{"API_URL": "tracked"},
+		}
+		require.NoError(t, env.SaveEnvVars([]EnvVar{{Key: "API_URL", Value: "captured"}}))
+
+		value, ok, err := env.GetEnvVar("API_URL")
+		require.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, "captured", value)
+
+		_, ok, err = env.GetEnvVar("MISSING")
+		require.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("unset removes variable, secret flag and empty file", func(t *testing.T) {
+		env := &Environment{Name: "test", Path: t.TempDir()}
+		require.NoError(t, env.SetEnvVar("TOKEN", "secret"))
+		env.SetEnvVarSecret("TOKEN", true)
+
+		removed, err := env.UnsetEnvVar("TOKEN")
+		require.NoError(t, err)
+		assert.True(t, removed)
+		assert.NotContains(t, env.EnvVars, "TOKEN")
+		assert.False(t, env.IsSecretEnvVar("TOKEN"))
+		assert.NoFileExists(t, filepath.Join(env.Path, "snapshots", envVarsFileName))
+
+		removed, err = env.UnsetEnvVar("TOKEN")
+		require.NoError(t, err)
+		assert.False(t, removed)
+	})
+}
+
+func TestListEnvVars(t *testing.T) {
+	env := &Environment{
+		Name:    "test",
+		Path:    t.TempDir(),
+		EnvVars: map[string]string{"B_VAR": "tracked", "C_VAR": "only-tracked"},
+	}
+	require.NoError(t, env.SaveEnvVars([]EnvVar{
+		{Key: "B_VAR", Value: "captured"},
+		{Key: "A_VAR", Value: "only-captured"},
+	}))
+
+	envVars, err := env.ListEnvVars()
+
+	require.NoError(t, err)
+	assert.Equal(t, []EnvVar{
+		{Key: "A_VAR", Value: "only-captured"},
+		{Key: "B_VAR", Value: "captured"},
+		{Key: "C_VAR", Value: "only-tracked"},
+	}, envVars)
+}
+
+func TestSetEnvVarSecret(t *testing.T) {
+	env := &Environment{Name: "test"}
+
+	env.SetEnvVarSecret("TOKEN", true)
+	env.SetEnvVarSecret("API_KEY", true)
+	env.SetEnvVarSecret("TOKEN", true)
+
+	assert.Equal(t, []string{"API_KEY", "TOKEN"}, env.SecretEnvVars)
+	assert.True(t, env.IsSecretEnvVar("TOKEN"))
+
+	env.SetEnvVarSecret("TOKEN", false)
+	assert.Equal(t, []string{"API_KEY"}, env.SecretEnvVars)
+}
+
+func TestFormatShellExports(t *testing.T) {
+	envVars := []EnvVar{
+		{Key: "API_URL", Value: "https://api"},
+		{Key: "QUOTE", Value: `it's a \ test`},
+		{Key: "BAD-KEY", Value: "skipped"},
+	}
+
+	t.Run("bash", func(t *testing.T) {
+		script, err := FormatShellExports("bash", envVars, []string{"OLD_VAR", "API_URL", "$(rm)"})
+
+		require.NoError(t, err)
+		assert.Equal(t, "unset OLD_VAR\n"+
+			"export API_URL='https://api'\n"+
+			"export QUOTE='it'\"'\"'s a \\ test'\n"+
+			"__ENVSWITCH_VARS='API_URL QUOTE'\n", script)
+	})
+
+	t.Run("fish", func(t *testing.T) {
+		script, err := FormatShellExports("fish", envVars, []string{"OLD_VAR"})
+
+		require.NoError(t, err)
+		assert.Equal(t, "set -e OLD_VAR\n"+
+			"set -gx API_URL 'https://api'\n"+
+			"set -gx QUOTE 'it\\'s a \\\\ test'\n"+
+			"set -g __envswitch_vars API_URL QUOTE\n", script)
+	})
+
+	t.Run("powershell", func(t *testing.T) {
+		script, err := FormatShellExports("powershell", envVars, []string{"OLD_VAR"})
+
+		require.NoError(t, err)
+		assert.Equal(t, "Remove-Item Env:OLD_VAR -ErrorAction SilentlyContinue\n"+
+			"$env:API_URL = 'https://api'\n"+
+			"$env:QUOTE = 'it''s a \\ test'\n"+
+			"$global:__envswitch_vars = 'API_URL QUOTE'\n", script)
+	})
+
+	t.Run("cmd", func(t *testing.T) {
+		script, err := FormatShellExports("cmd", append(envVars, EnvVar{Key: "LINES", Value: "a\r\nb"}), []string{"OLD_VAR"})
+
+		require.NoError(t, err)
+		assert.Equal(t, "set \"OLD_VAR=\"\n"+
+			"set \"API_URL=https://api\"\n"+
+			"set \"QUOTE=it's a \\ test\"\n"+
+			"set \"LINES=a b\"\n"+
+			"set \"__ENVSWITCH_VARS=API_URL QUOTE LINES\"\n", script)
+	})
+
+	t.Run("only unsets without variables", func(t *testing.T) {
+		script, err := FormatShellExports("zsh", nil, []string{"OLD_VAR"})
+
+		require.NoError(t, err)
+		assert.Equal(t, "unset OLD_VAR\n__ENVSWITCH_VARS=''\n", script)
+	})
+
+	t.Run("rejects unknown shell", func(t *testing.T) {
+		_, err := FormatShellExports("tcsh", envVars, nil)
+		assert.Error(t, err)
+	})
+}
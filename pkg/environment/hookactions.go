@@ -0,0 +1,163 @@
+package environment
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Built-in hook actions, as written in the action field of a hook
+const (
+	HookActionNotify         = "notify"
+	HookActionExec           = "exec"
+	HookActionDockerLogin    = "docker-login"
+	HookActionRestartService = "restart-service"
+	HookActionRunMake        = "run-make"
+)
+
+// HookActionParam is a parameter of a hook action, set in the with field
+type HookActionParam struct {
+	Name        string
+	Description string
+	Default     string
+	Required    bool
+	Validate    func(value string) error
+}
+
+// HookAction is a built-in hook action. Actions are run by internal/hooks
+// without a shell, so that they work the same on every machine.
+type HookAction struct {
+	Name    string
+	Summary string
+	Params  []HookActionParam
+	Args    bool // the action takes its arguments from the args field
+}
+
+var hookActions = map[string]HookAction{
+	HookActionNotify: {
+		Name:    HookActionNotify,
+		Summary: "Show a desktop notification",
+		Params: []HookActionParam{
+			{Name: "message", Description: "Text of the notification, {env} is replaced by the environment", Default: "Switched to {env}"},
+			{Name: "title", Description: "Title of the notification", Default: "envswitch"},
+		},
+	},
+	HookActionExec: {
+		Name:    HookActionExec,
+		Summary: "Run a program with the arguments of args, without a shell",
+		Args:    true,
+	},
+	HookActionDockerLogin: {
+		Name:    HookActionDockerLogin,
+		Summary: "Log in to a Docker registry with a password read from a variable",
+		Params: []HookActionParam{
+			{Name: "registry", Description: "Registry to log in to", Default: "docker.io"},
+			{Name: "username", Description: "User name", Required: true},
+			{Name: "password_var", Description: "Variable holding the password or token", Default: "DOCKER_PASSWORD", Validate: ValidateEnvVarKey},
+		},
+	},
+	HookActionRestartService: {
+		Name:    HookActionRestartService,
+		Summary: "Restart a service (systemd, launchd or Windows services)",
+		Params: []HookActionParam{
+			{Name: "service", Description: "Name of the service, the label of its launchd job on macOS", Required: true},
+			{Name: "user", Description: "true for a user service (systemctl --user, launchd gui domain)", Default: "false", Validate: validateHookBool},
+		},
+	},
+	HookActionRunMake: {
+		Name:    HookActionRunMake,
+		Summary: "Run make targets",
+		Params: []HookActionParam{
+			{Name: "target", Description: "Targets to run, separated by spaces, the first one of the Makefile when empty"},
+			{Name: "file", Description: "Makefile to use instead of the one of the working directory"},
+		},
+	},
+}
+
+// HookActions returns the built-in hook actions sorted by name
+func HookActions() []HookAction {
+	list := make([]HookAction, 0, len(hookActions))
+	for _, action := range hookActions {
+		list = append(list, action)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// HookActionNames returns the names of the built-in hook actions
+func HookActionNames() []string {
+	var names []string
+	for _, action := range HookActions() {
+		names = append(names, action.Name)
+	}
+	return names
+}
+
+// LookupHookAction returns the built-in hook action called name
+func LookupHookAction(name string) (HookAction, error) {
+	action, ok := hookActions[name]
+	if !ok {
+		return HookAction{}, fmt.Errorf("unknown hook action '%s' (available: %s)", name, strings.Join(HookActionNames(), ", "))
+	}
+	return action, nil
+}
+
+// Param returns the value of the parameter called name in with, or its
+// default
+func (a HookAction) Param(with map[string]string, name string) string {
+	if value, ok := with[name]; ok {
+		return value
+	}
+	for _, param := range a.Params {
+		if param.Name == name {
+			return param.Default
+		}
+	}
+	return ""
+}
+
+// validate checks the parameters and arguments given to the action
+func (a HookAction) validate(with map[string]string, args []string) error {
+	for name := range with {
+		if !a.hasParam(name) {
+			return fmt.Errorf("action '%s' has no parameter '%s'", a.Name, name)
+		}
+	}
+	for _, param := range a.Params {
+		value := a.Param(with, param.Name)
+		if param.Required && strings.TrimSpace(value) == "" {
+			return fmt.Errorf("action '%s' requires the parameter '%s'", a.Name, param.Name)
+		}
+		if param.Validate != nil && value != "" {
+			if err := param.Validate(value); err != nil {
+				return fmt.Errorf("invalid %s: %w", param.Name, err)
+			}
+		}
+	}
+
+	if a.Args && (len(args) == 0 || args[0] == "") {
+		return fmt.Errorf("action '%s' requires the program to run in args", a.Name)
+	}
+	if !a.Args && len(args) > 0 {
+		return fmt.Errorf("action '%s' takes no args", a.Name)
+	}
+	return nil
+}
+
+// hasParam reports whether the action takes a parameter called name
+func (a HookAction) hasParam(name string) bool {
+	for _, param := range a.Params {
+		if param.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// validateHookBool accepts true and false
+func validateHookBool(value string) error {
+	if value != "true" && value != "false" {
+		return fmt.Errorf("expected true or false, got '%s'", value)
+	}
+	return nil
+}
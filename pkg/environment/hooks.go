@@ -0,0 +1,136 @@
+package environment
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// Hook events, as written in metadata.yaml
+const (
+	HookPreSwitch    = "pre_switch"
+	HookPostSwitch   = "post_switch"
+	HookPreSnapshot  = "pre_snapshot"
+	HookPostSnapshot = "post_snapshot"
+)
+
+// HookEvents lists the hook events in the order they are shown
+var HookEvents = []string{HookPreSwitch, HookPostSwitch, HookPreSnapshot, HookPostSnapshot}
+
+// Values of Hook.OnFailure
+const (
+	HookFailAbort  = "abort"  // stop the operation (default)
+	HookFailWarn   = "warn"   // print a warning and continue
+	HookFailIgnore = "ignore" // continue silently
+)
+
+// Validate checks the action, timeout and failure policy of the hook
+func (h Hook) Validate() error {
+	set := 0
+	for _, field := range []string{h.Command, h.Script, h.Action} {
+		if field != "" {
+			set++
+		}
+	}
+	switch {
+	case set == 0:
+		return fmt.Errorf("hook has neither command nor script nor action")
+	case set > 1:
+		return fmt.Errorf("hook can only have one of command, script and action")
+	}
+	if h.Action != "" {
+		action, err := LookupHookAction(h.Action)
+		if err != nil {
+			return err
+		}
+		if err := action.validate(h.With, h.Args); err != nil {
+			return err
+		}
+	} else if len(h.With) > 0 || len(h.Args) > 0 {
+		return fmt.Errorf("with and args are only used by actions")
+	}
+	if _, err := h.TimeoutDuration(); err != nil {
+		return err
+	}
+	switch h.OnFailure {
+	case "", HookFailAbort, HookFailWarn, HookFailIgnore:
+	default:
+		return fmt.Errorf("invalid on_failure '%s' (valid: abort, warn, ignore)", h.OnFailure)
+	}
+	for key := range h.Env {
+		if err := ValidateEnvVarKey(key); err != nil {
+			return fmt.Errorf("invalid hook env: %w", err)
+		}
+	}
+	return nil
+}
+
+// TimeoutDuration returns the parsed timeout of the hook, 0 when it has none
+func (h Hook) TimeoutDuration() (time.Duration, error) {
+	if h.Timeout == "" {
+		return 0, nil
+	}
+	timeout, err := time.ParseDuration(h.Timeout)
+	if err != nil || timeout <= 0 {
+		return 0, fmt.Errorf("invalid timeout '%s' (expected a duration such as 30s or 2m)", h.Timeout)
+	}
+	return timeout, nil
+}
+
+// FailurePolicy returns what to do when the hook fails, abort when unset
+func (h Hook) FailurePolicy() string {
+	if h.OnFailure == "" {
+		return HookFailAbort
+	}
+	return h.OnFailure
+}
+
+// ForEvent returns the hooks registered for an event
+func (h *Hooks) ForEvent(event string) (*[]Hook, error) {
+	switch event {
+	case HookPreSwitch:
+		return &h.PreSwitch, nil
+	case HookPostSwitch:
+		return &h.PostSwitch, nil
+	case HookPreSnapshot:
+		return &h.PreSnapshot, nil
+	case HookPostSnapshot:
+		return &h.PostSnapshot, nil
+	default:
+		return nil, fmt.Errorf("unknown hook event '%s' (valid: pre_switch, post_switch, pre_snapshot, post_snapshot)", event)
+	}
+}
+
+// AddHook appends a hook to an event. It returns false without adding it
+// when an identical hook is already registered.
+func (h *Hooks) AddHook(event string, hook Hook) (bool, error) {
+	hooks, err := h.ForEvent(event)
+	if err != nil {
+		return false, err
+	}
+
+	for _, existing := range *hooks {
+		if reflect.DeepEqual(existing, hook) {
+			return false, nil
+		}
+	}
+
+	*hooks = append(*hooks, hook)
+	return true, nil
+}
+
+// RemoveHook removes the hook at position number (starting at 1) of an event
+// and returns it
+func (h *Hooks) RemoveHook(event string, number int) (Hook, error) {
+	hooks, err := h.ForEvent(event)
+	if err != nil {
+		return Hook{}, err
+	}
+	if number < 1 || number > len(*hooks) {
+		return Hook{}, fmt.Errorf("no %s hook #%d (there are %d)", event, number, len(*hooks))
+	}
+
+	removed := (*hooks)[number-1]
+	*hooks = append((*hooks)[:number-1], (*hooks)[number:]...)
+	return removed, nil
+}
@@ -0,0 +1,96 @@
+package environment
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHooksAddHook(t *testing.T) {
+	var hooks Hooks
+
+	added, err := hooks.AddHook(HookPostSwitch, Hook{Command: "echo hi"})
+	require.NoError(t, err)
+	assert.True(t, added)
+
+	added, err = hooks.AddHook(HookPostSwitch, Hook{Command: "echo hi"})
+	require.NoError(t, err)
+	assert.False(t, added)
+
+	added, err = hooks.AddHook(HookPreSnapshot, Hook{Command: "echo hi"})
+	require.NoError(t, err)
+	assert.True(t, added)
+
+	_, err = hooks.AddHook("on_boot", Hook{Command: "echo hi"})
+	assert.Error(t, err)
+
+	assert.Len(t, hooks.PostSwitch, 1)
+	assert.Len(t, hooks.PreSnapshot, 1)
+	assert.Empty(t, hooks.PreSwitch)
+}
+
+func TestHookValidate(t *testing.T) {
+	assert.NoError(t, Hook{Command: "true"}.Validate())
+	assert.NoError(t, Hook{
+		Script:    "true",
+		Timeout:   "30s",
+		Env:       map[string]string{"REGION": "eu"},
+		OnFailure: HookFailWarn,
+	}.Validate())
+
+	assert.ErrorContains(t, Hook{}.Validate(), "neither command nor script")
+	assert.ErrorContains(t, Hook{Command: "true", Timeout: "-1s"}.Validate(), "invalid timeout")
+	assert.ErrorContains(t, Hook{Command: "true", OnFailure: "retry"}.Validate(), "invalid on_failure")
+	assert.Error(t, Hook{Command: "true", Env: map[string]string{"BAD-KEY": "x"}}.Validate())
+}
+
+func TestHookValidateAction(t *testing.T) {
+	assert.NoError(t, Hook{Action: HookActionNotify}.Validate())
+	assert.NoError(t, Hook{Action: HookActionExec, Args: []string{"kubectl", "get", "nodes"}}.Validate())
+	assert.NoError(t, Hook{Action: HookActionRestartService, With: map[string]string{"service": "vpn", "user": "true"}}.Validate())
+
+	assert.ErrorContains(t, Hook{Action: "reboot"}.Validate(), "unknown hook action 'reboot'")
+	assert.ErrorContains(t, Hook{Action: HookActionNotify, Command: "true"}.Validate(), "only have one of")
+	assert.ErrorContains(t, Hook{Action: HookActionNotify, With: map[string]string{"sound": "on"}}.Validate(), "has no parameter 'sound'")
+	assert.ErrorContains(t, Hook{Action: HookActionDockerLogin}.Validate(), "requires the parameter 'username'")
+	assert.ErrorContains(t, Hook{Action: HookActionDockerLogin, With: map[string]string{"username": "me", "password_var": "BAD-VAR"}}.Validate(), "invalid password_var")
+	assert.ErrorContains(t, Hook{Action: HookActionRestartService, With: map[string]string{"service": "vpn", "user": "yes"}}.Validate(), "invalid user")
+	assert.ErrorContains(t, Hook{Action: HookActionExec}.Validate(), "requires the program")
+	assert.ErrorContains(t, Hook{Action: HookActionRunMake, Args: []string{"login"}}.Validate(), "takes no args")
+	assert.ErrorContains(t, Hook{Command: "true", With: map[string]string{"a": "b"}}.Validate(), "only used by actions")
+}
+
+func TestHookFailurePolicy(t *testing.T) {
+	assert.Equal(t, HookFailAbort, Hook{}.FailurePolicy())
+	assert.Equal(t, HookFailIgnore, Hook{OnFailure: HookFailIgnore}.FailurePolicy())
+}
+
+func TestHooksAddHookWithEnv(t *testing.T) {
+	var hooks Hooks
+	hook := Hook{Command: "deploy", Env: map[string]string{"STAGE": "prod"}}
+
+	added, err := hooks.AddHook(HookPreSwitch, hook)
+	require.NoError(t, err)
+	assert.True(t, added)
+
+	added, err = hooks.AddHook(HookPreSwitch, Hook{Command: "deploy", Env: map[string]string{"STAGE": "prod"}})
+	require.NoError(t, err)
+	assert.False(t, added)
+}
+
+func TestHooksRemoveHook(t *testing.T) {
+	hooks := Hooks{PostSwitch: []Hook{{Command: "one"}, {Command: "two"}, {Command: "three"}}}
+
+	removed, err := hooks.RemoveHook(HookPostSwitch, 2)
+	require.NoError(t, err)
+	assert.Equal(t, "two", removed.Command)
+	assert.Equal(t, []Hook{{Command: "one"}, {Command: "three"}}, hooks.PostSwitch)
+
+	_, err = hooks.RemoveHook(HookPostSwitch, 3)
+	assert.ErrorContains(t, err, "no post_switch hook #3 (there are 2)")
+	_, err = hooks.RemoveHook(HookPreSwitch, 1)
+	assert.Error(t, err)
+	_, err = hooks.RemoveHook("on_boot", 1)
+	assert.Error(t, err)
+}
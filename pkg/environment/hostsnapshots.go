@@ -0,0 +1,154 @@
+package environment
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hugofrely/envswitch/internal/storage"
+)
+
+// hostSnapshotsPrefix prefixes the per-machine snapshot directories
+// (snapshots@<hostname>) that overlay the shared snapshots directory
+const hostSnapshotsPrefix = "snapshots@"
+
+// hostname is a variable so tests can simulate other machines
+var hostname = os.Hostname
+
+// CurrentHost returns the short, lowercase hostname used to scope snapshots
+func CurrentHost() string {
+	name, err := hostname()
+	if err != nil || name == "" {
+		return "localhost"
+	}
+	if idx := strings.Index(name, "."); idx > 0 {
+		name = name[:idx]
+	}
+	return strings.ToLower(name)
+}
+
+// HostSnapshotsDir returns the snapshot overlay directory of the current machine
+func (e *Environment) HostSnapshotsDir() string {
+	return filepath.Join(e.Path, hostSnapshotsPrefix+CurrentHost())
+}
+
+// ToolSnapshotSize returns the size on disk of the snapshot of toolName,
+// including its machine-specific files for the current machine
+func (e *Environment) ToolSnapshotSize(toolName string) uint64 {
+	return storage.PathSize(filepath.Join(e.Path, "snapshots", toolName)) +
+		storage.PathSize(filepath.Join(e.HostSnapshotsDir(), toolName))
+}
+
+// SnapshotSize returns the size of the snapshots of the enabled tools as
+// recorded when they were taken
+func (e *Environment) SnapshotSize() uint64 {
+	var size uint64
+	for _, config := range e.Tools {
+		if config.Enabled {
+			size += config.SnapshotSize
+		}
+	}
+	return size
+}
+
+// ListSnapshotHosts returns the machines that have a snapshot overlay
+func (e *Environment) ListSnapshotHosts() []string {
+	entries, err := os.ReadDir(e.Path)
+	if err != nil {
+		return nil
+	}
+
+	var hosts []string
+	for _, entry := range entries {
+		if entry.IsDir() && strings.HasPrefix(entry.Name(), hostSnapshotsPrefix) {
+			hosts = append(hosts, strings.TrimPrefix(entry.Name(), hostSnapshotsPrefix))
+		}
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+// SeparateHostScoped moves the machine-specific parts of a freshly taken tool
+// snapshot from snapshots/<tool> to snapshots@<host>/<tool>, as listed in
+// HostScoped ("kubectl" for the whole tool, "gcloud/configurations" for a path)
+func (e *Environment) SeparateHostScoped(toolName string) error {
+	sharedDir := filepath.Join(e.Path, "snapshots", toolName)
+	hostDir := filepath.Join(e.HostSnapshotsDir(), toolName)
+
+	for _, scoped := range e.HostScoped {
+		relPath, ok := hostScopedPath(scoped, toolName)
+		if !ok {
+			continue
+		}
+
+		src := filepath.Join(sharedDir, relPath)
+		dst := filepath.Join(hostDir, relPath)
+		if err := os.RemoveAll(dst); err != nil {
+			return fmt.Errorf("failed to clean host snapshot %s: %w", scoped, err)
+		}
+		// Gone from the live config: the overlay goes too, or restoring
+		// would bring it back
+		if _, err := os.Stat(src); os.IsNotExist(err) {
+			continue
+		}
+
+		if err := os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+			return fmt.Errorf("failed to create host snapshot directory: %w", err)
+		}
+		if err := os.Rename(src, dst); err != nil {
+			return fmt.Errorf("failed to move %s to host snapshot: %w", scoped, err)
+		}
+	}
+
+	return nil
+}
+
+// ResolveToolSnapshot returns the snapshot directory to restore a tool from.
+// When the current machine has an overlay for the tool, the shared snapshot
+// and the overlay are merged into a temporary directory, the overlay taking
+// priority; cleanup removes it. Files stored in the keyring are put back the
+// same way. The returned path does not exist when the
+// tool has no snapshot at all.
+func (e *Environment) ResolveToolSnapshot(toolName string) (string, func(), error) {
+	sharedDir := filepath.Join(e.Path, "snapshots", toolName)
+	hostDir := filepath.Join(e.HostSnapshotsDir(), toolName)
+	noop := func() {}
+
+	if _, err := os.Stat(hostDir); os.IsNotExist(err) {
+		return e.withKeyringFiles(toolName, sharedDir, false, noop)
+	}
+
+	merged, err := os.MkdirTemp("", "envswitch-"+toolName+"-*")
+	if err != nil {
+		return "", noop, fmt.Errorf("failed to create merge directory: %w", err)
+	}
+	cleanup := func() { _ = os.RemoveAll(merged) }
+
+	if _, err := os.Stat(sharedDir); err == nil {
+		if err := storage.CopyDir(sharedDir, merged); err != nil {
+			cleanup()
+			return "", noop, fmt.Errorf("failed to merge shared snapshot: %w", err)
+		}
+	}
+	if err := storage.CopyDir(hostDir, merged); err != nil {
+		cleanup()
+		return "", noop, fmt.Errorf("failed to merge host snapshot: %w", err)
+	}
+
+	return e.withKeyringFiles(toolName, merged, true, cleanup)
+}
+
+// hostScopedPath returns the path inside the tool snapshot covered by a
+// HostScoped entry ("." for the whole tool)
+func hostScopedPath(scoped, toolName string) (string, bool) {
+	scoped = filepath.ToSlash(filepath.Clean(scoped))
+	if scoped == toolName {
+		return ".", true
+	}
+	if relPath, ok := strings.CutPrefix(scoped, toolName+"/"); ok && relPath != "" {
+		return filepath.FromSlash(relPath), true
+	}
+	return "", false
+}
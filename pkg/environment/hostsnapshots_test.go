@@ -0,0 +1,129 @@
+package environment
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func stubHostname(t *testing.T, name string) {
+	original := hostname
+	hostname = func() (string, error) { return name, nil }
+	t.Cleanup(func() { hostname = original })
+}
+
+func writeSnapshotFile(t *testing.T, path, content string) {
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+}
+
+func TestCurrentHost(t *testing.T) {
+	stubHostname(t, "Laptop.local")
+	assert.Equal(t, "laptop", CurrentHost())
+}
+
+func TestSeparateHostScoped(t *testing.T) {
+	stubHostname(t, "laptop")
+
+	env := &Environment{
+		Name:       "work",
+		Path:       t.TempDir(),
+		HostScoped: []string{"kubectl", "gcloud/configurations"},
+	}
+
+	writeSnapshotFile(t, filepath.Join(env.Path, "snapshots", "kubectl", "config"), "kube")
+	writeSnapshotFile(t, filepath.Join(env.Path, "snapshots", "gcloud", "configurations", "config_default"), "conf")
+	writeSnapshotFile(t, filepath.Join(env.Path, "snapshots", "gcloud", "credentials.db"), "creds")
+	writeSnapshotFile(t, filepath.Join(env.Path, "snapshots", "git", "gitconfig"), "git")
+
+	for _, tool := range []string{"kubectl", "gcloud", "git"} {
+		require.NoError(t, env.SeparateHostScoped(tool))
+	}
+
+	hostDir := filepath.Join(env.Path, "snapshots@laptop")
+	assert.FileExists(t, filepath.Join(hostDir, "kubectl", "config"))
+	assert.NoDirExists(t, filepath.Join(env.Path, "snapshots", "kubectl"))
+	assert.FileExists(t, filepath.Join(hostDir, "gcloud", "configurations", "config_default"))
+	assert.FileExists(t, filepath.Join(env.Path, "snapshots", "gcloud", "credentials.db"))
+	assert.NoDirExists(t, filepath.Join(env.Path, "snapshots", "gcloud", "configurations"))
+	assert.FileExists(t, filepath.Join(env.Path, "snapshots", "git", "gitconfig"))
+
+	assert.Equal(t, []string{"laptop"}, env.ListSnapshotHosts())
+
+	// The machine-specific part counts in the size of the snapshot
+	assert.Equal(t, uint64(len("conf")+len("creds")), env.ToolSnapshotSize("gcloud"))
+	assert.Equal(t, uint64(len("kube")), env.ToolSnapshotSize("kubectl"))
+
+	t.Run("removes the overlay of paths gone from the snapshot", func(t *testing.T) {
+		writeSnapshotFile(t, filepath.Join(env.Path, "snapshots", "gcloud", "credentials.db"), "creds")
+		require.NoError(t, env.SeparateHostScoped("gcloud"))
+
+		assert.NoDirExists(t, filepath.Join(hostDir, "gcloud", "configurations"))
+
+		resolved, cleanup, err := env.ResolveToolSnapshot("gcloud")
+		require.NoError(t, err)
+		defer cleanup()
+		assert.NoDirExists(t, filepath.Join(resolved, "configurations"))
+		assert.FileExists(t, filepath.Join(resolved, "credentials.db"))
+	})
+}
+
+func TestSnapshotSize(t *testing.T) {
+	env := &Environment{Tools: map[string]ToolConfig{
+		"git":    {Enabled: true, SnapshotSize: 300},
+		"gcloud": {Enabled: true, SnapshotSize: 2000},
+		"docker": {Enabled: false, SnapshotSize: 5000},
+	}}
+	assert.Equal(t, uint64(2300), env.SnapshotSize())
+}
+
+func TestResolveToolSnapshot(t *testing.T) {
+	env := &Environment{Name: "work", Path: t.TempDir()}
+
+	writeSnapshotFile(t, filepath.Join(env.Path, "snapshots", "gcloud", "credentials.db"), "shared")
+	writeSnapshotFile(t, filepath.Join(env.Path, "snapshots", "gcloud", "active_config"), "shared")
+	writeSnapshotFile(t, filepath.Join(env.Path, "snapshots@laptop", "gcloud", "active_config"), "laptop")
+	writeSnapshotFile(t, filepath.Join(env.Path, "snapshots@desktop", "gcloud", "active_config"), "desktop")
+
+	t.Run("merges the overlay of the current machine", func(t *testing.T) {
+		stubHostname(t, "laptop")
+
+		path, cleanup, err := env.ResolveToolSnapshot("gcloud")
+		require.NoError(t, err)
+
+		data, err := os.ReadFile(filepath.Join(path, "active_config"))
+		require.NoError(t, err)
+		assert.Equal(t, "laptop", string(data))
+
+		data, err = os.ReadFile(filepath.Join(path, "credentials.db"))
+		require.NoError(t, err)
+		assert.Equal(t, "shared", string(data))
+
+		cleanup()
+		assert.NoDirExists(t, path)
+	})
+
+	t.Run("uses the shared snapshot on other machines", func(t *testing.T) {
+		stubHostname(t, "server")
+
+		path, cleanup, err := env.ResolveToolSnapshot("gcloud")
+		require.NoError(t, err)
+		defer cleanup()
+
+		assert.Equal(t, filepath.Join(env.Path, "snapshots", "gcloud"), path)
+	})
+
+	t.Run("overlay only", func(t *testing.T) {
+		stubHostname(t, "laptop")
+		writeSnapshotFile(t, filepath.Join(env.Path, "snapshots@laptop", "kubectl", "config"), "kube")
+
+		path, cleanup, err := env.ResolveToolSnapshot("kubectl")
+		require.NoError(t, err)
+		defer cleanup()
+
+		assert.FileExists(t, filepath.Join(path, "config"))
+	})
+}
@@ -0,0 +1,187 @@
+package environment
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hugofrely/envswitch/internal/keyring"
+	"github.com/hugofrely/envswitch/internal/storage"
+)
+
+const (
+	// KeyringService is the keyring service of the snapshot files envswitch
+	// stores there
+	KeyringService = "envswitch"
+	// keyringRefSuffix is appended to the name of a snapshot file stored in
+	// the keyring, for the reference left in its place
+	keyringRefSuffix = ".keyring"
+)
+
+// keyringRef is the content of the reference to a snapshot file stored in
+// the keyring. The account is the SHA-256 of the content, so backups and
+// copies of the snapshot keep pointing at the content they were taken with.
+type keyringRef struct {
+	Service string      `json:"service"`
+	Account string      `json:"account"`
+	Mode    os.FileMode `json:"mode"`
+}
+
+// MoveToKeyring stores the files of a freshly taken tool snapshot listed in
+// files ("aws/credentials") in the keyring, leaving a reference in their
+// place, and records them in KeyringFiles. A file that cannot be stored stays
+// in the snapshot and is reported in the returned error.
+func (e *Environment) MoveToKeyring(toolName string, files []string) error {
+	kept := e.KeyringFiles[:0:0]
+	for _, file := range e.KeyringFiles {
+		if _, ok := hostScopedPath(file, toolName); !ok {
+			kept = append(kept, file)
+		}
+	}
+	e.KeyringFiles = kept
+
+	var failed []string
+	for _, file := range files {
+		relPath, ok := hostScopedPath(file, toolName)
+		if !ok || relPath == "." {
+			continue
+		}
+
+		stored := false
+		for _, dir := range []string{filepath.Join(e.Path, "snapshots", toolName), filepath.Join(e.HostSnapshotsDir(), toolName)} {
+			path := filepath.Join(dir, relPath)
+			info, err := os.Stat(path)
+			if err != nil || !info.Mode().IsRegular() {
+				continue
+			}
+			if err := storeInKeyring(path, info.Mode().Perm()); err != nil {
+				failed = append(failed, fmt.Sprintf("%s: %v", file, err))
+				continue
+			}
+			stored = true
+		}
+		if stored {
+			e.KeyringFiles = append(e.KeyringFiles, filepath.ToSlash(filepath.Join(toolName, relPath)))
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("kept in the snapshot: %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// storeInKeyring replaces the file at path with a reference to its content
+// stored in the keyring
+func storeInKeyring(path string, mode os.FileMode) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256(data)
+	ref := keyringRef{Service: KeyringService, Account: "sha256:" + hex.EncodeToString(sum[:]), Mode: mode}
+	if err := keyring.Set(ref.Service, ref.Account, base64.StdEncoding.EncodeToString(data)); err != nil {
+		return err
+	}
+
+	refData, err := json.MarshalIndent(ref, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path+keyringRefSuffix, refData, 0600); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// hasKeyringFiles reports whether a snapshot directory of toolName holds
+// references to files stored in the keyring
+func (e *Environment) hasKeyringFiles(toolName, dir string) bool {
+	for _, file := range e.KeyringFiles {
+		relPath, ok := hostScopedPath(file, toolName)
+		if !ok {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dir, relPath+keyringRefSuffix)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// loadKeyringFiles replaces the references of a copy of a tool snapshot with
+// the files stored in the keyring
+func (e *Environment) loadKeyringFiles(toolName, dir string) error {
+	for _, file := range e.KeyringFiles {
+		relPath, ok := hostScopedPath(file, toolName)
+		if !ok {
+			continue
+		}
+		refPath := filepath.Join(dir, relPath+keyringRefSuffix)
+		refData, err := os.ReadFile(refPath)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		var ref keyringRef
+		if err := json.Unmarshal(refData, &ref); err != nil {
+			return fmt.Errorf("invalid keyring reference of %s: %w", file, err)
+		}
+		secret, err := keyring.Get(ref.Service, ref.Account)
+		if err != nil {
+			return fmt.Errorf("failed to read %s from the keyring: %w", file, err)
+		}
+		data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(secret))
+		if err != nil {
+			return fmt.Errorf("invalid keyring content of %s: %w", file, err)
+		}
+
+		mode := ref.Mode
+		if mode == 0 {
+			mode = 0600
+		}
+		if err := os.WriteFile(filepath.Join(dir, relPath), data, mode); err != nil {
+			return err
+		}
+		if err := os.Remove(refPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withKeyringFiles returns dir, or a temporary copy of it with the files
+// stored in the keyring when it holds references to them
+func (e *Environment) withKeyringFiles(toolName, dir string, copied bool, cleanup func()) (string, func(), error) {
+	if !e.hasKeyringFiles(toolName, dir) {
+		return dir, cleanup, nil
+	}
+
+	if !copied {
+		tmp, err := os.MkdirTemp("", "envswitch-"+toolName+"-*")
+		if err != nil {
+			return "", func() {}, fmt.Errorf("failed to create snapshot copy: %w", err)
+		}
+		cleanup = func() { _ = os.RemoveAll(tmp) }
+		if err := storage.CopyDir(dir, tmp); err != nil {
+			cleanup()
+			return "", func() {}, fmt.Errorf("failed to copy snapshot: %w", err)
+		}
+		dir = tmp
+	}
+
+	if err := e.loadKeyringFiles(toolName, dir); err != nil {
+		cleanup()
+		return "", func() {}, err
+	}
+	return dir, cleanup, nil
+}
@@ -0,0 +1,56 @@
+package environment
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hugofrely/envswitch/internal/keyring"
+)
+
+func TestMoveToKeyring(t *testing.T) {
+	keyring.MockInit()
+	stubHostname(t, "laptop")
+
+	env := &Environment{Name: "work", Path: t.TempDir()}
+	snapshotDir := filepath.Join(env.Path, "snapshots", "aws")
+	writeSnapshotFile(t, filepath.Join(snapshotDir, "credentials"), "[default]\nsecret")
+	writeSnapshotFile(t, filepath.Join(snapshotDir, "config"), "[default]\nregion")
+
+	require.NoError(t, env.MoveToKeyring("aws", []string{"aws/credentials", "docker/config.json"}))
+	assert.Equal(t, []string{"aws/credentials"}, env.KeyringFiles)
+	assert.NoFileExists(t, filepath.Join(snapshotDir, "credentials"))
+	assert.FileExists(t, filepath.Join(snapshotDir, "credentials.keyring"))
+	assert.FileExists(t, filepath.Join(snapshotDir, "config"))
+
+	t.Run("resolves the files from the keyring", func(t *testing.T) {
+		path, cleanup, err := env.ResolveToolSnapshot("aws")
+		require.NoError(t, err)
+		assert.NotEqual(t, snapshotDir, path)
+
+		data, err := os.ReadFile(filepath.Join(path, "credentials"))
+		require.NoError(t, err)
+		assert.Equal(t, "[default]\nsecret", string(data))
+		assert.NoFileExists(t, filepath.Join(path, "credentials.keyring"))
+
+		cleanup()
+		assert.NoDirExists(t, path)
+		assert.FileExists(t, filepath.Join(snapshotDir, "credentials.keyring"))
+	})
+
+	t.Run("fails when the keyring lost the file", func(t *testing.T) {
+		keyring.MockInit()
+		t.Cleanup(func() { keyring.MockInit() })
+
+		_, _, err := env.ResolveToolSnapshot("aws")
+		assert.Error(t, err)
+	})
+
+	t.Run("a new snapshot without sensitive files resets the entries", func(t *testing.T) {
+		require.NoError(t, env.MoveToKeyring("aws", nil))
+		assert.Empty(t, env.KeyringFiles)
+	})
+}
@@ -0,0 +1,20 @@
+package environment
+
+import "path"
+
+// KubeContextAllowed reports whether the environment allows a kubectl
+// context, always true without allowed_contexts
+func (e *Environment) KubeContextAllowed(context string) bool {
+	if len(e.Kubectl.AllowedContexts) == 0 {
+		return true
+	}
+	for _, allowed := range e.Kubectl.AllowedContexts {
+		if allowed == context {
+			return true
+		}
+		if matched, err := path.Match(allowed, context); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
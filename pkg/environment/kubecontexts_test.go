@@ -0,0 +1,19 @@
+package environment
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKubeContextAllowed(t *testing.T) {
+	env := &Environment{}
+	assert.True(t, env.KubeContextAllowed("anything"), "any context without an allowlist")
+
+	env.Kubectl.AllowedContexts = []string{"staging", "dev-*"}
+	assert.True(t, env.KubeContextAllowed("staging"))
+	assert.True(t, env.KubeContextAllowed("dev-eu"))
+	assert.False(t, env.KubeContextAllowed("prod"))
+	assert.False(t, env.KubeContextAllowed("staging-2"))
+	assert.False(t, env.KubeContextAllowed(""))
+}
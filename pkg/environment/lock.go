@@ -0,0 +1,46 @@
+package environment
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/hugofrely/envswitch/internal/storage"
+)
+
+// locksDir holds the metadata locks of the environments, outside their
+// directories so that neither sync nor export carries them
+const locksDir = "locks"
+
+// Lock takes the lock of the environment metadata, waiting while another
+// process holds it. Saving a snapshot of the live config loads, changes and
+// saves metadata.yaml: 'save', 'switch' and 'envswitch daemon' hold the lock
+// meanwhile so that the last one to save does not drop what the others
+// recorded. The rename of Save already keeps the file whole.
+func (e *Environment) Lock() (func(), error) {
+	dir, err := GetEnvswitchDir()
+	if err != nil {
+		return nil, err
+	}
+
+	unlock, err := storage.LockFile(filepath.Join(dir, locksDir, filepath.FromSlash(e.Name)+".lock"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock environment '%s': %w", e.Name, err)
+	}
+	return unlock, nil
+}
+
+// LockEnvironment takes the lock of an environment, see Lock, and loads its
+// metadata as saved by the previous holder
+func LockEnvironment(name string) (*Environment, func(), error) {
+	unlock, err := (&Environment{Name: name}).Lock()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	env, err := LoadEnvironment(name)
+	if err != nil {
+		unlock()
+		return nil, nil, err
+	}
+	return env, unlock, nil
+}
@@ -0,0 +1,60 @@
+package environment
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockEnvironment(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+
+	envPath := filepath.Join(tempHome, ".envswitch", "environments", "client", "prod")
+	require.NoError(t, os.MkdirAll(envPath, 0700))
+	require.NoError(t, (&Environment{Name: "client/prod", Path: envPath}).Save())
+
+	env, unlock, err := LockEnvironment("client/prod")
+	require.NoError(t, err)
+	assert.Equal(t, "client/prod", env.Name)
+
+	// A second holder gets the metadata the first one saved
+	loaded := make(chan *Environment)
+	go func() {
+		env, unlock, err := LockEnvironment("client/prod")
+		if err != nil {
+			t.Errorf("LockEnvironment failed: %v", err)
+			close(loaded)
+			return
+		}
+		unlock()
+		loaded <- env
+	}()
+
+	env.Description = "saved while locked"
+	require.NoError(t, env.Save())
+	select {
+	case <-loaded:
+		t.Fatal("the environment was locked twice")
+	case <-time.After(100 * time.Millisecond):
+	}
+	unlock()
+
+	select {
+	case second := <-loaded:
+		require.NotNil(t, second)
+		assert.Equal(t, "saved while locked", second.Description)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the lock was not taken once released")
+	}
+
+	// The lock stays out of the environment directory
+	assert.FileExists(t, filepath.Join(tempHome, ".envswitch", "locks", "client", "prod.lock"))
+	entries, err := os.ReadDir(envPath)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
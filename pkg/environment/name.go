@@ -0,0 +1,327 @@
+package environment
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// MaxNameLength is the maximum length of an environment name
+const MaxNameLength = 64
+
+// NormalizeName returns the canonical form of an environment name. Names are
+// lowercase so that they map to a single directory on case-insensitive
+// filesystems (macOS, Windows).
+func NormalizeName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// GroupSeparator separates the group and the name of grouped environments
+// such as "client-a/prod", which are stored in nested directories
+const GroupSeparator = "/"
+
+// ValidateName checks that name is a valid, normalized environment name:
+// lowercase letters, digits, '-', '_' and '.', starting with a letter or digit.
+// Grouped names join several such segments with '/'.
+func ValidateName(name string) error {
+	if name == "" {
+		return fmt.Errorf("environment name cannot be empty")
+	}
+	if normalized := NormalizeName(name); normalized != name {
+		return fmt.Errorf("environment name '%s' must be lowercase without surrounding spaces (use '%s')", name, normalized)
+	}
+
+	offset := 0
+	for _, segment := range strings.Split(name, GroupSeparator) {
+		if segment == "" {
+			return fmt.Errorf("environment name '%s' contains an empty group (use 'group/name')", name)
+		}
+		if len(segment) > MaxNameLength {
+			return fmt.Errorf("environment name '%s' is too long (%d characters, maximum %d)", name, len(segment), MaxNameLength)
+		}
+
+		for i, r := range segment {
+			switch {
+			case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			case r == '-' || r == '_' || r == '.':
+				if i == 0 {
+					return fmt.Errorf("environment name '%s' must start with a letter or a digit", name)
+				}
+			default:
+				return fmt.Errorf("environment name '%s' contains invalid character %q at position %d (allowed: a-z, 0-9, '-', '_', '.', '/')", name, r, offset+i+1)
+			}
+		}
+		offset += len(segment) + len(GroupSeparator)
+	}
+
+	return nil
+}
+
+// GroupOf returns the group of an environment name ("client-a" for
+// "client-a/prod"), or an empty string for ungrouped names
+func GroupOf(name string) string {
+	if i := strings.LastIndex(name, GroupSeparator); i >= 0 {
+		return name[:i]
+	}
+	return ""
+}
+
+// FlatName returns a name usable as a single file name, for archives of
+// grouped environments
+func FlatName(name string) string {
+	return strings.ReplaceAll(name, GroupSeparator, "__")
+}
+
+// IsPattern reports whether name is a wildcard pattern such as "client-a/*"
+func IsPattern(name string) bool {
+	return strings.ContainsAny(name, "*?[")
+}
+
+// ExpandNames replaces wildcard patterns with the names of the matching
+// environments. '*' does not cross groups: "client-a/*" matches
+// "client-a/prod" but not "client-a/eu/prod".
+func ExpandNames(names []string) ([]string, error) {
+	var envs []*Environment
+	var expanded []string
+	seen := make(map[string]bool)
+
+	for _, name := range names {
+		if !IsPattern(name) {
+			if !seen[name] {
+				seen[name] = true
+				expanded = append(expanded, name)
+			}
+			continue
+		}
+
+		if envs == nil {
+			var err error
+			if envs, err = ListEnvironments(); err != nil {
+				return nil, err
+			}
+		}
+
+		matched := false
+		for _, env := range envs {
+			ok, err := path.Match(NormalizeName(name), env.Name)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern '%s': %w", name, err)
+			}
+			if ok {
+				matched = true
+				if !seen[env.Name] {
+					seen[env.Name] = true
+					expanded = append(expanded, env.Name)
+				}
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("no environment matches '%s'", name)
+		}
+	}
+
+	return expanded, nil
+}
+
+// resolveEnvironmentDir returns the path of an environment relative to
+// envDir, matching each group case-insensitively when there is no exact match
+func resolveEnvironmentDir(envDir, name string) string {
+	segments := strings.Split(name, GroupSeparator)
+	dir := envDir
+	for i, segment := range segments {
+		segments[i] = resolveDirEntry(dir, segment)
+		dir = filepath.Join(dir, segments[i])
+	}
+	return filepath.Join(segments...)
+}
+
+// resolveDirEntry returns the entry of dir named name, ignoring case when
+// there is no exact match
+func resolveDirEntry(dir, name string) string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return name
+	}
+
+	match := ""
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if entry.Name() == name {
+			return name
+		}
+		if match == "" && strings.EqualFold(entry.Name(), name) {
+			match = entry.Name()
+		}
+	}
+
+	if match != "" {
+		return match
+	}
+	return name
+}
+
+// EnvironmentExists reports whether an environment with the same name,
+// ignoring case, already exists
+func EnvironmentExists(name string) (bool, error) {
+	envDir, err := GetEnvironmentsDir()
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := os.Stat(envDir); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to read environments directory: %w", err)
+	}
+
+	_, err = os.Stat(filepath.Join(envDir, resolveEnvironmentDir(envDir, name)))
+	return err == nil, nil
+}
+
+// CheckGroups returns an error if name cannot be created because one of its
+// groups is an environment, or because name itself is a group
+func CheckGroups(name string) error {
+	envDir, err := GetEnvironmentsDir()
+	if err != nil {
+		return err
+	}
+
+	segments := strings.Split(name, GroupSeparator)
+	for i := 1; i < len(segments); i++ {
+		group := strings.Join(segments[:i], GroupSeparator)
+		if isEnvironmentDir(filepath.Join(envDir, resolveEnvironmentDir(envDir, group))) {
+			return fmt.Errorf("'%s' is an environment and cannot contain other environments", group)
+		}
+	}
+
+	envPath := filepath.Join(envDir, resolveEnvironmentDir(envDir, name))
+	if info, err := os.Stat(envPath); err == nil && info.IsDir() && !isEnvironmentDir(envPath) {
+		return fmt.Errorf("'%s' is a group of environments", name)
+	}
+	return nil
+}
+
+// isEnvironmentDir reports whether dir holds an environment rather than a
+// group of environments
+func isEnvironmentDir(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, "metadata.yaml"))
+	return err == nil
+}
+
+// RemoveEmptyGroups removes the group directories left empty after the
+// environment at envPath was deleted
+func RemoveEmptyGroups(envPath string) {
+	envDir, err := GetEnvironmentsDir()
+	if err != nil {
+		return
+	}
+
+	for dir := filepath.Dir(envPath); strings.HasPrefix(dir, envDir+string(filepath.Separator)); dir = filepath.Dir(dir) {
+		// os.Remove fails on directories that still have entries
+		if os.Remove(dir) != nil {
+			return
+		}
+	}
+}
+
+// NameMigration describes the rename of an environment to its normalized name
+type NameMigration struct {
+	From     string
+	To       string
+	Conflict bool // another environment already used the normalized name
+}
+
+// PlanNameMigrations lists the environments whose directory name is not
+// normalized. Names that collide once lowercased get a numeric suffix.
+func PlanNameMigrations() ([]NameMigration, error) {
+	envDir, err := GetEnvironmentsDir()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(envDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read environments directory: %w", err)
+	}
+
+	taken := make(map[string]bool)
+	var pending []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if entry.Name() == NormalizeName(entry.Name()) {
+			taken[entry.Name()] = true
+		} else if isEnvironmentDir(filepath.Join(envDir, entry.Name())) {
+			pending = append(pending, entry.Name())
+		}
+	}
+	sort.Strings(pending)
+
+	migrations := make([]NameMigration, 0, len(pending))
+	for _, from := range pending {
+		to := NormalizeName(from)
+		conflict := taken[to]
+		for i := 2; taken[to]; i++ {
+			to = fmt.Sprintf("%s-%d", NormalizeName(from), i)
+		}
+		taken[to] = true
+
+		migrations = append(migrations, NameMigration{From: from, To: to, Conflict: conflict})
+	}
+
+	return migrations, nil
+}
+
+// ApplyNameMigration renames an environment directory and updates its
+// metadata and current.lock
+func ApplyNameMigration(migration NameMigration) error {
+	envDir, err := GetEnvironmentsDir()
+	if err != nil {
+		return err
+	}
+
+	oldPath := filepath.Join(envDir, migration.From)
+	newPath := filepath.Join(envDir, migration.To)
+
+	// Rename through a temporary name: on case-insensitive filesystems
+	// renaming "Work" to "work" directly is a no-op or an error
+	tmpPath := filepath.Join(envDir, "."+migration.To+".migrating")
+	if err := os.Rename(oldPath, tmpPath); err != nil {
+		return fmt.Errorf("failed to rename '%s': %w", migration.From, err)
+	}
+	if err := os.Rename(tmpPath, newPath); err != nil {
+		_ = os.Rename(tmpPath, oldPath)
+		return fmt.Errorf("failed to rename '%s' to '%s': %w", migration.From, migration.To, err)
+	}
+
+	env, err := LoadEnvironment(migration.To)
+	if err != nil {
+		return fmt.Errorf("failed to load migrated environment '%s': %w", migration.To, err)
+	}
+	env.Name = migration.To
+	if err := env.Save(); err != nil {
+		return fmt.Errorf("failed to update metadata of '%s': %w", migration.To, err)
+	}
+
+	// Keep the active environment pointing at the renamed directory
+	dir, err := GetEnvswitchDir()
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(filepath.Join(dir, currentLockFile))
+	if err == nil && strings.TrimSpace(string(data)) == migration.From {
+		return SetCurrentEnvironment(migration.To)
+	}
+
+	return nil
+}
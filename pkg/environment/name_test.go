@@ -0,0 +1,167 @@
+package environment
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeName(t *testing.T) {
+	assert.Equal(t, "work", NormalizeName("Work"))
+	assert.Equal(t, "client-a", NormalizeName("  CLIENT-A "))
+}
+
+func TestValidateName(t *testing.T) {
+	valid := []string{"work", "client-a", "prod_eu", "v1.2", "2024", "client-a/prod", "client-a/eu/dev"}
+	for _, name := range valid {
+		assert.NoError(t, ValidateName(name), name)
+	}
+
+	tests := []struct {
+		name    string
+		message string
+	}{
+		{"", "cannot be empty"},
+		{"Work", "use 'work'"},
+		{" work", "use 'work'"},
+		{"-work", "must start with a letter or a digit"},
+		{".hidden", "must start with a letter or a digit"},
+		{"my env", "invalid character ' ' at position 3"},
+		{"a//b", "contains an empty group"},
+		{"/work", "contains an empty group"},
+		{"work/", "contains an empty group"},
+		{"a/-b", "must start with a letter or a digit"},
+		{"a/b c", "invalid character ' ' at position 4"},
+		{"café", "invalid character 'é'"},
+		{strings.Repeat("a", MaxNameLength+1), "too long"},
+	}
+	for _, tt := range tests {
+		err := ValidateName(tt.name)
+		require.Error(t, err, tt.name)
+		assert.Contains(t, err.Error(), tt.message)
+	}
+}
+
+func createNamedEnv(t *testing.T, envsDir, dirName string) {
+	env := &Environment{Name: dirName, Path: filepath.Join(envsDir, dirName), Tools: map[string]ToolConfig{}}
+	require.NoError(t, os.MkdirAll(env.Path, 0755))
+	require.NoError(t, env.Save())
+}
+
+func TestLoadEnvironmentCaseInsensitive(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+
+	envsDir := filepath.Join(tempHome, ".envswitch", "environments")
+	createNamedEnv(t, envsDir, "Work")
+
+	env, err := LoadEnvironment("work")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(envsDir, "Work"), env.Path)
+
+	exists, err := EnvironmentExists("WORK")
+	require.NoError(t, err)
+	assert.True(t, exists)
+}
+
+func TestNameMigration(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+
+	envsDir := filepath.Join(tempHome, ".envswitch", "environments")
+	createNamedEnv(t, envsDir, "work")
+	createNamedEnv(t, envsDir, "Work")
+	createNamedEnv(t, envsDir, "Perso")
+	require.NoError(t, SetCurrentEnvironment("Perso"))
+
+	migrations, err := PlanNameMigrations()
+	require.NoError(t, err)
+	assert.Equal(t, []NameMigration{
+		{From: "Perso", To: "perso"},
+		{From: "Work", To: "work-2", Conflict: true},
+	}, migrations)
+
+	for _, m := range migrations {
+		require.NoError(t, ApplyNameMigration(m))
+	}
+
+	env, err := LoadEnvironment("work-2")
+	require.NoError(t, err)
+	assert.Equal(t, "work-2", env.Name)
+
+	current, err := GetCurrentEnvironment()
+	require.NoError(t, err)
+	require.NotNil(t, current)
+	assert.Equal(t, "perso", current.Name)
+
+	migrations, err = PlanNameMigrations()
+	require.NoError(t, err)
+	assert.Empty(t, migrations)
+}
+
+func TestGroupedEnvironments(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+
+	envsDir := filepath.Join(tempHome, ".envswitch", "environments")
+	createNamedEnv(t, envsDir, "personal")
+	createNamedEnv(t, envsDir, "client-a/prod")
+	createNamedEnv(t, envsDir, "client-a/dev")
+	createNamedEnv(t, envsDir, "client-a/eu/prod")
+
+	t.Run("lists environments inside groups", func(t *testing.T) {
+		envs, err := ListEnvironments()
+		require.NoError(t, err)
+
+		var names []string
+		for _, env := range envs {
+			names = append(names, env.Name)
+		}
+		assert.Equal(t, []string{"client-a/dev", "client-a/eu/prod", "client-a/prod", "personal"}, names)
+	})
+
+	t.Run("loads grouped environment ignoring case", func(t *testing.T) {
+		env, err := LoadEnvironment("Client-A/Prod")
+		require.NoError(t, err)
+		assert.Equal(t, filepath.Join(envsDir, "client-a", "prod"), env.Path)
+	})
+
+	t.Run("rejects groups that clash with environments", func(t *testing.T) {
+		assert.ErrorContains(t, CheckGroups("personal/dev"), "'personal' is an environment")
+		assert.ErrorContains(t, CheckGroups("client-a"), "'client-a' is a group")
+		assert.NoError(t, CheckGroups("client-a/staging"))
+		assert.NoError(t, CheckGroups("client-b/prod"))
+	})
+
+	t.Run("expands wildcard patterns", func(t *testing.T) {
+		names, err := ExpandNames([]string{"client-a/*", "personal", "client-a/prod"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"client-a/dev", "client-a/prod", "personal"}, names)
+
+		_, err = ExpandNames([]string{"client-b/*"})
+		assert.ErrorContains(t, err, "no environment matches")
+	})
+
+	t.Run("removes empty groups", func(t *testing.T) {
+		envPath := filepath.Join(envsDir, "client-a", "eu", "prod")
+		require.NoError(t, os.RemoveAll(envPath))
+
+		RemoveEmptyGroups(envPath)
+
+		assert.NoDirExists(t, filepath.Join(envsDir, "client-a", "eu"))
+		assert.DirExists(t, filepath.Join(envsDir, "client-a"))
+	})
+}
+
+func TestGroupHelpers(t *testing.T) {
+	assert.Equal(t, "client-a", GroupOf("client-a/prod"))
+	assert.Equal(t, "client-a/eu", GroupOf("client-a/eu/prod"))
+	assert.Equal(t, "", GroupOf("work"))
+	assert.Equal(t, "client-a__prod", FlatName("client-a/prod"))
+	assert.True(t, IsPattern("client-a/*"))
+	assert.False(t, IsPattern("client-a/prod"))
+}
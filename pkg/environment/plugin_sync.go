@@ -33,6 +33,9 @@ func SyncPluginsToEnvironments() error {
 		// Pour chaque plugin
 		for _, p := range plugins {
 			toolName := p.Metadata.ToolName
+			if !env.PluginEnabled(p.Metadata.Name) {
+				continue
+			}
 
 			// Vérifier si le tool existe déjà dans l'environnement
 			if _, exists := env.Tools[toolName]; !exists {
@@ -84,7 +87,7 @@ func SyncPluginsOnLoad(envName string) (*Environment, error) {
 
 	modified := false
 	for _, p := range plugins {
-		if EnsurePluginInEnvironment(env, p.Metadata.ToolName) {
+		if env.PluginEnabled(p.Metadata.Name) && EnsurePluginInEnvironment(env, p.Metadata.ToolName) {
 			modified = true
 		}
 	}
@@ -95,3 +98,29 @@ func SyncPluginsOnLoad(envName string) (*Environment, error) {
 
 	return env, nil
 }
+
+// PluginEnabled reports whether the plugin is enabled in the environment.
+// Plugins are enabled unless the plugins section disables them.
+func (e *Environment) PluginEnabled(name string) bool {
+	enabled, listed := e.Plugins[name]
+	return !listed || enabled
+}
+
+// SetPluginEnabled enables or disables a plugin and the tool it provides in
+// the environment. The snapshot of a disabled tool is kept.
+func (e *Environment) SetPluginEnabled(name, toolName string, enabled bool) {
+	if e.Plugins == nil {
+		e.Plugins = make(map[string]bool)
+	}
+	e.Plugins[name] = enabled
+
+	if e.Tools == nil {
+		e.Tools = make(map[string]ToolConfig)
+	}
+	config := e.Tools[toolName]
+	config.Enabled = enabled
+	if config.SnapshotPath == "" {
+		config.SnapshotPath = fmt.Sprintf("snapshots/%s", toolName)
+	}
+	e.Tools[toolName] = config
+}
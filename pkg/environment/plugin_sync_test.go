@@ -0,0 +1,57 @@
+package environment
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncPluginsSkipsDisabledPlugins(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+
+	pluginDir := filepath.Join(tempHome, ".envswitch", "plugins", "terraform")
+	require.NoError(t, os.MkdirAll(pluginDir, 0755))
+	manifest := "metadata:\n  name: terraform\n  version: 1.0.0\n  tool_name: tf\n"
+	require.NoError(t, os.WriteFile(filepath.Join(pluginDir, "plugin.yaml"), []byte(manifest), 0644))
+
+	envsDir := filepath.Join(tempHome, ".envswitch", "environments")
+	for _, name := range []string{"work", "personal"} {
+		env := &Environment{Name: name, Path: filepath.Join(envsDir, name), Tools: map[string]ToolConfig{}}
+		if name == "personal" {
+			env.Plugins = map[string]bool{"terraform": false}
+		}
+		require.NoError(t, os.MkdirAll(env.Path, 0755))
+		require.NoError(t, env.Save())
+	}
+
+	require.NoError(t, SyncPluginsToEnvironments())
+
+	work, err := LoadEnvironment("work")
+	require.NoError(t, err)
+	assert.True(t, work.Tools["tf"].Enabled)
+	assert.True(t, work.PluginEnabled("terraform"))
+
+	personal, err := SyncPluginsOnLoad("personal")
+	require.NoError(t, err)
+	assert.NotContains(t, personal.Tools, "tf")
+	assert.False(t, personal.PluginEnabled("terraform"))
+}
+
+func TestSetPluginEnabled(t *testing.T) {
+	env := &Environment{}
+
+	env.SetPluginEnabled("terraform", "tf", false)
+	assert.False(t, env.PluginEnabled("terraform"))
+	assert.False(t, env.Tools["tf"].Enabled)
+	assert.Equal(t, "snapshots/tf", env.Tools["tf"].SnapshotPath)
+
+	env.SetPluginEnabled("terraform", "tf", true)
+	assert.True(t, env.PluginEnabled("terraform"))
+	assert.True(t, env.Tools["tf"].Enabled)
+
+	assert.True(t, env.PluginEnabled("vim"), "unlisted plugins are enabled")
+}
@@ -0,0 +1,73 @@
+package environment
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hugofrely/envswitch/internal/storage"
+)
+
+const promptStatusFile = "prompt-status.json"
+
+// PromptStatus is the drift of the active environment as last computed. The
+// shell prompt reads it instead of diffing the live config on every prompt.
+type PromptStatus struct {
+	Environment string    `json:"environment"`
+	Dirty       bool      `json:"dirty"`
+	CheckedAt   time.Time `json:"checked_at"`
+}
+
+// Fresh reports whether the status was computed for the environment name
+// less than maxAge ago
+func (s *PromptStatus) Fresh(name string, maxAge time.Duration, now time.Time) bool {
+	return s != nil && s.Environment == name && now.Sub(s.CheckedAt) < maxAge
+}
+
+// LoadPromptStatus returns the cached prompt status, nil when there is none
+// or it cannot be read
+func LoadPromptStatus() (*PromptStatus, error) {
+	dir, err := GetEnvswitchDir()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, promptStatusFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", promptStatusFile, err)
+	}
+
+	var status PromptStatus
+	if err := json.Unmarshal(data, &status); err != nil {
+		// A corrupted cache is recomputed
+		return nil, nil
+	}
+	return &status, nil
+}
+
+// SavePromptStatus caches the prompt status. The file is replaced atomically
+// since prompts read it while it is refreshed.
+func SavePromptStatus(status PromptStatus) error {
+	dir, err := GetEnvswitchDir()
+	if err != nil {
+		return err
+	}
+	if err := storage.MkdirPrivate(dir); err != nil {
+		return fmt.Errorf("failed to create envswitch directory: %w", err)
+	}
+
+	data, err := json.Marshal(status)
+	if err != nil {
+		return fmt.Errorf("failed to marshal prompt status: %w", err)
+	}
+
+	if err := storage.WriteFileAtomic(filepath.Join(dir, promptStatusFile), data, 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", promptStatusFile, err)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package environment
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPromptStatus(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+
+	status, err := LoadPromptStatus()
+	require.NoError(t, err)
+	assert.Nil(t, status)
+	assert.False(t, status.Fresh("work", time.Minute, time.Now()))
+
+	checkedAt := time.Now().Round(time.Second)
+	require.NoError(t, SavePromptStatus(PromptStatus{Environment: "work", Dirty: true, CheckedAt: checkedAt}))
+
+	// The store it creates is only readable by the current user
+	info, err := os.Stat(filepath.Join(tempHome, ".envswitch"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0700), info.Mode().Perm())
+
+	status, err = LoadPromptStatus()
+	require.NoError(t, err)
+	require.NotNil(t, status)
+	assert.Equal(t, "work", status.Environment)
+	assert.True(t, status.Dirty)
+	assert.True(t, status.CheckedAt.Equal(checkedAt))
+
+	assert.True(t, status.Fresh("work", time.Minute, checkedAt.Add(time.Second)))
+	assert.False(t, status.Fresh("work", time.Minute, checkedAt.Add(2*time.Minute)), "too old")
+	assert.False(t, status.Fresh("home", time.Minute, checkedAt), "another environment")
+
+	// A corrupted cache is recomputed
+	require.NoError(t, os.WriteFile(filepath.Join(tempHome, ".envswitch", promptStatusFile), []byte("{"), 0644))
+	status, err = LoadPromptStatus()
+	require.NoError(t, err)
+	assert.Nil(t, status)
+}
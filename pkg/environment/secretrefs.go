@@ -0,0 +1,179 @@
+package environment
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// SecretResolver reads the values of variables referencing a secret manager,
+// like op://vault/item/field, so that the secret never lives in the snapshots
+type SecretResolver interface {
+	// Name of the secret manager, for messages
+	Name() string
+	// Handles reports whether value is a reference the resolver reads
+	Handles(value string) bool
+	// Resolve returns the secret value referenced by ref
+	Resolve(ref string) (string, error)
+}
+
+var (
+	secretResolversMu sync.Mutex
+	// secretResolvers are tried in order on each value
+	secretResolvers = []SecretResolver{
+		onePasswordResolver{},
+		vaultResolver{},
+	}
+	// resolvedSecrets keeps the values read during the process, so a switch
+	// asks the secret managers once
+	resolvedSecrets = make(map[string]string)
+)
+
+// runResolverCommand runs the CLI of a secret manager and returns its
+// output, replaced in tests
+var runResolverCommand = func(name string, args ...string) ([]byte, error) {
+	if _, err := exec.LookPath(name); err != nil {
+		return nil, fmt.Errorf("%s is not installed", name)
+	}
+	var stderr bytes.Buffer
+	cmd := exec.Command(name, args...)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return nil, fmt.Errorf("%s: %s", name, message)
+		}
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return output, nil
+}
+
+// RegisterSecretResolver adds a resolver, tried before the built-in ones
+func RegisterSecretResolver(resolver SecretResolver) {
+	secretResolversMu.Lock()
+	defer secretResolversMu.Unlock()
+	secretResolvers = append([]SecretResolver{resolver}, secretResolvers...)
+}
+
+// secretResolverFor returns the resolver handling value, nil when value is
+// not a reference
+func secretResolverFor(value string) SecretResolver {
+	secretResolversMu.Lock()
+	defer secretResolversMu.Unlock()
+	for _, resolver := range secretResolvers {
+		if resolver.Handles(value) {
+			return resolver
+		}
+	}
+	return nil
+}
+
+// IsSecretReference reports whether value references a secret manager
+func IsSecretReference(value string) bool {
+	return secretResolverFor(value) != nil
+}
+
+// KeepSecretReferences returns captured, the variables captured from the
+// shell, with the ones the environment stores as references to secret
+// managers set back to their reference rather than the value they resolved to
+func (e *Environment) KeepSecretReferences(captured []EnvVar) ([]EnvVar, error) {
+	stored, err := e.LoadEnvVars()
+	if err != nil {
+		return nil, err
+	}
+	for i, envVar := range captured {
+		if j := findEnvVar(stored, envVar.Key); j >= 0 && IsSecretReference(stored[j].Value) {
+			captured[i].Value = stored[j].Value
+		}
+	}
+	return captured, nil
+}
+
+// ResolveEnvVars returns envVars with the references to secret managers
+// replaced by their values. The variables that cannot be resolved are left
+// out and reported in the error, the others are returned all the same.
+func ResolveEnvVars(envVars []EnvVar) ([]EnvVar, error) {
+	resolved := make([]EnvVar, 0, len(envVars))
+	var failed []string
+	for _, envVar := range envVars {
+		resolver := secretResolverFor(envVar.Value)
+		if resolver == nil {
+			resolved = append(resolved, envVar)
+			continue
+		}
+		value, err := resolveSecret(resolver, envVar.Value)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s (%s): %v", envVar.Key, resolver.Name(), err))
+			continue
+		}
+		resolved = append(resolved, EnvVar{Key: envVar.Key, Value: value})
+	}
+
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		return resolved, fmt.Errorf("failed to resolve %s", strings.Join(failed, "; "))
+	}
+	return resolved, nil
+}
+
+// resolveSecret resolves ref with resolver, once per process
+func resolveSecret(resolver SecretResolver, ref string) (string, error) {
+	secretResolversMu.Lock()
+	value, ok := resolvedSecrets[ref]
+	secretResolversMu.Unlock()
+	if ok {
+		return value, nil
+	}
+
+	value, err := resolver.Resolve(ref)
+	if err != nil {
+		return "", err
+	}
+	secretResolversMu.Lock()
+	resolvedSecrets[ref] = value
+	secretResolversMu.Unlock()
+	return value, nil
+}
+
+// onePasswordResolver reads op://vault/item/field references with the
+// 1Password CLI
+type onePasswordResolver struct{}
+
+func (onePasswordResolver) Name() string { return "1Password" }
+
+func (onePasswordResolver) Handles(value string) bool {
+	return strings.HasPrefix(value, "op://")
+}
+
+func (onePasswordResolver) Resolve(ref string) (string, error) {
+	output, err := runResolverCommand("op", "read", "--no-newline", ref)
+	if err != nil {
+		return "", err
+	}
+	return string(output), nil
+}
+
+// vaultResolver reads vault:secret/path#key references from a KV engine
+// with the Vault CLI
+type vaultResolver struct{}
+
+func (vaultResolver) Name() string { return "Vault" }
+
+func (vaultResolver) Handles(value string) bool {
+	return strings.HasPrefix(value, "vault:")
+}
+
+func (vaultResolver) Resolve(ref string) (string, error) {
+	path, field, ok := strings.Cut(strings.TrimPrefix(ref, "vault:"), "#")
+	if !ok || path == "" || field == "" {
+		return "", fmt.Errorf("invalid reference '%s', expected vault:<path>#<key>", ref)
+	}
+	output, err := runResolverCommand("vault", "kv", "get", "-field="+field, path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(output), "\n"), nil
+}
@@ -0,0 +1,112 @@
+package environment
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeResolverCommands replaces the CLIs of the secret managers with the
+// outputs of commands, recording the calls
+func fakeResolverCommands(t *testing.T, outputs map[string]string) *[]string {
+	t.Helper()
+	var calls []string
+	original := runResolverCommand
+	runResolverCommand = func(name string, args ...string) ([]byte, error) {
+		command := strings.Join(append([]string{name}, args...), " ")
+		calls = append(calls, command)
+		output, ok := outputs[command]
+		if !ok {
+			return nil, errors.New("not found")
+		}
+		return []byte(output), nil
+	}
+	resolvedSecrets = make(map[string]string)
+	t.Cleanup(func() {
+		runResolverCommand = original
+		resolvedSecrets = make(map[string]string)
+	})
+	return &calls
+}
+
+type prefixResolver struct{ prefix string }
+
+func (r prefixResolver) Name() string { return "test" }
+
+func (r prefixResolver) Handles(value string) bool { return strings.HasPrefix(value, r.prefix) }
+
+func (r prefixResolver) Resolve(ref string) (string, error) {
+	return strings.ToUpper(strings.TrimPrefix(ref, r.prefix)), nil
+}
+
+func TestResolveEnvVars(t *testing.T) {
+	t.Run("reads references with op and vault", func(t *testing.T) {
+		calls := fakeResolverCommands(t, map[string]string{
+			"op read --no-newline op://work/github/token": "ghp_123",
+			"vault kv get -field=password secret/db":      "hunter2\n",
+		})
+
+		envVars, err := ResolveEnvVars([]EnvVar{
+			{Key: "GITHUB_TOKEN", Value: "op://work/github/token"},
+			{Key: "DB_PASSWORD", Value: "vault:secret/db#password"},
+			{Key: "REGION", Value: "eu-west-1"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []EnvVar{
+			{Key: "GITHUB_TOKEN", Value: "ghp_123"},
+			{Key: "DB_PASSWORD", Value: "hunter2"},
+			{Key: "REGION", Value: "eu-west-1"},
+		}, envVars)
+
+		// Read once per process
+		_, err = ResolveEnvVars([]EnvVar{{Key: "GITHUB_TOKEN", Value: "op://work/github/token"}})
+		require.NoError(t, err)
+		assert.Len(t, *calls, 2)
+	})
+
+	t.Run("leaves out the variables that cannot be read", func(t *testing.T) {
+		fakeResolverCommands(t, nil)
+
+		envVars, err := ResolveEnvVars([]EnvVar{
+			{Key: "GITHUB_TOKEN", Value: "op://work/github/token"},
+			{Key: "DB_PASSWORD", Value: "vault:secret/db"},
+			{Key: "REGION", Value: "eu-west-1"},
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "GITHUB_TOKEN (1Password): not found")
+		assert.Contains(t, err.Error(), "DB_PASSWORD (Vault): invalid reference")
+		assert.Equal(t, []EnvVar{{Key: "REGION", Value: "eu-west-1"}}, envVars)
+	})
+
+	t.Run("uses registered resolvers", func(t *testing.T) {
+		original := secretResolvers
+		defer func() { secretResolvers = original }()
+		RegisterSecretResolver(prefixResolver{prefix: "test:"})
+
+		assert.True(t, IsSecretReference("test:value"))
+		envVars, err := ResolveEnvVars([]EnvVar{{Key: "API_KEY", Value: "test:value"}})
+		require.NoError(t, err)
+		assert.Equal(t, "VALUE", envVars[0].Value)
+	})
+}
+
+func TestKeepSecretReferences(t *testing.T) {
+	env := &Environment{Name: "work", Path: t.TempDir()}
+	require.NoError(t, env.SaveEnvVars([]EnvVar{
+		{Key: "GITHUB_TOKEN", Value: "op://work/github/token"},
+		{Key: "REGION", Value: "eu-west-1"},
+	}))
+
+	captured, err := env.KeepSecretReferences([]EnvVar{
+		{Key: "GITHUB_TOKEN", Value: "ghp_123"},
+		{Key: "REGION", Value: "us-east-1"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []EnvVar{
+		{Key: "GITHUB_TOKEN", Value: "op://work/github/token"},
+		{Key: "REGION", Value: "us-east-1"},
+	}, captured)
+}
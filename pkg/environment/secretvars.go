@@ -0,0 +1,254 @@
+package environment
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/hugofrely/envswitch/internal/keyring"
+)
+
+// PassphraseVariable holds the passphrase encrypting the values of secret
+// variables. When it is not set, they are encrypted with a key kept in the OS
+// keyring.
+const PassphraseVariable = "ENVSWITCH_PASSPHRASE"
+
+const (
+	// secretValuePrefix starts the encrypted values in env-vars.env, followed
+	// by the source of the key and the sealed value: envswitch-secret:keyring:...
+	secretValuePrefix = "envswitch-secret:"
+	secretKeyring     = "keyring"
+	secretPassphrase  = "passphrase"
+
+	// secretKeyringAccount is the keyring account of the key encrypting the
+	// values of secret variables
+	secretKeyringAccount = "env-vars-key"
+
+	secretKeySize  = 32
+	secretSaltSize = 16
+
+	// passphraseIterations of PBKDF2 deriving a key from the passphrase
+	passphraseIterations = 200000
+)
+
+var (
+	// secretKeysMu guards the keys derived or read from the keyring, kept
+	// for the life of the process so the keyring is asked once
+	secretKeysMu sync.Mutex
+	secretKeys   = make(map[string][]byte)
+	// passphraseSalt is the salt of the values sealed by this process with
+	// the passphrase, so that a whole file costs a single derivation
+	passphraseSalt []byte
+)
+
+// isSealedValue reports whether a value of env-vars.env is encrypted
+func isSealedValue(value string) bool {
+	return strings.HasPrefix(value, secretValuePrefix)
+}
+
+// sealSecretValue encrypts the value of the secret variable key, with the
+// passphrase of PassphraseVariable when set, or a key kept in the keyring
+func sealSecretValue(key, value string) (string, error) {
+	source := secretKeyring
+	var salt []byte
+	if os.Getenv(PassphraseVariable) != "" {
+		source = secretPassphrase
+		var err error
+		if salt, err = processSalt(); err != nil {
+			return "", err
+		}
+	}
+
+	secretKey, err := loadSecretKey(source, salt, true)
+	if err != nil {
+		return "", err
+	}
+	aead, err := newSecretAEAD(secretKey)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := append(append(append([]byte{}, salt...), nonce...), aead.Seal(nil, nonce, []byte(value), []byte(key))...)
+	return secretValuePrefix + source + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// openSecretValue decrypts a value sealed by sealSecretValue for the
+// variable key
+func openSecretValue(key, value string) (string, error) {
+	source, encoded, ok := strings.Cut(strings.TrimPrefix(value, secretValuePrefix), ":")
+	if !ok || (source != secretKeyring && source != secretPassphrase) {
+		return "", fmt.Errorf("invalid encrypted value of %s", key)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("invalid encrypted value of %s: %w", key, err)
+	}
+
+	var salt []byte
+	if source == secretPassphrase {
+		if len(sealed) < secretSaltSize {
+			return "", fmt.Errorf("invalid encrypted value of %s", key)
+		}
+		salt, sealed = sealed[:secretSaltSize], sealed[secretSaltSize:]
+	}
+
+	secretKey, err := loadSecretKey(source, salt, false)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt %s: %w", key, err)
+	}
+	aead, err := newSecretAEAD(secretKey)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", fmt.Errorf("invalid encrypted value of %s", key)
+	}
+	plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(key))
+	if err != nil {
+		if source == secretPassphrase {
+			return "", fmt.Errorf("failed to decrypt %s: wrong %s", key, PassphraseVariable)
+		}
+		return "", fmt.Errorf("failed to decrypt %s: the keyring key does not match", key)
+	}
+	return string(plain), nil
+}
+
+// SealSecret encrypts value like the values of secret variables, bound to
+// label, which OpenSecret must be given to decrypt it
+func SealSecret(label, value string) (string, error) {
+	return sealSecretValue(label, value)
+}
+
+// OpenSecret decrypts a value sealed by SealSecret for label
+func OpenSecret(label, value string) (string, error) {
+	return openSecretValue(label, value)
+}
+
+// IsSealedSecret reports whether value was sealed by SealSecret
+func IsSealedSecret(value string) bool {
+	return isSealedValue(value)
+}
+
+// loadSecretKey returns the key of source: the keyring key, created when
+// missing if create is true, or the one derived from the passphrase and salt
+func loadSecretKey(source string, salt []byte, create bool) ([]byte, error) {
+	cacheKey := source + ":" + hex.EncodeToString(salt)
+	if source == secretPassphrase {
+		cacheKey += ":" + os.Getenv(PassphraseVariable)
+	}
+	secretKeysMu.Lock()
+	defer secretKeysMu.Unlock()
+	if key, ok := secretKeys[cacheKey]; ok {
+		return key, nil
+	}
+
+	var key []byte
+	switch source {
+	case secretPassphrase:
+		passphrase := os.Getenv(PassphraseVariable)
+		if passphrase == "" {
+			return nil, fmt.Errorf("encrypted with a passphrase, set %s", PassphraseVariable)
+		}
+		key = pbkdf2SHA256([]byte(passphrase), salt, passphraseIterations, secretKeySize)
+	default:
+		var err error
+		if key, err = keyringSecretKey(create); err != nil {
+			return nil, err
+		}
+	}
+	secretKeys[cacheKey] = key
+	return key, nil
+}
+
+// keyringSecretKey reads the key kept in the keyring, generating it when
+// missing if create is true
+func keyringSecretKey(create bool) ([]byte, error) {
+	if !keyring.IsSupported() {
+		return nil, fmt.Errorf("no keyring available, set %s to encrypt secret variables with a passphrase", PassphraseVariable)
+	}
+
+	secret, err := keyring.Get(KeyringService, secretKeyringAccount)
+	if err == nil {
+		key, decodeErr := hex.DecodeString(strings.TrimSpace(secret))
+		if decodeErr != nil || len(key) != secretKeySize {
+			return nil, fmt.Errorf("invalid key of secret variables in the keyring")
+		}
+		return key, nil
+	}
+	if !errors.Is(err, keyring.ErrNotFound) {
+		return nil, fmt.Errorf("failed to read the key of secret variables from the keyring: %w", err)
+	}
+	if !create {
+		return nil, fmt.Errorf("the key of secret variables is not in the keyring of this machine")
+	}
+
+	key := make([]byte, secretKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	if err := keyring.Set(KeyringService, secretKeyringAccount, hex.EncodeToString(key)); err != nil {
+		return nil, fmt.Errorf("failed to store the key of secret variables in the keyring: %w", err)
+	}
+	return key, nil
+}
+
+// processSalt returns the salt of the values sealed with the passphrase by
+// this process
+func processSalt() ([]byte, error) {
+	secretKeysMu.Lock()
+	defer secretKeysMu.Unlock()
+	if passphraseSalt == nil {
+		salt := make([]byte, secretSaltSize)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, fmt.Errorf("failed to generate salt: %w", err)
+		}
+		passphraseSalt = salt
+	}
+	return passphraseSalt, nil
+}
+
+func newSecretAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// pbkdf2SHA256 derives a key of size bytes from password with PBKDF2
+// (RFC 8018) and HMAC-SHA256
+func pbkdf2SHA256(password, salt []byte, iterations, size int) []byte {
+	prf := hmac.New(sha256.New, password)
+	var key []byte
+	for block := uint32(1); len(key) < size; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		_ = binary.Write(prf, binary.BigEndian, block)
+		u := prf.Sum(nil)
+		t := append([]byte{}, u...)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:size]
+}
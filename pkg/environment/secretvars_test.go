@@ -0,0 +1,120 @@
+package environment
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hugofrely/envswitch/internal/keyring"
+)
+
+// resetSecretKeys forgets the keys cached by the previous tests
+func resetSecretKeys(t *testing.T) {
+	t.Helper()
+	secretKeysMu.Lock()
+	secretKeys = make(map[string][]byte)
+	passphraseSalt = nil
+	secretKeysMu.Unlock()
+}
+
+func TestSecretEnvVars(t *testing.T) {
+	newEnv := func(t *testing.T) *Environment {
+		env := &Environment{Name: "work", Path: t.TempDir(), SecretEnvVars: []string{"API_TOKEN"}}
+		require.NoError(t, env.SaveEnvVars([]EnvVar{{Key: "API_TOKEN", Value: "s3cr3t value"}, {Key: "REGION", Value: "eu-west-1"}}))
+		return env
+	}
+	envFile := func(t *testing.T, env *Environment) string {
+		data, err := os.ReadFile(filepath.Join(env.Path, "snapshots", envVarsFileName))
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	t.Run("encrypts secret values with a key kept in the keyring", func(t *testing.T) {
+		keyring.MockInit()
+		resetSecretKeys(t)
+		env := newEnv(t)
+
+		content := envFile(t, env)
+		assert.NotContains(t, content, "s3cr3t")
+		assert.Contains(t, content, "API_TOKEN="+secretValuePrefix+secretKeyring+":")
+		assert.Contains(t, content, "REGION=eu-west-1")
+
+		envVars, err := env.LoadEnvVars()
+		require.NoError(t, err)
+		assert.Equal(t, []EnvVar{{Key: "API_TOKEN", Value: "s3cr3t value"}, {Key: "REGION", Value: "eu-west-1"}}, envVars)
+
+		masked, err := env.ListEnvVarsMasked("***")
+		require.NoError(t, err)
+		assert.Equal(t, []EnvVar{{Key: "API_TOKEN", Value: "***"}, {Key: "REGION", Value: "eu-west-1"}}, masked)
+	})
+
+	t.Run("fails without the keyring key", func(t *testing.T) {
+		keyring.MockInit()
+		resetSecretKeys(t)
+		env := newEnv(t)
+
+		keyring.MockInit()
+		resetSecretKeys(t)
+		_, err := env.LoadEnvVars()
+		assert.ErrorContains(t, err, "not in the keyring")
+
+		// Listing masked values needs no key
+		_, err = env.ListEnvVarsMasked("***")
+		assert.NoError(t, err)
+	})
+
+	t.Run("encrypts with the passphrase when set", func(t *testing.T) {
+		resetSecretKeys(t)
+		t.Setenv(PassphraseVariable, "correct horse")
+		env := newEnv(t)
+		assert.Contains(t, envFile(t, env), "API_TOKEN="+secretValuePrefix+secretPassphrase+":")
+
+		value, _, err := env.GetEnvVar("API_TOKEN")
+		require.NoError(t, err)
+		assert.Equal(t, "s3cr3t value", value)
+
+		resetSecretKeys(t)
+		t.Setenv(PassphraseVariable, "wrong")
+		_, err = env.LoadEnvVars()
+		assert.ErrorContains(t, err, "wrong "+PassphraseVariable)
+
+		t.Setenv(PassphraseVariable, "")
+		_, err = env.LoadEnvVars()
+		assert.ErrorContains(t, err, "set "+PassphraseVariable)
+	})
+
+	t.Run("binds a value to its variable", func(t *testing.T) {
+		keyring.MockInit()
+		resetSecretKeys(t)
+		sealed, err := sealSecretValue("API_TOKEN", "s3cr3t")
+		require.NoError(t, err)
+
+		_, err = openSecretValue("OTHER_TOKEN", sealed)
+		assert.Error(t, err)
+	})
+
+	t.Run("seals values marked secret after they were written", func(t *testing.T) {
+		keyring.MockInit()
+		resetSecretKeys(t)
+		env := newEnv(t)
+		env.SetEnvVarSecret("REGION", true)
+
+		require.NoError(t, env.SealEnvVars())
+		assert.NotContains(t, envFile(t, env), "eu-west-1")
+
+		value, _, err := env.GetEnvVar("REGION")
+		require.NoError(t, err)
+		assert.Equal(t, "eu-west-1", value)
+	})
+}
+
+func TestPBKDF2SHA256(t *testing.T) {
+	// RFC 7914, section 11
+	key := pbkdf2SHA256([]byte("passwd"), []byte("salt"), 1, 64)
+	assert.Equal(t, "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc"+
+		"49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783", hex.EncodeToString(key))
+}
@@ -0,0 +1,90 @@
+package environment
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hugofrely/envswitch/internal/storage"
+)
+
+// Values of Environment.ShellHistory
+const (
+	ShellHistoryIsolated = "isolated" // the environment keeps its own shell history
+	ShellHistoryShared   = "shared"   // the environment uses the default shell history
+)
+
+// legacyShellHistoryDir is where, inside the environment directory, earlier
+// releases kept its shell history
+const legacyShellHistoryDir = "history"
+
+// HistoryIsolated reports whether the environment keeps its own shell history.
+// Environments without a shell_history setting follow the global default.
+func (e *Environment) HistoryIsolated(defaultIsolated bool) bool {
+	switch e.ShellHistory {
+	case ShellHistoryIsolated:
+		return true
+	case ShellHistoryShared:
+		return false
+	default:
+		return defaultIsolated
+	}
+}
+
+// ShellHistoryDir returns the directory holding the shell history files of
+// the environment. It is outside the environment directory so that export,
+// backup, sync and send, which carry that directory, never carry the command
+// lines typed with their secrets.
+func (e *Environment) ShellHistoryDir() (string, error) {
+	dir, err := GetEnvswitchDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "shell-history", FlatName(e.Name)), nil
+}
+
+// ShellHistoryFile returns the history file of the environment for a shell,
+// creating its directory so only the user can read it. For fish, which keeps
+// history files itself, the session name to use as fish_history is returned.
+func (e *Environment) ShellHistoryFile(shell string) (string, error) {
+	if shell == "fish" {
+		return "envswitch_" + fishSessionName(e.Name), nil
+	}
+
+	historyDir, err := e.ShellHistoryDir()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(historyDir, storage.PrivateDirMode); err != nil {
+		return "", fmt.Errorf("failed to create history directory: %w", err)
+	}
+
+	historyFile := filepath.Join(historyDir, shell+"_history")
+	// Earlier releases kept the history in the environment directory
+	legacyFile := filepath.Join(e.Path, legacyShellHistoryDir, shell+"_history")
+	if _, err := os.Stat(historyFile); os.IsNotExist(err) {
+		if err := os.Rename(legacyFile, historyFile); err == nil {
+			_ = os.Remove(filepath.Dir(legacyFile))
+		}
+	}
+
+	file, err := os.OpenFile(historyFile, os.O_CREATE|os.O_WRONLY, storage.PrivateFileMode)
+	if err != nil {
+		return "", fmt.Errorf("failed to create history file: %w", err)
+	}
+	_ = file.Close()
+
+	return historyFile, nil
+}
+
+// fishSessionName maps an environment name to the characters fish accepts
+// in fish_history (letters, digits and underscores)
+func fishSessionName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, name)
+}
@@ -0,0 +1,79 @@
+package environment
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistoryIsolated(t *testing.T) {
+	tests := []struct {
+		setting  string
+		fallback bool
+		want     bool
+	}{
+		{"", false, false},
+		{"", true, true},
+		{ShellHistoryIsolated, false, true},
+		{ShellHistoryShared, true, false},
+	}
+
+	for _, tt := range tests {
+		env := &Environment{ShellHistory: tt.setting}
+		assert.Equal(t, tt.want, env.HistoryIsolated(tt.fallback), "setting %q, default %v", tt.setting, tt.fallback)
+	}
+}
+
+func TestShellHistoryFile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	env := &Environment{Name: "client-a.prod", Path: filepath.Join(home, ".envswitch", "environments", "client-a.prod")}
+	historyDir := filepath.Join(home, ".envswitch", "shell-history", "client-a.prod")
+
+	t.Run("creates a private history file outside the environment", func(t *testing.T) {
+		historyFile, err := env.ShellHistoryFile("zsh")
+		require.NoError(t, err)
+		assert.Equal(t, filepath.Join(historyDir, "zsh_history"), historyFile)
+
+		info, err := os.Stat(historyFile)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+		dirInfo, err := os.Stat(filepath.Dir(historyFile))
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0700), dirInfo.Mode().Perm())
+	})
+
+	t.Run("keeps existing history", func(t *testing.T) {
+		historyFile := filepath.Join(historyDir, "bash_history")
+		require.NoError(t, os.WriteFile(historyFile, []byte("kubectl get pods\n"), 0600))
+
+		got, err := env.ShellHistoryFile("bash")
+		require.NoError(t, err)
+		data, err := os.ReadFile(got)
+		require.NoError(t, err)
+		assert.Equal(t, "kubectl get pods\n", string(data))
+	})
+
+	t.Run("moves the history out of the environment", func(t *testing.T) {
+		legacyFile := filepath.Join(env.Path, "history", "ksh_history")
+		require.NoError(t, os.MkdirAll(filepath.Dir(legacyFile), 0700))
+		require.NoError(t, os.WriteFile(legacyFile, []byte("vault login\n"), 0600))
+
+		got, err := env.ShellHistoryFile("ksh")
+		require.NoError(t, err)
+		data, err := os.ReadFile(got)
+		require.NoError(t, err)
+		assert.Equal(t, "vault login\n", string(data))
+		assert.NoDirExists(t, filepath.Join(env.Path, "history"))
+	})
+
+	t.Run("fish uses a session name", func(t *testing.T) {
+		session, err := env.ShellHistoryFile("fish")
+		require.NoError(t, err)
+		assert.Equal(t, "envswitch_client_a_prod", session)
+	})
+}
@@ -0,0 +1,281 @@
+package environment
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/hugofrely/envswitch/internal/storage"
+)
+
+// DefaultSnapshotHistoryLimit is the number of saved states kept per
+// environment when snapshot_history_limit is not set
+const DefaultSnapshotHistoryLimit = 10
+
+// snapshotRecordFile describes a saved state in its history directory
+const snapshotRecordFile = "snapshot.yaml"
+
+// SnapshotRecord is a saved state of an environment, kept in
+// ~/.envswitch/snapshots-history/<env>/<id> like a lightweight commit
+type SnapshotRecord struct {
+	ID        int                   `yaml:"id"`
+	Message   string                `yaml:"message,omitempty"`
+	CreatedAt time.Time             `yaml:"created_at"`
+	Tools     map[string]ToolConfig `yaml:"tools"`
+	SizeBytes uint64                `yaml:"size_bytes"`
+	Path      string                `yaml:"-"`
+}
+
+// snapshotStateEntries returns the files and directories of the environment
+// that make up its saved state: the snapshots, their per-machine overlays,
+// the captured variables and the checksums
+func (e *Environment) snapshotStateEntries() []string {
+	entries := []string{"snapshots"}
+	for _, host := range e.ListSnapshotHosts() {
+		entries = append(entries, hostSnapshotsPrefix+host)
+	}
+	return append(entries, envVarsFileName, ChecksumsFile)
+}
+
+// SnapshotHistoryDir returns the directory holding the saved states of the
+// environment
+func (e *Environment) SnapshotHistoryDir() (string, error) {
+	dir, err := GetEnvswitchDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "snapshots-history", FlatName(e.Name)), nil
+}
+
+// RecordSnapshot adds the current saved state of the environment to its
+// history with message, then removes the oldest states beyond limit. Nothing
+// is recorded when limit is 0.
+func (e *Environment) RecordSnapshot(message string, limit int) (*SnapshotRecord, error) {
+	if limit <= 0 {
+		return nil, nil
+	}
+
+	historyDir, err := e.SnapshotHistoryDir()
+	if err != nil {
+		return nil, err
+	}
+	if err := storage.MkdirPrivate(historyDir); err != nil {
+		return nil, fmt.Errorf("failed to create snapshot history directory: %w", err)
+	}
+
+	records, err := e.ListSnapshotHistory()
+	if err != nil {
+		return nil, err
+	}
+	id := 1
+	if len(records) > 0 {
+		id = records[0].ID + 1
+	}
+
+	// Copied next to the history so a failed copy never shows as a record
+	tmpDir, err := os.MkdirTemp(historyDir, ".record-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create snapshot history directory: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	if err := copyStateEntries(e.Path, tmpDir, e.snapshotStateEntries()); err != nil {
+		return nil, fmt.Errorf("failed to copy snapshots: %w", err)
+	}
+
+	record := &SnapshotRecord{
+		ID:        id,
+		Message:   message,
+		CreatedAt: time.Now(),
+		Tools:     e.Tools,
+		SizeBytes: storage.PathSize(tmpDir),
+		Path:      filepath.Join(historyDir, strconv.Itoa(id)),
+	}
+	data, err := yaml.Marshal(record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal snapshot record: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, snapshotRecordFile), data, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write snapshot record: %w", err)
+	}
+	if err := os.Rename(tmpDir, record.Path); err != nil {
+		return nil, fmt.Errorf("failed to record snapshot: %w", err)
+	}
+
+	// The new record is not in records, keep limit-1 of the previous ones
+	for i := limit - 1; i < len(records); i++ {
+		if err := os.RemoveAll(records[i].Path); err != nil {
+			return record, fmt.Errorf("failed to remove snapshot #%d: %w", records[i].ID, err)
+		}
+	}
+	return record, nil
+}
+
+// ListSnapshotHistory returns the saved states of the environment, the most
+// recent first
+func (e *Environment) ListSnapshotHistory() ([]*SnapshotRecord, error) {
+	historyDir, err := e.SnapshotHistoryDir()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(historyDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read snapshot history: %w", err)
+	}
+
+	var records []*SnapshotRecord
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		record, err := loadSnapshotRecord(filepath.Join(historyDir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].ID > records[j].ID })
+	return records, nil
+}
+
+// FindSnapshot returns the saved state with the given ID
+func (e *Environment) FindSnapshot(id int) (*SnapshotRecord, error) {
+	historyDir, err := e.SnapshotHistoryDir()
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(historyDir, strconv.Itoa(id))
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("environment '%s' has no snapshot #%d", e.Name, id)
+	}
+	return loadSnapshotRecord(path)
+}
+
+// RestoreSnapshot replaces the saved state of the environment with the one
+// of record. The current state is kept aside until the record is in place,
+// and put back if it cannot be. The live tool configs are not touched.
+func (e *Environment) RestoreSnapshot(record *SnapshotRecord) error {
+	// Copied next to the environment so that the swap is made of renames
+	tmpDir, err := os.MkdirTemp(filepath.Dir(e.Path), ".restore-"+filepath.Base(e.Path)+"-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	incoming := filepath.Join(tmpDir, "incoming")
+	previous := filepath.Join(tmpDir, "previous")
+	for _, dir := range []string{incoming, previous} {
+		if err := os.Mkdir(dir, 0700); err != nil {
+			return fmt.Errorf("failed to create temporary directory: %w", err)
+		}
+	}
+
+	recordEntries, err := stateEntriesIn(record.Path)
+	if err != nil {
+		return err
+	}
+	if err := copyStateEntries(record.Path, incoming, recordEntries); err != nil {
+		return fmt.Errorf("failed to copy snapshot #%d: %w", record.ID, err)
+	}
+
+	// Move the current state aside
+	var moved, placed []string
+	putBack := func() {
+		for _, name := range placed {
+			_ = os.RemoveAll(filepath.Join(e.Path, name))
+		}
+		for _, name := range moved {
+			_ = os.Rename(filepath.Join(previous, name), filepath.Join(e.Path, name))
+		}
+	}
+	for _, name := range e.snapshotStateEntries() {
+		if _, err := os.Lstat(filepath.Join(e.Path, name)); os.IsNotExist(err) {
+			continue
+		}
+		if err := os.Rename(filepath.Join(e.Path, name), filepath.Join(previous, name)); err != nil {
+			putBack()
+			return fmt.Errorf("failed to move %s aside: %w", name, err)
+		}
+		moved = append(moved, name)
+	}
+
+	for _, name := range recordEntries {
+		if err := os.Rename(filepath.Join(incoming, name), filepath.Join(e.Path, name)); err != nil {
+			putBack()
+			return fmt.Errorf("failed to restore %s: %w", name, err)
+		}
+		placed = append(placed, name)
+	}
+
+	tools := e.Tools
+	e.Tools = record.Tools
+	if err := e.Save(); err != nil {
+		e.Tools = tools
+		putBack()
+		return err
+	}
+	return nil
+}
+
+// loadSnapshotRecord reads the record of the saved state in dir
+func loadSnapshotRecord(dir string) (*SnapshotRecord, error) {
+	data, err := os.ReadFile(filepath.Join(dir, snapshotRecordFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot record: %w", err)
+	}
+	var record SnapshotRecord
+	if err := yaml.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filepath.Join(dir, snapshotRecordFile), err)
+	}
+	record.Path = dir
+	return &record, nil
+}
+
+// stateEntriesIn returns the state entries stored in the directory of a
+// saved state
+func stateEntriesIn(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		if entry.Name() != snapshotRecordFile {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
+
+// copyStateEntries copies the files and directories called names from src to
+// dst, skipping the missing ones
+func copyStateEntries(src, dst string, names []string) error {
+	for _, name := range names {
+		info, err := os.Stat(filepath.Join(src, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			err = storage.CopyDir(filepath.Join(src, name), filepath.Join(dst, name))
+		} else {
+			err = storage.CopyFile(filepath.Join(src, name), filepath.Join(dst, name))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package environment
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotHistory(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+	stubHostname(t, "laptop")
+
+	env := &Environment{
+		Name:  "client/prod",
+		Path:  filepath.Join(tempHome, ".envswitch", "environments", "client", "prod"),
+		Tools: map[string]ToolConfig{"git": {Enabled: true, SnapshotSize: 3}},
+	}
+	kubeconfig := filepath.Join(env.Path, "snapshots@laptop", "kubectl", "config")
+	writeSnapshotFile(t, filepath.Join(env.Path, "snapshots", "git", "gitconfig"), "v1")
+	writeSnapshotFile(t, kubeconfig, "kube-v1")
+	writeSnapshotFile(t, filepath.Join(env.Path, envVarsFileName), "A=1\n")
+	require.NoError(t, env.Save())
+
+	t.Run("records nothing without a limit", func(t *testing.T) {
+		record, err := env.RecordSnapshot("ignored", 0)
+		require.NoError(t, err)
+		assert.Nil(t, record)
+	})
+
+	t.Run("records the saved state", func(t *testing.T) {
+		record, err := env.RecordSnapshot("first", 2)
+		require.NoError(t, err)
+		assert.Equal(t, 1, record.ID)
+		assert.Equal(t, filepath.Join(tempHome, ".envswitch", "snapshots-history", "client__prod", "1"), record.Path)
+		assert.FileExists(t, filepath.Join(record.Path, "snapshots", "git", "gitconfig"))
+		assert.FileExists(t, filepath.Join(record.Path, "snapshots@laptop", "kubectl", "config"))
+		assert.FileExists(t, filepath.Join(record.Path, envVarsFileName))
+		assert.NoFileExists(t, filepath.Join(record.Path, ChecksumsFile))
+	})
+
+	t.Run("keeps the most recent states", func(t *testing.T) {
+		writeSnapshotFile(t, filepath.Join(env.Path, "snapshots", "git", "gitconfig"), "v2")
+		_, err := env.RecordSnapshot("second", 2)
+		require.NoError(t, err)
+
+		writeSnapshotFile(t, filepath.Join(env.Path, "snapshots", "git", "gitconfig"), "v3")
+		_, err = env.RecordSnapshot("", 2)
+		require.NoError(t, err)
+
+		records, err := env.ListSnapshotHistory()
+		require.NoError(t, err)
+		require.Len(t, records, 2)
+		assert.Equal(t, 3, records[0].ID)
+		assert.Equal(t, 2, records[1].ID)
+		assert.Equal(t, "second", records[1].Message)
+
+		_, err = env.FindSnapshot(1)
+		assert.ErrorContains(t, err, "has no snapshot #1")
+	})
+
+	t.Run("restores a saved state", func(t *testing.T) {
+		record, err := env.FindSnapshot(2)
+		require.NoError(t, err)
+
+		// Changed since: a new file, a removed overlay and other tools
+		writeSnapshotFile(t, filepath.Join(env.Path, "snapshots", "aws", "config"), "aws")
+		require.NoError(t, os.RemoveAll(filepath.Join(env.Path, "snapshots@laptop")))
+		env.Tools = map[string]ToolConfig{"aws": {Enabled: true}}
+
+		require.NoError(t, env.RestoreSnapshot(record))
+
+		data, err := os.ReadFile(filepath.Join(env.Path, "snapshots", "git", "gitconfig"))
+		require.NoError(t, err)
+		assert.Equal(t, "v2", string(data))
+		assert.NoDirExists(t, filepath.Join(env.Path, "snapshots", "aws"))
+		assert.FileExists(t, kubeconfig)
+
+		loaded, err := LoadEnvironment("client/prod")
+		require.NoError(t, err)
+		assert.Equal(t, map[string]ToolConfig{"git": {Enabled: true, SnapshotSize: 3}}, loaded.Tools)
+
+		entries, err := os.ReadDir(filepath.Dir(env.Path))
+		require.NoError(t, err)
+		assert.Len(t, entries, 1, "no temporary directory is left")
+	})
+}
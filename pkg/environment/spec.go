@@ -0,0 +1,174 @@
+package environment
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SpecFileName is the name of the spec file looked for in the working
+// directory
+const SpecFileName = "envswitch.yaml"
+
+// Spec describes an environment in a file that can live in a project
+// repository, so a team sets up its environments the same way. Fields left
+// out of the file are left as they are in the environment.
+type Spec struct {
+	Name         string        `yaml:"name,omitempty"`
+	Description  string        `yaml:"description,omitempty"`
+	Tools        []string      `yaml:"tools,omitempty"` // enabled tools, the others are disabled
+	EnvVars      []TemplateVar `yaml:"environment_variables,omitempty"`
+	Hooks        *Hooks        `yaml:"hooks,omitempty"`
+	Plugins      []string      `yaml:"plugins,omitempty"` // plugins that must be installed, enabled in the environment
+	Tags         []string      `yaml:"tags,omitempty"`
+	ShellHistory string        `yaml:"shell_history,omitempty"`
+}
+
+// LoadSpec reads and validates a spec file. Unknown fields are rejected, a
+// typo must not silently leave a setting out.
+func LoadSpec(path string) (*Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spec: %w", err)
+	}
+
+	var spec Spec
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&spec); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if err := spec.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	return &spec, nil
+}
+
+// Validate checks the name, variables, hooks and settings of the spec
+func (s *Spec) Validate() error {
+	if s.Name != "" {
+		if err := ValidateName(s.Name); err != nil {
+			return err
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, specVar := range s.EnvVars {
+		if err := ValidateEnvVarKey(specVar.Key); err != nil {
+			return err
+		}
+		if seen[specVar.Key] {
+			return fmt.Errorf("variable %s is listed twice", specVar.Key)
+		}
+		seen[specVar.Key] = true
+		if specVar.Secret && specVar.Default != "" {
+			return fmt.Errorf("secret variable %s cannot have a default", specVar.Key)
+		}
+	}
+
+	if s.Hooks != nil {
+		for _, event := range HookEvents {
+			hooks, _ := s.Hooks.ForEvent(event)
+			for i, hook := range *hooks {
+				if err := hook.Validate(); err != nil {
+					return fmt.Errorf("%s hook %d: %w", event, i+1, err)
+				}
+			}
+		}
+	}
+
+	switch s.ShellHistory {
+	case "", ShellHistoryIsolated, ShellHistoryShared:
+	default:
+		return fmt.Errorf("invalid shell_history '%s' (valid: %s, %s)", s.ShellHistory, ShellHistoryIsolated, ShellHistoryShared)
+	}
+	return nil
+}
+
+// Apply makes env match the spec, except for its variables which the caller
+// sets with the values asked for. pluginTools maps the plugins of the spec
+// to the tools they provide. It returns the changes made, empty when env
+// already matched.
+func (s *Spec) Apply(env *Environment, pluginTools map[string]string) []string {
+	var changes []string
+	if env.Tools == nil {
+		env.Tools = make(map[string]ToolConfig)
+	}
+
+	for _, pluginName := range s.Plugins {
+		toolName := pluginTools[pluginName]
+		if env.PluginEnabled(pluginName) && env.Tools[toolName].Enabled {
+			continue
+		}
+		env.SetPluginEnabled(pluginName, toolName, true)
+		changes = append(changes, fmt.Sprintf("enable plugin %s", pluginName))
+	}
+
+	if s.Tools != nil {
+		wanted := make(map[string]bool)
+		for _, toolName := range s.Tools {
+			wanted[toolName] = true
+		}
+		for _, toolName := range pluginTools {
+			wanted[toolName] = true
+		}
+
+		toolNames := make([]string, 0, len(env.Tools)+len(wanted))
+		for toolName := range env.Tools {
+			toolNames = append(toolNames, toolName)
+		}
+		for toolName := range wanted {
+			if _, ok := env.Tools[toolName]; !ok {
+				toolNames = append(toolNames, toolName)
+			}
+		}
+		sort.Strings(toolNames)
+
+		for _, toolName := range toolNames {
+			toolConfig := env.Tools[toolName]
+			if toolConfig.Enabled == wanted[toolName] {
+				continue
+			}
+			toolConfig.Enabled = wanted[toolName]
+			if toolConfig.SnapshotPath == "" {
+				toolConfig.SnapshotPath = fmt.Sprintf("snapshots/%s", toolName)
+			}
+			env.Tools[toolName] = toolConfig
+			if toolConfig.Enabled {
+				changes = append(changes, fmt.Sprintf("enable %s", toolName))
+			} else {
+				changes = append(changes, fmt.Sprintf("disable %s (its snapshot is kept)", toolName))
+			}
+		}
+	}
+
+	for _, specVar := range s.EnvVars {
+		if specVar.Secret && !env.IsSecretEnvVar(specVar.Key) {
+			env.SetEnvVarSecret(specVar.Key, true)
+			changes = append(changes, fmt.Sprintf("mark %s as secret", specVar.Key))
+		}
+	}
+
+	if s.Description != "" && s.Description != env.Description {
+		env.Description = s.Description
+		changes = append(changes, "set description")
+	}
+	if s.Hooks != nil && !reflect.DeepEqual(*s.Hooks, env.Hooks) {
+		env.Hooks = *s.Hooks
+		changes = append(changes, "replace hooks")
+	}
+	if s.Tags != nil && !reflect.DeepEqual(s.Tags, env.Tags) {
+		env.Tags = append([]string{}, s.Tags...)
+		changes = append(changes, fmt.Sprintf("set tags %s", strings.Join(s.Tags, ", ")))
+	}
+	if s.ShellHistory != "" && s.ShellHistory != env.ShellHistory {
+		env.ShellHistory = s.ShellHistory
+		changes = append(changes, fmt.Sprintf("set shell history %s", s.ShellHistory))
+	}
+	return changes
+}
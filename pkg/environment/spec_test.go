@@ -0,0 +1,104 @@
+package environment
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeSpec(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), SpecFileName)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestLoadSpec(t *testing.T) {
+	t.Run("loads a valid spec", func(t *testing.T) {
+		spec, err := LoadSpec(writeSpec(t, `
+name: acme
+tools: [git, kubectl]
+environment_variables:
+  - key: AWS_REGION
+    default: eu-west-1
+  - key: ACME_TOKEN
+    secret: true
+hooks:
+  post_switch:
+    - command: echo hi
+plugins: [vault]
+`))
+		require.NoError(t, err)
+		assert.Equal(t, "acme", spec.Name)
+		assert.Equal(t, []string{"git", "kubectl"}, spec.Tools)
+		assert.Len(t, spec.EnvVars, 2)
+		require.NotNil(t, spec.Hooks)
+		assert.Len(t, spec.Hooks.PostSwitch, 1)
+		assert.Equal(t, []string{"vault"}, spec.Plugins)
+	})
+
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"unknown field", "name: acme\ntool: [git]\n", "field tool not found"},
+		{"invalid name", "name: ACME\n", "lowercase"},
+		{"invalid variable", "environment_variables:\n  - key: 1BAD\n", "invalid variable name"},
+		{"duplicate variable", "environment_variables:\n  - key: A\n  - key: A\n", "listed twice"},
+		{"secret default", "environment_variables:\n  - key: A\n    secret: true\n    default: x\n", "cannot have a default"},
+		{"invalid hook", "hooks:\n  pre_switch:\n    - description: nothing\n", "pre_switch hook 1"},
+		{"invalid shell history", "shell_history: private\n", "invalid shell_history"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadSpec(writeSpec(t, tt.content))
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestSpecApply(t *testing.T) {
+	env := &Environment{
+		Name: "acme",
+		Tools: map[string]ToolConfig{
+			"git":    {Enabled: true, SnapshotPath: "snapshots/git"},
+			"docker": {Enabled: true, SnapshotPath: "snapshots/docker"},
+			"aws":    {Enabled: false, SnapshotPath: "snapshots/aws"},
+		},
+		Description: "Old",
+	}
+	spec := &Spec{
+		Tools:       []string{"git", "kubectl"},
+		EnvVars:     []TemplateVar{{Key: "ACME_TOKEN", Secret: true}},
+		Plugins:     []string{"vault"},
+		Description: "ACME project",
+		Hooks:       &Hooks{PostSwitch: []Hook{{Command: "echo hi"}}},
+	}
+
+	changes := spec.Apply(env, map[string]string{"vault": "vault"})
+	assert.Equal(t, []string{
+		"enable plugin vault",
+		"disable docker (its snapshot is kept)",
+		"enable kubectl",
+		"mark ACME_TOKEN as secret",
+		"set description",
+		"replace hooks",
+	}, changes)
+
+	assert.True(t, env.Tools["git"].Enabled)
+	assert.True(t, env.Tools["kubectl"].Enabled)
+	assert.Equal(t, "snapshots/kubectl", env.Tools["kubectl"].SnapshotPath)
+	assert.True(t, env.Tools["vault"].Enabled)
+	assert.False(t, env.Tools["docker"].Enabled)
+	assert.True(t, env.IsSecretEnvVar("ACME_TOKEN"))
+	assert.Equal(t, "ACME project", env.Description)
+
+	assert.Empty(t, spec.Apply(env, map[string]string{"vault": "vault"}), "applying again changes nothing")
+
+	// Fields left out are left as they are
+	assert.Empty(t, (&Spec{}).Apply(env, nil))
+	assert.True(t, env.Tools["kubectl"].Enabled)
+}
@@ -0,0 +1,49 @@
+package environment
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/hugofrely/envswitch/internal/storage"
+)
+
+const switchingFile = "switching"
+
+// switchingMaxAge bounds how long a switch marker is trusted, a switch that
+// crashed leaves it behind
+const switchingMaxAge = 10 * time.Minute
+
+// BeginSwitch records that a switch is restoring the live config until the
+// returned function is called, so that watchers do not snapshot it halfway
+func BeginSwitch() (func(), error) {
+	dir, err := GetEnvswitchDir()
+	if err != nil {
+		return nil, err
+	}
+	if err := storage.MkdirPrivate(dir); err != nil {
+		return nil, fmt.Errorf("failed to create envswitch directory: %w", err)
+	}
+
+	path := filepath.Join(dir, switchingFile)
+	if err := os.WriteFile(path, []byte(strconv.Itoa(os.Getpid())), 0600); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", switchingFile, err)
+	}
+	return func() { _ = os.Remove(path) }, nil
+}
+
+// SwitchInProgress reports whether a switch is restoring the live config
+func SwitchInProgress() bool {
+	dir, err := GetEnvswitchDir()
+	if err != nil {
+		return false
+	}
+
+	info, err := os.Stat(filepath.Join(dir, switchingFile))
+	if err != nil {
+		return false
+	}
+	return time.Since(info.ModTime()) < switchingMaxAge
+}
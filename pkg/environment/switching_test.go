@@ -0,0 +1,27 @@
+package environment
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBeginSwitch(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+
+	endSwitch, err := BeginSwitch()
+	require.NoError(t, err)
+	assert.True(t, SwitchInProgress())
+
+	// The store it creates is only readable by the current user
+	info, err := os.Stat(filepath.Join(tempHome, ".envswitch"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0700), info.Mode().Perm())
+
+	endSwitch()
+	assert.False(t, SwitchInProgress())
+}
@@ -0,0 +1,124 @@
+package environment
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ValidateTag checks a tag can be stored and filtered on: a word or a
+// key=value pair such as client=acme
+func ValidateTag(tag string) error {
+	if tag == "" {
+		return fmt.Errorf("tag cannot be empty")
+	}
+	if strings.ContainsAny(tag, " \t,") {
+		return fmt.Errorf("invalid tag '%s': tags cannot contain spaces or commas", tag)
+	}
+	if key, _, ok := strings.Cut(tag, "="); ok && key == "" {
+		return fmt.Errorf("invalid tag '%s': missing key before '='", tag)
+	}
+	return nil
+}
+
+// HasTag reports whether the environment matches a tag filter: "client=acme"
+// matches that tag, "client" matches the tag client and any client=<value>
+func (e *Environment) HasTag(filter string) bool {
+	for _, tag := range e.Tags {
+		if tag == filter {
+			return true
+		}
+		if !strings.Contains(filter, "=") {
+			if key, _, ok := strings.Cut(tag, "="); ok && key == filter {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// AddTags adds tags to the environment, a key=value tag replacing the value of
+// its key. It reports whether the tags changed.
+func (e *Environment) AddTags(tags ...string) bool {
+	changed := false
+	for _, tag := range tags {
+		if containsTag(e.Tags, tag) {
+			continue
+		}
+		if key, _, ok := strings.Cut(tag, "="); ok {
+			e.removeTagKey(key)
+		}
+		e.Tags = append(e.Tags, tag)
+		changed = true
+	}
+	if changed {
+		sort.Strings(e.Tags)
+	}
+	return changed
+}
+
+// RemoveTags removes the tags matching filters, see HasTag. It reports
+// whether the tags changed.
+func (e *Environment) RemoveTags(filters ...string) bool {
+	var kept []string
+	for _, tag := range e.Tags {
+		tagEnv := Environment{Tags: []string{tag}}
+		removed := false
+		for _, filter := range filters {
+			if tagEnv.HasTag(filter) {
+				removed = true
+				break
+			}
+		}
+		if !removed {
+			kept = append(kept, tag)
+		}
+	}
+	changed := len(kept) != len(e.Tags)
+	e.Tags = kept
+	return changed
+}
+
+// removeTagKey removes the key=value tags of key
+func (e *Environment) removeTagKey(key string) {
+	var kept []string
+	for _, tag := range e.Tags {
+		if tagKey, _, ok := strings.Cut(tag, "="); ok && tagKey == key {
+			continue
+		}
+		kept = append(kept, tag)
+	}
+	e.Tags = kept
+}
+
+// FilterByTags keeps the environments matching every tag filter
+func FilterByTags(envs []*Environment, filters []string) []*Environment {
+	if len(filters) == 0 {
+		return envs
+	}
+
+	var filtered []*Environment
+	for _, env := range envs {
+		matched := true
+		for _, filter := range filters {
+			if !env.HasTag(filter) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			filtered = append(filtered, env)
+		}
+	}
+	return filtered
+}
+
+// containsTag reports whether tags contains tag
+func containsTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,55 @@
+package environment
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateTag(t *testing.T) {
+	for _, tag := range []string{"prod", "client=acme", "type=prod", "team=a=b"} {
+		assert.NoError(t, ValidateTag(tag), "tag %s", tag)
+	}
+	for _, tag := range []string{"", "has space", "a,b", "=acme"} {
+		assert.Error(t, ValidateTag(tag), "tag %q", tag)
+	}
+}
+
+func TestHasTag(t *testing.T) {
+	env := &Environment{Tags: []string{"client=acme", "prod"}}
+
+	assert.True(t, env.HasTag("client=acme"))
+	assert.True(t, env.HasTag("client"), "a key matches any value")
+	assert.True(t, env.HasTag("prod"))
+	assert.False(t, env.HasTag("client=other"))
+	assert.False(t, env.HasTag("type"))
+	assert.False(t, env.HasTag("acme"))
+}
+
+func TestAddAndRemoveTags(t *testing.T) {
+	env := &Environment{}
+
+	assert.True(t, env.AddTags("type=prod", "client=acme"))
+	assert.Equal(t, []string{"client=acme", "type=prod"}, env.Tags)
+	assert.False(t, env.AddTags("client=acme"), "adding a tag twice changes nothing")
+
+	// A new value replaces the one of the key
+	assert.True(t, env.AddTags("client=globex"))
+	assert.Equal(t, []string{"client=globex", "type=prod"}, env.Tags)
+
+	assert.True(t, env.RemoveTags("client"))
+	assert.Equal(t, []string{"type=prod"}, env.Tags)
+	assert.False(t, env.RemoveTags("missing"))
+}
+
+func TestFilterByTags(t *testing.T) {
+	acmeProd := &Environment{Name: "acme-prod", Tags: []string{"client=acme", "type=prod"}}
+	acmeDev := &Environment{Name: "acme-dev", Tags: []string{"client=acme", "type=dev"}}
+	personal := &Environment{Name: "personal"}
+	envs := []*Environment{acmeProd, acmeDev, personal}
+
+	assert.Equal(t, envs, FilterByTags(envs, nil))
+	assert.Equal(t, []*Environment{acmeProd, acmeDev}, FilterByTags(envs, []string{"client=acme"}))
+	assert.Equal(t, []*Environment{acmeProd}, FilterByTags(envs, []string{"client=acme", "type=prod"}))
+	assert.Empty(t, FilterByTags(envs, []string{"client=globex"}))
+}
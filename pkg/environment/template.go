@@ -0,0 +1,247 @@
+package environment
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/hugofrely/envswitch/internal/redact"
+	"github.com/hugofrely/envswitch/internal/storage"
+)
+
+const templateFileExt = ".yaml"
+
+// Template is the structure of an environment without its snapshots and
+// secret values, to create environments set up the same way
+type Template struct {
+	Name         string          `yaml:"name"`
+	Description  string          `yaml:"description,omitempty"`
+	Source       string          `yaml:"source,omitempty"` // environment the template was saved from
+	CreatedAt    time.Time       `yaml:"created_at"`
+	Tools        []string        `yaml:"tools"` // enabled tools
+	EnvVars      []TemplateVar   `yaml:"environment_variables,omitempty"`
+	Hooks        Hooks           `yaml:"hooks,omitempty"`
+	Tags         []string        `yaml:"tags,omitempty"`
+	ShellHistory string          `yaml:"shell_history,omitempty"`
+	Plugins      map[string]bool `yaml:"plugins,omitempty"`
+}
+
+// TemplateVar is a variable of a template. Secret variables have no default,
+// a value is asked for when the template is used.
+type TemplateVar struct {
+	Key     string `yaml:"key"`
+	Default string `yaml:"default,omitempty"`
+	Secret  bool   `yaml:"secret,omitempty"`
+}
+
+// GetTemplatesDir returns the path to the templates directory
+func GetTemplatesDir() (string, error) {
+	dir, err := GetEnvswitchDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "templates"), nil
+}
+
+// ValidateTemplateName checks that name is a valid template name, an
+// environment name without group
+func ValidateTemplateName(name string) error {
+	if strings.Contains(name, GroupSeparator) {
+		return fmt.Errorf("invalid template name '%s': templates cannot be grouped", name)
+	}
+	if err := ValidateName(name); err != nil {
+		return fmt.Errorf("invalid template name: %w", err)
+	}
+	return nil
+}
+
+// NewTemplate returns a template named name with the structure of env: its
+// enabled tools, hooks, tags and variables, without the values of secrets
+func NewTemplate(env *Environment, name string) (*Template, error) {
+	if err := ValidateTemplateName(name); err != nil {
+		return nil, err
+	}
+
+	// The values of secrets are left out, no need to decrypt them
+	envVars, err := env.ListEnvVarsMasked("")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read variables of '%s': %w", env.Name, err)
+	}
+
+	tmpl := &Template{
+		Name:         name,
+		Description:  env.Description,
+		Source:       env.Name,
+		CreatedAt:    time.Now(),
+		Tools:        []string{},
+		Hooks:        env.Hooks,
+		Tags:         env.Tags,
+		ShellHistory: env.ShellHistory,
+		Plugins:      env.Plugins,
+	}
+	for toolName, toolConfig := range env.Tools {
+		if toolConfig.Enabled {
+			tmpl.Tools = append(tmpl.Tools, toolName)
+		}
+	}
+	sort.Strings(tmpl.Tools)
+
+	for _, envVar := range envVars {
+		// Variables named like secrets are treated as secrets even when they
+		// are not marked as such
+		templateVar := TemplateVar{
+			Key:    envVar.Key,
+			Secret: env.IsSecretEnvVar(envVar.Key) || redact.IsSecretKey(envVar.Key),
+		}
+		// Values holding a secret, such as a URL with a password, are not
+		// kept as defaults either
+		if !templateVar.Secret && redact.String(envVar.Value) == envVar.Value {
+			templateVar.Default = envVar.Value
+		}
+		tmpl.EnvVars = append(tmpl.EnvVars, templateVar)
+	}
+	return tmpl, nil
+}
+
+// Save writes the template, replacing a template with the same name
+func (t *Template) Save() error {
+	dir, err := GetTemplatesDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create templates directory: %w", err)
+	}
+
+	data, err := yaml.Marshal(t)
+	if err != nil {
+		return fmt.Errorf("failed to marshal template: %w", err)
+	}
+	if err := storage.WriteFileAtomic(filepath.Join(dir, t.Name+templateFileExt), data, 0600); err != nil {
+		return fmt.Errorf("failed to write template: %w", err)
+	}
+	return nil
+}
+
+// TemplateExists reports whether a template named name exists
+func TemplateExists(name string) (bool, error) {
+	dir, err := GetTemplatesDir()
+	if err != nil {
+		return false, err
+	}
+	_, err = os.Stat(filepath.Join(dir, name+templateFileExt))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// LoadTemplate loads the template named name
+func LoadTemplate(name string) (*Template, error) {
+	if err := ValidateTemplateName(name); err != nil {
+		return nil, err
+	}
+	dir, err := GetTemplatesDir()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, name+templateFileExt))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("template '%s' not found", name)
+		}
+		return nil, fmt.Errorf("failed to read template: %w", err)
+	}
+
+	var tmpl Template
+	if err := yaml.Unmarshal(data, &tmpl); err != nil {
+		return nil, fmt.Errorf("failed to parse template '%s': %w", name, err)
+	}
+	tmpl.Name = name
+	return &tmpl, nil
+}
+
+// ListTemplates returns the templates sorted by name
+func ListTemplates() ([]*Template, error) {
+	dir, err := GetTemplatesDir()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []*Template{}, nil
+		}
+		return nil, fmt.Errorf("failed to read templates directory: %w", err)
+	}
+
+	templates := []*Template{}
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), templateFileExt)
+		if entry.IsDir() || !ok {
+			continue
+		}
+		tmpl, err := LoadTemplate(name)
+		if err != nil {
+			continue
+		}
+		templates = append(templates, tmpl)
+	}
+	return templates, nil
+}
+
+// DeleteTemplate removes the template named name
+func DeleteTemplate(name string) error {
+	if err := ValidateTemplateName(name); err != nil {
+		return err
+	}
+	dir, err := GetTemplatesDir()
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(filepath.Join(dir, name+templateFileExt)); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("template '%s' not found", name)
+		}
+		return fmt.Errorf("failed to delete template: %w", err)
+	}
+	return nil
+}
+
+// Apply gives env the structure of the template: its tools are enabled, its
+// hooks, tags and settings copied. The variables are set by the caller, with
+// the values asked for the secrets.
+func (t *Template) Apply(env *Environment) {
+	if env.Tools == nil {
+		env.Tools = make(map[string]ToolConfig)
+	}
+	for _, toolName := range t.Tools {
+		toolConfig := env.Tools[toolName]
+		toolConfig.Enabled = true
+		if toolConfig.SnapshotPath == "" {
+			toolConfig.SnapshotPath = filepath.Join("snapshots", toolName)
+		}
+		env.Tools[toolName] = toolConfig
+	}
+
+	if env.Description == "" {
+		env.Description = t.Description
+	}
+	env.Hooks = t.Hooks
+	env.Tags = append([]string{}, t.Tags...)
+	env.ShellHistory = t.ShellHistory
+	if len(t.Plugins) > 0 {
+		env.Plugins = make(map[string]bool, len(t.Plugins))
+		for name, enabled := range t.Plugins {
+			env.Plugins[name] = enabled
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package environment
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplate(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+
+	env := &Environment{
+		Name:        "client-a",
+		Description: "Client A",
+		Path:        filepath.Join(tempHome, ".envswitch", "environments", "client-a"),
+		Tools: map[string]ToolConfig{
+			"kubectl": {Enabled: true, SnapshotPath: "snapshots/kubectl"},
+			"aws":     {Enabled: true, SnapshotPath: "snapshots/aws"},
+			"gcloud":  {Enabled: false, SnapshotPath: "snapshots/gcloud"},
+		},
+		EnvVars: map[string]string{
+			"REGION":       "eu-west-1",
+			"API_TOKEN":    "s3cr3t",
+			"GITHUB_TOKEN": "ghp_unflagged",
+			"DATABASE_URL": "postgres://app:hunter22@db/app",
+		},
+		SecretEnvVars: []string{"API_TOKEN"},
+		Tags:          []string{"client"},
+		Hooks:         Hooks{PostSwitch: []Hook{{Command: "echo hi"}}},
+	}
+	require.NoError(t, os.MkdirAll(env.Path, 0755))
+
+	t.Run("leaves out disabled tools and secret values", func(t *testing.T) {
+		tmpl, err := NewTemplate(env, "client")
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"aws", "kubectl"}, tmpl.Tools)
+		assert.Equal(t, "client-a", tmpl.Source)
+		assert.Equal(t, []TemplateVar{
+			{Key: "API_TOKEN", Secret: true},
+			{Key: "DATABASE_URL"},
+			{Key: "GITHUB_TOKEN", Secret: true},
+			{Key: "REGION", Default: "eu-west-1"},
+		}, tmpl.EnvVars)
+
+		require.NoError(t, tmpl.Save())
+		data, err := os.ReadFile(filepath.Join(tempHome, ".envswitch", "templates", "client.yaml"))
+		require.NoError(t, err)
+		assert.NotContains(t, string(data), "s3cr3t")
+		assert.NotContains(t, string(data), "ghp_unflagged")
+		assert.NotContains(t, string(data), "hunter22")
+	})
+
+	t.Run("loads and lists saved templates", func(t *testing.T) {
+		exists, err := TemplateExists("client")
+		require.NoError(t, err)
+		assert.True(t, exists)
+
+		tmpl, err := LoadTemplate("client")
+		require.NoError(t, err)
+		assert.Equal(t, "Client A", tmpl.Description)
+		assert.Equal(t, []string{"client"}, tmpl.Tags)
+		assert.Len(t, tmpl.Hooks.PostSwitch, 1)
+
+		templates, err := ListTemplates()
+		require.NoError(t, err)
+		require.Len(t, templates, 1)
+		assert.Equal(t, "client", templates[0].Name)
+
+		_, err = LoadTemplate("missing")
+		assert.ErrorContains(t, err, "not found")
+	})
+
+	t.Run("applies to an environment", func(t *testing.T) {
+		tmpl, err := LoadTemplate("client")
+		require.NoError(t, err)
+
+		target := &Environment{Name: "client-b", Tools: map[string]ToolConfig{"gcloud": {}}}
+		tmpl.Apply(target)
+
+		assert.True(t, target.Tools["aws"].Enabled)
+		assert.True(t, target.Tools["kubectl"].Enabled)
+		assert.Equal(t, "snapshots/kubectl", filepath.ToSlash(target.Tools["kubectl"].SnapshotPath))
+		assert.False(t, target.Tools["gcloud"].Enabled)
+		assert.Equal(t, "Client A", target.Description)
+		assert.Equal(t, []string{"client"}, target.Tags)
+	})
+
+	t.Run("rejects invalid names", func(t *testing.T) {
+		_, err := NewTemplate(env, "team/client")
+		assert.Error(t, err)
+		_, err = NewTemplate(env, "")
+		assert.Error(t, err)
+	})
+
+	t.Run("deletes templates", func(t *testing.T) {
+		require.NoError(t, DeleteTemplate("client"))
+		assert.ErrorContains(t, DeleteTemplate("client"), "not found")
+
+		templates, err := ListTemplates()
+		require.NoError(t, err)
+		assert.Empty(t, templates)
+	})
+}
@@ -4,10 +4,17 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
+
+	"github.com/hugofrely/envswitch/internal/platform"
 )
 
+// DefaultCommandTimeout bounds plugin commands without a timeout
+const DefaultCommandTimeout = 30 * time.Second
+
 // Plugin represents a plugin that extends envswitch functionality
 type Plugin interface {
 	// Name returns the plugin name
@@ -50,12 +57,51 @@ type Metadata struct {
 	ToolName    string   `yaml:"tool_name"`              // The tool this plugin supports
 	ConfigPath  string   `yaml:"config_path,omitempty"`  // Optional: single custom config path (default: auto-detected)
 	ConfigPaths []string `yaml:"config_paths,omitempty"` // Optional: multiple config paths
+	Commands    `yaml:",inline"`
+}
+
+// Commands are shell commands a plugin runs instead of copying its config
+// paths. They run in the plugin directory with ENVSWITCH_SNAPSHOT_DIR set to
+// the snapshot directory.
+type Commands struct {
+	SnapshotCmd string `yaml:"snapshot_cmd,omitempty"` // writes the state into $ENVSWITCH_SNAPSHOT_DIR
+	RestoreCmd  string `yaml:"restore_cmd,omitempty"`  // applies the state from $ENVSWITCH_SNAPSHOT_DIR
+	MetadataCmd string `yaml:"metadata_cmd,omitempty"` // prints a YAML or JSON mapping
+	ValidateCmd string `yaml:"validate_cmd,omitempty"` // fails when the snapshot is invalid
+	Timeout     string `yaml:"timeout,omitempty"`      // per command, e.g. "1m" (default: 30s)
+}
+
+// IsEmpty reports whether no command is set
+func (c Commands) IsEmpty() bool {
+	return c.SnapshotCmd == "" && c.RestoreCmd == "" && c.MetadataCmd == "" && c.ValidateCmd == ""
+}
+
+// TimeoutDuration returns the timeout of each command
+func (c Commands) TimeoutDuration() (time.Duration, error) {
+	if c.Timeout == "" {
+		return DefaultCommandTimeout, nil
+	}
+	timeout, err := time.ParseDuration(c.Timeout)
+	if err != nil || timeout <= 0 {
+		return 0, fmt.Errorf("invalid timeout '%s' (use e.g. 30s or 2m)", c.Timeout)
+	}
+	return timeout, nil
+}
+
+// Validate checks that the commands can snapshot and restore together
+func (c Commands) Validate() error {
+	if (c.SnapshotCmd == "") != (c.RestoreCmd == "") {
+		return fmt.Errorf("snapshot_cmd and restore_cmd must be set together")
+	}
+	_, err := c.TimeoutDuration()
+	return err
 }
 
 // Manifest represents the plugin manifest file
 type Manifest struct {
 	Metadata Metadata `yaml:"metadata"`
-	// Future: add hooks, dependencies, etc.
+	Dir      string   `yaml:"-"` // directory holding plugin.yaml
+	// Future: add dependencies, etc.
 }
 
 // LoadManifest loads a plugin manifest from a file
@@ -80,13 +126,17 @@ func LoadManifest(path string) (*Manifest, error) {
 	if manifest.Metadata.ToolName == "" {
 		return nil, fmt.Errorf("tool_name is required")
 	}
+	if err := manifest.Metadata.Commands.Validate(); err != nil {
+		return nil, err
+	}
 
+	manifest.Dir = filepath.Dir(path)
 	return &manifest, nil
 }
 
 // GetPluginsDir returns the plugins directory path
 func GetPluginsDir() (string, error) {
-	home, err := os.UserHomeDir()
+	home, err := platform.HomeDir()
 	if err != nil {
 		return "", fmt.Errorf("failed to get home directory: %w", err)
 	}
@@ -115,7 +165,8 @@ func ListInstalledPlugins() ([]*Manifest, error) {
 
 	var plugins []*Manifest
 	for _, entry := range entries {
-		if !entry.IsDir() {
+		// Hidden directories hold plugins being installed
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
 			continue
 		}
 
@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -88,6 +89,80 @@ metadata:
 		_, err := LoadManifest("/non/existent/path/plugin.yaml")
 		assert.Error(t, err)
 	})
+
+	t.Run("loads commands", func(t *testing.T) {
+		tempDir := t.TempDir()
+		manifestPath := filepath.Join(tempDir, "plugin.yaml")
+
+		manifestContent := `
+metadata:
+  name: vault
+  version: 1.0.0
+  tool_name: vault
+  snapshot_cmd: ./snapshot.sh
+  restore_cmd: ./restore.sh
+  metadata_cmd: vault token lookup -format=json
+  timeout: 1m
+`
+		require.NoError(t, os.WriteFile(manifestPath, []byte(manifestContent), 0644))
+
+		manifest, err := LoadManifest(manifestPath)
+		require.NoError(t, err)
+		assert.Equal(t, tempDir, manifest.Dir)
+		assert.Equal(t, "./snapshot.sh", manifest.Metadata.SnapshotCmd)
+		assert.Equal(t, "./restore.sh", manifest.Metadata.RestoreCmd)
+		assert.Equal(t, "vault token lookup -format=json", manifest.Metadata.MetadataCmd)
+		assert.Empty(t, manifest.Metadata.ValidateCmd)
+		assert.False(t, manifest.Metadata.Commands.IsEmpty())
+
+		timeout, err := manifest.Metadata.Commands.TimeoutDuration()
+		require.NoError(t, err)
+		assert.Equal(t, time.Minute, timeout)
+	})
+
+	t.Run("fails on snapshot_cmd without restore_cmd", func(t *testing.T) {
+		tempDir := t.TempDir()
+		manifestPath := filepath.Join(tempDir, "plugin.yaml")
+
+		manifestContent := `
+metadata:
+  name: vault
+  version: 1.0.0
+  tool_name: vault
+  snapshot_cmd: ./snapshot.sh
+`
+		require.NoError(t, os.WriteFile(manifestPath, []byte(manifestContent), 0644))
+
+		_, err := LoadManifest(manifestPath)
+		assert.ErrorContains(t, err, "must be set together")
+	})
+
+	t.Run("fails on invalid timeout", func(t *testing.T) {
+		tempDir := t.TempDir()
+		manifestPath := filepath.Join(tempDir, "plugin.yaml")
+
+		manifestContent := `
+metadata:
+  name: vault
+  version: 1.0.0
+  tool_name: vault
+  metadata_cmd: vault status
+  timeout: soon
+`
+		require.NoError(t, os.WriteFile(manifestPath, []byte(manifestContent), 0644))
+
+		_, err := LoadManifest(manifestPath)
+		assert.ErrorContains(t, err, "invalid timeout")
+	})
+}
+
+func TestCommandsTimeoutDuration(t *testing.T) {
+	timeout, err := Commands{}.TimeoutDuration()
+	require.NoError(t, err)
+	assert.Equal(t, DefaultCommandTimeout, timeout)
+
+	_, err = Commands{Timeout: "-1s"}.TimeoutDuration()
+	assert.Error(t, err)
 }
 
 func TestGetPluginsDir(t *testing.T) {
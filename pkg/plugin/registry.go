@@ -0,0 +1,280 @@
+package plugin
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultRegistryURL is the index used when plugin_registry is not set
+const DefaultRegistryURL = "https://raw.githubusercontent.com/hugofrely/envswitch-plugins/main/index.json"
+
+// maxArchiveSize bounds the size of downloaded plugin archives
+const maxArchiveSize = 50 << 20
+
+// Index lists the plugins a registry offers
+type Index struct {
+	Plugins []IndexEntry `json:"plugins"`
+}
+
+// IndexEntry describes the latest release of a plugin in the index
+type IndexEntry struct {
+	Name        string   `json:"name"`
+	Version     string   `json:"version"`
+	Description string   `json:"description,omitempty"`
+	ToolName    string   `json:"tool_name,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	URL         string   `json:"url"`    // .tar.gz archive, e.g. a GitHub release asset
+	SHA256      string   `json:"sha256"` // hex checksum of the archive
+}
+
+// Find returns the entry of the named plugin
+func (i *Index) Find(name string) (*IndexEntry, bool) {
+	for idx := range i.Plugins {
+		if i.Plugins[idx].Name == name {
+			return &i.Plugins[idx], true
+		}
+	}
+	return nil, false
+}
+
+// Search returns the entries whose name, tool, description or tags contain
+// query, ignoring case, sorted by name. An empty query matches every entry.
+func (i *Index) Search(query string) []IndexEntry {
+	query = strings.ToLower(strings.TrimSpace(query))
+
+	var matches []IndexEntry
+	for _, entry := range i.Plugins {
+		fields := append([]string{entry.Name, entry.ToolName, entry.Description}, entry.Tags...)
+		for _, field := range fields {
+			if strings.Contains(strings.ToLower(field), query) {
+				matches = append(matches, entry)
+				break
+			}
+		}
+	}
+
+	sort.Slice(matches, func(a, b int) bool { return matches[a].Name < matches[b].Name })
+	return matches
+}
+
+// Registry fetches plugins from an index at a URL or a local path
+type Registry struct {
+	URL    string
+	client *http.Client
+}
+
+// NewRegistry creates a registry client for the index at url, the default
+// registry when url is empty
+func NewRegistry(url string) *Registry {
+	if url == "" {
+		url = DefaultRegistryURL
+	}
+	return &Registry{
+		URL:    url,
+		client: &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// FetchIndex downloads and parses the index
+func (r *Registry) FetchIndex() (*Index, error) {
+	data, err := r.fetch(r.URL, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch plugin index: %w", err)
+	}
+
+	var index Index
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("failed to parse plugin index: %w", err)
+	}
+	return &index, nil
+}
+
+// Download fetches the archive of entry, verifies its checksum and extracts
+// it into destDir. It returns the directory holding plugin.yaml.
+func (r *Registry) Download(entry *IndexEntry, destDir string) (string, error) {
+	if entry.URL == "" {
+		return "", fmt.Errorf("plugin '%s' has no download URL in the index", entry.Name)
+	}
+	if entry.SHA256 == "" {
+		return "", fmt.Errorf("plugin '%s' has no checksum in the index", entry.Name)
+	}
+
+	data, err := r.fetch(entry.URL, maxArchiveSize)
+	if err != nil {
+		return "", fmt.Errorf("failed to download plugin '%s': %w", entry.Name, err)
+	}
+	if err := VerifyChecksum(data, entry.SHA256); err != nil {
+		return "", fmt.Errorf("plugin '%s': %w", entry.Name, err)
+	}
+
+	if err := ExtractArchive(bytes.NewReader(data), destDir); err != nil {
+		return "", fmt.Errorf("failed to extract plugin '%s': %w", entry.Name, err)
+	}
+	return FindManifestDir(destDir)
+}
+
+// fetch reads an http(s) URL or a local path. limit bounds the size of the
+// content when positive.
+func (r *Registry) fetch(url string, limit int64) ([]byte, error) {
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return os.ReadFile(strings.TrimPrefix(url, "file://"))
+	}
+
+	resp, err := r.client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	if limit <= 0 {
+		return io.ReadAll(resp.Body)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("archive is larger than %d MB", limit>>20)
+	}
+	return data, nil
+}
+
+// VerifyChecksum checks that data hashes to the hex SHA-256 checksum expected
+func VerifyChecksum(data []byte, expected string) error {
+	sum := sha256.Sum256(data)
+	actual := hex.EncodeToString(sum[:])
+	if !strings.EqualFold(actual, strings.TrimSpace(expected)) {
+		return fmt.Errorf("checksum mismatch: expected %s, got %s", expected, actual)
+	}
+	return nil
+}
+
+// ExtractArchive extracts a .tar.gz archive into destDir. Entries leaving
+// destDir and links are rejected.
+func ExtractArchive(r io.Reader, destDir string) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("not a gzip archive: %w", err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid archive: %w", err)
+		}
+
+		name := filepath.Clean(filepath.FromSlash(header.Name))
+		if filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("invalid path in archive: %s", header.Name)
+		}
+		target := filepath.Join(destDir, name)
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			// Keep the executable bit of scripts run by the plugin commands
+			mode := os.FileMode(0644)
+			if header.Mode&0111 != 0 {
+				mode = 0755
+			}
+			file, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+			if err != nil {
+				return err
+			}
+			// #nosec G110 - The archive size is bounded by maxArchiveSize
+			if _, err := io.Copy(file, tr); err != nil {
+				_ = file.Close()
+				return err
+			}
+			if err := file.Close(); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unsupported entry in archive: %s", header.Name)
+		}
+	}
+}
+
+// FindManifestDir returns dir when it holds plugin.yaml, or its only
+// subdirectory when that one does, as in archives of a plugin-name/ folder
+func FindManifestDir(dir string) (string, error) {
+	if _, err := os.Stat(filepath.Join(dir, "plugin.yaml")); err == nil {
+		return dir, nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	if len(entries) == 1 && entries[0].IsDir() {
+		sub := filepath.Join(dir, entries[0].Name())
+		if _, err := os.Stat(filepath.Join(sub, "plugin.yaml")); err == nil {
+			return sub, nil
+		}
+	}
+	return "", fmt.Errorf("plugin.yaml not found in archive")
+}
+
+// CompareVersions compares two versions like 1.2.0 or v1.10, returning -1,
+// 0 or 1. Parts that are not numbers are compared as text.
+func CompareVersions(a, b string) int {
+	partsA := strings.Split(strings.TrimPrefix(a, "v"), ".")
+	partsB := strings.Split(strings.TrimPrefix(b, "v"), ".")
+
+	for i := 0; i < len(partsA) || i < len(partsB); i++ {
+		partA, partB := "0", "0"
+		if i < len(partsA) {
+			partA = partsA[i]
+		}
+		if i < len(partsB) {
+			partB = partsB[i]
+		}
+
+		numA, errA := strconv.Atoi(partA)
+		numB, errB := strconv.Atoi(partB)
+		switch {
+		case errA == nil && errB == nil:
+			if numA != numB {
+				if numA < numB {
+					return -1
+				}
+				return 1
+			}
+		case partA != partB:
+			if partA < partB {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
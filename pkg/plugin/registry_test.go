@@ -0,0 +1,162 @@
+package plugin
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// buildArchive returns a .tar.gz archive of files, keyed by path
+func buildArchive(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     0755,
+			Size:     int64(len(content)),
+			Typeflag: tar.TypeReg,
+		}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestIndexSearch(t *testing.T) {
+	index := &Index{Plugins: []IndexEntry{
+		{Name: "vim", Description: "Vim configuration"},
+		{Name: "terraform", Description: "Terraform workspaces", Tags: []string{"iac"}},
+		{Name: "npm", ToolName: "npm", Description: "NPM registry"},
+	}}
+
+	assert.Len(t, index.Search(""), 3)
+	assert.Equal(t, "npm", index.Search("")[0].Name)
+
+	matches := index.Search("IAC")
+	require.Len(t, matches, 1)
+	assert.Equal(t, "terraform", matches[0].Name)
+
+	assert.Empty(t, index.Search("docker"))
+
+	entry, ok := index.Find("vim")
+	require.True(t, ok)
+	assert.Equal(t, "Vim configuration", entry.Description)
+	_, ok = index.Find("vi")
+	assert.False(t, ok)
+}
+
+func TestRegistryDownload(t *testing.T) {
+	archive := buildArchive(t, map[string]string{
+		"terraform/plugin.yaml": "metadata:\n  name: terraform\n  version: 1.1.0\n  tool_name: terraform\n",
+		"terraform/snapshot.sh": "#!/bin/sh\n",
+	})
+
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	index := Index{Plugins: []IndexEntry{
+		{Name: "terraform", Version: "1.1.0", URL: server.URL + "/terraform.tar.gz", SHA256: checksum(archive)},
+		{Name: "tampered", Version: "1.0.0", URL: server.URL + "/terraform.tar.gz", SHA256: checksum([]byte("other"))},
+		{Name: "unsigned", Version: "1.0.0", URL: server.URL + "/terraform.tar.gz"},
+	}}
+	mux.HandleFunc("/index.json", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(index)
+	})
+	mux.HandleFunc("/terraform.tar.gz", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(archive)
+	})
+
+	registry := NewRegistry(server.URL + "/index.json")
+	fetched, err := registry.FetchIndex()
+	require.NoError(t, err)
+	require.Len(t, fetched.Plugins, 3)
+
+	t.Run("extracts a verified archive", func(t *testing.T) {
+		entry, _ := fetched.Find("terraform")
+		dir, err := registry.Download(entry, t.TempDir())
+		require.NoError(t, err)
+		assert.Equal(t, "terraform", filepath.Base(dir))
+		assert.FileExists(t, filepath.Join(dir, "plugin.yaml"))
+
+		info, err := os.Stat(filepath.Join(dir, "snapshot.sh"))
+		require.NoError(t, err)
+		assert.NotZero(t, info.Mode()&0100, "scripts stay executable")
+	})
+
+	t.Run("rejects a checksum mismatch", func(t *testing.T) {
+		entry, _ := fetched.Find("tampered")
+		destDir := t.TempDir()
+		_, err := registry.Download(entry, destDir)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "checksum mismatch")
+
+		files, _ := os.ReadDir(destDir)
+		assert.Empty(t, files, "nothing is extracted")
+	})
+
+	t.Run("requires a checksum", func(t *testing.T) {
+		entry, _ := fetched.Find("unsigned")
+		_, err := registry.Download(entry, t.TempDir())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "no checksum")
+	})
+
+	t.Run("reports HTTP errors", func(t *testing.T) {
+		_, err := NewRegistry(server.URL + "/missing.json").FetchIndex()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "404")
+	})
+}
+
+func TestRegistryReadsLocalIndex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"plugins": [{"name": "vim", "version": "1.0.0"}]}`), 0644))
+
+	index, err := NewRegistry(path).FetchIndex()
+	require.NoError(t, err)
+	require.Len(t, index.Plugins, 1)
+	assert.Equal(t, "vim", index.Plugins[0].Name)
+
+	assert.Equal(t, DefaultRegistryURL, NewRegistry("").URL)
+}
+
+func TestExtractArchiveRejectsEscapingPaths(t *testing.T) {
+	archive := buildArchive(t, map[string]string{"../evil": "x"})
+
+	destDir := filepath.Join(t.TempDir(), "dest")
+	require.NoError(t, os.MkdirAll(destDir, 0755))
+	err := ExtractArchive(bytes.NewReader(archive), destDir)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid path")
+	assert.NoFileExists(t, filepath.Join(filepath.Dir(destDir), "evil"))
+}
+
+func TestCompareVersions(t *testing.T) {
+	assert.Equal(t, 0, CompareVersions("1.0.0", "v1.0"))
+	assert.Equal(t, 1, CompareVersions("1.10.0", "1.9.3"))
+	assert.Equal(t, -1, CompareVersions("1.2", "1.2.1"))
+	assert.Equal(t, 1, CompareVersions("2.0.0", "1.99.99"))
+	assert.Equal(t, -1, CompareVersions("1.0.0-alpha", "1.0.0-beta"))
+}
@@ -8,6 +8,19 @@ import (
 	"time"
 )
 
+// Modes of every spinner, set by --quiet and --no-color
+var (
+	quietMode bool
+	plainMode bool
+)
+
+// SetMode makes spinners silent but for errors when quiet is set, and
+// without animation or symbols when plain is set
+func SetMode(quiet, plain bool) {
+	quietMode = quiet
+	plainMode = plain
+}
+
 // Spinner represents a CLI spinner
 type Spinner struct {
 	frames  []string
@@ -16,6 +29,11 @@ type Spinner struct {
 	mu      sync.Mutex
 	writer  io.Writer
 	active  bool
+	// animate is unset when the writer is not a terminal, the spinner then
+	// prints a line per message
+	animate bool
+	quiet   bool
+	plain   bool
 }
 
 // New creates a new spinner with default frames
@@ -26,9 +44,25 @@ func New(message string) *Spinner {
 		stop:    make(chan bool),
 		writer:  os.Stdout,
 		active:  false,
+		animate: !quietMode && !plainMode && isTerminal(os.Stdout),
+		quiet:   quietMode,
+		plain:   plainMode,
 	}
 }
 
+// isTerminal reports whether w is a terminal
+func isTerminal(w io.Writer) bool {
+	file, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
 // Start begins the spinner animation
 func (s *Spinner) Start() {
 	s.mu.Lock()
@@ -37,6 +71,11 @@ func (s *Spinner) Start() {
 		return
 	}
 	s.active = true
+	if !s.animate {
+		s.printLine(s.message)
+		s.mu.Unlock()
+		return
+	}
 	s.mu.Unlock()
 
 	go func() {
@@ -61,8 +100,30 @@ func (s *Spinner) Start() {
 // Update changes the spinner message while it's running
 func (s *Spinner) Update(message string) {
 	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.animate && s.active && message != s.message {
+		s.printLine(message)
+	}
 	s.message = message
-	s.mu.Unlock()
+}
+
+// printLine prints a message on its own line, for spinners that do not
+// animate
+func (s *Spinner) printLine(message string) {
+	if !s.quiet {
+		fmt.Fprintln(s.writer, message)
+	}
+}
+
+// finish stops the spinner and returns the prefix clearing its line
+func (s *Spinner) finish() string {
+	s.active = false
+	if !s.animate {
+		return ""
+	}
+	s.stop <- true
+	return "\r\033[K"
 }
 
 // Success stops the spinner and displays a success message
@@ -74,9 +135,15 @@ func (s *Spinner) Success(message string) {
 		return
 	}
 
-	s.active = false
-	s.stop <- true
-	fmt.Fprintf(s.writer, "\r\033[K✓ %s\n", message)
+	clear := s.finish()
+	if s.quiet {
+		return
+	}
+	mark := "✓ "
+	if s.plain {
+		mark = ""
+	}
+	fmt.Fprintf(s.writer, "%s%s%s\n", clear, mark, message)
 }
 
 // Error stops the spinner and displays an error message
@@ -88,9 +155,12 @@ func (s *Spinner) Error(message string) {
 		return
 	}
 
-	s.active = false
-	s.stop <- true
-	fmt.Fprintf(s.writer, "\r\033[K✗ %s\n", message)
+	// Errors are shown even in quiet mode
+	mark := "✗ "
+	if s.plain {
+		mark = "error: "
+	}
+	fmt.Fprintf(s.writer, "%s%s%s\n", s.finish(), mark, message)
 }
 
 // Stop stops the spinner without displaying a message
@@ -102,7 +172,5 @@ func (s *Spinner) Stop() {
 		return
 	}
 
-	s.active = false
-	s.stop <- true
-	fmt.Fprintf(s.writer, "\r\033[K")
+	fmt.Fprint(s.writer, s.finish())
 }
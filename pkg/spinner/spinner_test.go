@@ -133,3 +133,80 @@ func TestSpinnerMultipleStarts(t *testing.T) {
 		t.Error("Spinner should not be active after Stop()")
 	}
 }
+
+func TestSpinnerAnimated(t *testing.T) {
+	var buf bytes.Buffer
+	spin := New("spinning")
+	spin.writer = &buf
+	spin.animate = true
+
+	spin.Start()
+	time.Sleep(100 * time.Millisecond)
+	spin.Success("done")
+
+	output := buf.String()
+	if !strings.Contains(output, "\r\033[K") {
+		t.Errorf("Animated output should redraw the line, got: %q", output)
+	}
+	if !strings.Contains(output, "✓ done") {
+		t.Errorf("Output should contain success message, got: %q", output)
+	}
+}
+
+func TestSpinnerWithoutTerminal(t *testing.T) {
+	var buf bytes.Buffer
+	spin := New("saving")
+	spin.writer = &buf
+
+	if spin.animate {
+		t.Fatal("Spinner should not animate when stdout is not a terminal")
+	}
+
+	spin.Start()
+	spin.Update("restoring")
+	spin.Update("restoring")
+	spin.Success("done")
+
+	if output := buf.String(); output != "saving\nrestoring\n✓ done\n" {
+		t.Errorf("Expected one line per message, got: %q", output)
+	}
+}
+
+func TestSpinnerModes(t *testing.T) {
+	defer SetMode(false, false)
+
+	t.Run("quiet only shows errors", func(t *testing.T) {
+		SetMode(true, false)
+		var buf bytes.Buffer
+		spin := New("saving")
+		spin.writer = &buf
+
+		spin.Start()
+		spin.Update("restoring")
+		spin.Success("done")
+		if buf.Len() != 0 {
+			t.Errorf("Quiet spinner should print nothing, got: %q", buf.String())
+		}
+
+		spin.Start()
+		spin.Error("failed")
+		if output := buf.String(); output != "✗ failed\n" {
+			t.Errorf("Quiet spinner should print errors, got: %q", output)
+		}
+	})
+
+	t.Run("plain has no symbols", func(t *testing.T) {
+		SetMode(false, true)
+		var buf bytes.Buffer
+		spin := New("saving")
+		spin.writer = &buf
+
+		spin.Start()
+		spin.Success("done")
+		spin.Start()
+		spin.Error("failed")
+		if output := buf.String(); output != "saving\ndone\nsaving\nerror: failed\n" {
+			t.Errorf("Unexpected plain output: %q", output)
+		}
+	})
+}
@@ -1,23 +1,27 @@
 package tools
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 
+	"github.com/hugofrely/envswitch/internal/platform"
 	"github.com/hugofrely/envswitch/internal/storage"
 )
 
 // AWSTool implements the Tool interface for AWS CLI
 type AWSTool struct {
-	AWSConfigDir string // ~/.aws
+	AWSConfigDir    string           // ~/.aws
+	ExcludePatterns []string         // paths left out of snapshots, see storage.IsExcluded
+	Progress        storage.Progress // told about each file copied, may be nil
 }
 
 // NewAWSTool creates a new AWS tool instance
 func NewAWSTool() *AWSTool {
-	home, _ := os.UserHomeDir()
+	home, _ := platform.HomeDir()
 	return &AWSTool{
 		AWSConfigDir: filepath.Join(home, ".aws"),
 	}
@@ -27,6 +31,20 @@ func (a *AWSTool) Name() string {
 	return "aws"
 }
 
+func (a *AWSTool) ConfigPaths() []string {
+	return []string{a.AWSConfigDir}
+}
+
+// AddExcludePatterns adds patterns to the paths left out of snapshots
+func (a *AWSTool) AddExcludePatterns(patterns ...string) {
+	a.ExcludePatterns = append(a.ExcludePatterns, patterns...)
+}
+
+// SetProgress sets where the progress of the files copied is reported
+func (a *AWSTool) SetProgress(progress storage.Progress) {
+	a.Progress = progress
+}
+
 func (a *AWSTool) IsInstalled() bool {
 	_, err := exec.LookPath("aws")
 	return err == nil
@@ -47,8 +65,8 @@ func (a *AWSTool) Snapshot(snapshotPath string) error {
 		return fmt.Errorf("failed to create snapshot directory: %w", err)
 	}
 
-	// Copy the entire .aws directory to snapshot
-	if err := storage.CopyDir(a.AWSConfigDir, snapshotPath); err != nil {
+	// Copy the config directory to the snapshot, only rewriting changed files
+	if _, err := storage.SnapshotDirProgress(a.AWSConfigDir, snapshotPath, a.Progress, a.ExcludePatterns...); err != nil {
 		return fmt.Errorf("failed to copy aws config: %w", err)
 	}
 
@@ -71,18 +89,38 @@ func (a *AWSTool) Restore(snapshotPath string) error {
 		return fmt.Errorf("failed to create config parent directory: %w", err)
 	}
 
-	// Remove existing config directory if it exists
-	if _, err := os.Stat(a.AWSConfigDir); err == nil {
-		if err := os.RemoveAll(a.AWSConfigDir); err != nil {
-			return fmt.Errorf("failed to remove existing config: %w", err)
-		}
+	// Restore from snapshot, only rewriting files that differ and removing
+	// files the snapshot does not have
+	if _, err := storage.SyncDirProgress(snapshotPath, a.AWSConfigDir, a.Progress, a.ExcludePatterns...); err != nil {
+		return fmt.Errorf("failed to restore aws config: %w", err)
 	}
 
-	// Restore from snapshot
-	if err := storage.CopyDir(snapshotPath, a.AWSConfigDir); err != nil {
-		return fmt.Errorf("failed to restore aws config: %w", err)
+	return nil
+}
+
+// Merge merges the profiles of the snapshot into the live config and
+// credentials files, key by key, and copies its other files, such as the SSO
+// cache, over the live ones
+func (a *AWSTool) Merge(ctx context.Context, snapshotPath string) error {
+	if !a.IsInstalled() {
+		return fmt.Errorf("aws cli is not installed")
+	}
+	if err := a.ValidateSnapshot(snapshotPath); err != nil {
+		return fmt.Errorf("invalid snapshot: %w", err)
 	}
 
+	if err := os.MkdirAll(a.AWSConfigDir, 0700); err != nil {
+		return fmt.Errorf("failed to create aws config directory: %w", err)
+	}
+	err := mergeDir(ctx, snapshotPath, a.AWSConfigDir, a.ExcludePatterns, func(rel string) fileMerger {
+		if rel == "config" || rel == "credentials" {
+			return mergeINIFile
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to merge aws config: %w", err)
+	}
 	return nil
 }
 
@@ -208,6 +246,13 @@ func (a *AWSTool) Diff(snapshotPath string) ([]Change, error) {
 		})
 	}
 
+	// Compare config files
+	fileChanges, err := diffTree(snapshotPath, a.AWSConfigDir, "", a.ExcludePatterns...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff aws config: %w", err)
+	}
+	changes = append(changes, fileChanges...)
+
 	return changes, nil
 }
 
@@ -0,0 +1,28 @@
+package tools
+
+import "context"
+
+// SnapshotContext snapshots tool, stopping when ctx is done if the tool
+// supports it. A tool without ContextTool is not started once ctx is done,
+// but runs to the end otherwise.
+func SnapshotContext(ctx context.Context, tool Tool, snapshotPath string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if ct, ok := tool.(ContextTool); ok {
+		return ct.SnapshotContext(ctx, snapshotPath)
+	}
+	return tool.Snapshot(snapshotPath)
+}
+
+// RestoreContext restores tool, stopping when ctx is done if the tool
+// supports it, see SnapshotContext
+func RestoreContext(ctx context.Context, tool Tool, snapshotPath string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if ct, ok := tool.(ContextTool); ok {
+		return ct.RestoreContext(ctx, snapshotPath)
+	}
+	return tool.Restore(snapshotPath)
+}
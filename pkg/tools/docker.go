@@ -8,17 +8,20 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/hugofrely/envswitch/internal/platform"
 	"github.com/hugofrely/envswitch/internal/storage"
 )
 
 // DockerTool implements the Tool interface for Docker
 type DockerTool struct {
-	DockerConfigDir string // ~/.docker
+	DockerConfigDir string           // ~/.docker, also %USERPROFILE%\.docker on Windows
+	ExcludePatterns []string         // paths left out of snapshots, see storage.IsExcluded
+	Progress        storage.Progress // told about each file copied, may be nil
 }
 
 // NewDockerTool creates a new Docker tool instance
 func NewDockerTool() *DockerTool {
-	home, _ := os.UserHomeDir()
+	home, _ := platform.HomeDir()
 	return &DockerTool{
 		DockerConfigDir: filepath.Join(home, ".docker"),
 	}
@@ -28,6 +31,20 @@ func (d *DockerTool) Name() string {
 	return "docker"
 }
 
+func (d *DockerTool) ConfigPaths() []string {
+	return []string{d.DockerConfigDir}
+}
+
+// AddExcludePatterns adds patterns to the paths left out of snapshots
+func (d *DockerTool) AddExcludePatterns(patterns ...string) {
+	d.ExcludePatterns = append(d.ExcludePatterns, patterns...)
+}
+
+// SetProgress sets where the progress of the files copied is reported
+func (d *DockerTool) SetProgress(progress storage.Progress) {
+	d.Progress = progress
+}
+
 func (d *DockerTool) IsInstalled() bool {
 	_, err := exec.LookPath("docker")
 	return err == nil
@@ -44,8 +61,8 @@ func (d *DockerTool) Snapshot(snapshotPath string) error {
 		return fmt.Errorf("failed to create snapshot directory: %w", err)
 	}
 
-	// Copy the entire .docker directory to snapshot
-	if err := storage.CopyDir(d.DockerConfigDir, snapshotPath); err != nil {
+	// Copy the config directory to the snapshot, only rewriting changed files
+	if _, err := storage.SnapshotDirProgress(d.DockerConfigDir, snapshotPath, d.Progress, d.ExcludePatterns...); err != nil {
 		return fmt.Errorf("failed to copy docker config: %w", err)
 	}
 
@@ -64,15 +81,9 @@ func (d *DockerTool) Restore(snapshotPath string) error {
 		return fmt.Errorf("failed to create config parent directory: %w", err)
 	}
 
-	// Remove existing config directory if it exists
-	if _, err := os.Stat(d.DockerConfigDir); err == nil {
-		if err := os.RemoveAll(d.DockerConfigDir); err != nil {
-			return fmt.Errorf("failed to remove existing config: %w", err)
-		}
-	}
-
-	// Restore from snapshot
-	if err := storage.CopyDir(snapshotPath, d.DockerConfigDir); err != nil {
+	// Restore from snapshot, only rewriting files that differ and removing
+	// files the snapshot does not have
+	if _, err := storage.SyncDirProgress(snapshotPath, d.DockerConfigDir, d.Progress, d.ExcludePatterns...); err != nil {
 		return fmt.Errorf("failed to restore docker config: %w", err)
 	}
 
@@ -115,26 +126,33 @@ func (d *DockerTool) ValidateSnapshot(snapshotPath string) error {
 }
 
 func (d *DockerTool) Diff(snapshotPath string) ([]Change, error) {
-	// Get current metadata
-	currentMeta, err := d.GetMetadata()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get current metadata: %w", err)
-	}
+	changes := []Change{}
 
-	// Get snapshot metadata
-	snapshotMeta, err := d.getSnapshotMetadata(snapshotPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get snapshot metadata: %w", err)
-	}
+	// Compare context (requires the docker CLI)
+	if d.IsInstalled() {
+		currentMeta, err := d.GetMetadata()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get current metadata: %w", err)
+		}
 
-	changes := []Change{}
+		snapshotMeta, err := d.getSnapshotMetadata(snapshotPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get snapshot metadata: %w", err)
+		}
 
-	// Compare context
-	changes = append(changes, compareMetadataField("context", snapshotMeta, currentMeta)...)
+		changes = append(changes, compareMetadataField("context", snapshotMeta, currentMeta)...)
+	}
 
 	// Note: We don't compare version as it's about the Docker server version,
 	// not about the configuration state
 
+	// Compare config files
+	fileChanges, err := diffTree(snapshotPath, d.DockerConfigDir, "", d.ExcludePatterns...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff docker config: %w", err)
+	}
+	changes = append(changes, fileChanges...)
+
 	return changes, nil
 }
 
@@ -0,0 +1,189 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/hugofrely/envswitch/pkg/plugin"
+)
+
+// execWaitDelay bounds how long a timed out command may keep its output
+// open, e.g. through background processes it started
+var execWaitDelay = 2 * time.Second
+
+// ExecPluginTool is a plugin tool that runs the commands of its plugin.yaml.
+// Operations without a command fall back to the tool copying the plugin's
+// config paths, when there is one.
+type ExecPluginTool struct {
+	toolName string
+	dir      string
+	commands plugin.Commands
+	timeout  time.Duration
+	base     Tool
+}
+
+// NewExecPluginTool creates a tool running commands in the plugin directory
+// dir. base may be nil when the commands snapshot and restore on their own.
+func NewExecPluginTool(toolName, dir string, commands plugin.Commands, base Tool) (*ExecPluginTool, error) {
+	if err := commands.Validate(); err != nil {
+		return nil, err
+	}
+	if commands.SnapshotCmd == "" && base == nil {
+		return nil, fmt.Errorf("plugin tool '%s' has neither snapshot_cmd nor config paths", toolName)
+	}
+
+	timeout, _ := commands.TimeoutDuration()
+	return &ExecPluginTool{
+		toolName: toolName,
+		dir:      dir,
+		commands: commands,
+		timeout:  timeout,
+		base:     base,
+	}, nil
+}
+
+func (e *ExecPluginTool) Name() string {
+	return e.toolName
+}
+
+// ConfigPaths returns the config paths of the plugin, none when its commands
+// take the snapshots
+func (e *ExecPluginTool) ConfigPaths() []string {
+	if provider, ok := e.base.(PathProvider); ok && e.commands.SnapshotCmd == "" {
+		return provider.ConfigPaths()
+	}
+	return nil
+}
+
+func (e *ExecPluginTool) IsInstalled() bool {
+	if e.base != nil {
+		return e.base.IsInstalled()
+	}
+	_, err := exec.LookPath(e.toolName)
+	return err == nil
+}
+
+func (e *ExecPluginTool) Snapshot(snapshotPath string) error {
+	return e.SnapshotContext(context.Background(), snapshotPath)
+}
+
+// SnapshotContext runs snapshot_cmd, killing it when ctx is done
+func (e *ExecPluginTool) SnapshotContext(ctx context.Context, snapshotPath string) error {
+	if e.commands.SnapshotCmd == "" {
+		return SnapshotContext(ctx, e.base, snapshotPath)
+	}
+
+	if err := os.MkdirAll(snapshotPath, 0700); err != nil {
+		return fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+	if _, err := e.run(ctx, "snapshot_cmd", e.commands.SnapshotCmd, snapshotPath); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (e *ExecPluginTool) Restore(snapshotPath string) error {
+	return e.RestoreContext(context.Background(), snapshotPath)
+}
+
+// RestoreContext runs restore_cmd, killing it when ctx is done
+func (e *ExecPluginTool) RestoreContext(ctx context.Context, snapshotPath string) error {
+	if e.commands.RestoreCmd == "" {
+		return RestoreContext(ctx, e.base, snapshotPath)
+	}
+
+	if _, err := e.run(ctx, "restore_cmd", e.commands.RestoreCmd, snapshotPath); err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetMetadata returns the mapping printed by metadata_cmd
+func (e *ExecPluginTool) GetMetadata() (map[string]interface{}, error) {
+	if e.commands.MetadataCmd == "" {
+		if e.base != nil {
+			return e.base.GetMetadata()
+		}
+		return map[string]interface{}{}, nil
+	}
+
+	output, err := e.run(context.Background(), "metadata_cmd", e.commands.MetadataCmd, "")
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := make(map[string]interface{})
+	if err := yaml.Unmarshal(output, &metadata); err != nil {
+		return nil, fmt.Errorf("metadata_cmd must print a YAML or JSON mapping: %w", err)
+	}
+	return metadata, nil
+}
+
+func (e *ExecPluginTool) ValidateSnapshot(snapshotPath string) error {
+	if _, err := os.Stat(snapshotPath); os.IsNotExist(err) {
+		return fmt.Errorf("snapshot path does not exist: %s", snapshotPath)
+	}
+
+	if e.commands.ValidateCmd == "" {
+		if e.base != nil {
+			return e.base.ValidateSnapshot(snapshotPath)
+		}
+		return nil
+	}
+
+	_, err := e.run(context.Background(), "validate_cmd", e.commands.ValidateCmd, snapshotPath)
+	return err
+}
+
+// Diff compares the config paths with the snapshot. Snapshots taken by
+// snapshot_cmd have no known layout and never report changes.
+func (e *ExecPluginTool) Diff(snapshotPath string) ([]Change, error) {
+	if e.commands.SnapshotCmd == "" {
+		return e.base.Diff(snapshotPath)
+	}
+	return nil, nil
+}
+
+// run runs a command of the plugin within the timeout, or until parent is
+// done, and returns its standard output. Its error output is part of the
+// returned error.
+func (e *ExecPluginTool) run(parent context.Context, name, script, snapshotPath string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(parent, e.timeout)
+	defer cancel()
+
+	// #nosec G204 - Commands come from plugins the user installed
+	cmd := exec.CommandContext(ctx, "sh", "-c", script)
+	setProcessGroup(cmd)
+	cmd.WaitDelay = execWaitDelay
+	cmd.Dir = e.dir
+	cmd.Env = append(os.Environ(),
+		"ENVSWITCH_TOOL="+e.toolName,
+		"ENVSWITCH_PLUGIN_DIR="+e.dir,
+		"ENVSWITCH_SNAPSHOT_DIR="+snapshotPath,
+	)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if parentErr := parent.Err(); parentErr != nil {
+			err = parentErr
+		} else if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			err = fmt.Errorf("timed out after %s", e.timeout)
+		}
+		if output := strings.TrimSpace(stderr.String()); output != "" {
+			return nil, fmt.Errorf("%s of plugin '%s' failed: %w: %s", name, e.toolName, err, output)
+		}
+		return nil, fmt.Errorf("%s of plugin '%s' failed: %w", name, e.toolName, err)
+	}
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,130 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hugofrely/envswitch/pkg/plugin"
+)
+
+func TestNewExecPluginTool(t *testing.T) {
+	t.Run("requires snapshot_cmd or a base tool", func(t *testing.T) {
+		_, err := NewExecPluginTool("vault", t.TempDir(), plugin.Commands{MetadataCmd: "echo"}, nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("requires snapshot_cmd and restore_cmd together", func(t *testing.T) {
+		_, err := NewExecPluginTool("vault", t.TempDir(), plugin.Commands{SnapshotCmd: "true"}, nil)
+		assert.Error(t, err)
+	})
+}
+
+func TestExecPluginToolSnapshotRestore(t *testing.T) {
+	pluginDir := t.TempDir()
+	state := filepath.Join(t.TempDir(), "state")
+	require.NoError(t, os.WriteFile(state, []byte("work"), 0644))
+
+	// Scripts run from the plugin directory
+	require.NoError(t, os.WriteFile(filepath.Join(pluginDir, "snapshot.sh"),
+		[]byte(`cp "$STATE" "$ENVSWITCH_SNAPSHOT_DIR/state"`), 0755))
+	t.Setenv("STATE", state)
+
+	tool, err := NewExecPluginTool("vault", pluginDir, plugin.Commands{
+		SnapshotCmd: "sh ./snapshot.sh",
+		RestoreCmd:  `cp "$ENVSWITCH_SNAPSHOT_DIR/state" "$STATE"`,
+		ValidateCmd: `test -f "$ENVSWITCH_SNAPSHOT_DIR/state" || { echo "state missing" >&2; exit 1; }`,
+		MetadataCmd: `echo "{\"tool\": \"$ENVSWITCH_TOOL\", \"state\": \"$(cat "$STATE")\"}"`,
+	}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "vault", tool.Name())
+	assert.Empty(t, tool.ConfigPaths())
+
+	snapshotDir := filepath.Join(t.TempDir(), "snapshots", "vault")
+	require.NoError(t, tool.Snapshot(snapshotDir))
+	require.NoError(t, tool.ValidateSnapshot(snapshotDir))
+
+	metadata, err := tool.GetMetadata()
+	require.NoError(t, err)
+	assert.Equal(t, "vault", metadata["tool"])
+	assert.Equal(t, "work", metadata["state"])
+
+	require.NoError(t, os.WriteFile(state, []byte("personal"), 0644))
+	require.NoError(t, tool.Restore(snapshotDir))
+	data, err := os.ReadFile(state)
+	require.NoError(t, err)
+	assert.Equal(t, "work", string(data))
+
+	changes, err := tool.Diff(snapshotDir)
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+
+	// A failing command reports its error output
+	require.NoError(t, os.Remove(filepath.Join(snapshotDir, "state")))
+	err = tool.ValidateSnapshot(snapshotDir)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "validate_cmd of plugin 'vault' failed")
+	assert.Contains(t, err.Error(), "state missing")
+}
+
+func TestExecPluginToolTimeout(t *testing.T) {
+	tool, err := NewExecPluginTool("slow", t.TempDir(), plugin.Commands{
+		SnapshotCmd: "sleep 5",
+		RestoreCmd:  "true",
+		Timeout:     "100ms",
+	}, nil)
+	require.NoError(t, err)
+
+	err = tool.Snapshot(filepath.Join(t.TempDir(), "snapshot"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "timed out after 100ms")
+}
+
+func TestExecPluginToolCanceled(t *testing.T) {
+	tool, err := NewExecPluginTool("slow", t.TempDir(), plugin.Commands{
+		SnapshotCmd: "true",
+		RestoreCmd:  "sleep 5",
+	}, nil)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err = RestoreContext(ctx, tool, t.TempDir())
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 3*time.Second)
+
+	// Nothing is started once ctx is done
+	err = SnapshotContext(ctx, tool, filepath.Join(t.TempDir(), "snapshot"))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestExecPluginToolFallsBackToConfigPaths(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), ".toolrc")
+	require.NoError(t, os.WriteFile(configPath, []byte("live"), 0644))
+
+	base := NewGenericTool("tool", configPath)
+	tool, err := NewExecPluginTool("tool", t.TempDir(), plugin.Commands{
+		MetadataCmd: "echo 'profile: work'",
+	}, base)
+	require.NoError(t, err)
+	assert.Equal(t, []string{configPath}, tool.ConfigPaths())
+
+	snapshotDir := filepath.Join(t.TempDir(), "snapshot")
+	require.NoError(t, tool.Snapshot(snapshotDir))
+	assert.FileExists(t, filepath.Join(snapshotDir, ".toolrc"))
+
+	metadata, err := tool.GetMetadata()
+	require.NoError(t, err)
+	assert.Equal(t, "work", metadata["profile"])
+
+	require.NoError(t, os.WriteFile(configPath, []byte("changed"), 0644))
+	changes, err := tool.Diff(snapshotDir)
+	require.NoError(t, err)
+	assert.Len(t, changes, 1)
+}
@@ -0,0 +1,9 @@
+//go:build windows
+
+package tools
+
+import "os/exec"
+
+// setProcessGroup is a no-op on Windows, where only the command process
+// itself is killed when its context is done
+func setProcessGroup(cmd *exec.Cmd) {}
@@ -0,0 +1,150 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"github.com/hugofrely/envswitch/internal/storage"
+)
+
+// diffPath compares a snapshot file or directory with its live counterpart.
+// Directories are compared file by file; changes are reported relative to
+// displayPath.
+func diffPath(snapshotPath, livePath, displayPath string) ([]Change, error) {
+	return diffPathManifest(snapshotPath, livePath, displayPath, nil)
+}
+
+// diffPathManifest compares like diffPath, taking the content hashes of files
+// that still have the size and modification time recorded in manifest from
+// it. The manifest is keyed by the paths reported in changes.
+func diffPathManifest(snapshotPath, livePath, displayPath string, manifest *storage.Manifest) ([]Change, error) {
+	snapshotInfo, snapshotErr := os.Stat(snapshotPath)
+	liveInfo, liveErr := os.Stat(livePath)
+
+	snapshotExists := snapshotErr == nil
+	liveExists := liveErr == nil
+
+	switch {
+	case !snapshotExists && !liveExists:
+		return nil, nil
+	case snapshotExists && !liveExists:
+		return []Change{{Type: ChangeTypeRemoved, Path: displayPath}}, nil
+	case !snapshotExists && liveExists:
+		return []Change{{Type: ChangeTypeAdded, Path: displayPath}}, nil
+	case snapshotInfo.IsDir() && liveInfo.IsDir():
+		return diffTreeManifest(snapshotPath, livePath, displayPath, manifest, nil)
+	case snapshotInfo.IsDir() != liveInfo.IsDir():
+		return []Change{{Type: ChangeTypeModified, Path: displayPath}}, nil
+	}
+
+	snapshotHash, err := manifest.Hash(snapshotPath, displayPath, snapshotInfo)
+	if err != nil {
+		return nil, err
+	}
+	liveHash, err := manifest.Hash(livePath, displayPath, liveInfo)
+	if err != nil {
+		return nil, err
+	}
+	if snapshotHash != liveHash {
+		return []Change{{Type: ChangeTypeModified, Path: displayPath}}, nil
+	}
+	return nil, nil
+}
+
+// diffTree compares the files under snapshotDir with the files under liveDir
+// using content hashes. Paths in the returned changes are relative to the
+// directories and prefixed with prefix. A missing directory is treated as empty.
+// Paths matching the exclude patterns are not compared.
+func diffTree(snapshotDir, liveDir, prefix string, exclude ...string) ([]Change, error) {
+	return diffTreeManifest(snapshotDir, liveDir, prefix, nil, exclude)
+}
+
+// diffTreeManifest compares like diffTree, reusing the hashes recorded in
+// manifest, see diffPathManifest
+func diffTreeManifest(snapshotDir, liveDir, prefix string, manifest *storage.Manifest, exclude []string) ([]Change, error) {
+	snapshotFiles, err := hashTreeManifest(snapshotDir, prefix, manifest, exclude)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+
+	liveFiles, err := hashTreeManifest(liveDir, prefix, manifest, exclude)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read live config: %w", err)
+	}
+
+	changes := []Change{}
+
+	for relPath, snapshotHash := range snapshotFiles {
+		liveHash, exists := liveFiles[relPath]
+		switch {
+		case !exists:
+			changes = append(changes, Change{Type: ChangeTypeRemoved, Path: path.Join(prefix, relPath)})
+		case liveHash != snapshotHash:
+			changes = append(changes, Change{Type: ChangeTypeModified, Path: path.Join(prefix, relPath)})
+		}
+	}
+
+	for relPath := range liveFiles {
+		if _, exists := snapshotFiles[relPath]; !exists {
+			changes = append(changes, Change{Type: ChangeTypeAdded, Path: path.Join(prefix, relPath)})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+
+	return changes, nil
+}
+
+// hashTree returns the SHA-256 of every regular file under root, keyed by
+// slash-separated relative path. The snapshot manifest and the paths matching
+// the exclude patterns are not included.
+func hashTree(root string, exclude []string) (map[string]string, error) {
+	return hashTreeManifest(root, "", nil, exclude)
+}
+
+// hashTreeManifest hashes like hashTree, taking the hash of a file from the
+// entry of manifest for its relative path prefixed with prefix while the file
+// still matches it
+func hashTreeManifest(root, prefix string, manifest *storage.Manifest, exclude []string) (map[string]string, error) {
+	hashes := make(map[string]string)
+
+	if _, err := os.Stat(root); os.IsNotExist(err) {
+		return hashes, nil
+	}
+
+	err := filepath.Walk(root, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(root, filePath)
+		if err != nil {
+			return err
+		}
+		if storage.IsExcluded(filepath.ToSlash(relPath), exclude) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() || relPath == storage.ManifestFile {
+			return nil
+		}
+
+		relPath = filepath.ToSlash(relPath)
+		hash, err := manifest.Hash(filePath, path.Join(prefix, relPath), info)
+		if err != nil {
+			return err
+		}
+
+		hashes[relPath] = hash
+		return nil
+	})
+
+	return hashes, err
+}
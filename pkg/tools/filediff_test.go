@@ -0,0 +1,151 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestFile(t *testing.T, path, content string) {
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+}
+
+func TestDiffTree(t *testing.T) {
+	tmpDir := t.TempDir()
+	snapshotDir := filepath.Join(tmpDir, "snapshot")
+	liveDir := filepath.Join(tmpDir, "live")
+
+	writeTestFile(t, filepath.Join(snapshotDir, "same.json"), "same")
+	writeTestFile(t, filepath.Join(liveDir, "same.json"), "same")
+	writeTestFile(t, filepath.Join(snapshotDir, "contexts", "changed.json"), "old")
+	writeTestFile(t, filepath.Join(liveDir, "contexts", "changed.json"), "new")
+	writeTestFile(t, filepath.Join(snapshotDir, "removed.json"), "gone")
+	writeTestFile(t, filepath.Join(liveDir, "nested", "added.json"), "added")
+
+	t.Run("reports added, removed and modified files", func(t *testing.T) {
+		changes, err := diffTree(snapshotDir, liveDir, "")
+		require.NoError(t, err)
+
+		assert.Equal(t, []Change{
+			{Type: ChangeTypeModified, Path: "contexts/changed.json"},
+			{Type: ChangeTypeAdded, Path: "nested/added.json"},
+			{Type: ChangeTypeRemoved, Path: "removed.json"},
+		}, changes)
+	})
+
+	t.Run("prefixes paths", func(t *testing.T) {
+		changes, err := diffTree(snapshotDir, liveDir, ".docker")
+		require.NoError(t, err)
+		require.NotEmpty(t, changes)
+		assert.Equal(t, ".docker/contexts/changed.json", changes[0].Path)
+	})
+
+	t.Run("treats missing directory as empty", func(t *testing.T) {
+		changes, err := diffTree(snapshotDir, filepath.Join(tmpDir, "missing"), "")
+		require.NoError(t, err)
+		assert.Len(t, changes, 3)
+		for _, change := range changes {
+			assert.Equal(t, ChangeTypeRemoved, change.Type)
+		}
+	})
+}
+
+func TestDiffPath(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	t.Run("compares single files", func(t *testing.T) {
+		snapshotFile := filepath.Join(tmpDir, "snap", ".npmrc")
+		liveFile := filepath.Join(tmpDir, "live", ".npmrc")
+		writeTestFile(t, snapshotFile, "registry=a")
+		writeTestFile(t, liveFile, "registry=a")
+
+		changes, err := diffPath(snapshotFile, liveFile, ".npmrc")
+		require.NoError(t, err)
+		assert.Empty(t, changes)
+
+		writeTestFile(t, liveFile, "registry=b")
+		changes, err = diffPath(snapshotFile, liveFile, ".npmrc")
+		require.NoError(t, err)
+		assert.Equal(t, []Change{{Type: ChangeTypeModified, Path: ".npmrc"}}, changes)
+	})
+
+	t.Run("reports missing side", func(t *testing.T) {
+		existing := filepath.Join(tmpDir, "exists")
+		writeTestFile(t, existing, "x")
+		missing := filepath.Join(tmpDir, "does-not-exist")
+
+		changes, err := diffPath(existing, missing, "cfg")
+		require.NoError(t, err)
+		assert.Equal(t, []Change{{Type: ChangeTypeRemoved, Path: "cfg"}}, changes)
+
+		changes, err = diffPath(missing, existing, "cfg")
+		require.NoError(t, err)
+		assert.Equal(t, []Change{{Type: ChangeTypeAdded, Path: "cfg"}}, changes)
+	})
+}
+
+func TestGenericTool_DiffDirectory(t *testing.T) {
+	tmpDir := t.TempDir()
+	configDir := filepath.Join(tmpDir, ".vim")
+	writeTestFile(t, filepath.Join(configDir, "vimrc"), "set number")
+
+	tool := NewGenericTool("vim", configDir)
+	snapshotPath := filepath.Join(tmpDir, "snapshot")
+	require.NoError(t, tool.Snapshot(snapshotPath))
+
+	changes, err := tool.Diff(snapshotPath)
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+
+	writeTestFile(t, filepath.Join(configDir, "vimrc"), "set nonumber")
+	writeTestFile(t, filepath.Join(configDir, "plugin", "new.vim"), "")
+
+	changes, err = tool.Diff(snapshotPath)
+	require.NoError(t, err)
+	assert.Equal(t, []Change{
+		{Type: ChangeTypeAdded, Path: ".vim/plugin/new.vim"},
+		{Type: ChangeTypeModified, Path: ".vim/vimrc"},
+	}, changes)
+}
+
+func TestMultiPathTool_Diff(t *testing.T) {
+	tmpDir := t.TempDir()
+	rcFile := filepath.Join(tmpDir, ".npmrc")
+	cacheDir := filepath.Join(tmpDir, ".npm")
+	writeTestFile(t, rcFile, "registry=a")
+	writeTestFile(t, filepath.Join(cacheDir, "config.json"), "{}")
+
+	tool := NewMultiPathTool("npm", []string{rcFile, cacheDir})
+	snapshotPath := filepath.Join(tmpDir, "snapshot")
+	require.NoError(t, tool.Snapshot(snapshotPath))
+
+	require.NoError(t, os.Remove(rcFile))
+	writeTestFile(t, filepath.Join(cacheDir, "config.json"), `{"a":1}`)
+
+	changes, err := tool.Diff(snapshotPath)
+	require.NoError(t, err)
+	assert.Equal(t, []Change{
+		{Type: ChangeTypeRemoved, Path: ".npmrc"},
+		{Type: ChangeTypeModified, Path: ".npm/config.json"},
+	}, changes)
+}
+
+func TestDockerTool_DiffFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	dockerDir := filepath.Join(tmpDir, ".docker")
+	writeTestFile(t, filepath.Join(dockerDir, "config.json"), "{}")
+
+	tool := &DockerTool{DockerConfigDir: dockerDir}
+	snapshotPath := filepath.Join(tmpDir, "snapshot")
+	require.NoError(t, tool.Snapshot(snapshotPath))
+
+	writeTestFile(t, filepath.Join(dockerDir, "config.json"), `{"auths":{}}`)
+
+	changes, err := tool.Diff(snapshotPath)
+	require.NoError(t, err)
+	assert.Contains(t, changes, Change{Type: ChangeTypeModified, Path: "config.json"})
+}
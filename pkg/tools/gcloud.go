@@ -1,32 +1,57 @@
 package tools
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 
+	"github.com/hugofrely/envswitch/internal/platform"
 	"github.com/hugofrely/envswitch/internal/storage"
 )
 
 // GCloudTool implements the Tool interface for Google Cloud CLI
 type GCloudTool struct {
-	ConfigPath string // ~/.config/gcloud
+	ConfigPath      string           // ~/.config/gcloud, %APPDATA%\gcloud on Windows
+	ExcludePatterns []string         // paths left out of snapshots, see storage.IsExcluded
+	Progress        storage.Progress // told about each file copied, may be nil
 }
 
 // NewGCloudTool creates a new GCloud tool instance
 func NewGCloudTool() *GCloudTool {
-	home, _ := os.UserHomeDir()
+	configDir, _ := platform.ConfigDir()
 	return &GCloudTool{
-		ConfigPath: filepath.Join(home, ".config", "gcloud"),
+		ConfigPath:      filepath.Join(configDir, "gcloud"),
+		ExcludePatterns: gcloudDefaultExcludes(),
 	}
 }
 
+// gcloudDefaultExcludes returns the logs and caches gcloud rebuilds on its
+// own, which can take hundreds of MB
+func gcloudDefaultExcludes() []string {
+	return []string{"logs/**", "cache/**", "surface_data/**", ".last_update_check.json"}
+}
+
 func (g *GCloudTool) Name() string {
 	return "gcloud"
 }
 
+func (g *GCloudTool) ConfigPaths() []string {
+	return []string{g.ConfigPath}
+}
+
+// AddExcludePatterns adds patterns to the paths left out of snapshots
+func (g *GCloudTool) AddExcludePatterns(patterns ...string) {
+	g.ExcludePatterns = append(g.ExcludePatterns, patterns...)
+}
+
+// SetProgress sets where the progress of the files copied is reported
+func (g *GCloudTool) SetProgress(progress storage.Progress) {
+	g.Progress = progress
+}
+
 func (g *GCloudTool) IsInstalled() bool {
 	_, err := exec.LookPath("gcloud")
 	return err == nil
@@ -47,15 +72,30 @@ func (g *GCloudTool) Snapshot(snapshotPath string) error {
 		return fmt.Errorf("failed to create snapshot directory: %w", err)
 	}
 
-	// Copy the entire gcloud config directory to snapshot
-	if err := storage.CopyDir(g.ConfigPath, snapshotPath); err != nil {
+	// Copy the config directory to the snapshot, only rewriting changed files
+	if _, err := storage.SnapshotDirProgress(g.ConfigPath, snapshotPath, g.Progress, g.ExcludePatterns...); err != nil {
 		return fmt.Errorf("failed to copy gcloud config: %w", err)
 	}
 
 	return nil
 }
 
+// SnapshotContext snapshots the config directory, it only copies files and
+// is not interrupted once started
+func (g *GCloudTool) SnapshotContext(ctx context.Context, snapshotPath string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return g.Snapshot(snapshotPath)
+}
+
 func (g *GCloudTool) Restore(snapshotPath string) error {
+	return g.RestoreContext(context.Background(), snapshotPath)
+}
+
+// RestoreContext restores the config directory then activates the
+// configuration of the snapshot, killing gcloud when ctx is done
+func (g *GCloudTool) RestoreContext(ctx context.Context, snapshotPath string) error {
 	if !g.IsInstalled() {
 		return fmt.Errorf("gcloud is not installed")
 	}
@@ -71,18 +111,54 @@ func (g *GCloudTool) Restore(snapshotPath string) error {
 		return fmt.Errorf("failed to create config parent directory: %w", err)
 	}
 
-	// Remove existing config directory if it exists
-	if _, err := os.Stat(g.ConfigPath); err == nil {
-		if err := os.RemoveAll(g.ConfigPath); err != nil {
-			return fmt.Errorf("failed to remove existing config: %w", err)
-		}
+	// Restore from snapshot, only rewriting files that differ and removing
+	// files the snapshot does not have
+	if _, err := storage.SyncDirProgress(snapshotPath, g.ConfigPath, g.Progress, g.ExcludePatterns...); err != nil {
+		return fmt.Errorf("failed to restore gcloud config: %w", err)
 	}
 
-	// Restore from snapshot
-	if err := storage.CopyDir(snapshotPath, g.ConfigPath); err != nil {
-		return fmt.Errorf("failed to restore gcloud config: %w", err)
+	return g.activate(ctx, snapshotPath)
+}
+
+// Merge merges the configurations of the snapshot into the live ones, key by
+// key, copies its other files over the live ones, then activates the
+// configuration of the snapshot. Configurations the snapshot does not have
+// are kept.
+func (g *GCloudTool) Merge(ctx context.Context, snapshotPath string) error {
+	if !g.IsInstalled() {
+		return fmt.Errorf("gcloud is not installed")
+	}
+	if err := g.ValidateSnapshot(snapshotPath); err != nil {
+		return fmt.Errorf("invalid snapshot: %w", err)
+	}
+
+	if err := os.MkdirAll(g.ConfigPath, 0700); err != nil {
+		return fmt.Errorf("failed to create gcloud config directory: %w", err)
+	}
+	err := mergeDir(ctx, snapshotPath, g.ConfigPath, g.ExcludePatterns, func(rel string) fileMerger {
+		if strings.HasPrefix(rel, "configurations/config_") {
+			return mergeINIFile
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to merge gcloud config: %w", err)
 	}
 
+	return g.activate(ctx, snapshotPath)
+}
+
+// activate activates the configuration of the snapshot explicitly, gcloud
+// may keep using another one despite active_config
+func (g *GCloudTool) activate(ctx context.Context, snapshotPath string) error {
+	name := activeConfigName(snapshotPath)
+	// #nosec G204 - The configuration name comes from the snapshot
+	if output, err := exec.CommandContext(ctx, "gcloud", "config", "configurations", "activate", name).CombinedOutput(); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return fmt.Errorf("failed to activate gcloud configuration '%s': %w", name, ctxErr)
+		}
+		return fmt.Errorf("failed to activate gcloud configuration '%s': %s", name, strings.TrimSpace(string(output)))
+	}
 	return nil
 }
 
@@ -164,68 +240,89 @@ func (g *GCloudTool) Diff(snapshotPath string) ([]Change, error) {
 	// Compare config_name
 	changes = append(changes, compareMetadataField("config_name", snapshotMeta, currentMeta)...)
 
+	// Compare config files
+	fileChanges, err := diffTree(snapshotPath, g.ConfigPath, "", g.ExcludePatterns...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff gcloud config: %w", err)
+	}
+	changes = append(changes, fileChanges...)
+
 	return changes, nil
 }
 
-// getSnapshotMetadata reads metadata from a snapshot by parsing config files
+// getSnapshotMetadata reads metadata from a snapshot by parsing the file of
+// its active configuration
 func (g *GCloudTool) getSnapshotMetadata(snapshotPath string) (map[string]interface{}, error) {
 	metadata := make(map[string]interface{})
 
-	// Try to read active configuration
-	configsPath := filepath.Join(snapshotPath, "configurations")
-	if entries, err := os.ReadDir(configsPath); err == nil {
-		for _, entry := range entries {
-			if !entry.IsDir() && strings.HasPrefix(entry.Name(), "config_") {
-				// Read config file to extract metadata
-				configFile := filepath.Join(configsPath, entry.Name())
-				if data, err := os.ReadFile(configFile); err == nil {
-					content := string(data)
-					lines := strings.Split(content, "\n")
-
-					inCoreSection := false
-					inComputeSection := false
-
-					for _, line := range lines {
-						line = strings.TrimSpace(line)
-
-						if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
-							sectionName := strings.Trim(line, "[]")
-							inCoreSection = sectionName == "core"
-							inComputeSection = sectionName == "compute"
-							continue
-						}
-
-						if strings.Contains(line, "=") {
-							parts := strings.SplitN(line, "=", 2)
-							if len(parts) == 2 {
-								key := strings.TrimSpace(parts[0])
-								value := strings.TrimSpace(parts[1])
-
-								if inCoreSection {
-									if key == "account" {
-										metadata["account"] = value
-									} else if key == "project" {
-										metadata["project"] = value
-									}
-								} else if inComputeSection && key == "region" {
-									metadata["region"] = value
-								}
-							}
-						}
-					}
-
-					// Extract config name from filename (config_default -> default)
-					configName := strings.TrimPrefix(entry.Name(), "config_")
-					metadata["config_name"] = configName
-					break // Only read the first config (active one should be there)
-				}
+	name := activeConfigName(snapshotPath)
+	data, err := os.ReadFile(filepath.Join(snapshotPath, "configurations", "config_"+name))
+	if err != nil {
+		// Snapshots without the file of their active configuration have no metadata
+		return metadata, nil
+	}
+
+	inCoreSection := false
+	inComputeSection := false
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			sectionName := strings.Trim(line, "[]")
+			inCoreSection = sectionName == "core"
+			inComputeSection = sectionName == "compute"
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+
+		if inCoreSection {
+			if key == "account" {
+				metadata["account"] = value
+			} else if key == "project" {
+				metadata["project"] = value
 			}
+		} else if inComputeSection && key == "region" {
+			metadata["region"] = value
 		}
 	}
 
+	metadata["config_name"] = name
 	return metadata, nil
 }
 
+// activeConfigName returns the configuration named by the active_config file
+// of a gcloud config directory, default when there is none
+func activeConfigName(configDir string) string {
+	data, err := os.ReadFile(filepath.Join(configDir, "active_config"))
+	if name := strings.TrimSpace(string(data)); err == nil && name != "" {
+		return name
+	}
+	return "default"
+}
+
+// Verify checks that gcloud uses the account of the snapshot's active
+// configuration, as restoring the files does not always take effect
+func (g *GCloudTool) Verify(snapshotPath string) error {
+	snapshotMeta, err := g.getSnapshotMetadata(snapshotPath)
+	if err != nil {
+		return err
+	}
+	expected, _ := snapshotMeta["account"].(string)
+	if expected == "" {
+		return nil
+	}
+
+	if actual := g.execCommand("config", "get-value", "account"); actual != expected {
+		return fmt.Errorf("active account is '%s', expected '%s' from configuration '%s'", actual, expected, snapshotMeta["config_name"])
+	}
+	return nil
+}
+
 // execCommand executes a gcloud command and returns the output
 func (g *GCloudTool) execCommand(args ...string) string {
 	cmd := exec.Command("gcloud", args...)
@@ -1,9 +1,15 @@
 package tools
 
 import (
+	"os"
+	"path/filepath"
+	"runtime"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hugofrely/envswitch/internal/storage"
 )
 
 func TestGcloudTool(t *testing.T) {
@@ -19,3 +25,79 @@ func TestGcloudTool(t *testing.T) {
 		_ = gcloud.IsInstalled()
 	})
 }
+
+func TestGcloudToolExcludesLogsAndCaches(t *testing.T) {
+	gcloud := NewGCloudTool()
+
+	for _, rel := range []string{"logs/2024.01.01/10.00.00.log", "cache/completion", "surface_data/run.json", ".last_update_check.json"} {
+		assert.True(t, storage.IsExcluded(rel, gcloud.ExcludePatterns), rel)
+	}
+	for _, rel := range []string{"credentials.db", "configurations/config_default", "active_config"} {
+		assert.False(t, storage.IsExcluded(rel, gcloud.ExcludePatterns), rel)
+	}
+}
+
+// fakeGCloud puts a gcloud script on PATH that logs its arguments and
+// reports account as the active account
+func fakeGCloud(t *testing.T, account string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake gcloud is a shell script")
+	}
+
+	binDir := t.TempDir()
+	logPath := filepath.Join(binDir, "calls.log")
+	script := "#!/bin/sh\n" +
+		"echo \"$@\" >> " + logPath + "\n" +
+		"if [ \"$1 $2 $3\" = \"config get-value account\" ]; then echo " + account + "; fi\n"
+	require.NoError(t, os.WriteFile(filepath.Join(binDir, "gcloud"), []byte(script), 0755))
+	t.Setenv("PATH", binDir)
+	return logPath
+}
+
+func writeGCloudSnapshot(t *testing.T, active string) string {
+	t.Helper()
+	snapshotPath := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(snapshotPath, "configurations"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(snapshotPath, "active_config"), []byte(active+"\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(snapshotPath, "configurations", "config_default"),
+		[]byte("[core]\naccount = personal@example.com\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(snapshotPath, "configurations", "config_"+active),
+		[]byte("[core]\naccount = work@example.com\nproject = work-project\n\n[compute]\nregion = europe-west1\n"), 0644))
+	return snapshotPath
+}
+
+func TestGcloudToolRestoreActivatesConfiguration(t *testing.T) {
+	logPath := fakeGCloud(t, "work@example.com")
+	snapshotPath := writeGCloudSnapshot(t, "work")
+	gcloud := &GCloudTool{ConfigPath: filepath.Join(t.TempDir(), "gcloud")}
+
+	require.NoError(t, gcloud.Restore(snapshotPath))
+
+	calls, err := os.ReadFile(logPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(calls), "config configurations activate work")
+
+	metadata, err := gcloud.getSnapshotMetadata(snapshotPath)
+	require.NoError(t, err)
+	assert.Equal(t, "work", metadata["config_name"])
+	assert.Equal(t, "work@example.com", metadata["account"])
+	assert.Equal(t, "europe-west1", metadata["region"])
+}
+
+func TestGcloudToolVerify(t *testing.T) {
+	snapshotPath := writeGCloudSnapshot(t, "work")
+	gcloud := &GCloudTool{}
+
+	t.Run("matching account", func(t *testing.T) {
+		fakeGCloud(t, "work@example.com")
+		assert.NoError(t, gcloud.Verify(snapshotPath))
+	})
+
+	t.Run("other account", func(t *testing.T) {
+		fakeGCloud(t, "personal@example.com")
+		err := gcloud.Verify(snapshotPath)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "personal@example.com")
+	})
+}
@@ -1,12 +1,13 @@
 package tools
 
 import (
-	"bytes"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+
+	"github.com/hugofrely/envswitch/internal/storage"
 )
 
 // GenericTool est un tool générique qui copie des fichiers de configuration
@@ -28,6 +29,10 @@ func (g *GenericTool) Name() string {
 	return g.toolName
 }
 
+func (g *GenericTool) ConfigPaths() []string {
+	return []string{g.configPath}
+}
+
 func (g *GenericTool) IsInstalled() bool {
 	_, err := exec.LookPath(g.toolName)
 	return err == nil
@@ -51,13 +56,25 @@ func (g *GenericTool) Snapshot(snapshotPath string) error {
 		return err
 	}
 
+	baseName := filepath.Base(g.configPath)
+	destPath := filepath.Join(snapshotPath, baseName)
 	if info.IsDir() {
 		// Copier le dossier entier
-		return copyDir(g.configPath, filepath.Join(snapshotPath, filepath.Base(g.configPath)))
+		err = copyDir(g.configPath, destPath)
+	} else {
+		// Copier le fichier
+		err = copyFile(g.configPath, destPath)
+	}
+	if err != nil {
+		return err
 	}
 
-	// Copier le fichier
-	return copyFile(g.configPath, filepath.Join(snapshotPath, filepath.Base(g.configPath)))
+	// Enregistrer les hashes des fichiers copiés pour Diff
+	manifest := &storage.Manifest{Files: make(map[string]storage.ManifestEntry)}
+	if err := recordSnapshot(manifest, g.configPath, destPath, baseName); err != nil {
+		return fmt.Errorf("failed to record snapshot manifest: %w", err)
+	}
+	return manifest.Save(snapshotPath)
 }
 
 func (g *GenericTool) Restore(snapshotPath string) error {
@@ -77,14 +94,13 @@ func (g *GenericTool) Restore(snapshotPath string) error {
 		return err
 	}
 
-	if info.IsDir() {
-		// Supprimer le dossier et le remplacer
-		os.RemoveAll(g.configPath)
-		return copyDir(sourcePath, g.configPath)
-	}
-
-	// Copier le fichier
-	return copyFile(sourcePath, g.configPath)
+	// Remplacer la config, l'ancienne est remise en place si la copie échoue
+	return storage.ReplaceSafely(g.configPath, func() error {
+		if info.IsDir() {
+			return copyDir(sourcePath, g.configPath)
+		}
+		return copyFile(sourcePath, g.configPath)
+	})
 }
 
 func (g *GenericTool) GetMetadata() (map[string]interface{}, error) {
@@ -104,6 +120,11 @@ func (g *GenericTool) GetMetadata() (map[string]interface{}, error) {
 		metadata["config_exists"] = false
 	}
 
+	// Nombre de fichiers, taille totale, dernière modification et hash du contenu
+	if err := addManifestMetadata(metadata, g.ConfigPaths()); err != nil {
+		return nil, err
+	}
+
 	return metadata, nil
 }
 
@@ -117,35 +138,12 @@ func (g *GenericTool) ValidateSnapshot(snapshotPath string) error {
 }
 
 func (g *GenericTool) Diff(snapshotPath string) ([]Change, error) {
-	var changes []Change
-
 	baseName := filepath.Base(g.configPath)
 	snapshotFile := filepath.Join(snapshotPath, baseName)
 
-	currentExists := fileExists(g.configPath)
-	snapshotExists := fileExists(snapshotFile)
-
-	if snapshotExists && !currentExists {
-		changes = append(changes, Change{
-			Type: ChangeTypeRemoved,
-			Path: baseName,
-		})
-	} else if !snapshotExists && currentExists {
-		changes = append(changes, Change{
-			Type: ChangeTypeAdded,
-			Path: baseName,
-		})
-	} else if snapshotExists && currentExists {
-		// Comparer les contenus
-		if !filesEqual(g.configPath, snapshotFile) {
-			changes = append(changes, Change{
-				Type: ChangeTypeModified,
-				Path: baseName,
-			})
-		}
-	}
-
-	return changes, nil
+	// Comparer les contenus (fichier par fichier pour les dossiers), les
+	// fichiers inchangés depuis le snapshot reprennent le hash du manifest
+	return diffPathManifest(snapshotFile, g.configPath, baseName, storage.LoadManifest(snapshotPath))
 }
 
 // Fonctions helper
@@ -200,19 +198,3 @@ func copyDir(src, dst string) error {
 		return copyFile(path, targetPath)
 	})
 }
-
-func fileExists(path string) bool {
-	_, err := os.Stat(path)
-	return err == nil
-}
-
-func filesEqual(file1, file2 string) bool {
-	content1, err1 := os.ReadFile(file1)
-	content2, err2 := os.ReadFile(file2)
-
-	if err1 != nil || err2 != nil {
-		return false
-	}
-
-	return bytes.Equal(content1, content2)
-}
@@ -1,25 +1,39 @@
 package tools
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 
+	"github.com/hugofrely/envswitch/internal/platform"
 	"github.com/hugofrely/envswitch/internal/storage"
 )
 
 // GitTool implements the Tool interface for Git
 type GitTool struct {
-	GitConfigPath string // ~/.gitconfig
+	GitConfigPath string   // ~/.gitconfig
+	GnuPGHome     string   // ~/.gnupg, or $GNUPGHOME
+	Includes      []string // files added to include.path of the restored config
+	CheckSigning  bool     // check the signing key is usable after a restore
+
+	Mode        string        // GitModeFull (default) or GitModeIncludeIf
+	Identities  []GitIdentity // identities mapped to directories in includeif mode
+	IdentityDir string        // where the identity files of includeif mode are written
 }
 
 // NewGitTool creates a new Git tool instance
 func NewGitTool() *GitTool {
-	home, _ := os.UserHomeDir()
+	home, _ := platform.HomeDir()
+	gnupgHome := os.Getenv("GNUPGHOME")
+	if gnupgHome == "" {
+		gnupgHome = filepath.Join(home, ".gnupg")
+	}
 	return &GitTool{
 		GitConfigPath: filepath.Join(home, ".gitconfig"),
+		GnuPGHome:     gnupgHome,
 	}
 }
 
@@ -27,6 +41,10 @@ func (g *GitTool) Name() string {
 	return "git"
 }
 
+func (g *GitTool) ConfigPaths() []string {
+	return []string{g.GitConfigPath}
+}
+
 func (g *GitTool) IsInstalled() bool {
 	_, err := exec.LookPath("git")
 	return err == nil
@@ -42,13 +60,19 @@ func (g *GitTool) Snapshot(snapshotPath string) error {
 		return fmt.Errorf("git config file does not exist: %s", g.GitConfigPath)
 	}
 
+	// In includeif mode ~/.gitconfig is shared by all environments, it only
+	// becomes the identity of environments without one
+	destPath := filepath.Join(snapshotPath, "gitconfig")
+	if _, err := os.Stat(destPath); err == nil && g.Mode == GitModeIncludeIf {
+		return nil
+	}
+
 	// Create snapshot directory
 	if err := os.MkdirAll(snapshotPath, 0755); err != nil {
 		return fmt.Errorf("failed to create snapshot directory: %w", err)
 	}
 
 	// Copy .gitconfig to snapshot
-	destPath := filepath.Join(snapshotPath, "gitconfig")
 	if err := storage.CopyFile(g.GitConfigPath, destPath); err != nil {
 		return fmt.Errorf("failed to copy git config: %w", err)
 	}
@@ -66,7 +90,22 @@ func (g *GitTool) Snapshot(snapshotPath string) error {
 	return nil
 }
 
+// SnapshotContext snapshots the git config, it only copies files and is not
+// interrupted once started
+func (g *GitTool) SnapshotContext(ctx context.Context, snapshotPath string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return g.Snapshot(snapshotPath)
+}
+
 func (g *GitTool) Restore(snapshotPath string) error {
+	return g.RestoreContext(context.Background(), snapshotPath)
+}
+
+// RestoreContext restores the git config, killing the git and gpg commands it
+// runs when ctx is done
+func (g *GitTool) RestoreContext(ctx context.Context, snapshotPath string) error {
 	if !g.IsInstalled() {
 		return fmt.Errorf("git is not installed")
 	}
@@ -76,9 +115,15 @@ func (g *GitTool) Restore(snapshotPath string) error {
 		return fmt.Errorf("invalid snapshot: %w", err)
 	}
 
+	if g.Mode == GitModeIncludeIf {
+		return g.updateIncludeIfs(ctx)
+	}
+
 	// Restore .gitconfig
 	srcPath := filepath.Join(snapshotPath, "gitconfig")
-	if err := storage.CopyFile(srcPath, g.GitConfigPath); err != nil {
+	if err := storage.ReplaceSafely(g.GitConfigPath, func() error {
+		return storage.CopyFile(srcPath, g.GitConfigPath)
+	}); err != nil {
 		return fmt.Errorf("failed to restore git config: %w", err)
 	}
 
@@ -91,9 +136,171 @@ func (g *GitTool) Restore(snapshotPath string) error {
 		}
 	}
 
+	if err := g.addIncludes(ctx); err != nil {
+		return err
+	}
+
+	if g.CheckSigning {
+		if err := g.validateSigning(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: commit signing will fail: %v\n", err)
+		}
+	}
+
+	return nil
+}
+
+// addIncludes adds the Includes missing from include.path to the git config,
+// like 'git config --global --add include.path'
+func (g *GitTool) addIncludes(ctx context.Context) error {
+	if len(g.Includes) == 0 {
+		return nil
+	}
+
+	existing := make(map[string]bool)
+	output, _ := exec.CommandContext(ctx, "git", "config", "--file", g.GitConfigPath, "--get-all", "include.path").Output()
+	for _, path := range strings.Split(string(output), "\n") {
+		existing[strings.TrimSpace(path)] = true
+	}
+
+	for _, include := range g.Includes {
+		if existing[include] {
+			continue
+		}
+		if _, err := os.Stat(expandHome(include)); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: git include %s not found, git will ignore it\n", include)
+		}
+		cmd := exec.CommandContext(ctx, "git", "config", "--file", g.GitConfigPath, "--add", "include.path", include)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return fmt.Errorf("failed to add git include %s: %w", include, ctxErr)
+			}
+			return fmt.Errorf("failed to add git include %s: %s", include, strings.TrimSpace(string(output)))
+		}
+		existing[include] = true
+	}
+	return nil
+}
+
+// ValidateSigning checks that the signing setup of the git config can sign:
+// the GnuPG home and program exist and the signing key is in the keyring, or
+// the SSH signing key exists. It returns nil when signing is not configured.
+func (g *GitTool) ValidateSigning() error {
+	return g.validateSigning(context.Background())
+}
+
+// validateSigning is ValidateSigning, killing the commands it runs when ctx
+// is done
+func (g *GitTool) validateSigning(ctx context.Context) error {
+	signingKey := g.configValue(ctx, "user.signingkey")
+	if signingKey == "" && !g.configBool(ctx, "commit.gpgsign") && !g.configBool(ctx, "tag.gpgsign") {
+		return nil
+	}
+
+	switch format := g.configValue(ctx, "gpg.format"); format {
+	case "ssh":
+		if signingKey == "" {
+			return fmt.Errorf("gpg.format is ssh but user.signingkey is not set")
+		}
+		// The key itself, or the path to it
+		if strings.HasPrefix(signingKey, "key::") || strings.HasPrefix(signingKey, "ssh-") {
+			return nil
+		}
+		if _, err := os.Stat(expandHome(signingKey)); err != nil {
+			return fmt.Errorf("SSH signing key %s not found", signingKey)
+		}
+		return nil
+	case "x509":
+		// gpgsm keys are not checked
+		return nil
+	case "", "openpgp":
+	default:
+		return fmt.Errorf("unknown gpg.format '%s'", format)
+	}
+
+	if _, err := os.Stat(g.GnuPGHome); err != nil {
+		return fmt.Errorf("GnuPG home %s not found", g.GnuPGHome)
+	}
+
+	program := g.configValue(ctx, "gpg.program")
+	if program == "" {
+		program = "gpg"
+	}
+	if _, err := exec.LookPath(program); err != nil {
+		return fmt.Errorf("%s is not installed", program)
+	}
+
+	// Without user.signingkey, git signs with the key of the committer email
+	key := signingKey
+	if key == "" {
+		key = g.configValue(ctx, "user.email")
+	}
+	if key == "" {
+		return fmt.Errorf("neither user.signingkey nor user.email is set")
+	}
+
+	cmd := exec.CommandContext(ctx, program, "--batch", "--list-secret-keys", key)
+	cmd.Env = append(os.Environ(), "GNUPGHOME="+g.GnuPGHome)
+	if err := cmd.Run(); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return fmt.Errorf("could not look up signing key %s: %w", key, ctxErr)
+		}
+		return fmt.Errorf("signing key %s not found in the keyring of %s", key, g.GnuPGHome)
+	}
 	return nil
 }
 
+// Verify checks that git uses the identity of the snapshot, and that it can
+// sign with it
+func (g *GitTool) Verify(snapshotPath string) error {
+	if g.Mode == GitModeIncludeIf {
+		return nil
+	}
+
+	snapshotMeta, err := g.getSnapshotMetadata(snapshotPath)
+	if err != nil {
+		return err
+	}
+
+	fields := map[string]string{
+		"user_name":   "user.name",
+		"user_email":  "user.email",
+		"signing_key": "user.signingkey",
+	}
+	for _, field := range []string{"user_name", "user_email", "signing_key"} {
+		expected, _ := snapshotMeta[field].(string)
+		if expected == "" {
+			continue
+		}
+		if actual := g.configValue(context.Background(), fields[field]); actual != expected {
+			return fmt.Errorf("%s is '%s', expected '%s'", fields[field], actual, expected)
+		}
+	}
+
+	if !g.CheckSigning {
+		return nil
+	}
+	return g.ValidateSigning()
+}
+
+// configValue returns a value of the git config, following its includes
+func (g *GitTool) configValue(ctx context.Context, key string) string {
+	return g.execCommand(ctx, "git", "config", "--file", g.GitConfigPath, "--includes", "--get", key)
+}
+
+// configBool returns a boolean of the git config, false when it is not set
+func (g *GitTool) configBool(ctx context.Context, key string) bool {
+	return g.execCommand(ctx, "git", "config", "--file", g.GitConfigPath, "--includes", "--type=bool", "--get", key) == "true"
+}
+
+// expandHome expands a leading ~ the way git does for paths of its config
+func expandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		home, _ := platform.HomeDir()
+		return filepath.Join(home, strings.TrimPrefix(path, "~"))
+	}
+	return path
+}
+
 func (g *GitTool) GetMetadata() (map[string]interface{}, error) {
 	if !g.IsInstalled() {
 		return nil, fmt.Errorf("git is not installed")
@@ -102,17 +309,17 @@ func (g *GitTool) GetMetadata() (map[string]interface{}, error) {
 	metadata := make(map[string]interface{})
 
 	// Get user name
-	if name := g.execCommand("git", "config", "--global", "user.name"); name != "" {
+	if name := g.execCommand(context.Background(), "git", "config", "--global", "user.name"); name != "" {
 		metadata["user_name"] = name
 	}
 
 	// Get user email
-	if email := g.execCommand("git", "config", "--global", "user.email"); email != "" {
+	if email := g.execCommand(context.Background(), "git", "config", "--global", "user.email"); email != "" {
 		metadata["user_email"] = email
 	}
 
 	// Get signing key if configured
-	if signingKey := g.execCommand("git", "config", "--global", "user.signingkey"); signingKey != "" {
+	if signingKey := g.execCommand(context.Background(), "git", "config", "--global", "user.signingkey"); signingKey != "" {
 		metadata["signing_key"] = signingKey
 	}
 
@@ -135,6 +342,11 @@ func (g *GitTool) ValidateSnapshot(snapshotPath string) error {
 }
 
 func (g *GitTool) Diff(snapshotPath string) ([]Change, error) {
+	// The live identity depends on the directory in includeif mode
+	if g.Mode == GitModeIncludeIf {
+		return []Change{}, nil
+	}
+
 	// Get current metadata
 	currentMeta, err := g.GetMetadata()
 	if err != nil {
@@ -203,8 +415,8 @@ func (g *GitTool) getSnapshotMetadata(snapshotPath string) (map[string]interface
 }
 
 // execCommand executes a command and returns the output
-func (g *GitTool) execCommand(name string, args ...string) string {
-	cmd := exec.Command(name, args...)
+func (g *GitTool) execCommand(ctx context.Context, name string, args ...string) string {
+	cmd := exec.CommandContext(ctx, name, args...)
 	output, err := cmd.Output()
 	if err != nil {
 		return ""
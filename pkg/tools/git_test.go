@@ -1,9 +1,15 @@
 package tools
 
 import (
+	"context"
+	"errors"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestGitTool_Name(t *testing.T) {
@@ -377,3 +383,154 @@ func TestGitTool_Diff(t *testing.T) {
 		}
 	})
 }
+
+// writeGitConfig writes a git config in a temporary directory and returns a
+// tool using it
+func writeGitConfig(t *testing.T, content string) *GitTool {
+	t.Helper()
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "gitconfig")
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write gitconfig: %v", err)
+	}
+	return &GitTool{
+		GitConfigPath: configPath,
+		GnuPGHome:     filepath.Join(tmpDir, "gnupg"),
+	}
+}
+
+func TestGitTool_RestoreIncludes(t *testing.T) {
+	tool := writeGitConfig(t, "")
+	if !tool.IsInstalled() {
+		t.Skip("git is not installed")
+	}
+
+	snapshotPath := filepath.Join(t.TempDir(), "snapshot")
+	if err := os.MkdirAll(snapshotPath, 0755); err != nil {
+		t.Fatalf("Failed to create snapshot: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(snapshotPath, "gitconfig"), []byte("[user]\n\tname = Work\n"), 0644); err != nil {
+		t.Fatalf("Failed to write snapshot: %v", err)
+	}
+
+	include := filepath.Join(t.TempDir(), "work.gitconfig")
+	if err := os.WriteFile(include, []byte("[user]\n\temail = work@example.com\n"), 0644); err != nil {
+		t.Fatalf("Failed to write include: %v", err)
+	}
+	tool.Includes = []string{include}
+
+	// Restoring twice adds the include once
+	for i := 0; i < 2; i++ {
+		if err := tool.Restore(snapshotPath); err != nil {
+			t.Fatalf("Restore failed: %v", err)
+		}
+	}
+
+	output, err := exec.Command("git", "config", "--file", tool.GitConfigPath, "--get-all", "include.path").Output()
+	if err != nil {
+		t.Fatalf("Failed to read include.path: %v", err)
+	}
+	if got := strings.TrimSpace(string(output)); got != include {
+		t.Errorf("Expected include.path %s, got: %q", include, got)
+	}
+	if email := tool.configValue(context.Background(), "user.email"); email != "work@example.com" {
+		t.Errorf("Expected the email of the include, got: %q", email)
+	}
+}
+
+func TestGitTool_ValidateSigning(t *testing.T) {
+	if !NewGitTool().IsInstalled() {
+		t.Skip("git is not installed")
+	}
+
+	t.Run("no signing configured", func(t *testing.T) {
+		tool := writeGitConfig(t, "[user]\n\temail = test@example.com\n")
+		if err := tool.ValidateSigning(); err != nil {
+			t.Errorf("Expected no error, got: %v", err)
+		}
+	})
+
+	t.Run("missing GnuPG home", func(t *testing.T) {
+		tool := writeGitConfig(t, "[user]\n\tsigningkey = ABCDEF12\n[commit]\n\tgpgsign = yes\n")
+		err := tool.ValidateSigning()
+		if err == nil || !strings.Contains(err.Error(), "GnuPG home") {
+			t.Errorf("Expected a missing GnuPG home error, got: %v", err)
+		}
+	})
+
+	t.Run("key not in keyring", func(t *testing.T) {
+		if _, err := exec.LookPath("gpg"); err != nil {
+			t.Skip("gpg is not installed")
+		}
+		tool := writeGitConfig(t, "[user]\n\tsigningkey = ABCDEF12\n")
+		if err := os.MkdirAll(tool.GnuPGHome, 0700); err != nil {
+			t.Fatalf("Failed to create GnuPG home: %v", err)
+		}
+		err := tool.ValidateSigning()
+		if err == nil || !strings.Contains(err.Error(), "ABCDEF12") {
+			t.Errorf("Expected a missing key error, got: %v", err)
+		}
+	})
+
+	t.Run("gpg killed when ctx is done", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("the gpg program is a shell script")
+		}
+		program := filepath.Join(t.TempDir(), "gpg")
+		if err := os.WriteFile(program, []byte("#!/bin/sh\nexec sleep 5\n"), 0755); err != nil {
+			t.Fatalf("Failed to write gpg program: %v", err)
+		}
+		tool := writeGitConfig(t, "[user]\n\tsigningkey = ABCDEF12\n[gpg]\n\tprogram = "+program+"\n")
+		if err := os.MkdirAll(tool.GnuPGHome, 0700); err != nil {
+			t.Fatalf("Failed to create GnuPG home: %v", err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		err := tool.validateSigning(ctx)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected the error of ctx, got: %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > 3*time.Second {
+			t.Errorf("Expected gpg to be killed, it ran %s", elapsed)
+		}
+	})
+
+	t.Run("ssh signing key", func(t *testing.T) {
+		keyPath := filepath.Join(t.TempDir(), "id_ed25519.pub")
+		tool := writeGitConfig(t, "[gpg]\n\tformat = ssh\n[user]\n\tsigningkey = "+keyPath+"\n")
+		if err := tool.ValidateSigning(); err == nil {
+			t.Error("Expected an error for a missing SSH key")
+		}
+
+		if err := os.WriteFile(keyPath, []byte("ssh-ed25519 AAAA test"), 0644); err != nil {
+			t.Fatalf("Failed to write key: %v", err)
+		}
+		if err := tool.ValidateSigning(); err != nil {
+			t.Errorf("Expected no error, got: %v", err)
+		}
+	})
+}
+
+func TestGitTool_Verify(t *testing.T) {
+	tool := writeGitConfig(t, "[user]\n\tname = Personal\n")
+	if !tool.IsInstalled() {
+		t.Skip("git is not installed")
+	}
+
+	snapshotPath := t.TempDir()
+	if err := os.WriteFile(filepath.Join(snapshotPath, "gitconfig"), []byte("[user]\n\tname = Work\n"), 0644); err != nil {
+		t.Fatalf("Failed to write snapshot: %v", err)
+	}
+
+	if err := tool.Verify(snapshotPath); err == nil {
+		t.Error("Expected an error for a different user.name")
+	}
+	if err := os.WriteFile(tool.GitConfigPath, []byte("[user]\n\tname = Work\n"), 0644); err != nil {
+		t.Fatalf("Failed to write gitconfig: %v", err)
+	}
+	if err := tool.Verify(snapshotPath); err != nil {
+		t.Errorf("Expected no error, got: %v", err)
+	}
+}
@@ -0,0 +1,200 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Modes of the git tool
+const (
+	// GitModeFull swaps the whole ~/.gitconfig
+	GitModeFull = "full"
+	// GitModeIncludeIf leaves ~/.gitconfig in place and maps directories to
+	// the identity of their environment with includeIf "gitdir:" sections
+	GitModeIncludeIf = "includeif"
+)
+
+// Markers of the includeIf sections envswitch maintains in ~/.gitconfig
+const (
+	includeIfBegin = "# >>> envswitch includeIf (generated, do not edit) >>>"
+	includeIfEnd   = "# <<< envswitch includeIf <<<"
+)
+
+// identityKeys are the sections of a git snapshot copied to the identity
+// file of its environment
+const identityKeys = `^(user|gpg|commit|tag)\.`
+
+// GitIdentity is the identity of an environment, used by the repositories
+// below its directories in includeif mode
+type GitIdentity struct {
+	Environment  string
+	Directories  []string // gitdir patterns, e.g. ~/work/
+	SnapshotPath string   // the git snapshot of the environment
+	Includes     []string // files the identity includes
+
+	// Set in the environment metadata, they take precedence over the
+	// snapshot
+	UserName   string
+	UserEmail  string
+	SigningKey string
+}
+
+// updateIncludeIfs writes the identity file of each of Identities, and
+// replaces the includeIf sections of the git config with one per directory
+func (g *GitTool) updateIncludeIfs(ctx context.Context) error {
+	var block strings.Builder
+	identities := append([]GitIdentity(nil), g.Identities...)
+	sort.Slice(identities, func(i, j int) bool {
+		return identities[i].Environment < identities[j].Environment
+	})
+
+	for _, identity := range identities {
+		if len(identity.Directories) == 0 {
+			continue
+		}
+		identityPath, err := g.writeIdentity(ctx, identity)
+		if err != nil {
+			return err
+		}
+
+		if g.CheckSigning {
+			identityTool := &GitTool{GitConfigPath: identityPath, GnuPGHome: g.GnuPGHome}
+			if err := identityTool.validateSigning(ctx); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: commit signing with the identity of %s will fail: %v\n", identity.Environment, err)
+			}
+		}
+
+		for _, dir := range identity.Directories {
+			fmt.Fprintf(&block, "[includeIf \"gitdir:%s\"]\n\tpath = %s\n", gitDirPattern(dir), filepath.ToSlash(identityPath))
+		}
+	}
+
+	return writeIncludeIfBlock(g.GitConfigPath, block.String())
+}
+
+// writeIdentity writes the identity file of an environment, holding the
+// user, gpg, commit and tag settings of its snapshot, the identity set in its
+// metadata and its includes
+func (g *GitTool) writeIdentity(ctx context.Context, identity GitIdentity) (string, error) {
+	identityPath := filepath.Join(g.IdentityDir, filepath.FromSlash(identity.Environment)+".gitconfig")
+	if err := os.MkdirAll(filepath.Dir(identityPath), 0755); err != nil {
+		return "", fmt.Errorf("failed to create git identity directory: %w", err)
+	}
+	header := fmt.Sprintf("# Generated by envswitch from the git snapshot of %s, do not edit\n", identity.Environment)
+	if err := os.WriteFile(identityPath, []byte(header), 0644); err != nil {
+		return "", fmt.Errorf("failed to write git identity of %s: %w", identity.Environment, err)
+	}
+
+	snapshotConfig := filepath.Join(identity.SnapshotPath, "gitconfig")
+	output, _ := exec.CommandContext(ctx, "git", "config", "--file", snapshotConfig, "--get-regexp", identityKeys).Output()
+	if err := ctx.Err(); err != nil {
+		return "", fmt.Errorf("failed to read the git identity of %s: %w", identity.Environment, err)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		key, value, _ := strings.Cut(line, " ")
+		if key == "" {
+			continue
+		}
+		if err := addConfigValue(ctx, identityPath, key, value); err != nil {
+			return "", err
+		}
+	}
+	overrides := [][2]string{
+		{"user.name", identity.UserName},
+		{"user.email", identity.UserEmail},
+		{"user.signingkey", identity.SigningKey},
+	}
+	for _, override := range overrides {
+		if override[1] == "" {
+			continue
+		}
+		cmd := exec.CommandContext(ctx, "git", "config", "--file", identityPath, "--replace-all", override[0], override[1])
+		if output, err := cmd.CombinedOutput(); err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return "", fmt.Errorf("failed to set %s in %s: %w", override[0], identityPath, ctxErr)
+			}
+			return "", fmt.Errorf("failed to set %s in %s: %s", override[0], identityPath, strings.TrimSpace(string(output)))
+		}
+	}
+	for _, include := range identity.Includes {
+		if err := addConfigValue(ctx, identityPath, "include.path", include); err != nil {
+			return "", err
+		}
+	}
+
+	return identityPath, nil
+}
+
+// addConfigValue adds a value to a git config file
+func addConfigValue(ctx context.Context, configPath, key, value string) error {
+	cmd := exec.CommandContext(ctx, "git", "config", "--file", configPath, "--add", key, value)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return fmt.Errorf("failed to set %s in %s: %w", key, configPath, ctxErr)
+		}
+		return fmt.Errorf("failed to set %s in %s: %s", key, configPath, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// gitDirPattern returns the gitdir pattern matching the repositories below
+// dir: git only matches subdirectories of patterns ending with a slash
+func gitDirPattern(dir string) string {
+	dir = filepath.ToSlash(dir)
+	if !strings.HasSuffix(dir, "/") {
+		dir += "/"
+	}
+	return dir
+}
+
+// writeIncludeIfBlock replaces the includeIf sections envswitch maintains in
+// a git config with block, leaving the rest of the file as it is
+func writeIncludeIfBlock(configPath, block string) error {
+	data, err := os.ReadFile(configPath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read git config: %w", err)
+	}
+	perm := os.FileMode(0644)
+	if info, err := os.Stat(configPath); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	content := removeIncludeIfBlock(string(data))
+	if block != "" {
+		if content != "" && !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		content += includeIfBegin + "\n" + block + includeIfEnd + "\n"
+	}
+	if content == string(data) {
+		return nil
+	}
+
+	if err := os.WriteFile(configPath, []byte(content), perm); err != nil {
+		return fmt.Errorf("failed to write git config: %w", err)
+	}
+	return nil
+}
+
+// removeIncludeIfBlock returns a git config without the includeIf sections
+// envswitch maintains
+func removeIncludeIfBlock(content string) string {
+	begin := strings.Index(content, includeIfBegin)
+	if begin < 0 {
+		return content
+	}
+	end := strings.Index(content[begin:], includeIfEnd)
+	if end < 0 {
+		return content[:begin]
+	}
+	end += begin + len(includeIfEnd)
+	if end < len(content) && content[end] == '\n' {
+		end++
+	}
+	return content[:begin] + content[end:]
+}
@@ -0,0 +1,167 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// setupIncludeIfTest returns a git tool in includeif mode with a global
+// config and the git snapshot of a work environment
+func setupIncludeIfTest(t *testing.T) (*GitTool, string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	tmpDir := t.TempDir()
+	tool := writeGitConfig(t, "[user]\n\tname = Personal\n\temail = me@example.com\n")
+	tool.Mode = GitModeIncludeIf
+	tool.IdentityDir = filepath.Join(tmpDir, "identities")
+
+	snapshotPath := filepath.Join(tmpDir, "snapshot")
+	if err := os.MkdirAll(snapshotPath, 0755); err != nil {
+		t.Fatalf("Failed to create snapshot: %v", err)
+	}
+	snapshot := "[user]\n\tname = Work\n\temail = work@example.com\n[core]\n\teditor = vim\n"
+	if err := os.WriteFile(filepath.Join(snapshotPath, "gitconfig"), []byte(snapshot), 0644); err != nil {
+		t.Fatalf("Failed to write snapshot: %v", err)
+	}
+	return tool, snapshotPath
+}
+
+// repoConfig returns a value of the config of a repository created in dir,
+// with the global config of tool
+func repoConfig(t *testing.T, tool *GitTool, dir, key string) string {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("Failed to create repository directory: %v", err)
+	}
+	env := append(os.Environ(), "GIT_CONFIG_GLOBAL="+tool.GitConfigPath, "GIT_CONFIG_NOSYSTEM=1")
+	initCmd := exec.Command("git", "init", "-q", dir)
+	initCmd.Env = env
+	if output, err := initCmd.CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %s", output)
+	}
+	cmd := exec.Command("git", "-C", dir, "config", "--get", key)
+	cmd.Env = env
+	output, _ := cmd.Output()
+	return strings.TrimSpace(string(output))
+}
+
+func TestGitTool_RestoreIncludeIf(t *testing.T) {
+	tool, snapshotPath := setupIncludeIfTest(t)
+	workDir := filepath.Join(t.TempDir(), "work")
+	tool.Identities = []GitIdentity{{
+		Environment:  "work",
+		Directories:  []string{workDir},
+		SnapshotPath: snapshotPath,
+		SigningKey:   "ABCDEF12",
+	}}
+
+	if err := tool.Restore(snapshotPath); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	// The global identity stays, repositories below the directory use the
+	// one of the environment
+	if name := tool.configValue(context.Background(), "user.name"); name != "Personal" {
+		t.Errorf("Expected the global config to be kept, got user.name %q", name)
+	}
+	repo := filepath.Join(workDir, "api")
+	if email := repoConfig(t, tool, repo, "user.email"); email != "work@example.com" {
+		t.Errorf("Expected the work email in %s, got: %q", repo, email)
+	}
+	if key := repoConfig(t, tool, repo, "user.signingkey"); key != "ABCDEF12" {
+		t.Errorf("Expected the signing key of the metadata, got: %q", key)
+	}
+	if editor := repoConfig(t, tool, repo, "core.editor"); editor != "" {
+		t.Errorf("Expected only identity settings, got core.editor %q", editor)
+	}
+	other := filepath.Join(t.TempDir(), "other")
+	if email := repoConfig(t, tool, other, "user.email"); email != "me@example.com" {
+		t.Errorf("Expected the global email in %s, got: %q", other, email)
+	}
+
+	// Restoring again replaces the mapping rather than adding to it
+	tool.Identities = nil
+	if err := tool.Restore(snapshotPath); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	content, err := os.ReadFile(tool.GitConfigPath)
+	if err != nil {
+		t.Fatalf("Failed to read gitconfig: %v", err)
+	}
+	if strings.Contains(string(content), "includeIf") {
+		t.Errorf("Expected the includeIf sections to be removed, got:\n%s", content)
+	}
+	if !strings.Contains(string(content), "name = Personal") {
+		t.Errorf("Expected the rest of the config to be kept, got:\n%s", content)
+	}
+}
+
+func TestGitTool_SnapshotIncludeIf(t *testing.T) {
+	tool, snapshotPath := setupIncludeIfTest(t)
+
+	// The shared config does not replace the identity of the environment
+	if err := tool.Snapshot(snapshotPath); err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	content, err := os.ReadFile(filepath.Join(snapshotPath, "gitconfig"))
+	if err != nil {
+		t.Fatalf("Failed to read snapshot: %v", err)
+	}
+	if !strings.Contains(string(content), "name = Work") {
+		t.Errorf("Expected the snapshot to be kept, got:\n%s", content)
+	}
+
+	// but becomes the one of environments without a snapshot
+	newSnapshot := filepath.Join(t.TempDir(), "new")
+	if err := tool.Snapshot(newSnapshot); err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(newSnapshot, "gitconfig")); err != nil {
+		t.Errorf("Expected a snapshot of the shared config: %v", err)
+	}
+}
+
+func TestRemoveIncludeIfBlock(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{"no block", "[user]\n\tname = Me\n", "[user]\n\tname = Me\n"},
+		{
+			"block at the end",
+			"[user]\n\tname = Me\n" + includeIfBegin + "\n[includeIf \"gitdir:~/work/\"]\n\tpath = x\n" + includeIfEnd + "\n",
+			"[user]\n\tname = Me\n",
+		},
+		{
+			"block in the middle",
+			"[a]\n" + includeIfBegin + "\n" + includeIfEnd + "\n[b]\n",
+			"[a]\n[b]\n",
+		},
+		{"unterminated block", "[a]\n" + includeIfBegin + "\n[includeIf]\n", "[a]\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := removeIncludeIfBlock(tt.content); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestGitDirPattern(t *testing.T) {
+	if got := gitDirPattern("~/work"); got != "~/work/" {
+		t.Errorf("Expected a trailing slash, got: %s", got)
+	}
+	if got := gitDirPattern("~/work/"); got != "~/work/" {
+		t.Errorf("Expected the pattern unchanged, got: %s", got)
+	}
+}
@@ -0,0 +1,156 @@
+package tools
+
+import (
+	"os"
+	"strings"
+)
+
+// iniFile is an INI file as the AWS CLI and gcloud write them. It is kept
+// line by line so that merging into it leaves its comments, order and
+// formatting alone.
+type iniFile struct {
+	sections []*iniSection // the first one, unnamed, holds the lines before any header
+}
+
+// iniSection is a [section] of an INI file
+type iniSection struct {
+	name    string
+	header  string // the raw header line, empty for the unnamed section
+	entries []iniEntry
+}
+
+// iniEntry is a key with its value, or a comment or blank line when key is
+// empty. Indented lines after a key, the nested values of the AWS config,
+// belong to it.
+type iniEntry struct {
+	key   string
+	lines []string
+}
+
+// blank reports whether the entry is a blank line
+func (e iniEntry) blank() bool {
+	return e.key == "" && len(e.lines) == 1 && strings.TrimSpace(e.lines[0]) == ""
+}
+
+// parseINI parses the content of an INI file. It never fails: lines it does
+// not understand are kept as they are.
+func parseINI(content string) *iniFile {
+	file := &iniFile{sections: []*iniSection{{}}}
+	section := file.sections[0]
+
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	if content == "" {
+		lines = nil
+	}
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]"):
+			section = &iniSection{name: strings.TrimSpace(trimmed[1 : len(trimmed)-1]), header: line}
+			file.sections = append(file.sections, section)
+		case trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, ";"):
+			section.entries = append(section.entries, iniEntry{lines: []string{line}})
+		case line != trimmed && len(section.entries) > 0 && section.entries[len(section.entries)-1].key != "":
+			last := &section.entries[len(section.entries)-1]
+			last.lines = append(last.lines, line)
+		default:
+			key, _, _ := strings.Cut(trimmed, "=")
+			section.entries = append(section.entries, iniEntry{key: strings.TrimSpace(key), lines: []string{line}})
+		}
+	}
+	return file
+}
+
+// loadINI reads the INI file at path, empty when it does not exist
+func loadINI(path string) (*iniFile, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return parseINI(""), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseINI(string(data)), nil
+}
+
+// section returns the section named name, nil if there is none
+func (f *iniFile) section(name string) *iniSection {
+	for _, section := range f.sections {
+		if section.name == name {
+			return section
+		}
+	}
+	return nil
+}
+
+// merge sets the keys of other in f: keys f has are replaced in place, the
+// others are added at the end of their section, and sections f lacks are
+// appended. What other does not have is kept.
+func (f *iniFile) merge(other *iniFile) {
+	for _, section := range other.sections {
+		live := f.section(section.name)
+		if live == nil {
+			if section.name == "" {
+				continue // keys before any header only merge into existing ones
+			}
+			f.appendSection(section)
+			continue
+		}
+		for _, entry := range section.entries {
+			if entry.key != "" {
+				live.set(entry)
+			}
+		}
+	}
+}
+
+// set replaces the entry with the same key, or adds entry before the blank
+// lines ending the section
+func (s *iniSection) set(entry iniEntry) {
+	for i := range s.entries {
+		if s.entries[i].key == entry.key {
+			s.entries[i].lines = entry.lines
+			return
+		}
+	}
+
+	end := len(s.entries)
+	for end > 0 && s.entries[end-1].blank() {
+		end--
+	}
+	s.entries = append(s.entries[:end], append([]iniEntry{entry}, s.entries[end:]...)...)
+}
+
+// appendSection adds a copy of section at the end of f, after a blank line
+func (f *iniFile) appendSection(section *iniSection) {
+	last := f.sections[len(f.sections)-1]
+	if n := len(last.entries); (n > 0 && !last.entries[n-1].blank()) || (n == 0 && last.header != "") {
+		last.entries = append(last.entries, iniEntry{lines: []string{""}})
+	}
+
+	added := &iniSection{name: section.name, header: section.header}
+	for _, entry := range section.entries {
+		if entry.key != "" {
+			added.entries = append(added.entries, entry)
+		}
+	}
+	f.sections = append(f.sections, added)
+}
+
+// String returns the content of the file
+func (f *iniFile) String() string {
+	var b strings.Builder
+	for _, section := range f.sections {
+		if section.header != "" {
+			b.WriteString(section.header)
+			b.WriteString("\n")
+		}
+		for _, entry := range section.entries {
+			for _, line := range entry.lines {
+				b.WriteString(line)
+				b.WriteString("\n")
+			}
+		}
+	}
+	return b.String()
+}
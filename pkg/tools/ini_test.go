@@ -0,0 +1,57 @@
+package tools
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestINIMerge(t *testing.T) {
+	live := parseINI(`# Managed by hand
+[default]
+region = eu-west-1
+output = json
+
+[profile personal]
+region = us-east-1
+s3 =
+    max_concurrent_requests = 20
+`)
+	snapshot := parseINI(`[default]
+region = eu-central-1
+cli_pager =
+
+[profile work]
+region = eu-west-3
+sso_start_url = https://work.awsapps.com/start
+`)
+
+	live.merge(snapshot)
+	assert.Equal(t, `# Managed by hand
+[default]
+region = eu-central-1
+output = json
+cli_pager =
+
+[profile personal]
+region = us-east-1
+s3 =
+    max_concurrent_requests = 20
+
+[profile work]
+region = eu-west-3
+sso_start_url = https://work.awsapps.com/start
+`, live.String())
+}
+
+func TestINIMergeNestedValues(t *testing.T) {
+	live := parseINI("[default]\ns3 =\n    max_concurrent_requests = 20\nregion = eu-west-1\n")
+	live.merge(parseINI("[default]\ns3 =\n    max_bandwidth = 50MB/s\n"))
+	assert.Equal(t, "[default]\ns3 =\n    max_bandwidth = 50MB/s\nregion = eu-west-1\n", live.String())
+}
+
+func TestINIMergeIntoEmptyFile(t *testing.T) {
+	live := parseINI("")
+	live.merge(parseINI("[core]\naccount = me@work.com\nproject = work\n"))
+	assert.Equal(t, "[core]\naccount = me@work.com\nproject = work\n", live.String())
+}
@@ -0,0 +1,207 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Modes of the kubectl tool
+const (
+	// KubectlModeFull snapshots and restores the whole ~/.kube directory
+	KubectlModeFull = "full"
+	// KubectlModeContext only carries the current context, with its cluster
+	// and user, and merges it into the live kubeconfig on restore
+	KubectlModeContext = "context"
+)
+
+// kubeConfig is a kubeconfig file. Fields envswitch does not use are kept
+// as they are.
+type kubeConfig struct {
+	APIVersion     string                 `yaml:"apiVersion,omitempty"`
+	Kind           string                 `yaml:"kind,omitempty"`
+	CurrentContext string                 `yaml:"current-context"`
+	Clusters       []kubeEntry            `yaml:"clusters"`
+	Contexts       []kubeEntry            `yaml:"contexts"`
+	Users          []kubeEntry            `yaml:"users"`
+	Extra          map[string]interface{} `yaml:",inline"`
+}
+
+// kubeEntry is a named cluster, context or user of a kubeconfig
+type kubeEntry struct {
+	Name   string                 `yaml:"name"`
+	Fields map[string]interface{} `yaml:",inline"`
+}
+
+// contextRef returns the value of field (cluster or user) of a context entry
+func (e kubeEntry) contextRef(field string) string {
+	context, ok := e.Fields["context"].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	value, _ := context[field].(string)
+	return value
+}
+
+func loadKubeConfig(path string) (*kubeConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config kubeConfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse kubeconfig %s: %w", path, err)
+	}
+	return &config, nil
+}
+
+// CurrentContext returns the current context of the live kubeconfig
+func (k *KubectlTool) CurrentContext() (string, error) {
+	return currentContextOf(filepath.Join(k.KubeConfigDir, "config"))
+}
+
+// SnapshotContext returns the current context a snapshot restores
+func (k *KubectlTool) SnapshotContext(snapshotPath string) (string, error) {
+	return currentContextOf(filepath.Join(snapshotPath, "config"))
+}
+
+// currentContextOf returns the current context of a kubeconfig file
+func currentContextOf(path string) (string, error) {
+	config, err := loadKubeConfig(path)
+	if err != nil {
+		return "", err
+	}
+	return config.CurrentContext, nil
+}
+
+func (c *kubeConfig) save(path string) error {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("failed to marshal kubeconfig: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create kubeconfig directory: %w", err)
+	}
+	// Kubeconfigs hold credentials
+	return os.WriteFile(path, data, 0600)
+}
+
+// subset returns a kubeconfig holding the current context and the named
+// contexts, with the clusters and users they use. Names that are not in the
+// config are ignored.
+func (c *kubeConfig) subset(contextNames []string) (*kubeConfig, error) {
+	if c.CurrentContext == "" {
+		return nil, fmt.Errorf("kubeconfig has no current context")
+	}
+	if _, ok := findKubeEntry(c.Contexts, c.CurrentContext); !ok {
+		return nil, fmt.Errorf("current context %q is not defined in kubeconfig", c.CurrentContext)
+	}
+
+	subset := &kubeConfig{
+		APIVersion:     c.APIVersion,
+		Kind:           c.Kind,
+		CurrentContext: c.CurrentContext,
+		Clusters:       []kubeEntry{},
+		Contexts:       []kubeEntry{},
+		Users:          []kubeEntry{},
+	}
+
+	for _, name := range append([]string{c.CurrentContext}, contextNames...) {
+		context, ok := findKubeEntry(c.Contexts, name)
+		if !ok {
+			continue
+		}
+		subset.Contexts = upsertKubeEntry(subset.Contexts, context)
+		if cluster, ok := findKubeEntry(c.Clusters, context.contextRef("cluster")); ok {
+			subset.Clusters = upsertKubeEntry(subset.Clusters, cluster)
+		}
+		if user, ok := findKubeEntry(c.Users, context.contextRef("user")); ok {
+			subset.Users = upsertKubeEntry(subset.Users, user)
+		}
+	}
+	return subset, nil
+}
+
+// merge adds the clusters, contexts and users of other to c, replacing the
+// ones with the same name, and makes its current context the current one
+func (c *kubeConfig) merge(other *kubeConfig) {
+	for _, entry := range other.Clusters {
+		c.Clusters = upsertKubeEntry(c.Clusters, entry)
+	}
+	for _, entry := range other.Contexts {
+		c.Contexts = upsertKubeEntry(c.Contexts, entry)
+	}
+	for _, entry := range other.Users {
+		c.Users = upsertKubeEntry(c.Users, entry)
+	}
+	if other.CurrentContext != "" {
+		c.CurrentContext = other.CurrentContext
+	}
+	if c.APIVersion == "" {
+		c.APIVersion = other.APIVersion
+	}
+	if c.Kind == "" {
+		c.Kind = other.Kind
+	}
+}
+
+// diffEntries compares the entries of the snapshot kubeconfig c with the
+// live one. Live entries the snapshot does not carry are not changes.
+func (c *kubeConfig) diffEntries(live *kubeConfig) []Change {
+	changes := []Change{}
+	if c.CurrentContext != live.CurrentContext {
+		changes = append(changes, Change{
+			Type:     ChangeTypeModified,
+			Path:     "current-context",
+			OldValue: c.CurrentContext,
+			NewValue: live.CurrentContext,
+		})
+	}
+
+	sections := []struct {
+		name           string
+		snapshot, live []kubeEntry
+	}{
+		{"clusters", c.Clusters, live.Clusters},
+		{"contexts", c.Contexts, live.Contexts},
+		{"users", c.Users, live.Users},
+	}
+	for _, section := range sections {
+		for _, entry := range section.snapshot {
+			liveEntry, ok := findKubeEntry(section.live, entry.Name)
+			switch {
+			case !ok:
+				changes = append(changes, Change{Type: ChangeTypeRemoved, Path: section.name + "/" + entry.Name})
+			case !reflect.DeepEqual(entry.Fields, liveEntry.Fields):
+				changes = append(changes, Change{Type: ChangeTypeModified, Path: section.name + "/" + entry.Name})
+			}
+		}
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+func findKubeEntry(entries []kubeEntry, name string) (kubeEntry, bool) {
+	for _, entry := range entries {
+		if entry.Name == name {
+			return entry, true
+		}
+	}
+	return kubeEntry{}, false
+}
+
+// upsertKubeEntry replaces the entry with the same name, or appends entry
+func upsertKubeEntry(entries []kubeEntry, entry kubeEntry) []kubeEntry {
+	for i := range entries {
+		if entries[i].Name == entry.Name {
+			entries[i] = entry
+			return entries
+		}
+	}
+	return append(entries, entry)
+}
@@ -0,0 +1,160 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hugofrely/envswitch/internal/storage"
+)
+
+const testKubeConfig = `apiVersion: v1
+kind: Config
+current-context: work
+preferences: {}
+clusters:
+- name: work-cluster
+  cluster:
+    server: https://work:6443
+- name: lab-cluster
+  cluster:
+    server: https://lab:6443
+- name: home-cluster
+  cluster:
+    server: https://home:6443
+contexts:
+- name: work
+  context:
+    cluster: work-cluster
+    user: work-user
+    namespace: team
+- name: lab
+  context:
+    cluster: lab-cluster
+    user: work-user
+- name: home
+  context:
+    cluster: home-cluster
+    user: home-user
+users:
+- name: work-user
+  user:
+    token: work-token
+- name: home-user
+  user:
+    token: home-token
+`
+
+func writeKubeConfig(t *testing.T, dir, content string) string {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	path := filepath.Join(dir, "config")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func entryNames(entries []kubeEntry) []string {
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name)
+	}
+	return names
+}
+
+func TestKubeConfigSubset(t *testing.T) {
+	config, err := loadKubeConfig(writeKubeConfig(t, t.TempDir(), testKubeConfig))
+	require.NoError(t, err)
+
+	subset, err := config.subset([]string{"lab", "missing"})
+	require.NoError(t, err)
+	assert.Equal(t, "work", subset.CurrentContext)
+	assert.Equal(t, []string{"work", "lab"}, entryNames(subset.Contexts))
+	assert.Equal(t, []string{"work-cluster", "lab-cluster"}, entryNames(subset.Clusters))
+	assert.Equal(t, []string{"work-user"}, entryNames(subset.Users))
+
+	config.CurrentContext = "gone"
+	_, err = config.subset(nil)
+	assert.Error(t, err)
+}
+
+func TestKubectlToolContextMode(t *testing.T) {
+	tmpDir := t.TempDir()
+	kubeDir := filepath.Join(tmpDir, "kube")
+	livePath := writeKubeConfig(t, kubeDir, testKubeConfig)
+	require.NoError(t, os.MkdirAll(filepath.Join(kubeDir, "cache"), 0755))
+
+	tool := &KubectlTool{KubeConfigDir: kubeDir, Mode: KubectlModeContext}
+	snapshotPath := filepath.Join(tmpDir, "snapshot")
+	require.NoError(t, tool.Snapshot(snapshotPath))
+
+	entries, err := os.ReadDir(snapshotPath)
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "only the kubeconfig is snapshotted")
+	snapshot, err := loadKubeConfig(filepath.Join(snapshotPath, "config"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"work"}, entryNames(snapshot.Contexts))
+
+	changes, err := tool.diffContexts(snapshotPath)
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+
+	// A cluster added from another environment, which switched to it
+	live, err := loadKubeConfig(livePath)
+	require.NoError(t, err)
+	live.Contexts = upsertKubeEntry(live.Contexts, kubeEntry{Name: "staging", Fields: map[string]interface{}{
+		"context": map[string]interface{}{"cluster": "home-cluster", "user": "home-user"},
+	}})
+	live.Contexts[0].Fields["context"].(map[string]interface{})["namespace"] = "other"
+	live.CurrentContext = "staging"
+	require.NoError(t, live.save(livePath))
+
+	changes, err = tool.diffContexts(snapshotPath)
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+	assert.Equal(t, "contexts/work", changes[0].Path)
+	assert.Equal(t, "current-context", changes[1].Path)
+
+	require.NoError(t, tool.Restore(snapshotPath))
+
+	restored, err := loadKubeConfig(livePath)
+	require.NoError(t, err)
+	assert.Equal(t, "work", restored.CurrentContext)
+	assert.Equal(t, []string{"work", "lab", "home", "staging"}, entryNames(restored.Contexts), "other contexts are kept")
+	work, _ := findKubeEntry(restored.Contexts, "work")
+	assert.Equal(t, "team", work.Fields["context"].(map[string]interface{})["namespace"])
+	assert.Equal(t, map[string]interface{}{}, restored.Extra["preferences"], "unknown fields are kept")
+}
+
+func TestKubectlToolContextModeRollback(t *testing.T) {
+	tmpDir := t.TempDir()
+	kubeDir := filepath.Join(tmpDir, "kube")
+	livePath := writeKubeConfig(t, kubeDir, testKubeConfig)
+
+	tool := &KubectlTool{KubeConfigDir: kubeDir, Mode: KubectlModeContext}
+	snapshotPath := filepath.Join(tmpDir, "snapshot")
+	require.NoError(t, tool.Snapshot(snapshotPath))
+
+	// The environment being left uses another context
+	live, err := loadKubeConfig(livePath)
+	require.NoError(t, err)
+	live.CurrentContext = "lab"
+	require.NoError(t, live.save(livePath))
+	before, err := os.ReadFile(livePath)
+	require.NoError(t, err)
+
+	held := storage.HoldRestoreBackups()
+	defer func() { _ = held.Release() }()
+	require.NoError(t, tool.Restore(snapshotPath))
+	context, err := tool.CurrentContext()
+	require.NoError(t, err)
+	assert.Equal(t, "work", context)
+
+	// An interrupted switch puts the live kubeconfig back
+	require.NoError(t, held.Rollback(tool.ConfigPaths()...))
+	after, err := os.ReadFile(livePath)
+	require.NoError(t, err)
+	assert.Equal(t, string(before), string(after))
+}
@@ -1,12 +1,14 @@
 package tools
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 
+	"github.com/hugofrely/envswitch/internal/platform"
 	"github.com/hugofrely/envswitch/internal/storage"
 )
 
@@ -16,21 +18,45 @@ const (
 
 // KubectlTool implements the Tool interface for Kubectl
 type KubectlTool struct {
-	KubeConfigDir string // ~/.kube
+	KubeConfigDir   string           // ~/.kube, %USERPROFILE%\.kube on Windows
+	ExcludePatterns []string         // paths left out of snapshots, see storage.IsExcluded
+	Progress        storage.Progress // told about each file copied, may be nil
+	Mode            string           // KubectlModeFull (default) or KubectlModeContext
+	Contexts        []string         // contexts carried besides the current one in context mode
 }
 
 // NewKubectlTool creates a new Kubectl tool instance
 func NewKubectlTool() *KubectlTool {
-	home, _ := os.UserHomeDir()
+	home, _ := platform.HomeDir()
 	return &KubectlTool{
-		KubeConfigDir: filepath.Join(home, ".kube"),
+		KubeConfigDir:   filepath.Join(home, ".kube"),
+		ExcludePatterns: kubectlDefaultExcludes(),
 	}
 }
 
+// kubectlDefaultExcludes returns the discovery and HTTP caches kubectl rebuilds on its own
+func kubectlDefaultExcludes() []string {
+	return []string{"cache/**", "http-cache/**"}
+}
+
 func (k *KubectlTool) Name() string {
 	return "kubectl"
 }
 
+func (k *KubectlTool) ConfigPaths() []string {
+	return []string{k.KubeConfigDir}
+}
+
+// AddExcludePatterns adds patterns to the paths left out of snapshots
+func (k *KubectlTool) AddExcludePatterns(patterns ...string) {
+	k.ExcludePatterns = append(k.ExcludePatterns, patterns...)
+}
+
+// SetProgress sets where the progress of the files copied is reported
+func (k *KubectlTool) SetProgress(progress storage.Progress) {
+	k.Progress = progress
+}
+
 func (k *KubectlTool) IsInstalled() bool {
 	_, err := exec.LookPath("kubectl")
 	return err == nil
@@ -47,8 +73,12 @@ func (k *KubectlTool) Snapshot(snapshotPath string) error {
 		return fmt.Errorf("failed to create snapshot directory: %w", err)
 	}
 
-	// Copy the entire .kube directory to snapshot
-	if err := storage.CopyDir(k.KubeConfigDir, snapshotPath); err != nil {
+	if k.Mode == KubectlModeContext {
+		return k.snapshotContexts(snapshotPath)
+	}
+
+	// Copy the config directory to the snapshot, only rewriting changed files
+	if _, err := storage.SnapshotDirProgress(k.KubeConfigDir, snapshotPath, k.Progress, k.ExcludePatterns...); err != nil {
 		return fmt.Errorf("failed to copy kubectl config: %w", err)
 	}
 
@@ -61,24 +91,49 @@ func (k *KubectlTool) Restore(snapshotPath string) error {
 		return fmt.Errorf("invalid snapshot: %w", err)
 	}
 
+	if k.Mode == KubectlModeContext {
+		return k.restoreContexts(snapshotPath)
+	}
+
 	// Create parent directory if it doesn't exist
 	configParent := filepath.Dir(k.KubeConfigDir)
 	if err := os.MkdirAll(configParent, 0755); err != nil {
 		return fmt.Errorf("failed to create config parent directory: %w", err)
 	}
 
-	// Remove existing config directory if it exists
-	if _, err := os.Stat(k.KubeConfigDir); err == nil {
-		if err := os.RemoveAll(k.KubeConfigDir); err != nil {
-			return fmt.Errorf("failed to remove existing config: %w", err)
-		}
+	// Restore from snapshot, only rewriting files that differ and removing
+	// files the snapshot does not have
+	if _, err := storage.SyncDirProgress(snapshotPath, k.KubeConfigDir, k.Progress, k.ExcludePatterns...); err != nil {
+		return fmt.Errorf("failed to restore kubectl config: %w", err)
 	}
 
-	// Restore from snapshot
-	if err := storage.CopyDir(snapshotPath, k.KubeConfigDir); err != nil {
-		return fmt.Errorf("failed to restore kubectl config: %w", err)
+	return nil
+}
+
+// Merge merges the clusters, contexts and users of the snapshot kubeconfig
+// into the live one and makes its current context the current one. In full
+// mode the other files of the snapshot are copied over the live ones.
+func (k *KubectlTool) Merge(ctx context.Context, snapshotPath string) error {
+	if err := k.ValidateSnapshot(snapshotPath); err != nil {
+		return fmt.Errorf("invalid snapshot: %w", err)
+	}
+	if k.Mode == KubectlModeContext {
+		// Context mode always merges
+		return k.restoreContexts(snapshotPath)
 	}
 
+	if err := os.MkdirAll(k.KubeConfigDir, 0700); err != nil {
+		return fmt.Errorf("failed to create kubectl config directory: %w", err)
+	}
+	err := mergeDir(ctx, snapshotPath, k.KubeConfigDir, k.ExcludePatterns, func(rel string) fileMerger {
+		if rel == "config" {
+			return mergeKubeConfigFile
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to merge kubectl config: %w", err)
+	}
 	return nil
 }
 
@@ -148,9 +203,93 @@ func (k *KubectlTool) Diff(snapshotPath string) ([]Change, error) {
 	// Compare namespace
 	changes = append(changes, compareMetadataField("namespace", snapshotMeta, currentMeta)...)
 
+	// Compare the carried contexts, or every config file
+	if k.Mode == KubectlModeContext {
+		contextChanges, err := k.diffContexts(snapshotPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to diff kubeconfig: %w", err)
+		}
+		return append(changes, contextChanges...), nil
+	}
+	fileChanges, err := diffTree(snapshotPath, k.KubeConfigDir, "", k.ExcludePatterns...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff kubectl config: %w", err)
+	}
+	changes = append(changes, fileChanges...)
+
 	return changes, nil
 }
 
+// snapshotContexts writes a kubeconfig holding the current context and the
+// configured ones, with their clusters and users, as the whole snapshot
+func (k *KubectlTool) snapshotContexts(snapshotPath string) error {
+	live, err := loadKubeConfig(filepath.Join(k.KubeConfigDir, "config"))
+	if err != nil {
+		return fmt.Errorf("failed to read kubeconfig: %w", err)
+	}
+	subset, err := live.subset(k.Contexts)
+	if err != nil {
+		return err
+	}
+
+	// Drop the files of a previous full snapshot
+	entries, err := os.ReadDir(snapshotPath)
+	if err != nil {
+		return fmt.Errorf("failed to read snapshot directory: %w", err)
+	}
+	for _, entry := range entries {
+		if err := os.RemoveAll(filepath.Join(snapshotPath, entry.Name())); err != nil {
+			return fmt.Errorf("failed to clean snapshot directory: %w", err)
+		}
+	}
+
+	return subset.save(filepath.Join(snapshotPath, "config"))
+}
+
+// restoreContexts merges the contexts of the snapshot into the live
+// kubeconfig and makes the snapshot's current context the current one.
+// Contexts the snapshot does not carry are kept.
+func (k *KubectlTool) restoreContexts(snapshotPath string) error {
+	snapshot, err := loadKubeConfig(filepath.Join(snapshotPath, "config"))
+	if err != nil {
+		return fmt.Errorf("failed to read snapshot kubeconfig: %w", err)
+	}
+
+	livePath := filepath.Join(k.KubeConfigDir, "config")
+	live, err := loadKubeConfig(livePath)
+	if os.IsNotExist(err) {
+		live = &kubeConfig{}
+	} else if err != nil {
+		return fmt.Errorf("failed to read kubeconfig: %w", err)
+	}
+
+	live.merge(snapshot)
+	// The live kubeconfig is set aside, to be put back if writing it fails
+	// or the switch is rolled back
+	if err := storage.ReplaceSafely(livePath, func() error {
+		return live.save(livePath)
+	}); err != nil {
+		return fmt.Errorf("failed to restore kubeconfig: %w", err)
+	}
+	return nil
+}
+
+// diffContexts compares the contexts carried by the snapshot with the live
+// kubeconfig
+func (k *KubectlTool) diffContexts(snapshotPath string) ([]Change, error) {
+	snapshot, err := loadKubeConfig(filepath.Join(snapshotPath, "config"))
+	if err != nil {
+		return nil, err
+	}
+	live, err := loadKubeConfig(filepath.Join(k.KubeConfigDir, "config"))
+	if os.IsNotExist(err) {
+		live = &kubeConfig{}
+	} else if err != nil {
+		return nil, err
+	}
+	return snapshot.diffEntries(live), nil
+}
+
 // getSnapshotMetadata reads metadata from a snapshot kubeconfig file
 func (k *KubectlTool) getSnapshotMetadata(snapshotPath string) (map[string]interface{}, error) {
 	metadata := make(map[string]interface{})
@@ -96,6 +96,37 @@ users:
 	}
 }
 
+func TestKubectlTool_SnapshotExcludesCaches(t *testing.T) {
+	tmpDir := t.TempDir()
+	kubeDir := filepath.Join(tmpDir, "kube")
+	for _, rel := range []string{"config", "cache/discovery.json", "http-cache/entry", "audit.log"} {
+		path := filepath.Join(kubeDir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(rel), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", rel, err)
+		}
+	}
+
+	tool := &KubectlTool{KubeConfigDir: kubeDir, ExcludePatterns: kubectlDefaultExcludes()}
+	tool.AddExcludePatterns("**/*.log")
+
+	snapshotPath := filepath.Join(tmpDir, "snapshot")
+	if err := tool.Snapshot(snapshotPath); err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(snapshotPath, "config")); err != nil {
+		t.Errorf("Snapshot config file was not created: %v", err)
+	}
+	for _, rel := range []string{"cache", "http-cache", "audit.log"} {
+		if _, err := os.Stat(filepath.Join(snapshotPath, rel)); !os.IsNotExist(err) {
+			t.Errorf("Excluded %s was copied", rel)
+		}
+	}
+}
+
 func TestKubectlTool_Restore(t *testing.T) {
 	// Create temp directory for testing
 	tmpDir, err := os.MkdirTemp("", "envswitch-test-*")
@@ -0,0 +1,109 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/hugofrely/envswitch/internal/storage"
+)
+
+// fileMerger merges the snapshot file src into the live file dst
+type fileMerger func(src, dst string) error
+
+// mergeDir merges the snapshot directory src into the live directory dst.
+// Files for which mergerFor returns a merger are merged by it, the others are
+// copied over the live ones. Live files the snapshot does not have are kept,
+// and paths matching the exclude patterns are left alone.
+//
+// The live files it writes are copied aside until the merge succeeds, and put
+// back if it fails or ctx is done.
+func mergeDir(ctx context.Context, src, dst string, exclude []string, mergerFor func(rel string) fileMerger) error {
+	backup := storage.NewRestoreBackup(dst)
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == "." {
+			return nil
+		}
+		if rel == storage.ManifestFile || storage.IsExcluded(rel, exclude) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		target := filepath.Join(dst, filepath.FromSlash(rel))
+		if info.IsDir() {
+			return os.MkdirAll(target, info.Mode().Perm()|0700)
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		if err := backup.Overwrite(target); err != nil {
+			return err
+		}
+		if merge := mergerFor(rel); merge != nil {
+			if err := merge(path, target); err != nil {
+				return fmt.Errorf("failed to merge %s: %w", rel, err)
+			}
+			return nil
+		}
+		return storage.CopyFile(path, target)
+	})
+	return backup.Finish(err)
+}
+
+// mergeINIFile merges the keys of the INI file src into dst, see
+// iniFile.merge. The file keeps its mode, 0600 when it is created since
+// these files hold credentials.
+func mergeINIFile(src, dst string) error {
+	snapshot, err := loadINI(src)
+	if err != nil {
+		return err
+	}
+	live, err := loadINI(dst)
+	if err != nil {
+		return err
+	}
+	live.merge(snapshot)
+	return writeMergedFile(dst, []byte(live.String()))
+}
+
+// mergeKubeConfigFile merges the clusters, contexts and users of the
+// kubeconfig src into dst, see kubeConfig.merge
+func mergeKubeConfigFile(src, dst string) error {
+	snapshot, err := loadKubeConfig(src)
+	if err != nil {
+		return err
+	}
+	live, err := loadKubeConfig(dst)
+	if os.IsNotExist(err) {
+		live = &kubeConfig{}
+	} else if err != nil {
+		return err
+	}
+	live.merge(snapshot)
+	return live.save(dst)
+}
+
+// writeMergedFile writes data to the live file path, keeping its mode
+func writeMergedFile(path string, data []byte) error {
+	perm := os.FileMode(0600)
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+	return os.WriteFile(path, data, perm)
+}
@@ -0,0 +1,104 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeDir(t *testing.T) {
+	tmpDir := t.TempDir()
+	snapshot := filepath.Join(tmpDir, "snapshot")
+	live := filepath.Join(tmpDir, "live")
+	for path, content := range map[string]string{
+		filepath.Join(snapshot, "credentials"):            "[work]\naws_access_key_id = WORK\n",
+		filepath.Join(snapshot, "sso", "cache", "a.json"): "snapshot",
+		filepath.Join(snapshot, "cli", "cache", "b.log"):  "excluded",
+		filepath.Join(live, "credentials"):                "[personal]\naws_access_key_id = PERSONAL\n",
+		filepath.Join(live, "sso", "cache", "a.json"):     "live",
+		filepath.Join(live, "sso", "cache", "old.json"):   "kept",
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0700))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	}
+
+	err := mergeDir(context.Background(), snapshot, live, []string{"*.log"}, func(rel string) fileMerger {
+		if rel == "credentials" {
+			return mergeINIFile
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	read := func(rel string) string {
+		data, err := os.ReadFile(filepath.Join(live, rel))
+		require.NoError(t, err)
+		return string(data)
+	}
+	assert.Equal(t, "[personal]\naws_access_key_id = PERSONAL\n\n[work]\naws_access_key_id = WORK\n", read("credentials"))
+	assert.Equal(t, "snapshot", read("sso/cache/a.json"))
+	assert.Equal(t, "kept", read("sso/cache/old.json"))
+	assert.NoFileExists(t, filepath.Join(live, "cli", "cache", "b.log"))
+
+	info, err := os.Stat(filepath.Join(live, "credentials"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
+
+func TestKubectlToolMerge(t *testing.T) {
+	tmpDir := t.TempDir()
+	kubeDir := filepath.Join(tmpDir, "kube")
+	snapshotPath := filepath.Join(tmpDir, "snapshot")
+
+	writeKubeConfig(t, snapshotPath, `apiVersion: v1
+kind: Config
+current-context: client
+clusters:
+- name: client-cluster
+  cluster:
+    server: https://client:6443
+contexts:
+- name: client
+  context:
+    cluster: client-cluster
+    user: client-user
+users:
+- name: client-user
+  user:
+    token: client-token
+`)
+	require.NoError(t, os.WriteFile(filepath.Join(snapshotPath, "client-ca.crt"), []byte("ca"), 0600))
+	livePath := writeKubeConfig(t, kubeDir, testKubeConfig)
+
+	tool := &KubectlTool{KubeConfigDir: kubeDir}
+	require.NoError(t, tool.Merge(context.Background(), snapshotPath))
+
+	merged, err := loadKubeConfig(livePath)
+	require.NoError(t, err)
+	assert.Equal(t, "client", merged.CurrentContext)
+	assert.Equal(t, []string{"work", "lab", "home", "client"}, entryNames(merged.Contexts))
+	assert.Equal(t, []string{"work-user", "home-user", "client-user"}, entryNames(merged.Users))
+	assert.FileExists(t, filepath.Join(kubeDir, "client-ca.crt"))
+}
+
+func TestMergeDirRollsBack(t *testing.T) {
+	tmpDir := t.TempDir()
+	kubeDir := filepath.Join(tmpDir, "kube")
+	snapshotPath := filepath.Join(tmpDir, "snapshot")
+
+	writeKubeConfig(t, snapshotPath, "clusters: [unterminated\n")
+	require.NoError(t, os.WriteFile(filepath.Join(snapshotPath, "a.crt"), []byte("new"), 0600))
+	livePath := writeKubeConfig(t, kubeDir, testKubeConfig)
+
+	tool := &KubectlTool{KubeConfigDir: kubeDir}
+	assert.ErrorContains(t, tool.Merge(context.Background(), snapshotPath), "failed to merge config")
+
+	data, err := os.ReadFile(livePath)
+	require.NoError(t, err)
+	assert.Equal(t, testKubeConfig, string(data))
+	assert.NoFileExists(t, filepath.Join(kubeDir, "a.crt"), "files copied before the failure are removed")
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+
+	"github.com/hugofrely/envswitch/internal/storage"
 )
 
 // MultiPathTool gère plusieurs fichiers/dossiers de configuration
@@ -24,6 +26,10 @@ func (m *MultiPathTool) Name() string {
 	return m.toolName
 }
 
+func (m *MultiPathTool) ConfigPaths() []string {
+	return m.configPaths
+}
+
 func (m *MultiPathTool) IsInstalled() bool {
 	// Considérer installé si au moins un fichier existe
 	for _, path := range m.configPaths {
@@ -40,6 +46,8 @@ func (m *MultiPathTool) Snapshot(snapshotPath string) error {
 		return fmt.Errorf("failed to create snapshot directory: %w", err)
 	}
 
+	manifest := &storage.Manifest{Files: make(map[string]storage.ManifestEntry)}
+
 	// Copier chaque fichier/dossier
 	for _, configPath := range m.configPaths {
 		// Vérifier si le fichier existe
@@ -68,9 +76,14 @@ func (m *MultiPathTool) Snapshot(snapshotPath string) error {
 				return fmt.Errorf("failed to copy file %s: %w", configPath, err)
 			}
 		}
+
+		// Enregistrer les hashes des fichiers copiés pour Diff
+		if err := recordSnapshot(manifest, configPath, destPath, baseName); err != nil {
+			return fmt.Errorf("failed to record snapshot manifest of %s: %w", configPath, err)
+		}
 	}
 
-	return nil
+	return manifest.Save(snapshotPath)
 }
 
 func (m *MultiPathTool) Restore(snapshotPath string) error {
@@ -91,15 +104,17 @@ func (m *MultiPathTool) Restore(snapshotPath string) error {
 			return fmt.Errorf("failed to stat snapshot %s: %w", sourcePath, err)
 		}
 
+		// Remplacer la config, l'ancienne est remise en place si la copie échoue
 		if info.IsDir() {
-			// Supprimer le dossier et le remplacer
-			os.RemoveAll(configPath)
-			if err := copyDir(sourcePath, configPath); err != nil {
+			if err := storage.ReplaceSafely(configPath, func() error {
+				return copyDir(sourcePath, configPath)
+			}); err != nil {
 				return fmt.Errorf("failed to restore directory %s: %w", configPath, err)
 			}
 		} else {
-			// Copier le fichier
-			if err := copyFile(sourcePath, configPath); err != nil {
+			if err := storage.ReplaceSafely(configPath, func() error {
+				return copyFile(sourcePath, configPath)
+			}); err != nil {
 				return fmt.Errorf("failed to restore file %s: %w", configPath, err)
 			}
 		}
@@ -121,6 +136,11 @@ func (m *MultiPathTool) GetMetadata() (map[string]interface{}, error) {
 	metadata["existing_paths"] = existingPaths
 	metadata["path_count"] = len(m.configPaths)
 
+	// Nombre de fichiers, taille totale, dernière modification et hash du contenu
+	if err := addManifestMetadata(metadata, m.configPaths); err != nil {
+		return nil, err
+	}
+
 	return metadata, nil
 }
 
@@ -135,33 +155,19 @@ func (m *MultiPathTool) ValidateSnapshot(snapshotPath string) error {
 
 func (m *MultiPathTool) Diff(snapshotPath string) ([]Change, error) {
 	var changes []Change
+	manifest := storage.LoadManifest(snapshotPath)
 
 	for _, configPath := range m.configPaths {
 		baseName := filepath.Base(configPath)
 		snapshotFile := filepath.Join(snapshotPath, baseName)
 
-		currentExists := fileExists(configPath)
-		snapshotExists := fileExists(snapshotFile)
-
-		if snapshotExists && !currentExists {
-			changes = append(changes, Change{
-				Type: ChangeTypeRemoved,
-				Path: baseName,
-			})
-		} else if !snapshotExists && currentExists {
-			changes = append(changes, Change{
-				Type: ChangeTypeAdded,
-				Path: baseName,
-			})
-		} else if snapshotExists && currentExists {
-			// Comparer les contenus (simple check, pas de diff profond)
-			if !filesEqual(configPath, snapshotFile) {
-				changes = append(changes, Change{
-					Type: ChangeTypeModified,
-					Path: baseName,
-				})
-			}
+		// Comparer les contenus (fichier par fichier pour les dossiers), les
+		// fichiers inchangés depuis le snapshot reprennent le hash du manifest
+		pathChanges, err := diffPathManifest(snapshotFile, configPath, baseName, manifest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to diff %s: %w", configPath, err)
 		}
+		changes = append(changes, pathChanges...)
 	}
 
 	return changes, nil
@@ -0,0 +1,289 @@
+package tools
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/hugofrely/envswitch/internal/platform"
+	"github.com/hugofrely/envswitch/internal/storage"
+)
+
+const npmDefaultRegistry = "https://registry.npmjs.org/"
+
+// NpmTool implements the Tool interface for npm and yarn registry settings
+type NpmTool struct {
+	NpmRCPath     string // ~/.npmrc
+	YarnRCPath    string // ~/.yarnrc (yarn 1)
+	YarnRCYMLPath string // ~/.yarnrc.yml (yarn 2+)
+}
+
+// NewNpmTool creates a new npm tool instance
+func NewNpmTool() *NpmTool {
+	home, _ := platform.HomeDir()
+	return &NpmTool{
+		NpmRCPath:     filepath.Join(home, ".npmrc"),
+		YarnRCPath:    filepath.Join(home, ".yarnrc"),
+		YarnRCYMLPath: filepath.Join(home, ".yarnrc.yml"),
+	}
+}
+
+func (n *NpmTool) Name() string {
+	return "npm"
+}
+
+func (n *NpmTool) ConfigPaths() []string {
+	return []string{n.NpmRCPath, n.YarnRCPath, n.YarnRCYMLPath}
+}
+
+func (n *NpmTool) IsInstalled() bool {
+	if _, err := exec.LookPath("npm"); err == nil {
+		return true
+	}
+	_, err := exec.LookPath("yarn")
+	return err == nil
+}
+
+func (n *NpmTool) Snapshot(snapshotPath string) error {
+	// Create snapshot directory
+	if err := os.MkdirAll(snapshotPath, 0755); err != nil {
+		return fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+
+	for name, livePath := range n.files() {
+		destPath := filepath.Join(snapshotPath, name)
+
+		if _, err := os.Stat(livePath); os.IsNotExist(err) {
+			// Record the absence so restore removes the file
+			if err := os.Remove(destPath); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to remove stale %s: %w", name, err)
+			}
+			continue
+		}
+
+		if err := storage.CopyFile(livePath, destPath); err != nil {
+			return fmt.Errorf("failed to copy %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+func (n *NpmTool) Restore(snapshotPath string) error {
+	// Validate snapshot first
+	if err := n.ValidateSnapshot(snapshotPath); err != nil {
+		return fmt.Errorf("invalid snapshot: %w", err)
+	}
+
+	for name, livePath := range n.files() {
+		srcPath := filepath.Join(snapshotPath, name)
+
+		if _, err := os.Stat(srcPath); os.IsNotExist(err) {
+			// Not part of the environment: don't leak the previous tokens
+			if err := os.Remove(livePath); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to remove %s: %w", livePath, err)
+			}
+			continue
+		}
+
+		if err := storage.ReplaceSafely(livePath, func() error {
+			return storage.CopyFile(srcPath, livePath)
+		}); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", name, err)
+		}
+		// The files hold registry tokens
+		if err := os.Chmod(livePath, 0600); err != nil {
+			return fmt.Errorf("failed to set permissions on %s: %w", livePath, err)
+		}
+	}
+
+	return nil
+}
+
+func (n *NpmTool) GetMetadata() (map[string]interface{}, error) {
+	return npmMetadata(n.NpmRCPath, n.YarnRCPath, n.YarnRCYMLPath), nil
+}
+
+func (n *NpmTool) ValidateSnapshot(snapshotPath string) error {
+	// Check if snapshot directory exists
+	if _, err := os.Stat(snapshotPath); os.IsNotExist(err) {
+		return fmt.Errorf("snapshot directory does not exist")
+	}
+
+	return nil
+}
+
+func (n *NpmTool) Diff(snapshotPath string) ([]Change, error) {
+	currentMeta := npmMetadata(n.NpmRCPath, n.YarnRCPath, n.YarnRCYMLPath)
+	snapshotMeta := npmMetadata(
+		filepath.Join(snapshotPath, "npmrc"),
+		filepath.Join(snapshotPath, "yarnrc"),
+		filepath.Join(snapshotPath, "yarnrc.yml"),
+	)
+
+	changes := []Change{}
+
+	// Compare registry settings
+	changes = append(changes, compareMetadataField("registry", snapshotMeta, currentMeta)...)
+	changes = append(changes, compareMetadataField("authenticated_scopes", snapshotMeta, currentMeta)...)
+
+	// Compare config files
+	names := []string{"npmrc", "yarnrc", "yarnrc.yml"}
+	files := n.files()
+	for _, name := range names {
+		fileChanges, err := diffPath(filepath.Join(snapshotPath, name), files[name], "."+name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to diff %s: %w", name, err)
+		}
+		changes = append(changes, fileChanges...)
+	}
+
+	return changes, nil
+}
+
+// files maps snapshot file names to live config paths
+func (n *NpmTool) files() map[string]string {
+	return map[string]string{
+		"npmrc":      n.NpmRCPath,
+		"yarnrc":     n.YarnRCPath,
+		"yarnrc.yml": n.YarnRCYMLPath,
+	}
+}
+
+// npmMetadata extracts the default registry and the authenticated scopes
+func npmMetadata(npmrcPath, yarnrcPath, yarnrcYMLPath string) map[string]interface{} {
+	metadata := make(map[string]interface{})
+
+	scopes := make(map[string]bool)
+
+	// .npmrc: registry=..., @scope:registry=..., //host/path/:_authToken=...
+	npmrc := readNpmrc(npmrcPath)
+	registry := npmrc["registry"]
+	for key, scopeRegistry := range npmrc {
+		scope, ok := strings.CutSuffix(key, ":registry")
+		if !ok || !strings.HasPrefix(scope, "@") {
+			continue
+		}
+		if npmrcHasAuth(npmrc, scopeRegistry) {
+			scopes[scope] = true
+		}
+	}
+
+	// .yarnrc.yml: npmRegistryServer and npmScopes.<scope>.npmAuthToken
+	if data, err := os.ReadFile(yarnrcYMLPath); err == nil {
+		var yarnrc struct {
+			NpmRegistryServer string `yaml:"npmRegistryServer"`
+			NpmScopes         map[string]struct {
+				NpmAuthToken string `yaml:"npmAuthToken"`
+				NpmAuthIdent string `yaml:"npmAuthIdent"`
+			} `yaml:"npmScopes"`
+		}
+		if err := yaml.Unmarshal(data, &yarnrc); err == nil {
+			if registry == "" {
+				registry = yarnrc.NpmRegistryServer
+			}
+			for scope, settings := range yarnrc.NpmScopes {
+				if settings.NpmAuthToken != "" || settings.NpmAuthIdent != "" {
+					scopes["@"+strings.TrimPrefix(scope, "@")] = true
+				}
+			}
+		}
+	}
+
+	// .yarnrc (yarn 1): registry "https://..."
+	if registry == "" {
+		registry = readYarnrcRegistry(yarnrcPath)
+	}
+
+	if registry == "" {
+		registry = npmDefaultRegistry
+	}
+	metadata["registry"] = registry
+
+	if len(scopes) > 0 {
+		names := make([]string, 0, len(scopes))
+		for scope := range scopes {
+			names = append(names, scope)
+		}
+		sort.Strings(names)
+		metadata["authenticated_scopes"] = strings.Join(names, ",")
+	}
+
+	return metadata
+}
+
+// readNpmrc parses an .npmrc file into key/value pairs
+func readNpmrc(path string) map[string]string {
+	values := make(map[string]string)
+
+	file, err := os.Open(path)
+	if err != nil {
+		return values
+	}
+	defer func() { _ = file.Close() }()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			continue
+		}
+		values[strings.TrimSpace(key)] = strings.Trim(strings.TrimSpace(value), `"`)
+	}
+
+	return values
+}
+
+// npmrcHasAuth reports whether .npmrc holds credentials for a registry URL
+func npmrcHasAuth(npmrc map[string]string, registry string) bool {
+	// Credentials are keyed by the registry URL without its scheme
+	target := registry
+	if idx := strings.Index(target, "//"); idx >= 0 {
+		target = target[idx:]
+	}
+	target = strings.TrimSuffix(target, "/") + "/"
+
+	for key := range npmrc {
+		if !strings.HasPrefix(key, "//") {
+			continue
+		}
+		prefix, field, found := strings.Cut(key, "/:")
+		if !found || (field != "_authToken" && field != "_auth" && field != "_password") {
+			continue
+		}
+		if strings.HasPrefix(target, prefix+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// readYarnrcRegistry reads the registry setting of a yarn 1 .yarnrc file
+func readYarnrcRegistry(path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer func() { _ = file.Close() }()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == "registry" {
+			return strings.Trim(fields[1], `"`)
+		}
+	}
+
+	return ""
+}
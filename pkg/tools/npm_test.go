@@ -0,0 +1,124 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupNpmTool(t *testing.T) *NpmTool {
+	home := t.TempDir()
+	return &NpmTool{
+		NpmRCPath:     filepath.Join(home, ".npmrc"),
+		YarnRCPath:    filepath.Join(home, ".yarnrc"),
+		YarnRCYMLPath: filepath.Join(home, ".yarnrc.yml"),
+	}
+}
+
+func TestNpmTool_Name(t *testing.T) {
+	assert.Equal(t, "npm", NewNpmTool().Name())
+}
+
+func TestNpmTool_SnapshotRestore(t *testing.T) {
+	tool := setupNpmTool(t)
+	snapshotPath := filepath.Join(t.TempDir(), "snapshot")
+
+	require.NoError(t, os.WriteFile(tool.NpmRCPath, []byte("registry=https://npm.client-a.com/\n"), 0644))
+	require.NoError(t, tool.Snapshot(snapshotPath))
+	assert.FileExists(t, filepath.Join(snapshotPath, "npmrc"))
+	assert.NoFileExists(t, filepath.Join(snapshotPath, "yarnrc.yml"))
+
+	// Switch to another client's settings
+	require.NoError(t, os.WriteFile(tool.NpmRCPath, []byte("registry=https://npm.client-b.com/\n"), 0644))
+	require.NoError(t, os.WriteFile(tool.YarnRCYMLPath, []byte("npmAuthToken: b\n"), 0644))
+
+	require.NoError(t, tool.Restore(snapshotPath))
+
+	data, err := os.ReadFile(tool.NpmRCPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "client-a")
+	assert.NoFileExists(t, tool.YarnRCYMLPath, "files absent from the snapshot should be removed")
+
+	info, err := os.Stat(tool.NpmRCPath)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
+
+func TestNpmTool_GetMetadata(t *testing.T) {
+	t.Run("defaults to the public registry", func(t *testing.T) {
+		tool := setupNpmTool(t)
+
+		metadata, err := tool.GetMetadata()
+		require.NoError(t, err)
+		assert.Equal(t, npmDefaultRegistry, metadata["registry"])
+		assert.NotContains(t, metadata, "authenticated_scopes")
+	})
+
+	t.Run("reads npmrc registries and tokens", func(t *testing.T) {
+		tool := setupNpmTool(t)
+		npmrc := `registry=https://npm.company.com/
+@company:registry=https://npm.company.com/
+@other:registry=https://npm.pkg.github.com/
+@public:registry=https://registry.npmjs.org/
+//npm.company.com/:_authToken=abc
+//npm.pkg.github.com/:_authToken=${GITHUB_TOKEN}
+`
+		require.NoError(t, os.WriteFile(tool.NpmRCPath, []byte(npmrc), 0600))
+
+		metadata, err := tool.GetMetadata()
+		require.NoError(t, err)
+		assert.Equal(t, "https://npm.company.com/", metadata["registry"])
+		assert.Equal(t, "@company,@other", metadata["authenticated_scopes"])
+	})
+
+	t.Run("reads yarn settings", func(t *testing.T) {
+		tool := setupNpmTool(t)
+		yarnrc := `npmRegistryServer: "https://yarn.company.com"
+npmScopes:
+  company:
+    npmRegistryServer: "https://yarn.company.com"
+    npmAuthToken: "abc"
+  public:
+    npmRegistryServer: "https://registry.yarnpkg.com"
+`
+		require.NoError(t, os.WriteFile(tool.YarnRCYMLPath, []byte(yarnrc), 0600))
+
+		metadata, err := tool.GetMetadata()
+		require.NoError(t, err)
+		assert.Equal(t, "https://yarn.company.com", metadata["registry"])
+		assert.Equal(t, "@company", metadata["authenticated_scopes"])
+	})
+
+	t.Run("reads yarn 1 registry", func(t *testing.T) {
+		tool := setupNpmTool(t)
+		require.NoError(t, os.WriteFile(tool.YarnRCPath, []byte("registry \"https://yarn1.company.com\"\n"), 0600))
+
+		metadata, err := tool.GetMetadata()
+		require.NoError(t, err)
+		assert.Equal(t, "https://yarn1.company.com", metadata["registry"])
+	})
+}
+
+func TestNpmTool_Diff(t *testing.T) {
+	tool := setupNpmTool(t)
+	snapshotPath := filepath.Join(t.TempDir(), "snapshot")
+
+	require.NoError(t, os.WriteFile(tool.NpmRCPath, []byte("registry=https://a.com/\n"), 0600))
+	require.NoError(t, tool.Snapshot(snapshotPath))
+
+	changes, err := tool.Diff(snapshotPath)
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+
+	require.NoError(t, os.WriteFile(tool.NpmRCPath, []byte("registry=https://b.com/\n"), 0600))
+
+	changes, err = tool.Diff(snapshotPath)
+	require.NoError(t, err)
+	assert.Equal(t, []Change{
+		{Type: ChangeTypeModified, Path: "registry", OldValue: "https://a.com/", NewValue: "https://b.com/"},
+		{Type: ChangeTypeModified, Path: ".npmrc"},
+	}, changes)
+}
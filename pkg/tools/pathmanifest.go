@@ -0,0 +1,131 @@
+package tools
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/hugofrely/envswitch/internal/storage"
+)
+
+// recordSnapshot adds to manifest the files of snapshotPath, the copy of the
+// live config path livePath, keyed by key followed by their path inside it:
+// the paths diffs report. Copies take the modification time of their live
+// file so that the entry describes both, diffs then only hash the files that
+// changed since the snapshot.
+func recordSnapshot(manifest *storage.Manifest, livePath, snapshotPath, key string) error {
+	return filepath.Walk(snapshotPath, func(copyPath string, copyInfo os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !copyInfo.Mode().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(snapshotPath, copyPath)
+		if err != nil {
+			return err
+		}
+		// A file that changed while it was copied is left out, it is hashed
+		// when diffed
+		liveInfo, err := os.Stat(filepath.Join(livePath, rel))
+		if err != nil || liveInfo.Size() != copyInfo.Size() {
+			return nil
+		}
+
+		hash, err := storage.HashFile(copyPath)
+		if err != nil {
+			return err
+		}
+		if err := os.Chtimes(copyPath, liveInfo.ModTime(), liveInfo.ModTime()); err != nil {
+			return fmt.Errorf("failed to update times of %s: %w", copyPath, err)
+		}
+		manifest.Files[path.Join(key, filepath.ToSlash(rel))] = storage.ManifestEntry{
+			Size:    liveInfo.Size(),
+			ModTime: liveInfo.ModTime(),
+			Hash:    hash,
+		}
+		return nil
+	})
+}
+
+// liveManifest hashes the files of the live config paths
+func liveManifest(configPaths []string) (*storage.Manifest, error) {
+	manifest := &storage.Manifest{Files: make(map[string]storage.ManifestEntry)}
+
+	for _, configPath := range configPaths {
+		if _, err := os.Stat(configPath); os.IsNotExist(err) {
+			continue
+		}
+
+		key := filepath.Base(configPath)
+		err := filepath.Walk(configPath, func(filePath string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.Mode().IsRegular() {
+				return nil
+			}
+
+			rel, err := filepath.Rel(configPath, filePath)
+			if err != nil {
+				return err
+			}
+			hash, err := storage.HashFile(filePath)
+			if err != nil {
+				return err
+			}
+			manifest.Files[path.Join(key, filepath.ToSlash(rel))] = storage.ManifestEntry{
+				Size:    info.Size(),
+				ModTime: info.ModTime(),
+				Hash:    hash,
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", configPath, err)
+		}
+	}
+
+	return manifest, nil
+}
+
+// addManifestMetadata records in metadata the number of files of the live
+// config paths, their total size, when the last one was modified and a hash
+// of their contents that changes whenever one of them does
+func addManifestMetadata(metadata map[string]interface{}, configPaths []string) error {
+	manifest, err := liveManifest(configPaths)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(manifest.Files))
+	var totalSize int64
+	var lastModified time.Time
+	for key, entry := range manifest.Files {
+		keys = append(keys, key)
+		totalSize += entry.Size
+		if entry.ModTime.After(lastModified) {
+			lastModified = entry.ModTime
+		}
+	}
+	sort.Strings(keys)
+
+	hasher := sha256.New()
+	for _, key := range keys {
+		fmt.Fprintf(hasher, "%s %s\n", manifest.Files[key].Hash, key)
+	}
+
+	metadata["file_count"] = len(keys)
+	metadata["total_size"] = totalSize
+	metadata["content_hash"] = hex.EncodeToString(hasher.Sum(nil))
+	if !lastModified.IsZero() {
+		metadata["last_modified"] = lastModified.UTC().Format(time.RFC3339)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hugofrely/envswitch/internal/storage"
+)
+
+func TestGenericTool_SnapshotManifest(t *testing.T) {
+	tmpDir := t.TempDir()
+	configDir := filepath.Join(tmpDir, ".vim")
+	vimrc := filepath.Join(configDir, "vimrc")
+	writeTestFile(t, vimrc, "set number")
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	require.NoError(t, os.Chtimes(vimrc, modTime, modTime))
+
+	tool := NewGenericTool("vim", configDir)
+	snapshotPath := filepath.Join(tmpDir, "snapshot")
+	require.NoError(t, tool.Snapshot(snapshotPath))
+
+	manifest := storage.LoadManifest(snapshotPath)
+	require.Contains(t, manifest.Files, ".vim/vimrc")
+	entry := manifest.Files[".vim/vimrc"]
+	assert.Equal(t, int64(len("set number")), entry.Size)
+	assert.True(t, entry.ModTime.Equal(modTime))
+
+	copyInfo, err := os.Stat(filepath.Join(snapshotPath, ".vim", "vimrc"))
+	require.NoError(t, err)
+	assert.True(t, copyInfo.ModTime().Equal(modTime), "the copy keeps the live modification time")
+
+	t.Run("reuses the recorded hash of unchanged files", func(t *testing.T) {
+		// Same size and time: trusted without reading the content
+		writeTestFile(t, vimrc, "set nonumb")
+		require.NoError(t, os.Chtimes(vimrc, modTime, modTime))
+
+		changes, err := tool.Diff(snapshotPath)
+		require.NoError(t, err)
+		assert.Empty(t, changes)
+	})
+
+	t.Run("hashes files modified since", func(t *testing.T) {
+		later := modTime.Add(time.Minute)
+		require.NoError(t, os.Chtimes(vimrc, later, later))
+
+		changes, err := tool.Diff(snapshotPath)
+		require.NoError(t, err)
+		assert.Equal(t, []Change{{Type: ChangeTypeModified, Path: ".vim/vimrc"}}, changes)
+	})
+}
+
+func TestMultiPathTool_SnapshotManifest(t *testing.T) {
+	tmpDir := t.TempDir()
+	rcFile := filepath.Join(tmpDir, ".npmrc")
+	configDir := filepath.Join(tmpDir, ".npm")
+	writeTestFile(t, rcFile, "registry=a")
+	writeTestFile(t, filepath.Join(configDir, "config.json"), "{}")
+
+	tool := NewMultiPathTool("npm", []string{rcFile, configDir, filepath.Join(tmpDir, "missing")})
+	snapshotPath := filepath.Join(tmpDir, "snapshot")
+	require.NoError(t, tool.Snapshot(snapshotPath))
+
+	manifest := storage.LoadManifest(snapshotPath)
+	assert.Len(t, manifest.Files, 2)
+	assert.Contains(t, manifest.Files, ".npmrc")
+	assert.Contains(t, manifest.Files, ".npm/config.json")
+
+	changes, err := tool.Diff(snapshotPath)
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+}
+
+func TestGenericTool_GetMetadata(t *testing.T) {
+	tmpDir := t.TempDir()
+	configDir := filepath.Join(tmpDir, ".vim")
+	writeTestFile(t, filepath.Join(configDir, "vimrc"), "set number")
+	writeTestFile(t, filepath.Join(configDir, "plugin", "a.vim"), "abc")
+	modTime := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, os.Chtimes(filepath.Join(configDir, "plugin", "a.vim"), modTime, modTime))
+	require.NoError(t, os.Chtimes(filepath.Join(configDir, "vimrc"), modTime.Add(-time.Hour), modTime.Add(-time.Hour)))
+
+	tool := NewGenericTool("vim", configDir)
+	metadata, err := tool.GetMetadata()
+	require.NoError(t, err)
+
+	assert.Equal(t, true, metadata["config_exists"])
+	assert.Equal(t, "directory", metadata["config_type"])
+	assert.Equal(t, 2, metadata["file_count"])
+	assert.Equal(t, int64(13), metadata["total_size"])
+	assert.Equal(t, "2024-03-01T12:00:00Z", metadata["last_modified"])
+	require.NotEmpty(t, metadata["content_hash"])
+
+	t.Run("content hash follows the content", func(t *testing.T) {
+		writeTestFile(t, filepath.Join(configDir, "vimrc"), "set nonumber")
+		changed, err := tool.GetMetadata()
+		require.NoError(t, err)
+		assert.NotEqual(t, metadata["content_hash"], changed["content_hash"])
+	})
+
+	t.Run("missing config", func(t *testing.T) {
+		metadata, err := NewGenericTool("vim", filepath.Join(tmpDir, "missing")).GetMetadata()
+		require.NoError(t, err)
+		assert.Equal(t, false, metadata["config_exists"])
+		assert.Equal(t, 0, metadata["file_count"])
+		assert.NotContains(t, metadata, "last_modified")
+	})
+}
+
+func TestMultiPathTool_GetMetadata(t *testing.T) {
+	tmpDir := t.TempDir()
+	rcFile := filepath.Join(tmpDir, ".npmrc")
+	configDir := filepath.Join(tmpDir, ".npm")
+	writeTestFile(t, rcFile, "registry=a")
+	writeTestFile(t, filepath.Join(configDir, "config.json"), "{}")
+
+	tool := NewMultiPathTool("npm", []string{rcFile, configDir, filepath.Join(tmpDir, "missing")})
+	metadata, err := tool.GetMetadata()
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, metadata["path_count"])
+	assert.Equal(t, []string{rcFile, configDir}, metadata["existing_paths"])
+	assert.Equal(t, 2, metadata["file_count"])
+	assert.Equal(t, int64(12), metadata["total_size"])
+	assert.Contains(t, metadata, "last_modified")
+	assert.NotEmpty(t, metadata["content_hash"])
+}
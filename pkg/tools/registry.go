@@ -0,0 +1,79 @@
+package tools
+
+import (
+	"sort"
+	"sync"
+)
+
+// Registry holds the tools envswitch snapshots and restores, by name. Adding
+// a tool, built-in or plugin, is one Register call.
+type Registry struct {
+	mu    sync.RWMutex
+	tools map[string]Tool
+}
+
+// NewRegistry returns an empty registry
+func NewRegistry() *Registry {
+	return &Registry{tools: make(map[string]Tool)}
+}
+
+// Builtin returns a registry of the tools shipped with envswitch, with their
+// default settings
+func Builtin() *Registry {
+	r := NewRegistry()
+	r.Register(NewGitTool())
+	r.Register(NewAWSTool())
+	r.Register(NewGCloudTool())
+	r.Register(NewKubectlTool())
+	r.Register(NewDockerTool())
+	r.Register(NewTerraformTool())
+	r.Register(NewSSHTool())
+	r.Register(NewNpmTool())
+	return r
+}
+
+// Register adds a tool under its name, replacing the tool registered under
+// the same name if any
+func (r *Registry) Register(tool Tool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tools[tool.Name()] = tool
+}
+
+// Unregister removes the tool registered under name, if any
+func (r *Registry) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.tools, name)
+}
+
+// Get returns the tool registered under name
+func (r *Registry) Get(name string) (Tool, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	tool, ok := r.tools[name]
+	return tool, ok
+}
+
+// List returns the registered tools, sorted by name
+func (r *Registry) List() []Tool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	list := make([]Tool, 0, len(r.tools))
+	for _, tool := range r.tools {
+		list = append(list, tool)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name() < list[j].Name() })
+	return list
+}
+
+// Tools returns the registered tools by name. The map is a copy.
+func (r *Registry) Tools() map[string]Tool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	tools := make(map[string]Tool, len(r.tools))
+	for name, tool := range r.tools {
+		tools[name] = tool
+	}
+	return tools
+}
@@ -0,0 +1,47 @@
+package tools
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	r.Register(&MockTool{name: "zeta"})
+	r.Register(&MockTool{name: "alpha"})
+
+	tool, ok := r.Get("alpha")
+	assert.True(t, ok)
+	assert.Equal(t, "alpha", tool.Name())
+	_, ok = r.Get("missing")
+	assert.False(t, ok)
+
+	list := r.List()
+	assert.Len(t, list, 2)
+	assert.Equal(t, "alpha", list[0].Name())
+	assert.Equal(t, "zeta", list[1].Name())
+
+	// A tool registered again under the same name replaces the first one
+	replacement := &MockTool{name: "alpha", installed: true}
+	r.Register(replacement)
+	tool, _ = r.Get("alpha")
+	assert.Same(t, replacement, tool)
+	assert.Len(t, r.List(), 2)
+
+	r.Unregister("zeta")
+	assert.Len(t, r.Tools(), 1)
+
+	// Tools returns a copy
+	r.Tools()["other"] = &MockTool{name: "other"}
+	_, ok = r.Get("other")
+	assert.False(t, ok)
+}
+
+func TestBuiltin(t *testing.T) {
+	var names []string
+	for _, tool := range Builtin().List() {
+		names = append(names, tool.Name())
+	}
+	assert.Equal(t, []string{"aws", "docker", "gcloud", "git", "kubectl", "npm", "ssh", "terraform"}, names)
+}
@@ -0,0 +1,252 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/hugofrely/envswitch/internal/platform"
+	"github.com/hugofrely/envswitch/internal/storage"
+)
+
+const sshConfigFile = "config"
+
+// SSHTool implements the Tool interface for SSH (config, known_hosts and keys)
+type SSHTool struct {
+	SSHDir             string // ~/.ssh
+	IncludePrivateKeys bool   // snapshot private keys along with the config
+}
+
+// NewSSHTool creates a new SSH tool instance
+func NewSSHTool() *SSHTool {
+	home, _ := platform.HomeDir()
+	return &SSHTool{
+		SSHDir:             filepath.Join(home, ".ssh"),
+		IncludePrivateKeys: false,
+	}
+}
+
+func (s *SSHTool) Name() string {
+	return "ssh"
+}
+
+func (s *SSHTool) ConfigPaths() []string {
+	return []string{s.SSHDir}
+}
+
+func (s *SSHTool) IsInstalled() bool {
+	_, err := exec.LookPath("ssh")
+	return err == nil
+}
+
+func (s *SSHTool) Snapshot(snapshotPath string) error {
+	// Check if .ssh directory exists
+	if _, err := os.Stat(s.SSHDir); os.IsNotExist(err) {
+		return fmt.Errorf("ssh directory does not exist: %s", s.SSHDir)
+	}
+
+	// Start from an empty snapshot so excluded keys don't linger
+	if err := os.RemoveAll(snapshotPath); err != nil {
+		return fmt.Errorf("failed to clean snapshot directory: %w", err)
+	}
+	if err := os.MkdirAll(snapshotPath, 0700); err != nil {
+		return fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+
+	return s.walkFiles(s.SSHDir, func(relPath, srcPath string) error {
+		dstPath := filepath.Join(snapshotPath, relPath)
+		if err := os.MkdirAll(filepath.Dir(dstPath), 0700); err != nil {
+			return err
+		}
+		if err := storage.CopyFile(srcPath, dstPath); err != nil {
+			return fmt.Errorf("failed to copy %s: %w", relPath, err)
+		}
+		return nil
+	})
+}
+
+func (s *SSHTool) Restore(snapshotPath string) error {
+	// Validate snapshot first
+	if err := s.ValidateSnapshot(snapshotPath); err != nil {
+		return fmt.Errorf("invalid snapshot: %w", err)
+	}
+
+	if err := os.MkdirAll(s.SSHDir, 0700); err != nil {
+		return fmt.Errorf("failed to create ssh directory: %w", err)
+	}
+
+	// Move the files managed by snapshots aside, they are put back if the
+	// restore fails; private keys are kept when they are not part of snapshots
+	backup := storage.NewRestoreBackup(s.SSHDir)
+	if err := s.walkFiles(s.SSHDir, func(_, livePath string) error {
+		return backup.Save(livePath)
+	}); err != nil {
+		return backup.Finish(fmt.Errorf("failed to remove existing config: %w", err))
+	}
+
+	// Restore from snapshot
+	err := filepath.Walk(snapshotPath, func(srcPath string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		relPath, err := filepath.Rel(snapshotPath, srcPath)
+		if err != nil {
+			return err
+		}
+
+		dstPath := filepath.Join(s.SSHDir, relPath)
+		if err := os.MkdirAll(filepath.Dir(dstPath), 0700); err != nil {
+			return err
+		}
+		if err := backup.Overwrite(dstPath); err != nil {
+			return err
+		}
+		if err := storage.CopyFile(srcPath, dstPath); err != nil {
+			return err
+		}
+		// ssh refuses keys and configs that are readable by others
+		return os.Chmod(dstPath, sshFileMode(relPath))
+	})
+	if err != nil {
+		return backup.Finish(fmt.Errorf("failed to restore ssh config: %w", err))
+	}
+
+	if err := os.Chmod(s.SSHDir, 0700); err != nil {
+		return backup.Finish(fmt.Errorf("failed to set ssh directory permissions: %w", err))
+	}
+
+	return backup.Finish(nil)
+}
+
+func (s *SSHTool) GetMetadata() (map[string]interface{}, error) {
+	return sshMetadata(s.SSHDir), nil
+}
+
+func (s *SSHTool) ValidateSnapshot(snapshotPath string) error {
+	// Check if snapshot directory exists
+	if _, err := os.Stat(snapshotPath); os.IsNotExist(err) {
+		return fmt.Errorf("snapshot directory does not exist")
+	}
+
+	return nil
+}
+
+func (s *SSHTool) Diff(snapshotPath string) ([]Change, error) {
+	currentMeta := sshMetadata(s.SSHDir)
+	snapshotMeta := sshMetadata(snapshotPath)
+
+	changes := []Change{}
+
+	// Compare configured hosts
+	changes = append(changes, compareMetadataField("hosts_count", snapshotMeta, currentMeta)...)
+
+	// Compare files
+	fileChanges, err := diffTree(snapshotPath, s.SSHDir, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff ssh config: %w", err)
+	}
+	for _, change := range fileChanges {
+		// Live private keys are expected to be missing from snapshots that exclude them
+		if change.Type == ChangeTypeAdded && !s.IncludePrivateKeys && isPrivateKey(filepath.Join(s.SSHDir, change.Path)) {
+			continue
+		}
+		changes = append(changes, change)
+	}
+
+	return changes, nil
+}
+
+// walkFiles calls fn for every regular file under root that is part of a
+// snapshot. Sockets (agent, ControlMaster) and excluded private keys are skipped.
+func (s *SSHTool) walkFiles(root string, fn func(relPath, path string) error) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		if !s.IncludePrivateKeys && isPrivateKey(path) {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		return fn(relPath, path)
+	})
+}
+
+// isPrivateKey reports whether a file is a PEM or OpenSSH private key
+func isPrivateKey(path string) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer func() { _ = file.Close() }()
+
+	header := make([]byte, 64)
+	n, _ := io.ReadFull(file, header)
+	header = header[:n]
+
+	return bytes.HasPrefix(header, []byte("-----BEGIN")) && bytes.Contains(header, []byte("PRIVATE KEY"))
+}
+
+// sshFileMode returns the permissions ssh expects for a file in ~/.ssh
+func sshFileMode(relPath string) os.FileMode {
+	name := filepath.Base(relPath)
+	if strings.HasSuffix(name, ".pub") || strings.HasPrefix(name, "known_hosts") {
+		return 0644
+	}
+	return 0600
+}
+
+// sshMetadata extracts metadata from an ssh directory (live or snapshot)
+func sshMetadata(dir string) map[string]interface{} {
+	metadata := make(map[string]interface{})
+
+	metadata["hosts_count"] = countSSHHosts(filepath.Join(dir, sshConfigFile))
+
+	keys := 0
+	_ = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() && isPrivateKey(path) {
+			keys++
+		}
+		return nil
+	})
+	metadata["keys_count"] = keys
+
+	return metadata
+}
+
+// countSSHHosts counts the non-wildcard Host patterns of an ssh config file
+func countSSHHosts(configPath string) int {
+	file, err := os.Open(configPath)
+	if err != nil {
+		return 0
+	}
+	defer func() { _ = file.Close() }()
+
+	count := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || !strings.EqualFold(fields[0], "Host") {
+			continue
+		}
+		for _, pattern := range fields[1:] {
+			if !strings.ContainsAny(pattern, "*?!") {
+				count++
+			}
+		}
+	}
+
+	return count
+}
@@ -0,0 +1,337 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hugofrely/envswitch/internal/platform"
+	"github.com/hugofrely/envswitch/internal/storage"
+)
+
+const (
+	terraformSnapshotDir      = "terraform.d"
+	terraformRCSnapshotFile   = "terraformrc"
+	terraformWorkspaceFile    = "workspace"
+	terraformCredentialsFile  = "credentials.tfrc.json"
+	terraformPluginCacheDir   = "plugin-cache"
+	terraformDefaultWorkspace = "default"
+)
+
+// TerraformTool implements the Tool interface for Terraform
+type TerraformTool struct {
+	TerraformConfigDir string // ~/.terraform.d, %APPDATA%\terraform.d on Windows
+	TerraformRCPath    string // ~/.terraformrc, %APPDATA%\terraform.rc on Windows
+}
+
+// NewTerraformTool creates a new Terraform tool instance
+func NewTerraformTool() *TerraformTool {
+	if platform.IsWindows() {
+		appData, _ := platform.ConfigDir()
+		return &TerraformTool{
+			TerraformConfigDir: filepath.Join(appData, "terraform.d"),
+			TerraformRCPath:    filepath.Join(appData, "terraform.rc"),
+		}
+	}
+
+	home, _ := platform.HomeDir()
+	return &TerraformTool{
+		TerraformConfigDir: filepath.Join(home, ".terraform.d"),
+		TerraformRCPath:    filepath.Join(home, ".terraformrc"),
+	}
+}
+
+func (t *TerraformTool) Name() string {
+	return "terraform"
+}
+
+func (t *TerraformTool) ConfigPaths() []string {
+	return []string{t.TerraformConfigDir, t.TerraformRCPath}
+}
+
+func (t *TerraformTool) IsInstalled() bool {
+	_, err := exec.LookPath("terraform")
+	return err == nil
+}
+
+func (t *TerraformTool) Snapshot(snapshotPath string) error {
+	return t.SnapshotContext(context.Background(), snapshotPath)
+}
+
+// SnapshotContext copies the config, then records the active workspace,
+// killing terraform when ctx is done
+func (t *TerraformTool) SnapshotContext(ctx context.Context, snapshotPath string) error {
+	// Check if .terraform.d directory exists
+	if _, err := os.Stat(t.TerraformConfigDir); os.IsNotExist(err) {
+		return fmt.Errorf("terraform config directory does not exist: %s", t.TerraformConfigDir)
+	}
+
+	// Create snapshot directory
+	destDir := filepath.Join(snapshotPath, terraformSnapshotDir)
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+
+	// Copy .terraform.d, skipping the provider plugin cache which can be huge
+	// and is not environment specific
+	entries, err := os.ReadDir(t.TerraformConfigDir)
+	if err != nil {
+		return fmt.Errorf("failed to read terraform config directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		if entry.Name() == terraformPluginCacheDir {
+			continue
+		}
+
+		srcPath := filepath.Join(t.TerraformConfigDir, entry.Name())
+		dstPath := filepath.Join(destDir, entry.Name())
+
+		if entry.IsDir() {
+			err = storage.CopyDir(srcPath, dstPath)
+		} else {
+			err = storage.CopyFile(srcPath, dstPath)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to copy terraform config: %w", err)
+		}
+	}
+
+	// Also copy .terraformrc (CLI config, e.g. plugin_cache_dir) if it exists
+	if _, err := os.Stat(t.TerraformRCPath); err == nil {
+		destPath := filepath.Join(snapshotPath, terraformRCSnapshotFile)
+		if err := storage.CopyFile(t.TerraformRCPath, destPath); err != nil {
+			return fmt.Errorf("failed to copy .terraformrc: %w", err)
+		}
+	}
+
+	// Record the active workspace so it can be selected again on restore
+	workspace := t.currentWorkspace(ctx)
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("failed to read terraform workspace: %w", err)
+	}
+	if workspace != "" {
+		workspacePath := filepath.Join(snapshotPath, terraformWorkspaceFile)
+		if err := os.WriteFile(workspacePath, []byte(workspace+"\n"), 0644); err != nil {
+			return fmt.Errorf("failed to write workspace file: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (t *TerraformTool) Restore(snapshotPath string) error {
+	return t.RestoreContext(context.Background(), snapshotPath)
+}
+
+// RestoreContext restores the config, then selects the recorded workspace,
+// killing terraform when ctx is done
+func (t *TerraformTool) RestoreContext(ctx context.Context, snapshotPath string) error {
+	// Validate snapshot first
+	if err := t.ValidateSnapshot(snapshotPath); err != nil {
+		return fmt.Errorf("invalid snapshot: %w", err)
+	}
+
+	// Create parent directory if it doesn't exist
+	configParent := filepath.Dir(t.TerraformConfigDir)
+	if err := os.MkdirAll(configParent, 0755); err != nil {
+		return fmt.Errorf("failed to create config parent directory: %w", err)
+	}
+
+	// Keep the local plugin cache across switches
+	cacheDir := filepath.Join(t.TerraformConfigDir, terraformPluginCacheDir)
+	var savedCache string
+	if _, err := os.Stat(cacheDir); err == nil {
+		savedCache = filepath.Join(configParent, ".terraform.d-plugin-cache.tmp")
+		_ = os.RemoveAll(savedCache)
+		if err := os.Rename(cacheDir, savedCache); err != nil {
+			savedCache = ""
+		}
+	}
+
+	// Replace the config directory with the snapshot, the previous one is put
+	// back if the copy fails
+	err := storage.ReplaceSafely(t.TerraformConfigDir, func() error {
+		return storage.CopyDir(filepath.Join(snapshotPath, terraformSnapshotDir), t.TerraformConfigDir)
+	})
+
+	if savedCache != "" {
+		if err := os.Rename(savedCache, cacheDir); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to restore terraform plugin cache: %v\n", err)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to restore terraform config: %w", err)
+	}
+
+	// Restore .terraformrc if it exists in snapshot
+	srcRCPath := filepath.Join(snapshotPath, terraformRCSnapshotFile)
+	if _, err := os.Stat(srcRCPath); err == nil {
+		if err := storage.ReplaceSafely(t.TerraformRCPath, func() error {
+			return storage.CopyFile(srcRCPath, t.TerraformRCPath)
+		}); err != nil {
+			return fmt.Errorf("failed to restore .terraformrc: %w", err)
+		}
+	}
+
+	// Select the recorded workspace if we are inside an initialized terraform project
+	if workspace := readSnapshotWorkspace(snapshotPath); workspace != "" && t.IsInstalled() {
+		if _, err := os.Stat(".terraform"); err == nil && workspace != t.currentWorkspace(ctx) {
+			if err := exec.CommandContext(ctx, "terraform", "workspace", "select", workspace).Run(); err != nil {
+				if ctxErr := ctx.Err(); ctxErr != nil {
+					return fmt.Errorf("failed to select terraform workspace '%s': %w", workspace, ctxErr)
+				}
+				fmt.Fprintf(os.Stderr, "Warning: failed to select terraform workspace '%s': %v\n", workspace, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (t *TerraformTool) GetMetadata() (map[string]interface{}, error) {
+	if !t.IsInstalled() {
+		return nil, fmt.Errorf("terraform is not installed")
+	}
+
+	metadata := make(map[string]interface{})
+
+	// Get active workspace
+	if workspace := t.currentWorkspace(context.Background()); workspace != "" {
+		metadata["workspace"] = workspace
+	}
+
+	// Get hosts with stored credentials
+	if hosts := credentialHosts(filepath.Join(t.TerraformConfigDir, terraformCredentialsFile)); len(hosts) > 0 {
+		metadata["credential_hosts"] = strings.Join(hosts, ",")
+	}
+
+	return metadata, nil
+}
+
+func (t *TerraformTool) ValidateSnapshot(snapshotPath string) error {
+	// Check if snapshot directory exists
+	if _, err := os.Stat(snapshotPath); os.IsNotExist(err) {
+		return fmt.Errorf("snapshot directory does not exist")
+	}
+
+	// Check for the terraform.d directory
+	configPath := filepath.Join(snapshotPath, terraformSnapshotDir)
+	if _, err := os.Stat(configPath); os.IsNotExist(err) {
+		return fmt.Errorf("missing required directory: %s", terraformSnapshotDir)
+	}
+
+	return nil
+}
+
+func (t *TerraformTool) Diff(snapshotPath string) ([]Change, error) {
+	// Get current metadata
+	currentMeta, err := t.GetMetadata()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current metadata: %w", err)
+	}
+
+	// Get snapshot metadata
+	snapshotMeta, err := t.getSnapshotMetadata(snapshotPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get snapshot metadata: %w", err)
+	}
+
+	changes := []Change{}
+
+	// Compare workspace
+	changes = append(changes, compareMetadataField("workspace", snapshotMeta, currentMeta)...)
+
+	// Compare credential hosts
+	changes = append(changes, compareMetadataField("credential_hosts", snapshotMeta, currentMeta)...)
+
+	// Compare config files, ignoring the plugin cache which is never snapshotted
+	fileChanges, err := diffTree(filepath.Join(snapshotPath, terraformSnapshotDir), t.TerraformConfigDir, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff terraform config: %w", err)
+	}
+	for _, change := range fileChanges {
+		if !strings.HasPrefix(change.Path, terraformPluginCacheDir+"/") {
+			changes = append(changes, change)
+		}
+	}
+
+	return changes, nil
+}
+
+// getSnapshotMetadata reads metadata from the snapshot files
+func (t *TerraformTool) getSnapshotMetadata(snapshotPath string) (map[string]interface{}, error) {
+	metadata := make(map[string]interface{})
+
+	if workspace := readSnapshotWorkspace(snapshotPath); workspace != "" {
+		metadata["workspace"] = workspace
+	}
+
+	credentialsPath := filepath.Join(snapshotPath, terraformSnapshotDir, terraformCredentialsFile)
+	if hosts := credentialHosts(credentialsPath); len(hosts) > 0 {
+		metadata["credential_hosts"] = strings.Join(hosts, ",")
+	}
+
+	return metadata, nil
+}
+
+// currentWorkspace returns the active workspace, honoring TF_WORKSPACE
+func (t *TerraformTool) currentWorkspace(ctx context.Context) string {
+	if workspace := os.Getenv("TF_WORKSPACE"); workspace != "" {
+		return workspace
+	}
+	if !t.IsInstalled() {
+		return ""
+	}
+	if workspace := t.execCommand(ctx, "terraform", "workspace", "show"); workspace != "" {
+		return workspace
+	}
+	return terraformDefaultWorkspace
+}
+
+// readSnapshotWorkspace reads the workspace recorded in a snapshot
+func readSnapshotWorkspace(snapshotPath string) string {
+	data, err := os.ReadFile(filepath.Join(snapshotPath, terraformWorkspaceFile))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// credentialHosts returns the sorted list of hosts in a credentials.tfrc.json file
+func credentialHosts(credentialsPath string) []string {
+	data, err := os.ReadFile(credentialsPath)
+	if err != nil {
+		return nil
+	}
+
+	var credentials struct {
+		Credentials map[string]interface{} `json:"credentials"`
+	}
+	if err := json.Unmarshal(data, &credentials); err != nil {
+		return nil
+	}
+
+	hosts := make([]string, 0, len(credentials.Credentials))
+	for host := range credentials.Credentials {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	return hosts
+}
+
+// execCommand executes a command and returns the output
+func (t *TerraformTool) execCommand(ctx context.Context, name string, args ...string) string {
+	cmd := exec.CommandContext(ctx, name, args...)
+	output, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
@@ -0,0 +1,127 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupTerraformTool(t *testing.T) (*TerraformTool, string) {
+	tmpDir := t.TempDir()
+
+	configDir := filepath.Join(tmpDir, ".terraform.d")
+	require.NoError(t, os.MkdirAll(filepath.Join(configDir, "plugin-cache", "registry"), 0755))
+
+	credentials := `{"credentials": {"app.terraform.io": {"token": "abc"}, "tfe.example.com": {"token": "def"}}}`
+	require.NoError(t, os.WriteFile(filepath.Join(configDir, "credentials.tfrc.json"), []byte(credentials), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(configDir, "plugin-cache", "registry", "provider"), []byte("binary"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, ".terraformrc"), []byte(`plugin_cache_dir = "$HOME/.terraform.d/plugin-cache"`), 0644))
+
+	return &TerraformTool{
+		TerraformConfigDir: configDir,
+		TerraformRCPath:    filepath.Join(tmpDir, ".terraformrc"),
+	}, tmpDir
+}
+
+func TestTerraformTool_Name(t *testing.T) {
+	assert.Equal(t, "terraform", NewTerraformTool().Name())
+}
+
+func TestTerraformTool_Snapshot(t *testing.T) {
+	t.Setenv("TF_WORKSPACE", "staging")
+	tool, tmpDir := setupTerraformTool(t)
+
+	snapshotPath := filepath.Join(tmpDir, "snapshot")
+	require.NoError(t, tool.Snapshot(snapshotPath))
+
+	assert.FileExists(t, filepath.Join(snapshotPath, "terraform.d", "credentials.tfrc.json"))
+	assert.FileExists(t, filepath.Join(snapshotPath, "terraformrc"))
+	assert.NoDirExists(t, filepath.Join(snapshotPath, "terraform.d", "plugin-cache"))
+	assert.Equal(t, "staging", readSnapshotWorkspace(snapshotPath))
+}
+
+func TestTerraformTool_SnapshotMissingConfig(t *testing.T) {
+	tool := &TerraformTool{TerraformConfigDir: filepath.Join(t.TempDir(), "missing")}
+
+	err := tool.Snapshot(filepath.Join(t.TempDir(), "snapshot"))
+	assert.Error(t, err)
+}
+
+func TestTerraformTool_Restore(t *testing.T) {
+	t.Setenv("TF_WORKSPACE", "staging")
+	tool, tmpDir := setupTerraformTool(t)
+
+	snapshotPath := filepath.Join(tmpDir, "snapshot")
+	require.NoError(t, tool.Snapshot(snapshotPath))
+
+	// Change the live credentials, then restore
+	credentialsPath := filepath.Join(tool.TerraformConfigDir, "credentials.tfrc.json")
+	require.NoError(t, os.WriteFile(credentialsPath, []byte(`{"credentials": {}}`), 0600))
+	require.NoError(t, os.Remove(tool.TerraformRCPath))
+
+	require.NoError(t, tool.Restore(snapshotPath))
+
+	assert.Equal(t, []string{"app.terraform.io", "tfe.example.com"}, credentialHosts(credentialsPath))
+	assert.FileExists(t, tool.TerraformRCPath)
+	// The plugin cache is kept across restores
+	assert.FileExists(t, filepath.Join(tool.TerraformConfigDir, "plugin-cache", "registry", "provider"))
+}
+
+func TestTerraformTool_ValidateSnapshot(t *testing.T) {
+	tool := NewTerraformTool()
+
+	t.Run("fails on missing directory", func(t *testing.T) {
+		assert.Error(t, tool.ValidateSnapshot(filepath.Join(t.TempDir(), "missing")))
+	})
+
+	t.Run("fails without terraform.d", func(t *testing.T) {
+		assert.Error(t, tool.ValidateSnapshot(t.TempDir()))
+	})
+
+	t.Run("accepts valid snapshot", func(t *testing.T) {
+		snapshotPath := t.TempDir()
+		require.NoError(t, os.MkdirAll(filepath.Join(snapshotPath, "terraform.d"), 0755))
+		assert.NoError(t, tool.ValidateSnapshot(snapshotPath))
+	})
+}
+
+func TestTerraformTool_GetSnapshotMetadata(t *testing.T) {
+	t.Setenv("TF_WORKSPACE", "prod")
+	tool, tmpDir := setupTerraformTool(t)
+
+	snapshotPath := filepath.Join(tmpDir, "snapshot")
+	require.NoError(t, tool.Snapshot(snapshotPath))
+
+	metadata, err := tool.getSnapshotMetadata(snapshotPath)
+	require.NoError(t, err)
+	assert.Equal(t, "prod", metadata["workspace"])
+	assert.Equal(t, "app.terraform.io,tfe.example.com", metadata["credential_hosts"])
+}
+
+func TestTerraformTool_RestoreFailureKeepsConfig(t *testing.T) {
+	tool, tmpDir := setupTerraformTool(t)
+
+	snapshotPath := filepath.Join(tmpDir, "snapshot")
+	require.NoError(t, tool.Snapshot(snapshotPath))
+	// A file the copy cannot read makes the restore fail halfway
+	if err := os.Symlink(filepath.Join(tmpDir, "missing"), filepath.Join(snapshotPath, "terraform.d", "zz-broken")); err != nil {
+		t.Skipf("Symlinks not supported: %v", err)
+	}
+
+	credentialsPath := filepath.Join(tool.TerraformConfigDir, "credentials.tfrc.json")
+	require.NoError(t, os.WriteFile(credentialsPath, []byte(`{"credentials": {"live.example.com": {"token": "x"}}}`), 0600))
+
+	assert.Error(t, tool.Restore(snapshotPath))
+
+	// The live config is back as it was
+	assert.Equal(t, []string{"live.example.com"}, credentialHosts(credentialsPath))
+	assert.FileExists(t, filepath.Join(tool.TerraformConfigDir, "plugin-cache", "registry", "provider"))
+	entries, err := os.ReadDir(tmpDir)
+	require.NoError(t, err)
+	for _, entry := range entries {
+		assert.NotContains(t, entry.Name(), ".envswitch-restore-")
+	}
+}
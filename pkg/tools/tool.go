@@ -1,6 +1,12 @@
 package tools
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+
+	"github.com/hugofrely/envswitch/internal/redact"
+	"github.com/hugofrely/envswitch/internal/storage"
+)
 
 // Tool is the interface that all tool integrations must implement
 type Tool interface {
@@ -26,6 +32,50 @@ type Tool interface {
 	Diff(snapshotPath string) ([]Change, error)
 }
 
+// PathProvider is implemented by tools that can report the live files and
+// directories they snapshot
+type PathProvider interface {
+	// ConfigPaths returns the paths captured by Snapshot
+	ConfigPaths() []string
+}
+
+// Excluder is implemented by tools that snapshot directories and can leave
+// out the paths matching exclude patterns, see storage.IsExcluded
+type Excluder interface {
+	// AddExcludePatterns adds patterns to the ones the tool excludes by default
+	AddExcludePatterns(patterns ...string)
+}
+
+// Verifier is implemented by tools that can check the live state matches a
+// snapshot after it was restored
+type Verifier interface {
+	// Verify returns an error describing how the live state differs
+	Verify(snapshotPath string) error
+}
+
+// ProgressReporter is implemented by tools copying directories that can
+// report the progress of each file, for large snapshots
+type ProgressReporter interface {
+	// SetProgress sets where the next snapshots and restores report their
+	// progress, nil for nowhere
+	SetProgress(progress storage.Progress)
+}
+
+// ContextTool is implemented by tools whose snapshot and restore can be
+// interrupted, typically because they run commands. They stop when ctx is
+// done and return its error.
+type ContextTool interface {
+	SnapshotContext(ctx context.Context, snapshotPath string) error
+	RestoreContext(ctx context.Context, snapshotPath string) error
+}
+
+// Merger is implemented by tools that can merge a snapshot into the live
+// configuration instead of replacing it: what the snapshot has wins, what it
+// does not have is kept. It stops when ctx is done and returns its error.
+type Merger interface {
+	Merge(ctx context.Context, snapshotPath string) error
+}
+
 // Change represents a difference between two states
 type Change struct {
 	Type     ChangeType `json:"type"`
@@ -34,6 +84,14 @@ type Change struct {
 	NewValue string     `json:"new_value,omitempty"`
 }
 
+// Redacted returns the change with the secrets of its values masked, as
+// shown to users
+func (c Change) Redacted() Change {
+	c.OldValue = redact.Value(c.Path, c.OldValue)
+	c.NewValue = redact.Value(c.Path, c.NewValue)
+	return c
+}
+
 // ChangeType represents the type of change
 type ChangeType string
 
@@ -97,6 +97,20 @@ func (m *MockTool) Diff(snapshotPath string) ([]Change, error) {
 	return m.diffChanges, m.diffErr
 }
 
+func TestChangeRedacted(t *testing.T) {
+	change := Change{Type: ChangeTypeModified, Path: "default/aws_secret_access_key", OldValue: "old", NewValue: "new"}
+	redacted := change.Redacted()
+	assert.Equal(t, "[REDACTED]", redacted.OldValue)
+	assert.Equal(t, "[REDACTED]", redacted.NewValue)
+	assert.Equal(t, "old", change.OldValue, "the change itself is not modified")
+
+	change = Change{Type: ChangeTypeAdded, Path: "config", NewValue: "token: abc123"}
+	assert.Equal(t, "token: [REDACTED]", change.Redacted().NewValue)
+
+	change = Change{Type: ChangeTypeModified, Path: "region", OldValue: "eu-west-1", NewValue: "us-east-1"}
+	assert.Equal(t, change, change.Redacted())
+}
+
 func TestCompareMetadataField(t *testing.T) {
 	t.Run("detects added field", func(t *testing.T) {
 		oldMeta := map[string]interface{}{}